@@ -0,0 +1,185 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/indiependente/pkg/buildinfo"
+	"github.com/indiependente/pkg/debug"
+	"github.com/indiependente/pkg/featureflag"
+	"github.com/indiependente/pkg/healthcheck"
+	"github.com/indiependente/pkg/http/server"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/shutdown"
+)
+
+const defaultAddr = ":9091"
+
+// FlagLister is implemented by the feature flag providers able to list their flags, e.g. featureflag.Static
+// and featureflag.Remote.
+type FlagLister interface {
+	Flags() map[string]featureflag.Flag
+}
+
+// Option customises the Admin.
+type Option func(*Admin)
+
+// WithAddr serves the admin endpoints on addr instead of :9091.
+func WithAddr(addr string) Option {
+	return func(a *Admin) {
+		a.addr = addr
+	}
+}
+
+// WithHealth serves the /livez, /readyz and /healthz endpoints of the checker. They are never authenticated,
+// so that orchestrators can probe them.
+func WithHealth(c *healthcheck.Checker) Option {
+	return func(a *Admin) {
+		a.health = c
+	}
+}
+
+// WithMetrics serves the metrics of the registry on /metrics.
+func WithMetrics(r *metrics.Registry) Option {
+	return func(a *Admin) {
+		a.handle("/metrics", r.Handler())
+	}
+}
+
+// WithDebug serves the pprof profiles and the runtime diagnostics of the debug package under /debug/.
+func WithDebug() Option {
+	return func(a *Admin) {
+		a.handle("/debug/", debug.Handler())
+	}
+}
+
+// WithFlags serves the feature flags of the provider as JSON on /flags.
+func WithFlags(l FlagLister) Option {
+	return func(a *Admin) {
+		a.handle("/flags", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, l.Flags())
+		}))
+	}
+}
+
+// WithHandler serves the handler in input on the pattern, e.g. the status of a scheduler.
+func WithHandler(pattern string, h http.Handler) Option {
+	return func(a *Admin) {
+		a.handle(pattern, h)
+	}
+}
+
+// WithAuth authenticates the requests to every endpoint but the health ones with the middleware in input,
+// e.g. middleware.AuthAPIKey.
+func WithAuth(mw func(http.Handler) http.Handler) Option {
+	return func(a *Admin) {
+		a.auth = mw
+	}
+}
+
+// WithBearerToken authenticates the requests to every endpoint but the health ones with the static token in input,
+// sent in the Authorization header as "Bearer <token>".
+func WithBearerToken(token string) Option {
+	return WithAuth(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// WithServerOptions customises the admin server, e.g. with server.WithShutdownTimeout.
+func WithServerOptions(opts ...server.Option) Option {
+	return func(a *Admin) {
+		a.serverOpts = append(a.serverOpts, opts...)
+	}
+}
+
+// Admin serves the operations endpoints of a service on a dedicated listener: health, metrics, log level,
+// build information, feature flags and, if enabled, the debug diagnostics. The endpoints expose internals of the
+// process and must never be served on the main listener.
+type Admin struct {
+	addr       string
+	health     *healthcheck.Checker
+	auth       func(http.Handler) http.Handler
+	serverOpts []server.Option
+
+	mux      *http.ServeMux
+	patterns []string
+	srv      *server.Server
+}
+
+// New returns an Admin serving the endpoints enabled by the options in input, along with:
+//
+//	/          the list of the endpoints
+//	/loglevel  the logger level, see logger.LevelHandler
+//	/buildinfo the build information, see buildinfo.Handler
+func New(opts ...Option) *Admin {
+	a := &Admin{addr: defaultAddr, mux: http.NewServeMux()}
+	a.handle("/loglevel", logger.LevelHandler())
+	a.handle("/buildinfo", buildinfo.Handler())
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.handle("/{$}", http.HandlerFunc(a.index))
+
+	var handler http.Handler = a.mux
+	if a.auth != nil {
+		handler = a.auth(handler)
+	}
+	root := http.NewServeMux()
+	root.Handle("/", handler)
+	if a.health != nil {
+		a.health.Mount(root)
+		a.patterns = append(a.patterns, "/livez", "/readyz", "/healthz")
+	}
+	// writes never time out, so that CPU profiles and traces can be collected for as long as requested
+	serverOpts := append([]server.Option{server.WithAddr(a.addr), server.WithWriteTimeout(0)}, a.serverOpts...)
+	a.srv = server.New(root, serverOpts...)
+	return a
+}
+
+func (a *Admin) handle(pattern string, h http.Handler) {
+	a.mux.Handle(pattern, h)
+	if pattern != "/{$}" {
+		a.patterns = append(a.patterns, pattern)
+	}
+}
+
+func (a *Admin) index(w http.ResponseWriter, _ *http.Request) {
+	patterns := slices.Clone(a.patterns)
+	slices.Sort(patterns)
+	writeJSON(w, map[string][]string{"endpoints": patterns})
+}
+
+// Handler returns the handler serving the admin endpoints, e.g. to serve them on a listener of choice.
+func (a *Admin) Handler() http.Handler {
+	return a.srv.Handler
+}
+
+// Run serves the admin endpoints until ctx is done, then shuts the server down gracefully.
+// It is meant to be run in its own goroutine, e.g. alongside shutdown.Wait or as a runner worker.
+func (a *Admin) Run(ctx context.Context) error {
+	return a.srv.Run(ctx)
+}
+
+// TerminationFn returns a shutdown.TerminationFn gracefully shutting the admin server down.
+func (a *Admin) TerminationFn() shutdown.TerminationFn {
+	return a.srv.TerminationFn()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(v)
+}
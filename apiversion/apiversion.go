@@ -0,0 +1,263 @@
+package apiversion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/httpx/respond"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	versionEvent = "api_version"
+
+	// DefaultHeader is the header read by FromHeader when no name is given.
+	DefaultHeader = "API-Version"
+)
+
+// pathVersionRE matches the version segments of paths, e.g. v1 or v2.1.
+var pathVersionRE = regexp.MustCompile(`^v\d+(\.\d+)?$`)
+
+// acceptVersionRE matches the version in vendor media types, e.g. application/vnd.acme.v2+json.
+var acceptVersionRE = regexp.MustCompile(`^application/vnd\.[^.]+(?:\.[^.]+)*?\.(v\d+(?:\.\d+)?)(?:\+[a-z]+)?$`)
+
+type contextKey struct{}
+
+// NewContext stores the API version in the context.
+func NewContext(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, contextKey{}, version)
+}
+
+// FromContext returns the API version stored in the context by the middleware.
+func FromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(contextKey{}).(string)
+	return v, ok
+}
+
+// Resolver extracts the requested API version from the request, returning false if it does not carry one.
+// It may rewrite the request, e.g. stripping the version from the path.
+type Resolver func(r *http.Request) (string, bool)
+
+// FromPath reads the version from the first segment of the path, e.g. /v2/users.
+// With strip, the segment is removed so that routes can be registered without it.
+func FromPath(strip bool) Resolver {
+	return func(r *http.Request) (string, bool) {
+		rest := strings.TrimPrefix(r.URL.Path, "/")
+		segment, tail, _ := strings.Cut(rest, "/")
+		if !pathVersionRE.MatchString(segment) {
+			return "", false
+		}
+		if strip {
+			u := *r.URL
+			u.Path = "/" + tail
+			u.RawPath = ""
+			r.URL = &u
+		}
+		return segment, true
+	}
+}
+
+// FromAccept reads the version from the Accept header, either as a vendor media type,
+// e.g. application/vnd.acme.v2+json, or as a version parameter, e.g. application/json; version=2.
+// Parameter versions are prefixed with v if they are numeric, so that both forms resolve to the same version.
+func FromAccept() Resolver {
+	return func(r *http.Request) (string, bool) {
+		for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			if m := acceptVersionRE.FindStringSubmatch(mediaType); m != nil {
+				return m[1], true
+			}
+			if v, ok := params["version"]; ok && v != "" {
+				return normalise(v), true
+			}
+		}
+		return "", false
+	}
+}
+
+// FromHeader reads the version from the header in input, DefaultHeader if empty.
+func FromHeader(name string) Resolver {
+	if name == "" {
+		name = DefaultHeader
+	}
+	return func(r *http.Request) (string, bool) {
+		v := strings.TrimSpace(r.Header.Get(name))
+		if v == "" {
+			return "", false
+		}
+		return normalise(v), true
+	}
+}
+
+// normalise prefixes numeric versions with v.
+func normalise(v string) string {
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return "v" + v
+	}
+	return v
+}
+
+// Deprecation describes a deprecated version.
+type Deprecation struct {
+	// Since is when the version was deprecated, sent in the Deprecation header. The zero value sends "true".
+	Since time.Time
+	// Sunset is when the version will stop being served, sent in the Sunset header if not zero.
+	Sunset time.Time
+	// Link points to the migration guide, sent as a Link header with the deprecation relation if not empty.
+	Link string
+}
+
+// ErrUnsupported is returned for requests asking for a version which is not supported.
+var ErrUnsupported = errors.New("unsupported API version")
+
+// Option customises the versioning middleware.
+type Option func(*config)
+
+type config struct {
+	supported  map[string]struct{}
+	def        string
+	resolvers  []Resolver
+	deprecated map[string]Deprecation
+	log        logger.Logger
+	requests   *prometheus.CounterVec
+}
+
+// WithDefault serves the requests not carrying a version with the one in input. Without a default,
+// such requests are rejected.
+func WithDefault(version string) Option {
+	return func(c *config) {
+		c.def = version
+	}
+}
+
+// WithResolvers resolves the version with the resolvers in input, in order, instead of the default ones:
+// the path, without stripping it, the API-Version header and the Accept header.
+func WithResolvers(resolvers ...Resolver) Option {
+	return func(c *config) {
+		c.resolvers = resolvers
+	}
+}
+
+// WithDeprecated marks the version as deprecated: responses carry the Deprecation, Sunset and Link headers
+// of RFC 9745 and RFC 8594, and requests are logged at warning level.
+func WithDeprecated(version string, d Deprecation) Option {
+	return func(c *config) {
+		c.deprecated[version] = d
+	}
+}
+
+// WithLogger logs the requests to deprecated versions.
+func WithLogger(log logger.Logger) Option {
+	return func(c *config) {
+		c.log = log
+	}
+}
+
+// WithMetrics counts the requests by version with the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(c *config) {
+		c.requests = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_server_api_version_requests_total",
+			Help: "Number of HTTP requests by API version and deprecation.",
+		}, []string{"version", "deprecated"}))
+	}
+}
+
+// Middleware resolves the API version of every request among the supported ones and stores it in the request
+// context, where FromContext and Switch find it. Requests asking for unsupported versions are answered with
+// 400 Bad Request.
+func Middleware(supported []string, opts ...Option) func(http.Handler) http.Handler {
+	cfg := &config{
+		supported:  make(map[string]struct{}, len(supported)),
+		resolvers:  []Resolver{FromPath(false), FromHeader(""), FromAccept()},
+		deprecated: make(map[string]Deprecation),
+	}
+	for _, v := range supported {
+		cfg.supported[v] = struct{}{}
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// resolvers may rewrite the request, which is shallow copied so that the caller's one is untouched
+			r = r.WithContext(r.Context())
+			version, ok := cfg.resolve(r)
+			if !ok {
+				version = cfg.def
+			}
+			if _, supported := cfg.supported[version]; !supported {
+				detail := "the request does not specify an API version"
+				if ok {
+					detail = fmt.Sprintf("%s: %q", ErrUnsupported, version)
+				}
+				respond.Error(w, respond.NewProblem(http.StatusBadRequest, detail))
+				return
+			}
+
+			d, deprecated := cfg.deprecated[version]
+			if deprecated {
+				setDeprecationHeaders(w.Header(), d)
+				if cfg.log != nil {
+					logger.WithTrace(r.Context(), cfg.log.Event(versionEvent).Method(r.Method).URI(r.RequestURI).UserAgent(r.UserAgent())).
+						Warn("Request to deprecated API version " + version)
+				}
+			}
+			if cfg.requests != nil {
+				cfg.requests.WithLabelValues(version, strconv.FormatBool(deprecated)).Inc()
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), version)))
+		})
+	}
+}
+
+// resolve returns the version found by the first resolver which finds one.
+func (c *config) resolve(r *http.Request) (string, bool) {
+	for _, resolve := range c.resolvers {
+		if v, ok := resolve(r); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func setDeprecationHeaders(h http.Header, d Deprecation) {
+	if d.Since.IsZero() {
+		h.Set("Deprecation", "true")
+	} else {
+		h.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		h.Add("Link", "<"+d.Link+`>; rel="deprecation"`)
+	}
+}
+
+// Switch dispatches the requests to the handler of their version, as stored in the context by the middleware.
+// Versions without a handler are answered with 404 Not Found.
+func Switch(handlers map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, _ := FromContext(r.Context())
+		h, ok := handlers[v]
+		if !ok {
+			respond.Error(w, respond.NewProblem(http.StatusNotFound, fmt.Sprintf("the resource is not available in API version %q", v)))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,498 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultMaxSize    = 1 << 30
+	defaultMaxEntries = 10000
+	// maxLinkHops is the number of links followed when resolving the target of a link, as in the Linux kernel.
+	maxLinkHops = 40
+)
+
+var (
+	// ErrUnsafePath is returned when an entry would be extracted outside of the destination directory,
+	// e.g. because its name is absolute or contains "..", or it is a link pointing outside of it.
+	ErrUnsafePath = errors.New("unsafe path")
+	// ErrTooLarge is returned when the extracted files exceed the maximum size.
+	ErrTooLarge = errors.New("archive too large")
+	// ErrTooManyEntries is returned when the archive holds more entries than allowed.
+	ErrTooManyEntries = errors.New("archive has too many entries")
+	// ErrUnknownFormat is returned by ExtractFile when the file is neither a tar, a gzipped tar nor a zip archive.
+	ErrUnknownFormat = errors.New("unknown archive format")
+)
+
+// Progress reports how far the extraction or creation has gone.
+type Progress struct {
+	// Name is the name of the last entry processed.
+	Name    string
+	Entries int
+	Bytes   int64
+}
+
+// Option customises extraction and creation.
+type Option func(*config)
+
+type config struct {
+	maxSize    int64
+	maxEntries int
+	strip      int
+	overwrite  bool
+	symlinks   bool
+	level      int
+	progress   func(Progress)
+}
+
+// WithMaxSize fails extraction when the extracted files exceed n bytes in total, 1GiB by default.
+// The size is measured while writing, regardless of the sizes declared by the archive.
+func WithMaxSize(n int64) Option {
+	return func(c *config) {
+		c.maxSize = n
+	}
+}
+
+// WithMaxEntries fails extraction when the archive holds more than n entries, 10000 by default.
+func WithMaxEntries(n int) Option {
+	return func(c *config) {
+		c.maxEntries = n
+	}
+}
+
+// WithStripComponents strips the first n elements from the names of the entries, e.g. the top level directory
+// of release tarballs. Entries with fewer elements are skipped.
+func WithStripComponents(n int) Option {
+	return func(c *config) {
+		c.strip = n
+	}
+}
+
+// WithOverwrite replaces existing files, which otherwise fail extraction.
+func WithOverwrite() Option {
+	return func(c *config) {
+		c.overwrite = true
+	}
+}
+
+// WithSymlinks extracts symbolic and hard links whose target lies within the destination directory.
+// Links are skipped by default.
+func WithSymlinks() Option {
+	return func(c *config) {
+		c.symlinks = true
+	}
+}
+
+// WithCompressionLevel sets the gzip compression level used when creating archives, gzip.DefaultCompression by default.
+func WithCompressionLevel(level int) Option {
+	return func(c *config) {
+		c.level = level
+	}
+}
+
+// WithProgress calls fn after every entry extracted or archived.
+func WithProgress(fn func(Progress)) Option {
+	return func(c *config) {
+		c.progress = fn
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		maxSize:    defaultMaxSize,
+		maxEntries: defaultMaxEntries,
+		level:      gzip.DefaultCompression,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// ExtractFile extracts the archive at path into dest, detecting whether it is a tar, a gzipped tar or a zip archive
+// from its content, e.g. after downloading it with client.Download.
+func ExtractFile(ctx context.Context, path, dest string, opts ...Option) error {
+	f, err := os.Open(path) // nolint:gosec
+	if err != nil {
+		return fmt.Errorf("could not open archive: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not stat archive: %w", err)
+	}
+
+	magic := make([]byte, 512)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("could not read archive: %w", err)
+	}
+	magic = magic[:n]
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("could not read archive: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return ExtractZip(ctx, f, fi.Size(), dest, opts...)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return ExtractTarGz(ctx, f, dest, opts...)
+	case len(magic) >= 262 && string(magic[257:262]) == "ustar":
+		return ExtractTar(ctx, f, dest, opts...)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+}
+
+// ExtractTarGz extracts the gzipped tar archive read from r into dest, creating it if needed.
+// See ExtractTar for the safety guarantees.
+func ExtractTarGz(ctx context.Context, r io.Reader, dest string, opts ...Option) error {
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return fmt.Errorf("could not read gzip stream: %w", err)
+	}
+	defer gz.Close()
+	return ExtractTar(ctx, gz, dest, opts...)
+}
+
+// ExtractTar extracts the tar archive read from r into dest, creating it if needed.
+// Entries are written through an os.Root, so that nothing is written outside of dest: entries with unsafe names
+// fail extraction with ErrUnsafePath, and so do links pointing outside of dest when enabled with WithSymlinks.
+// Special files are skipped and permissions are limited to the permission bits.
+func ExtractTar(ctx context.Context, r io.Reader, dest string, opts ...Option) error {
+	cfg := newConfig(opts)
+	x, err := newExtractor(ctx, dest, cfg)
+	if err != nil {
+		return err
+	}
+	defer x.root.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read tar entry: %w", err)
+		}
+		var kind entryKind
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			kind = kindDir
+		case tar.TypeReg:
+			kind = kindFile
+		case tar.TypeSymlink:
+			kind = kindSymlink
+		case tar.TypeLink:
+			kind = kindHardlink
+		default:
+			continue
+		}
+		e := entry{name: hdr.Name, kind: kind, mode: hdr.FileInfo().Mode(), link: hdr.Linkname}
+		if err := x.extract(e, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// ExtractZip extracts the zip archive read from r, whose size is in input, into dest, creating it if needed.
+// See ExtractTar for the safety guarantees.
+func ExtractZip(ctx context.Context, r io.ReaderAt, size int64, dest string, opts ...Option) error {
+	cfg := newConfig(opts)
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("could not read zip archive: %w", err)
+	}
+	if len(zr.File) > cfg.maxEntries {
+		return fmt.Errorf("%w: more than %d", ErrTooManyEntries, cfg.maxEntries)
+	}
+	x, err := newExtractor(ctx, dest, cfg)
+	if err != nil {
+		return err
+	}
+	defer x.root.Close()
+
+	for _, f := range zr.File {
+		mode := f.Mode()
+		e := entry{name: f.Name, mode: mode}
+		switch {
+		case mode.IsDir():
+			e.kind = kindDir
+		case mode&fs.ModeSymlink != 0:
+			e.kind = kindSymlink
+		case mode.IsRegular():
+			e.kind = kindFile
+		default:
+			continue
+		}
+		if err := x.extractZip(e, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type entryKind int
+
+const (
+	kindDir entryKind = iota
+	kindFile
+	kindSymlink
+	kindHardlink
+)
+
+type entry struct {
+	name string
+	kind entryKind
+	mode fs.FileMode
+	link string
+}
+
+// extractor writes entries within a root directory, enforcing the limits.
+type extractor struct {
+	ctx     context.Context
+	cfg     *config
+	root    *os.Root
+	entries int
+	written int64
+}
+
+func newExtractor(ctx context.Context, dest string, cfg *config) (*extractor, error) {
+	if err := os.MkdirAll(dest, 0o750); err != nil {
+		return nil, fmt.Errorf("could not create destination: %w", err)
+	}
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return nil, fmt.Errorf("could not open destination: %w", err)
+	}
+	return &extractor{ctx: ctx, cfg: cfg, root: root}, nil
+}
+
+func (x *extractor) extractZip(e entry, f *zip.File) error {
+	if e.kind == kindDir {
+		return x.extract(e, nil)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("could not open zip entry %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	if e.kind == kindSymlink {
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return fmt.Errorf("could not read zip entry %s: %w", f.Name, err)
+		}
+		e.link = string(target)
+	}
+	return x.extract(e, rc)
+}
+
+func (x *extractor) extract(e entry, r io.Reader) error {
+	if err := x.ctx.Err(); err != nil {
+		return err
+	}
+	x.entries++
+	if x.entries > x.cfg.maxEntries {
+		return fmt.Errorf("%w: more than %d", ErrTooManyEntries, x.cfg.maxEntries)
+	}
+	name, ok, err := x.clean(e.name)
+	if err != nil || !ok {
+		return err
+	}
+
+	switch e.kind {
+	case kindDir:
+		if err := x.root.MkdirAll(name, dirPerm(e.mode)); err != nil {
+			return fmt.Errorf("could not create directory %s: %w", name, err)
+		}
+	case kindFile:
+		if err := x.writeFile(name, e.mode, r); err != nil {
+			return err
+		}
+	case kindSymlink, kindHardlink:
+		if !x.cfg.symlinks {
+			return nil
+		}
+		if err := x.link(name, e); err != nil {
+			return err
+		}
+	}
+	if x.cfg.progress != nil {
+		x.cfg.progress(Progress{Name: name, Entries: x.entries, Bytes: x.written})
+	}
+	return nil
+}
+
+// clean validates the name of the entry, stripping the leading components. It returns false for entries to skip.
+func (x *extractor) clean(name string) (string, bool, error) {
+	name = strings.TrimSuffix(strings.ReplaceAll(name, `\`, "/"), "/")
+	if x.cfg.strip > 0 {
+		parts := strings.Split(name, "/")
+		if len(parts) <= x.cfg.strip {
+			return "", false, nil
+		}
+		name = strings.Join(parts[x.cfg.strip:], "/")
+	}
+	if name == "" || name == "." {
+		return "", false, nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", false, fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	return filepath.FromSlash(path.Clean(name)), true, nil
+}
+
+func (x *extractor) writeFile(name string, mode fs.FileMode, r io.Reader) error {
+	if dir := filepath.Dir(name); dir != "." {
+		if err := x.root.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("could not create directory %s: %w", dir, err)
+		}
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if x.cfg.overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := x.root.OpenFile(name, flags, filePerm(mode))
+	if err != nil {
+		return fmt.Errorf("could not create file %s: %w", name, err)
+	}
+	remaining := x.cfg.maxSize - x.written
+	n, err := io.Copy(f, io.LimitReader(&contextReader{ctx: x.ctx, r: r}, remaining+1))
+	x.written += n
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write file %s: %w", name, err)
+	}
+	if n > remaining {
+		return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, x.cfg.maxSize)
+	}
+	return nil
+}
+
+// link creates a link, making sure that its target lies within the root.
+func (x *extractor) link(name string, e entry) error {
+	target := filepath.FromSlash(e.link)
+	resolved := target
+	if e.kind == kindSymlink {
+		resolved = filepath.Join(filepath.Dir(name), target)
+	}
+	if filepath.IsAbs(target) || !filepath.IsLocal(resolved) {
+		return fmt.Errorf("%w: %s links to %s", ErrUnsafePath, name, e.link)
+	}
+	if dir := filepath.Dir(name); dir != "." {
+		if err := x.root.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("could not create directory %s: %w", dir, err)
+		}
+	}
+	if e.kind == kindSymlink {
+		// not joined, as cleaning the path would drop the ".." following links
+		if err := x.resolve(filepath.Dir(name) + string(filepath.Separator) + target); err != nil {
+			return fmt.Errorf("%w: %s links to %s", err, name, e.link)
+		}
+	}
+	if x.cfg.overwrite {
+		// directories are never replaced by links, so that the ones resolve walked through stay as they are
+		if fi, err := x.root.Lstat(name); err == nil && !fi.IsDir() {
+			_ = x.root.Remove(name)
+		}
+	}
+	var err error
+	if e.kind == kindSymlink {
+		err = x.root.Symlink(target, name)
+	} else {
+		err = x.root.Link(filepath.Clean(target), name)
+	}
+	if err != nil {
+		return fmt.Errorf("could not create link %s: %w", name, err)
+	}
+	return nil
+}
+
+// resolve walks the path from the root following the links extracted so far, returning ErrUnsafePath if it leads
+// outside of the root. As missing components may be extracted as links later, ".." may only follow components
+// which are directories already.
+func (x *extractor) resolve(name string) error {
+	pending := strings.Split(name, string(filepath.Separator))
+	var resolved []string
+	for hops := 0; len(pending) > 0; {
+		c := pending[0]
+		pending = pending[1:]
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return ErrUnsafePath
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		p := filepath.Join(append(resolved, c)...)
+		fi, err := x.root.Lstat(p)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if slices.Contains(pending, "..") {
+				return ErrUnsafePath
+			}
+			resolved = append(resolved, c)
+		case err != nil:
+			return fmt.Errorf("could not resolve %s: %w", p, err)
+		case fi.Mode()&fs.ModeSymlink != 0:
+			if hops++; hops > maxLinkHops {
+				return ErrUnsafePath
+			}
+			target, err := x.root.Readlink(p)
+			if err != nil {
+				return fmt.Errorf("could not resolve %s: %w", p, err)
+			}
+			if filepath.IsAbs(target) {
+				return ErrUnsafePath
+			}
+			pending = append(strings.Split(target, string(filepath.Separator)), pending...)
+		default:
+			resolved = append(resolved, c)
+		}
+	}
+	return nil
+}
+
+func filePerm(mode fs.FileMode) fs.FileMode {
+	if perm := mode.Perm(); perm != 0 {
+		return perm
+	}
+	return 0o644
+}
+
+func dirPerm(mode fs.FileMode) fs.FileMode {
+	if perm := mode.Perm(); perm != 0 {
+		return perm | 0o700
+	}
+	return 0o750
+}
+
+// contextReader stops reading once the context is done, so that large entries do not delay cancellation.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,305 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarEntry is an entry of a tar archive built by a test.
+type tarEntry struct {
+	name     string
+	typeflag byte
+	link     string
+	body     string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		hdr := &tar.Header{Name: e.name, Typeflag: typeflag, Linkname: e.link, Mode: 0o644, Size: int64(len(e.body))}
+		if typeflag == tar.TypeDir {
+			hdr.Mode = 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractTar(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []tarEntry
+		opts      []Option
+		wantErr   error
+		wantFiles map[string]string
+	}{
+		{
+			name: "files and directories",
+			entries: []tarEntry{
+				{name: "dir/", typeflag: tar.TypeDir},
+				{name: "dir/a.txt", body: "a"},
+				{name: "b/c.txt", body: "c"},
+			},
+			wantFiles: map[string]string{"dir/a.txt": "a", "b/c.txt": "c"},
+		},
+		{
+			name:    "parent directory",
+			entries: []tarEntry{{name: "../evil.txt", body: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "nested parent directory",
+			entries: []tarEntry{{name: "a/../../evil.txt", body: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "absolute path",
+			entries: []tarEntry{{name: "/evil.txt", body: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "backslashes",
+			entries: []tarEntry{{name: `..\evil.txt`, body: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "symlink outside",
+			entries: []tarEntry{{name: "link", typeflag: tar.TypeSymlink, link: "../outside"}},
+			opts:    []Option{WithSymlinks()},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "absolute symlink",
+			entries: []tarEntry{{name: "link", typeflag: tar.TypeSymlink, link: "/etc/passwd"}},
+			opts:    []Option{WithSymlinks()},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name: "symlink escaping through another symlink",
+			entries: []tarEntry{
+				{name: "sub/", typeflag: tar.TypeDir},
+				{name: "sub/up", typeflag: tar.TypeSymlink, link: ".."},
+				{name: "sub/up/escape", typeflag: tar.TypeSymlink, link: "../outside"},
+			},
+			opts:    []Option{WithSymlinks()},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name: "symlink escaping through a later component",
+			entries: []tarEntry{
+				{name: "escape", typeflag: tar.TypeSymlink, link: "missing/../../outside"},
+			},
+			opts:    []Option{WithSymlinks()},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name: "file written through a symlink to a directory outside",
+			entries: []tarEntry{
+				{name: "out", typeflag: tar.TypeSymlink, link: ".."},
+				{name: "out/evil.txt", body: "x"},
+			},
+			opts:    []Option{WithSymlinks()},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "hard link outside",
+			entries: []tarEntry{{name: "link", typeflag: tar.TypeLink, link: "../outside"}},
+			opts:    []Option{WithSymlinks()},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name: "symlink inside",
+			entries: []tarEntry{
+				{name: "a.txt", body: "a"},
+				{name: "dir/link", typeflag: tar.TypeSymlink, link: "../a.txt"},
+			},
+			opts:      []Option{WithSymlinks()},
+			wantFiles: map[string]string{"a.txt": "a", "dir/link": "a"},
+		},
+		{
+			name:      "links skipped by default",
+			entries:   []tarEntry{{name: "link", typeflag: tar.TypeSymlink, link: "../outside"}},
+			wantFiles: map[string]string{},
+		},
+		{
+			name:    "too large",
+			entries: []tarEntry{{name: "a.txt", body: "0123456789"}},
+			opts:    []Option{WithMaxSize(5)},
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "too many entries",
+			entries: []tarEntry{{name: "a.txt"}, {name: "b.txt"}, {name: "c.txt"}},
+			opts:    []Option{WithMaxEntries(2)},
+			wantErr: ErrTooManyEntries,
+		},
+		{
+			name: "strip components",
+			entries: []tarEntry{
+				{name: "release-1.0/", typeflag: tar.TypeDir},
+				{name: "release-1.0/bin/tool", body: "tool"},
+			},
+			opts:      []Option{WithStripComponents(1)},
+			wantFiles: map[string]string{"bin/tool": "tool"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			err := ExtractTar(context.Background(), bytes.NewReader(buildTar(t, tt.entries)), dest, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExtractTar() error = %v, want %v", err, tt.wantErr)
+			}
+			for name, want := range tt.wantFiles {
+				got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Fatalf("%s = %q, want %q", name, got, want)
+				}
+			}
+			// nothing may be written next to the destination
+			entries, err := os.ReadDir(parent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries next to the destination, want none", len(entries)-1)
+			}
+		})
+	}
+}
+
+func TestExtractTarDoesNotOverwrite(t *testing.T) {
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "a.txt"), []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := buildTar(t, []tarEntry{{name: "a.txt", body: "replaced"}})
+	if err := ExtractTar(context.Background(), bytes.NewReader(archive), dest); err == nil {
+		t.Fatal("ExtractTar() replaced an existing file")
+	}
+	if err := ExtractTar(context.Background(), bytes.NewReader(archive), dest, WithOverwrite()); err != nil {
+		t.Fatalf("ExtractTar() error = %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dest, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "replaced" {
+		t.Fatalf("got %q, want %q", got, "replaced")
+	}
+}
+
+func TestExtractZip(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr error
+	}{
+		{name: "valid", files: map[string]string{"dir/a.txt": "a"}},
+		{name: "parent directory", files: map[string]string{"../evil.txt": "x"}, wantErr: ErrUnsafePath},
+		{name: "absolute path", files: map[string]string{"/evil.txt": "x"}, wantErr: ErrUnsafePath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			for name, body := range tt.files {
+				w, err := zw.Create(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Write([]byte(body)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			dest := t.TempDir()
+			err := ExtractZip(context.Background(), bytes.NewReader(buf.Bytes()), int64(buf.Len()), dest)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExtractZip() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateAndExtractRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"a.txt": "a", "dir/b.txt": "b"}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(src, filepath.FromSlash(name)), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := CreateTarGz(context.Background(), &buf, src); err != nil {
+		t.Fatalf("CreateTarGz() error = %v", err)
+	}
+	archive := filepath.Join(t.TempDir(), "archive.tar.gz")
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := ExtractFile(context.Background(), archive, dest, WithSymlinks()); err != nil {
+		t.Fatalf("ExtractFile() error = %v", err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("%s = %q, want %q", name, got, want)
+		}
+	}
+	fi, err := os.Lstat(filepath.Join(dest, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		t.Fatal("link was not extracted as a symbolic link")
+	}
+}
+
+func TestExtractFileUnknownFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("not an archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExtractFile(context.Background(), path, t.TempDir()); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("ExtractFile() error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
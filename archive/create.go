@@ -0,0 +1,182 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CreateTarGz writes to w a gzipped tar archive of the directory tree at src, streaming it as the files are read,
+// e.g. into an http.ResponseWriter or an upload request body.
+// Entries are named after their path relative to src; regular files, directories and symbolic links are archived.
+func CreateTarGz(ctx context.Context, w io.Writer, src string, opts ...Option) error {
+	cfg := newConfig(opts)
+	gz, err := gzip.NewWriterLevel(w, cfg.level)
+	if err != nil {
+		return fmt.Errorf("could not create gzip stream: %w", err)
+	}
+	if err := createTar(ctx, gz, src, cfg); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("could not close gzip stream: %w", err)
+	}
+	return nil
+}
+
+// CreateTar writes to w a tar archive of the directory tree at src. See CreateTarGz.
+func CreateTar(ctx context.Context, w io.Writer, src string, opts ...Option) error {
+	return createTar(ctx, w, src, newConfig(opts))
+}
+
+func createTar(ctx context.Context, w io.Writer, src string, cfg *config) error {
+	tw := tar.NewWriter(w)
+	p := &progress{cfg: cfg}
+	err := walk(ctx, src, func(path, name string, fi fs.FileInfo) error {
+		var link string
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			var err error
+			if link, err = os.Readlink(path); err != nil {
+				return fmt.Errorf("could not read link %s: %w", path, err)
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return fmt.Errorf("could not create header for %s: %w", path, err)
+		}
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("could not write header for %s: %w", path, err)
+		}
+		if fi.Mode().IsRegular() {
+			if err := p.copyFile(ctx, tw, path); err != nil {
+				return err
+			}
+		}
+		p.report(name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("could not close tar archive: %w", err)
+	}
+	return nil
+}
+
+// CreateZip writes to w a zip archive of the directory tree at src, compressing the files with deflate.
+// See CreateTarGz.
+func CreateZip(ctx context.Context, w io.Writer, src string, opts ...Option) error {
+	cfg := newConfig(opts)
+	zw := zip.NewWriter(w)
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, cfg.level)
+	})
+	p := &progress{cfg: cfg}
+	err := walk(ctx, src, func(path, name string, fi fs.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(fi)
+		if err != nil {
+			return fmt.Errorf("could not create header for %s: %w", path, err)
+		}
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("could not write header for %s: %w", path, err)
+		}
+		switch {
+		case fi.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("could not read link %s: %w", path, err)
+			}
+			if _, err := io.WriteString(fw, link); err != nil {
+				return fmt.Errorf("could not write link %s: %w", path, err)
+			}
+		case fi.Mode().IsRegular():
+			if err := p.copyFile(ctx, fw, path); err != nil {
+				return err
+			}
+		}
+		p.report(name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("could not close zip archive: %w", err)
+	}
+	return nil
+}
+
+// walk calls fn for every regular file, directory and symbolic link under src, except src itself,
+// with its path and its slash separated name relative to src.
+func walk(ctx context.Context, src string, fn func(path, name string, fi fs.FileInfo) error) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == src {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() && !fi.Mode().IsRegular() && fi.Mode()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, filepath.ToSlash(rel), fi)
+	})
+}
+
+// progress tracks the entries archived.
+type progress struct {
+	cfg     *config
+	entries int
+	written int64
+}
+
+func (p *progress) copyFile(ctx context.Context, w io.Writer, path string) error {
+	f, err := os.Open(path) // nolint:gosec
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer f.Close()
+	n, err := io.Copy(w, &contextReader{ctx: ctx, r: f})
+	p.written += n
+	if err != nil {
+		return fmt.Errorf("could not archive file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (p *progress) report(name string) {
+	p.entries++
+	if p.cfg.progress != nil {
+		p.cfg.progress(Progress{Name: name, Entries: p.entries, Bytes: p.written})
+	}
+}
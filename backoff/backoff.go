@@ -0,0 +1,117 @@
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Stop is returned by a Strategy to give up.
+const Stop time.Duration = -1
+
+// Strategy computes the delays between attempts. Strategies are stateless, so that they can be shared.
+type Strategy interface {
+	// Delay returns how long to wait after the attempt in input, counted from 1, given the previous delay,
+	// zero after the first attempt. It returns Stop to give up.
+	Delay(attempt int, prev time.Duration) time.Duration
+}
+
+// StrategyFunc is a function implementing Strategy.
+type StrategyFunc func(attempt int, prev time.Duration) time.Duration
+
+// Delay calls the function.
+func (f StrategyFunc) Delay(attempt int, prev time.Duration) time.Duration {
+	return f(attempt, prev)
+}
+
+// Constant waits d after every attempt.
+func Constant(d time.Duration) Strategy {
+	return StrategyFunc(func(int, time.Duration) time.Duration {
+		return d
+	})
+}
+
+// Exponential waits initial after the first attempt, multiplying the delay by multiplier after every attempt up to
+// max, unlimited if zero. Multipliers lower than 1 are treated as 1.
+func Exponential(initial, max time.Duration, multiplier float64) Strategy {
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	return StrategyFunc(func(attempt int, _ time.Duration) time.Duration {
+		d := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+		return capped(d, max)
+	})
+}
+
+// Fibonacci waits initial after the first two attempts, then the sum of the two previous delays, up to max,
+// unlimited if zero.
+func Fibonacci(initial, max time.Duration) Strategy {
+	return StrategyFunc(func(attempt int, _ time.Duration) time.Duration {
+		a, b := 0.0, 1.0
+		for i := 1; i < attempt; i++ {
+			a, b = b, a+b
+			if max > 0 && b*float64(initial) >= float64(max) {
+				return max
+			}
+		}
+		return capped(b*float64(initial), max)
+	})
+}
+
+// Decorrelated waits a random delay between initial and three times the previous one, up to max, as described in
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+func Decorrelated(initial, max time.Duration) Strategy {
+	return StrategyFunc(func(_ int, prev time.Duration) time.Duration {
+		upper := int64(prev) * 3
+		if upper <= int64(initial) {
+			upper = int64(initial) + 1
+		}
+		d := time.Duration(int64(initial) + rand.Int63n(upper-int64(initial)))
+		if max > 0 && d > max {
+			return max
+		}
+		return d
+	})
+}
+
+// FullJitter picks a random delay between zero and the one of the strategy in input.
+func FullJitter(s Strategy) Strategy {
+	return StrategyFunc(func(attempt int, prev time.Duration) time.Duration {
+		d := s.Delay(attempt, prev)
+		if d <= 0 {
+			return d
+		}
+		return time.Duration(rand.Int63n(int64(d) + 1))
+	})
+}
+
+// EqualJitter keeps half of the delay of the strategy in input and randomises the other half.
+func EqualJitter(s Strategy) Strategy {
+	return StrategyFunc(func(attempt int, prev time.Duration) time.Duration {
+		d := s.Delay(attempt, prev)
+		if d <= 0 {
+			return d
+		}
+		return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	})
+}
+
+// MaxAttempts gives up after n attempts.
+func MaxAttempts(s Strategy, n int) Strategy {
+	return StrategyFunc(func(attempt int, prev time.Duration) time.Duration {
+		if attempt >= n {
+			return Stop
+		}
+		return s.Delay(attempt, prev)
+	})
+}
+
+func capped(d float64, max time.Duration) time.Duration {
+	if max > 0 && d > float64(max) {
+		return max
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,120 @@
+package backoff
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		want     []time.Duration
+	}{
+		{
+			name:     "constant",
+			strategy: Constant(time.Second),
+			want:     []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:     "exponential",
+			strategy: Exponential(100*time.Millisecond, 0, 2),
+			want:     []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond},
+		},
+		{
+			name:     "exponential capped",
+			strategy: Exponential(100*time.Millisecond, 300*time.Millisecond, 2),
+			want:     []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name:     "exponential multiplier lower than 1",
+			strategy: Exponential(time.Second, 0, 0.5),
+			want:     []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:     "fibonacci",
+			strategy: Fibonacci(time.Second, 0),
+			want:     []time.Duration{time.Second, time.Second, 2 * time.Second, 3 * time.Second, 5 * time.Second, 8 * time.Second},
+		},
+		{
+			name:     "fibonacci capped",
+			strategy: Fibonacci(time.Second, 4*time.Second),
+			want:     []time.Duration{time.Second, time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second, 4 * time.Second},
+		},
+		{
+			name:     "max attempts",
+			strategy: MaxAttempts(Constant(time.Second), 3),
+			want:     []time.Duration{time.Second, time.Second, Stop},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prev time.Duration
+			for i, want := range tt.want {
+				got := tt.strategy.Delay(i+1, prev)
+				if got != want {
+					t.Fatalf("attempt %d: got %v, want %v", i+1, got, want)
+				}
+				prev = got
+			}
+		})
+	}
+}
+
+func TestExponentialOverflow(t *testing.T) {
+	if got := Exponential(time.Second, 0, 2).Delay(1000, 0); got != time.Duration(math.MaxInt64) {
+		t.Fatalf("got %v, want the maximum duration", got)
+	}
+}
+
+func TestJitterBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		min, max time.Duration
+	}{
+		{
+			name:     "full jitter",
+			strategy: FullJitter(Constant(time.Second)),
+			min:      0,
+			max:      time.Second,
+		},
+		{
+			name:     "equal jitter",
+			strategy: EqualJitter(Constant(time.Second)),
+			min:      500 * time.Millisecond,
+			max:      time.Second,
+		},
+		{
+			name:     "decorrelated",
+			strategy: Decorrelated(100*time.Millisecond, 0),
+			min:      100 * time.Millisecond,
+			max:      3 * time.Second,
+		},
+		{
+			name:     "decorrelated capped",
+			strategy: Decorrelated(100*time.Millisecond, 2*time.Second),
+			min:      100 * time.Millisecond,
+			max:      2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range 1000 {
+				got := tt.strategy.Delay(i+1, time.Second)
+				if got < tt.min || got > tt.max {
+					t.Fatalf("attempt %d: got %v, want between %v and %v", i+1, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestJitterKeepsStop(t *testing.T) {
+	for _, s := range []Strategy{FullJitter(Constant(Stop)), EqualJitter(Constant(Stop))} {
+		if got := s.Delay(1, 0); got != Stop {
+			t.Fatalf("got %v, want Stop", got)
+		}
+	}
+}
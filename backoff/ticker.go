@@ -0,0 +1,115 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+// ErrStopped is returned by Ticker.Err when the strategy gave up or the maximum elapsed time was reached.
+var ErrStopped = errors.New("backoff stopped")
+
+// TickerOption customises a Ticker.
+type TickerOption func(*Ticker)
+
+// WithMaxElapsed gives up when the next attempt would start more than d after the first one.
+func WithMaxElapsed(d time.Duration) TickerOption {
+	return func(t *Ticker) {
+		t.maxElapsed = d
+	}
+}
+
+// WithClock waits with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) TickerOption {
+	return func(t *Ticker) {
+		t.clock = c
+	}
+}
+
+// Ticker paces the attempts of an operation with the delays of a strategy:
+//
+//	t := backoff.NewTicker(backoff.Exponential(100*time.Millisecond, 5*time.Second, 2))
+//	for t.Next(ctx) {
+//		if err := op(ctx); err == nil {
+//			return nil
+//		}
+//	}
+//	return t.Err()
+//
+// It is not safe for concurrent use.
+type Ticker struct {
+	strategy   Strategy
+	maxElapsed time.Duration
+	clock      clock.Clock
+
+	start   time.Time
+	attempt int
+	delay   time.Duration
+	err     error
+}
+
+// NewTicker returns a Ticker waiting the delays of the strategy in input.
+func NewTicker(s Strategy, opts ...TickerOption) *Ticker {
+	t := &Ticker{strategy: s, clock: clock.New()}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Next returns true right away the first time, then after the delay of the strategy. It returns false if the
+// strategy gives up, the maximum elapsed time would be exceeded or ctx is done, see Err.
+func (t *Ticker) Next(ctx context.Context) bool {
+	if t.err != nil {
+		return false
+	}
+	if t.attempt == 0 {
+		t.start = t.clock.Now()
+		t.attempt = 1
+		return true
+	}
+	d := t.strategy.Delay(t.attempt, t.delay)
+	if d < 0 || (t.maxElapsed > 0 && t.clock.Since(t.start)+d > t.maxElapsed) {
+		t.err = ErrStopped
+		return false
+	}
+	t.delay = d
+	timer := t.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		t.err = ctx.Err()
+		return false
+	case <-timer.C():
+	}
+	t.attempt++
+	return true
+}
+
+// Attempt returns the number of the current attempt, counted from 1.
+func (t *Ticker) Attempt() int {
+	return t.attempt
+}
+
+// Delay returns the last delay waited.
+func (t *Ticker) Delay() time.Duration {
+	return t.delay
+}
+
+// Err returns why Next returned false: ErrStopped or the error of the context.
+func (t *Ticker) Err() error {
+	return t.err
+}
+
+// Reset starts the backoff over, e.g. after an operation ran successfully for a while: the next call to Next waits
+// the first delay of the strategy, and the elapsed time is measured from now.
+func (t *Ticker) Reset() {
+	if t.attempt > 0 {
+		t.attempt = 1
+		t.start = t.clock.Now()
+	}
+	t.delay = 0
+	t.err = nil
+}
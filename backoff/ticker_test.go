@@ -0,0 +1,124 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+// next calls t.Next in the background, advancing the clock by d once it waits, and returns its result.
+func next(ctx context.Context, t *Ticker, c *clock.Fake, d time.Duration) bool {
+	done := make(chan bool)
+	go func() {
+		done <- t.Next(ctx)
+	}()
+	select {
+	case ok := <-done:
+		return ok
+	case <-waiting(c):
+	}
+	c.Advance(d)
+	return <-done
+}
+
+func waiting(c *clock.Fake) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		c.BlockUntil(1)
+		close(ch)
+	}()
+	return ch
+}
+
+func TestTickerNext(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	tk := NewTicker(MaxAttempts(Exponential(time.Second, 0, 2), 3), WithClock(c))
+	ctx := context.Background()
+
+	if !tk.Next(ctx) || tk.Attempt() != 1 || tk.Delay() != 0 {
+		t.Fatalf("first attempt: got attempt %d, delay %v", tk.Attempt(), tk.Delay())
+	}
+	for _, want := range []time.Duration{time.Second, 2 * time.Second} {
+		if !next(ctx, tk, c, want) {
+			t.Fatalf("attempt %d: Next returned false: %v", tk.Attempt()+1, tk.Err())
+		}
+		if tk.Delay() != want {
+			t.Fatalf("attempt %d: got delay %v, want %v", tk.Attempt(), tk.Delay(), want)
+		}
+	}
+	if tk.Next(ctx) {
+		t.Fatal("Next returned true after the maximum attempts")
+	}
+	if !errors.Is(tk.Err(), ErrStopped) {
+		t.Fatalf("got %v, want ErrStopped", tk.Err())
+	}
+}
+
+func TestTickerMaxElapsed(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	tk := NewTicker(Constant(time.Second), WithMaxElapsed(2500*time.Millisecond), WithClock(c))
+	ctx := context.Background()
+
+	tk.Next(ctx)
+	for i := range 2 {
+		if !next(ctx, tk, c, time.Second) {
+			t.Fatalf("attempt %d: Next returned false: %v", i+2, tk.Err())
+		}
+	}
+	if tk.Next(ctx) {
+		t.Fatal("Next returned true past the maximum elapsed time")
+	}
+	if !errors.Is(tk.Err(), ErrStopped) {
+		t.Fatalf("got %v, want ErrStopped", tk.Err())
+	}
+}
+
+func TestTickerReset(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	tk := NewTicker(Exponential(time.Second, 0, 2), WithMaxElapsed(3*time.Second), WithClock(c))
+	ctx := context.Background()
+
+	tk.Next(ctx)
+	next(ctx, tk, c, time.Second)
+	next(ctx, tk, c, 2*time.Second)
+	if tk.Next(ctx) {
+		t.Fatal("Next returned true past the maximum elapsed time")
+	}
+
+	tk.Reset()
+	if tk.Err() != nil {
+		t.Fatalf("got %v after Reset, want nil", tk.Err())
+	}
+	if !next(ctx, tk, c, time.Second) {
+		t.Fatalf("Next returned false after Reset: %v", tk.Err())
+	}
+	if tk.Delay() != time.Second || tk.Attempt() != 2 {
+		t.Fatalf("got attempt %d, delay %v after Reset, want attempt 2, delay 1s", tk.Attempt(), tk.Delay())
+	}
+}
+
+func TestTickerNextCancelled(t *testing.T) {
+	c := clock.NewFake(time.Unix(0, 0))
+	tk := NewTicker(Constant(time.Hour), WithClock(c))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	tk.Next(ctx)
+	done := make(chan bool)
+	go func() {
+		done <- tk.Next(ctx)
+	}()
+	c.BlockUntil(1)
+	cancel()
+	if <-done {
+		t.Fatal("Next returned true once the context was cancelled")
+	}
+	if !errors.Is(tk.Err(), context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", tk.Err())
+	}
+	if tk.Next(context.Background()) {
+		t.Fatal("Next returned true after failing")
+	}
+}
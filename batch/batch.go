@@ -0,0 +1,306 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/retry"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	batchEvent = "batch"
+
+	defaultMaxSize  = 100
+	defaultInterval = time.Second
+)
+
+var (
+	// ErrClosed is returned when adding items to a processor which is stopping or stopped.
+	ErrClosed = errors.New("batch processor closed")
+	// ErrFull is returned by TryAdd when the pending items reached the limit set by WithMaxPending.
+	ErrFull = errors.New("batch processor full")
+)
+
+// FlushFunc processes a batch of items. The slice is reused after it returns, so it must not be retained.
+type FlushFunc[T any] func(ctx context.Context, items []T) error
+
+// Option customises a Processor.
+type Option func(*config)
+
+type config struct {
+	name       string
+	maxSize    int
+	maxPending int
+	interval   time.Duration
+	retry      []retry.Option
+	log        logger.Logger
+	clock      clock.Clock
+
+	size     prometheus.Observer
+	duration prometheus.Observer
+	flushes  *prometheus.CounterVec
+}
+
+// WithMaxSize flushes as soon as n items are pending, 100 by default.
+func WithMaxSize(n int) Option {
+	return func(c *config) {
+		c.maxSize = n
+	}
+}
+
+// WithInterval flushes the pending items every d, even if fewer than the max size, 1s by default.
+func WithInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.interval = d
+	}
+}
+
+// WithMaxPending bounds the items waiting to be flushed, besides the batch being flushed, to n:
+// Add blocks and TryAdd fails while the limit is reached. Defaults to twice the max size.
+func WithMaxPending(n int) Option {
+	return func(c *config) {
+		c.maxPending = n
+	}
+}
+
+// WithRetry retries failed flushes with the retry options in input. By default flushes are not retried.
+func WithRetry(opts ...retry.Option) Option {
+	return func(c *config) {
+		c.retry = opts
+	}
+}
+
+// WithLogger logs the failed flushes.
+func WithLogger(log logger.Logger) Option {
+	return func(c *config) {
+		c.log = log
+	}
+}
+
+// WithMetrics registers the batch size, flush duration and flush outcome metrics with the registerer in input,
+// labelled with the name of the processor.
+func WithMetrics(registerer prometheus.Registerer, name string) Option {
+	return func(c *config) {
+		c.name = name
+		c.size = metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "batch_size",
+			Help:    "Number of items per flushed batch.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		}, []string{"processor"})).WithLabelValues(name)
+		c.duration = metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "batch_flush_duration_seconds",
+			Help:    "Duration of batch flushes, including retries.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"processor"})).WithLabelValues(name)
+		c.flushes = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "batch_flushes_total",
+			Help: "Number of batch flushes by processor and result.",
+		}, []string{"processor", "result"}))
+	}
+}
+
+// WithClock schedules the interval flushes with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(cl clock.Clock) Option {
+	return func(c *config) {
+		c.clock = cl
+	}
+}
+
+// Processor accumulates items and flushes them in batches, when the max size is reached or at every interval,
+// whichever comes first. Batches are flushed one at a time, by Run.
+type Processor[T any] struct {
+	cfg     config
+	flush   FlushFunc[T]
+	onError func(ctx context.Context, items []T, err error)
+	items   chan T
+
+	// mu guards closed: Add holds it for reading while sending, so that no item is sent after the drain.
+	mu       sync.RWMutex
+	closed   bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New returns a Processor flushing the batches with the function in input.
+func New[T any](flush FlushFunc[T], opts ...Option) *Processor[T] {
+	cfg := config{
+		maxSize:  defaultMaxSize,
+		interval: defaultInterval,
+		clock:    clock.New(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxPending <= 0 {
+		cfg.maxPending = 2 * cfg.maxSize
+	}
+	return &Processor[T]{
+		cfg:   cfg,
+		flush: flush,
+		items: make(chan T, cfg.maxPending),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// OnError calls fn with the batches whose flush failed, after the retries, e.g. to store them elsewhere.
+// The slice must not be retained. Failures are logged in any case. It must be called before Run.
+func (p *Processor[T]) OnError(fn func(ctx context.Context, items []T, err error)) {
+	p.onError = fn
+}
+
+// Add queues the item, blocking while the pending items are at the limit, until ctx is done.
+func (p *Processor[T]) Add(ctx context.Context, item T) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	select {
+	case p.items <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryAdd queues the item without blocking, failing with ErrFull if the pending items are at the limit.
+func (p *Processor[T]) TryAdd(item T) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	select {
+	case p.items <- item:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+// Run flushes the batches until ctx is done or the processor is stopped with Stop, then stops accepting items
+// and drains the pending ones, flushing them with a context which is not cancelled.
+// It is meant to be run in its own goroutine.
+func (p *Processor[T]) Run(ctx context.Context) {
+	defer close(p.done)
+	ticker := p.cfg.clock.NewTicker(p.cfg.interval)
+	defer ticker.Stop()
+	buf := make([]T, 0, p.cfg.maxSize)
+	for {
+		select {
+		case item := <-p.items:
+			buf = append(buf, item)
+			if len(buf) >= p.cfg.maxSize {
+				buf = p.flushBatch(ctx, buf)
+			}
+		case <-ticker.C():
+			buf = p.flushBatch(ctx, buf)
+		case <-ctx.Done():
+			p.drain(context.WithoutCancel(ctx), buf)
+			return
+		case <-p.stop:
+			p.drain(context.WithoutCancel(ctx), buf)
+			return
+		}
+	}
+}
+
+// Stop stops accepting items and waits for Run to flush the pending ones, until ctx is done.
+func (p *Processor[T]) Stop(ctx context.Context) error {
+	p.close()
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not drain batch processor: %w", ctx.Err())
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn draining the processor within timeout.
+func (p *Processor[T]) TerminationFn(timeout time.Duration) shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return p.Stop(ctx)
+	}
+}
+
+// close rejects new items and signals Run to drain, once the items being added are queued.
+func (p *Processor[T]) close() {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		p.mu.Unlock()
+		close(p.stop)
+	})
+}
+
+// drain flushes the buffered and queued items.
+func (p *Processor[T]) drain(ctx context.Context, buf []T) {
+	p.close()
+	for {
+		select {
+		case item := <-p.items:
+			buf = append(buf, item)
+			if len(buf) >= p.cfg.maxSize {
+				buf = p.flushBatch(ctx, buf)
+			}
+		default:
+			p.flushBatch(ctx, buf)
+			return
+		}
+	}
+}
+
+// flushBatch flushes the items, if any, returning the emptied buffer.
+func (p *Processor[T]) flushBatch(ctx context.Context, buf []T) []T {
+	if len(buf) == 0 {
+		return buf
+	}
+	start := time.Now()
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		return p.flush(ctx, buf)
+	}, p.retryOptions()...)
+	p.observe(len(buf), time.Since(start), err)
+	if err != nil {
+		if p.cfg.log != nil {
+			p.cfg.log.Event(batchEvent).Component(p.cfg.name).Error(fmt.Sprintf("Could not flush batch of %d items", len(buf)), err)
+		}
+		if p.onError != nil {
+			p.onError(ctx, buf, err)
+		}
+	}
+	clear(buf)
+	return buf[:0]
+}
+
+// retryOptions returns the options of the flush retries, a single attempt unless configured otherwise.
+func (p *Processor[T]) retryOptions() []retry.Option {
+	if p.cfg.retry == nil {
+		return []retry.Option{retry.WithMaxAttempts(1)}
+	}
+	return p.cfg.retry
+}
+
+func (p *Processor[T]) observe(size int, d time.Duration, err error) {
+	if p.cfg.flushes == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	p.cfg.size.Observe(float64(size))
+	p.cfg.duration.Observe(d.Seconds())
+	p.cfg.flushes.WithLabelValues(p.cfg.name, result).Inc()
+}
@@ -0,0 +1,82 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the object does not exist.
+	ErrNotFound = errors.New("object not found")
+	// ErrUnsupported is returned by drivers not supporting an operation, e.g. signing URLs without credentials.
+	ErrUnsupported = errors.New("operation not supported")
+)
+
+// Object describes a stored object.
+type Object struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ETag        string
+	ContentType string
+}
+
+// PutOptions are the attributes of an object being stored.
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+}
+
+// PutOption customises Put.
+type PutOption func(*PutOptions)
+
+// WithContentType sets the content type of the object.
+func WithContentType(ct string) PutOption {
+	return func(o *PutOptions) {
+		o.ContentType = ct
+	}
+}
+
+// WithMetadata sets the user metadata of the object.
+func WithMetadata(md map[string]string) PutOption {
+	return func(o *PutOptions) {
+		o.Metadata = md
+	}
+}
+
+// NewPutOptions applies the options in input. It is meant to be used by drivers.
+func NewPutOptions(opts ...PutOption) *PutOptions {
+	o := &PutOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Bucket stores objects by key.
+type Bucket interface {
+	// Get returns a reader of the content of the object, or ErrNotFound. The reader must be closed.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put stores the content read from r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, opts ...PutOption) error
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose key starts with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// SignedURL returns a URL allowing to perform the HTTP method (GET or PUT) on the object
+	// without credentials until it expires.
+	SignedURL(ctx context.Context, key, method string, expiry time.Duration) (string, error)
+}
+
+// Middleware decorates a Bucket.
+type Middleware func(Bucket) Bucket
+
+// Wrap decorates the bucket with the middlewares in input; the first one is the outermost.
+func Wrap(b Bucket, mws ...Middleware) Bucket {
+	for i := len(mws) - 1; i >= 0; i-- {
+		b = mws[i](b)
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/indiependente/pkg/blob"
+	"google.golang.org/api/iterator"
+)
+
+const defaultChunkSize = 16 << 20
+
+// Option customises the GCS bucket.
+type Option func(*Bucket)
+
+// WithChunkSize uploads objects in resumable chunks of n bytes, each retried on its own on transient errors.
+// Defaults to 16MiB; zero uploads objects in a single request.
+func WithChunkSize(n int) Option {
+	return func(b *Bucket) {
+		b.chunkSize = n
+	}
+}
+
+// Bucket stores objects in a Google Cloud Storage bucket.
+type Bucket struct {
+	handle    *storage.BucketHandle
+	chunkSize int
+}
+
+// compile time interface check.
+var _ blob.Bucket = &Bucket{}
+
+// New returns a bucket storing objects in the GCS bucket named bucket through the client in input.
+func New(client *storage.Client, bucket string, opts ...Option) *Bucket {
+	b := &Bucket{handle: client.Bucket(bucket), chunkSize: defaultChunkSize}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Get downloads the object.
+func (b *Bucket) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := b.handle.Object(key).NewReader(ctx)
+	if err != nil {
+		return nil, wrap(err, key, "could not get object")
+	}
+	return r, nil
+}
+
+// Put uploads the object, streaming it in resumable chunks.
+func (b *Bucket) Put(ctx context.Context, key string, r io.Reader, opts ...blob.PutOption) error {
+	o := blob.NewPutOptions(opts...)
+	w := b.handle.Object(key).NewWriter(ctx)
+	w.ChunkSize = b.chunkSize
+	w.ContentType = o.ContentType
+	w.Metadata = o.Metadata
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return wrap(err, key, "could not upload object")
+	}
+	if err := w.Close(); err != nil {
+		return wrap(err, key, "could not upload object")
+	}
+	return nil
+}
+
+// Delete deletes the object.
+func (b *Bucket) Delete(ctx context.Context, key string) error {
+	err := b.handle.Object(key).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return wrap(err, key, "could not delete object")
+	}
+	return nil
+}
+
+// List lists the objects whose key starts with prefix.
+func (b *Bucket) List(ctx context.Context, prefix string) ([]blob.Object, error) {
+	var objs []blob.Object
+	it := b.handle.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, wrap(err, prefix, "could not list objects")
+		}
+		objs = append(objs, blob.Object{
+			Key:         attrs.Name,
+			Size:        attrs.Size,
+			ModTime:     attrs.Updated,
+			ETag:        attrs.Etag,
+			ContentType: attrs.ContentType,
+		})
+	}
+}
+
+// SignedURL returns a V4 signed URL for GET or PUT requests.
+// The client must have been created with credentials able to sign, e.g. a service account key.
+func (b *Bucket) SignedURL(_ context.Context, key, method string, expiry time.Duration) (string, error) {
+	if method != http.MethodGet && method != http.MethodPut {
+		return "", fmt.Errorf("%w: signing %s requests", blob.ErrUnsupported, method)
+	}
+	u, err := b.handle.SignedURL(key, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  method,
+		Expires: time.Now().Add(expiry),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not sign URL: %w", err)
+	}
+	return u, nil
+}
+
+// wrap maps the missing object errors to blob.ErrNotFound.
+func wrap(err error, key, msg string) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %s", blob.ErrNotFound, key)
+	}
+	return fmt.Errorf("%s %s: %w", msg, key, err)
+}
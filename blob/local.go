@@ -0,0 +1,181 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/filex"
+)
+
+// LocalOption customises the Local bucket.
+type LocalOption func(*Local)
+
+// WithSigning enables SignedURL, generating URLs under baseURL, e.g. https://files.example.com/blob,
+// signed with the secret in input. Handler must be mounted at baseURL to serve them.
+func WithSigning(baseURL string, secret []byte) LocalOption {
+	return func(l *Local) {
+		l.baseURL = strings.TrimRight(baseURL, "/")
+		l.secret = secret
+	}
+}
+
+// Local stores objects as files in a directory, e.g. for development and tests.
+// Objects are written atomically; metadata other than the size and modification time is not stored.
+type Local struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// compile time interface check.
+var _ Bucket = &Local{}
+
+// NewLocal returns a bucket storing objects in the directory in input, creating it if needed.
+func NewLocal(root string, opts ...LocalOption) (*Local, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create bucket directory: %w", err)
+	}
+	l := &Local{root: root}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l, nil
+}
+
+// path returns the path of the object, confined to the root directory.
+func (l *Local) path(key string) string {
+	return filepath.Join(l.root, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+// Get opens the file of the object.
+func (l *Local) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open object: %w", err)
+	}
+	return f, nil
+}
+
+// Put writes the file of the object atomically, creating the parent directories as needed.
+func (l *Local) Put(_ context.Context, key string, r io.Reader, _ ...PutOption) error {
+	path := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("could not create directory: %w", err)
+	}
+	return filex.WriteAtomicFrom(path, r, 0o644)
+}
+
+// Delete removes the file of the object.
+func (l *Local) Delete(_ context.Context, key string) error {
+	if err := os.Remove(l.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not delete object: %w", err)
+	}
+	return nil
+}
+
+// List walks the directory for the files whose key starts with prefix, skipping hidden files.
+func (l *Local) List(_ context.Context, prefix string) ([]Object, error) {
+	var objs []Object
+	err := filepath.WalkDir(l.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objs = append(objs, Object{Key: key, Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list objects: %w", err)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return objs, nil
+}
+
+// SignedURL returns a URL served by Handler, or ErrUnsupported if signing is not enabled with WithSigning.
+func (l *Local) SignedURL(_ context.Context, key, method string, expiry time.Duration) (string, error) {
+	if l.secret == nil {
+		return "", ErrUnsupported
+	}
+	key = strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+key)), "/")
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	q := url.Values{}
+	q.Set("method", method)
+	q.Set("expires", expires)
+	q.Set("signature", l.sign(method, key, expires))
+	return l.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+func (l *Local) sign(method, key, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	_, _ = mac.Write([]byte(method + "\n" + key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Handler serves the GET and PUT requests made with the URLs returned by SignedURL.
+// It must be mounted with the prefix of the base URL stripped, e.g. with http.StripPrefix.
+func (l *Local) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+		if l.secret == nil || err != nil || q.Get("method") != r.Method || time.Now().Unix() > expires ||
+			!hmac.Equal([]byte(q.Get("signature")), []byte(l.sign(r.Method, key, q.Get("expires")))) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			f, err := os.Open(l.path(key))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			defer f.Close()
+			fi, err := f.Stat()
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			http.ServeContent(w, r, key, fi.ModTime(), f)
+		case http.MethodPut:
+			if err := l.Put(r.Context(), key, r.Body); err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
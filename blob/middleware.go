@@ -0,0 +1,184 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/retry"
+	"github.com/indiependente/pkg/streamio"
+	"github.com/indiependente/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNotReplayable is returned when a failed Put cannot be retried because its reader is not an io.Seeker.
+var ErrNotReplayable = errors.New("content cannot be replayed")
+
+// Interceptor wraps a bucket operation, named get, put, delete, list or sign, calling next to perform it.
+// For get, only opening the object is intercepted, not reading it.
+type Interceptor func(ctx context.Context, op, key string, next func(ctx context.Context) error) error
+
+// Intercept returns a Middleware running every operation of the bucket through the interceptor.
+func Intercept(ic Interceptor) Middleware {
+	return func(b Bucket) Bucket {
+		return &intercepted{next: b, ic: ic}
+	}
+}
+
+type intercepted struct {
+	next Bucket
+	ic   Interceptor
+}
+
+func (b *intercepted) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	err := b.ic(ctx, "get", key, func(ctx context.Context) error {
+		var err error
+		rc, err = b.next.Get(ctx, key)
+		return err
+	})
+	return rc, err
+}
+
+// Put seeks the reader back to its initial offset before every call after the first one.
+func (b *intercepted) Put(ctx context.Context, key string, r io.Reader, opts ...PutOption) error {
+	var (
+		seeker, seekable = r.(io.Seeker)
+		start            int64
+		calls            int
+		lastErr          error
+	)
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+	return b.ic(ctx, "put", key, func(ctx context.Context) error {
+		calls++
+		if calls > 1 {
+			if !seekable {
+				return fmt.Errorf("%w: %v", ErrNotReplayable, lastErr)
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return fmt.Errorf("could not rewind content: %w", err)
+			}
+		}
+		lastErr = b.next.Put(ctx, key, r, opts...)
+		return lastErr
+	})
+}
+
+func (b *intercepted) Delete(ctx context.Context, key string) error {
+	return b.ic(ctx, "delete", key, func(ctx context.Context) error {
+		return b.next.Delete(ctx, key)
+	})
+}
+
+func (b *intercepted) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objs []Object
+	err := b.ic(ctx, "list", prefix, func(ctx context.Context) error {
+		var err error
+		objs, err = b.next.List(ctx, prefix)
+		return err
+	})
+	return objs, err
+}
+
+func (b *intercepted) SignedURL(ctx context.Context, key, method string, expiry time.Duration) (string, error) {
+	var u string
+	err := b.ic(ctx, "sign", key, func(ctx context.Context) error {
+		var err error
+		u, err = b.next.SignedURL(ctx, key, method, expiry)
+		return err
+	})
+	return u, err
+}
+
+// isTransient reports whether the error may go away by retrying the operation.
+func isTransient(err error) bool {
+	return !errors.Is(err, ErrNotFound) &&
+		!errors.Is(err, ErrUnsupported) &&
+		!errors.Is(err, ErrNotReplayable) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// WithRetry retries the failed operations with the retry options in input, except for missing objects,
+// unsupported operations and cancelled contexts. Puts are retried only if their reader is an io.Seeker,
+// see WithSpooling.
+func WithRetry(opts ...retry.Option) Middleware {
+	opts = append([]retry.Option{retry.WithRetryIf(isTransient)}, opts...)
+	return Intercept(func(ctx context.Context, _, _ string, next func(ctx context.Context) error) error {
+		return retry.Do(ctx, next, opts...)
+	})
+}
+
+// WithSpooling makes the content of Puts replayable when their reader is not an io.Seeker, spooling it in memory up to
+// threshold bytes and to a temporary file in dir past it, os.TempDir() if empty. It must wrap WithRetry.
+func WithSpooling(threshold int64, dir string) Middleware {
+	return func(b Bucket) Bucket {
+		return &spooled{Bucket: b, threshold: threshold, dir: dir}
+	}
+}
+
+type spooled struct {
+	Bucket
+	threshold int64
+	dir       string
+}
+
+func (b *spooled) Put(ctx context.Context, key string, r io.Reader, opts ...PutOption) error {
+	if _, ok := r.(io.Seeker); ok {
+		return b.Bucket.Put(ctx, key, r, opts...)
+	}
+	buf, err := streamio.Spool(r, b.threshold, b.dir)
+	if err != nil {
+		return err
+	}
+	defer buf.Close()
+	return b.Bucket.Put(ctx, key, buf.Reader(), opts...)
+}
+
+// WithMetrics counts the operations by bucket name, operation and outcome and observes their latency
+// on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer, name string) Middleware {
+	ops := metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "blob_operations_total",
+		Help: "Number of blob operations by outcome.",
+	}, []string{"bucket", "op", "outcome"}))
+	latency := metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "blob_operation_duration_seconds",
+		Help:    "Duration of blob operations.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"bucket", "op"}))
+	return Intercept(func(ctx context.Context, op, _ string, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+		outcome := "success"
+		switch {
+		case errors.Is(err, ErrNotFound):
+			outcome = "not_found"
+		case err != nil:
+			outcome = "error"
+		}
+		ops.WithLabelValues(name, op, outcome).Inc()
+		latency.WithLabelValues(name, op).Observe(time.Since(start).Seconds())
+		return err
+	})
+}
+
+// WithTracing runs every operation within a span named after the bucket and the operation, e.g. "uploads put".
+func WithTracing(name string) Middleware {
+	return Intercept(func(ctx context.Context, op, key string, next func(ctx context.Context) error) error {
+		return tracing.Run(ctx, name+" "+op, next,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("blob.bucket", name), attribute.String("blob.key", key)),
+		)
+	})
+}
@@ -0,0 +1,172 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/indiependente/pkg/blob"
+)
+
+const (
+	defaultPartSize    = 16 << 20
+	defaultConcurrency = 4
+)
+
+// Option customises the S3 bucket.
+type Option func(*Bucket)
+
+// WithPartSize uploads objects in parts of n bytes. Defaults to 16MiB; S3 requires at least 5MiB.
+func WithPartSize(n int64) Option {
+	return func(b *Bucket) {
+		b.partSize = n
+	}
+}
+
+// WithConcurrency uploads up to n parts of the same object at once. Defaults to 4.
+// Each upload buffers up to n parts in memory.
+func WithConcurrency(n int) Option {
+	return func(b *Bucket) {
+		b.concurrency = n
+	}
+}
+
+// Bucket stores objects in an S3 bucket, or in any S3 compatible storage.
+type Bucket struct {
+	client      *s3.Client
+	presign     *s3.PresignClient
+	uploader    *manager.Uploader
+	bucket      string
+	partSize    int64
+	concurrency int
+}
+
+// compile time interface check.
+var _ blob.Bucket = &Bucket{}
+
+// New returns a bucket storing objects in the S3 bucket named bucket through the client in input.
+// Objects larger than the part size are uploaded with streaming multipart uploads.
+func New(client *s3.Client, bucket string, opts ...Option) *Bucket {
+	b := &Bucket{
+		client:      client,
+		presign:     s3.NewPresignClient(client),
+		bucket:      bucket,
+		partSize:    defaultPartSize,
+		concurrency: defaultConcurrency,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	// manager is superseded by feature/s3/transfermanager, which has no stable release yet.
+	b.uploader = manager.NewUploader(client, func(u *manager.Uploader) { // nolint:staticcheck
+		u.PartSize = b.partSize
+		u.Concurrency = b.concurrency
+	})
+	return b
+}
+
+// Get downloads the object.
+func (b *Bucket) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(b.bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, wrap(err, key, "could not get object")
+	}
+	return out.Body, nil
+}
+
+// Put uploads the object, in parts uploaded concurrently if it is larger than the part size.
+func (b *Bucket) Put(ctx context.Context, key string, r io.Reader, opts ...blob.PutOption) error {
+	o := blob.NewPutOptions(opts...)
+	in := &s3.PutObjectInput{
+		Bucket:   aws.String(b.bucket),
+		Key:      aws.String(key),
+		Body:     r,
+		Metadata: o.Metadata,
+	}
+	if o.ContentType != "" {
+		in.ContentType = aws.String(o.ContentType)
+	}
+	if _, err := b.uploader.Upload(ctx, in); err != nil {
+		return wrap(err, key, "could not upload object")
+	}
+	return nil
+}
+
+// Delete deletes the object.
+func (b *Bucket) Delete(ctx context.Context, key string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(b.bucket), Key: aws.String(key)})
+	if err != nil {
+		return wrap(err, key, "could not delete object")
+	}
+	return nil
+}
+
+// List lists the objects whose key starts with prefix, following the pagination.
+func (b *Bucket) List(ctx context.Context, prefix string) ([]blob.Object, error) {
+	var objs []blob.Object
+	p := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, wrap(err, prefix, "could not list objects")
+		}
+		for _, o := range page.Contents {
+			objs = append(objs, blob.Object{
+				Key:     aws.ToString(o.Key),
+				Size:    aws.ToInt64(o.Size),
+				ModTime: aws.ToTime(o.LastModified),
+				ETag:    strings.Trim(aws.ToString(o.ETag), `"`),
+			})
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Key < objs[j].Key })
+	return objs, nil
+}
+
+// SignedURL returns a presigned URL for GET or PUT requests.
+func (b *Bucket) SignedURL(ctx context.Context, key, method string, expiry time.Duration) (string, error) {
+	withExpiry := s3.WithPresignExpires(expiry)
+	var (
+		req *v4.PresignedHTTPRequest
+		err error
+	)
+	switch method {
+	case http.MethodGet:
+		req, err = b.presign.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(b.bucket), Key: aws.String(key)}, withExpiry)
+	case http.MethodPut:
+		req, err = b.presign.PresignPutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(b.bucket), Key: aws.String(key)}, withExpiry)
+	default:
+		return "", fmt.Errorf("%w: signing %s requests", blob.ErrUnsupported, method)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not presign request: %w", err)
+	}
+	return req.URL, nil
+}
+
+// wrap maps the missing object errors to blob.ErrNotFound.
+func wrap(err error, key, msg string) error {
+	var (
+		nsk *types.NoSuchKey
+		nf  *types.NotFound
+		ae  smithy.APIError
+	)
+	if errors.As(err, &nsk) || errors.As(err, &nf) || (errors.As(err, &ae) && ae.ErrorCode() == "NoSuchKey") {
+		return fmt.Errorf("%w: %s", blob.ErrNotFound, key)
+	}
+	return fmt.Errorf("%s %s: %w", msg, key, err)
+}
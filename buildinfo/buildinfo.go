@@ -0,0 +1,122 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// These variables are meant to be set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/indiependente/pkg/buildinfo.Version=v1.2.3 -X github.com/indiependente/pkg/buildinfo.Commit=$(git rev-parse HEAD)"
+//
+// When empty, they are filled from the build information embedded by the Go toolchain.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// Info describes the build of the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	Modified  bool   `json:"modified"`
+	Module    string `json:"module"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information of the running binary.
+// Values injected via ldflags take precedence over the ones read from debug.ReadBuildInfo.
+func Get() Info {
+	once.Do(func() {
+		info = read()
+	})
+	return info
+}
+
+func read() Info {
+	i := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if ok {
+		i.Module = bi.Main.Path
+		if i.Version == "" && bi.Main.Version != "(devel)" {
+			i.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if i.Commit == "" {
+					i.Commit = s.Value
+				}
+			case "vcs.time":
+				if i.Date == "" {
+					i.Date = s.Value
+				}
+			case "vcs.modified":
+				i.Modified = s.Value == "true"
+			}
+		}
+	}
+	if i.Version == "" {
+		i.Version = "dev"
+	}
+	return i
+}
+
+// Logger instructs the logger in input to log the version of the binary on every line.
+func Logger(l logger.Logger) logger.Logger {
+	return l.Version(Get().Version)
+}
+
+// Handler returns an HTTP handler writing the build information as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Get())
+	})
+}
+
+// Register registers a build_info gauge, always set to 1, labelled with the build information.
+// Registering it more than once is a no-op.
+func Register(registerer prometheus.Registerer) {
+	i := Get()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "build_info",
+		Help: "Build information of the running binary, always 1.",
+		ConstLabels: prometheus.Labels{
+			"version":    i.Version,
+			"commit":     i.Commit,
+			"date":       i.Date,
+			"go_version": i.GoVersion,
+		},
+	})
+	g.Set(1)
+	if err := registerer.Register(g); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/dedupe"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Loader loads the value of a key missing from the cache.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Option customises a cache.
+type Option func(*config)
+
+type config struct {
+	maxEntries int
+	ttl        time.Duration
+	stale      time.Duration
+	registerer prometheus.Registerer
+	name       string
+	clock      clock.Clock
+}
+
+// WithMaxEntries evicts the least recently used entries once the cache holds more than n entries.
+// Defaults to 0, meaning no limit.
+func WithMaxEntries(n int) Option {
+	return func(c *config) {
+		c.maxEntries = n
+	}
+}
+
+// WithTTL sets the time to live of the entries stored with Set. Defaults to 0, meaning entries never expire.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// WithStaleWhileRevalidate makes GetOrLoad return entries expired for less than d right away,
+// refreshing them in the background.
+func WithStaleWhileRevalidate(d time.Duration) Option {
+	return func(c *config) {
+		c.stale = d
+	}
+}
+
+// WithMetrics counts the lookups of the cache, labeled by name and result, on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer, name string) Option {
+	return func(c *config) {
+		c.registerer = registerer
+		c.name = name
+	}
+}
+
+// WithClock tells the time with the clock in input, e.g. a clock.Fake to test expiration.
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// Cache is an in-memory cache with per-entry expiration and LRU eviction. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	cfg *config
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[K]*list.Element
+	loads *dedupe.Group[K, V]
+
+	hits, misses, stale prometheus.Counter
+}
+
+// New returns an empty cache.
+func New[K comparable, V any](opts ...Option) *Cache[K, V] {
+	cfg := &config{clock: clock.New()}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	c := &Cache[K, V]{
+		cfg:   cfg,
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+		loads: dedupe.New[K, V](),
+	}
+	if cfg.registerer != nil {
+		lookups := metrics.Register(cfg.registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Number of cache lookups by result.",
+		}, []string{"cache", "result"}))
+		c.hits = lookups.WithLabelValues(cfg.name, "hit")
+		c.misses = lookups.WithLabelValues(cfg.name, "miss")
+		c.stale = lookups.WithLabelValues(cfg.name, "stale")
+	}
+	return c
+}
+
+// Get returns the value stored for key, if any and not expired.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, fresh := c.lookup(key, c.cfg.clock.Now())
+	if e == nil || !fresh {
+		inc(c.misses)
+		var zero V
+		return zero, false
+	}
+	inc(c.hits)
+	return e.value, true
+}
+
+// Set stores the value for key with the default time to live.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.cfg.ttl)
+}
+
+// SetWithTTL stores the value for key, expiring it after ttl. A zero ttl means the entry never expires.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.cfg.clock.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, expires)
+}
+
+// Delete removes the entry of key.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Purge removes every entry.
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[K]*list.Element)
+}
+
+// Len returns the number of entries, including the expired ones not evicted yet.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// GetOrLoad returns the value stored for key, loading and storing it with loader if missing or expired.
+// Concurrent loads of the same key are deduplicated with a dedupe.Group: only one loader runs and the others
+// wait for its result.
+// With WithStaleWhileRevalidate, recently expired values are returned right away and refreshed in the background.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	c.mu.Lock()
+	e, fresh := c.lookup(key, c.cfg.clock.Now())
+	switch {
+	case e != nil && fresh:
+		// the value is copied under the lock, as set replaces it in place
+		v := e.value
+		c.mu.Unlock()
+		inc(c.hits)
+		return v, nil
+	case e != nil:
+		v := e.value
+		c.mu.Unlock()
+		inc(c.stale)
+		go func() {
+			_, _ = c.load(context.WithoutCancel(ctx), key, loader)
+		}()
+		return v, nil
+	}
+	c.mu.Unlock()
+	inc(c.misses)
+	return c.load(ctx, key, loader)
+}
+
+// load runs the loader, unless a load of the same key is already in progress, and stores its result.
+// The load is cancelled only once every caller waiting for it has gone.
+func (c *Cache[K, V]) load(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	return c.loads.DoValue(ctx, key, func(ctx context.Context) (V, error) {
+		v, err := loader(ctx, key)
+		if err != nil {
+			return v, err
+		}
+		var expires time.Time
+		if c.cfg.ttl > 0 {
+			expires = c.cfg.clock.Now().Add(c.cfg.ttl)
+		}
+		c.mu.Lock()
+		c.set(key, v, expires)
+		c.mu.Unlock()
+		return v, nil
+	})
+}
+
+// lookup returns the entry of key and whether it is fresh, evicting it if expired beyond the stale window.
+// It must be called with the lock held.
+func (c *Cache[K, V]) lookup(key K, now time.Time) (*entry[K, V], bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry[K, V])
+	if e.expires.IsZero() || now.Before(e.expires) {
+		c.ll.MoveToFront(el)
+		return e, true
+	}
+	if now.Before(e.expires.Add(c.cfg.stale)) {
+		return e, false
+	}
+	c.remove(el)
+	return nil, false
+}
+
+// set stores the entry, evicting the least recently used ones if needed. It must be called with the lock held.
+func (c *Cache[K, V]) set(key K, value V, expires time.Time) {
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	for c.cfg.maxEntries > 0 && c.ll.Len() > c.cfg.maxEntries {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *Cache[K, V]) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
+
+// snapshotEntry is the serialised form of an entry.
+type snapshotEntry[K comparable, V any] struct {
+	Key     K         `json:"key"`
+	Value   V         `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Snapshot writes the entries not expired yet to w as JSON, so that they can be restored with Restore,
+// e.g. to warm the cache up after a restart.
+func (c *Cache[K, V]) Snapshot(w io.Writer) error {
+	now := c.cfg.clock.Now()
+	c.mu.Lock()
+	entries := make([]snapshotEntry[K, V], 0, c.ll.Len())
+	// oldest first, so that Restore preserves the recency order
+	for el := c.ll.Back(); el != nil; el = el.Prev() {
+		e := el.Value.(*entry[K, V])
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			continue
+		}
+		entries = append(entries, snapshotEntry[K, V]{Key: e.key, Value: e.value, Expires: e.expires})
+	}
+	c.mu.Unlock()
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		return fmt.Errorf("could not encode snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore loads the entries written by Snapshot, skipping the ones expired in the meantime.
+func (c *Cache[K, V]) Restore(r io.Reader) error {
+	var entries []snapshotEntry[K, V]
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return fmt.Errorf("could not decode snapshot: %w", err)
+	}
+	now := c.cfg.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		if !e.Expires.IsZero() && !now.Before(e.Expires) {
+			continue
+		}
+		c.set(e.Key, e.Value, e.Expires)
+	}
+	return nil
+}
+
+func inc(c prometheus.Counter) {
+	if c != nil {
+		c.Inc()
+	}
+}
@@ -0,0 +1,298 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+	defaultHalfOpenProbes   = 1
+)
+
+var (
+	// ErrOpen is returned when the breaker is open and calls are rejected.
+	ErrOpen = errors.New("circuit breaker is open")
+	// ErrTooManyProbes is returned when the breaker is half-open and all the probe slots are taken.
+	ErrTooManyProbes = errors.New("circuit breaker is half-open: too many probes")
+)
+
+// State is the state of a breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open rejects every call.
+	Open
+	// HalfOpen lets a limited number of probe calls through to test whether the dependency recovered.
+	HalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Option customises a breaker.
+type Option func(*config)
+
+type config struct {
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenProbes   int
+	isFailure        func(error) bool
+	onStateChange    []func(name string, from, to State)
+}
+
+// WithFailureThreshold opens the breaker after n consecutive failures.
+func WithFailureThreshold(n int) Option {
+	return func(c *config) {
+		c.failureThreshold = n
+	}
+}
+
+// WithOpenTimeout keeps the breaker open for d before letting probes through.
+func WithOpenTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.openTimeout = d
+	}
+}
+
+// WithHalfOpenProbes lets up to n concurrent probes through while half-open;
+// the breaker closes once n probes succeed and opens again as soon as one fails.
+func WithHalfOpenProbes(n int) Option {
+	return func(c *config) {
+		c.halfOpenProbes = n
+	}
+}
+
+// WithIsFailure decides which errors count as failures. By default every error but context cancellation does.
+func WithIsFailure(fn func(error) bool) Option {
+	return func(c *config) {
+		c.isFailure = fn
+	}
+}
+
+// WithOnStateChange calls the function in input whenever the breaker changes state, e.g. to log or export metrics.
+// It is called without holding the breaker lock, so it may use the breaker.
+func WithOnStateChange(fn func(name string, from, to State)) Option {
+	return func(c *config) {
+		c.onStateChange = append(c.onStateChange, fn)
+	}
+}
+
+// Breaker stops calling a failing dependency for a while, giving it time to recover.
+type Breaker[T any] struct {
+	name string
+	cfg  config
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	probes    int
+	successes int
+	openedAt  time.Time
+	// generation is incremented on every state change, so that the outcomes of calls let through
+	// in a previous state are ignored.
+	generation uint64
+	// changes are the state changes to notify once the lock is released.
+	changes []stateChange
+}
+
+type stateChange struct {
+	from, to State
+}
+
+// ticket is handed to the calls let through by the breaker.
+type ticket struct {
+	generation uint64
+	probe      bool
+}
+
+// New returns a closed breaker with the name in input.
+func New[T any](name string, opts ...Option) *Breaker[T] {
+	cfg := config{
+		failureThreshold: defaultFailureThreshold,
+		openTimeout:      defaultOpenTimeout,
+		halfOpenProbes:   defaultHalfOpenProbes,
+		isFailure: func(err error) bool {
+			return !errors.Is(err, context.Canceled)
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Breaker[T]{name: name, cfg: cfg}
+}
+
+// Name returns the name of the breaker.
+func (b *Breaker[T]) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker[T]) State() State {
+	b.mu.Lock()
+	defer b.unlock()
+	b.refresh()
+	return b.state
+}
+
+// Execute calls fn if the breaker allows it, returning ErrOpen or ErrTooManyProbes otherwise,
+// and records its outcome. A panicking fn counts as a failure.
+func (b *Breaker[T]) Execute(ctx context.Context, fn func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	t, err := b.acquire()
+	if err != nil {
+		return zero, err
+	}
+	failed := true
+	defer func() {
+		b.record(t, failed)
+	}()
+	v, err := fn(ctx)
+	failed = err != nil && b.cfg.isFailure(err)
+	return v, err
+}
+
+// acquire checks whether a call can go through, returning the ticket to record its outcome with.
+func (b *Breaker[T]) acquire() (ticket, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	b.refresh()
+	t := ticket{generation: b.generation}
+	switch b.state {
+	case Open:
+		return t, ErrOpen
+	case HalfOpen:
+		if b.probes >= b.cfg.halfOpenProbes {
+			return t, ErrTooManyProbes
+		}
+		b.probes++
+		t.probe = true
+	}
+	return t, nil
+}
+
+// record records the outcome of a call, releasing its probe slot, unless the breaker changed state since.
+func (b *Breaker[T]) record(t ticket, failed bool) {
+	b.mu.Lock()
+	defer b.unlock()
+	if t.generation != b.generation {
+		return
+	}
+	if t.probe {
+		b.probes--
+	}
+
+	switch b.state {
+	case Closed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.failureThreshold {
+			b.setState(Open)
+		}
+	case HalfOpen:
+		if !t.probe {
+			return
+		}
+		if failed {
+			b.setState(Open)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.halfOpenProbes {
+			b.setState(Closed)
+		}
+	}
+}
+
+// refresh moves an open breaker to half-open once the open timeout elapsed.
+func (b *Breaker[T]) refresh() {
+	if b.state == Open && time.Since(b.openedAt) >= b.cfg.openTimeout {
+		b.setState(HalfOpen)
+	}
+}
+
+func (b *Breaker[T]) setState(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.successes = 0
+	b.probes = 0
+	if to == Open {
+		b.openedAt = time.Now()
+	}
+	b.changes = append(b.changes, stateChange{from: from, to: to})
+}
+
+// unlock releases the lock, then notifies the state changes made while holding it.
+func (b *Breaker[T]) unlock() {
+	changes := b.changes
+	b.changes = nil
+	b.mu.Unlock()
+	for _, c := range changes {
+		for _, fn := range b.cfg.onStateChange {
+			fn(b.name, c.from, c.to)
+		}
+	}
+}
+
+// Registry holds named breakers sharing the same options.
+type Registry[T any] struct {
+	opts []Option
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker[T]
+}
+
+// NewRegistry returns a Registry creating breakers with the options in input.
+func NewRegistry[T any](opts ...Option) *Registry[T] {
+	return &Registry[T]{opts: opts, breakers: make(map[string]*Breaker[T])}
+}
+
+// Get returns the breaker with the name in input, creating it if needed.
+func (r *Registry[T]) Get(name string) *Breaker[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.breakers[name]
+	if !ok {
+		b = New[T](name, r.opts...)
+		r.breakers[name] = b
+	}
+	return b
+}
+
+// States returns the current state of every breaker in the registry.
+func (r *Registry[T]) States() map[string]State {
+	r.mu.Lock()
+	breakers := make([]*Breaker[T], 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mu.Unlock()
+	states := make(map[string]State, len(breakers))
+	for _, b := range breakers {
+		states[b.name] = b.State()
+	}
+	return states
+}
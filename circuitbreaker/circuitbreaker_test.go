@@ -0,0 +1,109 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errBoom = errors.New("boom")
+
+func fail(context.Context) (int, error) {
+	return 0, errBoom
+}
+
+func succeed(context.Context) (int, error) {
+	return 1, nil
+}
+
+// hold starts a call blocked until the returned function is called with its error.
+func hold(t *testing.T, b *Breaker[int]) func(error) {
+	t.Helper()
+	started, release, done := make(chan error, 1), make(chan error), make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = b.Execute(context.Background(), func(context.Context) (int, error) {
+			started <- nil
+			return 0, <-release
+		})
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("call was not let through")
+	}
+	return func(err error) {
+		release <- err
+		<-done
+	}
+}
+
+func TestPanicReleasesProbe(t *testing.T) {
+	b := New[int]("test", WithFailureThreshold(1), WithOpenTimeout(0))
+	_, _ = b.Execute(context.Background(), fail)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Execute() swallowed the panic")
+			}
+		}()
+		_, _ = b.Execute(context.Background(), func(context.Context) (int, error) {
+			panic("boom")
+		})
+	}()
+	if _, err := b.Execute(context.Background(), succeed); err != nil {
+		t.Fatalf("Execute() after a panicking probe: error = %v", err)
+	}
+	if got := b.State(); got != Closed {
+		t.Fatalf("got state %v, want %v", got, Closed)
+	}
+}
+
+func TestStaleProbesIgnored(t *testing.T) {
+	b := New[int]("test", WithFailureThreshold(1), WithOpenTimeout(0), WithHalfOpenProbes(2))
+	_, _ = b.Execute(context.Background(), fail)
+
+	// two probes of the first half-open phase: the second one fails and opens the breaker again
+	stale := hold(t, b)
+	hold(t, b)(errBoom)
+
+	// first probe of the second half-open phase
+	current := hold(t, b)
+	stale(nil)
+	next := hold(t, b)
+	if _, err := b.Execute(context.Background(), succeed); !errors.Is(err, ErrTooManyProbes) {
+		t.Fatalf("Execute() with every probe slot taken: error = %v, want %v", err, ErrTooManyProbes)
+	}
+
+	current(nil)
+	if got := b.State(); got != HalfOpen {
+		t.Fatalf("got state %v after one successful probe, want %v", got, HalfOpen)
+	}
+	next(nil)
+	if got := b.State(); got != Closed {
+		t.Fatalf("got state %v after two successful probes, want %v", got, Closed)
+	}
+}
+
+func TestOnStateChangeCanUseBreaker(t *testing.T) {
+	var b *Breaker[int]
+	changes := make(chan State, 10)
+	b = New[int]("test", WithFailureThreshold(1), WithOnStateChange(func(string, State, State) {
+		changes <- b.State()
+	}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = b.Execute(context.Background(), fail)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("state change callback deadlocked")
+	}
+	if got := <-changes; got != Open {
+		t.Fatalf("callback saw state %v, want %v", got, Open)
+	}
+}
@@ -0,0 +1,164 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock whose time only moves when told to. It is safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters map[*waiter]struct{}
+	// changed is closed and replaced every time a waiter is added.
+	changed chan struct{}
+}
+
+// compile time interface check.
+var _ Clock = &Fake{}
+
+// waiter is a pending timer or ticker.
+type waiter struct {
+	clock  *Fake
+	until  time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+// NewFake returns a Fake clock set to the time in input.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, waiters: make(map[*waiter]struct{}), changed: make(chan struct{})}
+}
+
+// Now returns the current time of the clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the time elapsed since t according to the clock.
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// After returns a channel receiving the time once the clock is advanced by d.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer returns a Timer firing once the clock is advanced by d.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return &fakeTimer{f.schedule(d, 0)}
+}
+
+// NewTicker returns a Ticker ticking every time the clock is advanced by d.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return &fakeTicker{f.schedule(d, d)}
+}
+
+// Sleep blocks until the clock is advanced by d.
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers due by then.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	for w := range f.waiters {
+		if w.until.After(f.now) {
+			continue
+		}
+		select {
+		case w.ch <- f.now:
+		default: // drop the tick, as time.Ticker does with slow receivers
+		}
+		if w.period == 0 {
+			delete(f.waiters, w)
+			continue
+		}
+		for !w.until.After(f.now) {
+			w.until = w.until.Add(w.period)
+		}
+	}
+}
+
+// BlockUntil blocks until at least n timers and tickers are waiting on the clock,
+// so that a test can advance it once the code under test is sleeping.
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		if len(f.waiters) >= n {
+			f.mu.Unlock()
+			return
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		<-changed
+	}
+}
+
+func (f *Fake) schedule(d, period time.Duration) *waiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &waiter{clock: f, until: f.now.Add(d), period: period, ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- f.now
+		if period == 0 {
+			return w
+		}
+		w.until = f.now.Add(period)
+	}
+	f.add(w)
+	return w
+}
+
+// add registers the waiter. It must be called with the lock held.
+func (f *Fake) add(w *waiter) {
+	f.waiters[w] = struct{}{}
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// stop unregisters the waiter, reporting whether it was pending.
+func (w *waiter) stop() bool {
+	f := w.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.waiters[w]
+	delete(f.waiters, w)
+	return ok
+}
+
+// reset reschedules the waiter d from now with the period in input, reporting whether it was pending.
+func (w *waiter) reset(d, period time.Duration) bool {
+	f := w.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.waiters[w]
+	w.until = f.now.Add(d)
+	w.period = period
+	f.add(w)
+	return ok
+}
+
+type fakeTimer struct {
+	w *waiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time        { return t.w.ch }
+func (t *fakeTimer) Stop() bool                 { return t.w.stop() }
+func (t *fakeTimer) Reset(d time.Duration) bool { return t.w.reset(d, 0) }
+
+type fakeTicker struct {
+	w *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time   { return t.w.ch }
+func (t *fakeTicker) Stop()                 { t.w.stop() }
+func (t *fakeTicker) Reset(d time.Duration) { t.w.reset(d, d) }
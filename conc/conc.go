@@ -0,0 +1,209 @@
+package conc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// ErrPanic is wrapped by the errors of goroutines which panicked.
+var ErrPanic = errors.New("panicked")
+
+// PanicError is the error of a goroutine which panicked, carrying the recovered value and the stack trace.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrPanic, e.Value)
+}
+
+// Unwrap returns ErrPanic, so that errors.Is(err, ErrPanic) holds.
+func (e *PanicError) Unwrap() error {
+	return ErrPanic
+}
+
+// Catch calls fn, turning a panic into a *PanicError.
+func Catch(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// Group runs goroutines, waiting for them and returning the first error, as errgroup.Group does,
+// but turning panics into *PanicError values instead of crashing the process.
+// The zero value is ready to use and has no limit.
+type Group struct {
+	eg      errgroup.Group
+	cancel  context.CancelCauseFunc
+	errOnce sync.Once
+}
+
+// WithContext returns a Group and a context derived from ctx which is cancelled when a goroutine fails
+// or Wait returns.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit limits the number of goroutines running at once to n; a negative value removes the limit.
+// It must not be called while goroutines are running.
+func (g *Group) SetLimit(n int) {
+	g.eg.SetLimit(n)
+}
+
+// Go runs fn in a new goroutine, blocking while the limit of running goroutines is reached.
+func (g *Group) Go(fn func() error) {
+	g.eg.Go(func() error {
+		return g.run(fn)
+	})
+}
+
+// TryGo runs fn in a new goroutine only if the limit of running goroutines is not reached, reporting whether it did.
+func (g *Group) TryGo(fn func() error) bool {
+	return g.eg.TryGo(func() error {
+		return g.run(fn)
+	})
+}
+
+// Wait blocks until all the goroutines have returned, returning the first error.
+func (g *Group) Wait() error {
+	err := g.eg.Wait()
+	if g.cancel != nil {
+		g.cancel(err)
+	}
+	return err
+}
+
+// run calls fn, cancelling the context of the group on the first error.
+func (g *Group) run(fn func() error) error {
+	err := Catch(fn)
+	if err != nil && g.cancel != nil {
+		g.errOnce.Do(func() {
+			g.cancel(err)
+		})
+	}
+	return err
+}
+
+// MapOption customises Map.
+type MapOption func(*mapConfig)
+
+type mapConfig struct {
+	collectAll bool
+}
+
+// CollectAll processes every item even if some fail, returning all the errors joined.
+// By default Map stops at the first error, cancelling the context of the other calls.
+func CollectAll() MapOption {
+	return func(c *mapConfig) {
+		c.collectAll = true
+	}
+}
+
+// Map calls fn on every item with at most parallelism calls running at once, or unbounded if parallelism
+// is not positive, returning the results in the order of the items.
+// Panics are turned into *PanicError values. Failed items leave the zero value in the results.
+func Map[T, R any](ctx context.Context, items []T, fn func(ctx context.Context, item T) (R, error), parallelism int, opts ...MapOption) ([]R, error) {
+	cfg := &mapConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	results := make([]R, len(items))
+
+	if cfg.collectAll {
+		var (
+			g    Group
+			mu   sync.Mutex
+			errs = make([]error, len(items))
+		)
+		if parallelism > 0 {
+			g.SetLimit(parallelism)
+		}
+		for i, item := range items {
+			g.Go(func() error {
+				err := Catch(func() error {
+					r, err := fn(ctx, item)
+					results[i] = r
+					return err
+				})
+				mu.Lock()
+				errs[i] = err
+				mu.Unlock()
+				return nil
+			})
+		}
+		_ = g.Wait()
+		return results, errors.Join(errs...)
+	}
+
+	g, gctx := WithContext(ctx)
+	if parallelism > 0 {
+		g.SetLimit(parallelism)
+	}
+	for i, item := range items {
+		if gctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			r, err := fn(gctx, item)
+			results[i] = r
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return results, err
+	}
+	return results, ctx.Err()
+}
+
+// Semaphore limits the access to a resource of the given capacity; callers acquire a weight
+// of it for the duration of their use.
+type Semaphore struct {
+	sem *semaphore.Weighted
+	n   int64
+}
+
+// NewSemaphore returns a semaphore with capacity n.
+func NewSemaphore(n int64) *Semaphore {
+	return &Semaphore{sem: semaphore.NewWeighted(n), n: n}
+}
+
+// Acquire blocks until the weight is available or the context is done.
+func (s *Semaphore) Acquire(ctx context.Context, weight int64) error {
+	if weight > s.n {
+		return fmt.Errorf("could not acquire %d: semaphore capacity is %d", weight, s.n)
+	}
+	if err := s.sem.Acquire(ctx, weight); err != nil {
+		return fmt.Errorf("could not acquire semaphore: %w", err)
+	}
+	return nil
+}
+
+// TryAcquire acquires the weight without blocking, reporting whether it did.
+func (s *Semaphore) TryAcquire(weight int64) bool {
+	return s.sem.TryAcquire(weight)
+}
+
+// Release releases the weight.
+func (s *Semaphore) Release(weight int64) {
+	s.sem.Release(weight)
+}
+
+// Do calls fn holding the weight, releasing it when fn returns or panics.
+func (s *Semaphore) Do(ctx context.Context, weight int64, fn func(ctx context.Context) error) error {
+	if err := s.Acquire(ctx, weight); err != nil {
+		return err
+	}
+	defer s.Release(weight)
+	return fn(ctx)
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size in bytes, parsed from strings such as "512", "10KB" or "1.5GiB".
+// Decimal units (KB, MB, GB, TB) are powers of 1000, binary units (KiB, MiB, GiB, TiB) powers of 1024.
+type ByteSize int64
+
+var byteUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// ParseByteSize parses a size with an optional unit.
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	}
+	mult, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", unit)
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	return ByteSize(n * mult), nil
+}
+
+// String returns the size in bytes.
+func (b ByteSize) String() string {
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
+
+// UnmarshalText parses the size, so it can be used in JSON, YAML and TOML files.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	s, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = s
+	return nil
+}
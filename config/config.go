@@ -0,0 +1,389 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/indiependente/pkg/logger"
+	"go.yaml.in/yaml/v3"
+)
+
+const redacted = "[REDACTED]"
+
+// Struct tags understood by Load.
+const (
+	// envTag names the environment variable of a field, or the prefix of the fields of a nested struct.
+	envTag = "env"
+	// defaultTag holds the default value of a field.
+	defaultTag = "default"
+	// requiredTag makes Load fail when the field is left empty.
+	requiredTag = "required"
+	// secretTag redacts the field value in String.
+	secretTag = "secret"
+	// flagTag names the command line flag of a field.
+	flagTag = "flag"
+	// secretRefTag names the secret holding the value of a field, resolved by the SecretGetter passed to WithSecrets.
+	secretRefTag = "secretref"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	urlType      = reflect.TypeOf(url.URL{})
+	logLevelType = reflect.TypeOf(logger.LogLevel(0))
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+)
+
+// Option customises Load.
+type Option func(*loader)
+
+type loader struct {
+	prefix   string
+	files    []string
+	optional map[string]bool
+	args     []string
+	flags    bool
+	lookup   func(string) (string, bool)
+	secrets  SecretGetter
+	ctx      context.Context
+}
+
+// SecretGetter retrieves secrets by name, e.g. a secrets.Provider.
+type SecretGetter interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// WithPrefix prepends the prefix in input, followed by an underscore, to every environment variable name.
+func WithPrefix(prefix string) Option {
+	return func(l *loader) {
+		l.prefix = prefix
+	}
+}
+
+// WithFile loads the JSON, YAML or TOML file in input, chosen by extension, before reading the environment.
+func WithFile(path string) Option {
+	return func(l *loader) {
+		l.files = append(l.files, path)
+	}
+}
+
+// WithOptionalFile behaves as WithFile but ignores the file if it does not exist.
+func WithOptionalFile(path string) Option {
+	return func(l *loader) {
+		l.files = append(l.files, path)
+		l.optional[path] = true
+	}
+}
+
+// WithFlags parses the command line arguments in input, e.g. os.Args[1:], for the fields with a flag tag.
+// Flags take precedence over environment variables.
+func WithFlags(args []string) Option {
+	return func(l *loader) {
+		l.flags = true
+		l.args = args
+	}
+}
+
+// WithSecrets resolves the fields tagged secretref:"name" through the getter in input, after flags.
+// Fields resolved this way are also redacted by String.
+func WithSecrets(ctx context.Context, getter SecretGetter) Option {
+	return func(l *loader) {
+		l.ctx = ctx
+		l.secrets = getter
+	}
+}
+
+// Load populates the struct pointed to by cfg, applying in order: default tags, files, environment variables, flags and secrets.
+// Fields are looked up in the environment by their env tag; nested structs use it as a prefix for their fields.
+// Besides the basic types and slices of them (comma separated), fields can be time.Duration, url.URL,
+// ByteSize and logger.LogLevel. Load fails if a field tagged required:"true" is left empty.
+func Load(cfg interface{}, opts ...Option) error {
+	l := &loader{optional: make(map[string]bool), lookup: os.LookupEnv}
+	for _, opt := range opts {
+		opt(l)
+	}
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config must be a pointer to a struct")
+	}
+	v = v.Elem()
+
+	if err := walk(v, "", func(f field) error {
+		if def, ok := f.tag.Lookup(defaultTag); ok && f.value.IsZero() {
+			return setValue(f.value, def)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, path := range l.files {
+		if err := loadFile(cfg, path, l.optional[path]); err != nil {
+			return err
+		}
+	}
+	if err := walk(v, l.prefix, func(f field) error {
+		if f.env == "" {
+			return nil
+		}
+		if raw, ok := l.lookup(f.env); ok {
+			return setValue(f.value, raw)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if l.flags {
+		if err := l.parseFlags(v); err != nil {
+			return err
+		}
+	}
+	if l.secrets != nil {
+		if err := walk(v, l.prefix, func(f field) error {
+			ref := f.tag.Get(secretRefTag)
+			if ref == "" {
+				return nil
+			}
+			raw, err := l.secrets.Get(l.ctx, ref)
+			if err != nil {
+				return fmt.Errorf("could not get secret %s for %s: %w", ref, f.name, err)
+			}
+			return setValue(f.value, raw)
+		}); err != nil {
+			return err
+		}
+	}
+
+	var missing []string
+	_ = walk(v, l.prefix, func(f field) error {
+		if f.tag.Get(requiredTag) == "true" && f.value.IsZero() {
+			missing = append(missing, f.name)
+		}
+		return nil
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// String returns a representation of the struct pointed to by cfg in which fields tagged secret:"true"
+// or secretref are redacted.
+func String(cfg interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+	if v.Kind() != reflect.Struct {
+		return fmt.Sprint(cfg)
+	}
+	var parts []string
+	_ = walk(v, "", func(f field) error {
+		val := redacted
+		if f.tag.Get(secretTag) != "true" && f.tag.Get(secretRefTag) == "" {
+			val = formatValue(f.value)
+		}
+		parts = append(parts, f.name+"="+val)
+		return nil
+	})
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// field is a leaf field of the configuration struct.
+type field struct {
+	name  string
+	env   string
+	tag   reflect.StructTag
+	value reflect.Value
+}
+
+// walk calls fn for every settable leaf field, recursing in nested structs.
+func walk(v reflect.Value, prefix string, fn func(field) error) error {
+	return walkNamed(v, prefix, "", fn)
+}
+
+func walkNamed(v reflect.Value, envPrefix, namePrefix string, fn func(field) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		env := sf.Tag.Get(envTag)
+		if env != "" && envPrefix != "" {
+			env = envPrefix + "_" + env
+		}
+		name := namePrefix + sf.Name
+		if fv.Kind() == reflect.Struct && !isLeaf(fv.Type()) {
+			nested := envPrefix
+			if env != "" {
+				nested = env
+			}
+			if err := walkNamed(fv, nested, name+".", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(field{name: name, env: env, tag: sf.Tag, value: fv}); err != nil {
+			return fmt.Errorf("could not set %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func isLeaf(t reflect.Type) bool {
+	return t == urlType || t == reflect.TypeOf(time.Time{})
+}
+
+func loadFile(cfg interface{}, path string, optional bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not decode config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (l *loader) parseFlags(v reflect.Value) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	values := make(map[string]*string)
+	fields := make(map[string]reflect.Value)
+	_ = walk(v, "", func(f field) error {
+		name := f.tag.Get(flagTag)
+		if name == "" {
+			return nil
+		}
+		values[name] = fs.String(name, formatValue(f.value), f.name)
+		fields[name] = f.value
+		return nil
+	})
+	if err := fs.Parse(l.args); err != nil {
+		return fmt.Errorf("could not parse flags: %w", err)
+	}
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		if err == nil {
+			if serr := setValue(fields[fl.Name], *values[fl.Name]); serr != nil {
+				err = fmt.Errorf("could not set flag %s: %w", fl.Name, serr)
+			}
+		}
+	})
+	return err
+}
+
+// setValue parses raw into the value according to its type.
+func setValue(v reflect.Value, raw string) error {
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case urlType:
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	case logLevelType:
+		v.SetInt(int64(logger.ParseLogLevel(raw)))
+		return nil
+	case byteSizeType:
+		s, err := ParseByteSize(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(s))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(raw, ",")
+		s := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setValue(s.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(p)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch v.Type() {
+	case urlType:
+		u := v.Interface().(url.URL)
+		return u.Redacted()
+	case logLevelType, durationType, byteSizeType:
+		return fmt.Sprint(v.Interface())
+	}
+	if v.Kind() == reflect.Slice {
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = formatValue(v.Index(i))
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,184 @@
+package cryptox
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// KeySize is the size of the keys used for encryption.
+const KeySize = chacha20poly1305.KeySize
+
+var (
+	// ErrDecrypt is returned when a ciphertext cannot be decrypted, because it was tampered with,
+	// encrypted with another key or with other additional data.
+	ErrDecrypt = errors.New("could not decrypt")
+	// ErrUnknownKey is returned when decrypting a ciphertext encrypted with a key not in the key ring.
+	ErrUnknownKey = errors.New("unknown key")
+)
+
+// NewKey returns a random key suitable for Encrypt and KeyRing.
+func NewKey() ([]byte, error) {
+	return RandomBytes(KeySize)
+}
+
+// Encrypt encrypts and authenticates the plaintext with XChaCha20-Poly1305 and a random nonce,
+// which is prepended to the returned ciphertext. The additional data, if any, is authenticated but not encrypted,
+// and must be passed to Decrypt as is, e.g. the ID of the record the ciphertext belongs to.
+func Encrypt(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %w", err)
+	}
+	nonce, err := RandomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt decrypts the ciphertext produced by Encrypt, failing with ErrDecrypt if it was tampered with.
+func Decrypt(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %w", err)
+	}
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// KeyRing encrypts with its primary key and decrypts with any of its keys, so that keys can be rotated
+// without re-encrypting the existing data at once: ciphertexts are prefixed with the ID of their key.
+// It is safe for concurrent use.
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	primary string
+}
+
+// NewKeyRing returns a key ring encrypting with the key in input, identified by id.
+func NewKeyRing(id string, key []byte) (*KeyRing, error) {
+	kr := &KeyRing{keys: make(map[string][]byte)}
+	if err := kr.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Add adds a key used only for decryption, e.g. a retired key whose ciphertexts are still around.
+func (kr *KeyRing) Add(id string, key []byte) error {
+	if len(id) == 0 || len(id) > 255 {
+		return errors.New("could not add key: id must be between 1 and 255 bytes long")
+	}
+	if len(key) != KeySize {
+		return fmt.Errorf("could not add key %s: key must be %d bytes long", id, KeySize)
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys[id] = append([]byte(nil), key...)
+	return nil
+}
+
+// Rotate adds the key in input and makes it the primary one, used for encrypting from now on.
+func (kr *KeyRing) Rotate(id string, key []byte) error {
+	if err := kr.Add(id, key); err != nil {
+		return err
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.primary = id
+	return nil
+}
+
+// Remove removes the key in input, unless it is the primary one.
+func (kr *KeyRing) Remove(id string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if id != kr.primary {
+		delete(kr.keys, id)
+	}
+}
+
+// Primary returns the ID of the primary key.
+func (kr *KeyRing) Primary() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.primary
+}
+
+// Encrypt encrypts the plaintext with the primary key. See Encrypt.
+func (kr *KeyRing) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	kr.mu.RLock()
+	id, key := kr.primary, kr.keys[kr.primary]
+	kr.mu.RUnlock()
+	sealed, err := Encrypt(key, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+len(id)+len(sealed))
+	out = append(out, byte(len(id)))
+	out = append(out, id...)
+	return append(out, sealed...), nil
+}
+
+// Decrypt decrypts the ciphertext produced by Encrypt with the key it was encrypted with.
+func (kr *KeyRing) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	id, sealed, err := kr.split(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	kr.mu.RLock()
+	key, ok := kr.keys[id]
+	kr.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return Decrypt(key, sealed, additionalData)
+}
+
+// KeyID returns the ID of the key the ciphertext was encrypted with, e.g. to find the data to re-encrypt
+// after a rotation.
+func (kr *KeyRing) KeyID(ciphertext []byte) (string, error) {
+	id, _, err := kr.split(ciphertext)
+	return id, err
+}
+
+// EncryptString encrypts the plaintext, returning the ciphertext encoded as URL safe base64.
+func (kr *KeyRing) EncryptString(plaintext string) (string, error) {
+	b, err := kr.Encrypt([]byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecryptString decrypts the ciphertext produced by EncryptString.
+func (kr *KeyRing) DecryptString(ciphertext string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	plaintext, err := kr.Decrypt(b, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (kr *KeyRing) split(ciphertext []byte) (string, []byte, error) {
+	if len(ciphertext) < 1 || len(ciphertext) < 1+int(ciphertext[0]) {
+		return "", nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+	n := int(ciphertext[0])
+	return string(ciphertext[1 : 1+n]), ciphertext[1+n:], nil
+}
@@ -0,0 +1,186 @@
+package cryptox
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecrypt(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, ad := []byte("secret"), []byte("record-1")
+	ciphertext, err := Encrypt(key, plaintext, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flip := func(i int) []byte {
+		c := bytes.Clone(ciphertext)
+		c[i] ^= 1
+		return c
+	}
+
+	tests := []struct {
+		name       string
+		key        []byte
+		ciphertext []byte
+		ad         []byte
+		wantErr    error
+	}{
+		{name: "valid", key: key, ciphertext: ciphertext, ad: ad},
+		{name: "tampered nonce", key: key, ciphertext: flip(0), ad: ad, wantErr: ErrDecrypt},
+		{name: "tampered body", key: key, ciphertext: flip(len(ciphertext) - 20), ad: ad, wantErr: ErrDecrypt},
+		{name: "tampered tag", key: key, ciphertext: flip(len(ciphertext) - 1), ad: ad, wantErr: ErrDecrypt},
+		{name: "truncated", key: key, ciphertext: ciphertext[:10], ad: ad, wantErr: ErrDecrypt},
+		{name: "wrong key", key: other, ciphertext: ciphertext, ad: ad, wantErr: ErrDecrypt},
+		{name: "wrong additional data", key: key, ciphertext: ciphertext, ad: []byte("record-2"), wantErr: ErrDecrypt},
+		{name: "missing additional data", key: key, ciphertext: ciphertext, wantErr: ErrDecrypt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decrypt(tt.key, tt.ciphertext, tt.ad)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, plaintext) {
+				t.Fatalf("Decrypt() = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestEncryptRejectsShortKey(t *testing.T) {
+	if _, err := Encrypt(make([]byte, KeySize-1), []byte("secret"), nil); err == nil {
+		t.Fatal("Encrypt() accepted a short key")
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	oldKey, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kr, err := NewKeyRing("old", oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCiphertext, err := kr.EncryptString("before")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kr.Rotate("new", newKey); err != nil {
+		t.Fatal(err)
+	}
+	newCiphertext, err := kr.EncryptString("after")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ciphertext, want := range map[string]string{oldCiphertext: "before", newCiphertext: "after"} {
+		got, err := kr.DecryptString(ciphertext)
+		if err != nil {
+			t.Fatalf("DecryptString() error = %v", err)
+		}
+		if got != want {
+			t.Fatalf("DecryptString() = %q, want %q", got, want)
+		}
+	}
+	raw, err := kr.Encrypt([]byte("x"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := kr.KeyID(raw); err != nil || id != "new" {
+		t.Fatalf("KeyID() = %q, %v, want new", id, err)
+	}
+
+	kr.Remove("old")
+	if _, err := kr.DecryptString(oldCiphertext); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("DecryptString() with a removed key: error = %v, want %v", err, ErrUnknownKey)
+	}
+	kr.Remove("new")
+	if kr.Primary() != "new" {
+		t.Fatal("Remove() removed the primary key")
+	}
+}
+
+func TestKeyRingDecrypt(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kr, err := NewKeyRing("k1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another ring using the same key ID for another key
+	impostor, err := NewKeyRing("k1", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := kr.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := impostor.Encrypt([]byte("forged"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := bytes.Clone(ciphertext)
+	renamed[2] = 'X'
+
+	tests := []struct {
+		name       string
+		ciphertext []byte
+		wantErr    error
+	}{
+		{name: "valid", ciphertext: ciphertext},
+		{name: "wrong key with the same ID", ciphertext: forged, wantErr: ErrDecrypt},
+		{name: "unknown key ID", ciphertext: renamed, wantErr: ErrUnknownKey},
+		{name: "empty", ciphertext: nil, wantErr: ErrDecrypt},
+		{name: "truncated key ID", ciphertext: []byte{10, 'k'}, wantErr: ErrDecrypt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kr.Decrypt(tt.ciphertext, nil); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyRingAddValidates(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		id   string
+		key  []byte
+	}{
+		{name: "empty ID", id: "", key: key},
+		{name: "long ID", id: string(make([]byte, 256)), key: key},
+		{name: "short key", id: "k", key: key[:16]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyRing(tt.id, tt.key); err == nil {
+				t.Fatal("NewKeyRing() accepted an invalid key")
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package cryptox
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	minSaltLength = 8
+	minKeyLength  = 16
+)
+
+var (
+	// ErrInvalidHash is returned when verifying a password against a hash not produced by HashPassword.
+	ErrInvalidHash = errors.New("invalid password hash")
+	// ErrInvalidParams is returned when hashing a password with parameters too weak to be used.
+	ErrInvalidParams = errors.New("invalid password hashing parameters")
+)
+
+// Params are the argon2id parameters used to hash passwords.
+type Params struct {
+	// Memory is the memory used by the hash function in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams are the parameters recommended by OWASP for argon2id: 19MiB of memory, 2 iterations, 1 thread.
+var DefaultParams = Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// validate checks that the parameters are usable: argon2 panics without iterations or parallelism, and an empty key
+// would match any password.
+func (p Params) validate() error {
+	switch {
+	case p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0:
+		return fmt.Errorf("memory, iterations and parallelism must be positive, got m=%d,t=%d,p=%d",
+			p.Memory, p.Iterations, p.Parallelism)
+	case p.SaltLength < minSaltLength:
+		return fmt.Errorf("salt must be at least %d bytes, got %d", minSaltLength, p.SaltLength)
+	case p.KeyLength < minKeyLength:
+		return fmt.Errorf("key must be at least %d bytes, got %d", minKeyLength, p.KeyLength)
+	}
+	return nil
+}
+
+// PasswordOption customises password hashing.
+type PasswordOption func(*Params)
+
+// WithParams hashes passwords with the parameters in input instead of DefaultParams.
+func WithParams(p Params) PasswordOption {
+	return func(params *Params) {
+		*params = p
+	}
+}
+
+// HashPassword hashes the password with argon2id and a random salt, returning it in the PHC string format,
+// e.g. $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, which records the parameters along with the hash.
+func HashPassword(password string, opts ...PasswordOption) (string, error) {
+	p := DefaultParams
+	for _, opt := range opts {
+		opt(&p)
+	}
+	if err := p.validate(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidParams, err)
+	}
+	salt, err := RandomBytes(int(p.SaltLength))
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword reports whether the password matches the hash produced by HashPassword,
+// using the parameters recorded in the hash. The comparison takes constant time.
+func VerifyPassword(password, hash string) (bool, error) {
+	p, salt, key, err := decodeHash(hash)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// NeedsRehash reports whether the hash was produced with parameters other than the ones in input,
+// so that it can be replaced after the next successful login.
+func NeedsRehash(hash string, opts ...PasswordOption) bool {
+	want := DefaultParams
+	for _, opt := range opts {
+		opt(&want)
+	}
+	p, _, _, err := decodeHash(hash)
+	return err != nil || p != want
+}
+
+func decodeHash(hash string) (Params, []byte, []byte, error) {
+	var p Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidHash, version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	p.SaltLength = uint32(len(salt)) // nolint:gosec
+	p.KeyLength = uint32(len(key))   // nolint:gosec
+	if err := p.validate(); err != nil {
+		return p, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,122 @@
+package cryptox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testParams are cheap parameters to keep the tests fast.
+var testParams = Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestVerifyPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse", WithParams(testParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+	withPart := func(i int, v string) string {
+		p := append([]string(nil), parts...)
+		p[i] = v
+		return strings.Join(p, "$")
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+		wantErr  error
+	}{
+		{name: "match", password: "correct horse", hash: hash, want: true},
+		{name: "wrong password", password: "battery staple", hash: hash},
+		{name: "empty password", password: "", hash: hash},
+		{name: "tampered hash", password: "correct horse", hash: withPart(5, strings.Repeat("A", len(parts[5])))},
+		{name: "tampered salt", password: "correct horse", hash: withPart(4, strings.Repeat("A", len(parts[4])))},
+		{name: "weakened parameters", password: "correct horse", hash: withPart(3, "m=64,t=1,p=2")},
+		{name: "other algorithm", password: "correct horse", hash: withPart(1, "argon2i"), wantErr: ErrInvalidHash},
+		{name: "other version", password: "correct horse", hash: withPart(2, "v=16"), wantErr: ErrInvalidHash},
+		{name: "zero iterations", password: "correct horse", hash: withPart(3, "m=64,t=0,p=1"), wantErr: ErrInvalidHash},
+		{name: "empty key", password: "", hash: withPart(5, ""), wantErr: ErrInvalidHash},
+		{name: "short salt", password: "correct horse", hash: withPart(4, "AAAA"), wantErr: ErrInvalidHash},
+		{name: "malformed", password: "correct horse", hash: "not a hash", wantErr: ErrInvalidHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyPassword(tt.password, tt.hash)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("VerifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashPasswordRejectsWeakParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{name: "zero memory", params: Params{Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}},
+		{name: "zero iterations", params: Params{Memory: 64, Parallelism: 1, SaltLength: 16, KeyLength: 32}},
+		{name: "zero parallelism", params: Params{Memory: 64, Iterations: 1, SaltLength: 16, KeyLength: 32}},
+		{name: "short salt", params: Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 4, KeyLength: 32}},
+		{name: "empty key", params: Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := HashPassword("password", WithParams(tt.params)); !errors.Is(err, ErrInvalidParams) {
+				t.Fatalf("HashPassword() error = %v, want %v", err, ErrInvalidParams)
+			}
+		})
+	}
+}
+
+func TestHashPasswordSalts(t *testing.T) {
+	a, err := HashPassword("password", WithParams(testParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := HashPassword("password", WithParams(testParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatal("HashPassword() returned the same hash twice")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := HashPassword("password", WithParams(testParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NeedsRehash(hash, WithParams(testParams)) {
+		t.Fatal("NeedsRehash() = true with the parameters of the hash")
+	}
+	if !NeedsRehash(hash) {
+		t.Fatal("NeedsRehash() = false with the default parameters")
+	}
+	if !NeedsRehash("not a hash") {
+		t.Fatal("NeedsRehash() = false with an invalid hash")
+	}
+}
+
+func TestEqualString(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "token", b: "token", want: true},
+		{a: "token", b: "tokem"},
+		{a: "token", b: "token-longer"},
+		{a: "", b: "", want: true},
+	}
+	for _, tt := range tests {
+		if got := EqualString(tt.a, tt.b); got != tt.want {
+			t.Fatalf("EqualString(%q, %q) = %v", tt.a, tt.b, got)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cryptox
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Alphanumeric is the alphabet of letters and digits, for RandomString.
+const Alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// RandomBytes returns n bytes read from the cryptographically secure random number generator.
+func RandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("could not read random bytes: %w", err)
+	}
+	return b, nil
+}
+
+// Token returns a random token made of n bytes, encoded as URL safe base64, e.g. for session IDs,
+// password reset links or API keys. 32 bytes are enough for any of them.
+func Token(n int) (string, error) {
+	b, err := RandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// TokenHex returns a random token made of n bytes, encoded as hex.
+func TokenHex(n int) (string, error) {
+	b, err := RandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RandomString returns a random string of n characters picked uniformly from the alphabet in input,
+// e.g. Alphanumeric for one-time codes.
+func RandomString(n int, alphabet string) (string, error) {
+	runes := []rune(alphabet)
+	if len(runes) == 0 {
+		return "", errors.New("could not generate random string: empty alphabet")
+	}
+	size := big.NewInt(int64(len(runes)))
+	out := make([]rune, n)
+	for i := range out {
+		j, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", fmt.Errorf("could not generate random string: %w", err)
+		}
+		out[i] = runes[j.Int64()]
+	}
+	return string(out), nil
+}
+
+// Equal reports whether a and b are equal, taking time independent of their content.
+// Their length may still leak: use EqualString to compare secrets of variable length.
+func Equal(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// EqualString reports whether a and b are equal, taking time independent of both their content and length,
+// e.g. to compare tokens or API keys supplied by clients with the expected ones.
+func EqualString(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
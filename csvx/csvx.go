@@ -0,0 +1,218 @@
+package csvx
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+
+	"github.com/indiependente/pkg/streamio"
+	"github.com/indiependente/pkg/validate"
+)
+
+// bom is the byte order mark some tools prepend to UTF-8 files.
+const bom = "\uFEFF"
+
+// ErrMissingColumns is returned when the header lacks some of the required columns.
+var ErrMissingColumns = errors.New("missing required columns")
+
+// Row is a record of a CSV file. Its fields are only valid until the mapper returns, as the underlying slice
+// is reused to keep memory use bounded; the strings themselves can be retained.
+type Row struct {
+	// Line is the line number of the record, starting from 1.
+	Line   int
+	Fields []string
+	header map[string]int
+}
+
+// Get returns the value of the column in input, or "" if the file has no such column.
+func (r Row) Get(column string) string {
+	i, ok := r.header[column]
+	if !ok || i >= len(r.Fields) {
+		return ""
+	}
+	return r.Fields[i]
+}
+
+// Has reports whether the file has the column in input.
+func (r Row) Has(column string) bool {
+	_, ok := r.header[column]
+	return ok
+}
+
+// Mapper maps a row onto a value.
+type Mapper[T any] func(r Row) (T, error)
+
+// RowError is yielded for the rows which could not be parsed, mapped or validated.
+// Iteration can go on after a RowError, skipping the row.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Progress reports how far the stream has been read.
+type Progress struct {
+	Rows  int64
+	Bytes int64
+}
+
+// Option customises a stream.
+type Option func(*config)
+
+type config struct {
+	comma      rune
+	comment    rune
+	noHeader   bool
+	required   []string
+	validate   bool
+	lazyQuotes bool
+	progress   func(Progress)
+	every      int64
+}
+
+// WithComma sets the field delimiter, ',' by default.
+func WithComma(r rune) Option {
+	return func(c *config) {
+		c.comma = r
+	}
+}
+
+// WithComment skips the lines starting with the rune in input.
+func WithComment(r rune) Option {
+	return func(c *config) {
+		c.comment = r
+	}
+}
+
+// WithLazyQuotes tolerates quotes appearing in unquoted fields and non doubled quotes in quoted fields.
+func WithLazyQuotes() Option {
+	return func(c *config) {
+		c.lazyQuotes = true
+	}
+}
+
+// WithoutHeader treats the first line as a record rather than as the header. Row.Get always returns "".
+func WithoutHeader() Option {
+	return func(c *config) {
+		c.noHeader = true
+	}
+}
+
+// WithRequiredColumns fails the stream before yielding any row if the header lacks any of the columns in input.
+func WithRequiredColumns(columns ...string) Option {
+	return func(c *config) {
+		c.required = append(c.required, columns...)
+	}
+}
+
+// WithValidation validates every mapped value with validate.Struct, yielding a RowError for the invalid ones.
+func WithValidation() Option {
+	return func(c *config) {
+		c.validate = true
+	}
+}
+
+// WithProgress calls fn every n rows, and once more at the end of the stream.
+func WithProgress(n int, fn func(Progress)) Option {
+	return func(c *config) {
+		c.every = int64(n)
+		c.progress = fn
+	}
+}
+
+// Stream returns an iterator over the rows of the CSV read from r, mapped with the mapper in input.
+// Rows are read one at a time, so memory use does not depend on the size of the input.
+// Rows which cannot be parsed, mapped or validated are yielded as *RowError and can be skipped;
+// any other error, e.g. a read error or missing columns, is yielded last.
+func Stream[T any](r io.Reader, mapper Mapper[T], opts ...Option) iter.Seq2[T, error] {
+	cfg := &config{comma: ','}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(yield func(T, error) bool) {
+		var zero T
+		cr := streamio.NewCountingReader(r)
+		reader := csv.NewReader(cr)
+		reader.Comma = cfg.comma
+		reader.Comment = cfg.comment
+		reader.LazyQuotes = cfg.lazyQuotes
+		reader.ReuseRecord = true
+		reader.FieldsPerRecord = -1
+
+		header := make(map[string]int)
+		if !cfg.noHeader {
+			record, err := reader.Read()
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield(zero, fmt.Errorf("could not read header: %w", err))
+				return
+			}
+			for i, name := range record {
+				header[strings.TrimSpace(strings.TrimPrefix(name, bom))] = i
+			}
+			var missing []string
+			for _, name := range cfg.required {
+				if _, ok := header[name]; !ok {
+					missing = append(missing, name)
+				}
+			}
+			if len(missing) > 0 {
+				yield(zero, fmt.Errorf("%w: %s", ErrMissingColumns, strings.Join(missing, ", ")))
+				return
+			}
+		}
+
+		var rows int64
+		report := func() {
+			if cfg.progress != nil {
+				cfg.progress(Progress{Rows: rows, Bytes: cr.N()})
+			}
+		}
+		defer report()
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				if !yield(zero, &RowError{Line: pe.StartLine, Err: pe.Err}) {
+					return
+				}
+				continue
+			}
+			if err != nil {
+				yield(zero, fmt.Errorf("could not read CSV: %w", err))
+				return
+			}
+
+			line, _ := reader.FieldPos(0)
+			rows++
+			if cfg.every > 0 && rows%cfg.every == 0 {
+				report()
+			}
+			v, err := mapper(Row{Line: line, Fields: record, header: header})
+			if err == nil && cfg.validate {
+				err = validate.Struct(v)
+			}
+			if err != nil {
+				if !yield(zero, &RowError{Line: line, Err: err}) {
+					return
+				}
+				continue
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
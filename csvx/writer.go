@@ -0,0 +1,59 @@
+package csvx
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// Writer writes values as CSV records, mapping them with a function.
+type Writer[T any] struct {
+	w      *csv.Writer
+	mapper func(T) ([]string, error)
+	header []string
+	wrote  bool
+}
+
+// NewWriter returns a Writer writing to w the values mapped with the function in input, preceded by the header
+// in input, if any. Records are buffered: Flush must be called once done.
+func NewWriter[T any](w io.Writer, header []string, mapper func(T) ([]string, error), opts ...Option) *Writer[T] {
+	cfg := &config{comma: ','}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = cfg.comma
+	return &Writer[T]{w: cw, mapper: mapper, header: header}
+}
+
+// Write writes the value as a record, writing the header first if this is the first record.
+func (w *Writer[T]) Write(v T) error {
+	if !w.wrote {
+		w.wrote = true
+		if len(w.header) > 0 {
+			if err := w.w.Write(w.header); err != nil {
+				return fmt.Errorf("could not write header: %w", err)
+			}
+		}
+	}
+	record, err := w.mapper(v)
+	if err != nil {
+		return fmt.Errorf("could not map record: %w", err)
+	}
+	if err := w.w.Write(record); err != nil {
+		return fmt.Errorf("could not write record: %w", err)
+	}
+	return nil
+}
+
+// Flush writes the buffered records to the underlying writer, returning any error occurred while writing.
+func (w *Writer[T]) Flush() error {
+	if !w.wrote && len(w.header) > 0 {
+		w.wrote = true
+		if err := w.w.Write(w.header); err != nil {
+			return fmt.Errorf("could not write header: %w", err)
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
@@ -0,0 +1,204 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/indiependente/pkg/healthcheck"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/retry"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 25
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+	defaultConnectTimeout  = 30 * time.Second
+)
+
+// Option customises the database handle returned by Open.
+type Option func(*config)
+
+type config struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	connMaxIdleTime time.Duration
+	connectTimeout  time.Duration
+	retryOptions    []retry.Option
+	checker         *healthcheck.Checker
+	checkName       string
+	registerer      prometheus.Registerer
+	metricsName     string
+	log             logger.Logger
+	slowThreshold   time.Duration
+}
+
+// WithMaxOpenConns sets the maximum number of open connections. Defaults to 25.
+func WithMaxOpenConns(n int) Option {
+	return func(c *config) {
+		c.maxOpenConns = n
+	}
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections. Defaults to 25.
+func WithMaxIdleConns(n int) Option {
+	return func(c *config) {
+		c.maxIdleConns = n
+	}
+}
+
+// WithConnMaxLifetime closes connections older than d. Defaults to 30m.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(c *config) {
+		c.connMaxLifetime = d
+	}
+}
+
+// WithConnMaxIdleTime closes connections idle for longer than d. Defaults to 5m.
+func WithConnMaxIdleTime(d time.Duration) Option {
+	return func(c *config) {
+		c.connMaxIdleTime = d
+	}
+}
+
+// WithConnectTimeout gives up connecting after d. Defaults to 30s.
+func WithConnectTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.connectTimeout = d
+	}
+}
+
+// WithConnectRetry customises how the initial connection is retried.
+func WithConnectRetry(opts ...retry.Option) Option {
+	return func(c *config) {
+		c.retryOptions = append(c.retryOptions, opts...)
+	}
+}
+
+// WithHealthCheck registers a check pinging the database on the checker in input under the name in input.
+func WithHealthCheck(checker *healthcheck.Checker, name string) Option {
+	return func(c *config) {
+		c.checker = checker
+		c.checkName = name
+	}
+}
+
+// WithMetrics registers the connection pool metrics, labeled by name, on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer, name string) Option {
+	return func(c *config) {
+		c.registerer = registerer
+		c.metricsName = name
+	}
+}
+
+// WithSlowQueryLog logs at warn level the queries taking longer than threshold, and failing queries at error level.
+func WithSlowQueryLog(log logger.Logger, threshold time.Duration) Option {
+	return func(c *config) {
+		c.log = log
+		c.slowThreshold = threshold
+	}
+}
+
+// DB is a database handle.
+type DB struct {
+	*sql.DB
+}
+
+// Open opens a database handle for the registered driver and DSN in input and waits until the database is reachable,
+// retrying with backoff until the connect timeout.
+func Open(ctx context.Context, driverName, dsn string, opts ...Option) (*DB, error) {
+	cfg := &config{
+		maxOpenConns:    defaultMaxOpenConns,
+		maxIdleConns:    defaultMaxIdleConns,
+		connMaxLifetime: defaultConnMaxLifetime,
+		connMaxIdleTime: defaultConnMaxIdleTime,
+		connectTimeout:  defaultConnectTimeout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	connector, err := newConnector(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.log != nil {
+		connector = &loggingConnector{Connector: connector, log: cfg.log, threshold: cfg.slowThreshold}
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(cfg.maxOpenConns)
+	db.SetMaxIdleConns(cfg.maxIdleConns)
+	db.SetConnMaxLifetime(cfg.connMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.connMaxIdleTime)
+
+	connectCtx, cancel := context.WithTimeout(ctx, cfg.connectTimeout)
+	defer cancel()
+	retryOpts := append([]retry.Option{retry.WithMaxAttempts(0), retry.WithBackoff(100*time.Millisecond, 5*time.Second, 2)}, cfg.retryOptions...)
+	if err := retry.Do(connectCtx, db.PingContext, retryOpts...); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	if cfg.checker != nil {
+		cfg.checker.Register(cfg.checkName, db.PingContext)
+	}
+	if cfg.registerer != nil {
+		if err := cfg.registerer.Register(collectors.NewDBStatsCollector(db, cfg.metricsName)); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("could not register metrics: %w", err)
+		}
+	}
+	return &DB{DB: db}, nil
+}
+
+// newConnector returns a connector for the registered driver and DSN in input.
+func newConnector(driverName, dsn string) (driver.Connector, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+	drv := db.Driver()
+	_ = db.Close()
+	if dc, ok := drv.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("could not open connector: %w", err)
+		}
+		return connector, nil
+	}
+	return &dsnConnector{dsn: dsn, driver: drv}, nil
+}
+
+// TerminationFn returns a shutdown.TerminationFn closing the database handle.
+func (db *DB) TerminationFn() shutdown.TerminationFn {
+	return func(context.Context) error {
+		if err := db.Close(); err != nil {
+			return fmt.Errorf("could not close database: %w", err)
+		}
+		return nil
+	}
+}
+
+// InTx runs fn in a transaction, committing it if fn returns nil and rolling it back otherwise.
+func (db *DB) InTx(ctx context.Context, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
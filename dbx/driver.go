@@ -0,0 +1,206 @@
+package dbx
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+)
+
+const queryEvent = "db_query"
+
+// dsnConnector is a driver.Connector for drivers not implementing driver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// loggingConnector wraps the connections of a driver.Connector to log slow and failing queries.
+type loggingConnector struct {
+	driver.Connector
+	log       logger.Logger
+	threshold time.Duration
+}
+
+func (c *loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &loggingConn{Conn: conn, lc: c}, nil
+}
+
+// observe logs the query if it failed or took longer than the threshold.
+func (c *loggingConnector) observe(ctx context.Context, query string, start time.Time, err error) {
+	d := time.Since(start)
+	if err != nil && !errors.Is(err, driver.ErrSkip) && !errors.Is(err, driver.ErrBadConn) && ctx.Err() == nil {
+		logger.WithTrace(ctx, c.log.Event(queryEvent).Query(query).Duration(d)).Error("Query failed", err)
+		return
+	}
+	if err == nil && d >= c.threshold {
+		logger.WithTrace(ctx, c.log.Event(queryEvent).Query(query).Duration(d)).Warn("Slow query")
+	}
+}
+
+// loggingConn wraps a driver.Conn, forwarding the optional interfaces it implements.
+type loggingConn struct {
+	driver.Conn
+	lc *loggingConnector
+}
+
+// compile time interface check.
+var (
+	_ driver.ExecerContext      = &loggingConn{}
+	_ driver.QueryerContext     = &loggingConn{}
+	_ driver.ConnPrepareContext = &loggingConn{}
+	_ driver.ConnBeginTx        = &loggingConn{}
+	_ driver.Pinger             = &loggingConn{}
+	_ driver.SessionResetter    = &loggingConn{}
+	_ driver.Validator          = &loggingConn{}
+	_ driver.NamedValueChecker  = &loggingConn{}
+)
+
+func (c *loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := execer.ExecContext(ctx, query, args)
+	c.lc.observe(ctx, query, start, err)
+	return res, err
+}
+
+func (c *loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.lc.observe(ctx, query, start, err)
+	return rows, err
+}
+
+func (c *loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &loggingStmt{Stmt: stmt, query: query, lc: c.lc}, nil
+}
+
+func (c *loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.ReadOnly || opts.Isolation != 0 {
+		return nil, errors.New("driver does not support transaction options")
+	}
+	return c.Conn.Begin() // nolint:staticcheck
+}
+
+func (c *loggingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *loggingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *loggingConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *loggingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// loggingStmt wraps a prepared driver.Stmt.
+type loggingStmt struct {
+	driver.Stmt
+	query string
+	lc    *loggingConnector
+}
+
+// compile time interface check.
+var (
+	_ driver.StmtExecContext   = &loggingStmt{}
+	_ driver.StmtQueryContext  = &loggingStmt{}
+	_ driver.NamedValueChecker = &loggingStmt{}
+)
+
+func (s *loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(values(args)) // nolint:staticcheck
+	}
+	s.lc.observe(ctx, s.query, start, err)
+	return res, err
+}
+
+func (s *loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args)) // nolint:staticcheck
+	}
+	s.lc.observe(ctx, s.query, start, err)
+	return rows, err
+}
+
+func (s *loggingStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vs := make([]driver.Value, len(args))
+	for i, a := range args {
+		vs[i] = a.Value
+	}
+	return vs
+}
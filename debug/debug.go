@@ -0,0 +1,107 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rdebug "runtime/debug"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/indiependente/pkg/http/server"
+	"github.com/indiependente/pkg/logger"
+)
+
+// Handler returns a mux serving the diagnostics endpoints:
+//
+//	/debug/pprof/     the pprof profiles
+//	/debug/vars       the expvar variables
+//	/debug/goroutines the stack traces of every goroutine
+//	/debug/runtime    the goroutine, GC and heap statistics as JSON
+//	/debug/loglevel   the logger level, see logger.LevelHandler
+//
+// The endpoints expose internals of the process and must never be served on the main listener.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", goroutines)
+	mux.HandleFunc("/debug/runtime", runtimeStats)
+	mux.Handle("/debug/loglevel", logger.LevelHandler())
+	return mux
+}
+
+// Serve serves Handler on addr until the context is done, then shuts the server down gracefully.
+// Writes never time out, so that CPU profiles and traces can be collected for as long as requested.
+// It is meant to be run in its own goroutine, e.g. alongside shutdown.Wait.
+func Serve(ctx context.Context, addr string, opts ...server.Option) error {
+	opts = append([]server.Option{server.WithAddr(addr), server.WithWriteTimeout(0)}, opts...)
+	return server.New(Handler(), opts...).Run(ctx)
+}
+
+func goroutines(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// Stats are the runtime statistics served by /debug/runtime.
+type Stats struct {
+	GoVersion  string `json:"go_version"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	NumCPU     int    `json:"num_cpu"`
+	Goroutines int    `json:"goroutines"`
+	Heap       struct {
+		Alloc    uint64 `json:"alloc_bytes"`
+		Sys      uint64 `json:"sys_bytes"`
+		Idle     uint64 `json:"idle_bytes"`
+		Released uint64 `json:"released_bytes"`
+		Objects  uint64 `json:"objects"`
+	} `json:"heap"`
+	GC struct {
+		Count      uint32        `json:"count"`
+		Forced     uint32        `json:"forced"`
+		Last       time.Time     `json:"last,omitzero"`
+		PauseTotal time.Duration `json:"pause_total_ns"`
+		NextTarget uint64        `json:"next_target_bytes"`
+		MemLimit   int64         `json:"memory_limit_bytes"`
+	} `json:"gc"`
+}
+
+// ReadStats returns the current runtime statistics. It stops the world briefly to read the memory statistics.
+func ReadStats() Stats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	var s Stats
+	s.GoVersion = runtime.Version()
+	s.GOMAXPROCS = runtime.GOMAXPROCS(0)
+	s.NumCPU = runtime.NumCPU()
+	s.Goroutines = runtime.NumGoroutine()
+	s.Heap.Alloc = ms.HeapAlloc
+	s.Heap.Sys = ms.HeapSys
+	s.Heap.Idle = ms.HeapIdle
+	s.Heap.Released = ms.HeapReleased
+	s.Heap.Objects = ms.HeapObjects
+	s.GC.Count = ms.NumGC
+	s.GC.Forced = ms.NumForcedGC
+	if ms.LastGC > 0 {
+		s.GC.Last = time.Unix(0, int64(ms.LastGC)) // nolint:gosec
+	}
+	s.GC.PauseTotal = time.Duration(ms.PauseTotalNs) // nolint:gosec
+	s.GC.NextTarget = ms.NextGC
+	// a negative limit reads the current one without changing it
+	s.GC.MemLimit = rdebug.SetMemoryLimit(-1)
+	return s
+}
+
+func runtimeStats(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ReadStats())
+}
@@ -0,0 +1,187 @@
+package dedupe
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option customises a Group.
+type Option func(*config)
+
+type config struct {
+	ttl        time.Duration
+	clock      clock.Clock
+	registerer prometheus.Registerer
+	name       string
+}
+
+// WithTTL keeps successful results for ttl, returning them to the calls made in the meantime
+// without calling the function again. Errors are never kept.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		c.ttl = ttl
+	}
+}
+
+// WithClock tells the time with the clock in input, e.g. a clock.Fake to test expiration.
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+// WithMetrics counts the calls of the group, labeled by name and result (leader, shared or cached),
+// on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer, name string) Option {
+	return func(c *config) {
+		c.registerer = registerer
+		c.name = name
+	}
+}
+
+// call is a call in progress or completed.
+type call[V any] struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	value   V
+	err     error
+	expires time.Time
+}
+
+// Group coalesces concurrent calls with the same key into a single execution whose result is shared.
+// The zero value is not usable, use New.
+type Group[K comparable, V any] struct {
+	cfg *config
+
+	mu    sync.Mutex
+	calls map[K]*call[V]
+	done  map[K]*call[V]
+
+	leader, shared, cached prometheus.Counter
+}
+
+// New returns a Group.
+func New[K comparable, V any](opts ...Option) *Group[K, V] {
+	cfg := &config{clock: clock.New()}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	g := &Group[K, V]{
+		cfg:   cfg,
+		calls: make(map[K]*call[V]),
+		done:  make(map[K]*call[V]),
+	}
+	if cfg.registerer != nil {
+		calls := metrics.Register(cfg.registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dedupe_calls_total",
+			Help: "Number of deduplicated calls by result.",
+		}, []string{"group", "result"}))
+		g.leader = calls.WithLabelValues(cfg.name, "leader")
+		g.shared = calls.WithLabelValues(cfg.name, "shared")
+		g.cached = calls.WithLabelValues(cfg.name, "cached")
+	}
+	return g
+}
+
+// DoValue calls fn, unless a call with the same key is in progress or its result is still kept,
+// in which case it returns that result instead.
+//
+// The function runs with a context carrying the values of the first caller's one but detached from its
+// cancellation: a caller whose context is done returns its error right away, while the call keeps going for
+// the others. The call's context is cancelled only once every caller waiting for it has gone.
+// Panics in fn are returned to every caller as *conc.PanicError values.
+func (g *Group[K, V]) DoValue(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	v, _, err := g.Do(ctx, key, fn)
+	return v, err
+}
+
+// Do behaves as DoValue, also reporting whether the result was shared with, or kept from, another call.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, bool, error) {
+	g.mu.Lock()
+	if c, ok := g.done[key]; ok {
+		if g.cfg.clock.Now().Before(c.expires) {
+			g.mu.Unlock()
+			inc(g.cached)
+			return c.value, true, nil
+		}
+		delete(g.done, key)
+	}
+	c, ok := g.calls[key]
+	if ok {
+		c.waiters++
+		g.mu.Unlock()
+		inc(g.shared)
+		return g.wait(ctx, key, c, true)
+	}
+	callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c = &call[V]{done: make(chan struct{}), cancel: cancel, waiters: 1}
+	g.calls[key] = c
+	g.mu.Unlock()
+	inc(g.leader)
+
+	go g.run(callCtx, key, c, fn)
+	return g.wait(ctx, key, c, false)
+}
+
+// Forget drops the kept result of key, if any, so that the next call runs the function again.
+// A call in progress completes for its callers, but later calls do not join it and its result is not kept.
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.done, key)
+	delete(g.calls, key)
+}
+
+func (g *Group[K, V]) run(ctx context.Context, key K, c *call[V], fn func(ctx context.Context) (V, error)) {
+	err := conc.Catch(func() error {
+		var err error
+		c.value, err = fn(ctx)
+		return err
+	})
+	c.err = err
+	c.cancel()
+
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+		if err == nil && g.cfg.ttl > 0 {
+			c.expires = g.cfg.clock.Now().Add(g.cfg.ttl)
+			g.done[key] = c
+		}
+	}
+	g.mu.Unlock()
+	close(c.done)
+}
+
+// wait waits for the call to complete or for ctx to be done, cancelling the call if no caller is left.
+func (g *Group[K, V]) wait(ctx context.Context, key K, c *call[V], shared bool) (V, bool, error) {
+	select {
+	case <-c.done:
+		return c.value, shared, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		var zero V
+		return zero, shared, ctx.Err()
+	}
+}
+
+func inc(c prometheus.Counter) {
+	if c != nil {
+		c.Inc()
+	}
+}
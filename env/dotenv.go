@@ -0,0 +1,78 @@
+package env
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadDotEnv sets the variables defined in the .env file of the working directory, if it exists.
+// It is meant for local development; variables already set in the environment are left untouched.
+func LoadDotEnv() error {
+	err := LoadFile(".env")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// LoadFile sets the variables defined in the file in input which are not already set in the environment.
+// Lines are in the KEY=VALUE form, optionally preceded by export; blank lines and lines starting with # are ignored.
+// Values may be single quoted, taken literally, or double quoted, expanding \n, \t, \" and \\.
+func LoadFile(path string) error {
+	f, err := os.Open(path) // nolint:gosec
+	if err != nil {
+		return fmt.Errorf("could not open env file: %w", err)
+	}
+	defer f.Close()
+
+	vars, err := parseDotEnv(bufio.NewScanner(f))
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	for k, v := range vars {
+		if _, ok := os.LookupEnv(k); ok {
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return fmt.Errorf("could not set %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func parseDotEnv(sc *bufio.Scanner) (map[string]string, error) {
+	vars := make(map[string]string)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = unquoted
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars[key] = value
+	}
+	return vars, sc.Err()
+}
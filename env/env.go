@@ -0,0 +1,152 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Value lists the types which can be read from the environment.
+// Slices are comma separated, durations use the time.ParseDuration format.
+type Value interface {
+	string | int | int64 | uint | uint64 | float64 | bool | time.Duration | *url.URL | []string
+}
+
+// Env reads variables sharing a prefix, collecting the errors of missing or malformed ones
+// so that they can be reported all at once.
+type Env struct {
+	prefix string
+	lookup func(string) (string, bool)
+
+	mu   *sync.Mutex
+	errs *[]error
+}
+
+// New returns an Env reading the variables named PREFIX_KEY, or KEY if the prefix is empty.
+func New(prefix string) *Env {
+	return &Env{
+		prefix: prefix,
+		lookup: os.LookupEnv,
+		mu:     &sync.Mutex{},
+		errs:   &[]error{},
+	}
+}
+
+// Scope returns an Env reading the variables nested under the prefix in input, e.g. APP_DB_ for Scope("DB")
+// of an Env with prefix APP. Its errors are collected along with the ones of the parent.
+func (e *Env) Scope(prefix string) *Env {
+	child := *e
+	child.prefix = e.name(prefix)
+	return &child
+}
+
+// Err returns the errors collected so far joined, or nil.
+func (e *Env) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return errors.Join(*e.errs...)
+}
+
+func (e *Env) name(key string) string {
+	if e.prefix == "" {
+		return key
+	}
+	return e.prefix + "_" + key
+}
+
+func (e *Env) fail(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	*e.errs = append(*e.errs, err)
+}
+
+// From returns the value of the variable key of the Env, or def if it is not set.
+// Malformed values are recorded in the errors of the Env, and def is returned.
+func From[T Value](e *Env, key string, def T) T {
+	name := e.name(key)
+	raw, ok := e.lookup(name)
+	if !ok {
+		return def
+	}
+	v, err := parse[T](raw)
+	if err != nil {
+		e.fail(fmt.Errorf("invalid value of %s: %w", name, err))
+		return def
+	}
+	return v
+}
+
+// Required returns the value of the variable key of the Env, recording an error if it is not set or malformed.
+func Required[T Value](e *Env, key string) T {
+	var zero T
+	name := e.name(key)
+	if _, ok := e.lookup(name); !ok {
+		e.fail(fmt.Errorf("missing required variable %s", name))
+		return zero
+	}
+	return From(e, key, zero)
+}
+
+// Get returns the value of the variable key, or def if it is not set or malformed.
+func Get[T Value](key string, def T) T {
+	return From(New(""), key, def)
+}
+
+// Load calls fn with an Env with the prefix in input, returning the errors it collected.
+func Load(prefix string, fn func(e *Env)) error {
+	e := New(prefix)
+	fn(e)
+	if err := e.Err(); err != nil {
+		return fmt.Errorf("could not load environment: %w", err)
+	}
+	return nil
+}
+
+// MustLoad behaves as Load, panicking with all the collected errors. It is meant to be called at startup.
+func MustLoad(prefix string, fn func(e *Env)) {
+	if err := Load(prefix, fn); err != nil {
+		panic(err)
+	}
+}
+
+func parse[T Value](raw string) (T, error) {
+	var v T
+	var err error
+	switch p := any(&v).(type) {
+	case *string:
+		*p = raw
+	case *int:
+		*p, err = strconv.Atoi(raw)
+	case *int64:
+		*p, err = strconv.ParseInt(raw, 0, 64)
+	case *uint:
+		var n uint64
+		n, err = strconv.ParseUint(raw, 0, strconv.IntSize)
+		*p = uint(n)
+	case *uint64:
+		*p, err = strconv.ParseUint(raw, 0, 64)
+	case *float64:
+		*p, err = strconv.ParseFloat(raw, 64)
+	case *bool:
+		*p, err = strconv.ParseBool(raw)
+	case *time.Duration:
+		*p, err = time.ParseDuration(raw)
+	case **url.URL:
+		*p, err = url.Parse(raw)
+	case *[]string:
+		if raw == "" {
+			return v, nil
+		}
+		parts := strings.Split(raw, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		*p = parts
+	}
+	return v, err
+}
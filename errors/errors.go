@@ -0,0 +1,261 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stackDepth is the maximum number of frames captured by New and Wrap.
+const stackDepth = 32
+
+// Code is a machine readable error category.
+type Code string
+
+const (
+	// Unknown is the code of errors without a code.
+	Unknown Code = "unknown"
+	// Invalid means the input is not valid.
+	Invalid Code = "invalid"
+	// NotFound means the requested entity does not exist.
+	NotFound Code = "not_found"
+	// AlreadyExists means the entity to create already exists.
+	AlreadyExists Code = "already_exists"
+	// Conflict means the operation conflicts with the current state of the entity.
+	Conflict Code = "conflict"
+	// Unauthorized means the caller is not authenticated.
+	Unauthorized Code = "unauthorized"
+	// Forbidden means the caller is not allowed to perform the operation.
+	Forbidden Code = "forbidden"
+	// RateLimited means the caller exhausted its quota.
+	RateLimited Code = "rate_limited"
+	// Canceled means the operation was cancelled by the caller.
+	Canceled Code = "canceled"
+	// Timeout means the operation did not complete in time.
+	Timeout Code = "timeout"
+	// Unavailable means a dependency is temporarily unavailable and the operation can be retried.
+	Unavailable Code = "unavailable"
+	// Unimplemented means the operation is not supported.
+	Unimplemented Code = "unimplemented"
+	// Internal means an unexpected failure.
+	Internal Code = "internal"
+)
+
+// Error is an error carrying a code, key-value metadata and the stack trace of where it was created.
+type Error struct {
+	code   Code
+	msg    string
+	cause  error
+	fields map[string]interface{}
+	stack  []uintptr
+}
+
+// New returns an error with the code and message in input.
+// The optional key-value pairs are attached as metadata; keys must be strings.
+func New(code Code, msg string, kv ...interface{}) error {
+	return newError(code, msg, nil, kv)
+}
+
+// Wrap returns an error with the code and message in input, wrapping err. It returns nil if err is nil.
+func Wrap(err error, code Code, msg string, kv ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return newError(code, msg, err, kv)
+}
+
+func newError(code Code, msg string, cause error, kv []interface{}) *Error {
+	e := &Error{code: code, msg: msg, cause: cause}
+	if len(kv) > 1 {
+		e.fields = make(map[string]interface{}, len(kv)/2)
+		for i := 0; i+1 < len(kv); i += 2 {
+			e.fields[fmt.Sprint(kv[i])] = kv[i+1]
+		}
+	}
+	pcs := make([]uintptr, stackDepth)
+	e.stack = pcs[:runtime.Callers(3, pcs)]
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return e.msg
+	}
+	if e.msg == "" {
+		return e.cause.Error()
+	}
+	return e.msg + ": " + e.cause.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() Code {
+	return e.code
+}
+
+// ErrorCode returns the code of the error as a string, for loggers which do not import this package.
+func (e *Error) ErrorCode() string {
+	return string(e.code)
+}
+
+// Fields returns the metadata of the error and of the errors it wraps; outer values win.
+func (e *Error) Fields() map[string]interface{} {
+	fields := make(map[string]interface{})
+	var chain []*Error
+	for err := error(e); err != nil; err = stderrors.Unwrap(err) {
+		if ce, ok := err.(*Error); ok {
+			chain = append(chain, ce)
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, v := range chain[i].fields {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// StackTrace returns the stack trace captured when the error was created, one frame per line.
+func (e *Error) StackTrace() string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// StatusCode returns the HTTP status code matching the error code, for the respond package.
+func (e *Error) StatusCode() int {
+	return httpStatus(e.code)
+}
+
+// CodeOf returns the code of the first error in the chain of err which is an Error or has an ErrorCode method.
+// Context errors map to Canceled and Timeout, nil to an empty code, and anything else to Unknown.
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var coder interface{ ErrorCode() string }
+	if stderrors.As(err, &coder) {
+		return Code(coder.ErrorCode())
+	}
+	switch {
+	case stderrors.Is(err, context.Canceled):
+		return Canceled
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	}
+	return Unknown
+}
+
+// IsCode reports whether the code of err is the code in input.
+func IsCode(err error, code Code) bool {
+	return CodeOf(err) == code
+}
+
+// HTTPStatus returns the HTTP status code matching the code of err, 200 if err is nil.
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	return httpStatus(CodeOf(err))
+}
+
+func httpStatus(code Code) int {
+	switch code {
+	case Invalid:
+		return http.StatusBadRequest
+	case NotFound:
+		return http.StatusNotFound
+	case AlreadyExists, Conflict:
+		return http.StatusConflict
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case RateLimited:
+		return http.StatusTooManyRequests
+	case Canceled:
+		return 499 // client closed request
+	case Timeout:
+		return http.StatusGatewayTimeout
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case Unimplemented:
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCStatus returns the gRPC status matching the code of err, carrying its message.
+func GRPCStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	return status.New(grpcCode(CodeOf(err)), err.Error())
+}
+
+func grpcCode(code Code) codes.Code {
+	switch code {
+	case Invalid:
+		return codes.InvalidArgument
+	case NotFound:
+		return codes.NotFound
+	case AlreadyExists:
+		return codes.AlreadyExists
+	case Conflict:
+		return codes.Aborted
+	case Unauthorized:
+		return codes.Unauthenticated
+	case Forbidden:
+		return codes.PermissionDenied
+	case RateLimited:
+		return codes.ResourceExhausted
+	case Canceled:
+		return codes.Canceled
+	case Timeout:
+		return codes.DeadlineExceeded
+	case Unavailable:
+		return codes.Unavailable
+	case Unimplemented:
+		return codes.Unimplemented
+	case Internal:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// Is reports whether any error in the chain of err matches target, as the standard library errors.Is.
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// As finds the first error in the chain of err matching target, as the standard library errors.As.
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
+// Unwrap returns the error wrapped by err, as the standard library errors.Unwrap.
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
+}
+
+// Join returns an error wrapping the errors in input, as the standard library errors.Join.
+func Join(errs ...error) error {
+	return stderrors.Join(errs...)
+}
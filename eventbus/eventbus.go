@@ -0,0 +1,341 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// busEvent is the event of the log lines written by the bus.
+const busEvent = "eventbus"
+
+var (
+	// ErrClosed is returned when publishing to a closed bus.
+	ErrClosed = errors.New("eventbus: closed")
+	// ErrPanic is wrapped by the errors of handlers which panicked, a *conc.PanicError carrying the stack trace.
+	ErrPanic = conc.ErrPanic
+)
+
+// Handler handles an event of type T.
+type Handler[T any] func(ctx context.Context, event T) error
+
+// Overflow defines what happens when publishing to an asynchronous subscriber whose queue is full.
+type Overflow int
+
+const (
+	// Block makes Publish wait for a free slot, or for its context to be done.
+	Block Overflow = iota
+	// DropNewest drops the event being published.
+	DropNewest
+	// DropOldest drops the oldest queued event to make room for the one being published.
+	DropOldest
+)
+
+// Option customises a Bus.
+type Option func(*Bus)
+
+// WithLogger logs the failures of asynchronous handlers and the events dropped.
+func WithLogger(log logger.Logger) Option {
+	return func(b *Bus) {
+		b.log = log
+	}
+}
+
+// WithMetrics registers the published, handled and dropped events metrics, labeled by event type, with the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(b *Bus) {
+		b.published = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "eventbus_events_published_total",
+			Help: "Number of events published by type.",
+		}, []string{"event"}))
+		b.handled = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "eventbus_events_handled_total",
+			Help: "Number of events handled by type, subscriber and result.",
+		}, []string{"event", "subscriber", "result"}))
+		b.dropped = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "eventbus_events_dropped_total",
+			Help: "Number of events dropped by type and subscriber because of a full queue.",
+		}, []string{"event", "subscriber"}))
+		b.duration = metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "eventbus_handle_duration_seconds",
+			Help:    "Duration of event handling by type and subscriber.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"event", "subscriber"}))
+	}
+}
+
+// SubscribeOption customises a subscription.
+type SubscribeOption func(*subscriber)
+
+// Async delivers the events to the handler on its own goroutine, through a queue of size n,
+// so that Publish does not wait for the handler. Events are handled one at a time, in publishing order.
+func Async(n int) SubscribeOption {
+	return func(s *subscriber) {
+		if n < 1 {
+			n = 1
+		}
+		s.queue = make(chan delivery, n)
+	}
+}
+
+// WithOverflow sets what happens when the queue of an asynchronous subscriber is full, Block by default.
+func WithOverflow(o Overflow) SubscribeOption {
+	return func(s *subscriber) {
+		s.overflow = o
+	}
+}
+
+// WithName names the subscriber in logs and metrics, "anonymous" by default.
+func WithName(name string) SubscribeOption {
+	return func(s *subscriber) {
+		s.name = name
+	}
+}
+
+// Bus dispatches in-process events to the subscribers of their type.
+// Subscribers are synchronous by default: Publish calls them in turn and returns their errors.
+// Asynchronous subscribers, see Async, are called on their own goroutine and their errors are logged.
+// Panics of a handler are isolated from the publisher and from the other subscribers.
+type Bus struct {
+	log logger.Logger
+
+	mu     sync.RWMutex
+	subs   map[reflect.Type][]*subscriber
+	closed bool
+	wg     sync.WaitGroup
+
+	published *prometheus.CounterVec
+	handled   *prometheus.CounterVec
+	dropped   *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+}
+
+type delivery struct {
+	ctx   context.Context
+	event interface{}
+}
+
+type subscriber struct {
+	name     string
+	event    string
+	handle   func(ctx context.Context, event interface{}) error
+	queue    chan delivery
+	overflow Overflow
+	quit     chan struct{}
+	once     sync.Once
+}
+
+// New returns a Bus.
+func New(opts ...Option) *Bus {
+	b := &Bus{subs: make(map[reflect.Type][]*subscriber)}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Subscribe subscribes the handler to the events of type T, returning a function to unsubscribe it.
+// Unsubscribing an asynchronous handler lets it handle the events already queued.
+func Subscribe[T any](b *Bus, h Handler[T], opts ...SubscribeOption) (unsubscribe func()) {
+	typ := reflect.TypeFor[T]()
+	s := &subscriber{
+		name:  "anonymous",
+		event: typ.String(),
+		handle: func(ctx context.Context, event interface{}) error {
+			return h(ctx, event.(T))
+		},
+		quit: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return func() {}
+	}
+	b.subs[typ] = append(b.subs[typ], s)
+	if s.queue != nil {
+		b.wg.Add(1)
+		go b.work(s)
+	}
+	return func() {
+		b.mu.Lock()
+		subs := b.subs[typ]
+		for i, sub := range subs {
+			if sub == s {
+				b.subs[typ] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+		b.mu.Unlock()
+		s.stop()
+	}
+}
+
+// Publish dispatches the event to the subscribers of type T, returning the errors of the synchronous ones.
+// Asynchronous handlers are called with a context carrying the values of ctx but not its cancellation.
+func Publish[T any](ctx context.Context, b *Bus, event T) error {
+	typ := reflect.TypeFor[T]()
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	subs := b.subs[typ]
+	b.mu.RUnlock()
+	if b.published != nil {
+		b.published.WithLabelValues(typ.String()).Inc()
+	}
+
+	var errs []error
+	for _, s := range subs {
+		if s.queue == nil {
+			if err := b.call(ctx, s, event); err != nil {
+				errs = append(errs, fmt.Errorf("subscriber %s: %w", s.name, err))
+			}
+			continue
+		}
+		if err := b.enqueue(ctx, s, delivery{ctx: context.WithoutCancel(ctx), event: event}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("could not handle %s: %w", typ, err)
+	}
+	return nil
+}
+
+// Close stops accepting events and waits for the asynchronous subscribers to handle the queued ones, or for ctx to be done.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, subs := range b.subs {
+			for _, s := range subs {
+				s.stop()
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not drain event bus: %w", ctx.Err())
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn closing the bus within timeout.
+func (b *Bus) TerminationFn(timeout time.Duration) shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return b.Close(ctx)
+	}
+}
+
+// enqueue queues the delivery according to the overflow policy of the subscriber.
+func (b *Bus) enqueue(ctx context.Context, s *subscriber, d delivery) error {
+	switch s.overflow {
+	case DropNewest:
+		select {
+		case s.queue <- d:
+		default:
+			b.drop(s)
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.queue <- d:
+				return nil
+			default:
+			}
+			select {
+			case <-s.queue:
+				b.drop(s)
+			default:
+			}
+		}
+	default:
+		select {
+		case s.queue <- d:
+		case <-s.quit:
+		case <-ctx.Done():
+			return fmt.Errorf("subscriber %s: %w", s.name, ctx.Err())
+		}
+	}
+	return nil
+}
+
+func (b *Bus) drop(s *subscriber) {
+	if b.dropped != nil {
+		b.dropped.WithLabelValues(s.event, s.name).Inc()
+	}
+	if b.log != nil {
+		b.log.Event(busEvent).Topic(s.event).Component(s.name).Warn("Queue full, event dropped")
+	}
+}
+
+// work handles the events queued for an asynchronous subscriber until it is stopped and its queue is drained.
+func (b *Bus) work(s *subscriber) {
+	defer b.wg.Done()
+	for {
+		select {
+		case d := <-s.queue:
+			b.callAsync(s, d)
+		case <-s.quit:
+			for {
+				select {
+				case d := <-s.queue:
+					b.callAsync(s, d)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (b *Bus) callAsync(s *subscriber, d delivery) {
+	if err := b.call(d.ctx, s, d.event); err != nil && b.log != nil {
+		logger.WithTrace(d.ctx, b.log.Event(busEvent).Topic(s.event).Component(s.name)).Error("Could not handle event", err)
+	}
+}
+
+// call calls the handler, turning panics into errors wrapping ErrPanic.
+func (b *Bus) call(ctx context.Context, s *subscriber, event interface{}) error {
+	start := time.Now()
+	err := conc.Catch(func() error {
+		return s.handle(ctx, event)
+	})
+	if b.handled != nil {
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		b.handled.WithLabelValues(s.event, s.name, result).Inc()
+		b.duration.WithLabelValues(s.event, s.name).Observe(time.Since(start).Seconds())
+	}
+	return err
+}
+
+func (s *subscriber) stop() {
+	s.once.Do(func() { close(s.quit) })
+}
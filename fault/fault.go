@@ -0,0 +1,178 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/indiependente/pkg/featureflag"
+	"github.com/indiependente/pkg/logger"
+)
+
+const faultEvent = "fault"
+
+// ErrInjected is returned by the RoundTripper when a rule aborts a request.
+var ErrInjected = errors.New("injected fault")
+
+// Rule describes the faults injected into a percentage of the matching requests.
+type Rule struct {
+	// Method restricts the rule to requests with the method, any if empty.
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	// PathPrefix restricts the rule to requests whose path starts with it, any if empty.
+	PathPrefix string `json:"path_prefix,omitempty" yaml:"path_prefix,omitempty"`
+	// Host restricts the rule to requests to the host, any if empty. Only the RoundTripper sees outgoing hosts.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+	// Percentage is the share (0-100) of matching requests the rule applies to.
+	Percentage float64 `json:"percentage" yaml:"percentage"`
+	// Latency delays the request.
+	Latency time.Duration `json:"latency,omitempty" yaml:"latency,omitempty"`
+	// Status, when set, answers the request with the status code instead of handling it.
+	Status int `json:"status,omitempty" yaml:"status,omitempty"`
+	// Abort drops the request: the Middleware closes the connection and the RoundTripper fails with ErrInjected.
+	Abort bool `json:"abort,omitempty" yaml:"abort,omitempty"`
+}
+
+// matches reports whether the rule applies to the request, regardless of the percentage.
+func (r Rule) matches(req *http.Request) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	if r.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, r.PathPrefix) {
+		return false
+	}
+	if r.Host != "" && !strings.EqualFold(r.Host, hostOf(req)) {
+		return false
+	}
+	return true
+}
+
+// Config is the fault injection configuration, meant to be loaded with the config package and applied with Update.
+type Config struct {
+	// Enabled switches fault injection on.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Rules are evaluated in order: the first one matching a request, and drawn for it, applies.
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Option customises an Injector.
+type Option func(*Injector)
+
+// WithLogger logs every injected fault at debug level.
+func WithLogger(log logger.Logger) Option {
+	return func(i *Injector) {
+		i.log = log
+	}
+}
+
+// WithFlag injects faults only into requests for which the feature flag is on, so that injection can be switched
+// on and off at runtime, or rolled out to a percentage of users, through the flags provider.
+func WithFlag(provider featureflag.Provider, name string) Option {
+	return func(i *Injector) {
+		i.flags = provider
+		i.flag = name
+	}
+}
+
+// Injector decides which faults to inject into requests, according to rules which can be replaced at runtime.
+type Injector struct {
+	cfg   atomic.Pointer[Config]
+	log   logger.Logger
+	flags featureflag.Provider
+	flag  string
+	// draw returns a number in [0, 100), replaced in tests.
+	draw func() float64
+}
+
+// NewInjector returns an Injector applying the configuration in input.
+func NewInjector(cfg Config, opts ...Option) *Injector {
+	i := &Injector{
+		draw: func() float64 { return rand.Float64() * 100 }, // nolint:gosec
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.Update(cfg)
+	return i
+}
+
+// Update replaces the configuration, affecting the requests which start afterwards.
+func (i *Injector) Update(cfg Config) {
+	cfg.Rules = append([]Rule(nil), cfg.Rules...)
+	i.cfg.Store(&cfg)
+}
+
+// Config returns the current configuration.
+func (i *Injector) Config() Config {
+	return *i.cfg.Load()
+}
+
+// SetEnabled switches fault injection on or off, keeping the rules.
+func (i *Injector) SetEnabled(enabled bool) {
+	cfg := i.Config()
+	cfg.Enabled = enabled
+	i.Update(cfg)
+}
+
+// pick returns the rule to apply to the request, if any.
+func (i *Injector) pick(req *http.Request) (Rule, bool) {
+	cfg := i.cfg.Load()
+	if !cfg.Enabled {
+		return Rule{}, false
+	}
+	if i.flags != nil && !i.flags.BoolFlag(req.Context(), i.flag, false) {
+		return Rule{}, false
+	}
+	for _, r := range cfg.Rules {
+		if r.matches(req) && i.draw() < r.Percentage {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// logInjected logs the fault injected into the request.
+func (i *Injector) logInjected(req *http.Request, r Rule) {
+	if i.log == nil {
+		return
+	}
+	l := i.log.Event(faultEvent).Method(req.Method).URI(req.URL.String()).Duration(r.Latency)
+	if r.Status != 0 {
+		l = l.StatusCode(r.Status)
+	}
+	l = logger.WithTrace(req.Context(), l)
+	if r.Abort {
+		l.Debug("Injected fault: abort")
+		return
+	}
+	l.Debug("Injected fault")
+}
+
+// sleep waits for the latency of the rule, returning the context error if it is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func hostOf(req *http.Request) string {
+	if req.URL.Host != "" {
+		return req.URL.Hostname()
+	}
+	host := req.Host
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	return host
+}
@@ -0,0 +1,80 @@
+package fault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/indiependente/pkg/http/client"
+	"github.com/indiependente/pkg/httpx/respond"
+)
+
+// Middleware injects faults into the requests served by the next handler: it delays them, answers them
+// with a problem+json response carrying the rule status code, or aborts them closing the connection.
+func Middleware(inj *Injector) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule, ok := inj.pick(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			inj.logInjected(r, rule)
+			if err := sleep(r.Context(), rule.Latency); err != nil {
+				return
+			}
+			switch {
+			case rule.Abort:
+				// aborting the handler closes the connection without a response
+				panic(http.ErrAbortHandler)
+			case rule.Status != 0:
+				respond.Error(w, respond.NewProblem(rule.Status, "injected fault"))
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// RoundTripper injects faults into the requests sent by next, http.DefaultTransport if nil: it delays them,
+// answers them with a synthetic problem+json response carrying the rule status code, or fails them with ErrInjected.
+// It can be added to the client package with client.WithMiddleware.
+func RoundTripper(inj *Injector, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return client.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		rule, ok := inj.pick(req)
+		if !ok {
+			return next.RoundTrip(req)
+		}
+		inj.logInjected(req, rule)
+		if err := sleep(req.Context(), rule.Latency); err != nil {
+			return nil, err
+		}
+		switch {
+		case rule.Abort:
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrInjected)
+		case rule.Status != 0:
+			if req.Body != nil {
+				_ = req.Body.Close()
+			}
+			body, _ := json.Marshal(respond.NewProblem(rule.Status, "injected fault"))
+			return &http.Response{
+				Status:        fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status)),
+				StatusCode:    rule.Status,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        http.Header{"Content-Type": {"application/problem+json"}},
+				Body:          io.NopCloser(bytes.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Request:       req,
+			}, nil
+		default:
+			return next.RoundTrip(req)
+		}
+	})
+}
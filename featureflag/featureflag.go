@@ -0,0 +1,139 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"sync"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/requestid"
+)
+
+// buckets is the number of rollout buckets, giving percentages a resolution of 0.01%.
+const buckets = 10000
+
+// Provider evaluates feature flags.
+type Provider interface {
+	// BoolFlag reports whether the flag is on for the context, returning def if the flag is unknown.
+	BoolFlag(ctx context.Context, name string, def bool) bool
+}
+
+// Flag defines a feature toggle.
+type Flag struct {
+	// Enabled switches the flag on.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Percentage, when set, limits an enabled flag to the given percentage (0-100) of rollout keys.
+	Percentage *float64 `json:"percentage,omitempty" yaml:"percentage,omitempty"`
+}
+
+type keyCtx struct{}
+
+// WithKey stores the rollout key, e.g. a user ID, in the context.
+// Percentage rollouts are keyed by it, falling back to the request ID.
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyCtx{}, key)
+}
+
+// keyFrom returns the rollout key stored in the context, or its request ID.
+func keyFrom(ctx context.Context) (string, bool) {
+	if key, ok := ctx.Value(keyCtx{}).(string); ok && key != "" {
+		return key, true
+	}
+	return requestid.FromContext(ctx)
+}
+
+// Evaluate reports whether the flag named name is on for the rollout key in the context.
+// The same key always falls in the same bucket of a flag, so raising the percentage only adds keys.
+// Without a key, partially rolled out flags are off.
+func (f Flag) Evaluate(ctx context.Context, name string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if f.Percentage == nil || *f.Percentage >= 100 {
+		return true
+	}
+	key, ok := keyFrom(ctx)
+	if !ok {
+		return false
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + ":" + key))
+	return float64(h.Sum32()%buckets) < *f.Percentage*buckets/100
+}
+
+// store holds the flags of a provider, notifying changes to the registered callbacks.
+type store struct {
+	log logger.Logger
+
+	mu        sync.RWMutex
+	flags     map[string]Flag
+	callbacks []func(name string, flag Flag, ok bool)
+}
+
+func (s *store) get(name string) (Flag, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.flags[name]
+	return f, ok
+}
+
+// Flags returns a copy of the flags served, e.g. to inspect them on an admin endpoint.
+func (s *store) Flags() map[string]Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.flags)
+}
+
+// set replaces the flags, calling the callbacks for every added, changed or removed flag.
+func (s *store) set(flags map[string]Flag) {
+	s.mu.Lock()
+	old := s.flags
+	s.flags = flags
+	callbacks := append([]func(string, Flag, bool){}, s.callbacks...)
+	s.mu.Unlock()
+
+	for name, f := range flags {
+		if prev, ok := old[name]; !ok || !equal(prev, f) {
+			for _, fn := range callbacks {
+				fn(name, f, true)
+			}
+		}
+	}
+	for name := range old {
+		if _, ok := flags[name]; !ok {
+			for _, fn := range callbacks {
+				fn(name, Flag{}, false)
+			}
+		}
+	}
+}
+
+// OnChange calls fn every time a flag is added, changed or removed; ok is false for removed flags.
+func (s *store) OnChange(fn func(name string, flag Flag, ok bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks = append(s.callbacks, fn)
+}
+
+// BoolFlag reports whether the flag is on for the context, returning def if the flag is unknown.
+func (s *store) BoolFlag(ctx context.Context, name string, def bool) bool {
+	f, ok := s.get(name)
+	v := def
+	if ok {
+		v = f.Evaluate(ctx, name)
+	}
+	if s.log != nil {
+		logger.WithTrace(ctx, s.log).Event("flag_evaluation").
+			Debug(fmt.Sprintf("Flag %s evaluated to %t (known: %t)", name, v, ok))
+	}
+	return v
+}
+
+func equal(a, b Flag) bool {
+	if a.Enabled != b.Enabled || (a.Percentage == nil) != (b.Percentage == nil) {
+		return false
+	}
+	return a.Percentage == nil || *a.Percentage == *b.Percentage
+}
@@ -0,0 +1,148 @@
+package featureflag
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+)
+
+const defaultPollInterval = 30 * time.Second
+
+// RemoteOption customises the Remote provider.
+type RemoteOption func(*Remote)
+
+// WithPollInterval fetches the flags every d. Defaults to 30s.
+func WithPollInterval(d time.Duration) RemoteOption {
+	return func(r *Remote) {
+		r.interval = d
+	}
+}
+
+// WithHTTPClient fetches the flags with the client in input instead of http.DefaultClient.
+func WithHTTPClient(c *http.Client) RemoteOption {
+	return func(r *Remote) {
+		r.client = c
+	}
+}
+
+// WithLogger logs flag evaluations at debug level and failed fetches at warning level.
+func WithLogger(log logger.Logger) RemoteOption {
+	return func(r *Remote) {
+		r.log = log
+	}
+}
+
+// WithFallback serves the flags in input until the remote ones are fetched successfully.
+func WithFallback(flags map[string]Flag) RemoteOption {
+	return func(r *Remote) {
+		r.fallback = flags
+	}
+}
+
+// WithClock schedules the polls with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) RemoteOption {
+	return func(r *Remote) {
+		r.clock = c
+	}
+}
+
+// Remote serves flags fetched from an HTTP endpoint returning them as JSON.
+// When the endpoint is unreachable it keeps serving the last fetched flags, or the fallback ones.
+type Remote struct {
+	store
+	url      string
+	interval time.Duration
+	client   *http.Client
+	clock    clock.Clock
+	fallback map[string]Flag
+
+	mu   sync.Mutex
+	etag string
+}
+
+// compile time interface check.
+var _ Provider = &Remote{}
+
+// NewRemote returns a provider serving the flags fetched from the URL in input.
+// Flags are fetched by Refresh and Poll, until then the fallback flags are served.
+func NewRemote(url string, opts ...RemoteOption) *Remote {
+	r := &Remote{
+		url:      url,
+		interval: defaultPollInterval,
+		client:   http.DefaultClient,
+		clock:    clock.New(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	fallback := r.fallback
+	if fallback == nil {
+		fallback = make(map[string]Flag)
+	}
+	r.set(fallback)
+	return r
+}
+
+// Refresh fetches the flags, replacing the served ones and notifying the changes.
+// Unchanged flags are detected with the ETag of the previous response.
+func (r *Remote) Refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	r.mu.Lock()
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	r.mu.Unlock()
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not fetch flags: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("could not fetch flags: unexpected status %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read flags: %w", err)
+	}
+	flags, err := decode(b, ".json")
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.etag = resp.Header.Get("ETag")
+	r.mu.Unlock()
+	r.set(flags)
+	return nil
+}
+
+// Poll refreshes the flags immediately and then at every poll interval, until the context is done.
+// Failures are logged and the last known flags keep being served. It is meant to be run in its own goroutine.
+func (r *Remote) Poll(ctx context.Context) {
+	ticker := r.clock.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Refresh(ctx); err != nil && ctx.Err() == nil && r.log != nil {
+			r.log.Event("flag_refresh").Err(err).Warn("Could not refresh feature flags, serving the last known ones")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package featureflag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/indiependente/pkg/logger"
+	"go.yaml.in/yaml/v3"
+)
+
+// Static serves a fixed set of flags, e.g. loaded from a configuration file.
+type Static struct {
+	store
+}
+
+// compile time interface check.
+var _ Provider = &Static{}
+
+// NewStatic returns a provider serving the flags in input, logging evaluations at debug level if log is not nil.
+func NewStatic(flags map[string]Flag, log logger.Logger) *Static {
+	s := &Static{store: store{log: log}}
+	s.set(flags)
+	return s
+}
+
+// NewFile returns a provider serving the flags in the JSON or YAML file in input, chosen by extension.
+// The file maps flag names to their definition, e.g. {"new_checkout": {"enabled": true, "percentage": 10}}.
+func NewFile(path string, log logger.Logger) (*Static, error) {
+	flags, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewStatic(flags, log), nil
+}
+
+// Reload reads again the file in input, replacing the flags and notifying the changes.
+func (s *Static) Reload(path string) error {
+	flags, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	s.set(flags)
+	return nil
+}
+
+// Set replaces the flags, notifying the changes.
+func (s *Static) Set(flags map[string]Flag) {
+	s.set(flags)
+}
+
+func readFile(path string) (map[string]Flag, error) {
+	b, err := os.ReadFile(path) // nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("could not read flags file: %w", err)
+	}
+	return decode(b, strings.ToLower(filepath.Ext(path)))
+}
+
+func decode(b []byte, ext string) (map[string]Flag, error) {
+	flags := make(map[string]Flag)
+	switch ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(b, &flags); err != nil {
+			return nil, fmt.Errorf("could not decode flags: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(b, &flags); err != nil {
+			return nil, fmt.Errorf("could not decode flags: %w", err)
+		}
+	}
+	return flags, nil
+}
@@ -0,0 +1,155 @@
+package filex
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// WriteAtomic writes data to a temporary file in the directory of path, syncs it and renames it to path,
+// so that readers see either the previous content or the new one, never a partial write.
+func WriteAtomic(path string, data []byte, perm os.FileMode) error {
+	return WriteAtomicFrom(path, bytes.NewReader(data), perm)
+}
+
+// WriteAtomicFrom behaves as WriteAtomic, streaming the content from r.
+func WriteAtomicFrom(path string, r io.Reader, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write temporary file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not set permissions: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not close temporary file: %w", err)
+	}
+	return Rename(tmp.Name(), path)
+}
+
+// Rename renames oldpath to newpath and syncs the directory of newpath, so that the rename survives a crash.
+func Rename(oldpath, newpath string) error {
+	if err := os.Rename(oldpath, newpath); err != nil {
+		return fmt.Errorf("could not rename file: %w", err)
+	}
+	return SyncDir(filepath.Dir(newpath))
+}
+
+// SyncDir flushes the directory entries of dir to disk.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir) // nolint:gosec
+	if err != nil {
+		return fmt.Errorf("could not open directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !isUnsupported(err) {
+		return fmt.Errorf("could not sync directory: %w", err)
+	}
+	return nil
+}
+
+// CopyFile copies the content and permissions of src to dst, atomically.
+func CopyFile(src, dst string) error {
+	f, err := os.Open(src) // nolint:gosec
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not stat file: %w", err)
+	}
+	return WriteAtomicFrom(dst, f, fi.Mode().Perm())
+}
+
+// CopyDir copies the directory tree at src to dst, preserving permissions.
+// Regular files are copied atomically, symbolic links are recreated and other files are skipped.
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(target, fi.Mode().Perm()); err != nil {
+				return fmt.Errorf("could not create directory: %w", err)
+			}
+		case fi.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("could not read link: %w", err)
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return fmt.Errorf("could not create link: %w", err)
+			}
+		case fi.Mode().IsRegular():
+			return CopyFile(path, target)
+		}
+		return nil
+	})
+}
+
+// Checksum returns the hex encoded digest of the file computed with the hash in input.
+func Checksum(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path) // nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("could not open file: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("could not hash file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SHA256 returns the hex encoded SHA-256 digest of the file.
+func SHA256(path string) (string, error) {
+	return Checksum(path, sha256.New())
+}
+
+// VerifySHA256 returns an error if the SHA-256 digest of the file is not the hex encoded one in input.
+func VerifySHA256(path, expected string) error {
+	sum, err := SHA256(path)
+	if err != nil {
+		return err
+	}
+	if sum != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, sum)
+	}
+	return nil
+}
+
+// isUnsupported reports whether the error is returned by file systems not supporting the operation,
+// e.g. syncing directories on some platforms.
+func isUnsupported(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EBADF)
+}
@@ -0,0 +1,42 @@
+package filex
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrLocked is returned by TryLock when the lock is held by another process.
+var ErrLocked = errors.New("file is locked")
+
+// Lock is an exclusive advisory lock on a file, e.g. to make sure a single instance of a program runs.
+// The lock is released when the process exits.
+type Lock struct {
+	f *os.File
+}
+
+// TryLock acquires the lock on the file at path, creating it if needed, or returns ErrLocked if it is held.
+// The process ID is written to the file for troubleshooting.
+func TryLock(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644) // nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("could not open lock file: %w", err)
+	}
+	if err := flock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Truncate(0); err == nil {
+		_, _ = fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+	return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock. The lock file is left in place, removing it would race with other processes.
+func (l *Lock) Unlock() error {
+	if err := funlock(l.f); err != nil {
+		l.f.Close()
+		return fmt.Errorf("could not release lock: %w", err)
+	}
+	return l.f.Close()
+}
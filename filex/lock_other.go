@@ -0,0 +1,16 @@
+//go:build !unix
+
+package filex
+
+import (
+	"errors"
+	"os"
+)
+
+func flock(*os.File) error {
+	return errors.ErrUnsupported
+}
+
+func funlock(*os.File) error {
+	return errors.ErrUnsupported
+}
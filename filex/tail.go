@@ -0,0 +1,139 @@
+package filex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const defaultPollInterval = 250 * time.Millisecond
+
+// TailOption customises the Tail reader.
+type TailOption func(*Tail)
+
+// FromStart reads the file from the beginning instead of from its end.
+func FromStart() TailOption {
+	return func(t *Tail) {
+		t.fromStart = true
+	}
+}
+
+// WithPollInterval checks for new data and rotations every d. Defaults to 250ms.
+func WithPollInterval(d time.Duration) TailOption {
+	return func(t *Tail) {
+		t.interval = d
+	}
+}
+
+// Tail reads a file as it grows, as tail -F does: when the file is truncated it reads again from the start,
+// and when it is rotated, i.e. replaced by a new file at the same path, it reads the rest of the old file
+// and moves to the new one.
+type Tail struct {
+	ctx       context.Context
+	path      string
+	interval  time.Duration
+	fromStart bool
+	f         *os.File
+}
+
+// compile time interface check.
+var _ io.ReadCloser = &Tail{}
+
+// NewTail opens the file at path for tailing. Reads block until new data is written or ctx is done.
+func NewTail(ctx context.Context, path string, opts ...TailOption) (*Tail, error) {
+	t := &Tail{ctx: ctx, path: path, interval: defaultPollInterval}
+	for _, opt := range opts {
+		opt(t)
+	}
+	f, err := os.Open(path) // nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	if !t.fromStart {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not seek file: %w", err)
+		}
+	}
+	t.f = f
+	return t, nil
+}
+
+// Read reads the data written to the file, blocking until some is available.
+// It returns the error of the context once it is done.
+func (t *Tail) Read(p []byte) (int, error) {
+	for {
+		n, err := t.f.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("could not read file: %w", err)
+		}
+		if err := t.follow(); err != nil {
+			return 0, err
+		}
+		if t.changed() {
+			continue
+		}
+		select {
+		case <-t.ctx.Done():
+			return 0, t.ctx.Err()
+		case <-time.After(t.interval):
+		}
+	}
+}
+
+// changed reports whether there may be data to read after a rotation or a truncation.
+func (t *Tail) changed() bool {
+	fi, err := t.f.Stat()
+	if err != nil {
+		return false
+	}
+	pos, err := t.f.Seek(0, io.SeekCurrent)
+	return err == nil && fi.Size() > pos
+}
+
+// follow reopens the file if it has been rotated, and rewinds it if it has been truncated.
+// It must be called once the current file has been read to the end.
+func (t *Tail) follow() error {
+	cur, err := t.f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not stat file: %w", err)
+	}
+	next, err := os.Stat(t.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// rotated but not recreated yet
+		return nil
+	case err != nil:
+		return fmt.Errorf("could not stat file: %w", err)
+	case !os.SameFile(cur, next):
+		f, err := os.Open(t.path)
+		if err != nil {
+			// the new file is not readable yet, retry at the next poll
+			return nil // nolint:nilerr
+		}
+		t.f.Close()
+		t.f = f
+		return nil
+	}
+	pos, err := t.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("could not seek file: %w", err)
+	}
+	if cur.Size() < pos {
+		if _, err := t.f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("could not seek file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the file.
+func (t *Tail) Close() error {
+	return t.f.Close()
+}
@@ -0,0 +1,284 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+)
+
+const fsmEvent = "fsm"
+
+var (
+	// ErrInvalidTransition is returned when firing an event which is not allowed in the current state.
+	ErrInvalidTransition = errors.New("invalid transition")
+	// ErrGuardRejected wraps the error of a guard rejecting a transition.
+	ErrGuardRejected = errors.New("transition rejected by guard")
+	// ErrUnknownState is returned when restoring a state which does not appear in the transition table.
+	ErrUnknownState = errors.New("unknown state")
+)
+
+// Change describes a transition being executed.
+type Change[S, E comparable] struct {
+	From  S
+	To    S
+	Event E
+}
+
+// Guard allows a transition returning nil, or rejects it returning the reason.
+type Guard[S, E comparable] func(ctx context.Context, c Change[S, E]) error
+
+// Hook is called while executing a transition; an error aborts the transition, unless said otherwise.
+type Hook[S, E comparable] func(ctx context.Context, c Change[S, E]) error
+
+// Transition moves the machine from any of the From states to To when Event is fired.
+type Transition[S, E comparable] struct {
+	From  []S
+	Event E
+	To    S
+	// Guard, if set, is checked before executing the transition.
+	Guard Guard[S, E]
+	// Action, if set, is called after the exit hooks and before the state changes.
+	Action Hook[S, E]
+}
+
+// Store persists the state of a machine, so that it can be resumed, e.g. after a restart.
+type Store[S, E comparable] interface {
+	// Load returns the persisted state, with ok false if there is none.
+	Load(ctx context.Context) (state S, ok bool, err error)
+	// Save persists the state reached by the change. An error aborts the transition.
+	Save(ctx context.Context, c Change[S, E]) error
+}
+
+// Option customises a Machine.
+type Option func(*config)
+
+type config struct {
+	name string
+	log  logger.Logger
+}
+
+// WithName names the machine in the logs, e.g. after the entity it tracks.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithLogger logs every transition at info level, and every failed one at warning level.
+func WithLogger(log logger.Logger) Option {
+	return func(c *config) {
+		c.log = log
+	}
+}
+
+type key[S, E comparable] struct {
+	state S
+	event E
+}
+
+// Machine is a finite state machine with states of type S and events of type E.
+// Transitions are executed one at a time: hooks must not fire events on the same machine.
+type Machine[S, E comparable] struct {
+	cfg         config
+	transitions map[key[S, E]]Transition[S, E]
+	states      map[S]struct{}
+	onEnter     map[S][]Hook[S, E]
+	onExit      map[S][]Hook[S, E]
+	onChange    []Hook[S, E]
+	store       Store[S, E]
+
+	mu    sync.Mutex
+	state S
+}
+
+// New returns a Machine in the initial state, allowing the transitions in input.
+// It fails if two transitions leave the same state on the same event.
+func New[S, E comparable](initial S, transitions []Transition[S, E], opts ...Option) (*Machine[S, E], error) {
+	m := &Machine[S, E]{
+		cfg:         config{name: fsmEvent},
+		transitions: make(map[key[S, E]]Transition[S, E]),
+		states:      map[S]struct{}{initial: {}},
+		onEnter:     make(map[S][]Hook[S, E]),
+		onExit:      make(map[S][]Hook[S, E]),
+		state:       initial,
+	}
+	for _, opt := range opts {
+		opt(&m.cfg)
+	}
+	for _, t := range transitions {
+		m.states[t.To] = struct{}{}
+		for _, from := range t.From {
+			k := key[S, E]{state: from, event: t.Event}
+			if _, ok := m.transitions[k]; ok {
+				return nil, fmt.Errorf("could not build state machine: duplicated transition from %v on %v", from, t.Event)
+			}
+			m.transitions[k] = t
+			m.states[from] = struct{}{}
+		}
+	}
+	return m, nil
+}
+
+// OnEnter registers a hook called after entering the state. The state has already changed when it is called,
+// so its error is returned by Fire but does not roll the transition back.
+// Hooks must be registered before firing events.
+func (m *Machine[S, E]) OnEnter(state S, hook Hook[S, E]) {
+	m.onEnter[state] = append(m.onEnter[state], hook)
+}
+
+// OnExit registers a hook called before leaving the state; an error aborts the transition.
+// Hooks must be registered before firing events.
+func (m *Machine[S, E]) OnExit(state S, hook Hook[S, E]) {
+	m.onExit[state] = append(m.onExit[state], hook)
+}
+
+// OnTransition registers a hook called after every transition, after the entry hooks.
+// Hooks must be registered before firing events.
+func (m *Machine[S, E]) OnTransition(hook Hook[S, E]) {
+	m.onChange = append(m.onChange, hook)
+}
+
+// SetStore persists every transition to the store in input, which Resume loads the state from.
+// It must be called before firing events.
+func (m *Machine[S, E]) SetStore(store Store[S, E]) {
+	m.store = store
+}
+
+// Resume loads the state from the store, keeping the current one if the store has none.
+func (m *Machine[S, E]) Resume(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+	state, ok, err := m.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load state: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+	return m.Restore(state)
+}
+
+// Restore sets the state without executing any transition, e.g. to resume a machine from a database row.
+func (m *Machine[S, E]) Restore(state S) error {
+	if _, ok := m.states[state]; !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownState, state)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+	return nil
+}
+
+// State returns the current state.
+func (m *Machine[S, E]) State() S {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Can reports whether the event is allowed in the current state, regardless of guards.
+func (m *Machine[S, E]) Can(event E) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.transitions[key[S, E]{state: m.state, event: event}]
+	return ok
+}
+
+// Events returns the events allowed in the current state, regardless of guards.
+func (m *Machine[S, E]) Events() []E {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []E
+	for k := range m.transitions {
+		if k.state == m.state {
+			events = append(events, k.event)
+		}
+	}
+	return events
+}
+
+// Fire executes the transition for the event from the current state: it checks the guard, calls the exit hooks
+// of the current state and the action, persists the change, switches state and calls the entry hooks of the new
+// state and the transition hooks. Any failure before the switch leaves the state unchanged.
+func (m *Machine[S, E]) Fire(ctx context.Context, event E) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start := time.Now()
+	t, ok := m.transitions[key[S, E]{state: m.state, event: event}]
+	if !ok {
+		err := fmt.Errorf("%w: %v on %v", ErrInvalidTransition, m.state, event)
+		m.logFailed(ctx, Change[S, E]{From: m.state, To: m.state, Event: event}, start, err)
+		return err
+	}
+	c := Change[S, E]{From: m.state, To: t.To, Event: event}
+	if err := m.execute(ctx, t, c); err != nil {
+		m.logFailed(ctx, c, start, err)
+		return err
+	}
+
+	m.state = t.To
+	var errs []error
+	for _, hook := range m.onEnter[c.To] {
+		if err := hook(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, hook := range m.onChange {
+		if err := hook(ctx, c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err := errors.Join(errs...)
+	if m.cfg.log != nil {
+		l := logger.WithTrace(ctx, m.cfg.log.Event(fsmEvent).Component(m.cfg.name).Duration(time.Since(start)))
+		msg := fmt.Sprintf("Transition from %v to %v on %v", c.From, c.To, c.Event)
+		if err != nil {
+			l.Error(msg+", entry hooks failed", err)
+		} else {
+			l.Info(msg)
+		}
+	}
+	return err
+}
+
+// execute runs the steps of the transition which may abort it.
+func (m *Machine[S, E]) execute(ctx context.Context, t Transition[S, E], c Change[S, E]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if t.Guard != nil {
+		if err := t.Guard(ctx, c); err != nil {
+			return fmt.Errorf("%w: %w", ErrGuardRejected, err)
+		}
+	}
+	for _, hook := range m.onExit[c.From] {
+		if err := hook(ctx, c); err != nil {
+			return fmt.Errorf("could not exit %v: %w", c.From, err)
+		}
+	}
+	if t.Action != nil {
+		if err := t.Action(ctx, c); err != nil {
+			return fmt.Errorf("could not execute transition to %v: %w", c.To, err)
+		}
+	}
+	if m.store != nil {
+		if err := m.store.Save(ctx, c); err != nil {
+			return fmt.Errorf("could not save state: %w", err)
+		}
+	}
+	return nil
+}
+
+func (m *Machine[S, E]) logFailed(ctx context.Context, c Change[S, E], start time.Time, err error) {
+	if m.cfg.log == nil {
+		return
+	}
+	logger.WithTrace(ctx, m.cfg.log.Event(fsmEvent).Component(m.cfg.name).Duration(time.Since(start)).Err(err)).
+		Warn(fmt.Sprintf("Transition from %v on %v failed", c.From, c.Event))
+}
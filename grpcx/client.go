@@ -0,0 +1,113 @@
+package grpcx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	keepaliveTime      = 30 * time.Second
+	keepaliveTimeout   = 10 * time.Second
+	defaultMaxAttempts = 3
+)
+
+// DialOption customises a client connection.
+type DialOption func(*dialConfig)
+
+type dialConfig struct {
+	log         logger.Logger
+	insecure    bool
+	maxAttempts int
+	noTracing   bool
+	grpcOptions []grpc.DialOption
+}
+
+// WithClientLogger logs every call at debug level with the logger in input.
+func WithClientLogger(log logger.Logger) DialOption {
+	return func(c *dialConfig) {
+		c.log = log
+	}
+}
+
+// WithInsecure disables transport security.
+func WithInsecure() DialOption {
+	return func(c *dialConfig) {
+		c.insecure = true
+	}
+}
+
+// WithRetries retries calls failing with codes.Unavailable up to n attempts in total, backing off exponentially.
+// Pass 1 to disable retries. Defaults to 3.
+func WithRetries(n int) DialOption {
+	return func(c *dialConfig) {
+		c.maxAttempts = n
+	}
+}
+
+// WithoutClientTracing disables the tracing instrumentation of the client.
+func WithoutClientTracing() DialOption {
+	return func(c *dialConfig) {
+		c.noTracing = true
+	}
+}
+
+// WithDialOptions passes the options in input to grpc.NewClient, after the default ones.
+func WithDialOptions(opts ...grpc.DialOption) DialOption {
+	return func(c *dialConfig) {
+		c.grpcOptions = append(c.grpcOptions, opts...)
+	}
+}
+
+// Dial returns a client connection to target propagating the request ID, instrumented for tracing,
+// with keepalive and retries on codes.Unavailable enabled. Transport security defaults to TLS with the system roots.
+func Dial(target string, opts ...DialOption) (*grpc.ClientConn, error) {
+	cfg := &dialConfig{maxAttempts: defaultMaxAttempts}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	creds := credentials.NewClientTLSFromCert(nil, "")
+	if cfg.insecure {
+		creds = insecure.NewCredentials()
+	}
+	unary := []grpc.UnaryClientInterceptor{UnaryClientRequestID()}
+	if cfg.log != nil {
+		unary = append(unary, UnaryClientLogging(cfg.log))
+	}
+	grpcOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(StreamClientRequestID()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                keepaliveTime,
+			Timeout:             keepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+	}
+	if cfg.maxAttempts > 1 {
+		grpcOpts = append(grpcOpts, grpc.WithDefaultServiceConfig(retryServiceConfig(cfg.maxAttempts)))
+	}
+	if !cfg.noTracing {
+		grpcOpts = append(grpcOpts, grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+	}
+
+	conn, err := grpc.NewClient(target, append(grpcOpts, cfg.grpcOptions...)...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create client for %s: %w", target, err)
+	}
+	return conn, nil
+}
+
+// retryServiceConfig returns a service config retrying every method on codes.Unavailable.
+func retryServiceConfig(maxAttempts int) string {
+	return fmt.Sprintf(`{"methodConfig":[{"name":[{}],"retryPolicy":{`+
+		`"maxAttempts":%d,"initialBackoff":"0.1s","maxBackoff":"5s","backoffMultiplier":2,`+
+		`"retryableStatusCodes":["UNAVAILABLE"]}}]}`, maxAttempts)
+}
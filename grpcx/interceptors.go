@@ -0,0 +1,184 @@
+package grpcx
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/id"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	serverEvent = "grpc_server"
+	clientEvent = "grpc_client"
+)
+
+// requestIDMetadata is the metadata key carrying the request ID.
+var requestIDMetadata = strings.ToLower(requestid.Header)
+
+// UnaryServerRequestID reads the request ID from the incoming metadata, generating one if missing,
+// stores it in the context and sends it back in the response header.
+func UnaryServerRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(withIncomingRequestID(ctx), req)
+	}
+}
+
+// StreamServerRequestID is the streaming counterpart of UnaryServerRequestID.
+func StreamServerRequestID() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: withIncomingRequestID(ss.Context())})
+	}
+}
+
+func withIncomingRequestID(ctx context.Context) context.Context {
+	var reqID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vs := md.Get(requestIDMetadata); len(vs) > 0 {
+			reqID = vs[0]
+		}
+	}
+	if reqID == "" {
+		reqID = id.NewUUIDv7()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, reqID))
+	return requestid.NewContext(ctx, reqID)
+}
+
+// UnaryServerLogging logs every call along with its method, status code, duration, peer address and request ID.
+// The status code field holds the gRPC code.
+func UnaryServerLogging(log logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, log.Event(serverEvent), info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerLogging is the streaming counterpart of UnaryServerLogging.
+func StreamServerLogging(log logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), log.Event(serverEvent), info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, l logger.Logger, method string, start time.Time, err error) {
+	l = l.Method(method).
+		StatusCode(int(status.Code(err))).
+		Duration(time.Since(start))
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		l = l.RemoteAddr(p.Addr.String())
+	}
+	if reqID, ok := requestid.FromContext(ctx); ok {
+		l = l.RequestID(reqID)
+	}
+	l = logger.WithTrace(ctx, l)
+	if err != nil {
+		l = l.Err(err)
+	}
+	l.Info("Call handled")
+}
+
+// UnaryServerRecovery recovers from panics of the handler, logs the panic value and the stack trace at error level
+// and fails the call with codes.Internal.
+func UnaryServerRecovery(log logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = recovered(log, info.FullMethod, rec)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerRecovery is the streaming counterpart of UnaryServerRecovery.
+func StreamServerRecovery(log logger.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = recovered(log, info.FullMethod, rec)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(log logger.Logger, method string, rec interface{}) error {
+	log.Event(serverEvent).
+		Method(method).
+		Stack(string(debug.Stack())).
+		Error("Recovered from panic", fmt.Errorf("panic: %v", rec))
+	return status.Error(codes.Internal, "internal error")
+}
+
+// UnaryClientRequestID propagates the request ID carried by the context to the outgoing metadata.
+func UnaryClientRequestID() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withOutgoingRequestID(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientRequestID is the streaming counterpart of UnaryClientRequestID.
+func StreamClientRequestID() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withOutgoingRequestID(ctx), desc, cc, method, opts...)
+	}
+}
+
+func withOutgoingRequestID(ctx context.Context) context.Context {
+	reqID, ok := requestid.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(requestIDMetadata)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestIDMetadata, reqID)
+}
+
+// UnaryClientLogging logs every call along with its method, status code, duration and request ID at debug level.
+// The status code field holds the gRPC code.
+func UnaryClientLogging(log logger.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		l := log.Event(clientEvent).
+			Method(method).
+			Host(cc.Target()).
+			StatusCode(int(status.Code(err))).
+			Duration(time.Since(start))
+		if reqID, ok := requestid.FromContext(ctx); ok {
+			l = l.RequestID(reqID)
+		}
+		l = logger.WithTrace(ctx, l)
+		if err != nil {
+			l = l.Err(err)
+		}
+		l.Debug("Call sent")
+		return err
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
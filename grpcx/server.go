@@ -0,0 +1,201 @@
+package grpcx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/shutdown"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// ServerOption customises a Server.
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	log             logger.Logger
+	addr            string
+	shutdownTimeout time.Duration
+	reflection      bool
+	tracing         []otelgrpc.Option
+	noTracing       bool
+	unary           []grpc.UnaryServerInterceptor
+	stream          []grpc.StreamServerInterceptor
+	grpcOptions     []grpc.ServerOption
+}
+
+// WithLogger logs every call and recovered panic with the logger in input.
+func WithLogger(log logger.Logger) ServerOption {
+	return func(c *serverConfig) {
+		c.log = log
+	}
+}
+
+// WithAddr sets the address Run listens on. Defaults to :9090.
+func WithAddr(addr string) ServerOption {
+	return func(c *serverConfig) {
+		c.addr = addr
+	}
+}
+
+// WithShutdownTimeout sets how long a graceful stop waits for in-flight calls before forcing the stop.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.shutdownTimeout = d
+	}
+}
+
+// WithoutReflection does not register the reflection service.
+func WithoutReflection() ServerOption {
+	return func(c *serverConfig) {
+		c.reflection = false
+	}
+}
+
+// WithServerTracing customises the tracing instrumentation of the server.
+func WithServerTracing(opts ...otelgrpc.Option) ServerOption {
+	return func(c *serverConfig) {
+		c.tracing = append(c.tracing, opts...)
+	}
+}
+
+// WithoutServerTracing disables the tracing instrumentation of the server.
+func WithoutServerTracing() ServerOption {
+	return func(c *serverConfig) {
+		c.noTracing = true
+	}
+}
+
+// WithUnaryInterceptors appends the interceptors in input to the unary chain, after the default ones.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(c *serverConfig) {
+		c.unary = append(c.unary, interceptors...)
+	}
+}
+
+// WithStreamInterceptors appends the interceptors in input to the stream chain, after the default ones.
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return func(c *serverConfig) {
+		c.stream = append(c.stream, interceptors...)
+	}
+}
+
+// WithServerOptions passes the options in input to the underlying grpc.Server.
+func WithServerOptions(opts ...grpc.ServerOption) ServerOption {
+	return func(c *serverConfig) {
+		c.grpcOptions = append(c.grpcOptions, opts...)
+	}
+}
+
+// Server is a grpc.Server with health and reflection services, and graceful stop.
+type Server struct {
+	*grpc.Server
+	Health *health.Server
+
+	addr            string
+	shutdownTimeout time.Duration
+}
+
+// NewServer returns a Server chaining the request ID, logging and recovery interceptors, in this order,
+// and instrumented for tracing. Logging and recovery are enabled when a logger is passed via WithLogger.
+func NewServer(opts ...ServerOption) *Server {
+	cfg := &serverConfig{
+		addr:            ":9090",
+		shutdownTimeout: defaultShutdownTimeout,
+		reflection:      true,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	unary := []grpc.UnaryServerInterceptor{UnaryServerRequestID()}
+	stream := []grpc.StreamServerInterceptor{StreamServerRequestID()}
+	if cfg.log != nil {
+		unary = append(unary, UnaryServerLogging(cfg.log), UnaryServerRecovery(cfg.log))
+		stream = append(stream, StreamServerLogging(cfg.log), StreamServerRecovery(cfg.log))
+	}
+	grpcOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(append(unary, cfg.unary...)...),
+		grpc.ChainStreamInterceptor(append(stream, cfg.stream...)...),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             keepaliveTime / 2,
+			PermitWithoutStream: true,
+		}),
+	}
+	if !cfg.noTracing {
+		grpcOpts = append(grpcOpts, grpc.StatsHandler(otelgrpc.NewServerHandler(cfg.tracing...)))
+	}
+
+	s := &Server{
+		Server:          grpc.NewServer(append(grpcOpts, cfg.grpcOptions...)...),
+		Health:          health.NewServer(),
+		addr:            cfg.addr,
+		shutdownTimeout: cfg.shutdownTimeout,
+	}
+	healthpb.RegisterHealthServer(s.Server, s.Health)
+	if cfg.reflection {
+		reflection.Register(s.Server)
+	}
+	return s
+}
+
+// Run serves calls until the context in input is cancelled, then gracefully stops the server,
+// waiting up to the shutdown timeout for in-flight calls to complete.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", s.addr, err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve behaves as Run but accepts connections on the listener in input.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("could not serve: %w", err)
+	case <-ctx.Done():
+	}
+	if err := s.TerminationFn()(context.Background()); err != nil {
+		return err
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("could not serve: %w", err)
+	}
+	return nil
+}
+
+// TerminationFn returns a shutdown.TerminationFn marking the server as not serving and gracefully stopping it,
+// forcing the stop if in-flight calls do not complete within the shutdown timeout.
+func (s *Server) TerminationFn() shutdown.TerminationFn {
+	return func(context.Context) error {
+		s.Health.Shutdown()
+		done := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-time.After(s.shutdownTimeout):
+			s.Stop()
+			return fmt.Errorf("could not stop server gracefully: timed out after %s", s.shutdownTimeout)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/indiependente/pkg/shutdown"
+)
+
+const (
+	defaultCheckTimeout = 5 * time.Second
+	defaultCacheTTL     = time.Second
+)
+
+// Status is the outcome of a check or of a whole report.
+type Status string
+
+const (
+	// StatusOK means the check passed.
+	StatusOK Status = "ok"
+	// StatusFail means the check failed.
+	StatusFail Status = "fail"
+	// StatusDraining means the service is shutting down and should not receive new traffic.
+	StatusDraining Status = "draining"
+)
+
+// Check reports the health of a dependency, returning an error when it is unhealthy.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single check.
+type CheckResult struct {
+	Status   Status    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Critical bool      `json:"critical"`
+	Duration string    `json:"duration"`
+	Checked  time.Time `json:"checked_at"`
+}
+
+// Report aggregates the outcome of the checks.
+// Its status fails if any critical check fails; informational checks are reported but do not affect it.
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Option customises the Checker returned by New.
+type Option func(*Checker)
+
+// WithCacheTTL reuses check results for ttl, protecting dependencies from frequent probes.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *Checker) {
+		c.ttl = ttl
+	}
+}
+
+// CheckOption customises a registered check.
+type CheckOption func(*check)
+
+// WithTimeout fails the check if it does not complete within d.
+func WithTimeout(d time.Duration) CheckOption {
+	return func(c *check) {
+		c.timeout = d
+	}
+}
+
+// Informational reports the check without letting its failures affect readiness.
+func Informational() CheckOption {
+	return func(c *check) {
+		c.critical = false
+	}
+}
+
+// Checker runs health checks and serves their results over HTTP.
+type Checker struct {
+	ttl      time.Duration
+	draining atomic.Bool
+
+	mu     sync.RWMutex
+	checks map[string]*check
+}
+
+type check struct {
+	fn       Check
+	timeout  time.Duration
+	critical bool
+
+	mu     sync.Mutex
+	result CheckResult
+}
+
+// New returns a Checker without checks.
+func New(opts ...Option) *Checker {
+	c := &Checker{
+		ttl:    defaultCacheTTL,
+		checks: make(map[string]*check),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Register adds a check under the name in input, replacing any check with the same name.
+// Checks are critical unless Informational is used.
+func (c *Checker) Register(name string, fn Check, opts ...CheckOption) {
+	chk := &check{fn: fn, timeout: defaultCheckTimeout, critical: true}
+	for _, opt := range opts {
+		opt(chk)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = chk
+}
+
+// Run runs the checks concurrently, reusing results younger than the cache TTL.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make(map[string]*check, len(c.checks))
+	for name, chk := range c.checks {
+		checks[name] = chk
+	}
+	c.mu.RUnlock()
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		r  = Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	)
+	for name, chk := range checks {
+		wg.Add(1)
+		go func(name string, chk *check) {
+			defer wg.Done()
+			res := chk.run(ctx, c.ttl)
+			mu.Lock()
+			defer mu.Unlock()
+			r.Checks[name] = res
+			if res.Status == StatusFail && res.Critical {
+				r.Status = StatusFail
+			}
+		}(name, chk)
+	}
+	wg.Wait()
+	return r
+}
+
+func (chk *check) run(ctx context.Context, ttl time.Duration) CheckResult {
+	chk.mu.Lock()
+	defer chk.mu.Unlock()
+	if !chk.result.Checked.IsZero() && time.Since(chk.result.Checked) < ttl {
+		return chk.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, chk.timeout)
+	defer cancel()
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- chk.fn(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := CheckResult{
+		Status:   StatusOK,
+		Critical: chk.critical,
+		Duration: time.Since(start).String(),
+		Checked:  time.Now(),
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	chk.result = res
+	return res
+}
+
+// Drain marks the service as draining: readiness fails from now on, so load balancers stop sending traffic.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// DrainFn returns a shutdown.TerminationFn that flips readiness and waits for delay,
+// giving load balancers time to notice before the service stops serving.
+func (c *Checker) DrainFn(delay time.Duration) shutdown.TerminationFn {
+	return func(context.Context) error {
+		c.Drain()
+		time.Sleep(delay)
+		return nil
+	}
+}
+
+// LivezHandler reports whether the process is alive: it always answers 200 OK without running any check.
+func (c *Checker) LivezHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeReport(w, Report{Status: StatusOK})
+	})
+}
+
+// ReadyzHandler reports whether the service can receive traffic, answering 503 Service Unavailable
+// when draining or when a critical check fails.
+func (c *Checker) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.draining.Load() {
+			writeReport(w, Report{Status: StatusDraining})
+			return
+		}
+		writeReport(w, c.Run(r.Context()))
+	})
+}
+
+// HealthzHandler reports the outcome of every check, answering 503 Service Unavailable when a critical check fails.
+func (c *Checker) HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Run(r.Context()))
+	})
+}
+
+// Mount serves the handlers on the /livez, /readyz and /healthz paths of the mux.
+func (c *Checker) Mount(mux *http.ServeMux) {
+	mux.Handle("/livez", c.LivezHandler())
+	mux.Handle("/readyz", c.ReadyzHandler())
+	mux.Handle("/healthz", c.HealthzHandler())
+}
+
+func writeReport(w http.ResponseWriter, r Report) {
+	status := http.StatusOK
+	if r.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,66 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultProbePath is the path probed by RunProbe when none is given.
+const DefaultProbePath = "/readyz"
+
+// Probe checks the health of the service listening on addr, e.g. :9091, by requesting the path in input, /readyz if
+// empty, returning an error if it does not answer with 200 OK within timeout. Addresses without a host are probed
+// on the loopback interface.
+func Probe(ctx context.Context, addr, path string, timeout time.Duration) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("could not parse address %s: %w", addr, err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	if path == "" {
+		path = DefaultProbePath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+net.JoinHostPort(host, port)+path, nil)
+	if err != nil {
+		return fmt.Errorf("could not create probe request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not probe %s: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("probe of %s answered %s: %s", req.URL, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// RunProbe probes the service like Probe, returning the exit code of a container health check: 0 if healthy,
+// 1 otherwise, after printing the failure to stderr. It is meant to be called from a -healthcheck flag of the
+// service binary, so that images without curl, e.g. distroless ones, can declare a Docker HEALTHCHECK:
+//
+//	if *healthcheckFlag {
+//		os.Exit(healthcheck.RunProbe(":9091", "/readyz", 3*time.Second))
+//	}
+func RunProbe(addr, path string, timeout time.Duration) int {
+	if err := Probe(context.Background(), addr, path, timeout); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/dedupe"
+)
+
+// expiryMargin is how long before their expiry cached OAuth2 tokens are refreshed.
+const expiryMargin = 10 * time.Second
+
+// TokenSource returns the token to authenticate a request with.
+type TokenSource func(ctx context.Context) (string, error)
+
+// WithBearerToken authenticates every request with the static bearer token in input.
+func WithBearerToken(token string) Option {
+	return WithTokenSource(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// WithTokenSource authenticates every request with a bearer token obtained from the token source in input.
+func WithTokenSource(src TokenSource) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			token, err := src(req.Context())
+			if err != nil {
+				return nil, fmt.Errorf("could not get token: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+token)
+			return next.RoundTrip(req)
+		})
+	})
+}
+
+// WithBasicAuth authenticates every request with the username and password in input.
+func WithBasicAuth(username, password string) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.SetBasicAuth(username, password)
+			return next.RoundTrip(req)
+		})
+	})
+}
+
+// WithAPIKeyHeader authenticates every request by setting the header in input to the API key.
+func WithAPIKeyHeader(header, key string) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set(header, key)
+			return next.RoundTrip(req)
+		})
+	})
+}
+
+// OAuth2Config describes an OAuth2 client credentials grant.
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// HTTPClient is used to request tokens. Defaults to a client built by New.
+	HTTPClient *http.Client
+}
+
+// WithOAuth2ClientCredentials authenticates every request with a token obtained via the OAuth2 client credentials grant.
+// Tokens are cached and refreshed shortly before they expire.
+func WithOAuth2ClientCredentials(cfg OAuth2Config) Option {
+	return WithTokenSource(NewClientCredentialsSource(cfg))
+}
+
+// NewClientCredentialsSource returns a TokenSource that caches tokens obtained via the OAuth2 client credentials grant.
+// Concurrent refreshes are coalesced into a single token request, during which the requests holding a valid token
+// are not held.
+func NewClientCredentialsSource(cfg OAuth2Config) TokenSource {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = New()
+	}
+	src := &clientCredentialsSource{cfg: cfg, fetches: dedupe.New[struct{}, string]()}
+	return src.Token
+}
+
+type clientCredentialsSource struct {
+	cfg     OAuth2Config
+	fetches *dedupe.Group[struct{}, string]
+
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Token returns the cached token, requesting a new one if it is missing or about to expire.
+func (s *clientCredentialsSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	token, refreshAt := s.token, s.refreshAt
+	s.mu.Unlock()
+	if token != "" && time.Now().Before(refreshAt) {
+		return token, nil
+	}
+	return s.fetches.DoValue(ctx, struct{}{}, s.fetch)
+}
+
+// fetch requests a new token and caches it.
+func (s *clientCredentialsSource) fetch(ctx context.Context) (string, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("could not create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not request token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("could not request token: unexpected status %d: %s", resp.StatusCode, body)
+	}
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", fmt.Errorf("could not decode token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return "", fmt.Errorf("could not request token: empty access token")
+	}
+
+	lifetime := time.Duration(tr.ExpiresIn) * time.Second
+	if tr.ExpiresIn <= 0 {
+		lifetime = time.Hour
+	}
+	s.mu.Lock()
+	s.token = tr.AccessToken
+	s.refreshAt = time.Now().Add(lifetime - refreshMargin(lifetime))
+	s.mu.Unlock()
+	return tr.AccessToken, nil
+}
+
+// refreshMargin returns how long before their expiry tokens valid for lifetime are refreshed: expiryMargin, clamped to
+// half the lifetime so that short-lived tokens are not refreshed on every request.
+func refreshMargin(lifetime time.Duration) time.Duration {
+	return min(expiryMargin, lifetime/2)
+}
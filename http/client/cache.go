@@ -0,0 +1,459 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/cache"
+	"github.com/indiependente/pkg/dedupe"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// defaultCacheEntries is the capacity of the in-memory store used when none is provided.
+	defaultCacheEntries = 1024
+	// defaultCacheMaxBody is the size of the largest response body stored by default.
+	defaultCacheMaxBody = 1 << 20
+)
+
+// CacheStore stores serialised responses by key.
+// Implementations must be safe for concurrent use.
+type CacheStore interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+// CacheOption customises the caching transport.
+type CacheOption func(*cacheTransport)
+
+// CacheWithStore stores responses in the store in input instead of the default in-memory LRU.
+func CacheWithStore(store CacheStore) CacheOption {
+	return func(t *cacheTransport) {
+		t.store = store
+	}
+}
+
+// CacheWithMetrics counts cache hits, misses, coalesced misses and revalidations on the registerer in input.
+func CacheWithMetrics(registerer prometheus.Registerer) CacheOption {
+	return func(t *cacheTransport) {
+		t.lookups = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_cache_lookups_total",
+			Help: "Number of HTTP cache lookups by result.",
+		}, []string{"result"}))
+	}
+}
+
+// CacheMaxBodySize stores only responses whose body is at most n bytes long. Defaults to 1MiB.
+// Larger responses are passed through without being buffered.
+func CacheMaxBodySize(n int64) CacheOption {
+	return func(t *cacheTransport) {
+		t.maxBody = n
+	}
+}
+
+// CacheCredentialHeaders adds the headers in input, e.g. a custom API key header, to the ones carrying credentials.
+func CacheCredentialHeaders(headers ...string) CacheOption {
+	return func(t *cacheTransport) {
+		for _, h := range headers {
+			t.credentialHeaders = append(t.credentialHeaders, http.CanonicalHeaderKey(h))
+		}
+	}
+}
+
+// CacheCoalesce makes concurrent misses of the same URL, with the same Accept, Accept-Encoding, Accept-Language
+// and credential headers, share a single request to the server through a dedupe.Group.
+// Shared responses are buffered in memory, cacheable or not, up to the CacheMaxBodySize limit: larger ones are
+// streamed to the caller which sent the request, the others sending their own.
+func CacheCoalesce() CacheOption {
+	return func(t *cacheTransport) {
+		t.coalesce = dedupe.New[string, []byte]()
+	}
+}
+
+// WithCache caches GET and HEAD responses honouring Cache-Control, Expires, ETag and Last-Modified as a private cache.
+// Stale responses carrying validators are revalidated with conditional requests.
+// Responses to requests with different credential headers (Authorization, Proxy-Authorization, Cookie, X-Api-Key
+// and the ones added with CacheCredentialHeaders) are stored apart, so that a response is never
+// served to a caller using other credentials.
+func WithCache(opts ...CacheOption) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		t := &cacheTransport{
+			next:              next,
+			maxBody:           defaultCacheMaxBody,
+			credentialHeaders: append([]string(nil), defaultCredentialHeaders...),
+		}
+		for _, opt := range opts {
+			opt(t)
+		}
+		if t.store == nil {
+			t.store = NewLRUStore(defaultCacheEntries)
+		}
+		return t
+	})
+}
+
+// cacheTransport is a caching RoundTripper.
+type cacheTransport struct {
+	next     http.RoundTripper
+	store    CacheStore
+	lookups  *prometheus.CounterVec
+	coalesce *dedupe.Group[string, []byte]
+	maxBody  int64
+
+	credentialHeaders []string
+}
+
+// coalesceHeaders are the request headers which must match for concurrent misses to be coalesced, besides the
+// credential ones already part of the cache key.
+var coalesceHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language"}
+
+// defaultCredentialHeaders are the request headers carrying credentials by default.
+var defaultCredentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// cacheEntry is what gets serialised in the store.
+type cacheEntry struct {
+	StoredAt time.Time         `json:"stored_at"`
+	Vary     map[string]string `json:"vary,omitempty"`
+	Response []byte            `json:"response"`
+}
+
+// RoundTrip serves the request from the cache when possible.
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || req.Header.Get("Range") != "" {
+		return t.next.RoundTrip(req)
+	}
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	key := t.cacheKey(req)
+	entry, cached := t.lookup(ctx, key, req)
+	if cached != nil {
+		_, noCache := reqCC["no-cache"]
+		if !noCache && isFresh(cached, entry.StoredAt) {
+			t.count("hit")
+			return cached, nil
+		}
+		if etag := cached.Header.Get("ETag"); etag != "" || cached.Header.Get("Last-Modified") != "" {
+			req = req.Clone(ctx)
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if lm := cached.Header.Get("Last-Modified"); lm != "" {
+				req.Header.Set("If-Modified-Since", lm)
+			}
+		}
+	}
+	if cached == nil && t.coalesce != nil {
+		return t.coalesced(req, key)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		t.count("revalidated")
+		resp.Body.Close()
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+		t.save(ctx, key, req, cached)
+		return cached, nil
+	}
+	t.count("miss")
+	if !isCacheable(resp) {
+		return resp, nil
+	}
+	return t.save(ctx, key, req, resp), nil
+}
+
+// errCoalesceTooLarge is returned to the callers sharing a response larger than the body size limit.
+var errCoalesceTooLarge = errors.New("response too large to be shared")
+
+// coalesced sends the request unless an identical one is in flight, sharing its response.
+// Responses larger than the body size limit are not shared: they are streamed to the caller which sent the
+// request, while the others send their own.
+func (t *cacheTransport) coalesced(req *http.Request, key string) (*http.Response, error) {
+	ckey := key
+	for _, h := range coalesceHeaders {
+		ckey += "\n" + req.Header.Get(h)
+	}
+	// own is the response handed to this caller when it is too large to be shared
+	var own *http.Response
+	dump, shared, err := t.coalesce.Do(req.Context(), ckey, func(ctx context.Context) ([]byte, error) {
+		// the response outlives the shared call when streamed, so it is bound to it only until then
+		rctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		stop := context.AfterFunc(ctx, cancel)
+		resp, err := t.next.RoundTrip(req.WithContext(rctx))
+		if err != nil {
+			stop()
+			cancel()
+			return nil, err
+		}
+		var body []byte
+		if resp.ContentLength <= t.maxBody {
+			body, err = io.ReadAll(io.LimitReader(resp.Body, t.maxBody+1))
+		}
+		if err == nil && (resp.ContentLength > t.maxBody || int64(len(body)) > t.maxBody) {
+			if !stop() {
+				// every caller has gone
+				resp.Body.Close()
+				return nil, ctx.Err()
+			}
+			context.AfterFunc(req.Context(), cancel)
+			resp.Body = &cancelOnClose{ReadCloser: struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}, cancel: cancel}
+			own = resp
+			return nil, errCoalesceTooLarge
+		}
+		resp.Body.Close()
+		stop()
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("could not read response: %w", err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		dump, err := httputil.DumpResponse(resp, true)
+		if err != nil {
+			return nil, fmt.Errorf("could not read response: %w", err)
+		}
+		if isCacheable(resp) {
+			t.persist(ctx, key, req, resp, dump)
+		}
+		return dump, nil
+	})
+	if errors.Is(err, errCoalesceTooLarge) {
+		t.count("miss")
+		if own != nil {
+			return own, nil
+		}
+		return t.next.RoundTrip(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		t.count("coalesced")
+	} else {
+		t.count("miss")
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+}
+
+func (t *cacheTransport) count(result string) {
+	if t.lookups != nil {
+		t.lookups.WithLabelValues(result).Inc()
+	}
+}
+
+// lookup returns the cached response for the request, if any.
+func (t *cacheTransport) lookup(ctx context.Context, key string, req *http.Request) (cacheEntry, *http.Response) {
+	var entry cacheEntry
+	raw, ok, err := t.store.Get(ctx, key)
+	if err != nil || !ok {
+		return entry, nil
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return entry, nil
+	}
+	for h, v := range entry.Vary {
+		if req.Header.Get(h) != v {
+			return entry, nil
+		}
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(entry.Response)), req)
+	if err != nil {
+		return entry, nil
+	}
+	return entry, resp
+}
+
+// save stores the response and returns an equivalent one whose body can still be read.
+// Responses whose body is larger than the limit are returned without being stored.
+func (t *cacheTransport) save(ctx context.Context, key string, req *http.Request, resp *http.Response) *http.Response {
+	if resp.ContentLength > t.maxBody {
+		return resp
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBody+1))
+	if err != nil || int64(len(body)) > t.maxBody {
+		// hand back what was read followed by the rest of the body
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return resp
+	}
+	t.persist(ctx, key, req, resp, dump)
+	return resp
+}
+
+// persist serialises the dump of the response in the store.
+func (t *cacheTransport) persist(ctx context.Context, key string, req *http.Request, resp *http.Response, dump []byte) {
+	entry := cacheEntry{
+		StoredAt: time.Now(),
+		Response: dump,
+	}
+	for _, v := range resp.Header.Values("Vary") {
+		for _, h := range strings.Split(v, ",") {
+			h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+			if entry.Vary == nil {
+				entry.Vary = make(map[string]string)
+			}
+			entry.Vary[h] = req.Header.Get(h)
+		}
+	}
+	raw, err := json.Marshal(entry)
+	if err == nil {
+		_ = t.store.Set(ctx, key, raw)
+	}
+}
+
+// cacheKey returns the key of the response to the request in input. It includes a hash of the credential
+// headers, if any, so that callers with different credentials never share entries.
+func (t *cacheTransport) cacheKey(req *http.Request) string {
+	key := req.Method + " " + req.URL.String()
+	h := sha256.New()
+	var found bool
+	for _, name := range t.credentialHeaders {
+		for _, v := range req.Header.Values(name) {
+			found = true
+			fmt.Fprintf(h, "%s: %s\n", name, v)
+		}
+	}
+	if found {
+		key += " " + hex.EncodeToString(h.Sum(nil))
+	}
+	return key
+}
+
+// isCacheable reports whether a response may be stored by a private cache.
+func isCacheable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusGone,
+		http.StatusRequestURITooLong, http.StatusNotImplemented:
+	default:
+		return false
+	}
+	if resp.Header.Get("Vary") == "*" {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	_, maxAge := cc["max-age"]
+	return maxAge ||
+		resp.Header.Get("Expires") != "" ||
+		resp.Header.Get("ETag") != "" ||
+		resp.Header.Get("Last-Modified") != ""
+}
+
+// isFresh reports whether a response stored at the time in input can be served without revalidation.
+func isFresh(resp *http.Response, storedAt time.Time) bool {
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+	age := time.Since(storedAt)
+	if a, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		age += time.Duration(a) * time.Second
+	}
+
+	var lifetime time.Duration
+	if v, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil {
+			return false
+		}
+		lifetime = time.Duration(secs) * time.Second
+	} else if expires := resp.Header.Get("Expires"); expires != "" {
+		exp, err := http.ParseTime(expires)
+		if err != nil {
+			return false
+		}
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			date = storedAt
+		}
+		lifetime = exp.Sub(date)
+	}
+	return age < lifetime
+}
+
+// parseCacheControl returns the Cache-Control directives, lower-cased, mapped to their values.
+func parseCacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(d, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
+
+// LRUStore is an in-memory CacheStore evicting the least recently used entries.
+type LRUStore struct {
+	cache *cache.Cache[string, []byte]
+}
+
+// compile time interface check.
+var _ CacheStore = &LRUStore{}
+
+// NewLRUStore returns an LRUStore holding up to maxEntries entries.
+func NewLRUStore(maxEntries int) *LRUStore {
+	return NewMemoryStore(cache.New[string, []byte](cache.WithMaxEntries(maxEntries)))
+}
+
+// NewMemoryStore returns an LRUStore backed by the cache in input, e.g. to share it or to expose its metrics.
+func NewMemoryStore(c *cache.Cache[string, []byte]) *LRUStore {
+	return &LRUStore{cache: c}
+}
+
+// Get returns the value stored for the key.
+func (s *LRUStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	value, ok := s.cache.Get(key)
+	return value, ok, nil
+}
+
+// Set stores the value for the key, evicting the least recently used entry if the store is full.
+func (s *LRUStore) Set(_ context.Context, key string, value []byte) error {
+	s.cache.Set(key, value)
+	return nil
+}
+
+// Delete removes the key from the store.
+func (s *LRUStore) Delete(_ context.Context, key string) error {
+	s.cache.Delete(key)
+	return nil
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheSeparatesCredentials(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		for _, h := range []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key", "X-Tenant-Token"} {
+			fmt.Fprint(w, r.Header.Get(h))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(WithCache(CacheCredentialHeaders("x-tenant-token")))
+	tests := []struct {
+		header    string
+		value     string
+		wantCalls int32
+	}{
+		{header: "Authorization", value: "Bearer alice", wantCalls: 1},
+		{header: "Authorization", value: "Bearer alice", wantCalls: 1},
+		{header: "Authorization", value: "Bearer bob", wantCalls: 2},
+		{wantCalls: 3},
+		{header: "Authorization", value: "Bearer bob", wantCalls: 3},
+		{header: "Cookie", value: "session=alice", wantCalls: 4},
+		{header: "Cookie", value: "session=bob", wantCalls: 5},
+		{header: "Cookie", value: "session=alice", wantCalls: 5},
+		{header: "Proxy-Authorization", value: "Basic YWxpY2U6", wantCalls: 6},
+		{header: "X-Api-Key", value: "alice", wantCalls: 7},
+		{header: "X-Api-Key", value: "bob", wantCalls: 8},
+		{header: "X-Tenant-Token", value: "alice", wantCalls: 9},
+		{header: "X-Tenant-Token", value: "bob", wantCalls: 10},
+	}
+	for i, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.value {
+			t.Fatalf("request %d: got body %q, want %q", i, body, tt.value)
+		}
+		if got := calls.Load(); got != tt.wantCalls {
+			t.Fatalf("request %d: got %d calls, want %d", i, got, tt.wantCalls)
+		}
+	}
+}
+
+func TestCacheCoalesceSeparatesCredentials(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 2 {
+			close(release)
+		}
+		select {
+		case <-release:
+		case <-time.After(time.Second):
+			// the requests were coalesced
+		}
+		fmt.Fprint(w, r.Header.Get("Cookie"))
+	}))
+	defer srv.Close()
+
+	c := New(WithCache(CacheCoalesce()))
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, cookie := range []string{"session=alice", "session=bob"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			req.Header.Set("Cookie", cookie)
+			resp, err := c.Do(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != cookie {
+				errs <- fmt.Errorf("got body %q, want %q", body, cookie)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheSkipsLargeBodies(t *testing.T) {
+	payload := strings.Repeat("x", 64)
+	tests := []struct {
+		name    string
+		opts    []CacheOption
+		chunked bool
+	}{
+		{name: "known length"},
+		{name: "unknown length", chunked: true},
+		{name: "coalesced known length", opts: []CacheOption{CacheCoalesce()}},
+		{name: "coalesced unknown length", opts: []CacheOption{CacheCoalesce()}, chunked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Cache-Control", "max-age=60")
+				if !tt.chunked {
+					w.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+				}
+				for i := 0; i < len(payload); i += 8 {
+					fmt.Fprint(w, payload[i:i+8])
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer srv.Close()
+
+			c := New(WithCache(append([]CacheOption{CacheMaxBodySize(16)}, tt.opts...)...))
+			get := func() error {
+				resp, err := c.Get(srv.URL)
+				if err != nil {
+					return err
+				}
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					return err
+				}
+				if string(body) != payload {
+					return fmt.Errorf("got body %q, want %q", body, payload)
+				}
+				return nil
+			}
+			for i := 0; i < 2; i++ {
+				if err := get(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := calls.Load(); got != 2 {
+				t.Fatalf("got %d calls, want 2", got)
+			}
+
+			// concurrent callers all get the whole body, whether they sent the request or not
+			var wg sync.WaitGroup
+			errs := make(chan error, 5)
+			for i := 0; i < cap(errs); i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- get()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/indiependente/pkg/circuitbreaker"
+)
+
+// WithCircuitBreaker sends requests through a breaker per host taken from the registry in input.
+// Transport errors and 5xx responses count as failures; rejected requests fail with circuitbreaker.ErrOpen
+// or circuitbreaker.ErrTooManyProbes.
+func WithCircuitBreaker(registry *circuitbreaker.Registry[*http.Response]) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var resp *http.Response
+			_, err := registry.Get(req.URL.Host).Execute(req.Context(), func(context.Context) (*http.Response, error) {
+				var err error
+				resp, err = next.RoundTrip(req)
+				if err != nil {
+					return nil, err
+				}
+				if resp.StatusCode >= http.StatusInternalServerError {
+					return resp, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header}
+				}
+				return resp, nil
+			})
+			if resp != nil {
+				// server errors count as failures but are returned to the caller as responses
+				return resp, nil
+			}
+			return nil, err
+		})
+	})
+}
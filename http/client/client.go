@@ -8,17 +8,30 @@ import (
 
 // DefaultHTTPClient - default http client
 func DefaultHTTPClient(maxWorkers int) *http.Client {
-	return &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   90 * time.Second,
-				KeepAlive: 60 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          128,
-			MaxIdleConnsPerHost:   maxWorkers + 1,   // one more than needed
-			IdleConnTimeout:       90 * time.Second, // from DefaultTransport
-			TLSHandshakeTimeout:   10 * time.Second, // from DefaultTransport
-			ExpectContinueTimeout: 1 * time.Second,  // from DefaultTransport
-		},
+	return New(WithMaxWorkers(maxWorkers))
+}
+
+// New returns an http client built on top of the default transport and customised by the options in input.
+func New(opts ...Option) *http.Client {
+	cfg := newConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg.client()
+}
+
+func defaultTransport(maxWorkers int) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   90 * time.Second,
+			KeepAlive: 60 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true, // from DefaultTransport
+		MaxIdleConns:          128,
+		MaxIdleConnsPerHost:   maxWorkers + 1,   // one more than needed
+		IdleConnTimeout:       90 * time.Second, // from DefaultTransport
+		TLSHandshakeTimeout:   10 * time.Second, // from DefaultTransport
+		ExpectContinueTimeout: 1 * time.Second,  // from DefaultTransport
 	}
 }
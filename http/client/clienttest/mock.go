@@ -0,0 +1,185 @@
+// Package clienttest provides transports to test code built on top of the client package without running servers.
+package clienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// MockTransport is an http.RoundTripper answering requests with canned responses.
+// Requests not matching any route fail with an error.
+type MockTransport struct {
+	mu     sync.Mutex
+	routes []*Route
+}
+
+// compile time interface check.
+var _ http.RoundTripper = &MockTransport{}
+
+// NewMockTransport returns a MockTransport without routes.
+func NewMockTransport() *MockTransport {
+	return &MockTransport{}
+}
+
+// Client returns an http client using the mock transport.
+func (m *MockTransport) Client() *http.Client {
+	return &http.Client{Transport: m}
+}
+
+// On registers a route answering requests with the method and URL in input.
+// The URL matches the full request URL, or its path and query if it does not carry a host.
+// Routes respond with 200 OK and no body unless configured otherwise.
+func (m *MockTransport) On(method, url string) *Route {
+	r := &Route{
+		method: method,
+		url:    url,
+		status: http.StatusOK,
+		header: http.Header{},
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes = append(m.routes, r)
+	return r
+}
+
+// RoundTrip answers the request with the response of the first matching route.
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	var route *Route
+	for _, r := range m.routes {
+		if r.matches(req) {
+			route = r
+			break
+		}
+	}
+	m.mu.Unlock()
+	if route == nil {
+		return nil, fmt.Errorf("no route for %s %s", req.Method, req.URL)
+	}
+	return route.serve(req)
+}
+
+// AssertCalled fails the test if the route for method and url has not been called exactly times times.
+func (m *MockTransport) AssertCalled(t testing.TB, method, url string, times int) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.routes {
+		if r.method == method && r.url == url {
+			if got := r.CallCount(); got != times {
+				t.Errorf("%s %s: expected %d calls, got %d", method, url, times, got)
+			}
+			return
+		}
+	}
+	t.Errorf("%s %s: no such route", method, url)
+}
+
+// Call is a request served by a route.
+type Call struct {
+	Request *http.Request
+	Body    []byte
+}
+
+// Route is a canned response for the requests matching a method and URL.
+type Route struct {
+	method string
+	url    string
+	status int
+	header http.Header
+	body   []byte
+	err    error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Respond sets the status code and body of the response.
+func (r *Route) Respond(status int, body string) *Route {
+	r.status = status
+	r.body = []byte(body)
+	return r
+}
+
+// RespondJSON sets the status code of the response and its body to v encoded as JSON.
+func (r *Route) RespondJSON(status int, v interface{}) *Route {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("could not encode response body: %v", err))
+	}
+	r.status = status
+	r.body = b
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// RespondError makes the route fail requests with the error in input.
+func (r *Route) RespondError(err error) *Route {
+	r.err = err
+	return r
+}
+
+// WithHeader adds the header to the response.
+func (r *Route) WithHeader(key, value string) *Route {
+	r.header.Add(key, value)
+	return r
+}
+
+// CallCount returns how many requests the route served.
+func (r *Route) CallCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+// Calls returns the requests the route served, along with their bodies.
+func (r *Route) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+func (r *Route) matches(req *http.Request) bool {
+	if r.method != req.Method {
+		return false
+	}
+	if r.url == req.URL.String() {
+		return true
+	}
+	return r.url == req.URL.RequestURI()
+}
+
+func (r *Route) serve(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not read request body: %w", err)
+		}
+		req.Body.Close()
+		body = b
+	}
+	r.mu.Lock()
+	r.calls = append(r.calls, Call{Request: req, Body: body})
+	r.mu.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package clienttest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are redacted in the golden files, as they are by the logging middleware.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// Mode tells a Recorder whether to record real interactions or replay recorded ones.
+type Mode int
+
+const (
+	// ModeReplay serves requests from the golden files.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the real transport and stores the interactions in golden files.
+	ModeRecord
+)
+
+// Recorder is an http.RoundTripper that records interactions to golden files and replays them.
+type Recorder struct {
+	dir  string
+	mode Mode
+	next http.RoundTripper
+}
+
+// compile time interface check.
+var _ http.RoundTripper = &Recorder{}
+
+// NewRecorder returns a Recorder storing golden files in dir.
+// In record mode requests are sent with next, http.DefaultTransport if nil.
+func NewRecorder(dir string, mode Mode, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{dir: dir, mode: mode, next: next}
+}
+
+// Client returns an http client using the recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// interaction is the content of a golden file. Bodies are stored as base64, so that binary payloads are preserved.
+type interaction struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody []byte      `json:"request_body,omitempty"`
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// RoundTrip records or replays the request depending on the recorder mode.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not read request body: %w", err)
+		}
+		req.Body.Close()
+		reqBody = b
+	}
+	path := r.goldenFile(req, reqBody)
+	if r.mode == ModeReplay {
+		return r.replay(req, path)
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(reqBody))
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response body: %w", err)
+	}
+	in := interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: reqBody,
+		StatusCode:  resp.StatusCode,
+		Header:      redactHeaders(resp.Header),
+		Body:        body,
+	}
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not encode interaction: %w", err)
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create golden files directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("could not write golden file: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, path string) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read golden file for %s %s: %w", req.Method, req.URL, err)
+	}
+	var in interaction
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("could not decode golden file %s: %w", path, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Header,
+		Body:          io.NopCloser(bytes.NewReader(in.Body)),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}, nil
+}
+
+// redactHeaders returns a copy of the headers with the sensitive ones redacted.
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, k := range sensitiveHeaders {
+		if _, ok := out[k]; ok {
+			out[k] = []string{redacted}
+		}
+	}
+	return out
+}
+
+// goldenFile returns the path of the golden file for the request, derived from its method, URL and body.
+func (r *Recorder) goldenFile(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.String() + "\n"))
+	h.Write(body)
+	return filepath.Join(r.dir, strings.ToLower(req.Method)+"_"+hex.EncodeToString(h.Sum(nil))[:16]+".json")
+}
@@ -0,0 +1,266 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConnTrace holds the duration of the connection phases of a request.
+// Phases that did not happen, e.g. because the connection was reused, have a zero duration.
+type ConnTrace struct {
+	Host            string
+	Reused          bool
+	DNS             time.Duration
+	Connect         time.Duration
+	TLSHandshake    time.Duration
+	TimeToFirstByte time.Duration
+}
+
+// ConnTraceSink receives the connection trace of each request.
+type ConnTraceSink func(req *http.Request, ct ConnTrace)
+
+// WithConnTrace traces the connection phases of every request via httptrace and reports them to the sinks in input.
+func WithConnTrace(sinks ...ConnTraceSink) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var (
+				ct                            = ConnTrace{Host: req.URL.Host}
+				start                         = time.Now()
+				dnsStart, connStart, tlsStart time.Time
+				mu                            sync.Mutex
+			)
+			trace := &httptrace.ClientTrace{
+				DNSStart: func(httptrace.DNSStartInfo) {
+					mu.Lock()
+					dnsStart = time.Now()
+					mu.Unlock()
+				},
+				DNSDone: func(httptrace.DNSDoneInfo) {
+					mu.Lock()
+					ct.DNS = time.Since(dnsStart)
+					mu.Unlock()
+				},
+				ConnectStart: func(string, string) {
+					mu.Lock()
+					connStart = time.Now()
+					mu.Unlock()
+				},
+				ConnectDone: func(string, string, error) {
+					mu.Lock()
+					ct.Connect = time.Since(connStart)
+					mu.Unlock()
+				},
+				TLSHandshakeStart: func() {
+					mu.Lock()
+					tlsStart = time.Now()
+					mu.Unlock()
+				},
+				TLSHandshakeDone: func(tls.ConnectionState, error) {
+					mu.Lock()
+					ct.TLSHandshake = time.Since(tlsStart)
+					mu.Unlock()
+				},
+				GotConn: func(info httptrace.GotConnInfo) {
+					mu.Lock()
+					ct.Reused = info.Reused
+					mu.Unlock()
+				},
+				GotFirstResponseByte: func() {
+					mu.Lock()
+					ct.TimeToFirstByte = time.Since(start)
+					mu.Unlock()
+				},
+			}
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+			resp, err := next.RoundTrip(req)
+			mu.Lock()
+			result := ct
+			mu.Unlock()
+			for _, sink := range sinks {
+				sink(req, result)
+			}
+			return resp, err
+		})
+	})
+}
+
+// LogConnTrace logs the connection phases at debug level.
+func LogConnTrace(log logger.Logger) ConnTraceSink {
+	return func(req *http.Request, ct ConnTrace) {
+		l := log.Event("http_client_trace").Method(req.Method).URI(req.URL.String()).Host(ct.Host)
+		if ct.Reused {
+			l.Debug("Reused connection")
+		}
+		if ct.DNS > 0 {
+			l.Duration(ct.DNS).Debug("DNS lookup")
+		}
+		if ct.Connect > 0 {
+			l.Duration(ct.Connect).Debug("Connect")
+		}
+		if ct.TLSHandshake > 0 {
+			l.Duration(ct.TLSHandshake).Debug("TLS handshake")
+		}
+		if ct.TimeToFirstByte > 0 {
+			l.Duration(ct.TimeToFirstByte).Debug("Time to first byte")
+		}
+	}
+}
+
+// ConnTraceMetrics observes the connection phases in a histogram labeled by host and phase.
+func ConnTraceMetrics(registerer prometheus.Registerer) ConnTraceSink {
+	phases := metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_client_phase_duration_seconds",
+		Help:    "Duration of the connection phases of outgoing HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"host", "phase"}))
+	return func(_ *http.Request, ct ConnTrace) {
+		observe := func(phase string, d time.Duration) {
+			if d > 0 {
+				phases.WithLabelValues(ct.Host, phase).Observe(d.Seconds())
+			}
+		}
+		observe("dns", ct.DNS)
+		observe("connect", ct.Connect)
+		observe("tls", ct.TLSHandshake)
+		observe("ttfb", ct.TimeToFirstByte)
+	}
+}
+
+// HostStats describes the connections to a host.
+type HostStats struct {
+	// Open is the number of open connections.
+	Open int
+	// Active is the number of requests waiting for a response or whose body has not been closed yet.
+	Active int
+	// Idle is the number of open connections estimated not to be serving any request.
+	Idle int
+}
+
+// ConnTracker keeps track of the connections opened by the clients it is attached to.
+type ConnTracker struct {
+	mu     sync.Mutex
+	open   map[string]int
+	active map[string]int
+}
+
+// NewConnTracker returns an empty ConnTracker.
+func NewConnTracker() *ConnTracker {
+	return &ConnTracker{
+		open:   make(map[string]int),
+		active: make(map[string]int),
+	}
+}
+
+// WithConnTracker records the connections and in-flight requests of the client in the tracker in input.
+func WithConnTracker(tracker *ConnTracker) Option {
+	return func(c *config) {
+		withTransport(func(t *http.Transport) {
+			t.DialContext = tracker.dialer(t.DialContext)
+		})(c)
+		WithMiddleware(tracker.middleware)(c)
+	}
+}
+
+// Stats returns a snapshot of the connections per host, keyed by host:port.
+func (ct *ConnTracker) Stats() map[string]HostStats {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	stats := make(map[string]HostStats, len(ct.open))
+	for host, open := range ct.open {
+		s := HostStats{Open: open, Active: ct.active[host]}
+		if s.Open > s.Active {
+			s.Idle = s.Open - s.Active
+		}
+		stats[host] = s
+	}
+	for host, active := range ct.active {
+		if _, ok := stats[host]; !ok {
+			stats[host] = HostStats{Active: active}
+		}
+	}
+	return stats
+}
+
+func (ct *ConnTracker) add(m map[string]int, host string, delta int) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	m[host] += delta
+	if m[host] <= 0 {
+		delete(m, host)
+	}
+}
+
+func (ct *ConnTracker) dialer(dial DialContextFunc) DialContextFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		ct.add(ct.open, addr, 1)
+		return &trackedConn{Conn: conn, onClose: func() { ct.add(ct.open, addr, -1) }}, nil
+	}
+}
+
+func (ct *ConnTracker) middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := canonicalAddr(req)
+		ct.add(ct.active, host, 1)
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			ct.add(ct.active, host, -1)
+			return nil, err
+		}
+		var once sync.Once
+		resp.Body = &onCloseBody{ReadCloser: resp.Body, onClose: func() {
+			once.Do(func() { ct.add(ct.active, host, -1) })
+		}}
+		return resp, nil
+	})
+}
+
+// canonicalAddr returns the host:port the request is sent to.
+func canonicalAddr(req *http.Request) string {
+	if req.URL.Port() != "" {
+		return req.URL.Host
+	}
+	port := "80"
+	if req.URL.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(req.URL.Hostname(), port)
+}
+
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
+
+type onCloseBody struct {
+	io.ReadCloser
+	onClose func()
+}
+
+func (b *onCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.onClose()
+	return err
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeadlineHeader carries the time left to the caller, in milliseconds, to the downstream services,
+// honoured by the timeout middleware with middleware.WithPropagatedDeadline.
+const DeadlineHeader = "X-Request-Timeout"
+
+// WithDeadlinePropagation caps the timeout of every request to the time left before the deadline of its context,
+// e.g. the one set on the incoming request by the timeout middleware, minus margin, keeping time for the caller to
+// answer once the downstream call fails. The time left is sent in the DeadlineHeader header, so that the downstream
+// services stop working on requests nobody waits for anymore. Requests are not sent, failing with
+// context.DeadlineExceeded, if no time is left. Requests whose context has no deadline are sent as is.
+func WithDeadlinePropagation(margin time.Duration) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			deadline, ok := req.Context().Deadline()
+			if !ok {
+				return next.RoundTrip(req)
+			}
+			remaining := time.Until(deadline) - margin
+			if remaining <= 0 {
+				return nil, fmt.Errorf("no time left to send the request before the caller deadline: %w", context.DeadlineExceeded)
+			}
+			ctx, cancel := context.WithTimeout(req.Context(), remaining)
+			req = req.Clone(ctx)
+			req.Header.Set(DeadlineHeader, strconv.FormatInt(remaining.Milliseconds(), 10))
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// DialContextFunc dials a connection to the address on the named network.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// WithDialContext opens connections with the dial function in input, e.g. a SOCKS5 or tunnel dialer,
+// instead of the default TCP dialer.
+func WithDialContext(dial DialContextFunc) Option {
+	return func(c *config) {
+		c.dial = dial
+	}
+}
+
+// NewUnixSocket returns an http client sending every request over the Unix domain socket at path,
+// regardless of the host in the request URL.
+func NewUnixSocket(path string, opts ...Option) *http.Client {
+	var d net.Dialer
+	opts = append([]Option{
+		WithDialContext(func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", path)
+		}),
+		withTransport(func(t *http.Transport) {
+			t.Proxy = nil
+		}),
+	}, opts...)
+	return New(opts...)
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/indiependente/pkg/cache"
+	"github.com/indiependente/pkg/clock"
+)
+
+// defaultDNSCacheHosts is the default maximum number of hosts whose addresses are cached.
+const defaultDNSCacheHosts = 1024
+
+// DNSCacheOption customises the DNS cache.
+type DNSCacheOption func(*dnsCacheConfig)
+
+type dnsCacheConfig struct {
+	ttl               time.Duration
+	staleWhileRefresh bool
+	maxStale          time.Duration
+	maxHosts          int
+	resolver          *net.Resolver
+	clock             clock.Clock
+}
+
+// StaleWhileRefresh serves expired entries while they are refreshed in background,
+// keeping resolver latency out of the request path. Entries are served stale for at most WithMaxStale.
+func StaleWhileRefresh() DNSCacheOption {
+	return func(c *dnsCacheConfig) {
+		c.staleWhileRefresh = true
+	}
+}
+
+// WithMaxStale sets how long after their expiration entries are still served with StaleWhileRefresh.
+// Defaults to the ttl of the cache.
+func WithMaxStale(d time.Duration) DNSCacheOption {
+	return func(c *dnsCacheConfig) {
+		c.maxStale = d
+	}
+}
+
+// WithMaxHosts evicts the least recently used hosts once the cache holds more than n of them. Defaults to 1024.
+func WithMaxHosts(n int) DNSCacheOption {
+	return func(c *dnsCacheConfig) {
+		c.maxHosts = n
+	}
+}
+
+// WithResolver resolves hosts with the resolver in input instead of the default one.
+func WithResolver(r *net.Resolver) DNSCacheOption {
+	return func(c *dnsCacheConfig) {
+		c.resolver = r
+	}
+}
+
+// WithDNSCache caches the addresses hosts resolve to for ttl and spreads connections across them in round-robin order.
+// The resolver does not expose the TTLs of the records, so ttl is an upper bound on how long addresses are reused;
+// the addresses of a host are dropped as soon as none of them can be dialed.
+func WithDNSCache(ttl time.Duration, opts ...DNSCacheOption) Option {
+	c := newDNSCache(ttl, opts...)
+	return withTransport(func(t *http.Transport) {
+		t.DialContext = c.dialer(t.DialContext)
+	})
+}
+
+type dnsCache struct {
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	entries    *cache.Cache[string, *dnsEntry]
+}
+
+type dnsEntry struct {
+	addrs []string
+	next  atomic.Uint64
+}
+
+func newDNSCache(ttl time.Duration, opts ...DNSCacheOption) *dnsCache {
+	cfg := &dnsCacheConfig{
+		ttl:      ttl,
+		maxStale: ttl,
+		maxHosts: defaultDNSCacheHosts,
+		resolver: net.DefaultResolver,
+		clock:    clock.New(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cacheOpts := []cache.Option{cache.WithMaxEntries(cfg.maxHosts), cache.WithTTL(cfg.ttl), cache.WithClock(cfg.clock)}
+	if cfg.staleWhileRefresh {
+		cacheOpts = append(cacheOpts, cache.WithStaleWhileRevalidate(cfg.maxStale))
+	}
+	return &dnsCache{
+		lookupHost: cfg.resolver.LookupHost,
+		entries:    cache.New[string, *dnsEntry](cacheOpts...),
+	}
+}
+
+// dialer returns a DialContextFunc resolving hosts through the cache and dialing with the function in input.
+func (c *dnsCache) dialer(dial DialContextFunc) DialContextFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if ctx.Err() == nil {
+			// none of the addresses is reachable: resolve the host again on the next dial
+			c.entries.Delete(host)
+		}
+		return nil, lastErr
+	}
+}
+
+// lookup returns the addresses of the host, rotated so that consecutive calls start from a different one.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	e, err := c.entries.GetOrLoad(ctx, host, c.resolve)
+	if err != nil {
+		return nil, err
+	}
+	return e.rotate(), nil
+}
+
+// resolve looks the host up.
+func (c *dnsCache) resolve(ctx context.Context, host string) (*dnsEntry, error) {
+	addrs, err := c.lookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve %s: %w", host, err)
+	}
+	return &dnsEntry{addrs: addrs}, nil
+}
+
+// rotate returns the addresses starting from the next one in round-robin order.
+func (e *dnsEntry) rotate() []string {
+	n := len(e.addrs)
+	if n == 0 {
+		return nil
+	}
+	start := int((e.next.Add(1) - 1) % uint64(n))
+	out := make([]string, 0, n)
+	out = append(out, e.addrs[start:]...)
+	return append(out, e.addrs[:start]...)
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+func TestDNSCache(t *testing.T) {
+	type dial struct {
+		host    string
+		advance time.Duration
+		fail    bool
+	}
+	tests := []struct {
+		name        string
+		opts        []DNSCacheOption
+		dials       []dial
+		wantLookups int
+		// refreshing allows one more lookup, made in the background to refresh a stale entry
+		refreshing bool
+	}{
+		{
+			name:        "cached",
+			dials:       []dial{{host: "a"}, {host: "a"}, {host: "a"}},
+			wantLookups: 1,
+		},
+		{
+			name:        "expired",
+			dials:       []dial{{host: "a"}, {host: "a", advance: 2 * time.Minute}},
+			wantLookups: 2,
+		},
+		{
+			name:        "least recently used host evicted",
+			opts:        []DNSCacheOption{WithMaxHosts(2)},
+			dials:       []dial{{host: "a"}, {host: "b"}, {host: "c"}, {host: "b"}, {host: "a"}},
+			wantLookups: 4,
+		},
+		{
+			name:        "evicted on dial failure",
+			dials:       []dial{{host: "a", fail: true}, {host: "a"}, {host: "a"}},
+			wantLookups: 2,
+		},
+		{
+			name:        "stale entry served",
+			opts:        []DNSCacheOption{StaleWhileRefresh(), WithMaxStale(time.Minute)},
+			dials:       []dial{{host: "a"}, {host: "a", advance: 90 * time.Second}},
+			wantLookups: 1,
+			refreshing:  true,
+		},
+		{
+			name:        "stale entry expired",
+			opts:        []DNSCacheOption{StaleWhileRefresh(), WithMaxStale(time.Minute)},
+			dials:       []dial{{host: "a"}, {host: "a", advance: 3 * time.Minute}},
+			wantLookups: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clock.NewFake(time.Now())
+			opts := append([]DNSCacheOption{func(c *dnsCacheConfig) { c.clock = fake }}, tt.opts...)
+			c := newDNSCache(time.Minute, opts...)
+			var (
+				mu      sync.Mutex
+				lookups int
+			)
+			c.lookupHost = func(_ context.Context, host string) ([]string, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				lookups++
+				return []string{"10.0.0.1"}, nil
+			}
+			var fail bool
+			dialer := c.dialer(func(_ context.Context, _, addr string) (net.Conn, error) {
+				if fail {
+					return nil, errors.New("connection refused")
+				}
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			})
+			for _, d := range tt.dials {
+				fake.Advance(d.advance)
+				fail = d.fail
+				conn, err := dialer(context.Background(), "tcp", net.JoinHostPort(d.host, "80"))
+				if (err != nil) != d.fail {
+					t.Fatalf("dial %s: error = %v, want failure %v", d.host, err, d.fail)
+				}
+				if conn != nil {
+					_ = conn.Close()
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if lookups != tt.wantLookups && !(tt.refreshing && lookups == tt.wantLookups+1) {
+				t.Fatalf("got %d lookups, want %d", lookups, tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestDNSCacheRoundRobin(t *testing.T) {
+	c := newDNSCache(time.Minute)
+	c.lookupHost = func(context.Context, string) ([]string, error) {
+		return []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, nil
+	}
+	var got []string
+	for i := 0; i < 4; i++ {
+		addrs, err := c.lookup(context.Background(), "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, addrs[0])
+	}
+	want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got first addresses %v, want %v", got, want)
+		}
+	}
+}
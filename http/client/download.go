@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/indiependente/pkg/filex"
+	"github.com/indiependente/pkg/streamio"
+)
+
+const (
+	// partialSuffix is appended to the destination path while the download is in progress.
+	partialSuffix = ".part"
+	// validatorSuffix is appended to the partial file path to store the validator of the content being downloaded.
+	validatorSuffix = ".validator"
+)
+
+// ProgressFunc is called while downloading with the bytes written so far and the total size, -1 if unknown.
+type ProgressFunc func(written, total int64)
+
+// DownloadOption customises Client.Download.
+type DownloadOption func(*downloadConfig)
+
+type downloadConfig struct {
+	checksum  string
+	progress  ProgressFunc
+	bandwidth int
+	noResume  bool
+}
+
+// WithSHA256 verifies the downloaded file against the hex encoded SHA-256 checksum in input.
+func WithSHA256(checksum string) DownloadOption {
+	return func(dc *downloadConfig) {
+		dc.checksum = strings.ToLower(checksum)
+	}
+}
+
+// WithProgress reports the download progress to the function in input.
+func WithProgress(fn ProgressFunc) DownloadOption {
+	return func(dc *downloadConfig) {
+		dc.progress = fn
+	}
+}
+
+// WithBandwidthLimit limits the download speed to bytesPerSecond.
+func WithBandwidthLimit(bytesPerSecond int) DownloadOption {
+	return func(dc *downloadConfig) {
+		dc.bandwidth = bytesPerSecond
+	}
+}
+
+// WithoutResume always downloads the whole file, discarding any partial download left by a previous attempt.
+func WithoutResume() DownloadOption {
+	return func(dc *downloadConfig) {
+		dc.noResume = true
+	}
+}
+
+// Download saves the resource at url to dest.
+// The content is written to a partial file, resumed with a Range request if a previous attempt left one behind,
+// and atomically renamed to dest once complete and verified.
+// A download is resumed only if the server sent a strong ETag or a Last-Modified date, which is sent back in
+// If-Range so that a resource changed in the meantime is downloaded again from the start.
+func (c *Client) Download(ctx context.Context, url, dest string, opts ...DownloadOption) error {
+	dc := &downloadConfig{}
+	for _, opt := range opts {
+		opt(dc)
+	}
+
+	part := dest + partialSuffix
+	var (
+		offset    int64
+		validator string
+	)
+	if !dc.noResume {
+		offset, validator = partialState(part)
+	}
+	resp, err := c.downloadRequest(ctx, url, offset, validator)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		resp.Body.Close()
+		if total, ok := unsatisfiedRangeTotal(resp.Header.Get("Content-Range")); ok && total == offset {
+			// the partial file already holds the whole content
+			return finishDownload(part, dest, dc.checksum)
+		}
+		offset = 0
+		if resp, err = c.downloadRequest(ctx, url, 0, ""); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			_ = os.Remove(part)
+			_ = os.Remove(part + validatorSuffix)
+			return fmt.Errorf("could not resume download: unexpected Content-Range %q at offset %d",
+				resp.Header.Get("Content-Range"), offset)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		flags |= os.O_TRUNC
+		offset = 0
+		if err := saveValidator(part, resp.Header); err != nil {
+			return err
+		}
+	default:
+		return newHTTPError(resp)
+	}
+
+	f, err := os.OpenFile(part, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open partial file: %w", err)
+	}
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	w := &progressWriter{w: f, written: offset, total: total, progress: dc.progress}
+	var body io.Reader = resp.Body
+	if dc.bandwidth > 0 {
+		body = streamio.NewRateLimitedReader(ctx, body, dc.bandwidth)
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		f.Close()
+		return fmt.Errorf("could not download: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("could not sync partial file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close partial file: %w", err)
+	}
+	return finishDownload(part, dest, dc.checksum)
+}
+
+// downloadRequest requests the content from offset, if the resource still matches the validator.
+func (c *Client) downloadRequest(ctx context.Context, url string, offset int64, validator string) (*http.Response, error) {
+	var reqOpts []RequestOption
+	if offset > 0 {
+		reqOpts = append(reqOpts,
+			WithHeader("Range", "bytes="+strconv.FormatInt(offset, 10)+"-"),
+			WithHeader("If-Range", validator),
+		)
+	}
+	return c.Do(ctx, http.MethodGet, url, reqOpts...)
+}
+
+// partialState returns the size of the partial file and the validator of its content.
+// A partial file without validator cannot be resumed safely, so its size is reported as 0.
+func partialState(part string) (int64, string) {
+	fi, err := os.Stat(part)
+	if err != nil {
+		return 0, ""
+	}
+	validator, err := os.ReadFile(part + validatorSuffix)
+	if err != nil || len(validator) == 0 {
+		return 0, ""
+	}
+	return fi.Size(), string(validator)
+}
+
+// saveValidator stores the strong ETag or the Last-Modified date of the response next to the partial file,
+// removing any previous one if the response has neither.
+func saveValidator(part string, h http.Header) error {
+	validator := h.Get("ETag")
+	if strings.HasPrefix(validator, "W/") {
+		// weak validators cannot be used in If-Range
+		validator = ""
+	}
+	if validator == "" {
+		validator = h.Get("Last-Modified")
+	}
+	if validator == "" {
+		if err := os.Remove(part + validatorSuffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove download validator: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(part+validatorSuffix, []byte(validator), 0o644); err != nil {
+		return fmt.Errorf("could not store download validator: %w", err)
+	}
+	return nil
+}
+
+// contentRangeStart returns the first byte position of a Content-Range header, e.g. "bytes 100-199/200".
+func contentRangeStart(cr string) (int64, bool) {
+	rng, ok := strings.CutPrefix(cr, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	return start, err == nil
+}
+
+// unsatisfiedRangeTotal returns the complete length of an unsatisfied Content-Range header, e.g. "bytes */200".
+func unsatisfiedRangeTotal(cr string) (int64, bool) {
+	total, ok := strings.CutPrefix(cr, "bytes */")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	return n, err == nil
+}
+
+// finishDownload verifies the partial file and renames it to its destination.
+func finishDownload(part, dest, checksum string) error {
+	if checksum != "" {
+		if err := filex.VerifySHA256(part, checksum); err != nil {
+			_ = os.Remove(part)
+			_ = os.Remove(part + validatorSuffix)
+			return err
+		}
+	}
+	if err := filex.Rename(part, dest); err != nil {
+		return err
+	}
+	_ = os.Remove(part + validatorSuffix)
+	return nil
+}
+
+// progressWriter reports the bytes written to the progress function.
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	if pw.progress != nil {
+		pw.progress(pw.written, pw.total)
+	}
+	return n, err
+}
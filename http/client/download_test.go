@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	tests := []struct {
+		name      string
+		partial   []byte
+		validator string
+		etag      string
+		wantRange bool
+	}{
+		{
+			name:      "no partial file",
+			etag:      `"v1"`,
+			wantRange: false,
+		},
+		{
+			name:      "resume",
+			partial:   content[:8],
+			validator: `"v1"`,
+			etag:      `"v1"`,
+			wantRange: true,
+		},
+		{
+			name:      "resource changed",
+			partial:   []byte("XXXXXXXX"),
+			validator: `"v0"`,
+			etag:      `"v1"`,
+			wantRange: true,
+		},
+		{
+			name:      "partial file without validator",
+			partial:   []byte("XXXXXXXX"),
+			etag:      `"v1"`,
+			wantRange: false,
+		},
+		{
+			name:      "partial file already complete",
+			partial:   content,
+			validator: `"v1"`,
+			etag:      `"v1"`,
+			wantRange: true,
+		},
+		{
+			name:      "partial file longer than the resource",
+			partial:   append(append([]byte{}, content...), "XXXX"...),
+			validator: `"v1"`,
+			etag:      `"v1"`,
+			wantRange: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRange bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRange = gotRange || r.Header.Get("Range") != ""
+				w.Header().Set("ETag", tt.etag)
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}))
+			defer srv.Close()
+
+			dest := filepath.Join(t.TempDir(), "file")
+			part := dest + partialSuffix
+			if tt.partial != nil {
+				if err := os.WriteFile(part, tt.partial, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.validator != "" {
+				if err := os.WriteFile(part+validatorSuffix, []byte(tt.validator), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := NewClient().Download(context.Background(), srv.URL, dest); err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			got, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Fatalf("got %q, want %q", got, content)
+			}
+			if gotRange != tt.wantRange {
+				t.Fatalf("sent Range = %v, want %v", gotRange, tt.wantRange)
+			}
+			for _, leftover := range []string{part, part + validatorSuffix} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Fatalf("%s was not removed", leftover)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadRejectsMisplacedRange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes 0-9/20")
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "file")
+	part := dest + partialSuffix
+	if err := os.WriteFile(part, []byte("01234"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(part+validatorSuffix, []byte(`"v1"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewClient().Download(context.Background(), srv.URL, dest); err == nil {
+		t.Fatal("Download() appended a range which does not start at the end of the partial file")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatal("destination file was created")
+	}
+}
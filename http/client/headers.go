@@ -0,0 +1,35 @@
+package client
+
+import (
+	"net/http"
+)
+
+// WithUserAgent sets the User-Agent header of requests which do not set it themselves.
+func WithUserAgent(ua string) Option {
+	return WithDefaultHeaders(http.Header{"User-Agent": {ua}})
+}
+
+// WithDefaultHeaders adds the headers in input to every request.
+// Headers already set on a request take precedence over the defaults.
+func WithDefaultHeaders(h http.Header) Option {
+	defaults := make(http.Header, len(h))
+	for k, vs := range h {
+		defaults[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+	}
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var cloned bool
+			for k, vs := range defaults {
+				if _, ok := req.Header[k]; ok {
+					continue
+				}
+				if !cloned {
+					req = req.Clone(req.Context())
+					cloned = true
+				}
+				req.Header[k] = append([]string(nil), vs...)
+			}
+			return next.RoundTrip(req)
+		})
+	})
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HedgingOption customises the hedging transport.
+type HedgingOption func(*hedgingTransport)
+
+// HedgeHosts sends hedged requests to the hosts in input, in round-robin order, instead of the original one.
+func HedgeHosts(hosts ...string) HedgingOption {
+	return func(t *hedgingTransport) {
+		t.hosts = hosts
+	}
+}
+
+// WithHedging sends up to maxHedges duplicates of an idempotent request, one every delay,
+// while no response has been received yet.
+// The first successful response wins and the other attempts are cancelled.
+func WithHedging(delay time.Duration, maxHedges int, opts ...HedgingOption) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		t := &hedgingTransport{
+			next:      next,
+			delay:     delay,
+			maxHedges: maxHedges,
+		}
+		for _, opt := range opts {
+			opt(t)
+		}
+		return t
+	})
+}
+
+// hedgingTransport is a RoundTripper sending hedged requests.
+type hedgingTransport struct {
+	next      http.RoundTripper
+	delay     time.Duration
+	maxHedges int
+	hosts     []string
+}
+
+type attemptResult struct {
+	resp    *http.Response
+	err     error
+	attempt int
+}
+
+// RoundTrip sends the request, hedging it if it is idempotent and its body can be replayed.
+func (t *hedgingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.maxHedges <= 0 || !isIdempotent(req.Method) || !canReplay(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	var (
+		ctx      = req.Context()
+		results  = make(chan attemptResult, t.maxHedges+1)
+		cancels  []context.CancelFunc
+		launched int
+		pending  int
+		lastErr  error
+	)
+	launch := func() {
+		attempt := launched
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		launched++
+		pending++
+		go func() {
+			r, err := t.attemptRequest(attemptCtx, req, attempt)
+			if err != nil {
+				results <- attemptResult{err: err, attempt: attempt}
+				return
+			}
+			resp, err := t.next.RoundTrip(r)
+			results <- attemptResult{resp: resp, err: err, attempt: attempt}
+		}()
+	}
+	// cancelOthers cancels every attempt but the one in input.
+	cancelOthers := func(winner int) {
+		for i, cancel := range cancels {
+			if i != winner {
+				cancel()
+			}
+		}
+	}
+
+	launch()
+	timer := time.NewTimer(t.delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if launched <= t.maxHedges {
+				launch()
+				timer.Reset(t.delay)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				cancelOthers(res.attempt)
+				go discard(results, pending)
+				res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: cancels[res.attempt]}
+				return res.resp, nil
+			}
+			cancels[res.attempt]()
+			lastErr = res.err
+			if pending == 0 {
+				if launched > t.maxHedges {
+					return nil, lastErr
+				}
+				launch()
+			}
+		case <-ctx.Done():
+			cancelOthers(-1)
+			go discard(results, pending)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attemptRequest returns the copy of the request to send for the attempt in input.
+func (t *hedgingTransport) attemptRequest(ctx context.Context, req *http.Request, attempt int) (*http.Request, error) {
+	r := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	if attempt > 0 && len(t.hosts) > 0 {
+		r.URL.Host = t.hosts[(attempt-1)%len(t.hosts)]
+		r.Host = ""
+	}
+	return r, nil
+}
+
+// discard waits for the cancelled attempts still pending and closes the bodies of those which completed anyway.
+func discard(results <-chan attemptResult, pending int) {
+	for ; pending > 0; pending-- {
+		res := <-results
+		if res.err == nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// isIdempotent reports whether requests with the method in input can be safely sent more than once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// canReplay reports whether the request body can be sent more than once.
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// cancelOnClose cancels a context when the body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
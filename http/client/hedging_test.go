@@ -0,0 +1,75 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgingCancelsLosers(t *testing.T) {
+	var (
+		calls     atomic.Int32
+		cancelled = make(chan struct{}, 4)
+	)
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if calls.Add(1) == 1 {
+			// The first attempt is slow and only completes once cancelled.
+			<-req.Context().Done()
+			cancelled <- struct{}{}
+			return nil, req.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})
+	rt := newHedgingTransport(t, next, 10*time.Millisecond, 1)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("losing attempt was not cancelled when the winner returned")
+	}
+}
+
+func TestHedgingSkipsNonIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		time.Sleep(30 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	rt := newHedgingTransport(t, next, time.Millisecond, 3)
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.GetBody = nil
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	resp.Body.Close()
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("got %d attempts, want 1", got)
+	}
+}
+
+// newHedgingTransport returns the transport built by WithHedging around next.
+func newHedgingTransport(t *testing.T, next http.RoundTripper, delay time.Duration, maxHedges int) http.RoundTripper {
+	t.Helper()
+	cfg := newConfig()
+	WithHedging(delay, maxHedges)(cfg)
+	return cfg.middlewares[len(cfg.middlewares)-1](next)
+}
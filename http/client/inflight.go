@@ -0,0 +1,123 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// TooManyInFlightError is returned when a host already has the maximum number of requests in flight
+// and the client is configured to reject excess requests.
+type TooManyInFlightError struct {
+	Host  string
+	Limit int
+}
+
+func (e *TooManyInFlightError) Error() string {
+	return fmt.Sprintf("too many requests in flight to %s: limit is %d", e.Host, e.Limit)
+}
+
+// InFlightOption customises the per-host concurrency limiter.
+type InFlightOption func(*inFlightTransport)
+
+// RejectWhenFull fails excess requests with a *TooManyInFlightError instead of queueing them.
+func RejectWhenFull() InFlightOption {
+	return func(t *inFlightTransport) {
+		t.reject = true
+	}
+}
+
+// WithMaxInFlightPerHost allows at most n requests per host in flight at the same time.
+// A request is in flight until its response body is closed. Excess requests wait for a slot,
+// or until their context is done. Zero or a negative n means no limit, as for http.Transport.MaxConnsPerHost.
+func WithMaxInFlightPerHost(n int, opts ...InFlightOption) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		if n <= 0 {
+			return next
+		}
+		t := &inFlightTransport{
+			next:  next,
+			limit: n,
+			hosts: make(map[string]*hostSlots),
+		}
+		for _, opt := range opts {
+			opt(t)
+		}
+		return t
+	})
+}
+
+// inFlightTransport is a RoundTripper limiting the concurrent requests per host with a semaphore.
+type inFlightTransport struct {
+	next   http.RoundTripper
+	limit  int
+	reject bool
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlots
+}
+
+// hostSlots is the semaphore of a host, dropped once no request holds or waits for it.
+type hostSlots struct {
+	sem  chan struct{}
+	refs int
+}
+
+// RoundTrip acquires a slot for the request host before sending it.
+func (t *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	slots := t.acquire(host)
+	if t.reject {
+		select {
+		case slots.sem <- struct{}{}:
+		default:
+			t.release(host, slots)
+			return nil, &TooManyInFlightError{Host: host, Limit: t.limit}
+		}
+	} else {
+		select {
+		case slots.sem <- struct{}{}:
+		case <-req.Context().Done():
+			t.release(host, slots)
+			return nil, req.Context().Err()
+		}
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-slots.sem
+			t.release(host, slots)
+		})
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &onCloseBody{ReadCloser: resp.Body, onClose: release}
+	return resp, nil
+}
+
+// acquire returns the semaphore of the host, referencing it until release is called.
+func (t *inFlightTransport) acquire(host string) *hostSlots {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slots, ok := t.hosts[host]
+	if !ok {
+		slots = &hostSlots{sem: make(chan struct{}, t.limit)}
+		t.hosts[host] = slots
+	}
+	slots.refs++
+	return slots
+}
+
+// release drops the reference to the semaphore of the host, removing it once unreferenced,
+// so that the hosts no longer called do not accumulate.
+func (t *inFlightTransport) release(host string, slots *hostSlots) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if slots.refs--; slots.refs == 0 {
+		delete(t.hosts, host)
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// errorBodySnippet is how much of an unexpected response body is kept in an HTTPError.
+const errorBodySnippet = 1024
+
+// HTTPError is returned when a response has an unexpected status code.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body holds the beginning of the response body.
+	Body []byte
+}
+
+func (e *HTTPError) Error() string {
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("unexpected status %s", e.Status)
+	}
+	return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Body)
+}
+
+// newHTTPError builds an HTTPError from the response, consuming its body.
+func newHTTPError(resp *http.Response) *HTTPError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, errorBodySnippet))
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	}
+}
+
+// Client wraps an http client with helpers for JSON APIs.
+type Client struct {
+	HTTP *http.Client
+}
+
+// NewClient returns a Client built on top of an http client configured by the options in input.
+func NewClient(opts ...Option) *Client {
+	return Wrap(New(opts...))
+}
+
+// Wrap returns a Client sending requests with the http client in input.
+func Wrap(c *http.Client) *Client {
+	return &Client{HTTP: c}
+}
+
+// GetJSON sends a GET request to url and decodes the JSON response into out.
+func (c *Client) GetJSON(ctx context.Context, url string, out interface{}) error {
+	return c.doJSON(ctx, http.MethodGet, url, nil, out)
+}
+
+// PostJSON sends in encoded as JSON to url and decodes the JSON response into out.
+// Pass a nil out to ignore the response body.
+func (c *Client) PostJSON(ctx context.Context, url string, in, out interface{}) error {
+	return c.doJSON(ctx, http.MethodPost, url, in, out)
+}
+
+// PutJSON sends in encoded as JSON to url with a PUT request and decodes the JSON response into out.
+// Pass a nil out to ignore the response body.
+func (c *Client) PutJSON(ctx context.Context, url string, in, out interface{}) error {
+	return c.doJSON(ctx, http.MethodPut, url, in, out)
+}
+
+// DeleteJSON sends a DELETE request to url and decodes the JSON response into out.
+// Pass a nil out to ignore the response body.
+func (c *Client) DeleteJSON(ctx context.Context, url string, out interface{}) error {
+	return c.doJSON(ctx, http.MethodDelete, url, nil, out)
+}
+
+// doJSON sends the request and decodes the response, returning an *HTTPError for non 2xx status codes.
+func (c *Client) doJSON(ctx context.Context, method, url string, in, out interface{}) error {
+	opts := []RequestOption{WithHeader("Accept", "application/json")}
+	if in != nil {
+		opts = append(opts, WithJSONBody(in))
+	}
+	resp, err := c.Do(ctx, method, url, opts...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not decode response body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// ResponseTooLargeError is returned when reading a response body exceeding the configured limit.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+// WithMaxResponseBytes fails reading response bodies longer than n bytes with a *ResponseTooLargeError.
+func WithMaxResponseBytes(n int64) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			if resp.ContentLength > n {
+				resp.Body.Close()
+				return nil, &ResponseTooLargeError{Limit: n}
+			}
+			resp.Body = newLimitedBody(resp.Body, n)
+			return resp, nil
+		})
+	})
+}
+
+// WithDecompression advertises gzip, deflate and br support and transparently decompresses responses,
+// failing with a *ResponseTooLargeError if the decompressed body exceeds maxBytes, to guard against decompression bombs.
+func WithDecompression(maxBytes int64) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Accept-Encoding") == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			if err := decompress(resp, maxBytes); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		})
+	})
+}
+
+// decompress replaces the response body with its decompressed content.
+// Responses without a body are left as they are, even if they declare an encoding.
+func decompress(resp *http.Response, maxBytes int64) error {
+	if (resp.Request != nil && resp.Request.Method == http.MethodHead) || resp.ContentLength == 0 ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	var (
+		body = resp.Body
+		r    io.Reader
+	)
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return fmt.Errorf("could not read gzip response: %w", err)
+		}
+		r = zr
+	case "deflate":
+		r = flate.NewReader(body)
+	case "br":
+		r = brotli.NewReader(body)
+	default:
+		return nil
+	}
+	resp.Body = newLimitedBody(struct {
+		io.Reader
+		io.Closer
+	}{Reader: r, Closer: body}, maxBytes)
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// limitedBody fails with a *ResponseTooLargeError once more than limit bytes have been read.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func newLimitedBody(body io.ReadCloser, limit int64) *limitedBody {
+	return &limitedBody{ReadCloser: body, limit: limit, remaining: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, &ResponseTooLargeError{Limit: b.limit}
+	}
+	// read one byte more than allowed to detect bodies exceeding the limit
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), &ResponseTooLargeError{Limit: b.limit}
+	}
+	return n, err
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+)
+
+const (
+	loggingEvent = "http_client"
+	redacted     = "[REDACTED]"
+)
+
+// sensitiveHeaders are always redacted when headers are logged.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// LoggingOption customises the logging transport.
+type LoggingOption func(*loggingTransport)
+
+// LogHeaders instructs the transport to log request and response headers at debug level.
+// Sensitive headers, along with the ones in input, are redacted.
+func LogHeaders(redact ...string) LoggingOption {
+	return func(t *loggingTransport) {
+		t.logHeaders = true
+		for _, h := range redact {
+			t.redact[http.CanonicalHeaderKey(h)] = struct{}{}
+		}
+	}
+}
+
+// LogBody instructs the transport to log up to maxBytes of the response body at debug level.
+func LogBody(maxBytes int) LoggingOption {
+	return func(t *loggingTransport) {
+		t.maxBodyBytes = maxBytes
+	}
+}
+
+// WithLogging logs method, URI, host, status code, duration and bytes written of every request.
+func WithLogging(log logger.Logger, opts ...LoggingOption) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		t := &loggingTransport{
+			next:   next,
+			log:    log,
+			redact: make(map[string]struct{}, len(sensitiveHeaders)),
+		}
+		for _, h := range sensitiveHeaders {
+			t.redact[h] = struct{}{}
+		}
+		for _, opt := range opts {
+			opt(t)
+		}
+		return t
+	})
+}
+
+// loggingTransport is a RoundTripper that logs requests and responses.
+type loggingTransport struct {
+	next         http.RoundTripper
+	log          logger.Logger
+	logHeaders   bool
+	maxBodyBytes int
+	redact       map[string]struct{}
+}
+
+// RoundTrip logs the request and its outcome.
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqLog := t.log.Event(loggingEvent).
+		Method(req.Method).
+		URI(req.URL.String()).
+		Host(req.URL.Host)
+	if t.logHeaders {
+		reqLog.Headers(t.redactHeaders(req.Header)).Debug("Sending request")
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	reqLog = reqLog.Duration(time.Since(start))
+	if req.ContentLength > 0 {
+		reqLog = reqLog.BytesWritten(int(req.ContentLength))
+	}
+	if err != nil {
+		reqLog.Error("Request failed", err)
+		return nil, err
+	}
+
+	respLog := reqLog.StatusCode(resp.StatusCode)
+	respLog.Info("Request completed")
+	if !t.logHeaders && t.maxBodyBytes <= 0 {
+		return resp, nil
+	}
+	if t.logHeaders {
+		respLog = respLog.Headers(t.redactHeaders(resp.Header))
+	}
+	if t.maxBodyBytes > 0 {
+		var body string
+		body, resp.Body = captureBody(resp.Body, t.maxBodyBytes)
+		respLog = respLog.Body(body)
+	}
+	respLog.Debug("Received response")
+	return resp, nil
+}
+
+func (t *loggingTransport) redactHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if _, ok := t.redact[http.CanonicalHeaderKey(k)]; ok {
+			out[k] = []string{redacted}
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// captureBody reads up to n bytes from body and returns them along with a body that replays them before the rest.
+func captureBody(body io.ReadCloser, n int) (string, io.ReadCloser) {
+	if body == nil || body == http.NoBody {
+		return "", body
+	}
+	var buf bytes.Buffer
+	_, _ = io.CopyN(&buf, body, int64(n))
+	captured := buf.String()
+	return strings.ToValidUTF8(captured, ""), struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.MultiReader(strings.NewReader(captured), body),
+		Closer: body,
+	}
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithMetrics instruments the client with request duration and response size histograms,
+// request counters by method, host and status class and an in-flight requests gauge.
+// Collectors already registered on the registerer by another client are reused.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return &metricsTransport{
+			next:    next,
+			metrics: newClientMetrics(registerer),
+		}
+	})
+}
+
+type clientMetrics struct {
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	requests *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newClientMetrics(registerer prometheus.Registerer) *clientMetrics {
+	labels := []string{"method", "host", "status_class"}
+	return &clientMetrics{
+		duration: metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_client_request_duration_seconds",
+			Help:    "Duration of outgoing HTTP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, labels)),
+		size: metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_client_response_size_bytes",
+			Help:    "Size of the bodies of incoming HTTP responses.",
+			Buckets: prometheus.ExponentialBuckets(128, 4, 8),
+		}, labels)),
+		requests: metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_requests_total",
+			Help: "Number of outgoing HTTP requests.",
+		}, labels)),
+		inFlight: metrics.Register(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_client_requests_in_flight",
+			Help: "Number of outgoing HTTP requests waiting for a response.",
+		}, []string{"host"})),
+	}
+}
+
+// metricsTransport is a RoundTripper that records Prometheus metrics.
+type metricsTransport struct {
+	next    http.RoundTripper
+	metrics *clientMetrics
+}
+
+// RoundTrip records the metrics of the request.
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	inFlight := t.metrics.inFlight.WithLabelValues(req.URL.Host)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	class := "error"
+	if err == nil {
+		class = statusClass(resp.StatusCode)
+	}
+	labels := prometheus.Labels{"method": req.Method, "host": req.URL.Host, "status_class": class}
+	t.metrics.duration.With(labels).Observe(time.Since(start).Seconds())
+	t.metrics.requests.With(labels).Inc()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &sizeObserver{ReadCloser: resp.Body, observer: t.metrics.size.With(labels)}
+	return resp, nil
+}
+
+// statusClass returns the class of the status code, e.g. 2xx.
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// sizeObserver counts the bytes read from a body and observes the total when it is closed.
+type sizeObserver struct {
+	io.ReadCloser
+	observer prometheus.Observer
+	n        int64
+	once     sync.Once
+}
+
+func (s *sizeObserver) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	s.n += int64(n)
+	return n, err
+}
+
+func (s *sizeObserver) Close() error {
+	s.once.Do(func() {
+		s.observer.Observe(float64(s.n))
+	})
+	return s.ReadCloser.Close()
+}
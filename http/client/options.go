@@ -0,0 +1,115 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultMaxWorkers is the number of workers assumed when WithMaxWorkers is not used.
+const defaultMaxWorkers = 1
+
+// Option customises the http client returned by New.
+type Option func(*config)
+
+// Middleware decorates a RoundTripper with additional behaviour.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// config holds everything needed to build the http client.
+type config struct {
+	maxWorkers       int
+	dial             DialContextFunc
+	transportOptions []func(*http.Transport)
+	proxy            func(*http.Request) (*url.URL, error)
+	noProxy          []string
+	middlewares      []Middleware
+	checkRedirect    func(*http.Request, []*http.Request) error
+	jar              http.CookieJar
+	http3            bool
+	hostTimeouts     map[string]time.Duration
+	err              error
+}
+
+func newConfig() *config {
+	return &config{
+		maxWorkers: defaultMaxWorkers,
+	}
+}
+
+// client builds the http client described by the config.
+// Middlewares wrap the transport in the order they have been added, the first one being the outermost.
+// If any option failed, every request made by the client fails with the option error.
+func (c *config) client() *http.Client {
+	if c.err != nil {
+		err := c.err
+		return &http.Client{
+			Transport: RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+				return nil, err
+			}),
+		}
+	}
+	t := defaultTransport(c.maxWorkers)
+	if c.dial != nil {
+		t.DialContext = c.dial
+	}
+	for _, opt := range c.transportOptions {
+		opt(t)
+	}
+	if c.proxy != nil {
+		t.Proxy = c.proxy
+	}
+	if len(c.noProxy) > 0 {
+		t.Proxy = bypassProxy(t.Proxy, c.noProxy)
+	}
+	var rt http.RoundTripper = t
+	if c.http3 {
+		rt = newHTTP3Transport(t)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		rt = c.middlewares[i](rt)
+	}
+	rt = &timeoutTransport{next: rt, hosts: c.hostTimeouts}
+	return &http.Client{
+		Transport:     rt,
+		CheckRedirect: c.checkRedirect,
+		Jar:           c.jar,
+	}
+}
+
+// WithMaxWorkers sizes the idle connection pool for the number of concurrent workers using the client.
+func WithMaxWorkers(n int) Option {
+	return func(c *config) {
+		c.maxWorkers = n
+	}
+}
+
+// withTransport customises the underlying transport.
+func withTransport(fn func(*http.Transport)) Option {
+	return func(c *config) {
+		c.transportOptions = append(c.transportOptions, fn)
+	}
+}
+
+// withError records an option failure, keeping the first one.
+func withError(err error) Option {
+	return func(c *config) {
+		if c.err == nil {
+			c.err = err
+		}
+	}
+}
+
+// WithMiddleware wraps the client transport with the middleware in input.
+func WithMiddleware(mw Middleware) Option {
+	return func(c *config) {
+		c.middlewares = append(c.middlewares, mw)
+	}
+}
+
+// RoundTripperFunc is an adapter to allow the use of ordinary functions as http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
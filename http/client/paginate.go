@@ -0,0 +1,218 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/backoff"
+	"github.com/indiependente/pkg/clock"
+)
+
+const defaultMaxPages = 1000
+
+// ErrPageLimit is yielded by Paginate when more pages are available past the page limit.
+var ErrPageLimit = errors.New("page limit reached")
+
+// PageOption customises the pagination of Paginate.
+type PageOption[T any] func(*pageConfig[T])
+
+type pageConfig[T any] struct {
+	next     func(current *url.URL, resp *http.Response, page T) (*url.URL, bool)
+	maxPages int
+	delay    backoff.Strategy
+	opts     []RequestOption
+	clock    clock.Clock
+}
+
+// WithCursor follows the cursor returned by the function in input, e.g. the next_cursor field of the page,
+// setting it as the param query parameter of the next request. Pagination stops on an empty cursor.
+func WithCursor[T any](param string, cursor func(page T) string) PageOption[T] {
+	return func(c *pageConfig[T]) {
+		c.next = func(current *url.URL, _ *http.Response, page T) (*url.URL, bool) {
+			next := cursor(page)
+			if next == "" {
+				return nil, false
+			}
+			return withParam(current, param, next), true
+		}
+	}
+}
+
+// WithOffset advances the param query parameter by the number of items of the page returned by the function in
+// input, starting from the offset of the first URL, if any. Pagination stops on a page with fewer than pageSize items.
+func WithOffset[T any](param string, pageSize int, count func(page T) int) PageOption[T] {
+	return func(c *pageConfig[T]) {
+		c.next = func(current *url.URL, _ *http.Response, page T) (*url.URL, bool) {
+			n := count(page)
+			if n == 0 || n < pageSize {
+				return nil, false
+			}
+			offset, _ := strconv.Atoi(current.Query().Get(param))
+			return withParam(current, param, strconv.Itoa(offset+n)), true
+		}
+	}
+}
+
+// WithMaxPages fetches up to n pages, 1000 by default, yielding ErrPageLimit if more are available.
+func WithMaxPages[T any](n int) PageOption[T] {
+	return func(c *pageConfig[T]) {
+		c.maxPages = n
+	}
+}
+
+// WithPageDelay waits between the requests of the pages as told by the strategy in input, e.g. backoff.Constant,
+// to go easy on the API. Pagination stops if the strategy returns backoff.Stop.
+func WithPageDelay[T any](s backoff.Strategy) PageOption[T] {
+	return func(c *pageConfig[T]) {
+		c.delay = s
+	}
+}
+
+// WithPageRequestOptions sends the requests of the pages with the options in input, e.g. WithHeader.
+func WithPageRequestOptions[T any](opts ...RequestOption) PageOption[T] {
+	return func(c *pageConfig[T]) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+// WithPageClock waits between the pages with the clock in input, e.g. a clock.Fake in tests.
+func WithPageClock[T any](clk clock.Clock) PageOption[T] {
+	return func(c *pageConfig[T]) {
+		c.clock = clk
+	}
+}
+
+// Paginate returns an iterator over the pages of a REST API, starting from firstURL and decoding every page from
+// JSON into T. Pages are fetched lazily, as the iteration proceeds, following the URL of the Link header with the
+// next relation, as in RFC 8288, unless WithCursor or WithOffset are used.
+// Errors, e.g. an *HTTPError for non 2xx status codes, are yielded last.
+func Paginate[T any](ctx context.Context, c *Client, firstURL string, opts ...PageOption[T]) iter.Seq2[T, error] {
+	cfg := &pageConfig[T]{
+		next:     nextLink[T],
+		maxPages: defaultMaxPages,
+		clock:    clock.New(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(yield func(T, error) bool) {
+		var zero T
+		current, err := url.Parse(firstURL)
+		if err != nil {
+			yield(zero, fmt.Errorf("could not parse URL: %w", err))
+			return
+		}
+		var wait time.Duration
+		for pages := 0; ; pages++ {
+			if pages == cfg.maxPages {
+				yield(zero, fmt.Errorf("%w: %d pages fetched", ErrPageLimit, pages))
+				return
+			}
+			if pages > 0 && cfg.delay != nil {
+				if wait = cfg.delay.Delay(pages, wait); wait == backoff.Stop {
+					return
+				}
+				if err := sleep(ctx, cfg.clock, wait); err != nil {
+					yield(zero, err)
+					return
+				}
+			}
+			page, resp, err := fetchPage[T](ctx, c, current, cfg.opts)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(page, nil) {
+				return
+			}
+			next, ok := cfg.next(current, resp, page)
+			if !ok {
+				return
+			}
+			current = next
+		}
+	}
+}
+
+// fetchPage requests the page, decoding it into T. The body of the response is consumed and closed.
+func fetchPage[T any](ctx context.Context, c *Client, u *url.URL, opts []RequestOption) (T, *http.Response, error) {
+	var page T
+	opts = append([]RequestOption{WithHeader("Accept", "application/json")}, opts...)
+	resp, err := c.Do(ctx, http.MethodGet, u.String(), opts...)
+	if err != nil {
+		return page, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return page, nil, newHTTPError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return page, nil, fmt.Errorf("could not decode page %s: %w", u, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return page, resp, nil
+}
+
+// nextLink returns the URL of the Link header with the next relation, resolved against the current URL.
+func nextLink[T any](current *url.URL, resp *http.Response, _ T) (*url.URL, bool) {
+	for _, header := range resp.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") || !hasRel(strings.Trim(value, `"`), "next") {
+					continue
+				}
+				next, err := current.Parse(strings.Trim(target, "<>"))
+				if err != nil {
+					return nil, false
+				}
+				return next, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// hasRel reports whether the space separated relations contain rel.
+func hasRel(rels, rel string) bool {
+	for _, r := range strings.Fields(rels) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// withParam returns a copy of the URL with the query parameter set to value.
+func withParam(u *url.URL, param, value string) *url.URL {
+	next := *u
+	q := next.Query()
+	q.Set(param, value)
+	next.RawQuery = q.Encode()
+	return &next
+}
+
+// sleep waits for d, returning early with the error of ctx if it is done.
+func sleep(ctx context.Context, clk clock.Clock, d time.Duration) error {
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
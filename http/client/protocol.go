@@ -0,0 +1,92 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// WithHTTP1Only disables HTTP/2, speaking HTTP/1.1 with every server.
+func WithHTTP1Only() Option {
+	return withTransport(func(t *http.Transport) {
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP1(true)
+	})
+}
+
+// WithH2C speaks HTTP/2 without TLS (prior knowledge) to http:// URLs, e.g. gRPC style cleartext backends.
+// https:// URLs keep using HTTP/2 over TLS.
+func WithH2C() Option {
+	return withTransport(func(t *http.Transport) {
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetUnencryptedHTTP2(true)
+		t.Protocols.SetHTTP2(true)
+	})
+}
+
+// WithHTTP2Health sends a ping on HTTP/2 connections which have not received frames for readIdle,
+// closing them if the ping is not answered within pingTimeout.
+func WithHTTP2Health(readIdle, pingTimeout time.Duration) Option {
+	return withTransport(func(t *http.Transport) {
+		if t.HTTP2 == nil {
+			t.HTTP2 = &http.HTTP2Config{}
+		}
+		t.HTTP2.SendPingTimeout = readIdle
+		t.HTTP2.PingTimeout = pingTimeout
+	})
+}
+
+// WithHTTP3 sends https:// requests over HTTP/3 (QUIC), falling back to the TCP transport when the QUIC round trip
+// fails and the request can be replayed: always if the QUIC connection could not be established, only for idempotent
+// methods otherwise, as the request may have reached the server.
+func WithHTTP3() Option {
+	return func(c *config) {
+		c.http3 = true
+	}
+}
+
+// http3Transport sends https requests over QUIC and everything else over TCP.
+type http3Transport struct {
+	h3  *http3.Transport
+	tcp *http.Transport
+}
+
+func newHTTP3Transport(tcp *http.Transport) *http3Transport {
+	h3 := &http3.Transport{}
+	if tcp.TLSClientConfig != nil {
+		h3.TLSClientConfig = tcp.TLSClientConfig.Clone()
+	}
+	return &http3Transport{h3: h3, tcp: tcp}
+}
+
+// RoundTrip sends the request over QUIC when possible.
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.tcp.RoundTrip(req)
+	}
+	// a request which got a QUIC connection may have reached the server, so only idempotent ones are sent again
+	var connected atomic.Bool
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { connected.Store(true) },
+	})
+	resp, err := t.h3.RoundTrip(req.WithContext(ctx))
+	if err == nil || req.Context().Err() != nil || !canReplay(req) || (connected.Load() && !isIdempotent(req.Method)) {
+		return resp, err
+	}
+	if req.GetBody != nil {
+		req = req.Clone(req.Context())
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.tcp.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of both transports.
+func (t *http3Transport) CloseIdleConnections() {
+	t.h3.CloseIdleConnections()
+	t.tcp.CloseIdleConnections()
+}
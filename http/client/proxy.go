@@ -0,0 +1,51 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WithProxyURL sends every request through the proxy in input, ignoring the HTTP_PROXY and HTTPS_PROXY environment variables.
+func WithProxyURL(u string) Option {
+	proxyURL, err := url.Parse(u)
+	if err != nil {
+		return withError(fmt.Errorf("could not parse proxy URL: %w", err))
+	}
+	return func(c *config) {
+		c.proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithNoProxy sends requests to the hosts in input directly, bypassing any configured proxy.
+// A host starting with a dot also matches all of its subdomains.
+func WithNoProxy(hosts ...string) Option {
+	return func(c *config) {
+		c.noProxy = append(c.noProxy, hosts...)
+	}
+}
+
+// bypassProxy wraps the proxy function so that requests to the hosts in input are not proxied.
+func bypassProxy(proxy func(*http.Request) (*url.URL, error), hosts []string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if proxy == nil || matchHost(req.URL.Hostname(), hosts) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+}
+
+func matchHost(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if host == strings.TrimPrefix(p, ".") {
+			return true
+		}
+		if strings.HasPrefix(p, ".") && strings.HasSuffix(host, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/indiependente/pkg/ratelimit"
+)
+
+// ErrRateLimited is returned when the rate limit budget is exhausted and the client is configured to fail fast.
+var ErrRateLimited = ratelimit.ErrLimited
+
+// RateLimitOption customises the rate limiting transport.
+type RateLimitOption func(*rateLimitTransport)
+
+// PerHost gives each host its own token bucket instead of sharing one across all the requests.
+func PerHost() RateLimitOption {
+	return func(t *rateLimitTransport) {
+		t.perHost = true
+	}
+}
+
+// FailFast makes requests fail with ErrRateLimited when the budget is exhausted instead of blocking until a token is available.
+func FailFast() RateLimitOption {
+	return func(t *rateLimitTransport) {
+		t.failFast = true
+	}
+}
+
+// RateLimitStore keeps the token buckets in the store in input instead of in memory,
+// e.g. a ratelimit.RedisStore to share the budget across instances.
+func RateLimitStore(store ratelimit.Store) RateLimitOption {
+	return func(t *rateLimitTransport) {
+		t.store = store
+	}
+}
+
+// WithRateLimit limits the client to rps requests per second, allowing bursts of up to burst requests.
+// By default requests block until the budget allows them or their context is done.
+func WithRateLimit(rps float64, burst int, opts ...RateLimitOption) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		t := &rateLimitTransport{next: next}
+		for _, opt := range opts {
+			opt(t)
+		}
+		if t.store == nil {
+			t.store = ratelimit.NewMemoryStore(1)
+		}
+		t.limiter = ratelimit.New(t.store, rps, burst)
+		return t
+	})
+}
+
+// rateLimitTransport is a token bucket RoundTripper.
+type rateLimitTransport struct {
+	next     http.RoundTripper
+	store    ratelimit.Store
+	limiter  *ratelimit.Limiter
+	perHost  bool
+	failFast bool
+}
+
+// RoundTrip waits for the rate limit budget before sending the request.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var key string
+	if t.perHost {
+		key = req.URL.Host
+	}
+	if t.failFast {
+		if !t.limiter.Allow(req.Context(), key) {
+			return nil, ErrRateLimited
+		}
+		return t.next.RoundTrip(req)
+	}
+	if err := t.limiter.Wait(req.Context(), key); err != nil {
+		return nil, fmt.Errorf("could not wait for rate limit: %w", err)
+	}
+	return t.next.RoundTrip(req)
+}
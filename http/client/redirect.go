@@ -0,0 +1,38 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// WithNoRedirects returns 3xx responses to the caller instead of following them.
+func WithNoRedirects() Option {
+	return WithRedirectPolicy(func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	})
+}
+
+// WithMaxRedirects follows up to n redirects, failing the request if more are needed.
+func WithMaxRedirects(n int) Option {
+	return WithRedirectPolicy(func(_ *http.Request, via []*http.Request) error {
+		if len(via) > n {
+			return fmt.Errorf("stopped after %d redirects", n)
+		}
+		return nil
+	})
+}
+
+// WithRedirectPolicy decides whether to follow redirects with the function in input,
+// which behaves as http.Client.CheckRedirect.
+func WithRedirectPolicy(policy func(req *http.Request, via []*http.Request) error) Option {
+	return func(c *config) {
+		c.checkRedirect = policy
+	}
+}
+
+// WithCookieJar stores the cookies received in responses in the jar in input and sends them along with later requests.
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *config) {
+		c.jar = jar
+	}
+}
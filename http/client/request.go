@@ -0,0 +1,125 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RequestOption customises a single request sent with Client.Do.
+type RequestOption func(*requestConfig) error
+
+type requestConfig struct {
+	query   url.Values
+	header  http.Header
+	body    io.Reader
+	expect  []int
+	timeout time.Duration
+}
+
+// WithQuery adds the query parameter to the request URL.
+func WithQuery(key, value string) RequestOption {
+	return func(rc *requestConfig) error {
+		rc.query.Add(key, value)
+		return nil
+	}
+}
+
+// WithHeader adds the header to the request.
+func WithHeader(key, value string) RequestOption {
+	return func(rc *requestConfig) error {
+		rc.header.Add(key, value)
+		return nil
+	}
+}
+
+// WithBody sends the content of the reader as request body.
+func WithBody(body io.Reader) RequestOption {
+	return func(rc *requestConfig) error {
+		rc.body = body
+		return nil
+	}
+}
+
+// WithJSONBody sends v encoded as JSON as request body.
+func WithJSONBody(v interface{}) RequestOption {
+	return func(rc *requestConfig) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("could not encode request body: %w", err)
+		}
+		rc.body = bytes.NewReader(b)
+		rc.header.Set("Content-Type", "application/json")
+		return nil
+	}
+}
+
+// ExpectStatus makes Do return an *HTTPError when the response status code is not one of the codes in input.
+func ExpectStatus(codes ...int) RequestOption {
+	return func(rc *requestConfig) error {
+		rc.expect = append(rc.expect, codes...)
+		return nil
+	}
+}
+
+// Do sends a request built from the options in input and returns the raw response.
+// The caller is responsible for closing the response body, unless an error is returned.
+func (c *Client) Do(ctx context.Context, method, rawURL string, opts ...RequestOption) (*http.Response, error) {
+	rc := &requestConfig{
+		query:  url.Values{},
+		header: http.Header{},
+	}
+	for _, opt := range opts {
+		if err := opt(rc); err != nil {
+			return nil, err
+		}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse URL: %w", err)
+	}
+	if len(rc.query) > 0 {
+		q := u.Query()
+		for k, vs := range rc.query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	if rc.timeout > 0 {
+		ctx = WithRequestTimeout(ctx, rc.timeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), rc.body)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	for k, vs := range rc.header {
+		req.Header[k] = vs
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not send request: %w", err)
+	}
+	if len(rc.expect) > 0 && !containsStatus(rc.expect, resp.StatusCode) {
+		defer resp.Body.Close()
+		return nil, newHTTPError(resp)
+	}
+	return resp, nil
+}
+
+func containsStatus(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
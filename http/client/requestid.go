@@ -0,0 +1,23 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/indiependente/pkg/requestid"
+)
+
+// WithRequestIDPropagation sets the X-Request-ID header of every request to the request ID carried by its context,
+// as stored by the request ID middleware.
+func WithRequestIDPropagation() Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			id, ok := requestid.FromContext(req.Context())
+			if !ok || req.Header.Get(requestid.Header) != "" {
+				return next.RoundTrip(req)
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set(requestid.Header, id)
+			return next.RoundTrip(req)
+		})
+	})
+}
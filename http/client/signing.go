@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// defaultSignedHeaders are the headers signed by WithHMACSigning when none is given.
+var defaultSignedHeaders = []string{"(request-target)", "host", "date", "digest"}
+
+// WithAWSSigV4 signs every request with AWS Signature Version 4 for the region and service in input,
+// e.g. "eu-west-1" and "execute-api", using the credentials of the provider, cached until they expire.
+// Request bodies are buffered in memory to be hashed.
+// The middleware must be added after the ones setting headers, so that their headers are signed.
+func WithAWSSigV4(creds aws.CredentialsProvider, region, service string) Option {
+	cache := aws.NewCredentialsCache(creds)
+	signer := v4.NewSigner()
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			req = req.Clone(ctx)
+			body, err := bufferBody(req)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(body)
+			hash := hex.EncodeToString(sum[:])
+			if service == "s3" {
+				req.Header.Set("X-Amz-Content-Sha256", hash)
+			}
+			c, err := cache.Retrieve(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("could not retrieve credentials: %w", err)
+			}
+			if err := signer.SignHTTP(ctx, c, req, hash, service, region, time.Now()); err != nil {
+				return nil, fmt.Errorf("could not sign request: %w", err)
+			}
+			return next.RoundTrip(req)
+		})
+	})
+}
+
+// WithHMACSigning signs every request with HMAC-SHA256 following the HTTP Signatures draft (cavage),
+// setting the Signature header to
+//
+//	keyId="<keyID>",algorithm="hmac-sha256",headers="<headers>",signature="<base64 signature>"
+//
+// The headers in input, lower-cased, make the signing string, "(request-target)" standing for the method and path;
+// they default to (request-target), host, date and digest. The Date and Digest (SHA-256 of the body) headers are
+// set when signed and missing, request bodies being buffered in memory to be hashed.
+// The middleware must be added after the ones setting headers, so that their headers are signed.
+func WithHMACSigning(keyID string, secret []byte, headers ...string) Option {
+	if len(headers) == 0 {
+		headers = defaultSignedHeaders
+	}
+	signed := make([]string, len(headers))
+	for i, h := range headers {
+		signed[i] = strings.ToLower(h)
+	}
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			signature, err := signHMAC(req, secret, signed)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="hmac-sha256",headers="%s",signature="%s"`,
+				keyID, strings.Join(signed, " "), signature))
+			return next.RoundTrip(req)
+		})
+	})
+}
+
+// signHMAC sets the missing Date and Digest headers and returns the base64 signature of the signing string.
+func signHMAC(req *http.Request, secret []byte, headers []string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, h := range headers {
+		var value string
+		switch h {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		case "date":
+			if req.Header.Get("Date") == "" {
+				req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+			}
+			value = req.Header.Get("Date")
+		case "digest":
+			if req.Header.Get("Digest") == "" {
+				body, err := bufferBody(req)
+				if err != nil {
+					return "", err
+				}
+				sum := sha256.Sum256(body)
+				req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+			}
+			value = req.Header.Get("Digest")
+		default:
+			value = strings.Join(req.Header.Values(h), ", ")
+		}
+		lines = append(lines, h+": "+value)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// bufferBody reads the body of the request, replacing it with a replayable copy.
+func bufferBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	rc := req.Body
+	if req.GetBody != nil {
+		var err error
+		if rc, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("could not get request body: %w", err)
+		}
+	}
+	body, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
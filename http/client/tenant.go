@@ -0,0 +1,23 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/indiependente/pkg/tenantid"
+)
+
+// WithTenantPropagation sets the tenantid.Header header of every request to the tenant ID carried by its context,
+// as stored by tenant.Middleware or tenantid.NewContext.
+func WithTenantPropagation() Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			id, ok := tenantid.FromContext(req.Context())
+			if !ok || req.Header.Get(tenantid.Header) != "" {
+				return next.RoundTrip(req)
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set(tenantid.Header, id)
+			return next.RoundTrip(req)
+		})
+	})
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type timeoutKey struct{}
+
+// WithRequestTimeout returns a copy of the context making the requests sent with it, by the clients built by New,
+// time out after d, response body included. It takes precedence over the host timeouts.
+// Unlike context.WithTimeout, the timer only starts when the request is sent and there is nothing to cancel.
+func WithRequestTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, d)
+}
+
+// Timeout makes the request sent by Client.Do time out after d, response body included.
+func Timeout(d time.Duration) RequestOption {
+	return func(rc *requestConfig) error {
+		rc.timeout = d
+		return nil
+	}
+}
+
+// WithHostTimeouts makes the requests to the hosts in input time out after the respective duration,
+// response body included, unless a timeout is set on the request context with WithRequestTimeout.
+// Hosts are matched by host and port first, then by name, then by "*.domain" wildcards, e.g.
+// {"billing.internal": time.Second, "*.thirdparty.com": 30 * time.Second}.
+func WithHostTimeouts(timeouts map[string]time.Duration) Option {
+	return func(c *config) {
+		if c.hostTimeouts == nil {
+			c.hostTimeouts = make(map[string]time.Duration, len(timeouts))
+		}
+		for host, d := range timeouts {
+			c.hostTimeouts[strings.ToLower(host)] = d
+		}
+	}
+}
+
+// timeoutTransport applies the request and host timeouts. It wraps every other middleware,
+// so that the timeouts bound the retries and hedged requests too.
+type timeoutTransport struct {
+	next  http.RoundTripper
+	hosts map[string]time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d, ok := req.Context().Value(timeoutKey{}).(time.Duration)
+	if !ok {
+		d, ok = t.hostTimeout(req)
+	}
+	if !ok || d <= 0 {
+		return t.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), d)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *timeoutTransport) hostTimeout(req *http.Request) (time.Duration, bool) {
+	if len(t.hosts) == 0 {
+		return 0, false
+	}
+	if d, ok := t.hosts[strings.ToLower(req.URL.Host)]; ok {
+		return d, true
+	}
+	name := strings.ToLower(req.URL.Hostname())
+	if d, ok := t.hosts[name]; ok {
+		return d, true
+	}
+	for i := strings.IndexByte(name, '.'); i >= 0; i = strings.IndexByte(name, '.') {
+		name = name[i+1:]
+		if d, ok := t.hosts["*."+name]; ok {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithClientCertificate presents the certificate in input to servers requesting mutual TLS authentication.
+// The files are read on the first handshake and reloaded whenever their modification time changes,
+// so rotated certificates are picked up without restarting the service.
+func WithClientCertificate(certFile, keyFile string) Option {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	return withTransport(func(t *http.Transport) {
+		tlsConfig(t).GetClientCertificate = reloader.GetClientCertificate
+	})
+}
+
+// WithCAFile verifies server certificates against the PEM encoded certificate authorities in the file in input,
+// instead of the system pool.
+func WithCAFile(path string) Option {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return withError(fmt.Errorf("could not read CA file: %w", err))
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return withError(fmt.Errorf("could not find any certificate in CA file %s", path))
+	}
+	return withTransport(func(t *http.Transport) {
+		tlsConfig(t).RootCAs = pool
+	})
+}
+
+// WithInsecureSkipVerify disables the verification of server certificates.
+// DO NOT USE IN PRODUCTION: it makes the client vulnerable to man-in-the-middle attacks.
+// It only exists to ease local development against self-signed certificates.
+func WithInsecureSkipVerify(skip bool) Option {
+	return withTransport(func(t *http.Transport) {
+		tlsConfig(t).InsecureSkipVerify = skip // nolint:gosec
+	})
+}
+
+// tlsConfig returns the TLS configuration of the transport, creating it if needed.
+func tlsConfig(t *http.Transport) *tls.Config {
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	return t.TLSClientConfig
+}
+
+// certReloader loads a key pair and reloads it when the files change.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// GetClientCertificate returns the current certificate, reloading it if the files changed since the last load.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not stat client certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load client certificate: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return r.cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// WithTracing creates a client span for every request and propagates the span context downstream
+// using the W3C traceparent and baggage headers.
+// The tracer provider and propagators default to the global ones and can be overridden via the options in input.
+func WithTracing(opts ...otelhttp.Option) Option {
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		defaults := []otelhttp.Option{
+			otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator(
+				propagation.TraceContext{},
+				propagation.Baggage{},
+			)),
+		}
+		return otelhttp.NewTransport(next, append(defaults, opts...)...)
+	})
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/indiependente/pkg/jwt"
+	"github.com/indiependente/pkg/tenant"
+)
+
+// ErrUnauthenticated is returned by API key lookups when the key is not valid.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Subject identifies the caller, e.g. the sub claim of a JWT or the owner of an API key.
+	Subject string
+	// Claims holds the JWT claims, or any metadata attached to an API key.
+	Claims map[string]interface{}
+}
+
+type principalKey struct{}
+
+// NewPrincipalContext returns a copy of the context carrying the principal in input.
+func NewPrincipalContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal authenticated by AuthJWT or AuthAPIKey, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// TenantFromPrincipal returns a tenant.Resolver reading the tenant ID from the claim in input of the principal
+// authenticated by AuthJWT or AuthAPIKey, which must run before tenant.Middleware.
+func TenantFromPrincipal(claim string) tenant.Resolver {
+	return func(r *http.Request) (string, bool) {
+		p, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			return "", false
+		}
+		return tenant.FromClaims(p.Claims, claim)
+	}
+}
+
+// AuthJWT authenticates requests carrying a valid JWT as bearer token in the Authorization header,
+// answering 401 Unauthorized otherwise.
+// Keys are resolved with the keyfunc in input, e.g. JWKSKeyfunc, and the expiration claim is required.
+// Tokens must be signed with HS256, RS256 or ES256 unless other methods are allowed with gojwt.WithValidMethods.
+// Use the parser options to validate the issuer and the audience too.
+func AuthJWT(keyfunc gojwt.Keyfunc, opts ...gojwt.ParserOption) func(http.Handler) http.Handler {
+	return AuthJWTVerifier(jwt.NewVerifier(keyfunc, jwt.WithParserOptions(opts...)))
+}
+
+// AuthJWTVerifier behaves as AuthJWT, validating tokens with the verifier in input.
+func AuthJWTVerifier(v *jwt.Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+				unauthorized(w, "Bearer")
+				return
+			}
+			claims, err := v.Verify(strings.TrimSpace(auth[7:]))
+			if err != nil {
+				unauthorized(w, `Bearer error="invalid_token"`)
+				return
+			}
+			sub, _ := claims.GetSubject()
+			p := &Principal{Subject: sub, Claims: claims}
+			next.ServeHTTP(w, r.WithContext(NewPrincipalContext(r.Context(), p)))
+		})
+	}
+}
+
+// APIKeyLookup returns the principal owning the API key, or ErrUnauthenticated if the key is not valid.
+type APIKeyLookup func(ctx context.Context, key string) (*Principal, error)
+
+// APIKeyOption customises the API key authentication middleware.
+type APIKeyOption func(*apiKeyConfig)
+
+type apiKeyConfig struct {
+	header string
+}
+
+// WithAPIKeyHeader reads the API key from the header in input instead of X-API-Key.
+func WithAPIKeyHeader(header string) APIKeyOption {
+	return func(c *apiKeyConfig) {
+		c.header = header
+	}
+}
+
+// AuthAPIKey authenticates requests carrying an API key accepted by the lookup function,
+// answering 401 Unauthorized for missing or invalid keys and 500 Internal Server Error if the lookup fails.
+func AuthAPIKey(lookup APIKeyLookup, opts ...APIKeyOption) func(http.Handler) http.Handler {
+	cfg := &apiKeyConfig{header: "X-API-Key"}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(cfg.header)
+			if key == "" {
+				unauthorized(w, "")
+				return
+			}
+			p, err := lookup(r.Context(), key)
+			switch {
+			case errors.Is(err, ErrUnauthenticated):
+				unauthorized(w, "")
+				return
+			case err != nil:
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewPrincipalContext(r.Context(), p)))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, challenge string) {
+	if challenge != "" {
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
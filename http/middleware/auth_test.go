@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthJWTAlgorithms(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef0123456789abcdef")
+	keyfunc := func(*gojwt.Token) (interface{}, error) {
+		return secret, nil
+	}
+	sign := func(method gojwt.SigningMethod) string {
+		s, err := gojwt.NewWithClaims(method, gojwt.MapClaims{
+			"sub": "alice",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	tests := []struct {
+		name       string
+		opts       []gojwt.ParserOption
+		method     gojwt.SigningMethod
+		wantStatus int
+	}{
+		{name: "default algorithm", method: gojwt.SigningMethodHS256, wantStatus: http.StatusOK},
+		{name: "algorithm outside the defaults", method: gojwt.SigningMethodHS384, wantStatus: http.StatusUnauthorized},
+		{
+			name:       "allowed algorithm",
+			opts:       []gojwt.ParserOption{gojwt.WithValidMethods([]string{"HS384"})},
+			method:     gojwt.SigningMethodHS384,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "algorithm outside the allowed ones",
+			opts:       []gojwt.ParserOption{gojwt.WithValidMethods([]string{"HS384"})},
+			method:     gojwt.SigningMethodHS256,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := AuthJWT(keyfunc, tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer "+sign(tt.method))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// defaultCompressMinSize is the minimum response size worth compressing.
+const defaultCompressMinSize = 1024
+
+// defaultCompressTypes are compressed when no content types are given.
+var defaultCompressTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/problem+json",
+	"image/svg+xml",
+}
+
+// Compressor compresses responses with brotli or gzip, depending on what the client accepts.
+type Compressor struct {
+	// Level is the compression level; gzip levels are used for brotli too, capped to brotli.BestCompression.
+	Level int
+	// Types are the compressed content types; an entry ending with a slash matches any subtype.
+	Types []string
+	// MinSize is the minimum size a response must have to be compressed.
+	MinSize int
+
+	gzipPool   sync.Pool
+	brotliPool sync.Pool
+}
+
+// NewCompressor returns a Compressor compressing the content types in input, or common textual types if none.
+func NewCompressor(level int, types ...string) *Compressor {
+	if len(types) == 0 {
+		types = defaultCompressTypes
+	}
+	c := &Compressor{Level: level, Types: types, MinSize: defaultCompressMinSize}
+	c.gzipPool.New = func() interface{} {
+		w, err := gzip.NewWriterLevel(io.Discard, c.Level)
+		if err != nil {
+			w = gzip.NewWriter(io.Discard)
+		}
+		return w
+	}
+	c.brotliPool.New = func() interface{} {
+		level := c.Level
+		if level < 0 || level > brotli.BestCompression {
+			level = brotli.DefaultCompression
+		}
+		return brotli.NewWriterLevel(io.Discard, level)
+	}
+	return c
+}
+
+// Compress compresses responses with the content types in input, or common textual types if none,
+// using brotli or gzip at the level in input.
+func Compress(level int, types ...string) func(http.Handler) http.Handler {
+	return NewCompressor(level, types...).Handler
+}
+
+// Handler compresses the responses of the next handler.
+func (c *Compressor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, c: c, encoding: encoding, status: http.StatusOK}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+func (c *Compressor) compressible(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	for _, t := range c.Types {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the preferred encoding accepted by the client, br or gzip, or an empty string.
+func negotiateEncoding(accept string) string {
+	var gzipOK, brOK bool
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.Replace(strings.TrimSpace(params), " ", "", -1) == "q=0" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "br":
+			brOK = true
+		case "gzip":
+			gzipOK = true
+		}
+	}
+	switch {
+	case brOK:
+		return "br"
+	case gzipOK:
+		return "gzip"
+	}
+	return ""
+}
+
+type resetWriteCloser interface {
+	io.WriteCloser
+	Reset(io.Writer)
+	Flush() error
+}
+
+// compressWriter buffers the beginning of the response to decide whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *Compressor
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	enc      resetWriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.decided {
+		return
+	}
+	cw.status = code
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.decide()
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.c.MinSize {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide writes the header, compressing the response if it is large enough and of a compressible type,
+// and flushes the buffered content.
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	if len(cw.buf) >= cw.c.MinSize && h.Get("Content-Encoding") == "" && cw.c.compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// the compressed representation differs byte for byte from the one the strong ETag identifies
+			h.Set("ETag", "W/"+etag)
+		}
+		if cw.encoding == "br" {
+			cw.enc = cw.c.brotliPool.Get().(*brotli.Writer)
+		} else {
+			cw.enc = cw.c.gzipPool.Get().(*gzip.Writer)
+		}
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+// Flush flushes the compressed data written so far to the client.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide()
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close completes the response and returns the compressor to its pool.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.enc == nil {
+		return nil
+	}
+	err := cw.enc.Close()
+	cw.enc.Reset(io.Discard)
+	if cw.encoding == "br" {
+		cw.c.brotliPool.Put(cw.enc)
+	} else {
+		cw.c.gzipPool.Put(cw.enc)
+	}
+	cw.enc = nil
+	return err
+}
+
+// Hijack implements http.Hijacker when the wrapped writer does, leaving the connection uncompressed.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(cw.ResponseWriter).Hijack()
+	if err == nil {
+		cw.decided = true
+		cw.buf = nil
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOption customises the CORS middleware.
+type CORSOption func(*corsConfig)
+
+type corsConfig struct {
+	origins          []string
+	methods          []string
+	headers          []string
+	exposed          []string
+	allowCredentials bool
+	maxAge           time.Duration
+}
+
+// AllowOrigins sets the origins allowed to make cross-origin requests.
+// "*" allows any origin, and a "*" in place of the leftmost subdomain, e.g. "https://*.example.com", allows any subdomain.
+func AllowOrigins(origins ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.origins = origins
+	}
+}
+
+// AllowMethods sets the methods allowed in cross-origin requests.
+func AllowMethods(methods ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.methods = methods
+	}
+}
+
+// AllowHeaders sets the request headers allowed in cross-origin requests.
+func AllowHeaders(headers ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.headers = headers
+	}
+}
+
+// ExposeHeaders sets the response headers browsers expose to cross-origin callers.
+func ExposeHeaders(headers ...string) CORSOption {
+	return func(c *corsConfig) {
+		c.exposed = headers
+	}
+}
+
+// AllowCredentials allows cross-origin requests to carry cookies and authorization headers.
+// It requires the allowed origins to be listed with AllowOrigins, as allowing credentials from any origin would let
+// every site act on behalf of the users: CORS panics if "*" is allowed.
+func AllowCredentials() CORSOption {
+	return func(c *corsConfig) {
+		c.allowCredentials = true
+	}
+}
+
+// MaxAge sets how long browsers can cache the result of preflight requests.
+func MaxAge(d time.Duration) CORSOption {
+	return func(c *corsConfig) {
+		c.maxAge = d
+	}
+}
+
+// CORS handles cross-origin resource sharing, answering preflight requests without calling the next handler.
+// By default any origin is allowed to use the GET, HEAD and POST methods.
+// It panics if credentials are allowed from any origin, see AllowCredentials.
+func CORS(opts ...CORSOption) func(http.Handler) http.Handler {
+	cfg := &corsConfig{
+		origins: []string{"*"},
+		methods: []string{http.MethodGet, http.MethodHead, http.MethodPost},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.allowCredentials && cfg.allowsAnyOrigin() {
+		panic("middleware: CORS cannot allow credentials from any origin, list the allowed origins with AllowOrigins")
+	}
+	methods := strings.Join(cfg.methods, ", ")
+	headers := strings.Join(cfg.headers, ", ")
+	exposed := strings.Join(cfg.exposed, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if origin == "" || !cfg.allowsOrigin(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !cfg.allowsAnyOrigin() {
+				h.Set("Access-Control-Allow-Origin", origin)
+			} else {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+			if cfg.allowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+				if exposed != "" {
+					h.Set("Access-Control-Expose-Headers", exposed)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// preflight request
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			} else if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" && cfg.allowsAnyOrigin() {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			if cfg.maxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.maxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func (c *corsConfig) allowsAnyOrigin() bool {
+	for _, o := range c.origins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *corsConfig) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range c.origins {
+		o = strings.ToLower(o)
+		if o == "*" || o == origin {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(o, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
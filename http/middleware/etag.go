@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultETagMaxSize is the largest response buffered to compute its ETag.
+const defaultETagMaxSize = 1 << 20
+
+// ETagOption customises the ETag middleware.
+type ETagOption func(*etagConfig)
+
+type etagConfig struct {
+	weak    bool
+	maxSize int
+}
+
+// WeakETags marks the computed ETags as weak, e.g. when equivalent responses may differ byte for byte.
+func WeakETags() ETagOption {
+	return func(c *etagConfig) {
+		c.weak = true
+	}
+}
+
+// WithETagMaxSize buffers responses up to n bytes to compute their ETag, larger ones being sent without one.
+// Defaults to 1MiB.
+func WithETagMaxSize(n int) ETagOption {
+	return func(c *etagConfig) {
+		c.maxSize = n
+	}
+}
+
+// ETag answers conditional GET and HEAD requests with a 304 Not Modified when the response did not change.
+// Responses carrying an ETag or a Last-Modified header set by the handler are checked against If-None-Match
+// and If-Modified-Since as soon as the header is written; successful GET responses without one are buffered
+// to compute a strong ETag from the SHA-256 of their body, unless they are flushed or exceed the maximum size.
+// The middleware must be wrapped by Compress, so that ETags are computed on the uncompressed content;
+// Compress then marks them as weak for the compressed responses.
+func ETag(opts ...ETagOption) func(http.Handler) http.Handler {
+	cfg := &etagConfig{maxSize: defaultETagMaxSize}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ew := &etagWriter{ResponseWriter: w, r: r, cfg: cfg}
+			next.ServeHTTP(ew, r)
+			ew.finish()
+		})
+	}
+}
+
+type etagMode int
+
+const (
+	etagPending etagMode = iota
+	etagBuffering
+	etagPassthrough
+	etagDiscard
+)
+
+// etagWriter buffers the response to compute its ETag, or checks the one provided by the handler.
+type etagWriter struct {
+	http.ResponseWriter
+	r      *http.Request
+	cfg    *etagConfig
+	mode   etagMode
+	status int
+	buf    bytes.Buffer
+}
+
+func (ew *etagWriter) WriteHeader(code int) {
+	if ew.mode != etagPending {
+		return
+	}
+	ew.status = code
+	h := ew.Header()
+	switch {
+	case code != http.StatusOK:
+		ew.passthrough()
+	case h.Get("ETag") != "" || h.Get("Last-Modified") != "":
+		if ew.notModified() {
+			ew.writeNotModified()
+			return
+		}
+		ew.passthrough()
+	case ew.r.Method == http.MethodHead:
+		ew.passthrough()
+	default:
+		ew.mode = etagBuffering
+	}
+}
+
+func (ew *etagWriter) Write(p []byte) (int, error) {
+	if ew.mode == etagPending {
+		ew.WriteHeader(http.StatusOK)
+	}
+	switch ew.mode {
+	case etagDiscard:
+		return len(p), nil
+	case etagBuffering:
+		if ew.buf.Len()+len(p) <= ew.cfg.maxSize {
+			return ew.buf.Write(p)
+		}
+		if err := ew.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return ew.ResponseWriter.Write(p)
+}
+
+// Flush gives up on the ETag of buffered responses, sending what was written so far.
+func (ew *etagWriter) Flush() {
+	if ew.mode == etagBuffering {
+		_ = ew.flushBuffer()
+	}
+	if ew.mode == etagDiscard {
+		return
+	}
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the wrapped writer does, giving up on the ETag.
+func (ew *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(ew.ResponseWriter).Hijack()
+	if err == nil {
+		ew.mode = etagPassthrough
+		ew.buf.Reset()
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// finish computes the ETag of the buffered response and sends it, or a 304 if the client has it already.
+func (ew *etagWriter) finish() {
+	if ew.mode == etagPending {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.mode != etagBuffering {
+		return
+	}
+	sum := sha256.Sum256(ew.buf.Bytes())
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if ew.cfg.weak {
+		etag = "W/" + etag
+	}
+	ew.Header().Set("ETag", etag)
+	if ew.notModified() {
+		ew.writeNotModified()
+		return
+	}
+	ew.Header().Set("Content-Length", strconv.Itoa(ew.buf.Len()))
+	_ = ew.flushBuffer()
+}
+
+func (ew *etagWriter) passthrough() {
+	ew.mode = etagPassthrough
+	ew.ResponseWriter.WriteHeader(ew.status)
+}
+
+func (ew *etagWriter) flushBuffer() error {
+	ew.passthrough()
+	_, err := ew.ResponseWriter.Write(ew.buf.Bytes())
+	ew.buf.Reset()
+	return err
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since in its absence, against the response headers.
+func (ew *etagWriter) notModified() bool {
+	h := ew.Header()
+	if inm := ew.r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		return etag != "" && etagMatch(inm, etag)
+	}
+	ims, err := http.ParseTime(ew.r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.Truncate(time.Second).After(ims)
+}
+
+// writeNotModified sends a 304, dropping the headers describing the body, as http.ServeContent does.
+func (ew *etagWriter) writeNotModified() {
+	ew.mode = etagDiscard
+	h := ew.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	if h.Get("ETag") != "" {
+		h.Del("Last-Modified")
+	}
+	ew.ResponseWriter.WriteHeader(http.StatusNotModified)
+}
+
+// etagMatch reports whether the If-None-Match header in input matches the ETag, using the weak comparison.
+func etagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/indiependente/pkg/jwt"
+)
+
+// JWKSKeyfunc returns a jwt.Keyfunc resolving keys by key ID from the JSON Web Key Set published at url.
+// The key set is cached for ttl and fetched again earlier when a token references an unknown key ID.
+// A nil client defaults to http.DefaultClient. See jwt.NewJWKS for background refreshes.
+func JWKSKeyfunc(client *http.Client, url string, ttl time.Duration) gojwt.Keyfunc {
+	opts := []jwt.JWKSOption{jwt.WithTTL(ttl)}
+	if client != nil {
+		opts = append(opts, jwt.WithHTTPClient(client))
+	}
+	return jwt.NewJWKS(url, opts...).Keyfunc
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/indiependente/pkg/ipx"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/requestid"
+	"github.com/indiependente/pkg/useragent"
+)
+
+const (
+	loggingEvent = "http_server"
+	redacted     = "[REDACTED]"
+)
+
+// sensitiveHeaders are always redacted when headers are logged.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// LoggingOption customises the logging middleware.
+type LoggingOption func(*loggingConfig)
+
+type loggingConfig struct {
+	exclude      map[string]struct{}
+	logHeaders   bool
+	redact       map[string]struct{}
+	trusted      []netip.Prefix
+	clientIPOpts []ipx.ClientIPOption
+	parseUA      bool
+}
+
+// ExcludePaths disables logging for requests to the paths in input, e.g. health checks.
+func ExcludePaths(paths ...string) LoggingOption {
+	return func(c *loggingConfig) {
+		for _, p := range paths {
+			c.exclude[p] = struct{}{}
+		}
+	}
+}
+
+// LogHeaders instructs the middleware to log the request headers at debug level.
+// Sensitive headers, along with the ones in input, are redacted.
+func LogHeaders(redact ...string) LoggingOption {
+	return func(c *loggingConfig) {
+		c.logHeaders = true
+		for _, h := range redact {
+			c.redact[http.CanonicalHeaderKey(h)] = struct{}{}
+		}
+	}
+}
+
+// WithTrustedProxies logs the address of the client, read from the forwarding headers when the request comes
+// from one of the trusted proxies as ipx.ClientIP does with the options in input, instead of the remote
+// address of the connection.
+func WithTrustedProxies(prefixes []netip.Prefix, opts ...ipx.ClientIPOption) LoggingOption {
+	return func(c *loggingConfig) {
+		c.trusted = prefixes
+		c.clientIPOpts = opts
+	}
+}
+
+// WithUserAgentParsing parses the user agent of every request with useragent.Parse, logging its family,
+// e.g. Chrome or Googlebot, and whether it is a bot.
+func WithUserAgentParsing() LoggingOption {
+	return func(c *loggingConfig) {
+		c.parseUA = true
+	}
+}
+
+// Logging logs every request handled by the next handler, along with its method, URI, status code, bytes written,
+// duration, remote address, user agent and request ID.
+// The request ID is the one set by the RequestID middleware, whether it runs before or after Logging, falling back
+// to a valid X-Request-ID request header.
+// The logger is stored in the request context, so that handlers can retrieve it with logger.FromContext.
+func Logging(log logger.Logger, opts ...LoggingOption) func(http.Handler) http.Handler {
+	cfg := &loggingConfig{
+		exclude: make(map[string]struct{}),
+		redact:  make(map[string]struct{}, len(sensitiveHeaders)),
+	}
+	for _, h := range sensitiveHeaders {
+		cfg.redact[h] = struct{}{}
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.WithContext(logger.NewContext(r.Context(), log))
+			if _, ok := cfg.exclude[r.URL.Path]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rw := newResponseWriter(w)
+			// the holder receives the request ID set by a RequestID middleware running after this one
+			holder := &requestIDHolder{}
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), requestIDHolderKey{}, holder)))
+
+			remoteAddr := r.RemoteAddr
+			if cfg.trusted != nil {
+				if addr := ipx.ClientIP(r, cfg.trusted, cfg.clientIPOpts...); addr.IsValid() {
+					remoteAddr = addr.String()
+				}
+			}
+
+			l := log.Event(loggingEvent).
+				Method(r.Method).
+				URI(r.RequestURI).
+				Host(r.Host).
+				RemoteAddr(remoteAddr).
+				UserAgent(r.UserAgent()).
+				StatusCode(rw.status).
+				BytesWritten(rw.bytesWritten()).
+				Duration(time.Since(start))
+			l = logger.WithTrace(r.Context(), l)
+			if id, ok := requestid.FromContext(r.Context()); ok {
+				l = l.RequestID(id)
+			} else if id, ok := holder.id.Load().(string); ok {
+				l = l.RequestID(id)
+			} else if id := r.Header.Get(requestid.Header); id != "" && validRequestID(id) {
+				l = l.RequestID(id)
+			}
+			if cfg.parseUA {
+				ua := useragent.Parse(r.UserAgent())
+				l = l.UserAgentFamily(ua.Family).IsBot(ua.IsBot)
+			}
+			if cfg.logHeaders {
+				l.Headers(redactHeaders(r.Header, cfg.redact)).Debug("Request headers")
+			}
+			l.Info("Request handled")
+		})
+	}
+}
+
+// requestIDHolderKey is the context key of the requestIDHolder.
+type requestIDHolderKey struct{}
+
+// requestIDHolder carries the request ID set by the RequestID middleware back to the Logging one wrapping it.
+type requestIDHolder struct {
+	id atomic.Value
+}
+
+func redactHeaders(h http.Header, redact map[string]struct{}) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if _, ok := redact[http.CanonicalHeaderKey(k)]; ok {
+			out[k] = []string{redacted}
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
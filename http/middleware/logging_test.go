@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/requestid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestLoggingRequestID(t *testing.T) {
+	generated := WithRequestIDGenerator(func() string { return "generated" })
+	custom := WithRequestIDHeader("X-Correlation-ID")
+	tests := []struct {
+		name    string
+		chain   func(log logger.Logger, h http.Handler) http.Handler
+		headers map[string]string
+		want    string
+	}{
+		{
+			name: "request ID middleware outside",
+			chain: func(log logger.Logger, h http.Handler) http.Handler {
+				return RequestID(generated)(Logging(log)(h))
+			},
+			want: "generated",
+		},
+		{
+			name: "request ID middleware inside",
+			chain: func(log logger.Logger, h http.Handler) http.Handler {
+				return Logging(log)(RequestID(generated)(h))
+			},
+			want: "generated",
+		},
+		{
+			name: "request ID middleware inside with a custom header",
+			chain: func(log logger.Logger, h http.Handler) http.Handler {
+				return Logging(log)(RequestID(generated, custom)(h))
+			},
+			headers: map[string]string{"X-Correlation-ID": "abc-123", requestid.Header: "other"},
+			want:    "abc-123",
+		},
+		{
+			name: "request ID middleware inside a timeout",
+			chain: func(log logger.Logger, h http.Handler) http.Handler {
+				return Logging(log)(Timeout(time.Second)(RequestID(generated)(h)))
+			},
+			want: "generated",
+		},
+		{
+			name: "request header",
+			chain: func(log logger.Logger, h http.Handler) http.Handler {
+				return Logging(log)(h)
+			},
+			headers: map[string]string{requestid.Header: "abc-123"},
+			want:    "abc-123",
+		},
+		{
+			name: "invalid request header",
+			chain: func(log logger.Logger, h http.Handler) http.Handler {
+				return Logging(log)(h)
+			},
+			headers: map[string]string{requestid.Header: "abc\n123"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			global := log.Logger
+			log.Logger = zerolog.New(&buf)
+			defer func() {
+				log.Logger = global
+			}()
+			h := tt.chain(logger.GetLoggerString("test", "INFO"), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			var line struct {
+				RequestID string `json:"request_id"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("could not decode log line %q: %v", buf.String(), err)
+			}
+			if line.RequestID != tt.want {
+				t.Fatalf("logged request ID %q, want %q", line.RequestID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const problemTooLarge = `{"type":"about:blank","title":"Request Entity Too Large","status":413,"detail":"body must not be larger than %d bytes"}`
+
+// MaxBytes limits the size of the request bodies to n bytes.
+// Requests declaring a larger Content-Length are answered right away with a 413 and a problem+json body;
+// the others have their body wrapped with http.MaxBytesReader, so that reading past the limit fails with an
+// *http.MaxBytesError, which respond.Error and bind.JSON render as a 413, and the connection gets closed.
+func MaxBytes(n int64) func(http.Handler) http.Handler {
+	body := []byte(fmt.Sprintf(problemTooLarge, n))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.Header().Set("Connection", "close")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				_, _ = w.Write(body)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// unmatchedRoute labels requests that did not match any route pattern.
+	unmatchedRoute = "unmatched"
+	// otherMethod labels requests with a non standard method.
+	otherMethod = "OTHER"
+)
+
+// standardMethods are the methods used as labels, any other one being labeled as otherMethod so that clients
+// cannot create unbounded label values.
+var standardMethods = map[string]struct{}{
+	http.MethodGet: {}, http.MethodHead: {}, http.MethodPost: {}, http.MethodPut: {}, http.MethodPatch: {},
+	http.MethodDelete: {}, http.MethodConnect: {}, http.MethodOptions: {}, http.MethodTrace: {},
+}
+
+// MetricsOption customises the metrics middleware.
+type MetricsOption func(*metricsConfig)
+
+type metricsConfig struct {
+	route func(*http.Request) string
+}
+
+// WithRouteFunc labels requests with the route returned by the function in input,
+// instead of the pattern matched by http.ServeMux.
+func WithRouteFunc(route func(*http.Request) string) MetricsOption {
+	return func(c *metricsConfig) {
+		c.route = route
+	}
+}
+
+// Metrics instruments the next handler with request duration histograms, request and response size summaries
+// labeled by method, route and status class, and an in-flight requests gauge. Non standard methods are labeled OTHER.
+// Routes are the patterns matched by http.ServeMux, so the middleware must wrap the mux directly,
+// unless a route function is provided. Collectors already registered on the registerer are reused.
+func Metrics(registerer prometheus.Registerer, opts ...MetricsOption) func(http.Handler) http.Handler {
+	cfg := &metricsConfig{
+		route: func(r *http.Request) string {
+			if r.Pattern == "" {
+				return unmatchedRoute
+			}
+			return r.Pattern
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	labels := []string{"method", "route", "status_class"}
+	duration := metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Duration of the HTTP requests handled by the server.",
+		Buckets: prometheus.DefBuckets,
+	}, labels))
+	reqSize := metrics.Register(registerer, prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "http_server_request_size_bytes",
+		Help:       "Size of the bodies of the HTTP requests handled by the server.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	}, labels))
+	respSize := metrics.Register(registerer, prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "http_server_response_size_bytes",
+		Help:       "Size of the bodies of the HTTP responses written by the server.",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	}, labels))
+	inFlight := metrics.Register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_server_requests_in_flight",
+		Help: "Number of HTTP requests being handled by the server.",
+	}))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			rw := newResponseWriter(w)
+			next.ServeHTTP(rw, r)
+
+			l := prometheus.Labels{
+				"method":       methodLabel(r.Method),
+				"route":        cfg.route(r),
+				"status_class": strconv.Itoa(rw.status/100) + "xx",
+			}
+			duration.With(l).Observe(time.Since(start).Seconds())
+			if r.ContentLength > 0 {
+				reqSize.With(l).Observe(float64(r.ContentLength))
+			} else {
+				reqSize.With(l).Observe(0)
+			}
+			respSize.With(l).Observe(float64(rw.bytesWritten()))
+		})
+	}
+}
+
+// methodLabel returns the label of the method, otherMethod if it is not a standard one.
+func methodLabel(method string) string {
+	if _, ok := standardMethods[method]; ok {
+		return method
+	}
+	return otherMethod
+}
+
+// MountMetrics serves the metrics gathered by the gatherer in input on the /metrics path of the admin mux.
+func MountMetrics(mux *http.ServeMux, gatherer prometheus.Gatherer) {
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsMethodLabel(t *testing.T) {
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{method: http.MethodGet, want: http.MethodGet},
+		{method: http.MethodPost, want: http.MethodPost},
+		{method: http.MethodOptions, want: http.MethodOptions},
+		{method: "PROPFIND", want: otherMethod},
+		{method: "get", want: otherMethod},
+		{method: "X-RANDOM-1234", want: otherMethod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			h := Metrics(registry)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/", nil))
+
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range families {
+				if f.GetName() != "http_server_request_duration_seconds" {
+					continue
+				}
+				for _, m := range f.GetMetric() {
+					for _, l := range m.GetLabel() {
+						if l.GetName() == "method" {
+							got = append(got, l.GetValue())
+						}
+					}
+				}
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("got method labels %v, want [%s]", got, tt.want)
+			}
+		})
+	}
+}
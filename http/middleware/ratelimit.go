@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/indiependente/pkg/ipx"
+	"github.com/indiependente/pkg/ratelimit"
+	"github.com/indiependente/pkg/tenant"
+)
+
+const defaultRateLimitShards = 32
+
+// KeyFunc returns the key a request is rate limited by.
+type KeyFunc func(*http.Request) string
+
+// KeyByIP rate limits requests by the IP address of the client.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByClientIP rate limits requests by the IP address of the client, read from the forwarding headers
+// when the request comes from one of the trusted proxies, as ipx.ClientIP does with the options in input.
+func KeyByClientIP(trustedProxies []netip.Prefix, opts ...ipx.ClientIPOption) KeyFunc {
+	return func(r *http.Request) string {
+		if addr := ipx.ClientIP(r, trustedProxies, opts...); addr.IsValid() {
+			return addr.String()
+		}
+		return KeyByIP(r)
+	}
+}
+
+// KeyByHeader rate limits requests by the value of the header in input, e.g. an API key.
+func KeyByHeader(header string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// KeyByTenant rate limits requests by the tenant stored in their context by tenant.Middleware, falling back to the
+// key returned by the function in input for requests without a tenant, e.g. KeyByIP.
+func KeyByTenant(fallback KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		if id, ok := tenant.FromContext(r.Context()); ok {
+			return "tenant:" + id
+		}
+		return fallback(r)
+	}
+}
+
+// LimitFunc returns the rate and burst a request is limited to, e.g. looked up by tenant plan,
+// reporting false to use the default ones.
+type LimitFunc func(*http.Request) (rps float64, burst int, ok bool)
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult = ratelimit.Result
+
+// RateLimitStore keeps the token buckets, e.g. in memory or in Redis to share limits across instances.
+type RateLimitStore = ratelimit.Store
+
+// RateLimitOption customises the rate limiting middleware.
+type RateLimitOption func(*rateLimitConfig)
+
+type rateLimitConfig struct {
+	keyFunc   KeyFunc
+	limitFunc LimitFunc
+	store     RateLimitStore
+}
+
+// WithKeyFunc rate limits requests by the key returned by the function in input instead of the client IP.
+func WithKeyFunc(fn KeyFunc) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.keyFunc = fn
+	}
+}
+
+// WithLimitFunc limits requests to the rate and burst returned by the function in input, e.g. to give tenants
+// different limits. Requests for which it reports false are limited to the default rate and burst.
+func WithLimitFunc(fn LimitFunc) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.limitFunc = fn
+	}
+}
+
+// WithRateLimitStore keeps the token buckets in the store in input instead of in memory.
+func WithRateLimitStore(store RateLimitStore) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.store = store
+	}
+}
+
+// RateLimit allows rps requests per second per key, with bursts of up to burst requests,
+// answering excess requests with 429 Too Many Requests and a Retry-After header.
+// Every response carries the X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers.
+// Requests are let through if the store fails.
+func RateLimit(rps float64, burst int, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	cfg := &rateLimitConfig{
+		keyFunc: KeyByIP,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = NewMemoryRateLimitStore(defaultRateLimitShards)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rps, burst := rps, burst
+			if cfg.limitFunc != nil {
+				if lrps, lburst, ok := cfg.limitFunc(r); ok {
+					rps, burst = lrps, lburst
+				}
+			}
+			res, err := cfg.store.Take(r.Context(), cfg.keyFunc(r), rps, burst)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(burst))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore keeps token buckets in memory, spread across shards to reduce lock contention.
+type MemoryRateLimitStore = ratelimit.MemoryStore
+
+// NewMemoryRateLimitStore returns a MemoryRateLimitStore with the number of shards in input.
+func NewMemoryRateLimitStore(shards int) *MemoryRateLimitStore {
+	return ratelimit.NewMemoryStore(shards)
+}
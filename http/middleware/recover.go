@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/indiependente/pkg/logger"
+)
+
+const problemInternalError = `{"type":"about:blank","title":"Internal Server Error","status":500}`
+
+// RecoverOption customises the recover middleware.
+type RecoverOption func(*recoverConfig)
+
+type recoverConfig struct {
+	contentType    string
+	body           []byte
+	repanicOnAbort bool
+}
+
+// WithRecoverResponse answers requests whose handler panicked with the body and content type in input.
+func WithRecoverResponse(contentType string, body []byte) RecoverOption {
+	return func(c *recoverConfig) {
+		c.contentType = contentType
+		c.body = body
+	}
+}
+
+// WithProblemJSON answers requests whose handler panicked with an RFC 7807 problem+json body.
+func WithProblemJSON() RecoverOption {
+	return WithRecoverResponse("application/problem+json", []byte(problemInternalError))
+}
+
+// RepanicOnAbort re-panics, without logging, when the handler panics with http.ErrAbortHandler,
+// letting the server abort the response as intended.
+func RepanicOnAbort() RecoverOption {
+	return func(c *recoverConfig) {
+		c.repanicOnAbort = true
+	}
+}
+
+// Recover recovers from panics of the next handler, logs the panic value and the stack trace at error level
+// and answers with 500 Internal Server Error.
+func Recover(log logger.Logger, opts ...RecoverOption) func(http.Handler) http.Handler {
+	cfg := &recoverConfig{
+		contentType: "text/plain; charset=utf-8",
+		body:        []byte(http.StatusText(http.StatusInternalServerError) + "\n"),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) && cfg.repanicOnAbort {
+					panic(rec)
+				}
+				log.Event("panic").
+					Method(r.Method).
+					URI(r.RequestURI).
+					Stack(string(debug.Stack())).
+					Error("Recovered from panic", panicError(rec))
+
+				w.Header().Set("Content-Type", cfg.contentType)
+				w.Header().Set("X-Content-Type-Options", "nosniff")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write(cfg.body)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// panicError converts a recovered value to an error.
+func panicError(rec interface{}) error {
+	if err, ok := rec.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", rec)
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/indiependente/pkg/id"
+	"github.com/indiependente/pkg/requestid"
+)
+
+// RequestIDOption customises the request ID middleware.
+type RequestIDOption func(*requestIDConfig)
+
+// maxRequestIDLength is the maximum length of the request IDs accepted from clients by default.
+const maxRequestIDLength = 128
+
+type requestIDConfig struct {
+	header   string
+	generate func() string
+	valid    func(string) bool
+}
+
+// WithRequestIDHeader reads and writes the request ID from the header in input instead of X-Request-ID.
+func WithRequestIDHeader(header string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.header = header
+	}
+}
+
+// WithRequestIDGenerator generates missing request IDs with the function in input instead of time ordered UUIDv7s.
+func WithRequestIDGenerator(generate func() string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.generate = generate
+	}
+}
+
+// WithRequestIDValidator accepts the request IDs received from clients only if valid returns true for them,
+// e.g. id.Valid to accept only UUIDs and ULIDs. By default IDs up to 128 printable ASCII characters are accepted.
+func WithRequestIDValidator(valid func(string) bool) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.valid = valid
+	}
+}
+
+// RequestID reads the request ID from the request header, generating one when missing or invalid,
+// stores it in the request context, where requestid.FromContext can retrieve it, and echoes it on the response.
+func RequestID(opts ...RequestIDOption) func(http.Handler) http.Handler {
+	cfg := &requestIDConfig{
+		header:   requestid.Header,
+		generate: id.NewUUIDv7,
+		valid:    validRequestID,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqID := r.Header.Get(cfg.header)
+			if reqID == "" || !cfg.valid(reqID) {
+				reqID = cfg.generate()
+			}
+			w.Header().Set(cfg.header, reqID)
+			if holder, ok := r.Context().Value(requestIDHolderKey{}).(*requestIDHolder); ok {
+				holder.id.Store(reqID)
+			}
+			next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), reqID)))
+		})
+	}
+}
+
+// validRequestID reports whether the request ID is short and made of printable ASCII characters only, so that it
+// cannot be used to forge log lines or headers.
+func validRequestID(s string) bool {
+	if len(s) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] <= ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/indiependente/pkg/id"
+	"github.com/indiependente/pkg/requestid"
+)
+
+func TestRequestID(t *testing.T) {
+	generated := func() string { return "generated" }
+	tests := []struct {
+		name   string
+		opts   []RequestIDOption
+		header string
+		want   string
+	}{
+		{name: "missing", want: "generated"},
+		{name: "valid", header: "abc-123", want: "abc-123"},
+		{name: "too long", header: strings.Repeat("a", maxRequestIDLength+1), want: "generated"},
+		{name: "longest", header: strings.Repeat("a", maxRequestIDLength), want: strings.Repeat("a", maxRequestIDLength)},
+		{name: "spaces", header: "abc 123", want: "generated"},
+		{name: "control characters", header: "abc\x1b[31m", want: "generated"},
+		{name: "non ASCII", header: "abcé", want: "generated"},
+		{name: "custom validator rejecting", opts: []RequestIDOption{WithRequestIDValidator(id.Valid)}, header: "abc-123", want: "generated"},
+		{
+			name:   "custom validator accepting",
+			opts:   []RequestIDOption{WithRequestIDValidator(id.Valid)},
+			header: "0190b2a4-5c1e-7cc3-9b2e-2f5e4c8a9d10",
+			want:   "0190b2a4-5c1e-7cc3-9b2e-2f5e4c8a9d10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			opts := append([]RequestIDOption{WithRequestIDGenerator(generated)}, tt.opts...)
+			h := RequestID(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = requestid.FromContext(r.Context())
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(requestid.Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if got != tt.want {
+				t.Fatalf("got request ID %q in the context, want %q", got, tt.want)
+			}
+			if echoed := w.Header().Get(requestid.Header); echoed != tt.want {
+				t.Fatalf("got request ID %q in the response, want %q", echoed, tt.want)
+			}
+		})
+	}
+}
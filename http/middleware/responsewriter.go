@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/indiependente/pkg/streamio"
+)
+
+// responseWriter records the status code and the number of bytes written by a handler.
+type responseWriter struct {
+	http.ResponseWriter
+	body        *streamio.CountingWriter
+	status      int
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, body: streamio.NewCountingWriter(w), status: http.StatusOK}
+}
+
+// WriteHeader records the status code before writing it.
+func (rw *responseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the bytes written.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.body.Write(b)
+}
+
+// bytesWritten returns the number of bytes written.
+func (rw *responseWriter) bytesWritten() int {
+	return int(rw.body.N())
+}
+
+// Flush implements http.Flusher when the wrapped writer does.
+func (rw *responseWriter) Flush() {
+	rw.wroteHeader = true
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker when the wrapped writer does, e.g. for websocket upgrades.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.wroteHeader = true
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
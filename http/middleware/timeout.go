@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	problemTimeout = `{"type":"about:blank","title":"Request Timeout","status":%d}`
+
+	// requestTimeoutHeader carries the time left to the caller in milliseconds, as set by
+	// client.WithDeadlinePropagation.
+	requestTimeoutHeader = "X-Request-Timeout"
+)
+
+// TimeoutOption customises the timeout middleware.
+type TimeoutOption func(*timeoutConfig)
+
+type timeoutConfig struct {
+	timeout     time.Duration
+	status      int
+	contentType string
+	body        []byte
+	routes      map[string]time.Duration
+	propagated  bool
+}
+
+// WithTimeoutStatus answers requests exceeding their deadline with the status code in input instead of 503.
+func WithTimeoutStatus(code int) TimeoutOption {
+	return func(c *timeoutConfig) {
+		c.status = code
+	}
+}
+
+// WithTimeoutResponse answers requests exceeding their deadline with the body and content type in input.
+func WithTimeoutResponse(contentType string, body []byte) TimeoutOption {
+	return func(c *timeoutConfig) {
+		c.contentType = contentType
+		c.body = body
+	}
+}
+
+// WithRouteTimeout applies the timeout in input to requests whose path starts with prefix.
+// The longest matching prefix wins; a zero timeout disables the middleware for the route.
+func WithRouteTimeout(prefix string, d time.Duration) TimeoutOption {
+	return func(c *timeoutConfig) {
+		c.routes[prefix] = d
+	}
+}
+
+// WithPropagatedDeadline shortens the timeout of the requests to the time left to their caller, as sent in the
+// X-Request-Timeout header by client.WithDeadlinePropagation, so that the work is abandoned once nobody waits for it.
+func WithPropagatedDeadline() TimeoutOption {
+	return func(c *timeoutConfig) {
+		c.propagated = true
+	}
+}
+
+// Timeout runs the next handler with a context deadline of d.
+// If the handler does not complete in time the client receives a 503 with a problem+json body,
+// and any later write by the handler fails with http.ErrHandlerTimeout.
+// The response is buffered until the handler completes.
+func Timeout(d time.Duration, opts ...TimeoutOption) func(http.Handler) http.Handler {
+	cfg := &timeoutConfig{
+		timeout:     d,
+		status:      http.StatusServiceUnavailable,
+		contentType: "application/problem+json",
+		routes:      make(map[string]time.Duration),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.body == nil {
+		cfg.body = []byte(fmt.Sprintf(problemTimeout, cfg.status))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := cfg.timeoutFor(r.URL.Path)
+			if cfg.propagated {
+				timeout = propagatedTimeout(r, timeout)
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{w: w, header: make(http.Header), status: http.StatusOK}
+			done := make(chan struct{})
+			panicCh := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicCh <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicCh:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, v := range tw.header {
+					dst[k] = v
+				}
+				w.WriteHeader(tw.status)
+				_, _ = w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				w.Header().Set("Content-Type", cfg.contentType)
+				w.WriteHeader(cfg.status)
+				_, _ = w.Write(cfg.body)
+			}
+		})
+	}
+}
+
+func (c *timeoutConfig) timeoutFor(path string) time.Duration {
+	timeout, longest := c.timeout, -1
+	for prefix, d := range c.routes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			timeout, longest = d, len(prefix)
+		}
+	}
+	return timeout
+}
+
+// propagatedTimeout returns the time left to the caller of the request, if shorter than timeout or if timeout
+// is disabled.
+func propagatedTimeout(r *http.Request, timeout time.Duration) time.Duration {
+	ms, err := strconv.ParseInt(r.Header.Get(requestTimeoutHeader), 10, 64)
+	if err != nil || ms <= 0 {
+		return timeout
+	}
+	// clamp before converting, as huge values overflow into negative durations disabling the timeout
+	if timeout > 0 && ms >= int64(timeout/time.Millisecond) {
+		return timeout
+	}
+	if ms > int64(math.MaxInt64/time.Millisecond) {
+		return math.MaxInt64
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// timeoutWriter buffers the response of a handler until it completes or times out.
+type timeoutWriter struct {
+	w http.ResponseWriter
+
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.status = code
+	tw.wroteHeader = true
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteHeader = true
+	return tw.buf.Write(b)
+}
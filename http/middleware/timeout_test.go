@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPropagatedTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{name: "no header", timeout: time.Second, want: time.Second},
+		{name: "invalid header", header: "soon", timeout: time.Second, want: time.Second},
+		{name: "negative header", header: "-5", timeout: time.Second, want: time.Second},
+		{name: "shorter", header: "250", timeout: time.Second, want: 250 * time.Millisecond},
+		{name: "longer", header: "5000", timeout: time.Second, want: time.Second},
+		{name: "overflowing", header: "9223372036854775807", timeout: time.Second, want: time.Second},
+		{name: "overflowing by a little", header: "9223372036855", timeout: time.Second, want: time.Second},
+		{name: "disabled timeout", header: "250", want: 250 * time.Millisecond},
+		{name: "overflowing with disabled timeout", header: "9223372036854775807", want: time.Duration(1<<63 - 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(requestTimeoutHeader, tt.header)
+			}
+			if got := propagatedTimeout(r, tt.timeout); got != tt.want {
+				t.Fatalf("propagatedTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutKeepsDeadlineWithHugePropagatedTimeout(t *testing.T) {
+	var hasDeadline bool
+	h := Timeout(time.Second, WithPropagatedDeadline())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		hasDeadline = ok && time.Until(deadline) <= time.Second
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(requestTimeoutHeader, "9223372036854775807")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if !hasDeadline {
+		t.Fatal("handler ran without the server-side deadline")
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// tracingOperation is the default name of server spans.
+const tracingOperation = "http.server"
+
+// Tracing starts a server span for every request, continuing the trace propagated by the caller
+// via W3C traceparent or B3 headers, and stores it in the request context, where the logger,
+// logger.WithTrace, and the instrumented client package pick it up.
+// The tracer provider and propagators default to the global ones and can be overridden via the options in input.
+func Tracing(opts ...otelhttp.Option) func(http.Handler) http.Handler {
+	defaults := []otelhttp.Option{
+		otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+			b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader|b3.B3SingleHeader)),
+		)),
+	}
+	opts = append(defaults, opts...)
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, tracingOperation, opts...)
+	}
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"net/url"
+	"sync/atomic"
+)
+
+// Upstream is a server requests are forwarded to.
+type Upstream struct {
+	URL *url.URL
+
+	active  atomic.Int64
+	healthy atomic.Bool
+}
+
+// Active returns the number of requests in flight to the upstream.
+func (u *Upstream) Active() int64 {
+	return u.active.Load()
+}
+
+// Healthy reports whether the upstream passed its last health check. Upstreams start healthy.
+func (u *Upstream) Healthy() bool {
+	return u.healthy.Load()
+}
+
+// Balancer picks the upstream of the next attempt among the candidates in input, which are never empty.
+// Implementations must be safe for concurrent use.
+type Balancer interface {
+	Pick(candidates []*Upstream) *Upstream
+}
+
+// BalancerFunc is an adapter to allow the use of ordinary functions as Balancer.
+type BalancerFunc func(candidates []*Upstream) *Upstream
+
+// Pick calls f(candidates).
+func (f BalancerFunc) Pick(candidates []*Upstream) *Upstream {
+	return f(candidates)
+}
+
+// RoundRobin returns a Balancer cycling through the candidates.
+func RoundRobin() Balancer {
+	var next atomic.Uint64
+	return BalancerFunc(func(candidates []*Upstream) *Upstream {
+		return candidates[(next.Add(1)-1)%uint64(len(candidates))]
+	})
+}
+
+// LeastConnections returns a Balancer picking the candidate with the fewest requests in flight,
+// the first one on ties.
+func LeastConnections() Balancer {
+	return BalancerFunc(func(candidates []*Upstream) *Upstream {
+		best := candidates[0]
+		for _, u := range candidates[1:] {
+			if u.Active() < best.Active() {
+				best = u
+			}
+		}
+		return best
+	})
+}
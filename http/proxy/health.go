@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// CheckHealth probes every upstream once, updating their health. It is a no-op without WithHealthCheck.
+func (p *Proxy) CheckHealth(ctx context.Context) {
+	if p.healthPath == "" {
+		return
+	}
+	var wg sync.WaitGroup
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.probe(ctx, u)
+			if healthy := err == nil; u.healthy.Swap(healthy) != healthy && p.log != nil {
+				l := p.log.Event(proxyEvent).Host(u.URL.Host)
+				if healthy {
+					l.Info("Upstream is healthy again")
+				} else {
+					l.Err(err).Warn("Upstream is unhealthy")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Watch checks the health of the upstreams every health check interval until the context is done.
+// It is meant to be run in its own goroutine, and returns right away without WithHealthCheck.
+func (p *Proxy) Watch(ctx context.Context) {
+	if p.healthPath == "" || p.healthInterval <= 0 {
+		return
+	}
+	p.CheckHealth(ctx)
+	ticker := p.clock.NewTicker(p.healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			p.CheckHealth(ctx)
+		}
+	}
+}
+
+func (p *Proxy) probe(ctx context.Context, u *Upstream) error {
+	ctx, cancel := context.WithTimeout(ctx, p.healthInterval)
+	defer cancel()
+	target := *u.URL
+	target.Path = singleJoiningSlash(u.URL.Path, p.healthPath)
+	target.RawPath = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return fmt.Errorf("could not create health check request: %w", err)
+	}
+	resp, err := p.transport.RoundTrip(req)
+	if err != nil {
+		return fmt.Errorf("could not check health: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health check replied %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,492 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/http/middleware"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/requestid"
+	"github.com/indiependente/pkg/streamio"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// proxyEvent is the event of the log lines written by the proxy.
+	proxyEvent = "proxy"
+	// statusClientClosedRequest is the non-standard status of the requests whose client went away.
+	statusClientClosedRequest = 499
+)
+
+// Option customises the Proxy returned by New.
+type Option func(*Proxy)
+
+// WithUpstreams balances the requests between the target and the upstreams in input.
+func WithUpstreams(targets ...string) Option {
+	return func(p *Proxy) {
+		p.targets = append(p.targets, targets...)
+	}
+}
+
+// WithBalancer picks the upstreams with the balancer in input. Defaults to RoundRobin.
+func WithBalancer(b Balancer) Option {
+	return func(p *Proxy) {
+		p.balancer = b
+	}
+}
+
+// WithRetry retries idempotent requests without a body up to attempts times in total, on another upstream
+// when possible, if the upstream could not be reached or replied with a 502, 503 or 504.
+// Requests with a body are retried too with WithBodySpooling.
+func WithRetry(attempts int) Option {
+	return func(p *Proxy) {
+		p.attempts = attempts
+	}
+}
+
+// WithBodySpooling retries idempotent requests with a body, e.g. PUT, spooling the body in memory up to threshold
+// bytes and to a temporary file past it, so that it can be sent again to another upstream.
+func WithBodySpooling(threshold int64) Option {
+	return func(p *Proxy) {
+		p.spoolThreshold = threshold
+	}
+}
+
+// WithTimeout bounds every attempt, response body included, to d.
+// Attempts timing out before the response headers are received fail with a 504.
+func WithTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.timeout = d
+	}
+}
+
+// WithRequestHeader sets the header on the requests sent upstream, removing it if value is empty.
+func WithRequestHeader(name, value string) Option {
+	return func(p *Proxy) {
+		p.rewrites = append(p.rewrites, func(pr *httputil.ProxyRequest) {
+			setHeader(pr.Out.Header, name, value)
+		})
+	}
+}
+
+// WithResponseHeader sets the header on the responses sent back, removing it if value is empty.
+func WithResponseHeader(name, value string) Option {
+	return func(p *Proxy) {
+		p.modifiers = append(p.modifiers, func(resp *http.Response) error {
+			setHeader(resp.Header, name, value)
+			return nil
+		})
+	}
+}
+
+// WithRewrite customises the requests sent upstream with the function in input, called after the headers
+// are rewritten. The URL of the outgoing request is set to the upstream afterwards, on every attempt.
+func WithRewrite(fn func(*httputil.ProxyRequest)) Option {
+	return func(p *Proxy) {
+		p.rewrites = append(p.rewrites, fn)
+	}
+}
+
+// WithModifyResponse customises the responses sent back with the function in input.
+// An error fails the request with a 502.
+func WithModifyResponse(fn func(*http.Response) error) Option {
+	return func(p *Proxy) {
+		p.modifiers = append(p.modifiers, fn)
+	}
+}
+
+// WithTransport sends the requests upstream with the RoundTripper in input instead of a clone of http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(p *Proxy) {
+		p.transport = rt
+	}
+}
+
+// WithHealthCheck probes path on every upstream every interval once Watch is running, leaving out of the balancing
+// the upstreams not replying with a 2xx status. When every upstream is unhealthy, all of them are used.
+func WithHealthCheck(path string, interval time.Duration) Option {
+	return func(p *Proxy) {
+		p.healthPath = path
+		p.healthInterval = interval
+	}
+}
+
+// WithClock schedules the health checks with the clock in input.
+func WithClock(c clock.Clock) Option {
+	return func(p *Proxy) {
+		p.clock = c
+	}
+}
+
+// WithLogger logs the requests handled with middleware.Logging and the upstream failures.
+func WithLogger(log logger.Logger) Option {
+	return func(p *Proxy) {
+		p.log = log
+	}
+}
+
+// WithMetrics instruments the proxy with middleware.Metrics, labeling the requests with the "proxy" route,
+// and counts the attempts by upstream and status class on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(p *Proxy) {
+		p.registerer = registerer
+	}
+}
+
+// WithTracing starts a server span for every request with middleware.Tracing
+// and a client span for every attempt, propagating the trace upstream.
+func WithTracing(opts ...otelhttp.Option) Option {
+	return func(p *Proxy) {
+		p.tracing = true
+		p.tracingOpts = opts
+	}
+}
+
+// Proxy is a reverse proxy balancing the requests between its upstreams.
+type Proxy struct {
+	targets        []string
+	balancer       Balancer
+	attempts       int
+	spoolThreshold int64
+	timeout        time.Duration
+	rewrites       []func(*httputil.ProxyRequest)
+	modifiers      []func(*http.Response) error
+	transport      http.RoundTripper
+	healthPath     string
+	healthInterval time.Duration
+	clock          clock.Clock
+	log            logger.Logger
+	registerer     prometheus.Registerer
+	tracing        bool
+	tracingOpts    []otelhttp.Option
+
+	upstreams []*Upstream
+	requests  *prometheus.CounterVec
+	handler   http.Handler
+}
+
+// compile time interface check.
+var _ http.Handler = &Proxy{}
+
+// New returns a Proxy forwarding the requests to the target URL in input, e.g. http://backend:8080/api.
+// The path of the target is prepended to the path of the requests.
+func New(target string, opts ...Option) (*Proxy, error) {
+	p := &Proxy{
+		targets:  []string{target},
+		balancer: RoundRobin(),
+		attempts: 1,
+		clock:    clock.New(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for _, t := range p.targets {
+		u, err := url.Parse(t)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse upstream %q: %w", t, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("could not parse upstream %q: missing scheme or host", t)
+		}
+		up := &Upstream{URL: u}
+		up.healthy.Store(true)
+		p.upstreams = append(p.upstreams, up)
+	}
+	if p.transport == nil {
+		p.transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if p.tracing {
+		defaults := []otelhttp.Option{
+			otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator(
+				propagation.TraceContext{},
+				propagation.Baggage{},
+			)),
+		}
+		p.transport = otelhttp.NewTransport(p.transport, append(defaults, p.tracingOpts...)...)
+	}
+	if p.registerer != nil {
+		p.requests = metrics.Register(p.registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_proxy_upstream_requests_total",
+			Help: "Number of requests sent upstream by upstream and status class.",
+		}, []string{"upstream", "status_class"}))
+	}
+
+	rp := &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      &balancingTransport{p: p},
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.handleError,
+	}
+	var handler http.Handler = rp
+	if p.registerer != nil {
+		handler = middleware.Metrics(p.registerer, middleware.WithRouteFunc(func(*http.Request) string {
+			return proxyEvent
+		}))(handler)
+	}
+	if p.tracing {
+		handler = middleware.Tracing(p.tracingOpts...)(handler)
+	}
+	if p.log != nil {
+		handler = middleware.Logging(p.log)(handler)
+	}
+	p.handler = handler
+	return p, nil
+}
+
+// ServeHTTP forwards the request upstream.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.handler.ServeHTTP(w, r)
+}
+
+// Upstreams returns the upstreams of the proxy.
+func (p *Proxy) Upstreams() []*Upstream {
+	return p.upstreams
+}
+
+func (p *Proxy) rewrite(pr *httputil.ProxyRequest) {
+	pr.SetXForwarded()
+	pr.Out.Host = ""
+	if id, ok := requestid.FromContext(pr.In.Context()); ok {
+		pr.Out.Header.Set(requestid.Header, id)
+	}
+	for _, fn := range p.rewrites {
+		fn(pr)
+	}
+}
+
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	for _, fn := range p.modifiers {
+		if err := fn(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		// the client went away, nobody reads the response
+		status = statusClientClosedRequest
+	}
+	if p.log != nil && status != statusClientClosedRequest {
+		l := p.log.Event(proxyEvent).Method(r.Method).URI(r.RequestURI).StatusCode(status)
+		logger.WithTrace(r.Context(), l).Error("Could not proxy request", err)
+	}
+	w.WriteHeader(status)
+}
+
+// candidates returns the healthy upstreams not tried yet, falling back to the healthy ones and then to all of them.
+func (p *Proxy) candidates(tried map[*Upstream]bool) []*Upstream {
+	var healthy, untried []*Upstream
+	for _, u := range p.upstreams {
+		if !u.Healthy() {
+			continue
+		}
+		healthy = append(healthy, u)
+		if !tried[u] {
+			untried = append(untried, u)
+		}
+	}
+	switch {
+	case len(untried) > 0:
+		return untried
+	case len(healthy) > 0:
+		return healthy
+	default:
+		return p.upstreams
+	}
+}
+
+// balancingTransport sends every attempt to the upstream picked by the balancer, retrying when allowed.
+type balancingTransport struct {
+	p *Proxy
+}
+
+func (t *balancingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := t.p.attempts
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if !isIdempotent(req.Method) || (hasBody && t.p.spoolThreshold <= 0) {
+		attempts = 1
+	}
+	var spool *streamio.SpoolBuffer
+	if hasBody && attempts > 1 {
+		var err error
+		spool, err = streamio.Spool(req.Body, t.p.spoolThreshold, "")
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		defer spool.Close()
+	}
+	tried := make(map[*Upstream]bool, attempts)
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		u := t.p.balancer.Pick(t.p.candidates(tried))
+		tried[u] = true
+		out := req
+		if spool != nil {
+			out = req.Clone(req.Context())
+			out.Body = io.NopCloser(spool.Reader())
+		}
+		resp, err := t.send(out, u)
+		retryable := err != nil && req.Context().Err() == nil
+		if err == nil {
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				retryable = true
+			}
+		}
+		if !retryable || attempt >= attempts {
+			if err != nil && lastErr != nil {
+				err = errors.Join(lastErr, err)
+			}
+			return resp, err
+		}
+		if err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			err = fmt.Errorf("upstream %s replied %d", u.URL.Host, resp.StatusCode)
+		}
+		lastErr = err
+		if t.p.log != nil {
+			t.p.log.Event(proxyEvent).Method(req.Method).Host(u.URL.Host).Warn("Retrying request on another upstream")
+		}
+	}
+}
+
+// send sends the request to the upstream in input.
+func (t *balancingTransport) send(req *http.Request, u *Upstream) (*http.Response, error) {
+	ctx := req.Context()
+	cancel := context.CancelFunc(func() {})
+	if t.p.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.p.timeout)
+	}
+	out := req.Clone(ctx)
+	out.URL.Scheme = u.URL.Scheme
+	out.URL.Host = u.URL.Host
+	out.URL.Path, out.URL.RawPath = joinURLPath(u.URL, req.URL)
+	if u.URL.RawQuery != "" {
+		if out.URL.RawQuery == "" {
+			out.URL.RawQuery = u.URL.RawQuery
+		} else {
+			out.URL.RawQuery = u.URL.RawQuery + "&" + out.URL.RawQuery
+		}
+	}
+
+	u.active.Add(1)
+	resp, err := t.p.transport.RoundTrip(out)
+	if err != nil {
+		u.active.Add(-1)
+		cancel()
+		t.count(u, "error")
+		return nil, err
+	}
+	t.count(u, strconv.Itoa(resp.StatusCode/100)+"xx")
+	release := func() {
+		u.active.Add(-1)
+		cancel()
+	}
+	if rwc, ok := resp.Body.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+		// httputil.ReverseProxy needs a writable body to tunnel upgraded connections, e.g. WebSockets
+		resp.Body = &releaseUpgradedOnClose{ReadWriteCloser: rwc, release: release}
+		return resp, nil
+	}
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+func (t *balancingTransport) count(u *Upstream, class string) {
+	if t.p.requests != nil {
+		t.p.requests.WithLabelValues(u.URL.Host, class).Inc()
+	}
+}
+
+// releaseOnClose calls release once the body is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// releaseUpgradedOnClose calls release once the upgraded connection is closed.
+type releaseUpgradedOnClose struct {
+	io.ReadWriteCloser
+	release func()
+	once    sync.Once
+}
+
+func (r *releaseUpgradedOnClose) Close() error {
+	err := r.ReadWriteCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// joinURLPath joins the paths of the upstream and of the request, as httputil.ProxyRequest.SetURL does.
+func joinURLPath(a, b *url.URL) (path, rawpath string) {
+	if a.RawPath == "" && b.RawPath == "" {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+	apath := a.EscapedPath()
+	bpath := b.EscapedPath()
+	aslash := strings.HasSuffix(apath, "/")
+	bslash := strings.HasPrefix(bpath, "/")
+	switch {
+	case aslash && bslash:
+		return a.Path + b.Path[1:], apath + bpath[1:]
+	case !aslash && !bslash:
+		return a.Path + "/" + b.Path, apath + "/" + bpath
+	}
+	return a.Path + b.Path, apath + bpath
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// isIdempotent reports whether requests with the method in input can be safely sent more than once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func setHeader(h http.Header, name, value string) {
+	if value == "" {
+		h.Del(name)
+		return
+	}
+	h.Set(name, value)
+}
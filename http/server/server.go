@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/indiependente/pkg/http/middleware"
+	"github.com/indiependente/pkg/shutdown"
+	"golang.org/x/net/netutil"
+)
+
+const (
+	defaultAddr              = ":8080"
+	defaultReadTimeout       = 30 * time.Second
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultShutdownTimeout   = 30 * time.Second
+)
+
+// Server wraps an http.Server adding lifecycle management.
+type Server struct {
+	*http.Server
+	certFile        string
+	keyFile         string
+	shutdownTimeout time.Duration
+	maxConns        int
+}
+
+// Option customises the server returned by New.
+type Option func(*Server)
+
+// New returns a Server serving the handler in input with production ready timeouts,
+// customised by the options in input.
+func New(handler http.Handler, opts ...Option) *Server {
+	s := &Server{
+		Server: &http.Server{
+			Addr:              defaultAddr,
+			Handler:           handler,
+			ReadTimeout:       defaultReadTimeout,
+			ReadHeaderTimeout: defaultReadHeaderTimeout,
+			WriteTimeout:      defaultWriteTimeout,
+			IdleTimeout:       defaultIdleTimeout,
+			MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		},
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.ReadHeaderTimeout <= 0 {
+		// without it, slow clients can hold connections open indefinitely by trickling the headers
+		s.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	return s
+}
+
+// WithAddr sets the TCP address to listen on.
+func WithAddr(addr string) Option {
+	return func(s *Server) {
+		s.Addr = addr
+	}
+}
+
+// WithReadTimeout sets the maximum duration for reading the entire request, including the body.
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.ReadTimeout = d
+	}
+}
+
+// WithReadHeaderTimeout sets the maximum duration for reading the request headers.
+// Non positive durations keep the default of 10s, so that slow-loris protection cannot be disabled.
+func WithReadHeaderTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.ReadHeaderTimeout = d
+	}
+}
+
+// WithWriteTimeout sets the maximum duration before timing out writes of the response.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.WriteTimeout = d
+	}
+}
+
+// WithIdleTimeout sets the maximum amount of time to wait for the next request on keep-alive connections.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.IdleTimeout = d
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum size of the request headers.
+func WithMaxHeaderBytes(n int) Option {
+	return func(s *Server) {
+		s.MaxHeaderBytes = n
+	}
+}
+
+// WithMaxBodyBytes limits the size of the request bodies to n bytes with middleware.MaxBytes.
+func WithMaxBodyBytes(n int64) Option {
+	return func(s *Server) {
+		s.Handler = middleware.MaxBytes(n)(s.Handler)
+	}
+}
+
+// WithMaxConnections accepts at most n connections at once, the others waiting to be accepted.
+func WithMaxConnections(n int) Option {
+	return func(s *Server) {
+		s.maxConns = n
+	}
+}
+
+// WithMaxConcurrentStreams limits the number of concurrent requests on each HTTP/2 connection. Defaults to 250.
+func WithMaxConcurrentStreams(n int) Option {
+	return func(s *Server) {
+		if s.HTTP2 == nil {
+			s.HTTP2 = &http.HTTP2Config{}
+		}
+		s.HTTP2.MaxConcurrentStreams = n
+	}
+}
+
+// WithTLS serves HTTPS using the certificate and key files in input.
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+		if s.TLSConfig == nil {
+			s.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+	}
+}
+
+// WithTLSConfig serves HTTPS using the TLS configuration in input, which must provide the certificates.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(s *Server) {
+		s.TLSConfig = cfg
+	}
+}
+
+// WithH2C accepts HTTP/2 without TLS (prior knowledge) alongside HTTP/1.1, e.g. behind a TLS terminating proxy.
+func WithH2C() Option {
+	return func(s *Server) {
+		s.Protocols = new(http.Protocols)
+		s.Protocols.SetHTTP1(true)
+		s.Protocols.SetHTTP2(true)
+		s.Protocols.SetUnencryptedHTTP2(true)
+	}
+}
+
+// WithShutdownTimeout sets how long the server waits for in-flight requests to complete during shutdown.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// Run serves requests until the context in input is cancelled, then gracefully shuts the server down,
+// waiting up to the shutdown timeout for in-flight requests to complete.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", s.Addr, err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve behaves as Run but accepts connections on the listener in input.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	if s.maxConns > 0 {
+		ln = netutil.LimitListener(ln, s.maxConns)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		if s.TLSConfig != nil {
+			errCh <- s.Server.ServeTLS(ln, s.certFile, s.keyFile)
+			return
+		}
+		errCh <- s.Server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("could not serve: %w", err)
+	case <-ctx.Done():
+	}
+	if err := s.TerminationFn()(context.Background()); err != nil {
+		return err
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("could not serve: %w", err)
+	}
+	return nil
+}
+
+// TerminationFn returns a shutdown.TerminationFn gracefully shutting the server down,
+// waiting up to the shutdown timeout for in-flight requests to complete.
+func (s *Server) TerminationFn() shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.shutdownTimeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			return fmt.Errorf("could not shutdown server: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,189 @@
+package httptestx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fault is a failure injected by a Route in place of its response.
+type fault int
+
+const (
+	noFault fault = iota
+	// faultHang never responds, so that clients time out.
+	faultHang
+	// faultReset closes the connection abruptly, so that clients get a connection reset.
+	faultReset
+	// faultPartial announces the whole body and closes the connection after writing part of it.
+	faultPartial
+)
+
+// Route is a stubbed response for the requests matching a method and path.
+type Route struct {
+	method  string
+	path    string
+	status  int
+	header  http.Header
+	body    []byte
+	handler http.Handler
+	delay   time.Duration
+	times   int
+	fault   fault
+	partial int
+
+	// claimed counts the requests matched, guarded by the server mutex.
+	claimed int
+
+	mu    sync.Mutex
+	calls []Request
+}
+
+// Respond sets the status code and body of the response.
+func (r *Route) Respond(status int, body string) *Route {
+	r.status = status
+	r.body = []byte(body)
+	return r
+}
+
+// RespondJSON sets the status code of the response and its body to v encoded as JSON.
+func (r *Route) RespondJSON(status int, v interface{}) *Route {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("could not encode response body: %v", err))
+	}
+	r.status = status
+	r.body = b
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// RespondWith serves the requests with the handler in input.
+func (r *Route) RespondWith(h http.Handler) *Route {
+	r.handler = h
+	return r
+}
+
+// WithHeader adds the header to the response.
+func (r *Route) WithHeader(key, value string) *Route {
+	r.header.Add(key, value)
+	return r
+}
+
+// Delay waits for d before responding.
+func (r *Route) Delay(d time.Duration) *Route {
+	r.delay = d
+	return r
+}
+
+// Times makes the route serve n requests at most, after which the following matching route is used.
+func (r *Route) Times(n int) *Route {
+	r.times = n
+	return r
+}
+
+// Once is equivalent to Times(1).
+func (r *Route) Once() *Route {
+	return r.Times(1)
+}
+
+// Hang never responds, until the client gives up or the server closes, to test client timeouts.
+func (r *Route) Hang() *Route {
+	r.fault = faultHang
+	return r
+}
+
+// ResetConnection closes the connection without responding, so that clients get a connection reset.
+func (r *Route) ResetConnection() *Route {
+	r.fault = faultReset
+	return r
+}
+
+// PartialBody announces the length of the whole body and closes the connection after writing n bytes of it,
+// so that clients get an unexpected EOF while reading.
+func (r *Route) PartialBody(n int) *Route {
+	r.fault = faultPartial
+	r.partial = n
+	return r
+}
+
+// CallCount returns how many requests the route served.
+func (r *Route) CallCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+// Calls returns the requests the route served.
+func (r *Route) Calls() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.calls...)
+}
+
+// claim reports whether the route serves the request, counting it if so. It is called with the server mutex held.
+func (r *Route) claim(req *http.Request) bool {
+	if r.method != req.Method || r.path != req.URL.Path {
+		return false
+	}
+	if r.times > 0 && r.claimed >= r.times {
+		return false
+	}
+	r.claimed++
+	return true
+}
+
+func (r *Route) record(req Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, req)
+}
+
+func (r *Route) serve(w http.ResponseWriter, req *http.Request, done <-chan struct{}) {
+	switch r.fault {
+	case faultHang:
+		select {
+		case <-req.Context().Done():
+		case <-done:
+		}
+		return
+	case faultReset:
+		resetConnection(w)
+		return
+	}
+	if r.handler != nil {
+		r.handler.ServeHTTP(w, req)
+		return
+	}
+
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	if r.fault != faultPartial {
+		w.WriteHeader(r.status)
+		_, _ = w.Write(r.body)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(r.body)))
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body[:min(r.partial, len(r.body))])
+	_ = http.NewResponseController(w).Flush()
+	// aborting the handler closes the connection without completing the response
+	panic(http.ErrAbortHandler)
+}
+
+// resetConnection closes the connection of the response writer, sending a TCP RST where possible.
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		_ = tc.SetLinger(0)
+	}
+	_ = conn.Close()
+}
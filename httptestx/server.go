@@ -0,0 +1,209 @@
+// Package httptestx provides a test server with stubbed routes and fault injection, to test HTTP clients,
+// e.g. the retry and circuit breaker transports of the client package, against realistic failures.
+package httptestx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Option customises a Server.
+type Option func(*Server)
+
+// WithTLS serves over TLS; Client returns a client trusting the server certificate.
+func WithTLS() Option {
+	return func(s *Server) {
+		s.tls = true
+	}
+}
+
+// WithLatency delays every response by d.
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// Request is a request received by the Server, along with its body.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+	Time   time.Time
+	// Matched tells whether the request was served by a route.
+	Matched bool
+}
+
+// Server is an httptest.Server answering requests with the routes registered with On.
+// Requests not matching any route are answered with 404 Not Found.
+type Server struct {
+	*httptest.Server
+	tls     bool
+	latency time.Duration
+	// done is closed when the server is closing, releasing the handlers which are hanging.
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	routes   []*Route
+	requests []Request
+}
+
+// NewServer starts a Server, which is closed when the test finishes.
+func NewServer(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	s := &Server{done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	if s.tls {
+		s.StartTLS()
+	} else {
+		s.Start()
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Close releases the hanging handlers and shuts the server down.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.CloseClientConnections()
+		s.Server.Close()
+	})
+}
+
+// On registers a route answering requests with the method and path in input.
+// Routes are matched in order of registration, skipping the ones which have served as many requests as set by Times,
+// so that a sequence of responses can be stubbed by registering the same method and path more than once.
+// Routes respond with 200 OK and no body unless configured otherwise.
+func (s *Server) On(method, path string) *Route {
+	r := &Route{
+		method: method,
+		path:   path,
+		status: http.StatusOK,
+		header: http.Header{},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, r)
+	return r
+}
+
+// Requests returns the requests received by the server, in order of arrival.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset removes the routes and the recorded requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = nil
+	s.requests = nil
+}
+
+// AssertCalled fails the test if the server has not received exactly times requests with the method and path in input.
+func (s *Server) AssertCalled(t testing.TB, method, path string, times int) {
+	t.Helper()
+	got := 0
+	for _, req := range s.Requests() {
+		if req.Method == method && req.Path == path {
+			got++
+		}
+	}
+	if got != times {
+		t.Errorf("%s %s: expected %d calls, got %d", method, path, times, got)
+	}
+}
+
+// AssertAllCalled fails the test if any route has not served a request, or fewer requests than set by Times.
+func (s *Server) AssertAllCalled(t testing.TB) {
+	t.Helper()
+	s.mu.Lock()
+	routes := append([]*Route(nil), s.routes...)
+	s.mu.Unlock()
+	for _, r := range routes {
+		got := r.CallCount()
+		switch {
+		case got == 0:
+			t.Errorf("%s %s: route not called", r.method, r.path)
+		case r.times > 0 && got < r.times:
+			t.Errorf("%s %s: expected %d calls, got %d", r.method, r.path, r.times, got)
+		}
+	}
+}
+
+// AssertNoUnmatched fails the test if the server received requests not matching any route.
+func (s *Server) AssertNoUnmatched(t testing.TB) {
+	t.Helper()
+	for _, req := range s.Requests() {
+		if !req.Matched {
+			t.Errorf("%s %s: no such route", req.Method, req.Path)
+		}
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+		Body:   body,
+		Time:   time.Now(),
+	}
+
+	s.mu.Lock()
+	var route *Route
+	for _, rt := range s.routes {
+		if rt.claim(r) {
+			route = rt
+			break
+		}
+	}
+	req.Matched = route != nil
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	if !s.sleep(r, s.latency) {
+		return
+	}
+	if route == nil {
+		http.NotFound(w, r)
+		return
+	}
+	route.record(req)
+	if !s.sleep(r, route.delay) {
+		return
+	}
+	route.serve(w, r, s.done)
+}
+
+// sleep waits for d, returning false if the request is cancelled or the server closes first.
+func (s *Server) sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-s.done:
+		return false
+	}
+}
@@ -0,0 +1,125 @@
+package bind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/indiependente/pkg/httpx/respond"
+	"github.com/indiependente/pkg/validate"
+)
+
+// errTooLarge is returned by limitedReader once the limit is exceeded.
+var errTooLarge = errors.New("body too large")
+
+// Validator is implemented by values validating themselves once decoded.
+// A returned *respond.Problem is passed through, respond.FieldErrorer values are rendered with their field errors,
+// any other error is rendered as 422 Unprocessable Entity.
+type Validator interface {
+	Validate() error
+}
+
+// JSON decodes the JSON body of the request into v, reading at most maxBytes, and validates it
+// against its validate struct tags and, if v is a Validator, its Validate method.
+// The returned errors are *respond.Problem values, ready to be written with respond.Error:
+// 413 when the body is too large, 415 for non JSON content types, and 400 with field-level errors
+// for malformed bodies, unknown fields and values of the wrong type.
+func JSON(r *http.Request, v interface{}, maxBytes int64) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return respond.NewProblem(http.StatusUnsupportedMediaType, "expected a JSON body")
+		}
+	}
+
+	dec := json.NewDecoder(&limitedReader{r: r.Body, remaining: maxBytes})
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return decodeProblem(err, maxBytes)
+	}
+	if dec.More() {
+		return respond.NewProblem(http.StatusBadRequest, "body must contain a single JSON value")
+	}
+
+	if err := validate.Struct(v); err != nil {
+		return respond.ProblemFor(err)
+	}
+	val, ok := v.(Validator)
+	if !ok {
+		return nil
+	}
+	if err := val.Validate(); err != nil {
+		var (
+			p  *respond.Problem
+			fe respond.FieldErrorer
+		)
+		switch {
+		case errors.As(err, &p):
+			return p
+		case errors.As(err, &fe):
+			return respond.ProblemFor(err)
+		}
+		return respond.NewProblem(http.StatusUnprocessableEntity, err.Error())
+	}
+	return nil
+}
+
+func decodeProblem(err error, maxBytes int64) *respond.Problem {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		bytesErr  *http.MaxBytesError
+	)
+	switch {
+	case errors.Is(err, errTooLarge):
+		return respond.NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", maxBytes))
+	case errors.As(err, &bytesErr):
+		return respond.ProblemFor(bytesErr)
+	case errors.Is(err, io.EOF):
+		return respond.NewProblem(http.StatusBadRequest, "body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return respond.NewProblem(http.StatusBadRequest, "body contains malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return respond.NewProblem(http.StatusBadRequest, fmt.Sprintf("body contains malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		p := respond.NewProblem(http.StatusBadRequest, "body contains invalid values")
+		p.Errors = []respond.FieldError{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		}}
+		return p
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		p := respond.NewProblem(http.StatusBadRequest, "body contains unknown fields")
+		p.Errors = []respond.FieldError{{
+			Field:   strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`),
+			Message: "unknown field",
+		}}
+		return p
+	}
+	return respond.NewProblem(http.StatusBadRequest, "body could not be decoded")
+}
+
+// limitedReader fails with errTooLarge when reading more than the remaining bytes.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if lr.remaining < 0 {
+		return 0, errTooLarge
+	}
+	if int64(len(p)) > lr.remaining+1 {
+		p = p[:lr.remaining+1]
+	}
+	n, err := lr.r.Read(p)
+	lr.remaining -= int64(n)
+	if lr.remaining < 0 {
+		return 0, errTooLarge
+	}
+	return n, err
+}
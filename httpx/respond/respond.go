@@ -0,0 +1,157 @@
+package respond
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	contentTypeJSON    = "application/json"
+	contentTypeProblem = "application/problem+json"
+	contentTypeNDJSON  = "application/x-ndjson"
+)
+
+// StatusCoder is implemented by errors carrying the HTTP status code they should be rendered with.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// FieldErrorer is implemented by errors listing the fields which are not valid, e.g. validate.Errors.
+type FieldErrorer interface {
+	FieldErrors() []FieldError
+}
+
+// FieldError describes why the value of a field is not valid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object. It implements error so it can be returned by handlers as is.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem returns a Problem with the status code in input, titled after it.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// StatusCode returns the status code of the problem.
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// JSON writes v encoded as JSON with the status code in input.
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	writeJSON(w, status, contentTypeJSON, v)
+}
+
+// NoContent writes a 204 No Content response.
+func NoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Error writes the error as an RFC 7807 problem+json response.
+// A *Problem in the error chain is written as is; an *http.MaxBytesError, returned when reading more than
+// the limit set by http.MaxBytesReader, is written as 413 Request Entity Too Large; errors implementing FieldErrorer are written as 400 Bad Request,
+// or with their status code if they implement StatusCoder too, listing the field errors; errors implementing StatusCoder are written with their status code,
+// exposing their message only for 4xx codes. Any other error results in a 500 Internal Server Error
+// whose body does not leak the error message.
+func Error(w http.ResponseWriter, err error) {
+	writeJSON(w, 0, contentTypeProblem, ProblemFor(err))
+}
+
+// ProblemFor returns the Problem describing the error, as written by Error.
+func ProblemFor(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", mbe.Limit))
+	}
+	var fe FieldErrorer
+	if errors.As(err, &fe) {
+		status := http.StatusBadRequest
+		if sc, ok := fe.(StatusCoder); ok {
+			status = sc.StatusCode()
+		}
+		p := NewProblem(status, "the request contains invalid fields")
+		p.Errors = fe.FieldErrors()
+		return p
+	}
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		status := sc.StatusCode()
+		if status >= 400 && status < 500 {
+			return NewProblem(status, err.Error())
+		}
+		return NewProblem(status, "")
+	}
+	return NewProblem(http.StatusInternalServerError, "")
+}
+
+// StreamNDJSON writes the items received from the channel as newline delimited JSON, flushing after each item,
+// until the channel is closed or the context is done.
+func StreamNDJSON[T any](ctx context.Context, w http.ResponseWriter, status int, items <-chan T) error {
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case item, ok := <-items:
+			if !ok {
+				return nil
+			}
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+	}
+}
+
+// writeJSON writes v encoded as JSON. A zero status uses the status of a *Problem.
+func writeJSON(w http.ResponseWriter, status int, contentType string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(NewProblem(http.StatusInternalServerError, ""))
+		status = http.StatusInternalServerError
+		contentType = contentTypeProblem
+	}
+	if p, ok := v.(*Problem); ok && status == 0 {
+		status = p.Status
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(b, '\n'))
+}
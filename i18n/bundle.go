@@ -0,0 +1,287 @@
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/text/language"
+)
+
+// ErrUnknownFormat is returned by LoadFS for message files which are neither JSON nor TOML.
+var ErrUnknownFormat = errors.New("unknown message file format")
+
+// message is a translation, with a form per plural category. Messages without plural forms only have Other.
+type message map[Category]string
+
+// Option customises a Bundle.
+type Option func(*Bundle)
+
+// WithPluralRule uses the rule in input for the language in input, e.g. "pt", overriding the built-in one.
+func WithPluralRule(lang string, rule PluralRule) Option {
+	return func(b *Bundle) {
+		b.rules[baseLanguage(lang)] = rule
+	}
+}
+
+// Bundle holds the message catalogs of every supported locale.
+// Messages are looked up in the requested locale, then in its parents, e.g. it-CH then it, and finally in the default
+// locale; missing messages are rendered as their key.
+type Bundle struct {
+	def   language.Tag
+	rules map[string]PluralRule
+
+	mu       sync.RWMutex
+	catalogs map[string]map[string]message
+	tags     []language.Tag
+	matcher  language.Matcher
+}
+
+// NewBundle returns an empty Bundle falling back to the default locale in input, e.g. "en".
+// It panics if the locale is not a valid BCP 47 tag.
+func NewBundle(defaultLocale string, opts ...Option) *Bundle {
+	b := &Bundle{
+		def:      language.MustParse(defaultLocale),
+		rules:    make(map[string]PluralRule),
+		catalogs: make(map[string]map[string]message),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.catalogs[b.def.String()] = make(map[string]message)
+	b.updateMatcher()
+	return b
+}
+
+// DefaultLocale returns the default locale of the bundle.
+func (b *Bundle) DefaultLocale() string {
+	return b.def.String()
+}
+
+// Locales returns the locales with a catalog, the default one first.
+func (b *Bundle) Locales() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]string, len(b.tags))
+	for i, t := range b.tags {
+		out[i] = t.String()
+	}
+	return out
+}
+
+// LoadFS loads the message files found in fsys, e.g. an embed.FS. Files are named after their locale, e.g. en.json,
+// it-CH.toml or messages.it.json, and hold either a string or the plural forms of every message, e.g.
+//
+//	{"greeting": "Hello %s", "items": {"one": "%d item", "other": "%d items"}}
+//
+// Nested objects are flattened into keys joined by dots, e.g. errors.not_found.
+func (b *Bundle) LoadFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		ext := path.Ext(p)
+		if ext != ".json" && ext != ".toml" {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return fmt.Errorf("could not read message file %s: %w", p, err)
+		}
+		locale := strings.TrimSuffix(path.Base(p), ext)
+		if i := strings.LastIndex(locale, "."); i >= 0 {
+			locale = locale[i+1:]
+		}
+		if err := b.Load(locale, ext[1:], data); err != nil {
+			return fmt.Errorf("could not load message file %s: %w", p, err)
+		}
+		return nil
+	})
+}
+
+// Load loads the messages of the locale in input, encoded in format, either "json" or "toml".
+func (b *Bundle) Load(locale, format string, data []byte) error {
+	var raw map[string]interface{}
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("could not decode JSON messages: %w", err)
+		}
+	case "toml":
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("could not decode TOML messages: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	messages := make(map[string]message)
+	if err := flatten(messages, "", raw); err != nil {
+		return err
+	}
+	return b.add(locale, messages)
+}
+
+// AddMessages adds the messages in input to the catalog of the locale, e.g. in tests.
+func (b *Bundle) AddMessages(locale string, messages map[string]string) error {
+	m := make(map[string]message, len(messages))
+	for k, v := range messages {
+		m[k] = message{Other: v}
+	}
+	return b.add(locale, m)
+}
+
+// AddPlural adds a message with its plural forms to the catalog of the locale.
+func (b *Bundle) AddPlural(locale, key string, forms map[Category]string) error {
+	m := make(message, len(forms))
+	for c, v := range forms {
+		m[c] = v
+	}
+	return b.add(locale, map[string]message{key: m})
+}
+
+func (b *Bundle) add(locale string, messages map[string]message) error {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return fmt.Errorf("could not parse locale %q: %w", locale, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	catalog, ok := b.catalogs[tag.String()]
+	if !ok {
+		catalog = make(map[string]message, len(messages))
+		b.catalogs[tag.String()] = catalog
+	}
+	for k, m := range messages {
+		catalog[k] = m
+	}
+	if !ok {
+		b.updateMatcher()
+	}
+	return nil
+}
+
+// updateMatcher rebuilds the matcher from the catalogs. It must be called with the lock held.
+func (b *Bundle) updateMatcher() {
+	tags := make([]language.Tag, 0, len(b.catalogs))
+	for locale := range b.catalogs {
+		if locale != b.def.String() {
+			tags = append(tags, language.Make(locale))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].String() < tags[j].String() })
+	// the first tag is the one matched when none of the preferred ones is supported
+	b.tags = append([]language.Tag{b.def}, tags...)
+	b.matcher = language.NewMatcher(b.tags)
+}
+
+// Match returns the supported locale which best matches the preferred ones in input, e.g. the values of an
+// Accept-Language header, or the default locale if none matches.
+func (b *Bundle) Match(preferred ...string) string {
+	var prefs []language.Tag
+	for _, p := range preferred {
+		tags, _, err := language.ParseAcceptLanguage(p)
+		if err != nil {
+			continue
+		}
+		prefs = append(prefs, tags...)
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(prefs) == 0 {
+		return b.def.String()
+	}
+	_, i, confidence := b.matcher.Match(prefs...)
+	if confidence == language.No {
+		return b.def.String()
+	}
+	return b.tags[i].String()
+}
+
+// Localizer returns a Localizer translating to the supported locale which best matches the preferred ones.
+func (b *Bundle) Localizer(preferred ...string) *Localizer {
+	return b.localizer(b.Match(preferred...))
+}
+
+func (b *Bundle) localizer(locale string) *Localizer {
+	tag := language.Make(locale)
+	var chain []string
+	for t := tag; ; t = t.Parent() {
+		chain = append(chain, t.String())
+		if t.IsRoot() {
+			break
+		}
+	}
+	if tag != b.def {
+		chain = append(chain, b.def.String())
+	}
+	rule, ok := b.rules[baseLanguage(locale)]
+	if !ok {
+		rule = pluralRuleFor(baseLanguage(locale))
+	}
+	return &Localizer{bundle: b, locale: locale, chain: chain, plural: rule}
+}
+
+// lookup returns the message of the first locale in chain having one.
+func (b *Bundle) lookup(chain []string, key string) (message, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, locale := range chain {
+		if m, ok := b.catalogs[locale][key]; ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// flatten adds the messages of raw to out, prefixing their keys with prefix.
+func flatten(out map[string]message, prefix string, raw map[string]interface{}) error {
+	for k, v := range raw {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case string:
+			out[key] = message{Other: v}
+		case map[string]interface{}:
+			if m, ok := pluralForms(v); ok {
+				out[key] = m
+				continue
+			}
+			if err := flatten(out, key, v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s is neither a string nor an object: %T", key, v)
+		}
+	}
+	return nil
+}
+
+// pluralForms returns the message whose plural forms are in raw, reporting false if raw is not made of plural forms.
+func pluralForms(raw map[string]interface{}) (message, bool) {
+	m := make(message, len(raw))
+	for k, v := range raw {
+		s, ok := v.(string)
+		if !ok || !Category(k).valid() {
+			return nil, false
+		}
+		m[Category(k)] = s
+	}
+	_, ok := m[Other]
+	return m, ok
+}
+
+func baseLanguage(locale string) string {
+	base, _ := language.Make(locale).Base()
+	return base.String()
+}
@@ -0,0 +1,139 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/indiependente/pkg/httpx/respond"
+)
+
+// MiddlewareOption customises the middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	query  string
+	cookie string
+}
+
+// WithQueryParam lets clients choose the locale with the query parameter in input, e.g. ?lang=it,
+// which takes precedence over the cookie and the Accept-Language header.
+func WithQueryParam(name string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.query = name
+	}
+}
+
+// WithCookie lets clients choose the locale with the cookie in input, which takes precedence over the
+// Accept-Language header.
+func WithCookie(name string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.cookie = name
+	}
+}
+
+// Middleware negotiates the locale of every request among the ones of the bundle, from its Accept-Language
+// header, and stores a Localizer for it in the request context, where FromContext finds it.
+// The locale is sent back in the Content-Language header.
+func Middleware(b *Bundle, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	cfg := &middlewareConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var preferred []string
+			if cfg.query != "" {
+				if v := r.URL.Query().Get(cfg.query); v != "" {
+					preferred = append(preferred, v)
+				}
+			}
+			if cfg.cookie != "" {
+				if c, err := r.Cookie(cfg.cookie); err == nil && c.Value != "" {
+					preferred = append(preferred, c.Value)
+				}
+			}
+			preferred = append(preferred, r.Header.Values("Accept-Language")...)
+
+			l := b.Localizer(preferred...)
+			w.Header().Add("Vary", "Accept-Language")
+			w.Header().Set("Content-Language", l.Locale())
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), l)))
+		})
+	}
+}
+
+// Error is an error whose message is translated when written by WriteError.
+// It implements respond.StatusCoder.
+type Error struct {
+	Status int
+	Key    string
+	Args   []interface{}
+	// Err is the underlying error, if any.
+	Err error
+}
+
+// NewError returns an Error with the status code in input, described by the message with the key in input
+// formatted with args.
+func NewError(status int, key string, args ...interface{}) *Error {
+	return &Error{Status: status, Key: key, Args: args}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Key, e.Err)
+	}
+	return e.Key
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the status code of the error.
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+// Problem returns the respond.Problem describing the error, as respond.ProblemFor does, translated:
+//   - the title is the message with key http.<status>, e.g. http.404, if any;
+//   - the detail of an Error in the chain is its message, formatted with its arguments;
+//   - other details, and the messages of field errors, are translated when used as keys of a message.
+func (l *Localizer) Problem(err error) *respond.Problem {
+	p := *respond.ProblemFor(err)
+	if key := "http." + strconv.Itoa(p.Status); l.Has(key) {
+		p.Title = l.T(key)
+	}
+	var e *Error
+	if errors.As(err, &e) && e.Status == p.Status {
+		p.Detail = l.T(e.Key, e.Args...)
+	} else if p.Detail != "" && l.Has(p.Detail) {
+		p.Detail = l.T(p.Detail)
+	}
+	if p.Errors != nil {
+		fieldErrors := make([]respond.FieldError, len(p.Errors))
+		for i, fe := range p.Errors {
+			if l.Has(fe.Message) {
+				fe.Message = l.T(fe.Message)
+			}
+			fieldErrors[i] = fe
+		}
+		p.Errors = fieldErrors
+	}
+	return &p
+}
+
+// WriteError writes the error as respond.Error does, translated to the locale of the request by the Localizer
+// stored in its context. Without a localizer, the error is written untranslated.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	l, ok := FromContext(r.Context())
+	if !ok {
+		respond.Error(w, err)
+		return
+	}
+	w.Header().Set("Content-Language", l.Locale())
+	respond.Error(w, l.Problem(err))
+}
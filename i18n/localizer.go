@@ -0,0 +1,71 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+)
+
+type contextKey struct{}
+
+// NewContext stores the localizer in the context.
+func NewContext(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the localizer stored in the context by the middleware.
+func FromContext(ctx context.Context) (*Localizer, bool) {
+	l, ok := ctx.Value(contextKey{}).(*Localizer)
+	return l, ok
+}
+
+// Localizer translates messages to a locale. It is safe for concurrent use.
+type Localizer struct {
+	bundle *Bundle
+	locale string
+	chain  []string
+	plural PluralRule
+}
+
+// Locale returns the locale of the localizer, e.g. it-CH.
+func (l *Localizer) Locale() string {
+	return l.locale
+}
+
+// Has reports whether a message with the key in input exists in the locale, its parents or the default locale.
+func (l *Localizer) Has(key string) bool {
+	_, ok := l.bundle.lookup(l.chain, key)
+	return ok
+}
+
+// T returns the message with the key in input, formatted with args as fmt.Sprintf does.
+// Translators can reorder the arguments with explicit indexes, e.g. %[2]s.
+// Missing messages are rendered as their key.
+func (l *Localizer) T(key string, args ...interface{}) string {
+	m, ok := l.bundle.lookup(l.chain, key)
+	if !ok {
+		return key
+	}
+	return format(m[Other], args)
+}
+
+// N returns the plural form of the message with the key in input matching the count n, formatted with n followed
+// by args, e.g. N("items", 3) renders "%d items" as "3 items".
+// Missing messages are rendered as their key.
+func (l *Localizer) N(key string, n int, args ...interface{}) string {
+	m, ok := l.bundle.lookup(l.chain, key)
+	if !ok {
+		return key
+	}
+	s, ok := m[l.plural(n)]
+	if !ok {
+		s = m[Other]
+	}
+	return format(s, append([]interface{}{n}, args...))
+}
+
+func format(s string, args []interface{}) string {
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
@@ -0,0 +1,121 @@
+package i18n
+
+// Category is a CLDR plural category.
+type Category string
+
+// Plural categories, see https://cldr.unicode.org/index/cldr-spec/plural-rules.
+const (
+	Zero  Category = "zero"
+	One   Category = "one"
+	Two   Category = "two"
+	Few   Category = "few"
+	Many  Category = "many"
+	Other Category = "other"
+)
+
+func (c Category) valid() bool {
+	switch c {
+	case Zero, One, Two, Few, Many, Other:
+		return true
+	}
+	return false
+}
+
+// PluralRule returns the plural category of the count in input.
+type PluralRule func(n int) Category
+
+// pluralRules are the built-in rules by language. Languages not listed use the English rule.
+var pluralRules = map[string]PluralRule{
+	// no plural forms
+	"ja": otherRule, "zh": otherRule, "ko": otherRule, "th": otherRule, "vi": otherRule, "id": otherRule,
+	"ms": otherRule,
+	// zero is singular
+	"fr": zeroOneRule, "pt": zeroOneRule, "hi": zeroOneRule,
+	// Slavic languages
+	"ru": eastSlavicRule, "uk": eastSlavicRule, "be": eastSlavicRule, "sr": eastSlavicRule, "hr": eastSlavicRule,
+	"bs": eastSlavicRule,
+	"pl": polishRule,
+	"cs": czechRule, "sk": czechRule,
+	"ar": arabicRule,
+}
+
+func pluralRuleFor(lang string) PluralRule {
+	if rule, ok := pluralRules[lang]; ok {
+		return rule
+	}
+	return oneRule
+}
+
+func oneRule(n int) Category {
+	if n == 1 {
+		return One
+	}
+	return Other
+}
+
+func otherRule(int) Category {
+	return Other
+}
+
+func zeroOneRule(n int) Category {
+	if n == 0 || n == 1 {
+		return One
+	}
+	return Other
+}
+
+func eastSlavicRule(n int) Category {
+	n = abs(n)
+	switch mod10, mod100 := n%10, n%100; {
+	case mod10 == 1 && mod100 != 11:
+		return One
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return Few
+	}
+	return Many
+}
+
+func polishRule(n int) Category {
+	n = abs(n)
+	switch mod10, mod100 := n%10, n%100; {
+	case n == 1:
+		return One
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return Few
+	}
+	return Many
+}
+
+func czechRule(n int) Category {
+	switch n = abs(n); {
+	case n == 1:
+		return One
+	case n >= 2 && n <= 4:
+		return Few
+	}
+	return Other
+}
+
+func arabicRule(n int) Category {
+	n = abs(n)
+	switch mod100 := n % 100; {
+	case n == 0:
+		return Zero
+	case n == 1:
+		return One
+	case n == 2:
+		return Two
+	case mod100 >= 3 && mod100 <= 10:
+		return Few
+	case mod100 >= 11:
+		return Many
+	}
+	return Other
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
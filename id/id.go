@@ -0,0 +1,213 @@
+package id
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidLen is the length of an encoded ULID.
+const ulidLen = 26
+
+// ErrInvalid is returned when parsing a malformed identifier.
+var ErrInvalid = errors.New("invalid identifier")
+
+// Option customises a Generator.
+type Option func(*Generator)
+
+// WithEntropy reads the random bits from r instead of crypto/rand, e.g. a seeded math/rand source in tests.
+func WithEntropy(r io.Reader) Option {
+	return func(g *Generator) {
+		g.entropy = r
+	}
+}
+
+// WithClock reads the time embedded in UUIDv7 and ULIDs from the function in input instead of time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(g *Generator) {
+		g.now = now
+	}
+}
+
+// Generator generates identifiers. It is safe for concurrent use.
+type Generator struct {
+	entropy io.Reader
+	now     func() time.Time
+
+	mu       sync.Mutex
+	lastMs   uint64
+	lastULID [10]byte
+}
+
+// New returns a Generator reading randomness from crypto/rand and the time from time.Now, unless overridden.
+func New(opts ...Option) *Generator {
+	g := &Generator{entropy: rand.Reader, now: time.Now}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+var std = New()
+
+// NewUUIDv4 returns a random UUID.
+func NewUUIDv4() string {
+	return std.UUIDv4()
+}
+
+// NewUUIDv7 returns a time ordered UUID.
+func NewUUIDv7() string {
+	return std.UUIDv7()
+}
+
+// NewULID returns a ULID, monotonically increasing within the same millisecond.
+func NewULID() string {
+	return std.ULID()
+}
+
+// UUIDv4 returns a random UUID.
+func (g *Generator) UUIDv4() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	u, err := uuid.NewRandomFromReader(g.entropy)
+	if err != nil {
+		panic(fmt.Sprintf("could not read entropy: %v", err))
+	}
+	return u.String()
+}
+
+// UUIDv7 returns a UUID starting with the current Unix time in milliseconds, so that it sorts by creation time.
+func (g *Generator) UUIDv7() string {
+	var u uuid.UUID
+	ms := uint64(g.now().UnixMilli())
+	g.mu.Lock()
+	g.read(u[6:])
+	g.mu.Unlock()
+	u[0], u[1], u[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	u[3], u[4], u[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	u[6] = u[6]&0x0f | 0x70 // version 7
+	u[8] = u[8]&0x3f | 0x80 // RFC 9562 variant
+	return u.String()
+}
+
+// ULID returns a ULID: the current Unix time in milliseconds followed by 80 random bits, encoded in Crockford base32.
+// ULIDs generated within the same millisecond increment the random bits of the previous one, so that they sort.
+func (g *Generator) ULID() string {
+	ms := uint64(g.now().UnixMilli())
+	var b [16]byte
+	g.mu.Lock()
+	if ms == g.lastMs && increment(g.lastULID[:]) {
+		copy(b[6:], g.lastULID[:])
+	} else {
+		g.read(b[6:])
+		copy(g.lastULID[:], b[6:])
+		g.lastMs = ms
+	}
+	g.mu.Unlock()
+	b[0], b[1], b[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	b[3], b[4], b[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	return encodeULID(b)
+}
+
+// read fills p with random bytes. It must be called with the lock held.
+func (g *Generator) read(p []byte) {
+	if _, err := io.ReadFull(g.entropy, p); err != nil {
+		panic(fmt.Sprintf("could not read entropy: %v", err))
+	}
+}
+
+// increment adds one to the big endian number in p, reporting false on overflow.
+func increment(p []byte) bool {
+	for i := len(p) - 1; i >= 0; i-- {
+		p[i]++
+		if p[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeULID(b [16]byte) string {
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [ulidLen]byte
+	for i := ulidLen - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// ParseULID decodes the ULID in input, case insensitively.
+func ParseULID(s string) ([16]byte, error) {
+	var b [16]byte
+	if len(s) != ulidLen || !strings.ContainsRune("01234567", rune(s[0])) {
+		return b, fmt.Errorf("%w: %q is not a ULID", ErrInvalid, s)
+	}
+	var hi, lo uint64
+	for i := 0; i < ulidLen; i++ {
+		v := strings.IndexByte(crockford, upper(s[i]))
+		if v < 0 {
+			return b, fmt.Errorf("%w: %q is not a ULID", ErrInvalid, s)
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	binary.BigEndian.PutUint64(b[:8], hi)
+	binary.BigEndian.PutUint64(b[8:], lo)
+	return b, nil
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// ParseUUID parses the UUID in input in its canonical, hyphenated form.
+func ParseUUID(s string) (uuid.UUID, error) {
+	if len(s) != 36 {
+		return uuid.Nil, fmt.Errorf("%w: %q is not a UUID", ErrInvalid, s)
+	}
+	u, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return u, nil
+}
+
+// Time returns the creation time embedded in a UUIDv7 or a ULID.
+func Time(s string) (time.Time, error) {
+	var ms uint64
+	if u, err := ParseUUID(s); err == nil {
+		if u.Version() != 7 {
+			return time.Time{}, fmt.Errorf("%w: UUID version %d does not embed a time", ErrInvalid, u.Version())
+		}
+		ms = uint64(u[0])<<40 | uint64(u[1])<<32 | uint64(u[2])<<24 | uint64(u[3])<<16 | uint64(u[4])<<8 | uint64(u[5])
+	} else if b, err := ParseULID(s); err == nil {
+		ms = uint64(b[0])<<40 | uint64(b[1])<<32 | uint64(b[2])<<24 | uint64(b[3])<<16 | uint64(b[4])<<8 | uint64(b[5])
+	} else {
+		return time.Time{}, fmt.Errorf("%w: %q is neither a UUID nor a ULID", ErrInvalid, s)
+	}
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// Valid reports whether s is a UUID or a ULID, e.g. to validate request IDs received from clients.
+func Valid(s string) bool {
+	if _, err := ParseUUID(s); err == nil {
+		return true
+	}
+	_, err := ParseULID(s)
+	return err == nil
+}
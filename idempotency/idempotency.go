@@ -0,0 +1,406 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/httpx/respond"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/tenantid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	idempotencyEvent = "idempotency"
+
+	// Header is the header carrying the idempotency key.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on the responses replayed from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	defaultTTL             = 24 * time.Hour
+	defaultLockTTL         = time.Minute
+	defaultWait            = 10 * time.Second
+	defaultPollInterval    = 100 * time.Millisecond
+	defaultMaxRequestSize  = 1 << 20
+	defaultMaxResponseSize = 1 << 20
+	maxKeyLength           = 255
+)
+
+// Option customises the middleware.
+type Option func(*config)
+
+type config struct {
+	methods         map[string]struct{}
+	required        bool
+	ttl             time.Duration
+	lockTTL         time.Duration
+	wait            time.Duration
+	maxRequestSize  int64
+	maxResponseSize int
+	scope           func(*http.Request) string
+	log             logger.Logger
+	requests        *prometheus.CounterVec
+	clock           clock.Clock
+}
+
+// WithMethods applies the middleware to the methods in input instead of POST and PATCH.
+func WithMethods(methods ...string) Option {
+	return func(c *config) {
+		c.methods = make(map[string]struct{}, len(methods))
+		for _, m := range methods {
+			c.methods[m] = struct{}{}
+		}
+	}
+}
+
+// WithRequired answers the requests without an idempotency key with 400 Bad Request.
+func WithRequired() Option {
+	return func(c *config) {
+		c.required = true
+	}
+}
+
+// WithTTL keeps the responses for d, 24h by default.
+func WithTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.ttl = d
+	}
+}
+
+// WithLockTTL holds the keys of the requests in flight for up to d, 1m by default, after which a retry is let
+// through, e.g. if the instance handling the request crashed. It must be longer than the time taken to handle a request.
+func WithLockTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.lockTTL = d
+	}
+}
+
+// WithWait waits up to d, 10s by default, for a request in flight with the same key to complete before answering
+// with 409 Conflict.
+func WithWait(d time.Duration) Option {
+	return func(c *config) {
+		c.wait = d
+	}
+}
+
+// WithMaxRequestSize buffers request bodies up to n bytes, 1MiB by default, to fingerprint them.
+// Requests with a larger body are answered with 413 Request Entity Too Large.
+func WithMaxRequestSize(n int64) Option {
+	return func(c *config) {
+		c.maxRequestSize = n
+	}
+}
+
+// WithMaxResponseSize stores responses up to n bytes, 1MiB by default. Larger responses are not replayed.
+func WithMaxResponseSize(n int) Option {
+	return func(c *config) {
+		c.maxResponseSize = n
+	}
+}
+
+// WithScope scopes the keys by the value returned by the function in input, e.g. the ID of the authenticated
+// caller, so that clients cannot replay each other's responses. Defaults to ScopeByCaller.
+func WithScope(fn func(*http.Request) string) Option {
+	return func(c *config) {
+		c.scope = fn
+	}
+}
+
+// ScopeByCaller scopes the keys by the tenant carried by the request context, as set by tenant.Middleware,
+// and by a hash of the Authorization header.
+// Requests carrying neither share a single scope: use WithScope if callers authenticate otherwise, e.g. with cookies.
+func ScopeByCaller(r *http.Request) string {
+	id, _ := tenantid.FromContext(r.Context())
+	var auth string
+	if a := r.Header.Get("Authorization"); a != "" {
+		sum := sha256.Sum256([]byte(a))
+		auth = hex.EncodeToString(sum[:])
+	}
+	return id + ":" + auth
+}
+
+// WithLogger logs the failures of the store and the keys reused with another request.
+func WithLogger(log logger.Logger) Option {
+	return func(c *config) {
+		c.log = log
+	}
+}
+
+// WithMetrics counts the idempotent requests by result on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(c *config) {
+		c.requests = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_server_idempotent_requests_total",
+			Help: "Number of HTTP requests with an idempotency key by result.",
+		}, []string{"result"}))
+	}
+}
+
+// WithClock uses the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+// Middleware honours the Idempotency-Key header of POST and PATCH requests: the first response to a key is stored,
+// and replayed to the retries using the same key within the TTL, flagged by the Idempotent-Replayed header.
+//
+// Retries arriving while the first request is in flight wait for its response, up to the wait time, and are
+// answered with 409 Conflict if it does not complete in time. Keys reused with a different request, as told by the
+// hash of its method, path, query and body, are answered with 422 Unprocessable Entity.
+// Keys are scoped by caller, see WithScope, so that a client cannot replay the response stored for another one.
+// Server errors are not stored, so that the request can be retried; requests are rejected if the store fails.
+func Middleware(store Store, opts ...Option) func(http.Handler) http.Handler {
+	cfg := &config{
+		methods:         map[string]struct{}{http.MethodPost: {}, http.MethodPatch: {}},
+		ttl:             defaultTTL,
+		lockTTL:         defaultLockTTL,
+		wait:            defaultWait,
+		maxRequestSize:  defaultMaxRequestSize,
+		maxResponseSize: defaultMaxResponseSize,
+		scope:           ScopeByCaller,
+		clock:           clock.New(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	m := &middleware{cfg: cfg, store: store, inflight: make(map[string]chan struct{})}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := cfg.methods[r.Method]; !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := r.Header.Get(Header)
+			if key == "" {
+				if cfg.required {
+					respond.Error(w, respond.NewProblem(http.StatusBadRequest, "the request does not carry an "+Header+" header"))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				respond.Error(w, respond.NewProblem(http.StatusBadRequest, Header+" header is too long"))
+				return
+			}
+			m.serve(w, r, next, key)
+		})
+	}
+}
+
+type middleware struct {
+	cfg   *config
+	store Store
+
+	mu       sync.Mutex
+	inflight map[string]chan struct{}
+}
+
+func (m *middleware) serve(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	// an *http.MaxBytesError is answered with 413 by respond.Error
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, m.cfg.maxRequestSize))
+	if err != nil {
+		respond.Error(w, err)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	fingerprint := fingerprint(r, body)
+	key = m.cfg.scope(r) + ":" + key
+
+	deadline := m.cfg.clock.Now().Add(m.cfg.wait)
+	for {
+		rec, err := m.store.Begin(r.Context(), key, Record{Fingerprint: fingerprint, InFlight: true, CreatedAt: m.cfg.clock.Now()}, m.cfg.lockTTL)
+		if err != nil {
+			m.logError(r, "Could not begin idempotent request", err)
+			m.observe("error")
+			respond.Error(w, err)
+			return
+		}
+		switch {
+		case rec == nil:
+			m.handle(w, r, next, key, fingerprint)
+			return
+		case rec.Fingerprint != fingerprint:
+			if m.cfg.log != nil {
+				logger.WithTrace(r.Context(), m.cfg.log.Event(idempotencyEvent).Method(r.Method).URI(r.RequestURI)).
+					Warn("Idempotency key reused with a different request")
+			}
+			m.observe("mismatch")
+			respond.Error(w, respond.NewProblem(http.StatusUnprocessableEntity, Header+" header was already used with a different request"))
+			return
+		case !rec.InFlight:
+			m.observe("replayed")
+			replay(w, rec)
+			return
+		}
+		if !m.waitFor(r, key, deadline) {
+			m.observe("conflict")
+			w.Header().Set("Retry-After", "1")
+			respond.Error(w, respond.NewProblem(http.StatusConflict, "a request with the same "+Header+" header is in progress"))
+			return
+		}
+	}
+}
+
+// handle runs the request holding the key, storing its response.
+func (m *middleware) handle(w http.ResponseWriter, r *http.Request, next http.Handler, key, fingerprint string) {
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.inflight[key] = done
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.inflight, key)
+		m.mu.Unlock()
+		close(done)
+	}()
+
+	// only the headers set by the handler are stored, not the ones of the outer middlewares, e.g. the request ID
+	before := w.Header().Clone()
+	rw := &recorder{ResponseWriter: w, status: http.StatusOK, max: m.cfg.maxResponseSize}
+	// the response is stored even if the client went away, so that its retry is replayed
+	ctx := context.WithoutCancel(r.Context())
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		// release the key if the handler panicked or failed, so that the request can be retried
+		if err := m.store.Release(ctx, key); err != nil {
+			m.logError(r, "Could not release idempotent request", err)
+		}
+	}()
+	next.ServeHTTP(rw, r)
+
+	m.observe("new")
+	if rw.status >= http.StatusInternalServerError || rw.overflow {
+		return
+	}
+	rec := Record{
+		Fingerprint: fingerprint,
+		Status:      rw.status,
+		Header:      headersSet(before, w.Header()),
+		Body:        rw.buf.Bytes(),
+		CreatedAt:   m.cfg.clock.Now(),
+	}
+	if err := m.store.Complete(ctx, key, rec, m.cfg.ttl); err != nil {
+		m.logError(r, "Could not store idempotent response", err)
+		return
+	}
+	completed = true
+}
+
+// waitFor waits for the request in flight with the key to complete, or for the next poll of the store if it is handled
+// by another instance, reporting false if the deadline is reached or the request is cancelled.
+func (m *middleware) waitFor(r *http.Request, key string, deadline time.Time) bool {
+	remaining := deadline.Sub(m.cfg.clock.Now())
+	if remaining <= 0 {
+		return false
+	}
+	m.mu.Lock()
+	done, local := m.inflight[key]
+	m.mu.Unlock()
+	wait := defaultPollInterval
+	if local || remaining < wait {
+		wait = remaining
+	}
+	timer := m.cfg.clock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C():
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func (m *middleware) observe(result string) {
+	if m.cfg.requests != nil {
+		m.cfg.requests.WithLabelValues(result).Inc()
+	}
+}
+
+func (m *middleware) logError(r *http.Request, msg string, err error) {
+	if m.cfg.log != nil {
+		logger.WithTrace(r.Context(), m.cfg.log.Event(idempotencyEvent).Method(r.Method).URI(r.RequestURI)).Error(msg, err)
+	}
+}
+
+// fingerprint hashes the method, path, query and body of the request.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// headersSet returns the headers of after which are not in before or have a different value.
+func headersSet(before, after http.Header) http.Header {
+	out := make(http.Header)
+	for k, v := range after {
+		if !slices.Equal(before[k], v) {
+			out[k] = slices.Clone(v)
+		}
+	}
+	return out
+}
+
+func replay(w http.ResponseWriter, rec *Record) {
+	dst := w.Header()
+	for k, v := range rec.Header {
+		dst[k] = v
+	}
+	dst.Set(ReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	_, _ = w.Write(rec.Body)
+}
+
+// recorder writes the response through, keeping a copy of the body up to max bytes.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	max         int
+	buf         bytes.Buffer
+	overflow    bool
+}
+
+func (rw *recorder) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	if !rw.overflow {
+		if rw.buf.Len()+len(b) > rw.max {
+			rw.overflow = true
+			rw.buf.Reset()
+		} else {
+			rw.buf.Write(b)
+		}
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
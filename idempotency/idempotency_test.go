@@ -0,0 +1,152 @@
+package idempotency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/indiependente/pkg/tenantid"
+)
+
+type request struct {
+	method string
+	target string
+	body   string
+	key    string
+	auth   string
+	tenant string
+}
+
+func (req request) send(h http.Handler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(req.method, req.target, strings.NewReader(req.body))
+	if req.key != "" {
+		r.Header.Set(Header, req.key)
+	}
+	if req.auth != "" {
+		r.Header.Set("Authorization", req.auth)
+	}
+	if req.tenant != "" {
+		r = r.WithContext(tenantid.NewContext(r.Context(), req.tenant))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	first := request{method: http.MethodPost, target: "/orders?dry_run=false", body: `{"amount":1}`, key: "k1", auth: "Bearer alice"}
+	tests := []struct {
+		name         string
+		retry        request
+		wantStatus   int
+		wantReplayed bool
+		wantCalls    int32
+	}{
+		{
+			name:         "replay",
+			retry:        first,
+			wantStatus:   http.StatusCreated,
+			wantReplayed: true,
+			wantCalls:    1,
+		},
+		{
+			name:       "different body",
+			retry:      request{method: http.MethodPost, target: first.target, body: `{"amount":2}`, key: "k1", auth: "Bearer alice"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCalls:  1,
+		},
+		{
+			name:       "different query",
+			retry:      request{method: http.MethodPost, target: "/orders?dry_run=true", body: first.body, key: "k1", auth: "Bearer alice"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCalls:  1,
+		},
+		{
+			name:       "different path",
+			retry:      request{method: http.MethodPost, target: "/refunds?dry_run=false", body: first.body, key: "k1", auth: "Bearer alice"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCalls:  1,
+		},
+		{
+			name:       "other caller reusing the key",
+			retry:      request{method: http.MethodPost, target: first.target, body: first.body, key: "k1", auth: "Bearer mallory"},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+		},
+		{
+			name:       "other tenant reusing the key",
+			retry:      request{method: http.MethodPost, target: first.target, body: first.body, key: "k1", auth: "Bearer alice", tenant: "acme"},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+		},
+		{
+			name:       "other key",
+			retry:      request{method: http.MethodPost, target: first.target, body: first.body, key: "k2", auth: "Bearer alice"},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+		},
+		{
+			name:       "without key",
+			retry:      request{method: http.MethodPost, target: first.target, body: first.body, auth: "Bearer alice"},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			h := Middleware(NewMemoryStore(nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: fmt.Sprint(n)})
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, "order %d", n)
+			}))
+
+			if w := first.send(h); w.Code != http.StatusCreated {
+				t.Fatalf("first request: got status %d, want %d", w.Code, http.StatusCreated)
+			}
+			w := tt.retry.send(h)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if replayed := w.Header().Get(ReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Fatalf("replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantReplayed && (w.Body.String() != "order 1" || !strings.Contains(w.Header().Get("Set-Cookie"), "session=1")) {
+				t.Fatalf("replayed body %q and cookie %q, want the first response", w.Body.String(), w.Header().Get("Set-Cookie"))
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("got %d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMiddlewareDoesNotStoreServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	h := Middleware(NewMemoryStore(nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	req := request{method: http.MethodPost, target: "/orders", body: "{}", key: "k"}
+	if w := req.send(h); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if w := req.send(h); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("retry got status %d, replayed %q, want a new response", w.Code, w.Header().Get(ReplayedHeader))
+	}
+}
+
+func TestMiddlewareRejectsLongKeys(t *testing.T) {
+	h := Middleware(NewMemoryStore(nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := request{method: http.MethodPost, target: "/", key: strings.Repeat("k", maxKeyLength+1)}
+	if w := req.send(h); w.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// beginScript returns the record of the key, or stores the in flight one if there is none.
+	beginScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	return v
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+	// releaseScript deletes the record of the key if it is in flight.
+	releaseScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v and cjson.decode(v)['in_flight'] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// RedisStore keeps the records in Redis, encoded as JSON, sharing them across instances.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// compile time interface check.
+var _ Store = &RedisStore{}
+
+// NewRedisStore returns a RedisStore using the client in input, e.g. a *redis.Client or a *redis.ClusterClient,
+// and prepending prefix to the keys.
+func NewRedisStore(client redis.Cmdable, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Begin marks the key as in flight unless it already has a record.
+func (s *RedisStore) Begin(ctx context.Context, key string, rec Record, lockTTL time.Duration) (*Record, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode idempotency record: %w", err)
+	}
+	v, err := beginScript.Run(ctx, s.client, []string{s.prefix + key}, data, lockTTL.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not begin idempotent request %s: %w", key, err)
+	}
+	var existing Record
+	if err := json.Unmarshal([]byte(v), &existing); err != nil {
+		return nil, fmt.Errorf("could not decode idempotency record %s: %w", key, err)
+	}
+	return &existing, nil
+}
+
+// Complete stores the record of the key.
+func (s *RedisStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("could not encode idempotency record: %w", err)
+	}
+	if err := s.client.Set(ctx, s.prefix+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("could not complete idempotent request %s: %w", key, err)
+	}
+	return nil
+}
+
+// Release deletes the record of the key if it is in flight.
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	if err := releaseScript.Run(ctx, s.client, []string{s.prefix + key}).Err(); err != nil {
+		return fmt.Errorf("could not release idempotent request %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+// Record is the state of an idempotency key: either a request in flight or the response to replay.
+type Record struct {
+	// Fingerprint identifies the request the key was first used with, to detect keys reused with another request.
+	Fingerprint string      `json:"fingerprint"`
+	InFlight    bool        `json:"in_flight,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Store stores the records of the idempotency keys.
+type Store interface {
+	// Begin marks the key as in flight with the record in input for up to lockTTL, returning nil, unless the key
+	// already has a record, which is returned instead.
+	Begin(ctx context.Context, key string, rec Record, lockTTL time.Duration) (*Record, error)
+	// Complete replaces the in flight record of the key with the one in input, kept for ttl.
+	Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error
+	// Release deletes the in flight record of the key, so that the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore keeps the records in memory. Expired records are dropped when their key is used again,
+// or by Sweep.
+type MemoryStore struct {
+	clock clock.Clock
+
+	mu      sync.Mutex
+	records map[string]memoryRecord
+}
+
+// compile time interface check.
+var _ Store = &MemoryStore{}
+
+type memoryRecord struct {
+	rec     Record
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore telling the time with the clock in input, clock.New() if nil.
+func NewMemoryStore(c clock.Clock) *MemoryStore {
+	if c == nil {
+		c = clock.New()
+	}
+	return &MemoryStore{clock: c, records: make(map[string]memoryRecord)}
+}
+
+// Begin marks the key as in flight unless it already has a record.
+func (s *MemoryStore) Begin(_ context.Context, key string, rec Record, lockTTL time.Duration) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	if r, ok := s.records[key]; ok && now.Before(r.expires) {
+		existing := r.rec
+		return &existing, nil
+	}
+	s.records[key] = memoryRecord{rec: rec, expires: now.Add(lockTTL)}
+	return nil, nil
+}
+
+// Complete stores the record of the key.
+func (s *MemoryStore) Complete(_ context.Context, key string, rec Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryRecord{rec: rec, expires: s.clock.Now().Add(ttl)}
+	return nil
+}
+
+// Release deletes the record of the key if it is in flight.
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok && r.rec.InFlight {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// Sweep drops the expired records.
+func (s *MemoryStore) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	for k, r := range s.records {
+		if !now.Before(r.expires) {
+			delete(s.records, k)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package ipx
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseAddr parses an IP address, with or without a port, unmapping IPv4-mapped IPv6 addresses
+// and dropping zones.
+func ParseAddr(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("could not parse IP address: %w", err)
+	}
+	return addr.Unmap().WithZone(""), nil
+}
+
+// ParsePrefixes parses CIDR prefixes, e.g. 10.0.0.0/8; plain addresses are turned into single address prefixes.
+func ParsePrefixes(cidrs ...string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if !strings.Contains(c, "/") {
+			addr, err := ParseAddr(c)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse CIDR: %w", err)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// MustParsePrefixes is like ParsePrefixes but panics on errors. It is meant for constants.
+func MustParsePrefixes(cidrs ...string) []netip.Prefix {
+	prefixes, err := ParsePrefixes(cidrs...)
+	if err != nil {
+		panic(err)
+	}
+	return prefixes
+}
+
+// Contains reports whether any of the prefixes contains the address.
+func Contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIPOption customises how ClientIP reads the forwarding headers.
+type ClientIPOption func(*clientIPConfig)
+
+type clientIPConfig struct {
+	forwarded bool
+}
+
+// FromForwarded reads the hops from the standard Forwarded header (RFC 7239) instead of X-Forwarded-For.
+// Use it only if the trusted proxies set Forwarded, as the header is otherwise under the control of the client.
+func FromForwarded() ClientIPOption {
+	return func(c *clientIPConfig) {
+		c.forwarded = true
+	}
+}
+
+// ClientIP returns the address of the client which sent the request. The remote address of the connection is
+// returned, unless it belongs to a trusted proxy: then X-Forwarded-For, or Forwarded with the FromForwarded
+// option, is walked from the closest hop, skipping the trusted proxies, and the first address which is not one
+// is returned. Only the header set by the trusted proxies is read: the other one is under the control of the
+// client. Addresses appended by untrusted hops can be spoofed, so they are never skipped.
+// The zero Addr is returned when the remote address cannot be parsed.
+func ClientIP(r *http.Request, trustedProxies []netip.Prefix, opts ...ClientIPOption) netip.Addr {
+	remote, err := ParseAddr(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}
+	}
+	if !Contains(trustedProxies, remote) {
+		return remote
+	}
+	cfg := &clientIPConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	var hops []string
+	if cfg.forwarded {
+		hops = forwardedHops(r.Header)
+	} else {
+		hops = forwardedForHops(r.Header)
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := ParseAddr(hops[i])
+		if err != nil {
+			// obfuscated or malformed hops, e.g. "unknown", cannot be trusted to be the client nor skipped
+			break
+		}
+		client = addr
+		if !Contains(trustedProxies, addr) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedHops returns the hops of the Forwarded header, from the farthest one.
+func forwardedHops(h http.Header) []string {
+	var hops []string
+	for _, v := range h.Values("Forwarded") {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(k, "for") {
+					hops = append(hops, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedForHops returns the hops of the X-Forwarded-For header, from the farthest one.
+func forwardedForHops(h http.Header) []string {
+	var hops []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// bogons are the ranges which are not routable on the public internet, besides the private,
+// loopback, link-local and multicast ones detected by netip.
+var bogons = MustParsePrefixes(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"240.0.0.0/4",
+	"255.255.255.255/32",
+	"::/128",
+	"100::/64",
+	"2001:db8::/32",
+)
+
+// IsPrivate reports whether the address belongs to a private network (RFC 1918, RFC 4193), the loopback interface
+// or a link-local network.
+func IsPrivate(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast()
+}
+
+// IsBogon reports whether the address is not routable on the public internet: private, reserved, shared,
+// documentation, multicast or unspecified.
+func IsBogon(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || IsPrivate(addr) || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	return Contains(bogons, addr)
+}
+
+// IsPublic reports whether the address is routable on the public internet.
+func IsPublic(addr netip.Addr) bool {
+	return !IsBogon(addr)
+}
@@ -0,0 +1,196 @@
+package ipx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := MustParsePrefixes("10.0.0.0/8")
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		opts    []ClientIPOption
+		want    string
+	}{
+		{
+			name:   "direct client",
+			remote: "203.0.113.7:1234",
+			want:   "203.0.113.7",
+		},
+		{
+			name:    "untrusted remote spoofing X-Forwarded-For",
+			remote:  "203.0.113.7:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "untrusted remote spoofing Forwarded",
+			remote:  "203.0.113.7:1234",
+			headers: map[string]string{"Forwarded": "for=1.2.3.4"},
+			opts:    []ClientIPOption{FromForwarded()},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "trusted proxy",
+			remote:  "10.0.0.1:80",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "trusted proxy chain",
+			remote:  "10.0.0.1:80",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.2"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "client prepending a spoofed hop",
+			remote:  "10.0.0.1:80",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:   "client sending Forwarded to a proxy setting X-Forwarded-For",
+			remote: "10.0.0.1:80",
+			headers: map[string]string{
+				"Forwarded":       "for=1.2.3.4",
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:   "client sending X-Forwarded-For to a proxy setting Forwarded",
+			remote: "10.0.0.1:80",
+			headers: map[string]string{
+				"Forwarded":       `for="[2001:db8::1]:4711";proto=https`,
+				"X-Forwarded-For": "1.2.3.4",
+			},
+			opts: []ClientIPOption{FromForwarded()},
+			want: "2001:db8::1",
+		},
+		{
+			name:    "malformed hop",
+			remote:  "10.0.0.1:80",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1, unknown"},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "IPv4-mapped remote",
+			remote:  "[::ffff:10.0.0.1]:80",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := ClientIP(r, trusted, tt.opts...); got != netip.MustParseAddr(tt.want) {
+				t.Fatalf("ClientIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{in: "192.0.2.1", want: "192.0.2.1/32"},
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "::ffff:192.0.2.1", want: "192.0.2.1/32"},
+		{in: "not an address", wantErr: true},
+		{in: "10.0.0.0/33", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePrefixes(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrefixes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got[0] != netip.MustParsePrefix(tt.want) {
+				t.Fatalf("ParsePrefixes() = %v, want %v", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBogon(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "8.8.8.8", want: false},
+		{addr: "2606:4700::1111", want: false},
+		{addr: "10.0.0.1", want: true},
+		{addr: "127.0.0.1", want: true},
+		{addr: "100.64.0.1", want: true},
+		{addr: "192.0.2.1", want: true},
+		{addr: "::ffff:192.168.1.1", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "2001:db8::1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := IsBogon(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Fatalf("IsBogon() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	rule := NewRule(MustParsePrefixes("198.51.100.0/24"), MustParsePrefixes("198.51.100.66"))
+	h := Middleware(rule, MustParsePrefixes("10.0.0.0/8"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    int
+	}{
+		{name: "allowed", remote: "198.51.100.1:1", want: http.StatusOK},
+		{name: "denied", remote: "198.51.100.66:1", want: http.StatusForbidden},
+		{name: "outside allow list", remote: "203.0.113.1:1", want: http.StatusForbidden},
+		{
+			name:    "spoofed X-Forwarded-For from untrusted remote",
+			remote:  "203.0.113.1:1",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "spoofed Forwarded through trusted proxy",
+			remote:  "10.0.0.1:1",
+			headers: map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "203.0.113.1"},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "allowed through trusted proxy",
+			remote:  "10.0.0.1:1",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package ipx
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/indiependente/pkg/httpx/respond"
+)
+
+// Rule decides whether a client address is allowed.
+type Rule struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// NewRule returns a Rule allowing the addresses in the allow prefixes, or any address if there are none,
+// unless they are in the deny prefixes.
+func NewRule(allow, deny []netip.Prefix) *Rule {
+	return &Rule{allow: allow, deny: deny}
+}
+
+// Allowed reports whether the address is allowed.
+func (r *Rule) Allowed(addr netip.Addr) bool {
+	if !addr.IsValid() || Contains(r.deny, addr) {
+		return false
+	}
+	return len(r.allow) == 0 || Contains(r.allow, addr)
+}
+
+// Middleware answers the requests from clients not allowed by the rule with 403 Forbidden.
+// The client address is found with ClientIP, the trusted proxies and the options in input.
+func Middleware(rule *Rule, trustedProxies []netip.Prefix, opts ...ClientIPOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !rule.Allowed(ClientIP(r, trustedProxies, opts...)) {
+				respond.Error(w, respond.NewProblem(http.StatusForbidden, "the client address is not allowed"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
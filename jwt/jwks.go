@@ -0,0 +1,293 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/dedupe"
+)
+
+const (
+	defaultJWKSTTL = time.Hour
+	// minJWKSRefresh is the minimum interval between two fetches triggered by unknown key IDs.
+	minJWKSRefresh = 10 * time.Second
+	// jwksFetchTimeout bounds a fetch of the key set, so that a hanging endpoint does not block verifications.
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// JWKSOption customises the JWKS key source.
+type JWKSOption func(*JWKS)
+
+// WithHTTPClient fetches the key set with the client in input instead of a client with a 10s timeout.
+func WithHTTPClient(c *http.Client) JWKSOption {
+	return func(j *JWKS) {
+		j.client = c
+	}
+}
+
+// WithTTL caches the key set for ttl. Defaults to 1h.
+func WithTTL(ttl time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.ttl = ttl
+	}
+}
+
+// WithJWKSClock tells the time with the clock in input, e.g. a clock.Fake in tests.
+func WithJWKSClock(c clock.Clock) JWKSOption {
+	return func(j *JWKS) {
+		j.clock = c
+	}
+}
+
+// JWKS resolves verification keys by key ID from the JSON Web Key Set published at a URL.
+// The key set is cached for a TTL and fetched again earlier when a token references an unknown key ID,
+// so that keys rotated by the issuer are picked up.
+// Concurrent fetches are coalesced into one and fetches after a failure are rate limited as after a success.
+type JWKS struct {
+	client  *http.Client
+	url     string
+	ttl     time.Duration
+	clock   clock.Clock
+	fetches *dedupe.Group[struct{}, struct{}]
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetched   time.Time
+	attempted time.Time
+}
+
+// NewJWKS returns a key source for the key set published at url. Keys are fetched on first use.
+func NewJWKS(url string, opts ...JWKSOption) *JWKS {
+	j := &JWKS{
+		client:  &http.Client{Timeout: jwksFetchTimeout},
+		url:     url,
+		ttl:     defaultJWKSTTL,
+		clock:   clock.New(),
+		fetches: dedupe.New[struct{}, struct{}](),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Keyfunc resolves the key of the token by its kid header. It can be passed to NewVerifier.
+// If the key set cannot be fetched, the cached keys keep being used.
+func (j *JWKS) Keyfunc(token *Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok, expired, attempted := j.lookup(kid)
+	if ok && !expired {
+		return key, nil
+	}
+	if j.clock.Since(attempted) > minJWKSRefresh {
+		if err := j.Refresh(context.Background()); err != nil && !ok {
+			return nil, err
+		}
+		key, ok, _, _ = j.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// lookup returns the cached key with the ID in input, whether the key set expired and when it was last fetched.
+func (j *JWKS) lookup(kid string) (key crypto.PublicKey, ok, expired bool, attempted time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	key, ok = j.keys[kid]
+	return key, ok, j.clock.Since(j.fetched) > j.ttl, j.attempted
+}
+
+// Refresh fetches the key set, joining the fetch in progress if any.
+func (j *JWKS) Refresh(ctx context.Context) error {
+	_, err := j.fetches.DoValue(ctx, struct{}{}, j.fetch)
+	return err
+}
+
+// Watch refreshes the key set every TTL until the context is done, so that tokens never wait for a fetch.
+// Failed refreshes are retried at the next tick. It is meant to be run in its own goroutine.
+func (j *JWKS) Watch(ctx context.Context) {
+	_ = j.Refresh(ctx)
+	ticker := j.clock.NewTicker(j.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			_ = j.Refresh(ctx)
+		}
+	}
+}
+
+// fetch downloads the key set, keeping the keys it can parse. It records the attempt whatever its outcome.
+func (j *JWKS) fetch(ctx context.Context) (struct{}, error) {
+	j.mu.Lock()
+	j.attempted = j.clock.Now()
+	j.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return struct{}{}, fmt.Errorf("could not create JWKS request: %w", err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return struct{}{}, fmt.Errorf("could not fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return struct{}{}, fmt.Errorf("could not fetch JWKS: unexpected status %s", resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return struct{}{}, fmt.Errorf("could not decode JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	j.mu.Lock()
+	j.keys = keys
+	j.fetched = j.clock.Now()
+	j.mu.Unlock()
+	return struct{}{}, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// PublicJWK returns the JSON Web Key of the public key in input, for publishing in a key set.
+// Supported keys are *rsa.PublicKey and *ecdsa.PublicKey on the P-256 curve.
+func PublicJWK(kid string, pub crypto.PublicKey) (map[string]string, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA", "kid": kid, "alg": string(RS256), "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+		}
+		b, err := k.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("could not encode key: %w", err)
+		}
+		// uncompressed point: 0x04 || X || Y
+		return map[string]string{
+			"kty": "EC", "kid": kid, "alg": string(ES256), "use": "sig", "crv": "P-256",
+			"x": base64.RawURLEncoding.EncodeToString(b[1:33]),
+			"y": base64.RawURLEncoding.EncodeToString(b[33:]),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", pub)
+}
+
+// JWKSHandler returns an HTTP handler publishing the public keys in input, by key ID, as a JSON Web Key Set.
+func JWKSHandler(keys map[string]crypto.PublicKey) (http.Handler, error) {
+	set := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	for kid, pub := range keys {
+		jwk, err := PublicJWK(kid, pub)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	body, err := json.Marshal(set)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode JWKS: %w", err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}), nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,111 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+func TestJWKSKeyfunc(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, err := JWKSHandler(map[string]crypto.PublicKey{"es": &key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	fake := clock.NewFake(time.Now())
+	jwks := NewJWKS(srv.URL, WithJWKSClock(fake))
+	s, err := NewSigner(ES256, "es", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.Sign(RegisteredClaims{ExpiresAt: NewNumericDate(time.Now().Add(time.Hour))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(jwks.Keyfunc)
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(token); err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("got %d fetches, want 1", got)
+	}
+
+	unknown := &Token{Header: map[string]interface{}{"kid": "unknown"}}
+	if _, err := jwks.Keyfunc(unknown); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Keyfunc() error = %v, want %v", err, ErrUnknownKey)
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("unknown key ID within the refresh interval: got %d fetches, want 1", got)
+	}
+	fake.Advance(minJWKSRefresh + time.Second)
+	if _, err := jwks.Keyfunc(unknown); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Keyfunc() error = %v, want %v", err, ErrUnknownKey)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("unknown key ID after the refresh interval: got %d fetches, want 2", got)
+	}
+}
+
+func TestJWKSRateLimitsFailedFetches(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	fake := clock.NewFake(time.Now())
+	jwks := NewJWKS(srv.URL, WithJWKSClock(fake))
+	token := &Token{Header: map[string]interface{}{"kid": "es"}}
+	for i := 0; i < 5; i++ {
+		if _, err := jwks.Keyfunc(token); err == nil {
+			t.Fatal("Keyfunc() returned a key while the endpoint is down")
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("got %d fetches, want 1", got)
+	}
+	fake.Advance(minJWKSRefresh + time.Second)
+	_, _ = jwks.Keyfunc(token)
+	if got := fetches.Load(); got != 2 {
+		t.Fatalf("got %d fetches, want 2", got)
+	}
+}
+
+func TestJWKSRefreshHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	jwks := NewJWKS(srv.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := jwks.Refresh(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Refresh() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
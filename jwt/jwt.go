@@ -0,0 +1,120 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithm is a JWT signing algorithm.
+type Algorithm string
+
+const (
+	// HS256 is HMAC with SHA-256, using a shared secret.
+	HS256 Algorithm = "HS256"
+	// RS256 is RSASSA-PKCS1-v1_5 with SHA-256, using an RSA key pair.
+	RS256 Algorithm = "RS256"
+	// ES256 is ECDSA with the P-256 curve and SHA-256, using an EC key pair.
+	ES256 Algorithm = "ES256"
+)
+
+// ErrUnknownKey is returned when a token references a key ID which is not known.
+var ErrUnknownKey = errors.New("unknown key ID")
+
+type (
+	// Claims is implemented by the claims types accepted by Sign and VerifyClaims.
+	Claims = gojwt.Claims
+	// RegisteredClaims holds the standard claims; embed it in custom claims types.
+	RegisteredClaims = gojwt.RegisteredClaims
+	// MapClaims holds the claims of a token as a map.
+	MapClaims = gojwt.MapClaims
+	// NumericDate is a JWT date.
+	NumericDate = gojwt.NumericDate
+	// Token is a parsed token.
+	Token = gojwt.Token
+	// Keyfunc returns the key verifying the token in input.
+	Keyfunc = gojwt.Keyfunc
+)
+
+// NewNumericDate returns the NumericDate of t.
+func NewNumericDate(t time.Time) *NumericDate {
+	return gojwt.NewNumericDate(t)
+}
+
+func (a Algorithm) method() (gojwt.SigningMethod, error) {
+	switch a {
+	case HS256:
+		return gojwt.SigningMethodHS256, nil
+	case RS256:
+		return gojwt.SigningMethodRS256, nil
+	case ES256:
+		return gojwt.SigningMethodES256, nil
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q", a)
+}
+
+// checkKey reports an error if the key cannot sign with the algorithm.
+func (a Algorithm) checkKey(key crypto.PrivateKey) error {
+	var ok bool
+	switch a {
+	case HS256:
+		_, ok = key.([]byte)
+	case RS256:
+		_, ok = key.(*rsa.PrivateKey)
+	case ES256:
+		_, ok = key.(*ecdsa.PrivateKey)
+	}
+	if !ok {
+		return fmt.Errorf("key of type %T cannot sign %s tokens", key, a)
+	}
+	return nil
+}
+
+// KeySet holds verification keys by key ID and can be updated at any time, e.g. to rotate keys:
+// add the new key, start signing with it, and remove the old one once the tokens it signed have expired.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys map[string]interface{}
+}
+
+// NewKeySet returns a key set holding the keys in input, by key ID.
+// Keys are []byte secrets for HS256, *rsa.PublicKey for RS256 and *ecdsa.PublicKey for ES256.
+func NewKeySet(keys map[string]interface{}) *KeySet {
+	ks := &KeySet{keys: make(map[string]interface{}, len(keys))}
+	for kid, k := range keys {
+		ks.keys[kid] = k
+	}
+	return ks
+}
+
+// Set adds or replaces the key with the key ID in input.
+func (ks *KeySet) Set(kid string, key interface{}) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = key
+}
+
+// Remove removes the key with the key ID in input.
+func (ks *KeySet) Remove(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	delete(ks.keys, kid)
+}
+
+// Keyfunc resolves the key of the token by its kid header. It can be passed to NewVerifier.
+func (ks *KeySet) Keyfunc(token *Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
@@ -0,0 +1,202 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/indiependente/pkg/clock"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewKeySet(map[string]interface{}{
+		"hs":  secret,
+		"es":  &ecKey.PublicKey,
+		"bad": &otherKey.PublicKey,
+	})
+	claims := RegisteredClaims{
+		Issuer:    "issuer",
+		ExpiresAt: NewNumericDate(now.Add(time.Hour)),
+	}
+	sign := func(alg Algorithm, kid string, key interface{}, c Claims) string {
+		t.Helper()
+		s, err := NewSigner(alg, kid, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := s.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(HS256, "hs", secret, claims)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "HS256", token: valid},
+		{name: "ES256", token: sign(ES256, "es", ecKey, claims)},
+		{
+			name:    "tampered payload",
+			token:   tamper(t, valid),
+			wantErr: true,
+		},
+		{
+			name:    "tampered signature",
+			token:   valid[:len(valid)-2] + "AA",
+			wantErr: true,
+		},
+		{
+			name:    "wrong secret",
+			token:   sign(HS256, "hs", []byte("another secret of thirty-two bytes"), claims),
+			wantErr: true,
+		},
+		{
+			name:    "wrong key ID",
+			token:   sign(ES256, "bad", ecKey, claims),
+			wantErr: true,
+		},
+		{
+			name:    "unknown key ID",
+			token:   sign(HS256, "missing", secret, claims),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   sign(HS256, "hs", secret, RegisteredClaims{ExpiresAt: NewNumericDate(now.Add(-time.Minute))}),
+			wantErr: true,
+		},
+		{
+			name:    "missing expiration",
+			token:   sign(HS256, "hs", secret, RegisteredClaims{Issuer: "issuer"}),
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			token:   sign(HS256, "hs", secret, RegisteredClaims{Issuer: "other", ExpiresAt: claims.ExpiresAt}),
+			wantErr: true,
+		},
+		{
+			name:    "alg none",
+			token:   unsigned(t, claims),
+			wantErr: true,
+		},
+	}
+	v := NewVerifier(keys.Keyfunc, WithIssuer("issuer"), WithClock(clock.NewFake(now)))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyConfusion(t *testing.T) {
+	// An HS256 token signed with the bytes of a public key must not verify against that key.
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ecKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := gojwt.NewWithClaims(gojwt.SigningMethodHS256, RegisteredClaims{ExpiresAt: NewNumericDate(time.Now().Add(time.Hour))})
+	token.Header["kid"] = "es"
+	signed, err := token.SignedString(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewKeySet(map[string]interface{}{"es": &ecKey.PublicKey})
+	if _, err := NewVerifier(keys.Keyfunc).Verify(signed); err == nil {
+		t.Fatal("Verify() accepted an HS256 token signed with a public key")
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	keys := NewKeySet(map[string]interface{}{"old": []byte("old secret")})
+	s, err := NewSigner(HS256, "old", []byte("old secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(keys.Keyfunc, WithoutExpiration())
+	old, err := s.Sign(RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys.Set("new", []byte("new secret"))
+	if err := s.Rotate(HS256, "new", []byte("new secret")); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := s.Sign(RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{old, rotated} {
+		if _, err := v.Verify(token); err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+	}
+
+	keys.Remove("old")
+	if _, err := v.Verify(old); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestSignerRejectsMismatchedKey(t *testing.T) {
+	if _, err := NewSigner(ES256, "kid", []byte("secret")); err == nil {
+		t.Fatal("NewSigner() accepted a secret for ES256")
+	}
+	if _, err := NewSigner("none", "kid", []byte("secret")); err == nil {
+		t.Fatal("NewSigner() accepted the none algorithm")
+	}
+}
+
+// tamper replaces the payload of the token with one claiming another issuer, keeping the signature.
+func tamper(t *testing.T, token string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	forged := gojwt.NewWithClaims(gojwt.SigningMethodHS256, RegisteredClaims{
+		Issuer:    "attacker",
+		ExpiresAt: NewNumericDate(time.Now().Add(24 * time.Hour)),
+	})
+	s, err := forged.SigningString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parts[0] + "." + strings.Split(s, ".")[1] + "." + parts[2]
+}
+
+// unsigned returns the token carrying the claims in input with the none algorithm.
+func unsigned(t *testing.T, c Claims) string {
+	t.Helper()
+	token := gojwt.NewWithClaims(gojwt.SigningMethodNone, c)
+	token.Header["kid"] = "hs"
+	s, err := token.SignedString(gojwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package jwt
+
+import (
+	"crypto"
+	"fmt"
+	"sync"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	alg    Algorithm
+	method gojwt.SigningMethod
+	kid    string
+	key    crypto.PrivateKey
+}
+
+// Signer issues signed tokens. It is safe for concurrent use, including while rotating keys.
+type Signer struct {
+	mu  sync.RWMutex
+	cur signingKey
+}
+
+// NewSigner returns a signer issuing tokens signed with the algorithm and key in input, stamped with the key ID.
+// Keys are []byte secrets for HS256, *rsa.PrivateKey for RS256 and *ecdsa.PrivateKey for ES256.
+func NewSigner(alg Algorithm, kid string, key crypto.PrivateKey) (*Signer, error) {
+	s := &Signer{}
+	if err := s.Rotate(alg, kid, key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Rotate signs the next tokens with the algorithm, key ID and key in input.
+// Verifiers must know the new key before the rotation and keep the old one until the tokens it signed expire.
+func (s *Signer) Rotate(alg Algorithm, kid string, key crypto.PrivateKey) error {
+	method, err := alg.method()
+	if err != nil {
+		return err
+	}
+	if err := alg.checkKey(key); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur = signingKey{alg: alg, method: method, kid: kid, key: key}
+	return nil
+}
+
+// Sign returns the signed token carrying the claims in input, e.g. RegisteredClaims, MapClaims
+// or a custom type embedding RegisteredClaims.
+func (s *Signer) Sign(claims Claims) (string, error) {
+	s.mu.RLock()
+	k := s.cur
+	s.mu.RUnlock()
+
+	t := gojwt.NewWithClaims(k.method, claims)
+	if k.kid != "" {
+		t.Header["kid"] = k.kid
+	}
+	signed, err := t.SignedString(k.key)
+	if err != nil {
+		return "", fmt.Errorf("could not sign token: %w", err)
+	}
+	return signed, nil
+}
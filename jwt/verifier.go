@@ -0,0 +1,119 @@
+package jwt
+
+import (
+	"fmt"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/indiependente/pkg/clock"
+)
+
+// VerifierOption customises the Verifier.
+type VerifierOption func(*verifierConfig)
+
+type verifierConfig struct {
+	algs    []string
+	parser  []gojwt.ParserOption
+	clock   clock.Clock
+	noExpOK bool
+}
+
+// WithIssuer requires the iss claim to be the one in input.
+func WithIssuer(iss string) VerifierOption {
+	return func(c *verifierConfig) {
+		c.parser = append(c.parser, gojwt.WithIssuer(iss))
+	}
+}
+
+// WithAudience requires the aud claim to contain the audience in input.
+func WithAudience(aud string) VerifierOption {
+	return func(c *verifierConfig) {
+		c.parser = append(c.parser, gojwt.WithAudience(aud))
+	}
+}
+
+// WithLeeway tolerates the clock skew in input when validating the exp, nbf and iat claims.
+func WithLeeway(d time.Duration) VerifierOption {
+	return func(c *verifierConfig) {
+		c.parser = append(c.parser, gojwt.WithLeeway(d))
+	}
+}
+
+// WithAlgorithms accepts only tokens signed with the algorithms in input. Defaults to HS256, RS256 and ES256.
+// Without arguments, any algorithm matching the type of the resolved key is accepted.
+func WithAlgorithms(algs ...Algorithm) VerifierOption {
+	return func(c *verifierConfig) {
+		c.algs = nil
+		for _, a := range algs {
+			c.algs = append(c.algs, string(a))
+		}
+	}
+}
+
+// WithoutExpiration accepts tokens without the exp claim, which is required by default.
+func WithoutExpiration() VerifierOption {
+	return func(c *verifierConfig) {
+		c.noExpOK = true
+	}
+}
+
+// WithClock validates the time based claims against the clock in input, e.g. a clock.Fake in tests.
+func WithClock(cl clock.Clock) VerifierOption {
+	return func(c *verifierConfig) {
+		c.clock = cl
+	}
+}
+
+// WithParserOptions passes the options in input to the underlying github.com/golang-jwt/jwt parser.
+func WithParserOptions(opts ...gojwt.ParserOption) VerifierOption {
+	return func(c *verifierConfig) {
+		c.parser = append(c.parser, opts...)
+	}
+}
+
+// Verifier validates tokens: their signature, against the keys resolved by its Keyfunc, and their standard claims.
+type Verifier struct {
+	keyfunc Keyfunc
+	parser  *gojwt.Parser
+}
+
+// NewVerifier returns a verifier resolving keys with the keyfunc in input, e.g. KeySet.Keyfunc or JWKS.Keyfunc.
+func NewVerifier(keyfunc Keyfunc, opts ...VerifierOption) *Verifier {
+	cfg := &verifierConfig{
+		algs:  []string{string(HS256), string(RS256), string(ES256)},
+		clock: clock.New(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	popts := []gojwt.ParserOption{gojwt.WithTimeFunc(cfg.clock.Now)}
+	if len(cfg.algs) > 0 {
+		popts = append(popts, gojwt.WithValidMethods(cfg.algs))
+	}
+	if !cfg.noExpOK {
+		popts = append(popts, gojwt.WithExpirationRequired())
+	}
+	return &Verifier{keyfunc: keyfunc, parser: gojwt.NewParser(append(popts, cfg.parser...)...)}
+}
+
+// Verify validates the token, returning its claims.
+func (v *Verifier) Verify(token string) (MapClaims, error) {
+	claims := MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyfunc); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	return claims, nil
+}
+
+// VerifyClaims validates the token with the verifier in input, decoding its claims in a C, typically
+// a struct embedding RegisteredClaims, e.g. VerifyClaims[MyClaims](v, token).
+func VerifyClaims[C any, PC interface {
+	*C
+	Claims
+}](v *Verifier, token string) (*C, error) {
+	claims := PC(new(C))
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyfunc); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,197 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+var (
+	// ErrNotAcquired is returned when the lock is held by someone else.
+	ErrNotAcquired = errors.New("lock not acquired")
+	// ErrNotHeld is returned when refreshing or releasing a lock which expired or was taken over.
+	ErrNotHeld = errors.New("lock not held")
+	// ErrLost is the cause of the context cancelled by Run when the lock is lost.
+	ErrLost = errors.New("lock lost")
+	// ErrInvalidTTL is returned when acquiring a lock for a ttl which is not positive.
+	ErrInvalidTTL = errors.New("invalid lock ttl")
+)
+
+// Locker acquires distributed locks.
+type Locker interface {
+	// Acquire tries to take the lock on key for ttl, failing with ErrNotAcquired if it is held by someone else
+	// and with ErrInvalidTTL if ttl is not positive.
+	Acquire(ctx context.Context, key string, ttl time.Duration, opts ...AcquireOption) (*Lock, error)
+}
+
+// AcquireOption customises the acquisition of a lock.
+type AcquireOption func(*acquireConfig)
+
+type acquireConfig struct {
+	autoRenew bool
+	wait      time.Duration
+}
+
+// WithAutoRenew extends the lock every ttl/3 until it is released, so that long running work keeps it.
+// Lost is closed if a renewal finds the lock expired or taken over.
+func WithAutoRenew() AcquireOption {
+	return func(c *acquireConfig) {
+		c.autoRenew = true
+	}
+}
+
+// WithWait waits for the lock to be free, trying again every interval, until ctx is done,
+// rather than failing straight away with ErrNotAcquired.
+func WithWait(interval time.Duration) AcquireOption {
+	return func(c *acquireConfig) {
+		c.wait = interval
+	}
+}
+
+// backend refreshes and releases a lock in the underlying store.
+type backend interface {
+	refresh(ctx context.Context, l *Lock) error
+	release(ctx context.Context, l *Lock) error
+}
+
+// Lock is a lock held on a key. Its fencing token increases at every acquisition of the key, so that the resources
+// it protects can reject writes carrying a token older than the last one seen, e.g. from a holder which paused
+// long enough for its lock to expire.
+type Lock struct {
+	key   string
+	owner string
+	token int64
+	ttl   time.Duration
+	b     backend
+	clock clock.Clock
+
+	lost     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	lostOnce sync.Once
+}
+
+// acquire calls try until it acquires the lock, or fails for any other reason than ErrNotAcquired,
+// honouring the options, and starts the auto renewal if needed, waiting and renewing with the clock in input.
+func acquire(ctx context.Context, c clock.Clock, ttl time.Duration, opts []AcquireOption, try func() (*Lock, error)) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTTL, ttl)
+	}
+	cfg := &acquireConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	for {
+		l, err := try()
+		if err == nil {
+			l.clock = c
+			l.lost = make(chan struct{})
+			l.stop = make(chan struct{})
+			l.done = make(chan struct{})
+			if cfg.autoRenew {
+				go l.renew()
+			} else {
+				close(l.done)
+			}
+			return l, nil
+		}
+		if !errors.Is(err, ErrNotAcquired) || cfg.wait <= 0 {
+			return nil, err
+		}
+		timer := c.NewTimer(cfg.wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %v", ErrNotAcquired, ctx.Err())
+		case <-timer.C():
+		}
+	}
+}
+
+// Key returns the key of the lock.
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token returns the fencing token of the lock.
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+// Lost returns a channel closed when the auto renewal finds the lock expired or taken over.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Refresh extends the lock by its ttl, failing with ErrNotHeld if it expired or was taken over.
+func (l *Lock) Refresh(ctx context.Context) error {
+	return l.b.refresh(ctx, l)
+}
+
+// Release stops the auto renewal and releases the lock, failing with ErrNotHeld if it expired or was taken over.
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+	return l.b.release(ctx, l)
+}
+
+func (l *Lock) renew() {
+	defer close(l.done)
+	interval := l.ttl / 3
+	if interval <= 0 {
+		interval = l.ttl
+	}
+	ticker := l.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C():
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := l.Refresh(ctx)
+			cancel()
+			if errors.Is(err, ErrNotHeld) {
+				l.lostOnce.Do(func() { close(l.lost) })
+				return
+			}
+		}
+	}
+}
+
+// Run acquires the lock on key with auto renewal and runs fn while holding it, releasing it afterwards,
+// e.g. to make a single instance of a service run a job. The context of fn is cancelled with ErrLost as cause
+// if the lock is lost. Run fails with ErrNotAcquired if the lock is held by someone else, unless WithWait is set.
+func Run(ctx context.Context, locker Locker, key string, ttl time.Duration, fn func(ctx context.Context, token int64) error, opts ...AcquireOption) error {
+	l, err := locker.Acquire(ctx, key, ttl, append(opts, WithAutoRenew())...)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		select {
+		case <-l.Lost():
+			cancel(ErrLost)
+		case <-ctx.Done():
+		}
+	}()
+
+	err = fn(ctx, l.Token())
+	relErr := l.Release(context.WithoutCancel(ctx))
+	if errors.Is(relErr, ErrNotHeld) {
+		relErr = nil
+	}
+	if err != nil {
+		return err
+	}
+	if relErr != nil {
+		return fmt.Errorf("could not release lock %s: %w", key, relErr)
+	}
+	return nil
+}
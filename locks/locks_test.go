@@ -0,0 +1,107 @@
+package locks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+func TestMemoryAcquire(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		wantErr error
+	}{
+		{name: "positive ttl", ttl: time.Second},
+		{name: "tiny ttl", ttl: time.Nanosecond},
+		{name: "zero ttl", ttl: 0, wantErr: ErrInvalidTTL},
+		{name: "negative ttl", ttl: -time.Second, wantErr: ErrInvalidTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewMemory(clock.NewFake(time.Now())).Acquire(ctx, "key", tt.ttl, WithAutoRenew())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Acquire() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				_ = l.Release(ctx)
+			}
+		})
+	}
+}
+
+func TestMemoryExclusionAndFencing(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Now())
+	m := NewMemory(fake)
+
+	first, err := m.Acquire(ctx, "key", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Acquire(ctx, "key", time.Minute); !errors.Is(err, ErrNotAcquired) {
+		t.Fatalf("Acquire() of a held lock: error = %v, want %v", err, ErrNotAcquired)
+	}
+
+	fake.Advance(2 * time.Minute)
+	second, err := m.Acquire(ctx, "key", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() of an expired lock: error = %v", err)
+	}
+	if second.Token() <= first.Token() {
+		t.Fatalf("got token %d after %d, want an increasing token", second.Token(), first.Token())
+	}
+	if err := first.Release(ctx); !errors.Is(err, ErrNotHeld) {
+		t.Fatalf("Release() of a taken over lock: error = %v, want %v", err, ErrNotHeld)
+	}
+	if err := second.Release(ctx); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+}
+
+func TestAutoRenewUsesClock(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Now())
+	m := NewMemory(fake)
+
+	l, err := m.Acquire(ctx, "key", 3*time.Second, WithAutoRenew())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(time.Second)
+		// wait for the renewal triggered by the tick to complete
+		waitFor(t, func() bool {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			return m.held["key"].expires.Equal(fake.Now().Add(3 * time.Second))
+		})
+	}
+	if _, err := m.Acquire(ctx, "key", time.Second); !errors.Is(err, ErrNotAcquired) {
+		t.Fatalf("Acquire() of a renewed lock: error = %v, want %v", err, ErrNotAcquired)
+	}
+	if err := l.Release(ctx); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	select {
+	case <-l.Lost():
+		t.Fatal("renewed lock reported as lost")
+	default:
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,76 @@
+package locks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/id"
+)
+
+// Memory is an in-process Locker, e.g. for tests or single instance deployments.
+type Memory struct {
+	clock clock.Clock
+
+	mu     sync.Mutex
+	held   map[string]memoryEntry
+	fences map[string]int64
+}
+
+type memoryEntry struct {
+	owner   string
+	expires time.Time
+}
+
+// compile time interface check.
+var _ Locker = &Memory{}
+
+// NewMemory returns an in-process Locker measuring the expiration of the locks with the clock in input,
+// e.g. a clock.Fake in tests, or the real one if nil.
+func NewMemory(c clock.Clock) *Memory {
+	if c == nil {
+		c = clock.New()
+	}
+	return &Memory{clock: c, held: make(map[string]memoryEntry), fences: make(map[string]int64)}
+}
+
+// Acquire tries to take the lock on key for ttl.
+func (m *Memory) Acquire(ctx context.Context, key string, ttl time.Duration, opts ...AcquireOption) (*Lock, error) {
+	return acquire(ctx, m.clock, ttl, opts, func() (*Lock, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		now := m.clock.Now()
+		if e, ok := m.held[key]; ok && now.Before(e.expires) {
+			return nil, ErrNotAcquired
+		}
+		owner := id.NewUUIDv4()
+		m.held[key] = memoryEntry{owner: owner, expires: now.Add(ttl)}
+		m.fences[key]++
+		return &Lock{key: key, owner: owner, token: m.fences[key], ttl: ttl, b: m}, nil
+	})
+}
+
+func (m *Memory) refresh(_ context.Context, l *Lock) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	e, ok := m.held[l.key]
+	if !ok || e.owner != l.owner || !now.Before(e.expires) {
+		return ErrNotHeld
+	}
+	e.expires = now.Add(l.ttl)
+	m.held[l.key] = e
+	return nil
+}
+
+func (m *Memory) release(_ context.Context, l *Lock) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.held[l.key]
+	if !ok || e.owner != l.owner || !m.clock.Now().Before(e.expires) {
+		return ErrNotHeld
+	}
+	delete(m.held, l.key)
+	return nil
+}
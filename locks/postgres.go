@@ -0,0 +1,109 @@
+package locks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+// Postgres is a Locker holding PostgreSQL session level advisory locks. Each lock keeps a connection of the pool
+// for as long as it is held: the lock is released by the database if the connection drops, which renewals detect.
+// The ttl only sets how often renewals check the connection. Fencing tokens are transaction IDs, which increase
+// across the whole cluster.
+type Postgres struct {
+	db *sql.DB
+}
+
+// compile time interface check.
+var _ Locker = &Postgres{}
+
+// NewPostgres returns a Locker holding advisory locks through the database handle in input.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// pgLock is the backend of a lock, holding its connection.
+type pgLock struct {
+	conn *sql.Conn
+	id   int64
+}
+
+// Acquire tries to take the lock on key. Keys are hashed onto the 64 bit IDs of advisory locks.
+// As with the other lockers, ttl must be positive.
+func (p *Postgres) Acquire(ctx context.Context, key string, ttl time.Duration, opts ...AcquireOption) (*Lock, error) {
+	lockID := advisoryID(key)
+	return acquire(ctx, clock.New(), ttl, opts, func() (*Lock, error) {
+		conn, err := p.db.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not acquire lock %s: %w", key, err)
+		}
+		var ok bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, lockID).Scan(&ok); err != nil {
+			// the lock may have been taken before the error, e.g. if ctx was cancelled
+			discard(conn)
+			return nil, fmt.Errorf("could not acquire lock %s: %w", key, err)
+		}
+		if !ok {
+			_ = conn.Close()
+			return nil, ErrNotAcquired
+		}
+		var token int64
+		if err := conn.QueryRowContext(ctx, `SELECT pg_current_xact_id()::text::bigint`).Scan(&token); err != nil {
+			discard(conn)
+			return nil, fmt.Errorf("could not get fencing token for lock %s: %w", key, err)
+		}
+		return &Lock{key: key, token: token, ttl: ttl, b: &pgLock{conn: conn, id: lockID}}, nil
+	})
+}
+
+// refresh checks that the connection holding the lock is still alive.
+func (b *pgLock) refresh(ctx context.Context, l *Lock) error {
+	var held bool
+	err := b.conn.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_locks WHERE locktype = 'advisory' AND objsubid = 1 AND pid = pg_backend_pid() AND granted
+		AND ((classid::bigint << 32) | objid::bigint) = $1)`, b.id).Scan(&held)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("could not refresh lock %s: %w", l.key, err)
+		}
+		return ErrNotHeld
+	}
+	if !held {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// release unlocks the lock and returns the connection to the pool.
+// If the lock cannot be unlocked, the connection is discarded instead, which releases it.
+func (b *pgLock) release(ctx context.Context, l *Lock) error {
+	var ok bool
+	if err := b.conn.QueryRowContext(ctx, `SELECT pg_advisory_unlock($1)`, b.id).Scan(&ok); err != nil {
+		discard(b.conn)
+		return fmt.Errorf("could not release lock %s: %w", l.key, err)
+	}
+	_ = b.conn.Close()
+	if !ok {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// discard closes the connection instead of returning it to the pool, so that the session level locks it may
+// still hold are released by the database.
+func discard(conn *sql.Conn) {
+	_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	_ = conn.Close()
+}
+
+// advisoryID hashes the key onto the ID of an advisory lock.
+func advisoryID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64()) // nolint:gosec
+}
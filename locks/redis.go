@@ -0,0 +1,89 @@
+package locks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/id"
+	"github.com/redis/go-redis/v9"
+)
+
+const fenceSuffix = ":fence"
+
+var (
+	// acquireScript sets the key if it does not exist, returning the incremented fencing token, or 0.
+	acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+	// refreshScript extends the expiration of the key if it still holds the owner.
+	refreshScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+	// releaseScript deletes the key if it still holds the owner.
+	releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+// Redis is a Locker holding locks on Redis keys. The fencing token of a key is stored in the key
+// suffixed with ":fence", which is never deleted. Keys and fencing keys are hashed to the same slot
+// on Redis Cluster only if the key has a hash tag, e.g. {jobs}:cleanup.
+type Redis struct {
+	client redis.Scripter
+}
+
+// compile time interface check.
+var _ Locker = &Redis{}
+
+// NewRedis returns a Locker holding locks through the client in input.
+func NewRedis(client redis.Scripter) *Redis {
+	return &Redis{client: client}
+}
+
+// Acquire tries to take the lock on key for ttl.
+func (r *Redis) Acquire(ctx context.Context, key string, ttl time.Duration, opts ...AcquireOption) (*Lock, error) {
+	return acquire(ctx, clock.New(), ttl, opts, func() (*Lock, error) {
+		owner := id.NewUUIDv4()
+		token, err := acquireScript.Run(ctx, r.client, []string{key, key + fenceSuffix}, owner, ttl.Milliseconds()).Int64()
+		if err != nil {
+			return nil, fmt.Errorf("could not acquire lock %s: %w", key, err)
+		}
+		if token == 0 {
+			return nil, ErrNotAcquired
+		}
+		return &Lock{key: key, owner: owner, token: token, ttl: ttl, b: r}, nil
+	})
+}
+
+func (r *Redis) refresh(ctx context.Context, l *Lock) error {
+	res, err := refreshScript.Run(ctx, r.client, []string{l.key}, l.owner, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return fmt.Errorf("could not refresh lock %s: %w", l.key, err)
+	}
+	if res == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+func (r *Redis) release(ctx context.Context, l *Lock) error {
+	res, err := releaseScript.Run(ctx, r.client, []string{l.key}, l.owner).Int64()
+	if err != nil {
+		return fmt.Errorf("could not release lock %s: %w", l.key, err)
+	}
+	if res == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
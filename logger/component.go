@@ -0,0 +1,70 @@
+package logger
+
+// componentLevels maps the component names to the minimum level they log. It is never modified once built.
+type componentLevels struct {
+	levels map[string]LogLevel
+}
+
+// componentLeveled is implemented by the loggers supporting component levels.
+type componentLeveled interface {
+	withComponentLevels(levels *componentLevels) Logger
+}
+
+// WithComponentLevels returns a logger whose lines, once tagged with Component, are only logged from the level
+// of their component in input, e.g. {"httpclient": WARNING} to quiet a noisy subsystem while the rest of the service
+// logs at DEBUG. Levels are resolved when the lines are logged, on top of the global level set by the constructors,
+// so that a component can only log less than the rest of the service. Components not in the map log as usual.
+func WithComponentLevels(l Logger, levels map[string]LogLevel) Logger {
+	c, ok := l.(componentLeveled)
+	if !ok {
+		return l
+	}
+	cl := &componentLevels{levels: make(map[string]LogLevel, len(levels))}
+	for name, level := range levels {
+		cl.levels[name] = level
+	}
+	return c.withComponentLevels(cl)
+}
+
+// level returns the level of the component, if overridden.
+func (cl *componentLevels) level(component string) (LogLevel, bool) {
+	if cl == nil || component == "" {
+		return 0, false
+	}
+	level, ok := cl.levels[component]
+	return level, ok
+}
+
+// Component instructs the logger to log the name of the component, whose level applies if overridden
+// with WithComponentLevels.
+func (l *FastLogger) Component(name string) Logger {
+	lcopy := *l
+	lcopy.component = name
+	lcopy.lggr = l.lggr.With().Str(componentKey.String(), name).Logger()
+	return &lcopy
+}
+
+func (l *FastLogger) withComponentLevels(levels *componentLevels) Logger {
+	lcopy := *l
+	lcopy.components = levels
+	return &lcopy
+}
+
+// enabled reports whether the lines at the level in input are logged by the component of the logger.
+func (l *FastLogger) enabled(level LogLevel) bool {
+	minLevel, ok := l.components.level(l.component)
+	return !ok || level >= minLevel
+}
+
+// Component instructs the logger to log the name of the component.
+func (l *dedupLogger) Component(name string) Logger {
+	return l.with(l.next.Component(name), componentKey, name)
+}
+
+func (l *dedupLogger) withComponentLevels(levels *componentLevels) Logger {
+	next := l.next
+	if c, ok := next.(componentLeveled); ok {
+		next = c.withComponentLevels(levels)
+	}
+	return &dedupLogger{next: next, d: l.d, fields: l.fields}
+}
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"context"
+	"sort"
+
+	"github.com/indiependente/pkg/requestid"
+	"github.com/indiependente/pkg/tenantid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey struct{}
+
+// ContextExtractor returns the value to log out of the context, an empty string to log nothing.
+type ContextExtractor func(ctx context.Context) string
+
+// extractor is a ContextExtractor along with the key its value is logged under.
+type extractor struct {
+	key LogKey
+	fn  ContextExtractor
+}
+
+// contextual is implemented by the loggers supporting context extraction.
+type contextual interface {
+	withExtractors(extractors []extractor) Logger
+	withContext(ctx context.Context) Logger
+}
+
+// DefaultContextExtractors returns the extractors of the request ID stored by the requestid package,
+// of the tenant ID stored by the tenantid package and of the IDs of the span stored in the context.
+func DefaultContextExtractors() map[LogKey]ContextExtractor {
+	return map[LogKey]ContextExtractor{
+		requestIDKey: func(ctx context.Context) string {
+			id, _ := requestid.FromContext(ctx)
+			return id
+		},
+		tenantKey: func(ctx context.Context) string {
+			id, _ := tenantid.FromContext(ctx)
+			return id
+		},
+		traceIDKey: func(ctx context.Context) string {
+			if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+				return sc.TraceID().String()
+			}
+			return ""
+		},
+		spanIDKey: func(ctx context.Context) string {
+			if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+				return sc.SpanID().String()
+			}
+			return ""
+		},
+	}
+}
+
+// WithContextExtractors returns a logger which, once obtained via FromContext, logs the values extracted
+// out of the context under their key on every levelled call, e.g. LogKey("tenant") or LogKey("user_id").
+// Extractors are added to the ones the logger already has, replacing those with the same key.
+func WithContextExtractors(l Logger, extractors map[LogKey]ContextExtractor) Logger {
+	c, ok := l.(contextual)
+	if !ok {
+		return l
+	}
+	ext := make([]extractor, 0, len(extractors))
+	for key, fn := range extractors {
+		ext = append(ext, extractor{key: key, fn: fn})
+	}
+	sort.Slice(ext, func(i, j int) bool { return ext[i].key < ext[j].key })
+	return c.withExtractors(ext)
+}
+
+// NewContext returns a copy of the context carrying the logger in input.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by the context, bound to it so that its context extractors,
+// if any, pull their values out of it. Without a logger in the context, the global zerolog logger is used.
+func FromContext(ctx context.Context) Logger {
+	l, ok := ctx.Value(contextKey{}).(Logger)
+	if !ok {
+		l = &FastLogger{lggr: log.Logger}
+	}
+	if c, ok := l.(contextual); ok {
+		return c.withContext(ctx)
+	}
+	return l
+}
+
+func (l *FastLogger) withExtractors(extractors []extractor) Logger {
+	lcopy := *l
+	merged := make([]extractor, 0, len(l.extractors)+len(extractors))
+	for _, e := range l.extractors {
+		replaced := false
+		for _, n := range extractors {
+			replaced = replaced || n.key == e.key
+		}
+		if !replaced {
+			merged = append(merged, e)
+		}
+	}
+	lcopy.extractors = append(merged, extractors...)
+	return &lcopy
+}
+
+func (l *FastLogger) withContext(ctx context.Context) Logger {
+	lcopy := *l
+	lcopy.ctx = ctx
+	return &lcopy
+}
+
+func (l *dedupLogger) withExtractors(extractors []extractor) Logger {
+	next := l.next
+	if c, ok := next.(contextual); ok {
+		next = c.withExtractors(extractors)
+	}
+	return &dedupLogger{next: next, d: l.d, fields: l.fields}
+}
+
+func (l *dedupLogger) withContext(ctx context.Context) Logger {
+	next := l.next
+	if c, ok := next.(contextual); ok {
+		next = c.withContext(ctx)
+	}
+	return &dedupLogger{next: next, d: l.d, fields: l.fields}
+}
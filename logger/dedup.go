@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithDedup returns a logger suppressing the lines identical, in level, message and fields, to one already
+// logged within window, preventing error storms from flooding the sink. Once the window of a line ends,
+// the number of copies suppressed, if any, is logged in a summary line carrying the same message and fields
+// along with a suppressed_count. Panic and fatal lines are never suppressed.
+// Loggers chained from the returned one share the same window.
+func WithDedup(l Logger, window time.Duration) Logger {
+	return &dedupLogger{
+		next: l,
+		d: &deduper{
+			window: window,
+			seen:   make(map[string]*dedupEntry),
+		},
+	}
+}
+
+// deduper tracks the lines logged within their window.
+type deduper struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	suppressed int
+}
+
+// allow reports whether the line identified by key must be logged, counting it as suppressed otherwise.
+// The first time a line is allowed, summary is scheduled to run at the end of its window
+// with the number of copies suppressed, if any.
+func (d *deduper) allow(key string, summary func(suppressed int)) bool {
+	d.mu.Lock()
+	if e, ok := d.seen[key]; ok {
+		e.suppressed++
+		d.mu.Unlock()
+		return false
+	}
+	e := &dedupEntry{}
+	d.seen[key] = e
+	d.mu.Unlock()
+
+	time.AfterFunc(d.window, func() {
+		d.mu.Lock()
+		delete(d.seen, key)
+		n := e.suppressed
+		d.mu.Unlock()
+		if n > 0 {
+			summary(n)
+		}
+	})
+	return true
+}
+
+// dedupLogger is a Logger identifying its lines by the fields it has been instructed to log.
+type dedupLogger struct {
+	next   Logger
+	d      *deduper
+	fields string
+}
+
+// compile time interface check.
+var _ Logger = &dedupLogger{}
+
+func (l *dedupLogger) with(next Logger, key LogKey, value string) Logger {
+	return &dedupLogger{
+		next:   next,
+		d:      l.d,
+		fields: l.fields + "\x00" + key.String() + "=" + value,
+	}
+}
+
+// Body instructs the logger to log the body.
+func (l *dedupLogger) Body(b string) Logger {
+	return l.with(l.next.Body(b), bodyKey, b)
+}
+
+// BytesWritten instructs the logger to log the bytes written.
+func (l *dedupLogger) BytesWritten(bw int) Logger {
+	return l.with(l.next.BytesWritten(bw), bytesWrittenKey, strconv.Itoa(bw))
+}
+
+// Duration instructs the logger to log the duration.
+func (l *dedupLogger) Duration(d time.Duration) Logger {
+	return l.with(l.next.Duration(d), durationKey, d.String())
+}
+
+// Err instructs the logger to log the error.
+func (l *dedupLogger) Err(err error) Logger {
+	return l.with(l.next.Err(err), errorKey, errString(err))
+}
+
+// Headers instructs the logger to log the headers.
+func (l *dedupLogger) Headers(h http.Header) Logger {
+	return l.with(l.next.Headers(h), headersKey, fmt.Sprint(h))
+}
+
+// Host instructs the logger to log the host.
+func (l *dedupLogger) Host(h string) Logger {
+	return l.with(l.next.Host(h), hostKey, h)
+}
+
+// Method instructs the logger to log the method.
+func (l *dedupLogger) Method(m string) Logger {
+	return l.with(l.next.Method(m), methodKey, m)
+}
+
+// Event instructs the logger to log the event.
+func (l *dedupLogger) Event(e string) Logger {
+	return l.with(l.next.Event(e), eventKey, e)
+}
+
+// Query instructs the logger to log the database query.
+func (l *dedupLogger) Query(q string) Logger {
+	return l.with(l.next.Query(q), queryKey, q)
+}
+
+// RequestID instructs the logger to log the request ID.
+func (l *dedupLogger) RequestID(id string) Logger {
+	return l.with(l.next.RequestID(id), requestIDKey, id)
+}
+
+// RemoteAddr instructs the logger to log the remote address.
+func (l *dedupLogger) RemoteAddr(addr string) Logger {
+	return l.with(l.next.RemoteAddr(addr), remoteAddrKey, addr)
+}
+
+// StatusCode instructs the logger to log the status code.
+func (l *dedupLogger) StatusCode(sc int) Logger {
+	return l.with(l.next.StatusCode(sc), statusCodeKey, strconv.Itoa(sc))
+}
+
+// SuppressedCount instructs the logger to log the number of identical lines suppressed.
+func (l *dedupLogger) SuppressedCount(n int) Logger {
+	return l.with(l.next.SuppressedCount(n), suppressedKey, strconv.Itoa(n))
+}
+
+// Signal instructs the logger to log the signal.
+func (l *dedupLogger) Signal(sig fmt.Stringer) Logger {
+	return l.with(l.next.Signal(sig), signalKey, sig.String())
+}
+
+// SpanID instructs the logger to log the span ID.
+func (l *dedupLogger) SpanID(id string) Logger {
+	return l.with(l.next.SpanID(id), spanIDKey, id)
+}
+
+// Stack instructs the logger to log the stack trace.
+func (l *dedupLogger) Stack(st string) Logger {
+	return l.with(l.next.Stack(st), stackKey, st)
+}
+
+// Tenant instructs the logger to log the tenant ID.
+func (l *dedupLogger) Tenant(id string) Logger {
+	return l.with(l.next.Tenant(id), tenantKey, id)
+}
+
+// Topic instructs the logger to log the messaging topic.
+func (l *dedupLogger) Topic(t string) Logger {
+	return l.with(l.next.Topic(t), topicKey, t)
+}
+
+// TraceID instructs the logger to log the trace ID.
+func (l *dedupLogger) TraceID(id string) Logger {
+	return l.with(l.next.TraceID(id), traceIDKey, id)
+}
+
+// URI instructs the logger to log the URI.
+func (l *dedupLogger) URI(uri string) Logger {
+	return l.with(l.next.URI(uri), uriKey, uri)
+}
+
+// UserAgent instructs the logger to log the user agent.
+func (l *dedupLogger) UserAgent(ua string) Logger {
+	return l.with(l.next.UserAgent(ua), userAgentKey, ua)
+}
+
+// UserAgentFamily instructs the logger to log the family of the user agent, e.g. the browser or bot name.
+func (l *dedupLogger) UserAgentFamily(f string) Logger {
+	return l.with(l.next.UserAgentFamily(f), uaFamilyKey, f)
+}
+
+// IsBot instructs the logger to log whether the client is a bot.
+func (l *dedupLogger) IsBot(bot bool) Logger {
+	return l.with(l.next.IsBot(bot), isBotKey, strconv.FormatBool(bot))
+}
+
+// Version instructs the logger to log the version of the service.
+func (l *dedupLogger) Version(v string) Logger {
+	return l.with(l.next.Version(v), versionKey, v)
+}
+
+// Panic logs the message at panic level, never suppressing it.
+func (l *dedupLogger) Panic(msg string) {
+	withCaller(l.next, getFrame(1).Function).Panic(msg)
+}
+
+// Fatal logs the message and the error at fatal level, never suppressing them.
+func (l *dedupLogger) Fatal(msg string, err error) {
+	withCaller(l.next, getFrame(1).Function).Fatal(msg, err)
+}
+
+// Error logs the message and the error at error level, unless an identical line was logged within the window.
+func (l *dedupLogger) Error(msg string, err error) {
+	next := withCaller(l.next, getFrame(1).Function)
+	if l.d.allow(l.key("error", msg+"\x00"+errString(err)), func(n int) { next.SuppressedCount(n).Error(msg, err) }) {
+		next.Error(msg, err)
+	}
+}
+
+// Warn logs the message at warning level, unless an identical line was logged within the window.
+func (l *dedupLogger) Warn(msg string) {
+	next := withCaller(l.next, getFrame(1).Function)
+	if l.d.allow(l.key("warn", msg), func(n int) { next.SuppressedCount(n).Warn(msg) }) {
+		next.Warn(msg)
+	}
+}
+
+// Info logs the message at info level, unless an identical line was logged within the window.
+func (l *dedupLogger) Info(msg string) {
+	next := withCaller(l.next, getFrame(1).Function)
+	if l.d.allow(l.key("info", msg), func(n int) { next.SuppressedCount(n).Info(msg) }) {
+		next.Info(msg)
+	}
+}
+
+// Debug logs the message at debug level, unless an identical line was logged within the window.
+func (l *dedupLogger) Debug(msg string) {
+	next := withCaller(l.next, getFrame(1).Function)
+	if l.d.allow(l.key("debug", msg), func(n int) { next.SuppressedCount(n).Debug(msg) }) {
+		next.Debug(msg)
+	}
+}
+
+func (l *dedupLogger) key(level, msg string) string {
+	return level + "\x00" + msg + l.fields
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// SetLevel changes the minimum level logged by every logger at runtime.
+func SetLevel(level LogLevel) {
+	switch level {
+	case DEBUG:
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	case INFO:
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	case WARNING:
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	case ERROR:
+		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	case FATAL:
+		zerolog.SetGlobalLevel(zerolog.FatalLevel)
+	case PANIC:
+		zerolog.SetGlobalLevel(zerolog.PanicLevel)
+	case DISABLED:
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+}
+
+// Level returns the minimum level currently logged.
+func Level() LogLevel {
+	switch zerolog.GlobalLevel() {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return DEBUG
+	case zerolog.InfoLevel:
+		return INFO
+	case zerolog.WarnLevel:
+		return WARNING
+	case zerolog.ErrorLevel:
+		return ERROR
+	case zerolog.FatalLevel:
+		return FATAL
+	case zerolog.PanicLevel:
+		return PANIC
+	}
+	return DISABLED
+}
+
+type levelPayload struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler returns an http.Handler reporting the current level on GET as {"level":"INFO"}
+// and changing it on PUT or POST, reading the level from the same JSON body or the level query parameter.
+// It must only be served on an internal listener.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			name := r.URL.Query().Get("level")
+			if name == "" {
+				var p levelPayload
+				if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&p); err != nil {
+					http.Error(w, "invalid body", http.StatusBadRequest)
+					return
+				}
+				name = p.Level
+			}
+			level := ParseLogLevel(name)
+			if level.String() != strings.ToUpper(name) {
+				http.Error(w, "unknown level "+name, http.StatusBadRequest)
+				return
+			}
+			SetLevel(level)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelPayload{Level: Level().String()})
+	})
+}
@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"runtime"
 	"strings"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // LogLevel represents the logging level.
@@ -31,6 +34,33 @@ const (
 	DISABLED
 )
 
+// String returns the name of the log level.
+func (ll LogLevel) String() string {
+	switch ll {
+	case DEBUG:
+		return "DEBUG"
+	case INFO:
+		return "INFO"
+	case WARNING:
+		return "WARNING"
+	case ERROR:
+		return "ERROR"
+	case FATAL:
+		return "FATAL"
+	case PANIC:
+		return "PANIC"
+	case DISABLED:
+		return "DISABLED"
+	}
+	return "UNKNOWN"
+}
+
+// UnmarshalText parses the log level name, so it can be used in configuration files.
+func (ll *LogLevel) UnmarshalText(text []byte) error {
+	*ll = ParseLogLevel(string(text))
+	return nil
+}
+
 // LogKey is the type each key that appears in the log should be.
 type LogKey string
 
@@ -40,36 +70,65 @@ func (lk LogKey) String() string {
 }
 
 const (
+	bodyKey         LogKey = "body"
 	bytesWrittenKey LogKey = "bytes_written"
 	callerKey       LogKey = "caller"
+	componentKey    LogKey = "component"
 	durationKey     LogKey = "duration"
+	errorKey        LogKey = "error"
+	errorCodeKey    LogKey = "error_code"
 	eventKey        LogKey = "event"
+	headersKey      LogKey = "headers"
 	hostKey         LogKey = "host"
+	isBotKey        LogKey = "is_bot"
 	methodKey       LogKey = "method"
+	queryKey        LogKey = "query"
 	remoteAddrKey   LogKey = "remote_addr"
 	requestIDKey    LogKey = "request_id"
 	serviceKey      LogKey = "service"
 	signalKey       LogKey = "signal"
+	spanIDKey       LogKey = "span_id"
+	stackKey        LogKey = "stack"
 	statusCodeKey   LogKey = "status_code"
+	suppressedKey   LogKey = "suppressed_count"
+	tenantKey       LogKey = "tenant"
+	topicKey        LogKey = "topic"
+	traceIDKey      LogKey = "trace_id"
 	uriKey          LogKey = "uri"
 	userAgentKey    LogKey = "user_agent"
+	uaFamilyKey     LogKey = "user_agent_family"
+	versionKey      LogKey = "version"
 )
 
 // Logger defines the behavior of the logger.
 // Exposes a function for each loggable field which maps to a LogKey.
 // The functions invocations can be chained and terminated by one of the levelled function calls (Fatal, Error, Warn, Info).
 type Logger interface {
+	Body(string) Logger
 	BytesWritten(int) Logger
+	Component(string) Logger
 	Duration(time.Duration) Logger
+	Err(error) Logger
+	Headers(http.Header) Logger
 	Host(string) Logger
+	IsBot(bool) Logger
 	Method(string) Logger
 	Event(string) Logger
+	Query(string) Logger
 	RequestID(string) Logger
 	RemoteAddr(string) Logger
 	StatusCode(int) Logger
+	SuppressedCount(int) Logger
 	Signal(fmt.Stringer) Logger
+	SpanID(string) Logger
+	Stack(string) Logger
+	Tenant(string) Logger
+	Topic(string) Logger
+	TraceID(string) Logger
 	URI(string) Logger
 	UserAgent(string) Logger
+	UserAgentFamily(string) Logger
+	Version(string) Logger
 
 	// These are the last functions that should be called on a log chain.
 	// These will execute and log all the information
@@ -86,7 +145,19 @@ var _ Logger = &FastLogger{}
 
 // FastLogger implements the LogChainer interface and relies on http://github.com/rs/zerolog.
 type FastLogger struct {
-	lggr zerolog.Logger
+	lggr       zerolog.Logger
+	caller     string
+	extractors []extractor
+	ctx        context.Context
+	component  string
+	components *componentLevels
+}
+
+// Body instructs the logger to log the body.
+func (l *FastLogger) Body(b string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(bodyKey.String(), b).Logger()
+	return &lcopy
 }
 
 // BytesWritten instructs the logger to log the bytes written.
@@ -103,6 +174,28 @@ func (l *FastLogger) Duration(d time.Duration) Logger {
 	return &lcopy
 }
 
+// Err instructs the logger to log the error, along with its code and metadata
+// when it carries them, as the errors of the errors package do.
+func (l *FastLogger) Err(err error) Logger {
+	lcopy := *l
+	ctx := l.lggr.With().AnErr(errorKey.String(), err)
+	if coded, ok := err.(interface{ ErrorCode() string }); ok {
+		ctx = ctx.Str(errorCodeKey.String(), coded.ErrorCode())
+	}
+	if withFields, ok := err.(interface{ Fields() map[string]interface{} }); ok {
+		ctx = ctx.Fields(withFields.Fields())
+	}
+	lcopy.lggr = ctx.Logger()
+	return &lcopy
+}
+
+// Headers instructs the logger to log the headers.
+func (l *FastLogger) Headers(h http.Header) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Interface(headersKey.String(), h).Logger()
+	return &lcopy
+}
+
 // Host instructs the logger to log the host.
 func (l *FastLogger) Host(h string) Logger {
 	lcopy := *l
@@ -110,6 +203,27 @@ func (l *FastLogger) Host(h string) Logger {
 	return &lcopy
 }
 
+// Query instructs the logger to log the database query.
+func (l *FastLogger) Query(q string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(queryKey.String(), q).Logger()
+	return &lcopy
+}
+
+// Tenant instructs the logger to log the tenant ID.
+func (l *FastLogger) Tenant(id string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(tenantKey.String(), id).Logger()
+	return &lcopy
+}
+
+// Topic instructs the logger to log the messaging topic.
+func (l *FastLogger) Topic(t string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(topicKey.String(), t).Logger()
+	return &lcopy
+}
+
 // UserAgent instructs the logger to log the user agent.
 func (l *FastLogger) UserAgent(ua string) Logger {
 	lcopy := *l
@@ -117,6 +231,20 @@ func (l *FastLogger) UserAgent(ua string) Logger {
 	return &lcopy
 }
 
+// UserAgentFamily instructs the logger to log the family of the user agent, e.g. the browser or bot name.
+func (l *FastLogger) UserAgentFamily(f string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(uaFamilyKey.String(), f).Logger()
+	return &lcopy
+}
+
+// IsBot instructs the logger to log whether the client is a bot.
+func (l *FastLogger) IsBot(bot bool) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Bool(isBotKey.String(), bot).Logger()
+	return &lcopy
+}
+
 // Method instructs the logger to log the method.
 func (l *FastLogger) Method(m string) Logger {
 	lcopy := *l
@@ -152,6 +280,13 @@ func (l *FastLogger) StatusCode(sc int) Logger {
 	return &lcopy
 }
 
+// SuppressedCount instructs the logger to log the number of identical lines suppressed.
+func (l *FastLogger) SuppressedCount(n int) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Int(suppressedKey.String(), n).Logger()
+	return &lcopy
+}
+
 // Signal instructs the logger to log the signal.
 func (l *FastLogger) Signal(sig fmt.Stringer) Logger {
 	lcopy := *l
@@ -159,6 +294,27 @@ func (l *FastLogger) Signal(sig fmt.Stringer) Logger {
 	return &lcopy
 }
 
+// SpanID instructs the logger to log the span ID.
+func (l *FastLogger) SpanID(id string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(spanIDKey.String(), id).Logger()
+	return &lcopy
+}
+
+// Stack instructs the logger to log the stack trace.
+func (l *FastLogger) Stack(st string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(stackKey.String(), st).Logger()
+	return &lcopy
+}
+
+// TraceID instructs the logger to log the trace ID.
+func (l *FastLogger) TraceID(id string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(traceIDKey.String(), id).Logger()
+	return &lcopy
+}
+
 // URI instructs the logger to log the URI.
 func (l *FastLogger) URI(uri string) Logger {
 	lcopy := *l
@@ -166,49 +322,108 @@ func (l *FastLogger) URI(uri string) Logger {
 	return &lcopy
 }
 
+// Version instructs the logger to log the version of the service.
+func (l *FastLogger) Version(v string) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.With().Str(versionKey.String(), v).Logger()
+	return &lcopy
+}
+
 // Panic logs the message at panic level.
 // It stops the ordinary flow of a goroutine.
 // The log payload will contain everything else the logger has been instructed to log.
 func (l *FastLogger) Panic(msg string) {
-	l.lggr.Panic().Str(callerKey.String(), getCallerFunctionName()).Msg(msg)
+	l.extracted().Panic().Str(callerKey.String(), l.callerFunctionName()).Msg(msg)
 }
 
 // Fatal logs the message and the error at fatal level.
 // It after exits with os.Exit(1).
 // The log payload will contain everything else the logger has been instructed to log.
 func (l *FastLogger) Fatal(msg string, err error) {
-	l.lggr.Fatal().AnErr("error", err).Str(callerKey.String(), getCallerFunctionName()).Msg(msg)
+	l.extracted().Fatal().AnErr("error", err).Str(callerKey.String(), l.callerFunctionName()).Msg(msg)
 }
 
 // Error logs the message and the error at error level.
 // The log payload will contain everything else the logger has been instructed to log.
 func (l *FastLogger) Error(msg string, err error) {
-	l.lggr.Error().AnErr("error", err).Str(callerKey.String(), getCallerFunctionName()).Msg(msg)
+	if !l.enabled(ERROR) {
+		return
+	}
+	l.extracted().Error().AnErr("error", err).Str(callerKey.String(), l.callerFunctionName()).Msg(msg)
 }
 
 // Warn logs the message at warning level.
 // The log payload will contain everything else the logger has been instructed to log.
 func (l *FastLogger) Warn(msg string) {
-	l.lggr.Warn().Str(callerKey.String(), getCallerFunctionName()).Msg(msg)
+	if !l.enabled(WARNING) {
+		return
+	}
+	l.extracted().Warn().Str(callerKey.String(), l.callerFunctionName()).Msg(msg)
 }
 
 // Info logs the message at info level.
 // The log payload will contain everything else the logger has been instructed to log.
 func (l *FastLogger) Info(msg string) {
-	l.lggr.Info().Str(callerKey.String(), getCallerFunctionName()).Msg(msg)
+	if !l.enabled(INFO) {
+		return
+	}
+	l.extracted().Info().Str(callerKey.String(), l.callerFunctionName()).Msg(msg)
 }
 
 // Debug logs the message at debug level.
 // The log payload will contain everything else the logger has been instructed to log.
 func (l *FastLogger) Debug(msg string) {
-	l.lggr.Debug().Str(callerKey.String(), getCallerFunctionName()).Msg(msg)
+	if !l.enabled(DEBUG) {
+		return
+	}
+	l.extracted().Debug().Str(callerKey.String(), l.callerFunctionName()).Msg(msg)
+}
+
+// WithTrace instructs the logger to log the trace and span IDs of the span stored in the context, if any.
+func WithTrace(ctx context.Context, l Logger) Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return l
+	}
+	return l.TraceID(sc.TraceID().String()).SpanID(sc.SpanID().String())
 }
 
-func getCallerFunctionName() string {
-	// Skip GetCallerFunctionName and the function to get the caller of
+// extracted returns the underlying logger along with the values extracted out of the bound context, if any.
+func (l *FastLogger) extracted() *zerolog.Logger {
+	if l.ctx == nil || len(l.extractors) == 0 {
+		return &l.lggr
+	}
+	zctx := l.lggr.With()
+	for _, e := range l.extractors {
+		if v := e.fn(l.ctx); v != "" {
+			zctx = zctx.Str(e.key.String(), v)
+		}
+	}
+	lggr := zctx.Logger()
+	return &lggr
+}
+
+// callerFunctionName returns the caller set by withCaller, if any, or the function calling the levelled method.
+func (l *FastLogger) callerFunctionName() string {
+	if l.caller != "" {
+		return l.caller
+	}
+	// Skip callerFunctionName and the function to get the caller of
 	return getFrame(2).Function
 }
 
+// withCaller returns a copy of the logger reporting the caller in input instead of the function calling
+// the levelled method, for the loggers wrapping it.
+func withCaller(l Logger, caller string) Logger {
+	fl, ok := l.(*FastLogger)
+	if !ok {
+		return l
+	}
+	lcopy := *fl
+	lcopy.caller = caller
+	return &lcopy
+}
+
 func getFrame(skipFrames int) runtime.Frame {
 	// We need the frame at index skipFrames+2, since we never want runtime.Callers and getFrame
 	targetFrameIndex := skipFrames + 2
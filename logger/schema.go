@@ -0,0 +1,268 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// schemaViolationEvent is the event of the warnings logged on violations, never validated to avoid loops.
+const schemaViolationEvent = "log_schema_violation"
+
+// FieldType is the JSON type of a field of a log line.
+type FieldType int
+
+// Types of the fields of a log line.
+const (
+	TypeAny FieldType = iota
+	TypeString
+	TypeNumber
+	TypeBool
+	TypeObject
+	TypeArray
+)
+
+// String returns the name of the type.
+func (t FieldType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeNumber:
+		return "number"
+	case TypeBool:
+		return "bool"
+	case TypeObject:
+		return "object"
+	case TypeArray:
+		return "array"
+	default:
+		return "any"
+	}
+}
+
+// matches reports whether the decoded JSON value is of the type.
+func (t FieldType) matches(v interface{}) bool {
+	switch v.(type) {
+	case string:
+		return t == TypeAny || t == TypeString
+	case float64:
+		return t == TypeAny || t == TypeNumber
+	case bool:
+		return t == TypeAny || t == TypeBool
+	case map[string]interface{}:
+		return t == TypeAny || t == TypeObject
+	case []interface{}:
+		return t == TypeAny || t == TypeArray
+	default:
+		return t == TypeAny
+	}
+}
+
+// Field describes a field of the log lines of an event.
+type Field struct {
+	Key      string
+	Type     FieldType
+	Required bool
+}
+
+// Required returns a field which must be logged with the type in input.
+func Required(key string, t FieldType) Field {
+	return Field{Key: key, Type: t, Required: true}
+}
+
+// Optional returns a field which, when logged, must have the type in input.
+func Optional(key string, t FieldType) Field {
+	return Field{Key: key, Type: t}
+}
+
+// Schema is the contract of the log lines, by event, that dashboards and alerts depend on.
+// It is meant to be registered once and not modified while lines are validated.
+type Schema struct {
+	common []Field
+	events map[string][]Field
+	strict bool
+}
+
+// NewSchema returns an empty Schema.
+func NewSchema() *Schema {
+	return &Schema{events: make(map[string][]Field)}
+}
+
+// Common adds fields to the contract of every line, e.g. the service and the level.
+func (s *Schema) Common(fields ...Field) *Schema {
+	s.common = append(s.common, fields...)
+	return s
+}
+
+// Event adds fields to the contract of the lines of the event in input.
+func (s *Schema) Event(name string, fields ...Field) *Schema {
+	s.events[name] = append(s.events[name], fields...)
+	return s
+}
+
+// Strict rejects the lines whose event is not registered with Event, including the lines without an event.
+func (s *Schema) Strict() *Schema {
+	s.strict = true
+	return s
+}
+
+// Validate checks the JSON log line in input against the contract, returning the violations found, if any.
+func (s *Schema) Validate(line []byte) *Violation {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return &Violation{Line: string(line), Problems: []string{fmt.Sprintf("not a JSON object: %v", err)}}
+	}
+	event, _ := fields[eventKey.String()].(string)
+	if event == schemaViolationEvent {
+		return nil
+	}
+	var problems []string
+	check := func(f Field) {
+		v, ok := fields[f.Key]
+		switch {
+		case !ok && f.Required:
+			problems = append(problems, fmt.Sprintf("missing required field %q", f.Key))
+		case ok && !f.Type.matches(v):
+			problems = append(problems, fmt.Sprintf("field %q is not a %s", f.Key, f.Type))
+		}
+	}
+	for _, f := range s.common {
+		check(f)
+	}
+	eventFields, registered := s.events[event]
+	if !registered && s.strict {
+		problems = append(problems, fmt.Sprintf("event %q is not registered", event))
+	}
+	for _, f := range eventFields {
+		check(f)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &Violation{Event: event, Line: strings.TrimSpace(string(line)), Problems: problems}
+}
+
+// Violation describes a log line breaking the contract of its event.
+type Violation struct {
+	Event    string
+	Line     string
+	Problems []string
+}
+
+// Error returns the problems of the line.
+func (v *Violation) Error() string {
+	return fmt.Sprintf("log line of event %q breaks the schema: %s: %s", v.Event, strings.Join(v.Problems, "; "), v.Line)
+}
+
+// TestingT is the subset of testing.TB used to fail the tests logging lines which break the schema.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// SchemaOption customises the validation of the log lines.
+type SchemaOption func(*schemaConfig)
+
+type schemaConfig struct {
+	out         io.Writer
+	onViolation func(*Violation)
+}
+
+// WithSchemaOutput writes the validated lines to w instead of standard error.
+func WithSchemaOutput(w io.Writer) SchemaOption {
+	return func(c *schemaConfig) {
+		c.out = w
+	}
+}
+
+// FailTest fails the test in input on every violation, e.g. in the tests of the handlers of a service.
+func FailTest(t TestingT) SchemaOption {
+	return func(c *schemaConfig) {
+		c.onViolation = func(v *Violation) {
+			t.Helper()
+			t.Errorf("%v", v)
+		}
+	}
+}
+
+// OnViolation calls fn on every violation instead of logging a warning.
+func OnViolation(fn func(*Violation)) SchemaOption {
+	return func(c *schemaConfig) {
+		c.onViolation = fn
+	}
+}
+
+// schemaValidated is implemented by the loggers supporting schema validation.
+type schemaValidated interface {
+	withSchemaWriter(w *schemaWriter) Logger
+}
+
+// WithSchema returns a logger validating every line it emits against the schema in input, meant for development
+// and tests as every line is decoded. Lines are written as usual, followed by a warning with the
+// log_schema_violation event when they break the schema, unless FailTest or OnViolation handle the violations.
+// Lines are written to standard error, as by the default logger, unless WithSchemaOutput is used;
+// they must be JSON, so console loggers are not supported.
+func WithSchema(l Logger, s *Schema, opts ...SchemaOption) Logger {
+	v, ok := l.(schemaValidated)
+	if !ok {
+		return l
+	}
+	cfg := &schemaConfig{out: os.Stderr}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return v.withSchemaWriter(&schemaWriter{schema: s, out: cfg.out, onViolation: cfg.onViolation})
+}
+
+// schemaWriter validates the lines before writing them to out.
+type schemaWriter struct {
+	schema      *Schema
+	out         io.Writer
+	onViolation func(*Violation)
+
+	mu sync.Mutex
+}
+
+func (w *schemaWriter) Write(p []byte) (int, error) {
+	violation := w.schema.Validate(p)
+	w.mu.Lock()
+	n, err := w.out.Write(p)
+	if violation != nil && w.onViolation == nil {
+		_, _ = w.out.Write(violationLine(violation))
+	}
+	w.mu.Unlock()
+	if violation != nil && w.onViolation != nil {
+		w.onViolation(violation)
+	}
+	return n, err
+}
+
+// violationLine returns the JSON line of the warning reporting the violation.
+func violationLine(v *Violation) []byte {
+	b, _ := json.Marshal(map[string]interface{}{
+		"level":           "warn",
+		eventKey.String(): schemaViolationEvent,
+		"violated_event":  v.Event,
+		"problems":        v.Problems,
+		"line":            v.Line,
+		"message":         "Log line breaks the schema",
+	})
+	return append(b, '\n')
+}
+
+func (l *FastLogger) withSchemaWriter(w *schemaWriter) Logger {
+	lcopy := *l
+	lcopy.lggr = l.lggr.Output(w)
+	return &lcopy
+}
+
+func (l *dedupLogger) withSchemaWriter(w *schemaWriter) Logger {
+	next := l.next
+	if v, ok := next.(schemaValidated); ok {
+		next = v.withSchemaWriter(w)
+	}
+	return &dedupLogger{next: next, d: l.d, fields: l.fields}
+}
@@ -0,0 +1,179 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/retry"
+)
+
+// mailEvent is the event of the log lines written by the Sender.
+const mailEvent = "mail"
+
+// ErrNoRecipients is returned when sending a message without recipients.
+var ErrNoRecipients = errors.New("message has no recipients")
+
+// Attachment is a file attached to a message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	// ContentID, if set, makes the attachment inline, referenced by the HTML body as cid:<ContentID>.
+	ContentID string
+}
+
+// Message is an email message. At least one of Text and HTML should be set;
+// when both are, clients display the one they prefer.
+type Message struct {
+	From        string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	ReplyTo     string
+	Subject     string
+	Text        string
+	HTML        string
+	Headers     map[string]string
+	Attachments []Attachment
+}
+
+// Recipients returns the addresses of the To, Cc and Bcc recipients.
+func (m *Message) Recipients() ([]string, error) {
+	var rcpts []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, a := range list {
+			addr, err := mail.ParseAddress(a)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse address %q: %w", a, err)
+			}
+			rcpts = append(rcpts, addr.Address)
+		}
+	}
+	if len(rcpts) == 0 {
+		return nil, ErrNoRecipients
+	}
+	return rcpts, nil
+}
+
+// Driver delivers messages, e.g. over SMTP or through the API of a provider such as SES or SendGrid.
+// Drivers should wrap with retry.Transient the errors worth retrying.
+type Driver interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// DriverFunc is an adapter to allow the use of ordinary functions as Driver.
+type DriverFunc func(ctx context.Context, msg *Message) error
+
+// Send calls f(ctx, msg).
+func (f DriverFunc) Send(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// Option customises a Sender.
+type Option func(*Sender)
+
+// WithFrom sends the messages without a sender from the address in input.
+func WithFrom(from string) Option {
+	return func(s *Sender) {
+		s.from = from
+	}
+}
+
+// WithLogger logs every message sent or failed.
+func WithLogger(log logger.Logger) Option {
+	return func(s *Sender) {
+		s.log = log
+	}
+}
+
+// WithRetry retries transient failures with the options in input. By default transient failures are retried
+// 3 times with exponential backoff; network errors, SMTP 4xx replies and errors wrapped with retry.Transient
+// are transient.
+func WithRetry(opts ...retry.Option) Option {
+	return func(s *Sender) {
+		s.retry = opts
+	}
+}
+
+// WithTemplates renders the messages sent with SendTemplate with the templates in input.
+func WithTemplates(t *Templates) Option {
+	return func(s *Sender) {
+		s.templates = t
+	}
+}
+
+// Sender sends messages through a Driver, retrying transient failures.
+type Sender struct {
+	driver    Driver
+	from      string
+	log       logger.Logger
+	retry     []retry.Option
+	templates *Templates
+}
+
+// NewSender returns a Sender delivering the messages with the driver in input.
+func NewSender(driver Driver, opts ...Option) *Sender {
+	s := &Sender{driver: driver}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Send sends the message, retrying transient failures.
+func (s *Sender) Send(ctx context.Context, msg *Message) error {
+	if msg.From == "" {
+		msg.From = s.from
+	}
+	if _, err := msg.Recipients(); err != nil {
+		return err
+	}
+	start := time.Now()
+	opts := append([]retry.Option{retry.WithRetryIf(IsTransient)}, s.retry...)
+	if s.log != nil {
+		opts = append(opts, retry.WithLogger(s.log))
+	}
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		return s.driver.Send(ctx, msg)
+	}, opts...)
+	if s.log != nil {
+		l := logger.WithTrace(ctx, s.log.Event(mailEvent).Duration(time.Since(start)))
+		if err != nil {
+			l.Error("Could not send mail", err)
+		} else {
+			l.Info("Mail sent")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not send mail: %w", err)
+	}
+	return nil
+}
+
+// SendTemplate renders the subject and the bodies of the message with the template called name
+// and the data in input, then sends it.
+func (s *Sender) SendTemplate(ctx context.Context, msg *Message, name string, data interface{}) error {
+	if s.templates == nil {
+		return fmt.Errorf("could not render template %s: no templates", name)
+	}
+	if err := s.templates.Render(msg, name, data); err != nil {
+		return err
+	}
+	return s.Send(ctx, msg)
+}
+
+// IsTransient reports whether sending may succeed if retried: network errors and SMTP 4xx replies are transient.
+func IsTransient(err error) bool {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 400 && tpErr.Code < 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,73 @@
+package mailtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/indiependente/pkg/mail"
+)
+
+// Mock is a mail.Driver recording the messages sent, for tests. The zero value is ready to use.
+type Mock struct {
+	mu   sync.Mutex
+	sent []mail.Message
+	errs []error
+}
+
+// compile time interface check.
+var _ mail.Driver = &Mock{}
+
+// NewMock returns a Mock failing the first sends with the errors in input, in order, e.g. to exercise retries.
+func NewMock(errs ...error) *Mock {
+	return &Mock{errs: errs}
+}
+
+// Send records the message, unless an error is queued, in which case it is returned instead.
+func (m *Mock) Send(ctx context.Context, msg *mail.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	m.sent = append(m.sent, *msg)
+	return nil
+}
+
+// FailNext makes the next sends fail with the errors in input, in order.
+func (m *Mock) FailNext(errs ...error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs = append(m.errs, errs...)
+}
+
+// Sent returns a copy of the messages sent so far.
+func (m *Mock) Sent() []mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mail.Message(nil), m.sent...)
+}
+
+// Last returns the last message sent, and false if none was.
+func (m *Mock) Last() (mail.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		return mail.Message{}, false
+	}
+	return m.sent[len(m.sent)-1], true
+}
+
+// Reset forgets the messages sent and the queued errors.
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = nil
+	m.errs = nil
+}
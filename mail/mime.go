@@ -0,0 +1,215 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/id"
+)
+
+// base64LineLen is the maximum length of the lines of base64 encoded attachments, as per RFC 2045.
+const base64LineLen = 76
+
+// part is a MIME entity: its header, e.g. Content-Type, and its encoded body.
+type part struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// Bytes encodes the message as RFC 5322 MIME message, ready to be sent over SMTP or through raw sending APIs.
+// Bcc recipients are omitted from the headers.
+func (m *Message) Bytes() ([]byte, error) {
+	h := make(textproto.MIMEHeader)
+	for k, v := range m.Headers {
+		h.Set(k, v)
+	}
+	addresses := []struct {
+		key  string
+		list []string
+	}{
+		{"From", []string{m.From}},
+		{"Reply-To", []string{m.ReplyTo}},
+		{"To", m.To},
+		{"Cc", m.Cc},
+	}
+	for _, a := range addresses {
+		if len(a.list) == 0 || a.list[0] == "" {
+			continue
+		}
+		v, err := formatAddresses(a.list...)
+		if err != nil {
+			return nil, err
+		}
+		h.Set(a.key, v)
+	}
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	if h.Get("Date") == "" {
+		h.Set("Date", time.Now().Format(time.RFC1123Z))
+	}
+	if h.Get("Message-Id") == "" {
+		h.Set("Message-Id", messageID(m.From))
+	}
+	h.Set("MIME-Version", "1.0")
+
+	content, err := m.content()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range content.header {
+		h[k] = v
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, h)
+	buf.Write(content.body)
+	return buf.Bytes(), nil
+}
+
+// content builds the MIME tree of the message: the text and HTML alternatives, the inline attachments
+// related to the HTML body and the other attachments.
+func (m *Message) content() (part, error) {
+	var inline, attached []part
+	for _, a := range m.Attachments {
+		if a.ContentID != "" {
+			inline = append(inline, attachmentPart(a, "inline"))
+		} else {
+			attached = append(attached, attachmentPart(a, "attachment"))
+		}
+	}
+
+	var alternatives []part
+	if m.Text != "" || m.HTML == "" {
+		alternatives = append(alternatives, textPart("text/plain", m.Text))
+	}
+	if m.HTML != "" {
+		html := textPart("text/html", m.HTML)
+		if len(inline) > 0 {
+			related, err := multipartOf("related", append([]part{html}, inline...))
+			if err != nil {
+				return part{}, err
+			}
+			html = related
+		}
+		alternatives = append(alternatives, html)
+	}
+
+	content := alternatives[0]
+	if len(alternatives) > 1 {
+		alt, err := multipartOf("alternative", alternatives)
+		if err != nil {
+			return part{}, err
+		}
+		content = alt
+	}
+	if len(attached) > 0 {
+		return multipartOf("mixed", append([]part{content}, attached...))
+	}
+	return content, nil
+}
+
+func textPart(contentType, text string) part {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	_, _ = w.Write([]byte(text))
+	_ = w.Close()
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", contentType+"; charset=utf-8")
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	return part{header: h, body: buf.Bytes()}
+}
+
+func attachmentPart(a Attachment, disposition string) part {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(a.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	enc := base64.StdEncoding.EncodeToString(a.Data)
+	var buf bytes.Buffer
+	for len(enc) > base64LineLen {
+		buf.WriteString(enc[:base64LineLen] + "\r\n")
+		enc = enc[base64LineLen:]
+	}
+	buf.WriteString(enc + "\r\n")
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Transfer-Encoding", "base64")
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+	if a.ContentID != "" {
+		h.Set("Content-Id", "<"+a.ContentID+">")
+	}
+	return part{header: h, body: buf.Bytes()}
+}
+
+func multipartOf(subtype string, parts []part) (part, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		pw, err := w.CreatePart(p.header)
+		if err != nil {
+			return part{}, fmt.Errorf("could not create part: %w", err)
+		}
+		if _, err := pw.Write(p.body); err != nil {
+			return part{}, fmt.Errorf("could not write part: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return part{}, fmt.Errorf("could not close multipart: %w", err)
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", "multipart/"+subtype+"; boundary="+w.Boundary())
+	return part{header: h, body: buf.Bytes()}, nil
+}
+
+// writeHeader writes the header in a stable order, followed by the blank line separating it from the body.
+func writeHeader(buf *bytes.Buffer, h textproto.MIMEHeader) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func formatAddresses(list ...string) (string, error) {
+	out := make([]string, 0, len(list))
+	for _, a := range list {
+		addr, err := mail.ParseAddress(a)
+		if err != nil {
+			return "", fmt.Errorf("could not parse address %q: %w", a, err)
+		}
+		out = append(out, addr.String())
+	}
+	return strings.Join(out, ", "), nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndexByte(addr.Address, '@'); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	return "<" + id.NewULID() + "@" + domain + ">"
+}
@@ -0,0 +1,60 @@
+package ses
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"github.com/aws/smithy-go"
+	"github.com/indiependente/pkg/mail"
+	"github.com/indiependente/pkg/retry"
+)
+
+// API is the subset of the SES v2 client used by the driver, satisfied by *sesv2.Client.
+type API interface {
+	SendEmail(ctx context.Context, in *sesv2.SendEmailInput, opts ...func(*sesv2.Options)) (*sesv2.SendEmailOutput, error)
+}
+
+// Driver delivers messages through Amazon SES, sending them as raw MIME messages
+// so that attachments and inline images are supported.
+type Driver struct {
+	client API
+}
+
+// compile time interface check.
+var _ mail.Driver = &Driver{}
+
+// New returns a driver sending messages through the client in input.
+func New(client API) *Driver {
+	return &Driver{client: client}
+}
+
+// Send delivers the message to all of its recipients, including Bcc ones. Throttling and service errors are transient.
+func (d *Driver) Send(ctx context.Context, msg *mail.Message) error {
+	rcpts, err := msg.Recipients()
+	if err != nil {
+		return err
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	_, err = d.client.SendEmail(ctx, &sesv2.SendEmailInput{
+		Content: &types.EmailContent{
+			Raw: &types.RawMessage{Data: data},
+		},
+		Destination: &types.Destination{ToAddresses: rcpts},
+	})
+	if err != nil {
+		err = fmt.Errorf("could not send email through SES: %w", err)
+		var tooMany *types.TooManyRequestsException
+		var apiErr smithy.APIError
+		if errors.As(err, &tooMany) || (errors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultServer) {
+			return retry.Transient(err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+const defaultSMTPTimeout = 30 * time.Second
+
+// SMTPOption customises the SMTP driver.
+type SMTPOption func(*SMTP)
+
+// WithAuth authenticates to the server with the mechanism in input, e.g. smtp.PlainAuth.
+// Credentials are only sent over TLS connections.
+func WithAuth(auth smtp.Auth) SMTPOption {
+	return func(s *SMTP) {
+		s.auth = auth
+	}
+}
+
+// WithImplicitTLS connects to the server over TLS, usually on port 465, instead of upgrading the connection
+// with STARTTLS.
+func WithImplicitTLS() SMTPOption {
+	return func(s *SMTP) {
+		s.implicitTLS = true
+	}
+}
+
+// WithTLSConfig uses the TLS configuration in input, both for implicit TLS and STARTTLS.
+func WithTLSConfig(cfg *tls.Config) SMTPOption {
+	return func(s *SMTP) {
+		s.tlsConfig = cfg
+	}
+}
+
+// WithRequireTLS fails sending when the server does not support STARTTLS, rather than sending in clear text.
+func WithRequireTLS() SMTPOption {
+	return func(s *SMTP) {
+		s.requireTLS = true
+	}
+}
+
+// WithLocalName sets the host name sent to the server with the HELO/EHLO command.
+func WithLocalName(name string) SMTPOption {
+	return func(s *SMTP) {
+		s.localName = name
+	}
+}
+
+// WithSMTPTimeout bounds the time taken by every delivery, including dialing. It defaults to 30s.
+func WithSMTPTimeout(d time.Duration) SMTPOption {
+	return func(s *SMTP) {
+		s.timeout = d
+	}
+}
+
+// SMTP is a Driver delivering messages to an SMTP server.
+// It opens a connection per message, upgrading it with STARTTLS when the server supports it.
+type SMTP struct {
+	addr        string
+	host        string
+	auth        smtp.Auth
+	implicitTLS bool
+	requireTLS  bool
+	tlsConfig   *tls.Config
+	localName   string
+	timeout     time.Duration
+}
+
+// compile time interface check.
+var _ Driver = &SMTP{}
+
+// NewSMTP returns a Driver delivering messages to the SMTP server listening at addr, in the host:port form.
+func NewSMTP(addr string, opts ...SMTPOption) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse SMTP address %s: %w", addr, err)
+	}
+	s := &SMTP{
+		addr:    addr,
+		host:    host,
+		timeout: defaultSMTPTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.tlsConfig == nil {
+		s.tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	return s, nil
+}
+
+// Send delivers the message to the server.
+func (s *SMTP) Send(ctx context.Context, msg *Message) error {
+	rcpts, err := msg.Recipients()
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("could not parse sender %q: %w", msg.From, err)
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// net/smtp is not context aware: closing the connection unblocks it when the context is done.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("could not start SMTP session: %w", err)
+	}
+	defer c.Close()
+	if err := s.deliver(c, from.Address, rcpts, data); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *SMTP) dial(ctx context.Context) (net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.implicitTLS {
+		d := &tls.Dialer{Config: s.tlsConfig}
+		conn, err = d.DialContext(ctx, "tcp", s.addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to SMTP server %s: %w", s.addr, err)
+	}
+	return conn, nil
+}
+
+func (s *SMTP) deliver(c *smtp.Client, from string, rcpts []string, data []byte) error {
+	if s.localName != "" {
+		if err := c.Hello(s.localName); err != nil {
+			return fmt.Errorf("could not greet SMTP server: %w", err)
+		}
+	}
+	if !s.implicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(s.tlsConfig); err != nil {
+				return fmt.Errorf("could not start TLS: %w", err)
+			}
+		} else if s.requireTLS {
+			return fmt.Errorf("could not start TLS: server %s does not support STARTTLS", s.addr)
+		}
+	}
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err := c.Auth(s.auth); err != nil {
+				return fmt.Errorf("could not authenticate: %w", err)
+			}
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("could not set sender: %w", err)
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("could not add recipient: %w", err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("could not start data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("could not write data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("could not send data: %w", err)
+	}
+	if err := c.Quit(); err != nil {
+		return fmt.Errorf("could not quit SMTP session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	subjectSuffix = ".subject.tmpl"
+	textSuffix    = ".txt.tmpl"
+	htmlSuffix    = ".html.tmpl"
+)
+
+// Templates renders messages from named templates. A template called name is made of up to three files:
+// name.subject.tmpl, name.txt.tmpl and name.html.tmpl, rendered respectively into the subject, the text body
+// and the HTML body of the message. HTML bodies are escaped with html/template.
+type Templates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+	names   map[string]struct{}
+}
+
+// ParseFS parses the templates in fsys matching the glob patterns in input, e.g. "templates/*.tmpl".
+func ParseFS(fsys fs.FS, patterns ...string) (*Templates, error) {
+	t := &Templates{
+		subject: texttemplate.New(""),
+		text:    texttemplate.New(""),
+		html:    htmltemplate.New(""),
+		names:   make(map[string]struct{}),
+	}
+	for _, pattern := range patterns {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("could not match templates %s: %w", pattern, err)
+		}
+		for _, file := range files {
+			if err := t.parse(fsys, file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(t.names) == 0 {
+		return nil, errors.New("could not parse templates: no templates found")
+	}
+	return t, nil
+}
+
+func (t *Templates) parse(fsys fs.FS, file string) error {
+	b, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return fmt.Errorf("could not read template %s: %w", file, err)
+	}
+	base := file[strings.LastIndex(file, "/")+1:]
+	switch {
+	case strings.HasSuffix(base, subjectSuffix):
+		name := strings.TrimSuffix(base, subjectSuffix)
+		_, err = t.subject.New(name).Parse(string(b))
+		t.names[name] = struct{}{}
+	case strings.HasSuffix(base, textSuffix):
+		name := strings.TrimSuffix(base, textSuffix)
+		_, err = t.text.New(name).Parse(string(b))
+		t.names[name] = struct{}{}
+	case strings.HasSuffix(base, htmlSuffix):
+		name := strings.TrimSuffix(base, htmlSuffix)
+		_, err = t.html.New(name).Parse(string(b))
+		t.names[name] = struct{}{}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse template %s: %w", file, err)
+	}
+	return nil
+}
+
+// Render renders the template called name with the data in input into the subject and the bodies of the message.
+func (t *Templates) Render(msg *Message, name string, data interface{}) error {
+	if _, ok := t.names[name]; !ok {
+		return fmt.Errorf("could not render template %s: not found", name)
+	}
+	var buf bytes.Buffer
+	if tmpl := t.subject.Lookup(name); tmpl != nil {
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("could not render subject of template %s: %w", name, err)
+		}
+		msg.Subject = strings.TrimSpace(buf.String())
+		buf.Reset()
+	}
+	if tmpl := t.text.Lookup(name); tmpl != nil {
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("could not render text of template %s: %w", name, err)
+		}
+		msg.Text = buf.String()
+		buf.Reset()
+	}
+	if tmpl := t.html.Lookup(name); tmpl != nil {
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("could not render HTML of template %s: %w", name, err)
+		}
+		msg.HTML = buf.String()
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package mapsx
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Keys returns the keys of the map in ascending order.
+func Keys[M ~map[K]V, K cmp.Ordered, V any](m M) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Values returns the values of the map in ascending order of their keys.
+func Values[M ~map[K]V, K cmp.Ordered, V any](m M) []V {
+	values := make([]V, 0, len(m))
+	for _, k := range Keys(m) {
+		values = append(values, m[k])
+	}
+	return values
+}
+
+// Conflict decides the value of a key found in more than one of the maps merged, given the value merged so far
+// and the one of the map being merged.
+type Conflict[K comparable, V any] func(key K, current, next V) V
+
+// KeepFirst keeps the value of the first map holding the key.
+func KeepFirst[K comparable, V any](_ K, current, _ V) V {
+	return current
+}
+
+// KeepLast keeps the value of the last map holding the key.
+func KeepLast[K comparable, V any](_ K, _, next V) V {
+	return next
+}
+
+// Merge returns a new map holding the entries of the maps, resolving the keys found in more than one of them
+// with the conflict function, e.g. KeepFirst or KeepLast.
+func Merge[M ~map[K]V, K comparable, V any](conflict Conflict[K, V], maps ...M) M {
+	size := 0
+	for _, m := range maps {
+		size = max(size, len(m))
+	}
+	out := make(M, size)
+	for _, m := range maps {
+		for k, v := range m {
+			if current, ok := out[k]; ok {
+				v = conflict(k, current, v)
+			}
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Filter returns a new map holding the entries for which keep returns true.
+func Filter[M ~map[K]V, K comparable, V any](m M, keep func(K, V) bool) M {
+	out := make(M)
+	for k, v := range m {
+		if keep(k, v) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// MapValues returns a new map with the same keys, holding the result of fn for every value.
+func MapValues[M ~map[K]V, K comparable, V, R any](m M, fn func(V) R) map[K]R {
+	out := make(map[K]R, len(m))
+	for k, v := range m {
+		out[k] = fn(v)
+	}
+	return out
+}
+
+// Invert returns a new map from the values to the keys. When values are shared, the key kept is unspecified.
+func Invert[M ~map[K]V, K, V comparable](m M) map[V]K {
+	out := make(map[V]K, len(m))
+	for k, v := range m {
+		out[v] = k
+	}
+	return out
+}
+
+// Set is a set of comparable items.
+type Set[T comparable] map[T]struct{}
+
+// NewSet returns a set holding the items in input.
+func NewSet[T comparable](items ...T) Set[T] {
+	s := make(Set[T], len(items))
+	for _, v := range items {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+// Add adds the items to the set.
+func (s Set[T]) Add(items ...T) {
+	for _, v := range items {
+		s[v] = struct{}{}
+	}
+}
+
+// Remove removes the items from the set.
+func (s Set[T]) Remove(items ...T) {
+	for _, v := range items {
+		delete(s, v)
+	}
+}
+
+// Has reports whether the item is in the set.
+func (s Set[T]) Has(v T) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// Union returns a new set holding the items of both sets.
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	out := make(Set[T], max(len(s), len(other)))
+	for v := range s {
+		out[v] = struct{}{}
+	}
+	for v := range other {
+		out[v] = struct{}{}
+	}
+	return out
+}
+
+// Intersect returns a new set holding the items in both sets.
+func (s Set[T]) Intersect(other Set[T]) Set[T] {
+	small, large := s, other
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	out := make(Set[T], len(small))
+	for v := range small {
+		if _, ok := large[v]; ok {
+			out[v] = struct{}{}
+		}
+	}
+	return out
+}
+
+// Difference returns a new set holding the items of s which are not in other.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	out := make(Set[T], len(s))
+	for v := range s {
+		if _, ok := other[v]; !ok {
+			out[v] = struct{}{}
+		}
+	}
+	return out
+}
+
+// Sorted returns the items of the set in ascending order.
+func Sorted[T cmp.Ordered](s Set[T]) []T {
+	return Keys(s)
+}
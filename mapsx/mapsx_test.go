@@ -0,0 +1,44 @@
+package mapsx
+
+import (
+	"testing"
+)
+
+func set(n, offset int) Set[int] {
+	s := make(Set[int], n)
+	for i := range n {
+		s.Add(i + offset)
+	}
+	return s
+}
+
+func BenchmarkSetUnion(b *testing.B) {
+	x, y := set(10000, 0), set(10000, 5000)
+	for b.Loop() {
+		_ = x.Union(y)
+	}
+}
+
+func BenchmarkSetIntersect(b *testing.B) {
+	x, y := set(10000, 0), set(10000, 5000)
+	for b.Loop() {
+		_ = x.Intersect(y)
+	}
+}
+
+func BenchmarkSetDifference(b *testing.B) {
+	x, y := set(10000, 0), set(10000, 5000)
+	for b.Loop() {
+		_ = x.Difference(y)
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	x, y := map[int]int{}, map[int]int{}
+	for i := range 10000 {
+		x[i], y[i+5000] = i, i
+	}
+	for b.Loop() {
+		_ = Merge(KeepLast[int, int], x, y)
+	}
+}
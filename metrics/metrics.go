@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+var (
+	// DefaultBuckets are the histogram buckets, in seconds, used for latencies.
+	DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+	// SizeBuckets are the histogram buckets, in bytes, used for payload sizes.
+	SizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+)
+
+// Option customises a Registry.
+type Option func(*Registry)
+
+// WithoutRuntimeCollectors does not register the Go runtime and process collectors.
+func WithoutRuntimeCollectors() Option {
+	return func(r *Registry) {
+		r.runtime = false
+	}
+}
+
+// WithConstLabels adds the labels in input to every metric created by the registry.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(r *Registry) {
+		r.constLabels = labels
+	}
+}
+
+// Registry is a Prometheus registry creating metrics under a namespace and a subsystem.
+// It implements prometheus.Registerer and prometheus.Gatherer, so it can be passed to the HTTP middleware
+// and client metrics options.
+type Registry struct {
+	*prometheus.Registry
+	namespace   string
+	subsystem   string
+	constLabels prometheus.Labels
+	runtime     bool
+}
+
+// New returns a registry for the namespace and subsystem in input.
+// The Go runtime and process collectors are registered unless WithoutRuntimeCollectors is passed.
+func New(namespace, subsystem string, opts ...Option) *Registry {
+	r := &Registry{
+		Registry:  prometheus.NewRegistry(),
+		namespace: namespace,
+		subsystem: subsystem,
+		runtime:   true,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.runtime {
+		r.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+	return r
+}
+
+// Counter returns a counter vector with the name and labels in input, registering it if needed.
+func (r *Registry) Counter(name, help string, labels ...string) *prometheus.CounterVec {
+	return Register(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   r.namespace,
+		Subsystem:   r.subsystem,
+		Name:        name,
+		Help:        help,
+		ConstLabels: r.constLabels,
+	}, labels))
+}
+
+// Gauge returns a gauge vector with the name and labels in input, registering it if needed.
+func (r *Registry) Gauge(name, help string, labels ...string) *prometheus.GaugeVec {
+	return Register(r, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   r.namespace,
+		Subsystem:   r.subsystem,
+		Name:        name,
+		Help:        help,
+		ConstLabels: r.constLabels,
+	}, labels))
+}
+
+// Histogram returns a histogram vector with the name and labels in input, registering it if needed.
+// It uses DefaultBuckets when buckets is nil.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return Register(r, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   r.namespace,
+		Subsystem:   r.subsystem,
+		Name:        name,
+		Help:        help,
+		ConstLabels: r.constLabels,
+		Buckets:     buckets,
+	}, labels))
+}
+
+// Handler returns an http.Handler exposing the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r, promhttp.HandlerOpts{Registry: r})
+}
+
+// Mount serves the metrics of the registry on the /metrics path of the admin mux.
+func (r *Registry) Mount(mux *http.ServeMux) {
+	mux.Handle("/metrics", r.Handler())
+}
+
+// Push pushes the metrics of the registry to the Prometheus push gateway at url under the job in input,
+// replacing the metrics previously pushed for the same job.
+func (r *Registry) Push(ctx context.Context, url, job string) error {
+	if err := push.New(url, job).Gatherer(r).PushContext(ctx); err != nil {
+		return fmt.Errorf("could not push metrics: %w", err)
+	}
+	return nil
+}
+
+// PushEvery pushes the metrics of the registry every interval until ctx is done, pushing one last time before returning.
+// Push errors are reported to onError, if not nil.
+func (r *Registry) PushEvery(ctx context.Context, url, job string, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pushOnce := func(ctx context.Context) {
+		if err := r.Push(ctx, url, job); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+	for {
+		select {
+		case <-ticker.C:
+			pushOnce(ctx)
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), interval)
+			pushOnce(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+// Register registers the collector, returning the existing one if an equivalent collector was already registered,
+// so that constructors can be called more than once with the same registerer. It panics on other errors.
+func Register[C prometheus.Collector](registerer prometheus.Registerer, c C) C {
+	if err := registerer.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,471 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/locks"
+	"github.com/indiependente/pkg/logger"
+)
+
+const (
+	migrateEvent = "migrate"
+
+	defaultTable   = "schema_migrations"
+	defaultLockKey = "schema_migrations"
+	defaultLockTTL = time.Minute
+
+	// noTxDirective, on the first line of a migration, runs it outside of a transaction,
+	// e.g. for CREATE INDEX CONCURRENTLY.
+	noTxDirective = "-- migrate:notx"
+)
+
+// fileRE matches the names of the migration files, e.g. 0001_create_users.up.sql.
+var fileRE = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var (
+	// ErrDirty is returned when a previous migration failed halfway, leaving the schema in an unknown state
+	// which must be fixed by hand before calling Force.
+	ErrDirty = errors.New("database is dirty")
+	// ErrNoDown is returned when rolling back a migration without a down file.
+	ErrNoDown = errors.New("migration has no down file")
+	// ErrUnknownVersion is returned when migrating to a version which does not exist.
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+// Migration is a versioned schema change, read from the files <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+	hasDown bool
+}
+
+// Option customises a Migrator.
+type Option func(*Migrator)
+
+// WithTable records the applied migrations in the table in input, "schema_migrations" by default.
+func WithTable(name string) Option {
+	return func(m *Migrator) {
+		m.table = name
+	}
+}
+
+// WithDollarPlaceholders uses $1, $2... placeholders, as PostgreSQL requires, rather than ?.
+func WithDollarPlaceholders() Option {
+	return func(m *Migrator) {
+		m.dollar = true
+	}
+}
+
+// WithLocker holds a lock while migrating, e.g. with locks.NewPostgres, so that instances starting together
+// do not run the migrations concurrently: the others wait for the lock and find nothing left to apply.
+func WithLocker(locker locks.Locker) Option {
+	return func(m *Migrator) {
+		m.locker = locker
+	}
+}
+
+// WithLogger logs every migration applied or rolled back, along with its duration.
+func WithLogger(log logger.Logger) Option {
+	return func(m *Migrator) {
+		m.log = log
+	}
+}
+
+// Migrator applies and rolls back the migrations read from a file system to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	table      string
+	dollar     bool
+	locker     locks.Locker
+	log        logger.Logger
+}
+
+// New returns a Migrator applying the migrations at the root of fsys, e.g. fs.Sub of an embed.FS, to the database.
+// Every migration runs in a transaction, unless its first line is "-- migrate:notx".
+func New(db *sql.DB, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	m := &Migrator{db: db, table: defaultTable}
+	for _, opt := range opts {
+		opt(m)
+	}
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	m.migrations = migrations
+	return m, nil
+}
+
+// Run applies all the pending migrations at the root of fsys to the database.
+func Run(ctx context.Context, db *sql.DB, fsys fs.FS, opts ...Option) error {
+	m, err := New(db, fsys, opts...)
+	if err != nil {
+		return err
+	}
+	return m.Up(ctx)
+}
+
+// Migrations returns the migrations, sorted by version.
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Version returns the latest applied version, 0 if none, and whether the database is dirty.
+func (m *Migrator) Version(ctx context.Context) (int64, bool, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, false, err
+	}
+	applied, dirty, err := m.applied(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	var latest int64
+	for v := range applied {
+		latest = max(latest, v)
+	}
+	return latest, dirty != 0, nil
+}
+
+// Up applies all the pending migrations, in order of version.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.UpTo(ctx, -1)
+}
+
+// UpTo applies the pending migrations up to the version in input, included. A negative version applies them all.
+func (m *Migrator) UpTo(ctx context.Context, version int64) error {
+	if version > 0 && m.find(version) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.checked(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if version >= 0 && mig.Version > version {
+				break
+			}
+			if applied[mig.Version] {
+				continue
+			}
+			if err := m.apply(ctx, mig, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.checked(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if applied[m.migrations[i].Version] {
+				return m.apply(ctx, m.migrations[i], false)
+			}
+		}
+		return nil
+	})
+}
+
+// DownTo rolls back the applied migrations newer than the version in input, from the latest one.
+// Version 0 rolls back all of them.
+func (m *Migrator) DownTo(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.checked(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && m.migrations[i].Version > version; i-- {
+			if !applied[m.migrations[i].Version] {
+				continue
+			}
+			if err := m.apply(ctx, m.migrations[i], false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Force clears the dirty state and records the migration with the version in input as applied, without running it,
+// once the schema has been fixed by hand after a failure.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	i := m.find(version)
+	if i < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.locked(ctx, func(ctx context.Context) error {
+		if err := m.ensureTable(ctx); err != nil {
+			return err
+		}
+		tx, err := m.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("could not begin transaction: %w", err)
+		}
+		defer func() { _ = tx.Rollback() }()
+		if _, err := tx.ExecContext(ctx, m.rebind(`DELETE FROM `+m.table+` WHERE dirty = ? OR version = ?`), true, version); err != nil {
+			return fmt.Errorf("could not clear dirty state: %w", err)
+		}
+		if err := m.record(ctx, tx, m.migrations[i], false); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("could not commit transaction: %w", err)
+		}
+		return nil
+	})
+}
+
+// apply runs the up or down script of the migration, recording the outcome.
+func (m *Migrator) apply(ctx context.Context, mig Migration, up bool) error {
+	script, direction := mig.up, "up"
+	if !up {
+		if !mig.hasDown {
+			return fmt.Errorf("%w: %d_%s", ErrNoDown, mig.Version, mig.Name)
+		}
+		script, direction = mig.down, "down"
+	}
+	start := time.Now()
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(script), noTxDirective) {
+		err = m.applyNoTx(ctx, mig, script, up)
+	} else {
+		err = m.applyTx(ctx, mig, script, up)
+	}
+	if m.log != nil {
+		l := m.log.Event(migrateEvent).Duration(time.Since(start))
+		msg := fmt.Sprintf("Migration %d_%s %s", mig.Version, mig.Name, direction)
+		if err != nil {
+			l.Error(msg+" failed", err)
+		} else {
+			l.Info(msg + " applied")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not migrate %d_%s %s: %w", mig.Version, mig.Name, direction, err)
+	}
+	return nil
+}
+
+// applyTx runs the script and records it in the same transaction, so that a failure leaves no trace.
+func (m *Migrator) applyTx(ctx context.Context, mig Migration, script string, up bool) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if up {
+		err = m.record(ctx, tx, mig, false)
+	} else {
+		err = m.forget(ctx, tx, mig)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// applyNoTx marks the database as dirty while the script runs, so that a failure halfway is detected.
+func (m *Migrator) applyNoTx(ctx context.Context, mig Migration, script string, up bool) error {
+	if up {
+		if err := m.record(ctx, m.db, mig, true); err != nil {
+			return err
+		}
+	} else {
+		if _, err := m.db.ExecContext(ctx, m.rebind(`UPDATE `+m.table+` SET dirty = ? WHERE version = ?`), true, mig.Version); err != nil {
+			return fmt.Errorf("could not mark migration as dirty: %w", err)
+		}
+	}
+	if _, err := m.db.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if !up {
+		return m.forget(ctx, m.db, mig)
+	}
+	if _, err := m.db.ExecContext(ctx, m.rebind(`UPDATE `+m.table+` SET dirty = ? WHERE version = ?`), false, mig.Version); err != nil {
+		return fmt.Errorf("could not mark migration as clean: %w", err)
+	}
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (m *Migrator) record(ctx context.Context, ex execer, mig Migration, dirty bool) error {
+	query := m.rebind(`INSERT INTO ` + m.table + ` (version, name, dirty, applied_at) VALUES (?, ?, ?, ?)`)
+	if _, err := ex.ExecContext(ctx, query, mig.Version, mig.Name, dirty, time.Now().UTC()); err != nil {
+		return fmt.Errorf("could not record migration: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) forget(ctx context.Context, ex execer, mig Migration) error {
+	if _, err := ex.ExecContext(ctx, m.rebind(`DELETE FROM `+m.table+` WHERE version = ?`), mig.Version); err != nil {
+		return fmt.Errorf("could not forget migration: %w", err)
+	}
+	return nil
+}
+
+// locked runs fn holding the migration lock, if a locker is set.
+func (m *Migrator) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.locker == nil {
+		return fn(ctx)
+	}
+	return locks.Run(ctx, m.locker, defaultLockKey+":"+m.table, defaultLockTTL, func(ctx context.Context, _ int64) error {
+		return fn(ctx)
+	}, locks.WithWait(time.Second))
+}
+
+// checked returns the applied versions, failing with ErrDirty if the database is dirty.
+func (m *Migrator) checked(ctx context.Context) (map[int64]bool, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, dirty, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dirty != 0 {
+		return nil, fmt.Errorf("%w: migration %d failed halfway", ErrDirty, dirty)
+	}
+	return applied, nil
+}
+
+// applied returns the applied versions, along with the dirty one, if any.
+func (m *Migrator) applied(ctx context.Context) (map[int64]bool, int64, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version, dirty FROM `+m.table)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not read applied migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int64]bool)
+	var dirtyVersion int64
+	for rows.Next() {
+		var (
+			v     int64
+			dirty bool
+		)
+		if err := rows.Scan(&v, &dirty); err != nil {
+			return nil, 0, fmt.Errorf("could not read applied migrations: %w", err)
+		}
+		applied[v] = true
+		if dirty {
+			dirtyVersion = v
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("could not read applied migrations: %w", err)
+	}
+	return applied, dirtyVersion, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS ` + m.table + ` (
+	version    BIGINT PRIMARY KEY,
+	name       VARCHAR(255) NOT NULL,
+	dirty      BOOLEAN NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("could not create migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int64) int {
+	for i, mig := range m.migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// rebind replaces the ? placeholders with $1, $2... if needed.
+func (m *Migrator) rebind(query string) string {
+	if !m.dollar {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// load reads the migrations at the root of fsys, failing on duplicated versions and on down files without an up one.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %w", err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := fileRE.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse version of %s: %w", e.Name(), err)
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %s: %w", e.Name(), err)
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("could not load migrations: version %d is used by both %s and %s", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.up = string(b)
+		} else {
+			mig.down, mig.hasDown = string(b), true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.up == "" {
+			return nil, fmt.Errorf("could not load migrations: %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
@@ -0,0 +1,454 @@
+package money
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrCurrencyMismatch is returned by operations between amounts in different currencies.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrUnknownCurrency is returned for currency codes which are not ISO 4217 codes known to the package.
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrOverflow is returned when the result does not fit the minor units.
+	ErrOverflow = errors.New("amount overflow")
+	// ErrInvalidAmount is returned when parsing amounts which are malformed or more precise than the currency.
+	ErrInvalidAmount = errors.New("invalid amount")
+)
+
+// exponents holds the number of minor unit digits of the currencies not using 2.
+var exponents = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KMF": 0,
+	"KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0,
+	"VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// twoDigits lists the other known currencies, all using 2 minor unit digits.
+var twoDigits = strings.Fields(`AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BMD BND BOB BRL BSD BTN BWP BYN
+BZD CAD CDF CHF CNY COP CRC CUP CVE CZK DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GTQ GYD HKD HNL HTG HUF
+IDR ILS INR IRR JMD KES KGS KHR KPW KYD KZT LAK LBP LKR LRD LSL MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN
+NAD NGN NIO NOK NPR NZD PAB PEN PGK PHP PKR PLN QAR RON RSD RUB SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP
+SZL THB TJS TMT TOP TRY TTD TWD TZS UAH USD UYU UZS VES WST XCD YER ZAR ZMW ZWL`)
+
+func init() {
+	for _, code := range twoDigits {
+		exponents[code] = 2
+	}
+}
+
+// Exponent returns the number of minor unit digits of the currency, e.g. 2 for EUR and 0 for JPY.
+func Exponent(currency string) (int, error) {
+	exp, ok := exponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return exp, nil
+}
+
+// RoundingMode decides how amounts more precise than the minor units are rounded.
+type RoundingMode int
+
+const (
+	// HalfEven rounds to the nearest minor unit, ties to the even one (banker's rounding).
+	HalfEven RoundingMode = iota
+	// HalfUp rounds to the nearest minor unit, ties away from zero.
+	HalfUp
+	// Down truncates towards zero.
+	Down
+	// Up rounds away from zero.
+	Up
+)
+
+// Money is an amount of a currency, held as an integer number of minor units, e.g. cents.
+// The zero value has no currency and is only equal to itself.
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// New returns the amount of minor units of the currency in input, e.g. New(1234, "EUR") is 12.34 EUR.
+func New(minor int64, currency string) (Money, error) {
+	if _, err := Exponent(currency); err != nil {
+		return Money{}, err
+	}
+	return Money{minor: minor, currency: currency}, nil
+}
+
+// MustNew is like New but panics on unknown currencies. It is meant for constants and tests.
+func MustNew(minor int64, currency string) Money {
+	m, err := New(minor, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Zero returns no money of the currency.
+func Zero(currency string) (Money, error) {
+	return New(0, currency)
+}
+
+// Parse parses a decimal amount of the currency, e.g. "-12.34". Amounts more precise than the currency
+// minor units are rejected; use ParseRound to round them instead.
+func Parse(amount, currency string) (Money, error) {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	r, err := parseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	r.Mul(r, new(big.Rat).SetInt(pow10(exp)))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("%w: %q has more than %d decimals", ErrInvalidAmount, amount, exp)
+	}
+	return fromBig(r.Num(), currency)
+}
+
+// ParseRound parses a decimal amount of the currency, rounding it to the minor units with the mode in input.
+func ParseRound(amount, currency string, mode RoundingMode) (Money, error) {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	r, err := parseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+	r.Mul(r, new(big.Rat).SetInt(pow10(exp)))
+	return fromBig(round(r.Num(), r.Denom(), mode), currency)
+}
+
+// decimalPattern matches plain decimal amounts, as big.Rat.SetString also accepts Go literals such as "0x10",
+// "0b11", "1_000", exponents and fractions.
+var decimalPattern = regexp.MustCompile(`^[+-]?\d+(\.\d+)?$`)
+
+// parseDecimal parses a plain decimal amount.
+func parseDecimal(amount string) (*big.Rat, error) {
+	if !decimalPattern.MatchString(amount) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	r, ok := new(big.Rat).SetString(amount)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	return r, nil
+}
+
+// Minor returns the amount in minor units.
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+// Currency returns the ISO 4217 code of the currency.
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.minor == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.minor < 0
+}
+
+// Equal reports whether the amounts and currencies are the same.
+func (m Money) Equal(other Money) bool {
+	return m == other
+}
+
+// Cmp compares the amounts, returning -1, 0 or +1. It fails if the currencies differ.
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.minor < other.minor:
+		return -1, nil
+	case m.minor > other.minor:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Add returns the sum of the amounts, failing if the currencies differ or the sum overflows.
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	sum := m.minor + other.minor
+	if (sum > m.minor) != (other.minor > 0) {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: sum, currency: m.currency}, nil
+}
+
+// Sub returns the difference of the amounts, failing if the currencies differ or the difference overflows.
+func (m Money) Sub(other Money) (Money, error) {
+	if other.minor == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{minor: -other.minor, currency: other.currency})
+}
+
+// Mul returns the amount multiplied by n, failing if the product overflows.
+func (m Money) Mul(n int64) (Money, error) {
+	p := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(n))
+	return fromBig(p, m.currency)
+}
+
+// MulRat returns the amount multiplied by num/den, e.g. 15/100 for a 15% fee, rounded with the mode in input.
+func (m Money) MulRat(num, den int64, mode RoundingMode) (Money, error) {
+	if den == 0 {
+		return Money{}, fmt.Errorf("%w: zero denominator", ErrInvalidAmount)
+	}
+	p := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(num))
+	d := big.NewInt(den)
+	if den < 0 {
+		p.Neg(p)
+		d.Neg(d)
+	}
+	return fromBig(round(p, d, mode), m.currency)
+}
+
+// Neg returns the opposite amount.
+func (m Money) Neg() (Money, error) {
+	if m.minor == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: -m.minor, currency: m.currency}, nil
+}
+
+// Abs returns the absolute amount.
+func (m Money) Abs() (Money, error) {
+	if m.minor < 0 {
+		return m.Neg()
+	}
+	return m, nil
+}
+
+// Allocate splits the amount proportionally to the ratios, with the largest remainder method: every share gets
+// the floor of its exact part, and the minor units left go one each to the shares with the largest remainders,
+// so that the shares always add up to the amount.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, fmt.Errorf("%w: no ratios", ErrInvalidAmount)
+	}
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, fmt.Errorf("%w: negative ratio", ErrInvalidAmount)
+		}
+		total += r
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: ratios add up to zero", ErrInvalidAmount)
+	}
+
+	abs := new(big.Int).Abs(big.NewInt(m.minor))
+	bigTotal := big.NewInt(total)
+	shares := make([]int64, len(ratios))
+	remainders := make([]*big.Int, len(ratios))
+	left := new(big.Int).Set(abs)
+	for i, r := range ratios {
+		q, rem := new(big.Int).QuoRem(new(big.Int).Mul(abs, big.NewInt(r)), bigTotal, new(big.Int))
+		shares[i] = q.Int64()
+		remainders[i] = rem
+		left.Sub(left, q)
+	}
+	// the units left are fewer than the shares, so each gets at most one
+	for n := left.Int64(); n > 0; n-- {
+		best := -1
+		for i, rem := range remainders {
+			if rem.Sign() >= 0 && ratios[i] > 0 && (best < 0 || rem.Cmp(remainders[best]) > 0) {
+				best = i
+			}
+		}
+		shares[best]++
+		remainders[best] = big.NewInt(-1)
+	}
+
+	out := make([]Money, len(shares))
+	for i, s := range shares {
+		if m.minor < 0 {
+			s = -s
+		}
+		out[i] = Money{minor: s, currency: m.currency}
+	}
+	return out, nil
+}
+
+// Split divides the amount into n shares differing by one minor unit at most, the larger ones first.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: split into %d shares", ErrInvalidAmount, n)
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Decimal returns the amount as a decimal string, e.g. "-12.34".
+func (m Money) Decimal() string {
+	return m.Format("", ".")
+}
+
+// Format returns the amount as a decimal string, grouping the thousands with thousandsSep and separating
+// the decimals with decimalSep, e.g. Format(",", ".") returns "1,234.56".
+func (m Money) Format(thousandsSep, decimalSep string) string {
+	exp := exponents[m.currency]
+	digits := strconv.FormatUint(absUint(m.minor), 10)
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	integer, frac := digits[:len(digits)-exp], digits[len(digits)-exp:]
+
+	var sb strings.Builder
+	if m.minor < 0 {
+		sb.WriteByte('-')
+	}
+	for i, d := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			sb.WriteString(thousandsSep)
+		}
+		sb.WriteRune(d)
+	}
+	if exp > 0 {
+		sb.WriteString(decimalSep)
+		sb.WriteString(frac)
+	}
+	return sb.String()
+}
+
+// String returns the amount followed by the currency code, e.g. "12.34 EUR".
+func (m Money) String() string {
+	if m.currency == "" {
+		return "0"
+	}
+	return m.Decimal() + " " + m.currency
+}
+
+type jsonMoney struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as {"amount":"12.34","currency":"EUR"}, keeping the amount a string
+// so that clients do not parse it as a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.Decimal(), Currency: m.currency})
+}
+
+// UnmarshalJSON decodes the format written by MarshalJSON, accepting numeric amounts too.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	parsed, err := Parse(raw.Amount.String(), raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// compile time interface check.
+var (
+	_ driver.Valuer = Money{}
+	_ sql.Scanner   = &Money{}
+)
+
+// Value stores the amount in a single text column as the currency code followed by the amount, e.g. "EUR 12.34".
+// To store amounts in numeric columns, use Minor and Currency.
+func (m Money) Value() (driver.Value, error) {
+	return m.currency + " " + m.Decimal(), nil
+}
+
+// Scan reads the format written by Value.
+func (m *Money) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("could not scan %T into money", src)
+	}
+	currency, amount, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	parsed, err := Parse(amount, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m Money) sameCurrency(other Money) error {
+	if m.currency != other.currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, other.currency)
+	}
+	return nil
+}
+
+// fromBig returns the money of the minor units in input, failing if they do not fit an int64.
+func fromBig(minor *big.Int, currency string) (Money, error) {
+	if !minor.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{minor: minor.Int64(), currency: currency}, nil
+}
+
+// round returns num/den rounded to an integer with the mode in input. den must be positive.
+func round(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	away := big.NewInt(int64(num.Sign()))
+	switch mode {
+	case Down:
+		return q
+	case Up:
+		return q.Add(q, away)
+	}
+	// compare twice the remainder with the denominator to find the nearest integer
+	cmp := new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(den)
+	if cmp > 0 || (cmp == 0 && (mode == HalfUp || q.Bit(0) == 1)) {
+		return q.Add(q, away)
+	}
+	return q
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func absUint(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
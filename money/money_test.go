@@ -0,0 +1,107 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		currency string
+		want     int64
+		wantErr  error
+	}{
+		{name: "integer", amount: "12", currency: "EUR", want: 1200},
+		{name: "decimal", amount: "12.34", currency: "EUR", want: 1234},
+		{name: "negative", amount: "-12.3", currency: "EUR", want: -1230},
+		{name: "explicit sign", amount: "+0.01", currency: "EUR", want: 1},
+		{name: "no minor units", amount: "1000", currency: "JPY", want: 1000},
+		{name: "three minor digits", amount: "1.234", currency: "KWD", want: 1234},
+		{name: "too precise", amount: "1.234", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "hexadecimal", amount: "0x10", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "binary", amount: "0b11", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "octal", amount: "0o17", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "hexadecimal float", amount: "0x1p4", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "underscores", amount: "1_000", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "exponent", amount: "1e3", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "fraction", amount: "1/3", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "missing integer part", amount: ".5", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "missing decimals", amount: "5.", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "spaces", amount: " 5", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "empty", amount: "", currency: "EUR", wantErr: ErrInvalidAmount},
+		{name: "overflow", amount: "92233720368547758.08", currency: "EUR", wantErr: ErrOverflow},
+		{name: "unknown currency", amount: "1", currency: "XXX", wantErr: ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.amount, tt.currency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Minor() != tt.want || got.Currency() != tt.currency) {
+				t.Fatalf("Parse() = %v, want %d minor units of %s", got, tt.want, tt.currency)
+			}
+		})
+	}
+}
+
+func TestParseRound(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  string
+		mode    RoundingMode
+		want    int64
+		wantErr error
+	}{
+		{name: "half even down", amount: "0.125", mode: HalfEven, want: 12},
+		{name: "half even up", amount: "0.135", mode: HalfEven, want: 14},
+		{name: "half up", amount: "0.125", mode: HalfUp, want: 13},
+		{name: "down", amount: "-0.129", mode: Down, want: -12},
+		{name: "up", amount: "0.121", mode: Up, want: 13},
+		{name: "hexadecimal", amount: "0x10", mode: HalfEven, wantErr: ErrInvalidAmount},
+		{name: "hexadecimal float", amount: "0x1p4", mode: HalfEven, wantErr: ErrInvalidAmount},
+		{name: "underscores", amount: "1_000.5", mode: HalfEven, wantErr: ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRound(tt.amount, "EUR", tt.mode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseRound() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Minor() != tt.want {
+				t.Fatalf("ParseRound() = %d minor units, want %d", got.Minor(), tt.want)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Money
+		wantErr bool
+	}{
+		{name: "string", src: "EUR 12.34", want: MustNew(1234, "EUR")},
+		{name: "bytes", src: []byte("JPY 5"), want: MustNew(5, "JPY")},
+		{name: "hexadecimal", src: "EUR 0x10", wantErr: true},
+		{name: "binary", src: "EUR 0b11", wantErr: true},
+		{name: "underscores", src: "EUR 1_000", wantErr: true},
+		{name: "missing currency", src: "12.34", wantErr: true},
+		{name: "wrong type", src: 12, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := got.Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(tt.want) {
+				t.Fatalf("Scan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
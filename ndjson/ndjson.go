@@ -0,0 +1,195 @@
+package ndjson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/indiependente/pkg/validate"
+)
+
+const (
+	defaultMaxLineSize = 1 << 20
+	initialBufferSize  = 64 << 10
+)
+
+// LineError is returned for the lines which could not be decoded or validated.
+// Decoding can go on after a LineError, skipping the line.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Progress reports how far the stream has been read.
+type Progress struct {
+	Lines int64
+	Bytes int64
+}
+
+// Option customises a Decoder.
+type Option func(*config)
+
+type config struct {
+	maxLineSize int
+	strict      bool
+	validate    bool
+	progress    func(Progress)
+	every       int64
+}
+
+// WithMaxLineSize bounds the memory used by the decoder, failing lines longer than n bytes. It defaults to 1MiB.
+func WithMaxLineSize(n int) Option {
+	return func(c *config) {
+		c.maxLineSize = n
+	}
+}
+
+// WithDisallowUnknownFields fails the lines holding fields which do not map onto T.
+func WithDisallowUnknownFields() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// WithValidation validates every decoded value with validate.Struct, returning a LineError for the invalid ones.
+func WithValidation() Option {
+	return func(c *config) {
+		c.validate = true
+	}
+}
+
+// WithProgress calls fn every n lines, and once more at the end of the stream.
+func WithProgress(n int, fn func(Progress)) Option {
+	return func(c *config) {
+		c.every = int64(n)
+		c.progress = fn
+	}
+}
+
+// Decoder decodes newline delimited JSON values of type T, one line at a time,
+// so that memory use does not depend on the size of the input. Blank lines are skipped.
+type Decoder[T any] struct {
+	cfg     *config
+	scanner *bufio.Scanner
+	line    int
+	lines   int64
+	bytes   int64
+	done    bool
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder[T any](r io.Reader, opts ...Option) *Decoder[T] {
+	cfg := &config{maxLineSize: defaultMaxLineSize}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, min(initialBufferSize, cfg.maxLineSize)), cfg.maxLineSize)
+	return &Decoder[T]{cfg: cfg, scanner: s}
+}
+
+// Decode returns the next value, or io.EOF once the input is over.
+// Lines which cannot be decoded or validated result in a *LineError, after which decoding can go on.
+func (d *Decoder[T]) Decode() (T, error) {
+	var v T
+	for {
+		if d.done {
+			return v, io.EOF
+		}
+		if !d.scanner.Scan() {
+			d.done = true
+			d.report()
+			if err := d.scanner.Err(); err != nil {
+				if errors.Is(err, bufio.ErrTooLong) {
+					return v, &LineError{Line: d.line + 1, Err: fmt.Errorf("line longer than %d bytes", d.cfg.maxLineSize)}
+				}
+				return v, fmt.Errorf("could not read NDJSON: %w", err)
+			}
+			return v, io.EOF
+		}
+		d.line++
+		b := d.scanner.Bytes()
+		d.bytes += int64(len(b)) + 1
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+
+		d.lines++
+		if d.cfg.every > 0 && d.lines%d.cfg.every == 0 {
+			d.report()
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		if d.cfg.strict {
+			dec.DisallowUnknownFields()
+		}
+		var decoded T
+		if err := dec.Decode(&decoded); err != nil {
+			return v, &LineError{Line: d.line, Err: err}
+		}
+		if d.cfg.validate {
+			if err := validate.Struct(decoded); err != nil {
+				return v, &LineError{Line: d.line, Err: err}
+			}
+		}
+		return decoded, nil
+	}
+}
+
+// All returns an iterator over the remaining values. Errors are yielded along the way, as returned by Decode:
+// *LineError can be skipped, any other error is yielded last.
+func (d *Decoder[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			v, err := d.Decode()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			var le *LineError
+			if err != nil && !errors.As(err, &le) {
+				yield(v, err)
+				return
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+func (d *Decoder[T]) report() {
+	if d.cfg.progress != nil {
+		d.cfg.progress(Progress{Lines: d.lines, Bytes: d.bytes})
+	}
+}
+
+// Encoder writes values as newline delimited JSON.
+type Encoder[T any] struct {
+	enc *json.Encoder
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder[T any](w io.Writer) *Encoder[T] {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &Encoder[T]{enc: enc}
+}
+
+// Encode writes the value followed by a newline.
+func (e *Encoder[T]) Encode(v T) error {
+	if err := e.enc.Encode(v); err != nil {
+		return fmt.Errorf("could not encode value: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/backoff"
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/pubsub"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	relayEvent = "outbox"
+
+	defaultBatchSize    = 100
+	defaultInterval     = time.Second
+	defaultLease        = 30 * time.Second
+	defaultMaxAttempts  = 10
+	defaultRetryInitial = time.Second
+	defaultRetryMax     = 5 * time.Minute
+)
+
+// Record is a message stored in the outbox, waiting to be published.
+type Record struct {
+	ID        string
+	Topic     string
+	Key       []byte
+	Data      []byte
+	Headers   map[string]string
+	CreatedAt time.Time
+	// Attempts is the number of failed attempts to publish the record.
+	Attempts int
+}
+
+// Store stores the records of the outbox. Records are written in the same transaction as the business data,
+// see SQLStore.Add, and claimed by the Relay which publishes them.
+type Store interface {
+	// Claim leases up to limit pending records, oldest first, so that no other relay claims them until now+lease.
+	Claim(ctx context.Context, limit int, now time.Time, lease time.Duration) ([]Record, error)
+	// MarkSent records that the record was published.
+	MarkSent(ctx context.Context, id string, at time.Time) error
+	// MarkFailed records a failed attempt to publish the record, which must not be claimed again before retryAt,
+	// or ever again if dead is true.
+	MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time, dead bool) error
+}
+
+// Option customises a Relay.
+type Option func(*Relay)
+
+// WithBatchSize claims up to n records at a time, 100 by default.
+func WithBatchSize(n int) Option {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// WithInterval polls the store every d when there are no pending records, every second by default.
+// Full batches are followed by another poll straight away.
+func WithInterval(d time.Duration) Option {
+	return func(r *Relay) {
+		r.interval = d
+	}
+}
+
+// WithLease leases the claimed records for d, 30s by default: records whose relay crashed are claimed again
+// once their lease expires. It must be longer than the time taken to publish a batch.
+func WithLease(d time.Duration) Option {
+	return func(r *Relay) {
+		r.lease = d
+	}
+}
+
+// WithMaxAttempts gives up publishing a record after n failed attempts, marking it as dead, 10 by default.
+func WithMaxAttempts(n int) Option {
+	return func(r *Relay) {
+		r.maxAttempts = n
+	}
+}
+
+// WithRetryBackoff waits initial before retrying a failed record, doubling the delay at every attempt up to max.
+// It defaults to 1s and 5m.
+func WithRetryBackoff(initial, max time.Duration) Option {
+	return func(r *Relay) {
+		r.retryInitial = initial
+		r.retryMax = max
+	}
+}
+
+// WithDeadLetter calls fn with the records marked as dead, along with the last error, e.g. to publish them
+// to a dead letter topic or to alert.
+func WithDeadLetter(fn func(ctx context.Context, rec Record, err error)) Option {
+	return func(r *Relay) {
+		r.deadLetter = fn
+	}
+}
+
+// WithLogger logs the failures to publish and the records marked as dead.
+func WithLogger(log logger.Logger) Option {
+	return func(r *Relay) {
+		r.log = log
+	}
+}
+
+// WithMetrics registers the relayed records and publishing lag metrics with the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(r *Relay) {
+		r.relayed = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "outbox_records_relayed_total",
+			Help: "Number of outbox records relayed by topic and result.",
+		}, []string{"topic", "result"}))
+		r.lag = metrics.Register(registerer, prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "outbox_publish_lag_seconds",
+			Help:    "Time elapsed between the creation and the publication of outbox records.",
+			Buckets: prometheus.DefBuckets,
+		}))
+	}
+}
+
+// WithClock uses the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(r *Relay) {
+		r.clock = c
+	}
+}
+
+// Relay publishes the records of the outbox, with at-least-once semantics: a record is marked as sent only once
+// published, so a crash in between publishes it again. Messages carry the ID of their record, which consumers
+// can use to discard duplicates.
+type Relay struct {
+	store        Store
+	pub          pubsub.Publisher
+	batchSize    int
+	interval     time.Duration
+	lease        time.Duration
+	maxAttempts  int
+	retryInitial time.Duration
+	retryMax     time.Duration
+	deadLetter   func(ctx context.Context, rec Record, err error)
+	log          logger.Logger
+	clock        clock.Clock
+
+	relayed *prometheus.CounterVec
+	lag     prometheus.Histogram
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewRelay returns a Relay publishing the records of the store with the publisher in input.
+func NewRelay(store Store, pub pubsub.Publisher, opts ...Option) *Relay {
+	r := &Relay{
+		store:        store,
+		pub:          pub,
+		batchSize:    defaultBatchSize,
+		interval:     defaultInterval,
+		lease:        defaultLease,
+		maxAttempts:  defaultMaxAttempts,
+		retryInitial: defaultRetryInitial,
+		retryMax:     defaultRetryMax,
+		clock:        clock.New(),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run relays the records until ctx is done or the relay is stopped with Stop, which lets the batch in flight complete.
+// It is meant to be run in its own goroutine.
+func (r *Relay) Run(ctx context.Context) {
+	defer close(r.done)
+	ticker := r.clock.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		n, err := r.RelayBatch(ctx)
+		if err != nil && ctx.Err() == nil && r.log != nil {
+			r.log.Event(relayEvent).Error("Could not relay outbox records", err)
+		}
+		if n == r.batchSize && err == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.stop:
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// RelayBatch claims and publishes a batch of records, returning how many were claimed.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	records, err := r.store.Claim(ctx, r.batchSize, r.clock.Now(), r.lease)
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, rec := range records {
+		if err := r.relay(ctx, rec); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(records), errors.Join(errs...)
+}
+
+// relay publishes the record, recording the outcome. It only returns the errors of the store.
+func (r *Relay) relay(ctx context.Context, rec Record) error {
+	msg := &pubsub.Message{ID: rec.ID, Topic: rec.Topic, Key: rec.Key, Data: rec.Data, Headers: rec.Headers}
+	err := r.pub.Publish(ctx, rec.Topic, msg)
+	now := r.clock.Now()
+	if err == nil {
+		r.observe(rec, "sent", now)
+		return r.store.MarkSent(ctx, rec.ID, now)
+	}
+
+	attempts := rec.Attempts + 1
+	dead := r.maxAttempts > 0 && attempts >= r.maxAttempts
+	if dead {
+		r.observe(rec, "dead", now)
+	} else {
+		r.observe(rec, "error", now)
+	}
+	if r.log != nil {
+		l := r.log.Event(relayEvent).Topic(rec.Topic)
+		if dead {
+			l.Error(fmt.Sprintf("Giving up publishing outbox record %s after %d attempts", rec.ID, attempts), err)
+		} else {
+			l.Warn(fmt.Sprintf("Could not publish outbox record %s: %v", rec.ID, err))
+		}
+	}
+	if markErr := r.store.MarkFailed(ctx, rec.ID, err, now.Add(r.backoff(attempts)), dead); markErr != nil {
+		return markErr
+	}
+	if dead && r.deadLetter != nil {
+		r.deadLetter(ctx, rec, err)
+	}
+	return nil
+}
+
+func (r *Relay) observe(rec Record, result string, now time.Time) {
+	if r.relayed == nil {
+		return
+	}
+	r.relayed.WithLabelValues(rec.Topic, result).Inc()
+	if result == "sent" {
+		r.lag.Observe(now.Sub(rec.CreatedAt).Seconds())
+	}
+}
+
+// backoff returns how long to wait before retrying a record which failed the number of attempts in input.
+func (r *Relay) backoff(attempts int) time.Duration {
+	return backoff.Exponential(r.retryInitial, r.retryMax, 2).Delay(attempts, 0)
+}
+
+// Stop stops the relay, waiting for the batch in flight to complete or for ctx to be done.
+// It must only be called after Run.
+func (r *Relay) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not stop outbox relay: %w", ctx.Err())
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn stopping the relay within timeout.
+func (r *Relay) TerminationFn(timeout time.Duration) shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return r.Stop(ctx)
+	}
+}
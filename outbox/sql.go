@@ -0,0 +1,207 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/id"
+	"github.com/indiependente/pkg/pubsub"
+)
+
+const defaultTable = "outbox"
+
+// Schema is the PostgreSQL definition of the table used by SQLStore, to be adapted to other databases.
+const Schema = `CREATE TABLE IF NOT EXISTS outbox (
+	id           TEXT PRIMARY KEY,
+	topic        TEXT NOT NULL,
+	msg_key      BYTEA,
+	data         BYTEA NOT NULL,
+	headers      TEXT NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	last_error   TEXT NOT NULL DEFAULT '',
+	locked_until TIMESTAMPTZ,
+	sent_at      TIMESTAMPTZ,
+	dead         BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS outbox_pending ON outbox (created_at) WHERE sent_at IS NULL AND NOT dead;`
+
+// Execer executes statements, satisfied by *sql.Tx and *sql.DB.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// SQLOption customises a SQLStore.
+type SQLOption func(*SQLStore)
+
+// WithTable stores the records in the table in input, "outbox" by default.
+func WithTable(name string) SQLOption {
+	return func(s *SQLStore) {
+		s.table = name
+	}
+}
+
+// WithDollarPlaceholders uses $1, $2... placeholders, as PostgreSQL requires, rather than ?.
+func WithDollarPlaceholders() SQLOption {
+	return func(s *SQLStore) {
+		s.dollar = true
+	}
+}
+
+// WithRowLocking locks the claimed rows with FOR UPDATE SKIP LOCKED, supported by PostgreSQL and MySQL 8,
+// so that concurrent relays do not contend for the same records.
+func WithRowLocking() SQLOption {
+	return func(s *SQLStore) {
+		s.skipLocked = true
+	}
+}
+
+// SQLStore is a Store backed by a SQL table, see Schema. Records are claimed with a lease,
+// so that several relays can run against the same table.
+type SQLStore struct {
+	db         *sql.DB
+	table      string
+	dollar     bool
+	skipLocked bool
+}
+
+// compile time interface check.
+var _ Store = &SQLStore{}
+
+// NewSQLStore returns a store reading and writing records through the database handle in input.
+func NewSQLStore(db *sql.DB, opts ...SQLOption) *SQLStore {
+	s := &SQLStore{db: db, table: defaultTable}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add stores the messages to publish to the topic in input, executing with the transaction in input,
+// e.g. the one passed by dbx.DB.InTx, so that they are published if and only if the transaction commits.
+// Messages without an ID get a time ordered one, which consumers can use to discard duplicates.
+func (s *SQLStore) Add(ctx context.Context, tx Execer, topic string, msgs ...*pubsub.Message) error {
+	query := s.rebind(`INSERT INTO ` + s.table + ` (id, topic, msg_key, data, headers, created_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	now := time.Now().UTC()
+	for _, msg := range msgs {
+		if msg.ID == "" {
+			msg.ID = id.NewUUIDv7()
+		}
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return fmt.Errorf("could not encode headers: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, query, msg.ID, topic, msg.Key, msg.Data, string(headers), now); err != nil {
+			return fmt.Errorf("could not add message to outbox: %w", err)
+		}
+	}
+	return nil
+}
+
+// Claim leases up to limit pending records, oldest first, until now+lease.
+func (s *SQLStore) Claim(ctx context.Context, limit int, now time.Time, lease time.Duration) ([]Record, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `SELECT id, topic, msg_key, data, headers, created_at, attempts FROM ` + s.table +
+		` WHERE sent_at IS NULL AND dead = ? AND (locked_until IS NULL OR locked_until < ?) ORDER BY created_at, id LIMIT ?`
+	if s.skipLocked {
+		query += ` FOR UPDATE SKIP LOCKED`
+	}
+	rows, err := tx.QueryContext(ctx, s.rebind(query), false, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not query outbox: %w", err)
+	}
+	var records []Record
+	for rows.Next() {
+		var (
+			r       Record
+			headers string
+		)
+		if err := rows.Scan(&r.ID, &r.Topic, &r.Key, &r.Data, &headers, &r.CreatedAt, &r.Attempts); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("could not scan outbox record: %w", err)
+		}
+		if err := json.Unmarshal([]byte(headers), &r.Headers); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("could not decode headers of %s: %w", r.ID, err)
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not query outbox: %w", err)
+	}
+
+	// The lease is only taken if no other relay took it in the meantime, for databases without row locking.
+	lock := s.rebind(`UPDATE ` + s.table + ` SET locked_until = ? WHERE id = ? AND (locked_until IS NULL OR locked_until < ?)`)
+	claimed := records[:0]
+	for _, r := range records {
+		res, err := tx.ExecContext(ctx, lock, now.Add(lease), r.ID, now)
+		if err != nil {
+			return nil, fmt.Errorf("could not lease outbox record %s: %w", r.ID, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			continue
+		}
+		claimed = append(claimed, r)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return claimed, nil
+}
+
+// MarkSent records that the record was published.
+func (s *SQLStore) MarkSent(ctx context.Context, id string, at time.Time) error {
+	query := s.rebind(`UPDATE ` + s.table + ` SET sent_at = ?, locked_until = NULL WHERE id = ?`)
+	if _, err := s.db.ExecContext(ctx, query, at, id); err != nil {
+		return fmt.Errorf("could not mark outbox record %s as sent: %w", id, err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt to publish the record, releasing its lease until retryAt,
+// or for good if dead is true.
+func (s *SQLStore) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time, dead bool) error {
+	query := s.rebind(`UPDATE ` + s.table + ` SET attempts = attempts + 1, last_error = ?, locked_until = ?, dead = ? WHERE id = ?`)
+	if _, err := s.db.ExecContext(ctx, query, cause.Error(), retryAt, dead, id); err != nil {
+		return fmt.Errorf("could not mark outbox record %s as failed: %w", id, err)
+	}
+	return nil
+}
+
+// Purge deletes the records sent before the time in input, returning how many were deleted.
+func (s *SQLStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	query := s.rebind(`DELETE FROM ` + s.table + ` WHERE sent_at IS NOT NULL AND sent_at < ?`)
+	res, err := s.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("could not purge outbox: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// rebind replaces the ? placeholders with $1, $2... if needed.
+func (s *SQLStore) rebind(query string) string {
+	if !s.dollar {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
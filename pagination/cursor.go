@@ -0,0 +1,103 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/indiependente/pkg/errors"
+)
+
+// ErrInvalidCursor is wrapped by the errors returned when decoding a cursor which was tampered with,
+// signed with another key or is malformed. It maps onto errors.Invalid, hence a 400 Bad Request.
+var ErrInvalidCursor = stderrors.New("invalid cursor")
+
+// Direction is the direction a cursor moves in.
+type Direction int
+
+const (
+	// Forward moves to the items after the position of the cursor.
+	Forward Direction = iota
+	// Backward moves to the items before the position of the cursor.
+	Backward
+)
+
+// MinSecretSize is the minimum size of the secrets signing cursors.
+const MinSecretSize = 32
+
+// cursor is the payload of an encoded cursor.
+type cursor struct {
+	Position  json.RawMessage `json:"p"`
+	Direction Direction       `json:"d,omitempty"`
+}
+
+// Codec encodes keyset positions into opaque cursors and back. Cursors are signed with HMAC-SHA256,
+// so that clients cannot forge positions, e.g. to skip filters applied when the cursor was created.
+type Codec struct {
+	secret []byte
+}
+
+// NewCodec returns a Codec signing cursors with the secret in input, which must be at least MinSecretSize bytes
+// long: cursors signed with shorter secrets could be forged.
+func NewCodec(secret []byte) (*Codec, error) {
+	if len(secret) < MinSecretSize {
+		return nil, fmt.Errorf("cursor secret must be at least %d bytes long, got %d", MinSecretSize, len(secret))
+	}
+	return &Codec{secret: append([]byte(nil), secret...)}, nil
+}
+
+// MustNewCodec is like NewCodec but panics on errors.
+func MustNewCodec(secret []byte) *Codec {
+	c, err := NewCodec(secret)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Encode returns the cursor pointing at the position in input, e.g. a struct holding the sort keys
+// of the last item of a page, moving in the direction in input.
+func (c *Codec) Encode(position interface{}, dir Direction) (string, error) {
+	p, err := json.Marshal(position)
+	if err != nil {
+		return "", fmt.Errorf("could not encode cursor position: %w", err)
+	}
+	payload, err := json.Marshal(cursor{Position: p, Direction: dir})
+	if err != nil {
+		return "", fmt.Errorf("could not encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(append(payload, c.sign(payload)...)), nil
+}
+
+// Decode decodes the position of the cursor into the value pointed to by position, returning its direction.
+func (c *Codec) Decode(s string, position interface{}) (Direction, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) < sha256.Size {
+		return Forward, invalidCursor()
+	}
+	payload, mac := b[:len(b)-sha256.Size], b[len(b)-sha256.Size:]
+	if !hmac.Equal(mac, c.sign(payload)) {
+		return Forward, invalidCursor()
+	}
+	var cur cursor
+	if err := json.Unmarshal(payload, &cur); err != nil {
+		return Forward, invalidCursor()
+	}
+	if err := json.Unmarshal(cur.Position, position); err != nil {
+		return Forward, invalidCursor()
+	}
+	return cur.Direction, nil
+}
+
+func (c *Codec) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+func invalidCursor() error {
+	return errors.Wrap(ErrInvalidCursor, errors.Invalid, "")
+}
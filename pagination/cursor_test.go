@@ -0,0 +1,92 @@
+package pagination
+
+import (
+	stderrors "errors"
+	"strings"
+	"testing"
+)
+
+type position struct {
+	CreatedAt int64  `json:"c"`
+	ID        string `json:"i"`
+}
+
+func TestNewCodec(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  []byte
+		wantErr bool
+	}{
+		{name: "nil secret", secret: nil, wantErr: true},
+		{name: "empty secret", secret: []byte{}, wantErr: true},
+		{name: "short secret", secret: make([]byte, MinSecretSize-1), wantErr: true},
+		{name: "valid secret", secret: make([]byte, MinSecretSize)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCodec(tt.secret); (err != nil) != tt.wantErr {
+				t.Fatalf("NewCodec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	codec := MustNewCodec([]byte(strings.Repeat("k", MinSecretSize)))
+	other := MustNewCodec([]byte(strings.Repeat("o", MinSecretSize)))
+	want := position{CreatedAt: 1700000000, ID: "item-42"}
+	cursor, err := codec.Encode(want, Backward)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := other.Encode(position{CreatedAt: 0, ID: "item-1"}, Forward)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(cursor)
+	if tampered[5] == 'A' {
+		tampered[5] = 'B'
+	} else {
+		tampered[5] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		cursor  string
+		wantErr bool
+	}{
+		{name: "valid", cursor: cursor},
+		{name: "tampered", cursor: string(tampered), wantErr: true},
+		{name: "signed with another secret", cursor: forged, wantErr: true},
+		{name: "truncated", cursor: cursor[:len(cursor)-4], wantErr: true},
+		{name: "not base64", cursor: "not a cursor!", wantErr: true},
+		{name: "empty", cursor: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got position
+			dir, err := codec.Decode(tt.cursor, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !stderrors.Is(err, ErrInvalidCursor) {
+					t.Fatalf("Decode() error = %v, want %v", err, ErrInvalidCursor)
+				}
+				return
+			}
+			if got != want || dir != Backward {
+				t.Fatalf("Decode() = %+v, %v, want %+v, %v", got, dir, want, Backward)
+			}
+		})
+	}
+}
+
+func TestMustNewCodecPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustNewCodec() did not panic with a short secret")
+		}
+	}()
+	MustNewCodec([]byte("short"))
+}
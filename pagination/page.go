@@ -0,0 +1,116 @@
+package pagination
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/indiependente/pkg/httpx/respond"
+)
+
+// Page is the response envelope of a page of items.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+
+	// next and prev hold the query parameters of the adjacent pages, for the Link header.
+	next, prev url.Values
+}
+
+// Keyset returns the page of items fetched for the parameters in input, computing the cursors of the adjacent pages
+// from the positions of the first and last items, as returned by position.
+// Items must be fetched with a limit of p.Limit+1, so that the extra item reveals whether there are more,
+// in the direction of the request cursor: in ascending order for Forward cursors and for the first page,
+// in descending order for Backward ones, in which case they are reversed.
+func Keyset[T any](items []T, p Params, dir Direction, codec *Codec, position func(T) interface{}) (*Page[T], error) {
+	more := len(items) > p.Limit
+	if more {
+		items = items[:p.Limit]
+	}
+	if dir == Backward {
+		items = slices.Clone(items)
+		slices.Reverse(items)
+	}
+	page := &Page[T]{Items: items, Limit: p.Limit}
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	hasNext, hasPrev := more, p.Cursor != ""
+	if dir == Backward {
+		hasNext, hasPrev = true, more
+	}
+	if hasNext {
+		c, err := codec.Encode(position(items[len(items)-1]), Forward)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = c
+		page.next = url.Values{cursorParam: {c}}
+	}
+	if hasPrev {
+		c, err := codec.Encode(position(items[0]), Backward)
+		if err != nil {
+			return nil, err
+		}
+		page.PrevCursor = c
+		page.prev = url.Values{cursorParam: {c}}
+	}
+	return page, nil
+}
+
+// Offset returns the page of items fetched for the parameters in input, out of total items.
+func Offset[T any](items []T, p Params, total int64) *Page[T] {
+	offset := p.Offset
+	page := &Page[T]{Items: items, Limit: p.Limit, Offset: &offset, Total: &total}
+	if int64(p.Offset+len(items)) < total {
+		page.next = url.Values{offsetParam: {strconv.Itoa(p.Offset + p.Limit)}}
+	}
+	if p.Offset > 0 {
+		page.prev = url.Values{offsetParam: {strconv.Itoa(max(p.Offset-p.Limit, 0))}}
+	}
+	return page
+}
+
+// WithTotal sets the total number of items, e.g. for keyset pages of small collections.
+func (p *Page[T]) WithTotal(total int64) *Page[T] {
+	p.Total = &total
+	return p
+}
+
+// Write writes the page as a 200 OK JSON response with respond.JSON, along with an RFC 8288 Link header
+// pointing at the adjacent pages, derived from the URL of the request.
+func Write[T any](w http.ResponseWriter, r *http.Request, page *Page[T]) {
+	var links []string
+	for _, l := range []struct {
+		rel   string
+		query url.Values
+	}{{"next", page.next}, {"prev", page.prev}} {
+		if l.query == nil {
+			continue
+		}
+		u := *r.URL
+		q := u.Query()
+		q.Del(cursorParam)
+		q.Del(offsetParam)
+		for k, v := range l.query {
+			q[k] = v
+		}
+		q.Set(limitParam, strconv.Itoa(page.Limit))
+		u.RawQuery = q.Encode()
+		links = append(links, "<"+u.RequestURI()+`>; rel="`+l.rel+`"`)
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	respond.JSON(w, http.StatusOK, page)
+}
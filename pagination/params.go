@@ -0,0 +1,79 @@
+package pagination
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/indiependente/pkg/errors"
+)
+
+const (
+	defaultLimit    = 20
+	defaultMaxLimit = 100
+
+	limitParam  = "limit"
+	offsetParam = "offset"
+	cursorParam = "cursor"
+)
+
+// Params are the pagination parameters of a request.
+type Params struct {
+	Limit  int
+	Offset int
+	// Cursor is the opaque cursor sent by the client, if any, to be decoded with a Codec.
+	Cursor string
+}
+
+// Option customises the parsing of the parameters.
+type Option func(*config)
+
+type config struct {
+	defaultLimit int
+	maxLimit     int
+}
+
+// WithDefaultLimit sets the limit of requests which do not specify any, 20 by default.
+func WithDefaultLimit(n int) Option {
+	return func(c *config) {
+		c.defaultLimit = n
+	}
+}
+
+// WithMaxLimit caps the limit requested by clients, 100 by default. Larger limits are lowered to n.
+func WithMaxLimit(n int) Option {
+	return func(c *config) {
+		c.maxLimit = n
+	}
+}
+
+// ParseParams parses the limit, offset and cursor query parameters of the request.
+// Limits and offsets which are not non-negative integers result in an errors.Invalid error, hence a 400 Bad Request
+// when written with respond.Error.
+func ParseParams(r *http.Request, opts ...Option) (Params, error) {
+	cfg := &config{defaultLimit: defaultLimit, maxLimit: defaultMaxLimit}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	q := r.URL.Query()
+	p := Params{Limit: cfg.defaultLimit, Cursor: q.Get(cursorParam)}
+
+	if v := q.Get(limitParam); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, errors.New(errors.Invalid, fmt.Sprintf("%s must be a positive integer", limitParam))
+		}
+		p.Limit = n
+	}
+	if cfg.maxLimit > 0 && p.Limit > cfg.maxLimit {
+		p.Limit = cfg.maxLimit
+	}
+	if v := q.Get(offsetParam); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, errors.New(errors.Invalid, fmt.Sprintf("%s must be a non-negative integer", offsetParam))
+		}
+		p.Offset = n
+	}
+	return p, nil
+}
@@ -0,0 +1,172 @@
+package panicguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/indiependente/pkg/backoff"
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/logger"
+)
+
+const guardEvent = "panicguard"
+
+// ErrTooManyRestarts is returned by GoRestart when the function failed more times than Backoff.MaxRestarts allows.
+var ErrTooManyRestarts = errors.New("too many restarts")
+
+// Reporter is notified of the panics recovered by the guard, e.g. to send them to an error tracking service.
+type Reporter interface {
+	ReportPanic(ctx context.Context, name string, p *conc.PanicError)
+}
+
+// ReporterFunc adapts a function to the Reporter interface.
+type ReporterFunc func(ctx context.Context, name string, p *conc.PanicError)
+
+// ReportPanic calls f(ctx, name, p).
+func (f ReporterFunc) ReportPanic(ctx context.Context, name string, p *conc.PanicError) {
+	f(ctx, name, p)
+}
+
+// Backoff defines the delays between restarts, growing exponentially from Initial up to Max.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// MaxRestarts stops restarting after the given number of consecutive failures, unlimited if 0.
+	MaxRestarts int
+	// ResetAfter starts the backoff over when the function ran for at least the given duration before failing,
+	// so that rare failures of long-running functions are not penalised. Disabled if 0.
+	ResetAfter time.Duration
+}
+
+// DefaultBackoff restarts after 100ms, doubling the delay up to 30s, without limits.
+var DefaultBackoff = Backoff{
+	Initial:    100 * time.Millisecond,
+	Max:        30 * time.Second,
+	Multiplier: 2,
+	ResetAfter: time.Minute,
+}
+
+// delay returns how long to wait before the restart in input, counted from 1.
+func (b Backoff) delay(restart int) time.Duration {
+	return backoff.Exponential(b.Initial, b.Max, b.Multiplier).Delay(restart, 0)
+}
+
+// Option customises Go and GoRestart.
+type Option func(*config)
+
+type config struct {
+	name      string
+	reporters []Reporter
+	clock     clock.Clock
+}
+
+// WithName names the goroutine in the logs and reports.
+func WithName(name string) Option {
+	return func(c *config) {
+		c.name = name
+	}
+}
+
+// WithReporter notifies the reporter of every recovered panic.
+func WithReporter(r Reporter) Option {
+	return func(c *config) {
+		c.reporters = append(c.reporters, r)
+	}
+}
+
+// WithClock waits between restarts with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(cl clock.Clock) Option {
+	return func(c *config) {
+		c.clock = cl
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{name: "goroutine", clock: clock.New()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Go runs fn in a new goroutine, recovering a panic into a *conc.PanicError, which is logged along with its stack
+// trace and sent to the reporters. The returned channel receives the outcome of fn, and is then closed.
+// log may be nil.
+func Go(ctx context.Context, log logger.Logger, fn func(ctx context.Context) error, opts ...Option) <-chan error {
+	cfg := newConfig(opts)
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		done <- cfg.run(ctx, log, fn)
+	}()
+	return done
+}
+
+// GoRestart runs fn in a new goroutine as Go does, restarting it after the delays of the backoff every time
+// it panics or returns an error, until it returns nil, ctx is done or the backoff allows no more restarts.
+// The returned channel receives the final outcome, and is then closed.
+func GoRestart(ctx context.Context, log logger.Logger, fn func(ctx context.Context) error, backoff Backoff, opts ...Option) <-chan error {
+	cfg := newConfig(opts)
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		done <- cfg.supervise(ctx, log, fn, backoff)
+	}()
+	return done
+}
+
+func (c *config) supervise(ctx context.Context, log logger.Logger, fn func(ctx context.Context) error, backoff Backoff) error {
+	failures := 0
+	for {
+		start := c.clock.Now()
+		err := c.run(ctx, log, fn)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if backoff.ResetAfter > 0 && c.clock.Since(start) >= backoff.ResetAfter {
+			failures = 0
+		}
+		failures++
+		if backoff.MaxRestarts > 0 && failures > backoff.MaxRestarts {
+			if log != nil {
+				log.Event(guardEvent).Component(c.name).Error("Goroutine failed too many times, giving up", err)
+			}
+			return fmt.Errorf("%w: %d failures, last error: %w", ErrTooManyRestarts, failures, err)
+		}
+
+		delay := backoff.delay(failures)
+		if log != nil {
+			log.Event(guardEvent).Component(c.name).Duration(delay).Err(err).Warn("Goroutine failed, restarting")
+		}
+		timer := c.clock.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C():
+		}
+	}
+}
+
+// run calls fn, logging and reporting a panic.
+func (c *config) run(ctx context.Context, log logger.Logger, fn func(ctx context.Context) error) error {
+	err := conc.Catch(func() error { return fn(ctx) })
+	var pe *conc.PanicError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	if log != nil {
+		logger.WithTrace(ctx, log.Event(guardEvent).Component(c.name).Stack(string(pe.Stack))).Error("Goroutine panicked", err)
+	}
+	for _, r := range c.reporters {
+		r.ReportPanic(ctx, c.name, pe)
+	}
+	return err
+}
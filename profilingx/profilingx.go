@@ -0,0 +1,253 @@
+package profilingx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/buildinfo"
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/shutdown"
+)
+
+const (
+	profilingEvent = "profiling"
+
+	defaultInterval    = time.Minute
+	defaultCPUDuration = 10 * time.Second
+)
+
+// ErrNoSink is returned by Start when no sink is configured.
+var ErrNoSink = errors.New("no profile sink configured")
+
+// ProfileType is the kind of a profile.
+type ProfileType string
+
+// Types of the profiles captured.
+const (
+	CPU       ProfileType = "cpu"
+	Heap      ProfileType = "heap"
+	Goroutine ProfileType = "goroutine"
+	Mutex     ProfileType = "mutex"
+	Block     ProfileType = "block"
+)
+
+// Profile is a profile captured between Start and End, as gzipped pprof protobuf.
+type Profile struct {
+	Type   ProfileType
+	Start  time.Time
+	End    time.Time
+	Data   []byte
+	Labels map[string]string
+}
+
+// Sink ships the profiles, e.g. to a profiling backend or to a local directory.
+// Implementations must be safe for concurrent use.
+type Sink interface {
+	Write(ctx context.Context, p Profile) error
+}
+
+// SinkFunc is a function implementing Sink.
+type SinkFunc func(ctx context.Context, p Profile) error
+
+// Write calls f.
+func (f SinkFunc) Write(ctx context.Context, p Profile) error {
+	return f(ctx, p)
+}
+
+// Option customises the Profiler.
+type Option func(*Profiler)
+
+// WithSink ships the profiles to the sink in input. Several sinks can be used.
+func WithSink(s Sink) Option {
+	return func(p *Profiler) {
+		p.sinks = append(p.sinks, s)
+	}
+}
+
+// WithTypes captures the profiles of the types in input instead of CPU, heap and goroutine.
+// Mutex and block profiles require runtime.SetMutexProfileFraction and runtime.SetBlockProfileRate.
+func WithTypes(types ...ProfileType) Option {
+	return func(p *Profiler) {
+		p.types = types
+	}
+}
+
+// WithInterval captures the profiles every d, one minute by default.
+func WithInterval(d time.Duration) Option {
+	return func(p *Profiler) {
+		p.interval = d
+	}
+}
+
+// WithCPUDuration profiles the CPU for d at every interval, 10s by default, to bound the overhead.
+func WithCPUDuration(d time.Duration) Option {
+	return func(p *Profiler) {
+		p.cpuDuration = d
+	}
+}
+
+// WithLabels adds the labels in input to the profiles, on top of the service and build ones.
+func WithLabels(labels map[string]string) Option {
+	return func(p *Profiler) {
+		maps.Copy(p.labels, labels)
+	}
+}
+
+// WithService labels the profiles with the name of the service.
+func WithService(name string) Option {
+	return func(p *Profiler) {
+		p.labels["service"] = name
+	}
+}
+
+// WithLogger logs the failures to capture or ship the profiles.
+func WithLogger(log logger.Logger) Option {
+	return func(p *Profiler) {
+		p.log = log
+	}
+}
+
+// WithClock schedules the captures with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(p *Profiler) {
+		p.clock = c
+	}
+}
+
+// Profiler captures profiles periodically, shipping them to its sinks.
+type Profiler struct {
+	sinks       []Sink
+	types       []ProfileType
+	interval    time.Duration
+	cpuDuration time.Duration
+	labels      map[string]string
+	log         logger.Logger
+	clock       clock.Clock
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Start starts capturing profiles in the background until ctx is done or the profiler is stopped with Stop.
+// Profiles are labelled with the version, commit and Go version of the buildinfo package.
+func Start(ctx context.Context, opts ...Option) (*Profiler, error) {
+	bi := buildinfo.Get()
+	p := &Profiler{
+		types:       []ProfileType{CPU, Heap, Goroutine},
+		interval:    defaultInterval,
+		cpuDuration: defaultCPUDuration,
+		labels:      map[string]string{"version": bi.Version, "commit": bi.Commit, "go_version": bi.GoVersion},
+		clock:       clock.New(),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if len(p.sinks) == 0 {
+		return nil, ErrNoSink
+	}
+	for k, v := range p.labels {
+		if v == "" {
+			delete(p.labels, k)
+		}
+	}
+	if p.cpuDuration > p.interval {
+		p.cpuDuration = p.interval
+	}
+	go p.run(ctx)
+	return p, nil
+}
+
+func (p *Profiler) run(ctx context.Context) {
+	defer close(p.done)
+	ticker := p.clock.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.capture(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// capture captures and ships a profile of every type.
+func (p *Profiler) capture(ctx context.Context) {
+	var errs []error
+	for _, t := range p.types {
+		prof, err := p.profile(ctx, t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, s := range p.sinks {
+			if err := s.Write(ctx, prof); err != nil {
+				errs = append(errs, fmt.Errorf("could not ship %s profile: %w", t, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil && ctx.Err() == nil && p.log != nil {
+		p.log.Event(profilingEvent).Error("Could not capture profiles", err)
+	}
+}
+
+// profile captures a profile of the type in input. CPU profiles last for the CPU duration, unless interrupted.
+func (p *Profiler) profile(ctx context.Context, t ProfileType) (Profile, error) {
+	var buf bytes.Buffer
+	start := p.clock.Now()
+	if t == CPU {
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return Profile{}, fmt.Errorf("could not start CPU profile: %w", err)
+		}
+		timer := p.clock.NewTimer(p.cpuDuration)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+		case <-p.stop:
+		}
+		timer.Stop()
+		pprof.StopCPUProfile()
+	} else {
+		prof := pprof.Lookup(string(t))
+		if prof == nil {
+			return Profile{}, fmt.Errorf("unknown profile type %s", t)
+		}
+		if err := prof.WriteTo(&buf, 0); err != nil {
+			return Profile{}, fmt.Errorf("could not write %s profile: %w", t, err)
+		}
+	}
+	return Profile{Type: t, Start: start, End: p.clock.Now(), Data: buf.Bytes(), Labels: maps.Clone(p.labels)}, nil
+}
+
+// Stop stops capturing profiles, interrupting the CPU profile in progress, and waits for the profiles captured to be
+// shipped, or for ctx to be done.
+func (p *Profiler) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not stop profiler: %w", ctx.Err())
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn stopping the profiler within timeout.
+func (p *Profiler) TerminationFn(timeout time.Duration) shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return p.Stop(ctx)
+	}
+}
@@ -0,0 +1,175 @@
+package profilingx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/http/client"
+)
+
+// DirSink writes the profiles to a local directory, as <type>-<unix nano>.pb.gz files readable by go tool pprof,
+// removing the ones older than the retention.
+type DirSink struct {
+	dir       string
+	retention time.Duration
+
+	mu sync.Mutex
+}
+
+// compile time interface check.
+var _ Sink = &DirSink{}
+
+// NewDirSink returns a DirSink writing to dir, created if missing, and keeping the profiles for retention,
+// forever if zero.
+func NewDirSink(dir string, retention time.Duration) (*DirSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create profiles directory: %w", err)
+	}
+	return &DirSink{dir: dir, retention: retention}, nil
+}
+
+// Write writes the profile to a new file, then removes the expired ones.
+func (s *DirSink) Write(_ context.Context, p Profile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := filepath.Join(s.dir, fmt.Sprintf("%s-%d.pb.gz", p.Type, p.End.UnixNano()))
+	if err := os.WriteFile(name, p.Data, 0o644); err != nil {
+		return fmt.Errorf("could not write profile: %w", err)
+	}
+	if s.retention <= 0 {
+		return nil
+	}
+	return s.prune(p.End.Add(-s.retention))
+}
+
+// prune removes the profiles captured before the cutoff. It must be called with the lock held.
+func (s *DirSink) prune(cutoff time.Time) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("could not list profiles: %w", err)
+	}
+	for _, e := range entries {
+		base, ok := strings.CutSuffix(e.Name(), ".pb.gz")
+		if !ok || e.IsDir() {
+			continue
+		}
+		i := strings.LastIndexByte(base, '-')
+		if i < 0 {
+			continue
+		}
+		ts, err := strconv.ParseInt(base[i+1:], 10, 64)
+		if err != nil || !time.Unix(0, ts).Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove expired profile: %w", err)
+		}
+	}
+	return nil
+}
+
+// HTTPSink posts the profiles to an HTTP endpoint, e.g. a Parca or Pyroscope compatible ingester.
+type HTTPSink struct {
+	client  *client.Client
+	request func(p Profile) (string, io.Reader, string, error)
+}
+
+// compile time interface check.
+var _ Sink = &HTTPSink{}
+
+// NewHTTPSink returns an HTTPSink posting the raw profiles to the URL in input, with the type, the start and end
+// unix timestamps and the labels as query parameters.
+func NewHTTPSink(c *client.Client, rawURL string) *HTTPSink {
+	return &HTTPSink{
+		client: c,
+		request: func(p Profile) (string, io.Reader, string, error) {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return "", nil, "", fmt.Errorf("could not parse URL: %w", err)
+			}
+			q := u.Query()
+			q.Set("type", string(p.Type))
+			q.Set("from", strconv.FormatInt(p.Start.Unix(), 10))
+			q.Set("until", strconv.FormatInt(p.End.Unix(), 10))
+			for k, v := range p.Labels {
+				q.Set(k, v)
+			}
+			u.RawQuery = q.Encode()
+			return u.String(), bytes.NewReader(p.Data), "application/octet-stream", nil
+		},
+	}
+}
+
+// NewPyroscopeSink returns an HTTPSink pushing the profiles to the ingest API of the Pyroscope server at baseURL,
+// under the application name in input, e.g. checkout.cpu{service=checkout,version=1.2.0} for the CPU profiles.
+func NewPyroscopeSink(c *client.Client, baseURL, app string) *HTTPSink {
+	return &HTTPSink{
+		client: c,
+		request: func(p Profile) (string, io.Reader, string, error) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, err := mw.CreateFormFile("profile", "profile.pprof")
+			if err != nil {
+				return "", nil, "", fmt.Errorf("could not create profile form: %w", err)
+			}
+			if _, err := fw.Write(p.Data); err != nil {
+				return "", nil, "", fmt.Errorf("could not write profile form: %w", err)
+			}
+			if err := mw.Close(); err != nil {
+				return "", nil, "", fmt.Errorf("could not close profile form: %w", err)
+			}
+			q := url.Values{}
+			q.Set("name", pyroscopeName(app, p))
+			q.Set("from", strconv.FormatInt(p.Start.Unix(), 10))
+			q.Set("until", strconv.FormatInt(p.End.Unix(), 10))
+			q.Set("format", "pprof")
+			q.Set("spyName", "gospy")
+			return strings.TrimSuffix(baseURL, "/") + "/ingest?" + q.Encode(), &body, mw.FormDataContentType(), nil
+		},
+	}
+}
+
+// pyroscopeName returns the name of the profile in the Pyroscope format, <app>.<type>{<labels>}.
+func pyroscopeName(app string, p Profile) string {
+	keys := make([]string, 0, len(p.Labels))
+	for k := range p.Labels {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	labels := make([]string, 0, len(keys))
+	for _, k := range keys {
+		labels = append(labels, k+"="+p.Labels[k])
+	}
+	return app + "." + string(p.Type) + "{" + strings.Join(labels, ",") + "}"
+}
+
+// Write posts the profile, returning an *client.HTTPError if it is not accepted.
+func (s *HTTPSink) Write(ctx context.Context, p Profile) error {
+	target, body, contentType, err := s.request(p)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(ctx, http.MethodPost, target,
+		client.WithBody(body),
+		client.WithHeader("Content-Type", contentType),
+		client.ExpectStatus(http.StatusOK, http.StatusAccepted, http.StatusNoContent),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,305 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/pubsub"
+	"github.com/indiependente/pkg/retry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	consumeEvent        = "consumer"
+	defaultDrainTimeout = 30 * time.Second
+)
+
+// Delivery is a message received from a Source, along with the functions settling it.
+type Delivery struct {
+	Message *pubsub.Message
+	// Ack acknowledges the message, so that it is not delivered again.
+	Ack func(ctx context.Context) error
+	// Nack rejects the message, so that the source delivers it again, possibly after a delay.
+	Nack func(ctx context.Context) error
+}
+
+// Source delivers messages to consume, e.g. an adapter over a broker client or a queue.
+type Source interface {
+	// Receive blocks until a message is available or ctx is done.
+	Receive(ctx context.Context) (*Delivery, error)
+}
+
+// SourceFunc is an adapter to allow the use of ordinary functions as Source.
+type SourceFunc func(ctx context.Context) (*Delivery, error)
+
+// Receive calls f(ctx).
+func (f SourceFunc) Receive(ctx context.Context) (*Delivery, error) {
+	return f(ctx)
+}
+
+// ErrSourceClosed is returned by the Source returned by FromChannel once its channel is closed.
+var ErrSourceClosed = errors.New("consumer: source closed")
+
+// FromChannel returns a Source receiving the messages from the channel in input, whose acknowledgements are no-ops,
+// e.g. for tests or in-memory queues. Run returns once the channel is closed and drained.
+func FromChannel(ch <-chan *pubsub.Message) Source {
+	noop := func(context.Context) error { return nil }
+	return SourceFunc(func(ctx context.Context) (*Delivery, error) {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return nil, ErrSourceClosed
+			}
+			return &Delivery{Message: msg, Ack: noop, Nack: noop}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+}
+
+// Option customises a consumer.
+type Option func(*config)
+
+type config struct {
+	concurrency  int
+	ordered      bool
+	timeout      time.Duration
+	drainTimeout time.Duration
+	retry        []retry.Option
+	deadLetter   func(ctx context.Context, msg *pubsub.Message, err error) error
+	log          logger.Logger
+	name         string
+
+	handled  *prometheus.CounterVec
+	duration prometheus.Observer
+	inFlight prometheus.Gauge
+}
+
+// WithConcurrency handles up to n messages at a time, 1 by default.
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		c.concurrency = n
+	}
+}
+
+// WithOrderedKeys handles the messages with the same key one at a time, in the order they are received,
+// while messages with different keys are still handled concurrently.
+func WithOrderedKeys() Option {
+	return func(c *config) {
+		c.ordered = true
+	}
+}
+
+// WithTimeout cancels the context of the handler after d.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithRetry retries failed messages in process with the options in input, e.g. retry.WithMaxAttempts and
+// retry.WithBackoff, before giving up on them. Errors wrapped with retry.Permanent are not retried.
+func WithRetry(opts ...retry.Option) Option {
+	return func(c *config) {
+		c.retry = opts
+	}
+}
+
+// WithDeadLetter calls fn with the messages the handler gave up on, e.g. to publish them to a dead letter topic,
+// acknowledging them if it returns nil. Without a dead letter function, such messages are rejected with Nack.
+func WithDeadLetter(fn func(ctx context.Context, msg *pubsub.Message, err error) error) Option {
+	return func(c *config) {
+		c.deadLetter = fn
+	}
+}
+
+// WithDrainTimeout lets the in-flight messages complete for up to d once the context of Run is done,
+// cancelling their handlers afterwards, 30s by default.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.drainTimeout = d
+	}
+}
+
+// WithLogger logs every message handled, along with its topic, duration and the error if any.
+func WithLogger(log logger.Logger) Option {
+	return func(c *config) {
+		c.log = log
+	}
+}
+
+// WithMetrics registers the handled messages, handling duration and in-flight messages metrics,
+// labeled by name, with the registerer in input.
+func WithMetrics(registerer prometheus.Registerer, name string) Option {
+	return func(c *config) {
+		c.name = name
+		c.handled = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "consumer_messages_handled_total",
+			Help: "Number of messages handled by consumer, topic and result.",
+		}, []string{"consumer", "topic", "result"}))
+		c.duration = metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "consumer_handle_duration_seconds",
+			Help:    "Duration of message handling, including retries.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"consumer"})).WithLabelValues(name)
+		c.inFlight = metrics.Register(registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "consumer_messages_in_flight",
+			Help: "Number of messages being handled.",
+		}, []string{"consumer"})).WithLabelValues(name)
+	}
+}
+
+// Run receives the messages from the source and handles them with the handler until ctx is done,
+// then stops receiving and drains the in-flight messages. Messages are acknowledged once handled,
+// and those the handler gave up on are passed to the dead letter function or rejected.
+// It returns nil once drained, or the first error returned by the source.
+func Run(ctx context.Context, src Source, h pubsub.Handler, opts ...Option) error {
+	cfg := &config{concurrency: 1, drainTimeout: defaultDrainTimeout}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+
+	// Handlers outlive ctx while draining, until the drain timeout.
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+
+	queues := make([]chan *Delivery, 1)
+	if cfg.ordered {
+		queues = make([]chan *Delivery, cfg.concurrency)
+	}
+	for i := range queues {
+		queues[i] = make(chan *Delivery)
+	}
+	var wg sync.WaitGroup
+	wg.Add(cfg.concurrency)
+	for i := 0; i < cfg.concurrency; i++ {
+		q := queues[i%len(queues)]
+		go func() {
+			defer wg.Done()
+			for d := range q {
+				handle(handlerCtx, cfg, h, d)
+			}
+		}()
+	}
+
+	err := receive(ctx, src, queues)
+	for _, q := range queues {
+		close(q)
+	}
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(cfg.drainTimeout):
+		cancelHandlers()
+		<-drained
+	}
+	if errors.Is(err, ErrSourceClosed) {
+		return nil
+	}
+	return err
+}
+
+// receive dispatches the deliveries to the queues until ctx is done or the source fails.
+func receive(ctx context.Context, src Source, queues []chan *Delivery) error {
+	for {
+		d, err := src.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("could not receive message: %w", err)
+		}
+		q := queues[0]
+		if len(queues) > 1 {
+			h := fnv.New32a()
+			h.Write(d.Message.Key)
+			q = queues[h.Sum32()%uint32(len(queues))] // nolint:gosec
+		}
+		select {
+		case q <- d:
+		case <-ctx.Done():
+			// The delivery was received but will not be handled: reject it so that it is delivered again.
+			_ = d.Nack(context.WithoutCancel(ctx))
+			return nil
+		}
+	}
+}
+
+// handle handles the delivery, retrying it if configured, and settles it.
+func handle(ctx context.Context, cfg *config, h pubsub.Handler, d *Delivery) {
+	if cfg.inFlight != nil {
+		cfg.inFlight.Inc()
+		defer cfg.inFlight.Dec()
+	}
+	msg := d.Message
+	start := time.Now()
+	attempt := func(ctx context.Context) error {
+		if cfg.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+			defer cancel()
+		}
+		return conc.Catch(func() error {
+			return h(ctx, msg)
+		})
+	}
+	var err error
+	if cfg.retry != nil {
+		err = retry.Do(ctx, attempt, cfg.retry...)
+	} else {
+		err = attempt(ctx)
+	}
+	elapsed := time.Since(start)
+	if cfg.duration != nil {
+		cfg.duration.Observe(elapsed.Seconds())
+	}
+
+	result := "success"
+	switch {
+	case err == nil:
+		err = d.Ack(ctx)
+		if err != nil {
+			result = "ack_error"
+		}
+	case cfg.deadLetter != nil:
+		result = "dead_letter"
+		if dlErr := cfg.deadLetter(ctx, msg, err); dlErr != nil {
+			result = "error"
+			err = errors.Join(err, fmt.Errorf("could not dead letter message: %w", dlErr))
+			_ = d.Nack(ctx)
+		} else {
+			_ = d.Ack(ctx)
+		}
+	default:
+		result = "error"
+		_ = d.Nack(ctx)
+	}
+	if cfg.handled != nil {
+		cfg.handled.WithLabelValues(cfg.name, msg.Topic, result).Inc()
+	}
+	if cfg.log != nil {
+		l := logger.WithTrace(ctx, cfg.log.Event(consumeEvent).Topic(msg.Topic).Duration(elapsed))
+		switch result {
+		case "success":
+			l.Info("Message handled")
+		case "dead_letter":
+			l.Warn(fmt.Sprintf("Message %s dead lettered: %v", msg.ID, err))
+		default:
+			l.Error("Could not handle message", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/pubsub"
+	"github.com/indiependente/pkg/retry"
+	"github.com/segmentio/kafka-go"
+)
+
+const defaultRetryBackoff = time.Second
+
+// Option customises the Kafka driver.
+type Option func(*config)
+
+type config struct {
+	groupID      string
+	retryBackoff time.Duration
+	writer       func(*kafka.Writer)
+	reader       func(*kafka.ReaderConfig)
+}
+
+// WithGroupID sets the consumer group the subscriptions join. It is required to subscribe.
+func WithGroupID(id string) Option {
+	return func(c *config) {
+		c.groupID = id
+	}
+}
+
+// WithRetryBackoff sets the maximum delay between the attempts to handle a failing message. Defaults to 1s.
+func WithRetryBackoff(d time.Duration) Option {
+	return func(c *config) {
+		c.retryBackoff = d
+	}
+}
+
+// WithWriter customises the underlying kafka.Writer.
+func WithWriter(fn func(*kafka.Writer)) Option {
+	return func(c *config) {
+		c.writer = fn
+	}
+}
+
+// WithReaderConfig customises the configuration of the underlying kafka.Reader of each subscription.
+func WithReaderConfig(fn func(*kafka.ReaderConfig)) Option {
+	return func(c *config) {
+		c.reader = fn
+	}
+}
+
+// Driver publishes and subscribes to Kafka topics.
+// Messages are committed once handled; since Kafka tracks offsets rather than single messages,
+// a failing message is retried with backoff until it succeeds or the subscription stops, blocking its partition.
+type Driver struct {
+	brokers []string
+	cfg     *config
+	writer  *kafka.Writer
+
+	mu      sync.Mutex
+	readers []*kafka.Reader
+	closed  bool
+}
+
+// compile time interface check.
+var (
+	_ pubsub.Publisher  = &Driver{}
+	_ pubsub.Subscriber = &Driver{}
+)
+
+// New returns a Kafka driver connecting to the brokers in input.
+func New(brokers []string, opts ...Option) *Driver {
+	cfg := &config{retryBackoff: defaultRetryBackoff}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	if cfg.writer != nil {
+		cfg.writer(w)
+	}
+	return &Driver{brokers: brokers, cfg: cfg, writer: w}
+}
+
+// Publish writes the message to the topic, waiting for the acknowledgement of all the in-sync replicas.
+func (d *Driver) Publish(ctx context.Context, topic string, msg *pubsub.Message) error {
+	km := kafka.Message{Topic: topic, Key: msg.Key, Value: msg.Data}
+	for k, v := range msg.Headers {
+		km.Headers = append(km.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	if err := d.writer.WriteMessages(ctx, km); err != nil {
+		return fmt.Errorf("could not publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe handles the messages of the topic as a member of the consumer group until ctx is done.
+func (d *Driver) Subscribe(ctx context.Context, topic string, h pubsub.Handler) error {
+	if d.cfg.groupID == "" {
+		return errors.New("could not subscribe: missing consumer group ID")
+	}
+	rc := kafka.ReaderConfig{Brokers: d.brokers, GroupID: d.cfg.groupID, Topic: topic}
+	if d.cfg.reader != nil {
+		d.cfg.reader(&rc)
+	}
+	r := kafka.NewReader(rc)
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		_ = r.Close()
+		return pubsub.ErrClosed
+	}
+	d.readers = append(d.readers, r)
+	d.mu.Unlock()
+
+	handleCtx := context.WithoutCancel(ctx)
+	for {
+		km, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("could not fetch message: %w", err)
+		}
+		msg := toMessage(km)
+		// retry until the message is handled, giving up only when the subscription stops,
+		// in which case the message is not committed and is delivered again
+		err = retry.Do(ctx, func(context.Context) error {
+			return h(handleCtx, msg)
+		}, retry.WithMaxAttempts(0), retry.WithBackoff(d.cfg.retryBackoff/10, d.cfg.retryBackoff, 2))
+		if err != nil {
+			return nil
+		}
+		if err := r.CommitMessages(handleCtx, km); err != nil {
+			return fmt.Errorf("could not commit message: %w", err)
+		}
+	}
+}
+
+func toMessage(km kafka.Message) *pubsub.Message {
+	msg := &pubsub.Message{
+		ID:      strconv.Itoa(km.Partition) + "-" + strconv.FormatInt(km.Offset, 10),
+		Topic:   km.Topic,
+		Key:     km.Key,
+		Data:    km.Value,
+		Headers: make(map[string]string, len(km.Headers)),
+	}
+	for _, h := range km.Headers {
+		msg.Headers[h.Key] = string(h.Value)
+	}
+	return msg
+}
+
+// Close flushes the pending messages and closes the writer and the readers.
+func (d *Driver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	errs := []error{d.writer.Close()}
+	for _, r := range d.readers {
+		errs = append(errs, r.Close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("could not close kafka driver: %w", err)
+	}
+	return nil
+}
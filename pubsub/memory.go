@@ -0,0 +1,143 @@
+package pubsub
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMemoryBuffer   = 64
+	defaultRedeliverDelay = 100 * time.Millisecond
+)
+
+// Memory is an in-memory Publisher and Subscriber, meant for tests.
+// Every subscription to a topic receives every message published to it after subscribing;
+// messages whose handler fails are delivered again after a delay.
+type Memory struct {
+	redeliverDelay time.Duration
+
+	mu     sync.RWMutex
+	subs   map[string][]chan *Message
+	closed bool
+	seq    atomic.Uint64
+}
+
+// compile time interface check.
+var (
+	_ Publisher  = &Memory{}
+	_ Subscriber = &Memory{}
+)
+
+// NewMemory returns an in-memory driver, redelivering failed messages after redeliverDelay.
+// A zero redeliverDelay defaults to 100ms.
+func NewMemory(redeliverDelay time.Duration) *Memory {
+	if redeliverDelay <= 0 {
+		redeliverDelay = defaultRedeliverDelay
+	}
+	return &Memory{redeliverDelay: redeliverDelay, subs: make(map[string][]chan *Message)}
+}
+
+// Publish delivers a copy of the message to every subscription of the topic, blocking while their buffers are full.
+func (m *Memory) Publish(ctx context.Context, topic string, msg *Message) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return ErrClosed
+	}
+	id := msg.ID
+	if id == "" {
+		id = strconv.FormatUint(m.seq.Add(1), 10)
+	}
+	for _, ch := range m.subs[topic] {
+		out := *msg
+		out.ID, out.Topic = id, topic
+		select {
+		case ch <- &out:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe handles the messages of the topic until ctx is done or the driver is closed.
+func (m *Memory) Subscribe(ctx context.Context, topic string, h Handler) error {
+	ch := make(chan *Message, defaultMemoryBuffer)
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+	m.subs[topic] = append(m.subs[topic], ch)
+	m.mu.Unlock()
+	defer m.unsubscribe(topic, ch)
+
+	handleCtx := context.WithoutCancel(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := h(handleCtx, msg); err != nil {
+				m.redeliver(ctx, ch, msg)
+			}
+		}
+	}
+}
+
+// redeliver puts the message back in the subscription buffer after the redelivery delay.
+func (m *Memory) redeliver(ctx context.Context, ch chan *Message, msg *Message) {
+	go func() {
+		t := time.NewTimer(m.redeliverDelay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		if m.closed {
+			return
+		}
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (m *Memory) unsubscribe(topic string, ch chan *Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := m.subs[topic]
+	for i, c := range subs {
+		if c == ch {
+			m.subs[topic] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+}
+
+// Close stops the subscriptions and rejects further publishing.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	for _, subs := range m.subs {
+		for _, ch := range subs {
+			close(ch)
+		}
+	}
+	m.subs = nil
+	return nil
+}
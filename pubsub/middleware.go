@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	consumeEvent        = "pubsub_consume"
+	instrumentationName = "github.com/indiependente/pkg/pubsub"
+)
+
+// Logging logs every handled message along with its topic and duration, and the error if any.
+func Logging(log logger.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			l := logger.WithTrace(ctx, log.Event(consumeEvent).Topic(msg.Topic).Duration(time.Since(start)))
+			if err != nil {
+				l.Error("Could not handle message", err)
+				return err
+			}
+			l.Info("Message handled")
+			return nil
+		}
+	}
+}
+
+// Recover recovers from panics of the handler, logging them and returning an error so that the message is delivered again.
+func Recover(log logger.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) (err error) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					err = fmt.Errorf("panic: %v", rec)
+					log.Event(consumeEvent).Topic(msg.Topic).Stack(string(debug.Stack())).Error("Recovered from panic", err)
+				}
+			}()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Metrics counts the handled messages by topic and result, and observes the handling duration.
+func Metrics(registerer prometheus.Registerer) Middleware {
+	handled := metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pubsub_messages_handled_total",
+		Help: "Number of messages handled by topic and result.",
+	}, []string{"topic", "result"}))
+	duration := metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pubsub_handle_duration_seconds",
+		Help:    "Duration of message handling.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"}))
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			duration.WithLabelValues(msg.Topic).Observe(time.Since(start).Seconds())
+			result := "success"
+			if err != nil {
+				result = "error"
+			}
+			handled.WithLabelValues(msg.Topic, result).Inc()
+			return err
+		}
+	}
+}
+
+// Tracing starts a consumer span for every message, continuing the trace propagated in its headers.
+func Tracing() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+			ctx, span := otel.Tracer(instrumentationName).Start(ctx, msg.Topic+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(attribute.String("messaging.destination.name", msg.Topic)),
+			)
+			defer span.End()
+			err := next(ctx, msg)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
+
+// TracePublisher wraps the publisher starting a producer span for every message
+// and propagating the trace context in its headers.
+func TracePublisher(p Publisher) Publisher {
+	return &tracingPublisher{Publisher: p}
+}
+
+type tracingPublisher struct {
+	Publisher
+}
+
+func (p *tracingPublisher) Publish(ctx context.Context, topic string, msg *Message) error {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", topic)),
+	)
+	defer span.End()
+	out := *msg
+	out.Headers = make(map[string]string, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		out.Headers[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(out.Headers))
+	err := p.Publisher.Publish(ctx, topic, &out)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
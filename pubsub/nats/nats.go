@@ -0,0 +1,179 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/pubsub"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const defaultRedeliverDelay = time.Second
+
+// Option customises the NATS driver.
+type Option func(*config)
+
+type config struct {
+	durable        string
+	redeliverDelay time.Duration
+	consumer       func(*jetstream.ConsumerConfig)
+}
+
+// WithDurable sets the name of the durable consumers created by the subscriptions, suffixed by the topic,
+// so that instances sharing it share the messages. Defaults to an ephemeral consumer per subscription.
+func WithDurable(name string) Option {
+	return func(c *config) {
+		c.durable = name
+	}
+}
+
+// WithRedeliverDelay sets how long the server waits before delivering again a message whose handler failed.
+// Defaults to 1s.
+func WithRedeliverDelay(d time.Duration) Option {
+	return func(c *config) {
+		c.redeliverDelay = d
+	}
+}
+
+// WithConsumerConfig customises the configuration of the consumer of each subscription.
+func WithConsumerConfig(fn func(*jetstream.ConsumerConfig)) Option {
+	return func(c *config) {
+		c.consumer = fn
+	}
+}
+
+// Driver publishes and subscribes to the subjects of a JetStream stream, topics being subjects.
+type Driver struct {
+	nc     *natsgo.Conn
+	js     jetstream.JetStream
+	stream string
+	cfg    *config
+
+	mu       sync.Mutex
+	consumes []jetstream.ConsumeContext
+	closed   bool
+}
+
+// compile time interface check.
+var (
+	_ pubsub.Publisher  = &Driver{}
+	_ pubsub.Subscriber = &Driver{}
+)
+
+// New returns a NATS driver using the JetStream stream in input over the connection in input.
+// The stream must exist and capture the subjects used as topics.
+func New(nc *natsgo.Conn, stream string, opts ...Option) (*Driver, error) {
+	cfg := &config{redeliverDelay: defaultRedeliverDelay}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("could not create jetstream context: %w", err)
+	}
+	return &Driver{nc: nc, js: js, stream: stream, cfg: cfg}, nil
+}
+
+// Publish publishes the message to the subject, waiting for the acknowledgement of the stream.
+func (d *Driver) Publish(ctx context.Context, topic string, msg *pubsub.Message) error {
+	nm := natsgo.NewMsg(topic)
+	nm.Data = msg.Data
+	for k, v := range msg.Headers {
+		nm.Header.Set(k, v)
+	}
+	var opts []jetstream.PublishOpt
+	if msg.ID != "" {
+		// lets the stream discard duplicates within its deduplication window
+		opts = append(opts, jetstream.WithMsgID(msg.ID))
+	}
+	if _, err := d.js.PublishMsg(ctx, nm, opts...); err != nil {
+		return fmt.Errorf("could not publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe handles the messages of the subject until ctx is done, then drains the subscription,
+// letting the in-flight handler complete.
+func (d *Driver) Subscribe(ctx context.Context, topic string, h pubsub.Handler) error {
+	cc := jetstream.ConsumerConfig{
+		FilterSubject: topic,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	}
+	if d.cfg.durable != "" {
+		cc.Durable = d.cfg.durable + "_" + sanitize(topic)
+	}
+	if d.cfg.consumer != nil {
+		d.cfg.consumer(&cc)
+	}
+	cons, err := d.js.CreateOrUpdateConsumer(ctx, d.stream, cc)
+	if err != nil {
+		return fmt.Errorf("could not create consumer: %w", err)
+	}
+
+	handleCtx := context.WithoutCancel(ctx)
+	consume, err := cons.Consume(func(m jetstream.Msg) {
+		if err := h(handleCtx, toMessage(m)); err != nil {
+			_ = m.NakWithDelay(d.cfg.redeliverDelay)
+			return
+		}
+		_ = m.Ack()
+	})
+	if err != nil {
+		return fmt.Errorf("could not consume: %w", err)
+	}
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		consume.Stop()
+		return pubsub.ErrClosed
+	}
+	d.consumes = append(d.consumes, consume)
+	d.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		consume.Drain()
+		<-consume.Closed()
+	case <-consume.Closed():
+	}
+	return nil
+}
+
+func toMessage(m jetstream.Msg) *pubsub.Message {
+	msg := &pubsub.Message{
+		Topic:   m.Subject(),
+		Data:    m.Data(),
+		Headers: make(map[string]string, len(m.Headers())),
+	}
+	if md, err := m.Metadata(); err == nil {
+		msg.ID = strconv.FormatUint(md.Sequence.Stream, 10)
+	}
+	for k := range m.Headers() {
+		msg.Headers[k] = m.Headers().Get(k)
+	}
+	return msg
+}
+
+// sanitize makes the subject usable in a consumer name.
+func sanitize(subject string) string {
+	return strings.NewReplacer(".", "_", "*", "any", ">", "all").Replace(subject)
+}
+
+// Close stops the subscriptions. The connection is left open, as it is owned by the caller.
+func (d *Driver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	for _, c := range d.consumes {
+		c.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/shutdown"
+)
+
+// ErrClosed is returned when using a closed publisher or subscriber.
+var ErrClosed = errors.New("pubsub: closed")
+
+// Message is a message published to or received from a topic.
+type Message struct {
+	// ID identifies the message, when the driver provides one.
+	ID string
+	// Topic is the topic the message was received from.
+	Topic string
+	// Key is used by the drivers supporting partitioning to route messages with the same key together.
+	Key []byte
+	// Data is the payload.
+	Data []byte
+	// Headers carry metadata, e.g. the trace context.
+	Headers map[string]string
+}
+
+// Handler handles a message. Returning an error leaves the message unacknowledged, so that it is delivered again.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Middleware decorates a Handler.
+type Middleware func(Handler) Handler
+
+// Chain decorates the handler with the middlewares in input, the first one being the outermost.
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Publisher publishes messages to topics.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg *Message) error
+	Close() error
+}
+
+// Subscriber delivers the messages of a topic to a handler with at-least-once semantics:
+// a message is acknowledged only once the handler returns nil.
+type Subscriber interface {
+	// Subscribe blocks handling the messages of the topic until ctx is done, letting the in-flight handler complete.
+	Subscribe(ctx context.Context, topic string, h Handler) error
+	Close() error
+}
+
+// Run subscribes each handler to its topic, blocking until ctx is done or a subscription fails.
+func Run(ctx context.Context, sub Subscriber, handlers map[string]Handler) error {
+	eg, ctx := conc.WithContext(ctx)
+	for topic, h := range handlers {
+		eg.Go(func() error {
+			if err := sub.Subscribe(ctx, topic, h); err != nil {
+				return fmt.Errorf("could not subscribe to %s: %w", topic, err)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// TerminationFn returns a shutdown.TerminationFn closing the publishers and subscribers in input.
+func TerminationFn(closers ...io.Closer) shutdown.TerminationFn {
+	return func(context.Context) error {
+		var errs []error
+		for _, c := range closers {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("could not close: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,95 @@
+package quota
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/indiependente/pkg/httpx/respond"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/tenant"
+)
+
+// MiddlewareOption customises the quota middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	keyFunc  func(*http.Request) string
+	costFunc func(*http.Request) int64
+	log      logger.Logger
+}
+
+// WithKeyFunc charges the requests to the key returned by the function in input, e.g. an API key, instead of the
+// tenant of the request context. Requests with an empty key are let through.
+func WithKeyFunc(fn func(*http.Request) string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.keyFunc = fn
+	}
+}
+
+// WithCostFunc charges the requests the units returned by the function in input instead of 1, e.g. by endpoint.
+func WithCostFunc(fn func(*http.Request) int64) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.costFunc = fn
+	}
+}
+
+// WithMiddlewareLogger logs the failures of the store.
+func WithMiddlewareLogger(log logger.Logger) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.log = log
+	}
+}
+
+// Middleware charges every request to the quotas of its key, by default the tenant set by tenant.Middleware,
+// answering the requests over quota with 429 Too Many Requests and a Retry-After header.
+// Responses carry the X-Quota-Limit, X-Quota-Remaining and X-Quota-Reset headers of the quota closest to exhaustion,
+// the latter in seconds. Requests are let through if the store fails.
+func Middleware(m *Manager, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	cfg := &middlewareConfig{
+		keyFunc: func(r *http.Request) string {
+			id, _ := tenant.FromContext(r.Context())
+			return id
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := cfg.keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cost := int64(1)
+			if cfg.costFunc != nil {
+				cost = cfg.costFunc(r)
+			}
+			res, err := m.AllowN(r.Context(), key, cost)
+			if err != nil {
+				if cfg.log != nil {
+					logger.WithTrace(r.Context(), cfg.log.Event(quotaEvent).Method(r.Method).URI(r.RequestURI)).Error("Could not check quota", err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if res.Limit > 0 {
+				reset := strconv.Itoa(int(math.Ceil(res.Reset.Sub(m.clock.Now()).Seconds())))
+				h := w.Header()
+				h.Set("X-Quota-Limit", strconv.FormatInt(res.Limit, 10))
+				h.Set("X-Quota-Remaining", strconv.FormatInt(res.Remaining, 10))
+				h.Set("X-Quota-Reset", reset)
+				if !res.Allowed {
+					h.Set("Retry-After", reset)
+				}
+			}
+			if !res.Allowed {
+				respond.Error(w, respond.NewProblem(http.StatusTooManyRequests, "the "+res.Period.String()+" quota is exhausted"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	quotaEvent = "quota"
+
+	defaultRetention      = 7 * 24 * time.Hour
+	defaultReportInterval = time.Hour
+)
+
+// ErrExceeded is returned when a key used up its quota.
+var ErrExceeded = errors.New("quota exceeded")
+
+// Period is the window after which a quota resets.
+type Period int
+
+// Periods of the quotas, starting at midnight of the manager location.
+const (
+	Daily Period = iota
+	Monthly
+)
+
+// String returns the name of the period.
+func (p Period) String() string {
+	switch p {
+	case Daily:
+		return "daily"
+	case Monthly:
+		return "monthly"
+	default:
+		return fmt.Sprintf("period(%d)", int(p))
+	}
+}
+
+// Start returns the start of the window including t, in the location of t.
+func (p Period) Start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	if p == Monthly {
+		d = 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// End returns the end of the window including t, i.e. the start of the next one.
+func (p Period) End(t time.Time) time.Time {
+	start := p.Start(t)
+	if p == Monthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// window returns the identifier of the window including t.
+func (p Period) window(t time.Time) string {
+	return p.String() + ":" + p.Start(t).Format("20060102")
+}
+
+// Limit allows up to Max units of usage per Period.
+type Limit struct {
+	Period Period
+	Max    int64
+}
+
+// Result is the outcome of using a quota.
+type Result struct {
+	Allowed bool
+	// Period, Limit, Remaining and Reset describe the quota closest to exhaustion, the one exceeded if not allowed.
+	Period    Period
+	Limit     int64
+	Remaining int64
+	Reset     time.Time
+}
+
+// Err returns an error wrapping ErrExceeded if the result is not allowed, nil otherwise.
+func (r Result) Err() error {
+	if r.Allowed {
+		return nil
+	}
+	return fmt.Errorf("%w: %s limit of %d, resets at %s", ErrExceeded, r.Period, r.Limit, r.Reset.Format(time.RFC3339))
+}
+
+// Usage is the usage of the quota of a key in the current window.
+type Usage struct {
+	Period Period
+	Used   int64
+	Limit  int64
+	Start  time.Time
+	End    time.Time
+}
+
+// Option customises a Manager.
+type Option func(*Manager)
+
+// WithLimits applies the limits in input to every key without limits of its own, see WithLimitFunc.
+func WithLimits(limits ...Limit) Option {
+	return func(m *Manager) {
+		m.limits = limits
+	}
+}
+
+// WithLimitFunc looks up the limits of a key with the function in input, e.g. by the plan of a tenant, falling back
+// to the limits set by WithLimits if it reports false.
+func WithLimitFunc(fn func(ctx context.Context, key string) ([]Limit, bool)) Option {
+	return func(m *Manager) {
+		m.limitFunc = fn
+	}
+}
+
+// WithLocation starts the windows at midnight in the location in input instead of UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(m *Manager) {
+		m.loc = loc
+	}
+}
+
+// WithRetention keeps the usage of a window for d after its end, 7 days by default, e.g. to be reported.
+func WithRetention(d time.Duration) Option {
+	return func(m *Manager) {
+		m.retention = d
+	}
+}
+
+// WithReporter exports the usage with the reporter in input, see Run.
+func WithReporter(r Reporter) Option {
+	return func(m *Manager) {
+		m.reporters = append(m.reporters, r)
+	}
+}
+
+// WithReportInterval exports the usage every d while running, hourly by default.
+func WithReportInterval(d time.Duration) Option {
+	return func(m *Manager) {
+		m.reportInterval = d
+	}
+}
+
+// WithLogger logs the failures to export the usage.
+func WithLogger(log logger.Logger) Option {
+	return func(m *Manager) {
+		m.log = log
+	}
+}
+
+// WithMetrics counts the quota checks by period and result on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(m *Manager) {
+		m.checks = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "quota_checks_total",
+			Help: "Number of quota checks by period and result.",
+		}, []string{"period", "result"}))
+	}
+}
+
+// WithClock tells the time with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(m *Manager) {
+		m.clock = c
+	}
+}
+
+// Manager tracks the usage of keys, e.g. tenants or API keys, against daily and monthly quotas.
+// Unlike the rate limits of the ratelimit package, which smooth out bursts over seconds, quotas cap the
+// total usage of a window and reset at its end.
+type Manager struct {
+	store          Store
+	limits         []Limit
+	limitFunc      func(ctx context.Context, key string) ([]Limit, bool)
+	loc            *time.Location
+	retention      time.Duration
+	reporters      []Reporter
+	reportInterval time.Duration
+	log            logger.Logger
+	checks         *prometheus.CounterVec
+	clock          clock.Clock
+
+	mu      sync.Mutex
+	periods map[Period]struct{}
+}
+
+// NewManager returns a Manager keeping the usage in the store in input.
+func NewManager(store Store, opts ...Option) *Manager {
+	m := &Manager{
+		store:          store,
+		loc:            time.UTC,
+		retention:      defaultRetention,
+		reportInterval: defaultReportInterval,
+		clock:          clock.New(),
+		periods:        make(map[Period]struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	for _, l := range m.limits {
+		m.periods[l.Period] = struct{}{}
+	}
+	return m
+}
+
+// Allow uses a unit of the quotas of the key, if none is exhausted.
+func (m *Manager) Allow(ctx context.Context, key string) (Result, error) {
+	return m.AllowN(ctx, key, 1)
+}
+
+// AllowN uses n units of the quotas of the key, if none would be exceeded: the usage is only recorded if allowed
+// by every quota. Keys without limits are always allowed.
+func (m *Manager) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	limits := m.limitsOf(ctx, key)
+	now := m.clock.Now().In(m.loc)
+	res := Result{Allowed: true, Remaining: math.MaxInt64}
+	for i, l := range limits {
+		used, allowed, err := m.store.Add(ctx, l.Period.window(now), key, n, l.Max, l.Period.End(now).Add(m.retention))
+		if err != nil {
+			m.rollback(ctx, key, n, limits[:i], now)
+			return Result{}, fmt.Errorf("could not check %s quota: %w", l.Period, err)
+		}
+		if !allowed {
+			m.rollback(ctx, key, n, limits[:i], now)
+			m.observe(l.Period, "exceeded")
+			return Result{Period: l.Period, Limit: l.Max, Remaining: max(0, l.Max-used), Reset: l.Period.End(now)}, nil
+		}
+		if remaining := l.Max - used; remaining < res.Remaining {
+			res = Result{Allowed: true, Period: l.Period, Limit: l.Max, Remaining: remaining, Reset: l.Period.End(now)}
+		}
+	}
+	for _, l := range limits {
+		m.observe(l.Period, "allowed")
+	}
+	return res, nil
+}
+
+// Record adds n units to the usage of the key even if it exceeds the quotas, e.g. for usage measured after the
+// fact, such as the bytes of a response. The following calls to AllowN are rejected until the quotas reset.
+func (m *Manager) Record(ctx context.Context, key string, n int64) error {
+	now := m.clock.Now().In(m.loc)
+	var errs []error
+	for _, l := range m.limitsOf(ctx, key) {
+		if _, _, err := m.store.Add(ctx, l.Period.window(now), key, n, math.MaxInt64, l.Period.End(now).Add(m.retention)); err != nil {
+			errs = append(errs, fmt.Errorf("could not record %s quota usage: %w", l.Period, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Usage returns the usage of the quotas of the key in the current windows.
+func (m *Manager) Usage(ctx context.Context, key string) ([]Usage, error) {
+	now := m.clock.Now().In(m.loc)
+	limits := m.limitsOf(ctx, key)
+	out := make([]Usage, 0, len(limits))
+	for _, l := range limits {
+		usage, err := m.store.Usage(ctx, l.Period.window(now))
+		if err != nil {
+			return nil, fmt.Errorf("could not get %s quota usage: %w", l.Period, err)
+		}
+		out = append(out, Usage{Period: l.Period, Used: usage[key], Limit: l.Max, Start: l.Period.Start(now), End: l.Period.End(now)})
+	}
+	return out, nil
+}
+
+// limitsOf returns the limits of the key, remembering their periods to be reported.
+func (m *Manager) limitsOf(ctx context.Context, key string) []Limit {
+	limits := m.limits
+	if m.limitFunc != nil {
+		if l, ok := m.limitFunc(ctx, key); ok {
+			limits = l
+			m.mu.Lock()
+			for _, l := range limits {
+				m.periods[l.Period] = struct{}{}
+			}
+			m.mu.Unlock()
+		}
+	}
+	return limits
+}
+
+// rollback removes the usage added to the windows of the limits in input.
+func (m *Manager) rollback(ctx context.Context, key string, n int64, limits []Limit, now time.Time) {
+	for _, l := range limits {
+		if _, _, err := m.store.Add(context.WithoutCancel(ctx), l.Period.window(now), key, -n, math.MaxInt64, l.Period.End(now).Add(m.retention)); err != nil && m.log != nil {
+			m.log.Event(quotaEvent).Component(key).Error(fmt.Sprintf("Could not roll back %s quota usage", l.Period), err)
+		}
+	}
+}
+
+func (m *Manager) observe(p Period, result string) {
+	if m.checks != nil {
+		m.checks.WithLabelValues(p.String(), result).Inc()
+	}
+}
@@ -0,0 +1,67 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// addScript adds to the usage of a key, stored in the hash of the window, unless it would exceed the maximum.
+var addScript = redis.NewScript(`
+local used = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+local n = tonumber(ARGV[2])
+if used + n > tonumber(ARGV[3]) then
+	return {used, 0}
+end
+used = redis.call('HINCRBY', KEYS[1], ARGV[1], n)
+redis.call('PEXPIREAT', KEYS[1], ARGV[4])
+return {used, 1}
+`)
+
+// RedisStore keeps the usage counters in Redis, sharing the quotas across instances.
+// The usage of a window is stored in a hash by key, which expires with the window.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// compile time interface check.
+var _ Store = &RedisStore{}
+
+// NewRedisStore returns a RedisStore using the client in input, e.g. a *redis.Client or a *redis.ClusterClient,
+// and prepending prefix to the keys.
+func NewRedisStore(client redis.Cmdable, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Add adds n to the usage of the key in the window unless the usage would exceed max.
+func (s *RedisStore) Add(ctx context.Context, window, key string, n, max int64, expireAt time.Time) (int64, bool, error) {
+	vals, err := addScript.Run(ctx, s.client, []string{s.prefix + window}, key, n, max, expireAt.UnixMilli()).Int64Slice()
+	if err != nil {
+		return 0, false, fmt.Errorf("could not add quota usage: %w", err)
+	}
+	if len(vals) != 2 {
+		return 0, false, fmt.Errorf("unexpected quota script result: %v", vals)
+	}
+	return vals[0], vals[1] == 1, nil
+}
+
+// Usage returns the usage of every key in the window.
+func (s *RedisStore) Usage(ctx context.Context, window string) (map[string]int64, error) {
+	vals, err := s.client.HGetAll(ctx, s.prefix+window).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not get quota usage: %w", err)
+	}
+	usage := make(map[string]int64, len(vals))
+	for k, v := range vals {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse quota usage of %s: %w", k, err)
+		}
+		usage[k] = n
+	}
+	return usage, nil
+}
@@ -0,0 +1,97 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Report is the usage of every key in a window.
+type Report struct {
+	Period Period
+	Start  time.Time
+	End    time.Time
+	// Final is true once the window ended, and the usage can no longer change, e.g. to be billed.
+	Final bool
+	Usage map[string]int64
+}
+
+// Reporter exports the usage reports, e.g. to a billing system or a data warehouse.
+type Reporter interface {
+	Export(ctx context.Context, r Report) error
+}
+
+// ReporterFunc is a function implementing Reporter.
+type ReporterFunc func(ctx context.Context, r Report) error
+
+// Export calls f.
+func (f ReporterFunc) Export(ctx context.Context, r Report) error {
+	return f(ctx, r)
+}
+
+// Report returns the usage of every key in the window of the period including t.
+func (m *Manager) Report(ctx context.Context, p Period, t time.Time) (Report, error) {
+	t = t.In(m.loc)
+	usage, err := m.store.Usage(ctx, p.window(t))
+	if err != nil {
+		return Report{}, fmt.Errorf("could not get %s quota usage: %w", p, err)
+	}
+	return Report{Period: p, Start: p.Start(t), End: p.End(t), Final: !m.clock.Now().Before(p.End(t)), Usage: usage}, nil
+}
+
+// Run exports the usage of the current windows to the reporters at every report interval, along with the final
+// usage of the windows ended since the previous export, until ctx is done. It is meant to be run in its own goroutine.
+// The windows must be kept past their end for longer than the report interval, see WithRetention.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := m.clock.NewTicker(m.reportInterval)
+	defer ticker.Stop()
+	last := make(map[Period]time.Time)
+	for {
+		m.export(ctx, last)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// export exports the usage of the current windows, and of the windows ended since the times in last, updating them.
+func (m *Manager) export(ctx context.Context, last map[Period]time.Time) {
+	now := m.clock.Now().In(m.loc)
+	m.mu.Lock()
+	periods := make([]Period, 0, len(m.periods))
+	for p := range m.periods {
+		periods = append(periods, p)
+	}
+	m.mu.Unlock()
+	slices.Sort(periods)
+
+	var errs []error
+	for _, p := range periods {
+		if prev, ok := last[p]; ok && p.window(prev) != p.window(now) {
+			errs = append(errs, m.exportWindow(ctx, p, prev))
+		}
+		last[p] = now
+		errs = append(errs, m.exportWindow(ctx, p, now))
+	}
+	if err := errors.Join(errs...); err != nil && ctx.Err() == nil && m.log != nil {
+		m.log.Event(quotaEvent).Error("Could not export quota usage", err)
+	}
+}
+
+func (m *Manager) exportWindow(ctx context.Context, p Period, t time.Time) error {
+	r, err := m.Report(ctx, p, t)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, rep := range m.reporters {
+		if err := rep.Export(ctx, r); err != nil {
+			errs = append(errs, fmt.Errorf("could not export %s quota usage: %w", p, err))
+		}
+	}
+	return errors.Join(errs...)
+}
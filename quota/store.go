@@ -0,0 +1,79 @@
+package quota
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+// Store keeps the usage counters by window and key, e.g. in memory or in Redis to share quotas across instances.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Add adds n to the usage of the key in the window unless the usage would exceed max, returning the usage after
+	// the addition, or the current one if not allowed. The window may be dropped once expireAt is past.
+	Add(ctx context.Context, window, key string, n, max int64, expireAt time.Time) (used int64, allowed bool, err error)
+	// Usage returns the usage of every key in the window.
+	Usage(ctx context.Context, window string) (map[string]int64, error)
+}
+
+// MemoryStore keeps the usage counters in memory. Expired windows are dropped on the next addition.
+type MemoryStore struct {
+	clock clock.Clock
+
+	mu      sync.Mutex
+	windows map[string]*memoryWindow
+}
+
+// compile time interface check.
+var _ Store = &MemoryStore{}
+
+type memoryWindow struct {
+	usage    map[string]int64
+	expireAt time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore telling the time with the clock in input, the real one if nil.
+func NewMemoryStore(c clock.Clock) *MemoryStore {
+	if c == nil {
+		c = clock.New()
+	}
+	return &MemoryStore{clock: c, windows: make(map[string]*memoryWindow)}
+}
+
+// Add adds n to the usage of the key in the window unless the usage would exceed max.
+func (s *MemoryStore) Add(_ context.Context, window, key string, n, max int64, expireAt time.Time) (int64, bool, error) {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, w := range s.windows {
+		if !now.Before(w.expireAt) {
+			delete(s.windows, id)
+		}
+	}
+	w, ok := s.windows[window]
+	if !ok {
+		w = &memoryWindow{usage: make(map[string]int64)}
+		s.windows[window] = w
+	}
+	w.expireAt = expireAt
+	used := w.usage[key]
+	if used+n > max {
+		return used, false, nil
+	}
+	w.usage[key] = used + n
+	return used + n, true, nil
+}
+
+// Usage returns the usage of every key in the window.
+func (s *MemoryStore) Usage(_ context.Context, window string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.windows[window]
+	if !ok || !s.clock.Now().Before(w.expireAt) {
+		return map[string]int64{}, nil
+	}
+	return maps.Clone(w.usage), nil
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+const (
+	defaultShards = 32
+	sweepInterval = time.Minute
+)
+
+// MemoryStore keeps token buckets in memory, spread across shards to reduce lock contention.
+// Buckets which refilled completely are periodically dropped.
+type MemoryStore struct {
+	shards []*bucketShard
+	clock  clock.Clock
+}
+
+// compile time interface check.
+var (
+	_ Store    = &MemoryStore{}
+	_ Reserver = &MemoryStore{}
+)
+
+type bucketShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket keeps the limits of its last request, so that sweeps refill it with its own limits.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rps    float64
+	burst  int
+}
+
+// NewMemoryStore returns a MemoryStore with the number of shards in input.
+func NewMemoryStore(shards int, opts ...Option) *MemoryStore {
+	if shards <= 0 {
+		shards = 1
+	}
+	s := &MemoryStore{shards: make([]*bucketShard, shards), clock: newOptions(opts).clock}
+	for i := range s.shards {
+		s.shards[i] = &bucketShard{buckets: make(map[string]*bucket), lastSweep: s.clock.Now()}
+	}
+	return s
+}
+
+// Take takes a token from the bucket of the key.
+func (s *MemoryStore) Take(_ context.Context, key string, rps float64, burst int) (Result, error) {
+	if rps <= 0 || burst <= 0 {
+		return denied(), nil
+	}
+	var res Result
+	s.with(key, rps, burst, func(b *bucket) {
+		if b.tokens >= 1 {
+			b.tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = durationOf((1 - b.tokens) / rps)
+		}
+		res.Remaining = int(math.Max(0, b.tokens))
+		res.Reset = durationOf((float64(burst) - b.tokens) / rps)
+	})
+	return res, nil
+}
+
+// Reserve takes a token from the bucket of the key, leaving it in debt if empty.
+// It returns ErrLimited without taking a token if the limits are not positive, as the token would never be available.
+func (s *MemoryStore) Reserve(_ context.Context, key string, rps float64, burst int) (time.Duration, error) {
+	if rps <= 0 || burst <= 0 {
+		return 0, denied().Err()
+	}
+	var wait time.Duration
+	s.with(key, rps, burst, func(b *bucket) {
+		b.tokens--
+		wait = durationOf(-b.tokens / rps)
+	})
+	return wait, nil
+}
+
+// with calls fn with the refilled bucket of the key, holding the lock of its shard.
+func (s *MemoryStore) with(key string, rps float64, burst int, fn func(*bucket)) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	shard := s.shards[h.Sum32()%uint32(len(s.shards))]
+
+	now := s.clock.Now()
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if now.Sub(shard.lastSweep) > sweepInterval {
+		shard.sweep(now)
+	}
+	b, ok := shard.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now, rps: rps, burst: burst}
+		shard.buckets[key] = b
+	}
+	b.refill(now)
+	b.rps, b.burst = rps, burst
+	b.tokens = math.Min(float64(burst), b.tokens)
+	fn(b)
+}
+
+// refill adds the tokens accrued since the last request, with the limits of the last request.
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.burst), b.tokens+now.Sub(b.last).Seconds()*b.rps)
+	b.last = now
+}
+
+// sweep drops the buckets which are full, as they are equivalent to missing ones.
+func (s *bucketShard) sweep(now time.Time) {
+	for k, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.burst) {
+			delete(s.buckets, k)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+var (
+	// ErrLimited is returned when a request is not allowed by the limiter.
+	ErrLimited = errors.New("rate limit exceeded")
+	// ErrReserveUnsupported is returned by Limiter.Reserve when the store cannot reserve tokens.
+	ErrReserveUnsupported = errors.New("store does not support reservations")
+)
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+	// RetryAfter is how long to wait before a token is available, when not allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Err returns an error wrapping ErrLimited and carrying how long to wait if the result is not allowed, nil otherwise.
+func (r Result) Err() error {
+	if r.Allowed {
+		return nil
+	}
+	return fmt.Errorf("%w: retry after %s", ErrLimited, r.RetryAfter.Round(time.Millisecond))
+}
+
+// Store keeps the state of the limits by key, e.g. in memory or in Redis to share limits across instances.
+// A rate or a burst which is not positive denies every event. Implementations must be safe for concurrent use.
+type Store interface {
+	Take(ctx context.Context, key string, rps float64, burst int) (Result, error)
+}
+
+// Reserver is implemented by the stores able to take a token ahead of time, leaving the bucket in debt.
+type Reserver interface {
+	// Reserve takes a token and returns how long to wait before using it.
+	Reserve(ctx context.Context, key string, rps float64, burst int) (time.Duration, error)
+}
+
+// Option customises a Limiter or an in-memory store.
+type Option func(*options)
+
+type options struct {
+	clock clock.Clock
+}
+
+// WithClock tells the time and waits with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{clock: clock.New()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Limiter allows rps events per second per key, with bursts of up to burst events.
+// A rate or a burst which is not positive denies every event, e.g. to block a key: Wait blocks until its context is done.
+type Limiter struct {
+	store Store
+	rps   float64
+	burst int
+	clock clock.Clock
+}
+
+// New returns a Limiter keeping its state in the store in input.
+func New(store Store, rps float64, burst int, opts ...Option) *Limiter {
+	return &Limiter{store: store, rps: rps, burst: burst, clock: newOptions(opts).clock}
+}
+
+// NewMemory returns a Limiter keeping its state in memory.
+func NewMemory(rps float64, burst int, opts ...Option) *Limiter {
+	return New(NewMemoryStore(defaultShards, opts...), rps, burst, opts...)
+}
+
+// Rate returns the number of events per second allowed.
+func (l *Limiter) Rate() float64 {
+	return l.rps
+}
+
+// Burst returns the maximum number of events allowed at once.
+func (l *Limiter) Burst() int {
+	return l.burst
+}
+
+// Take takes a token from the bucket of the key.
+func (l *Limiter) Take(ctx context.Context, key string) (Result, error) {
+	res, err := l.store.Take(ctx, key, l.rps, l.burst)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not take token: %w", err)
+	}
+	return res, nil
+}
+
+// Allow reports whether an event for the key may happen now. It allows the event if the store fails.
+func (l *Limiter) Allow(ctx context.Context, key string) bool {
+	res, err := l.Take(ctx, key)
+	return err != nil || res.Allowed
+}
+
+// Wait blocks until an event for the key is allowed or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, key string) error {
+	for {
+		res, err := l.Take(ctx, key)
+		if err != nil {
+			return err
+		}
+		if res.Allowed {
+			return nil
+		}
+		t := l.clock.NewTimer(res.RetryAfter)
+		select {
+		case <-t.C():
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Reserve takes a token for the key, even if not available yet, and returns how long to wait before acting.
+// It returns ErrReserveUnsupported if the store does not implement Reserver.
+func (l *Limiter) Reserve(ctx context.Context, key string) (time.Duration, error) {
+	r, ok := l.store.(Reserver)
+	if !ok {
+		return 0, ErrReserveUnsupported
+	}
+	return r.Reserve(ctx, key, l.rps, l.burst)
+}
+
+// forever is how long to wait for a token when the limits are not positive.
+const forever = time.Duration(math.MaxInt64)
+
+// denied returns the result of limits which are not positive, never allowing an event.
+func denied() Result {
+	return Result{RetryAfter: forever, Reset: forever}
+}
+
+// durationOf converts the seconds in input to a duration, saturating instead of overflowing.
+func durationOf(seconds float64) time.Duration {
+	d := math.Max(0, seconds) * float64(time.Second)
+	if d >= float64(forever) || math.IsNaN(d) {
+		return forever
+	}
+	return time.Duration(d)
+}
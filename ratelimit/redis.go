@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript takes a token from the bucket stored in a hash, using the Redis clock
+// so that instances with skewed clocks share the same view.
+var tokenBucketScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps token buckets in Redis, sharing the limits across instances.
+// Buckets expire once they would be full again.
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// compile time interface check.
+var _ Store = &RedisStore{}
+
+// NewRedisStore returns a RedisStore using the client in input, e.g. a *redis.Client or a *redis.ClusterClient,
+// and prepending prefix to the keys.
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Take takes a token from the bucket of the key.
+func (s *RedisStore) Take(ctx context.Context, key string, rps float64, burst int) (Result, error) {
+	if rps <= 0 || burst <= 0 {
+		return denied(), nil
+	}
+	vals, err := tokenBucketScript.Run(ctx, s.client, []string{s.prefix + key}, rps, burst).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("could not run token bucket script: %w", err)
+	}
+	if len(vals) != 2 {
+		return Result{}, fmt.Errorf("unexpected token bucket script result: %v", vals)
+	}
+	allowed, _ := vals[0].(int64)
+	tokensStr, _ := vals[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not parse tokens: %w", err)
+	}
+	res := Result{
+		Allowed:   allowed == 1,
+		Remaining: int(math.Max(0, tokens)),
+		Reset:     durationOf((float64(burst) - tokens) / rps),
+	}
+	if !res.Allowed {
+		res.RetryAfter = durationOf((1 - tokens) / rps)
+	}
+	return res, nil
+}
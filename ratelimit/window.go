@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+)
+
+// SlidingWindowStore limits keys with a sliding window counter: at most burst events are allowed in any window
+// of burst/rps seconds, estimating the events of the window from the counts of the current and previous fixed windows.
+// Compared to a token bucket, it does not let a full burst through right after a previous one.
+type SlidingWindowStore struct {
+	clock clock.Clock
+
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+// compile time interface check.
+var _ Store = &SlidingWindowStore{}
+
+// window keeps the size of its last request, so that sweeps expire it with its own size.
+type window struct {
+	start      time.Time
+	size       time.Duration
+	prev, curr int
+}
+
+// NewSlidingWindowStore returns an empty SlidingWindowStore.
+func NewSlidingWindowStore(opts ...Option) *SlidingWindowStore {
+	c := newOptions(opts).clock
+	return &SlidingWindowStore{clock: c, windows: make(map[string]*window), lastSweep: c.Now()}
+}
+
+// Take counts an event for the key if the window allows it.
+func (s *SlidingWindowStore) Take(_ context.Context, key string, rps float64, burst int) (Result, error) {
+	if rps <= 0 || burst <= 0 {
+		return denied(), nil
+	}
+	size := durationOf(float64(burst) / rps)
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+	w, ok := s.windows[key]
+	if !ok {
+		w = &window{start: now.Truncate(size), size: size}
+		s.windows[key] = w
+	}
+	w.size = size
+	w.advance(now, size)
+
+	elapsed := now.Sub(w.start)
+	weight := 1 - float64(elapsed)/float64(size)
+	estimate := float64(w.prev)*weight + float64(w.curr)
+
+	res := Result{Reset: size - elapsed}
+	if estimate+1 <= float64(burst) {
+		w.curr++
+		res.Allowed = true
+		res.Remaining = int(math.Max(0, float64(burst)-estimate-1))
+		return res, nil
+	}
+	// wait until the weight of the previous window drops enough, or for the next window
+	if w.prev > 0 && w.curr < burst {
+		need := 1 - (float64(burst)-float64(w.curr)-1)/float64(w.prev)
+		res.RetryAfter = time.Duration(need*float64(size)) - elapsed
+	} else {
+		res.RetryAfter = size - elapsed
+	}
+	if res.RetryAfter < 0 {
+		res.RetryAfter = 0
+	}
+	return res, nil
+}
+
+// advance moves the window forward to the one containing now.
+func (w *window) advance(now time.Time, size time.Duration) {
+	switch elapsed := now.Sub(w.start); {
+	case elapsed < size:
+	case elapsed < 2*size:
+		w.prev, w.curr = w.curr, 0
+		w.start = w.start.Add(size)
+	default:
+		w.prev, w.curr = 0, 0
+		w.start = now.Truncate(size)
+	}
+}
+
+// sweep drops the windows idle for more than two windows, as they are equivalent to missing ones.
+func (s *SlidingWindowStore) sweep(now time.Time) {
+	for k, w := range s.windows {
+		if now.Sub(w.start) >= 2*w.size {
+			delete(s.windows, k)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,193 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	commandEvent        = "redis_command"
+	instrumentationName = "github.com/indiependente/pkg/redisx"
+)
+
+// isError reports whether err is a failure, redis.Nil being a regular miss.
+func isError(err error) bool {
+	return err != nil && !errors.Is(err, redis.Nil)
+}
+
+// pipelineName returns the names of the commands of a pipeline, e.g. "pipeline get set".
+func pipelineName(cmds []redis.Cmder) string {
+	names := make([]string, 0, len(cmds)+1)
+	names = append(names, "pipeline")
+	for _, cmd := range cmds {
+		names = append(names, cmd.FullName())
+	}
+	return strings.Join(names, " ")
+}
+
+// pipelineErr returns the first failure of a pipeline.
+func pipelineErr(cmds []redis.Cmder, err error) error {
+	if isError(err) {
+		return err
+	}
+	for _, cmd := range cmds {
+		if isError(cmd.Err()) {
+			return cmd.Err()
+		}
+	}
+	return nil
+}
+
+// loggingHook logs the commands, without their arguments which may hold sensitive values.
+type loggingHook struct {
+	log logger.Logger
+}
+
+// compile time interface check.
+var _ redis.Hook = &loggingHook{}
+
+func (h *loggingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *loggingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.logCommand(ctx, cmd.FullName(), start, err)
+		return err
+	}
+}
+
+func (h *loggingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.logCommand(ctx, pipelineName(cmds), start, pipelineErr(cmds, err))
+		return err
+	}
+}
+
+func (h *loggingHook) logCommand(ctx context.Context, name string, start time.Time, err error) {
+	l := logger.WithTrace(ctx, h.log.Event(commandEvent).Query(name).Duration(time.Since(start)))
+	if isError(err) {
+		l.Error("Command failed", err)
+		return
+	}
+	l.Debug("Command executed")
+}
+
+// metricsHook observes the latency of the commands and counts the failing ones.
+type metricsHook struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// compile time interface check.
+var _ redis.Hook = &metricsHook{}
+
+func newMetricsHook(registerer prometheus.Registerer) *metricsHook {
+	return &metricsHook{
+		duration: metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "redis_command_duration_seconds",
+			Help:    "Duration of Redis commands.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"command"})),
+		errors: metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "redis_command_errors_total",
+			Help: "Number of failed Redis commands.",
+		}, []string{"command"})),
+	}
+}
+
+func (h *metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.observe(cmd.Name(), start, err)
+		return err
+	}
+}
+
+func (h *metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.observe("pipeline", start, pipelineErr(cmds, err))
+		return err
+	}
+}
+
+func (h *metricsHook) observe(command string, start time.Time, err error) {
+	h.duration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	if isError(err) {
+		h.errors.WithLabelValues(command).Inc()
+	}
+}
+
+// tracingHook creates a client span for every command and pipeline.
+type tracingHook struct{}
+
+// compile time interface check.
+var _ redis.Hook = &tracingHook{}
+
+func (h *tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := h.start(ctx, "redis.dial")
+		defer span.End()
+		conn, err := next(ctx, network, addr)
+		record(span, err)
+		return conn, err
+	}
+}
+
+func (h *tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := h.start(ctx, cmd.FullName())
+		defer span.End()
+		err := next(ctx, cmd)
+		record(span, err)
+		return err
+	}
+}
+
+func (h *tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := h.start(ctx, "pipeline")
+		defer span.End()
+		span.SetAttributes(attribute.String("db.operation.name", pipelineName(cmds)))
+		err := next(ctx, cmds)
+		record(span, pipelineErr(cmds, err))
+		return err
+	}
+}
+
+func (h *tracingHook) start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system.name", "redis")),
+	)
+}
+
+func record(span trace.Span, err error) {
+	if isError(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,34 @@
+package redisx
+
+import (
+	"context"
+	"time"
+
+	"github.com/indiependente/pkg/locks"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrNotObtained is returned when the lock is held by someone else. It is locks.ErrNotAcquired.
+	ErrNotObtained = locks.ErrNotAcquired
+	// ErrNotHeld is returned when releasing or refreshing a lock which expired or was taken over. It is locks.ErrNotHeld.
+	ErrNotHeld = locks.ErrNotHeld
+)
+
+// LockOption customises a lock.
+type LockOption = locks.AcquireOption
+
+// WithAutoRefresh extends the lock every ttl/3 until it is released, so that long running work keeps it.
+// Lost is closed if a refresh finds the lock taken over.
+func WithAutoRefresh() LockOption {
+	return locks.WithAutoRenew()
+}
+
+// Lock is a distributed lock held on a Redis key, as held by locks.Redis.
+type Lock = locks.Lock
+
+// Obtain tries to take the lock on key for ttl, failing with ErrNotObtained if it is held by someone else.
+// It is a shorthand for locks.NewRedis(client).Acquire.
+func Obtain(ctx context.Context, client redis.Cmdable, key string, ttl time.Duration, opts ...LockOption) (*Lock, error) {
+	return locks.NewRedis(client).Acquire(ctx, key, ttl, opts...)
+}
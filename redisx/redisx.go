@@ -0,0 +1,158 @@
+package redisx
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/indiependente/pkg/healthcheck"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultConnectTimeout = 5 * time.Second
+
+// Option customises the client returned by New.
+type Option func(*config)
+
+type config struct {
+	opts           *redis.UniversalOptions
+	connectTimeout time.Duration
+	hooks          []redis.Hook
+	checker        *healthcheck.Checker
+	checkName      string
+}
+
+// WithAddrs sets the addresses of the servers. A single address connects to a standalone server,
+// multiple addresses to a cluster. Defaults to localhost:6379.
+func WithAddrs(addrs ...string) Option {
+	return func(c *config) {
+		c.opts.Addrs = addrs
+	}
+}
+
+// WithSentinel connects to the master in input through the sentinels listening on the addresses set with WithAddrs.
+func WithSentinel(masterName string) Option {
+	return func(c *config) {
+		c.opts.MasterName = masterName
+	}
+}
+
+// WithAuth authenticates with the credentials in input. The username may be empty when using the default user.
+func WithAuth(username, password string) Option {
+	return func(c *config) {
+		c.opts.Username = username
+		c.opts.Password = password
+	}
+}
+
+// WithDB selects the database in input. It is ignored by clusters.
+func WithDB(db int) Option {
+	return func(c *config) {
+		c.opts.DB = db
+	}
+}
+
+// WithTLS connects over TLS with the configuration in input.
+func WithTLS(cfg *tls.Config) Option {
+	return func(c *config) {
+		c.opts.TLSConfig = cfg
+	}
+}
+
+// WithPoolSize sets the maximum number of connections per server.
+func WithPoolSize(n int) Option {
+	return func(c *config) {
+		c.opts.PoolSize = n
+	}
+}
+
+// WithConnectTimeout gives up the initial ping after d. Defaults to 5s.
+func WithConnectTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.connectTimeout = d
+	}
+}
+
+// WithOptions customises the underlying redis.UniversalOptions.
+func WithOptions(fn func(*redis.UniversalOptions)) Option {
+	return func(c *config) {
+		fn(c.opts)
+	}
+}
+
+// WithLogger logs every command at debug level, and failing ones at error level.
+func WithLogger(log logger.Logger) Option {
+	return WithHook(&loggingHook{log: log})
+}
+
+// WithMetrics observes the latency of the commands and counts the failing ones on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(c *config) {
+		c.hooks = append(c.hooks, newMetricsHook(registerer))
+	}
+}
+
+// WithTracing creates a client span for every command and pipeline.
+func WithTracing() Option {
+	return WithHook(&tracingHook{})
+}
+
+// WithHook adds the hook in input to the client.
+func WithHook(h redis.Hook) Option {
+	return func(c *config) {
+		c.hooks = append(c.hooks, h)
+	}
+}
+
+// WithHealthCheck registers a check pinging the servers on the checker in input under the name in input.
+func WithHealthCheck(checker *healthcheck.Checker, name string) Option {
+	return func(c *config) {
+		c.checker = checker
+		c.checkName = name
+	}
+}
+
+// New returns a client connected to a standalone server, a cluster or a sentinel-managed master
+// depending on the options in input, and pings it to make sure it is reachable.
+func New(ctx context.Context, opts ...Option) (redis.UniversalClient, error) {
+	cfg := &config{
+		opts:           &redis.UniversalOptions{Addrs: []string{"localhost:6379"}},
+		connectTimeout: defaultConnectTimeout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	client := redis.NewUniversalClient(cfg.opts)
+	for _, h := range cfg.hooks {
+		client.AddHook(h)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.connectTimeout)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("could not ping redis: %w", err)
+	}
+
+	if cfg.checker != nil {
+		cfg.checker.Register(cfg.checkName, func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		})
+	}
+	return client, nil
+}
+
+// TerminationFn returns a shutdown.TerminationFn closing the client in input.
+func TerminationFn(client redis.UniversalClient) shutdown.TerminationFn {
+	return func(context.Context) error {
+		if err := client.Close(); err != nil {
+			return fmt.Errorf("could not close redis client: %w", err)
+		}
+		return nil
+	}
+}
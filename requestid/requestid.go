@@ -0,0 +1,21 @@
+package requestid
+
+import (
+	"context"
+)
+
+// Header is the default header carrying the request ID.
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the request ID in input.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID carried by the context, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
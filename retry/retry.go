@@ -0,0 +1,245 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/indiependente/pkg/backoff"
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultInitial     = 100 * time.Millisecond
+	defaultMax         = 10 * time.Second
+	defaultMultiplier  = 2
+)
+
+// Jitter defines how the backoff delays are randomised to avoid synchronised retries.
+type Jitter int
+
+const (
+	// NoJitter uses the exact exponential delays.
+	NoJitter Jitter = iota
+	// FullJitter picks a random delay between zero and the exponential delay.
+	FullJitter
+	// EqualJitter keeps half of the exponential delay and randomises the other half.
+	EqualJitter
+	// DecorrelatedJitter picks a random delay between the initial delay and three times the previous one.
+	DecorrelatedJitter
+)
+
+// permanentError marks an error which must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// transientError marks an error which must be retried.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// Permanent wraps the error so that it is not retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Transient wraps the error so that it is retried, regardless of the retry classification.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsPermanent reports whether the error has been marked as permanent.
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// Option customises the retry behaviour.
+type Option func(*config)
+
+type config struct {
+	maxAttempts int
+	maxElapsed  time.Duration
+	initial     time.Duration
+	max         time.Duration
+	multiplier  float64
+	jitter      Jitter
+	strategy    backoff.Strategy
+	retryIf     func(error) bool
+	onRetry     []func(attempt int, err error, wait time.Duration)
+	clock       clock.Clock
+}
+
+// WithMaxAttempts gives up after n attempts. Zero or negative values retry until the context is done
+// or the maximum elapsed time is reached.
+func WithMaxAttempts(n int) Option {
+	return func(c *config) {
+		c.maxAttempts = n
+	}
+}
+
+// WithMaxElapsed gives up when the next attempt would start more than d after the first one.
+func WithMaxElapsed(d time.Duration) Option {
+	return func(c *config) {
+		c.maxElapsed = d
+	}
+}
+
+// WithBackoff waits initial before the first retry, multiplying the delay by multiplier at every retry up to max.
+func WithBackoff(initial, max time.Duration, multiplier float64) Option {
+	return func(c *config) {
+		c.initial = initial
+		c.max = max
+		c.multiplier = multiplier
+	}
+}
+
+// WithStrategy waits the delays of the backoff strategy in input, replacing WithBackoff and WithJitter.
+// The strategy can give up by returning backoff.Stop.
+func WithStrategy(s backoff.Strategy) Option {
+	return func(c *config) {
+		c.strategy = s
+	}
+}
+
+// WithJitter randomises the delays according to the jitter mode in input.
+func WithJitter(j Jitter) Option {
+	return func(c *config) {
+		c.jitter = j
+	}
+}
+
+// WithRetryIf retries only the errors for which the function returns true.
+// Errors wrapped with Transient are always retried, those wrapped with Permanent never are.
+func WithRetryIf(fn func(error) bool) Option {
+	return func(c *config) {
+		c.retryIf = fn
+	}
+}
+
+// WithOnRetry calls the function in input after each failed attempt which is going to be retried.
+func WithOnRetry(fn func(attempt int, err error, wait time.Duration)) Option {
+	return func(c *config) {
+		c.onRetry = append(c.onRetry, fn)
+	}
+}
+
+// WithClock measures the elapsed time and waits between attempts with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
+// WithLogger logs each failed attempt which is going to be retried at warning level.
+func WithLogger(log logger.Logger) Option {
+	return WithOnRetry(func(attempt int, err error, wait time.Duration) {
+		log.Event("retry").Duration(wait).Warn(fmt.Sprintf("Attempt %d failed, retrying: %v", attempt, err))
+	})
+}
+
+// Do calls fn until it succeeds, returns a permanent error, the attempts are exhausted or the context is done.
+// By default it makes 3 attempts with exponential backoff starting at 100ms and full jitter.
+func Do(ctx context.Context, fn func(ctx context.Context) error, opts ...Option) error {
+	_, err := DoValue(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, opts...)
+	return err
+}
+
+// DoValue behaves as Do, returning the value produced by the successful attempt.
+func DoValue[T any](ctx context.Context, fn func(ctx context.Context) (T, error), opts ...Option) (T, error) {
+	cfg := &config{
+		maxAttempts: defaultMaxAttempts,
+		initial:     defaultInitial,
+		max:         defaultMax,
+		multiplier:  defaultMultiplier,
+		jitter:      FullJitter,
+		clock:       clock.New(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var (
+		zero     T
+		start    = cfg.clock.Now()
+		strategy = cfg.backoff()
+		prev     time.Duration
+	)
+	for attempt := 1; ; attempt++ {
+		v, err := fn(ctx)
+		if err == nil {
+			return v, nil
+		}
+		if !cfg.retryable(err) {
+			return zero, err
+		}
+		if cfg.maxAttempts > 0 && attempt >= cfg.maxAttempts {
+			return zero, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		wait := strategy.Delay(attempt, prev)
+		if wait < 0 {
+			return zero, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		prev = wait
+		if cfg.maxElapsed > 0 && cfg.clock.Since(start)+wait > cfg.maxElapsed {
+			return zero, fmt.Errorf("giving up after %s: %w", cfg.clock.Since(start).Round(time.Millisecond), err)
+		}
+		for _, hook := range cfg.onRetry {
+			hook(attempt, err, wait)
+		}
+
+		timer := cfg.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, fmt.Errorf("%w: last error: %v", ctx.Err(), err)
+		case <-timer.C():
+		}
+	}
+}
+
+func (c *config) retryable(err error) bool {
+	var te *transientError
+	if errors.As(err, &te) {
+		return true
+	}
+	if IsPermanent(err) {
+		return false
+	}
+	return c.retryIf == nil || c.retryIf(err)
+}
+
+// backoff returns the strategy computing the delays between attempts.
+func (c *config) backoff() backoff.Strategy {
+	if c.strategy != nil {
+		return c.strategy
+	}
+	exp := backoff.Exponential(c.initial, c.max, c.multiplier)
+	switch c.jitter {
+	case FullJitter:
+		return backoff.FullJitter(exp)
+	case EqualJitter:
+		return backoff.EqualJitter(exp)
+	case DecorrelatedJitter:
+		return backoff.Decorrelated(c.initial, c.max)
+	}
+	return exp
+}
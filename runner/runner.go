@@ -0,0 +1,297 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/indiependente/pkg/admin"
+	"github.com/indiependente/pkg/buildinfo"
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/config"
+	"github.com/indiependente/pkg/debug"
+	"github.com/indiependente/pkg/grpcx"
+	"github.com/indiependente/pkg/healthcheck"
+	"github.com/indiependente/pkg/http/middleware"
+	"github.com/indiependente/pkg/http/server"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/indiependente/pkg/tracing"
+)
+
+const (
+	defaultHTTPAddr        = ":8080"
+	defaultAdminAddr       = ":9091"
+	defaultLogLevel        = "info"
+	defaultShutdownTimeout = 30 * time.Second
+	shutdownEvent          = "shutdown"
+	defaultProbeTimeout    = 3 * time.Second
+)
+
+// Config describes the service started by Run. Only Name and Setup are required.
+type Config struct {
+	// Name is the name of the service, used by the logger and the tracing resource.
+	Name string
+	// Version is the version of the service, logged on every line and reported by tracing.
+	// Defaults to the version in buildinfo.
+	Version string
+	// LogLevel is the minimum level logged. Defaults to info.
+	LogLevel string
+
+	// Settings, if not nil, is a pointer to the configuration struct of the service,
+	// loaded with config.Load and the ConfigOptions before Setup is called.
+	Settings      interface{}
+	ConfigOptions []config.Option
+
+	// HTTPAddr is the address of the HTTP server serving Service.Mux. Defaults to :8080.
+	HTTPAddr string
+	// AdminAddr is the address of the admin server serving /metrics, /livez, /readyz, /healthz, /loglevel and
+	// /buildinfo. Defaults to :9091.
+	AdminAddr string
+	// AdminOptions customise the admin server, e.g. with admin.WithBearerToken, admin.WithDebug or admin.WithFlags.
+	AdminOptions []admin.Option
+	// GRPCAddr is the address of the gRPC server. The gRPC server is only started when set.
+	GRPCAddr string
+	// DebugAddr is the address of the debug server serving pprof and the runtime diagnostics.
+	// The debug server is only started when set.
+	DebugAddr string
+
+	// TracingEndpoint is the URL of the OTLP gRPC collector. Tracing is only set up when set.
+	TracingEndpoint string
+	// TraceSampleRatio is the fraction of root traces sampled. Defaults to 1.
+	TraceSampleRatio float64
+
+	// DrainDelay is how long readiness fails before the servers stop, giving load balancers time to notice.
+	DrainDelay time.Duration
+	// ShutdownTimeout is how long the servers wait for in-flight requests when stopping. Defaults to 30s.
+	ShutdownTimeout time.Duration
+	// SystemdNotify reports the lifecycle of the service to systemd, see shutdown.WithSystemdNotify.
+	SystemdNotify bool
+
+	// HealthcheckFlag is the name of a command line flag, e.g. healthcheck, which makes Run probe the readiness of
+	// the instance already running through the admin server and exit with 0 if ready, 1 otherwise, instead of
+	// starting the service. It lets images without curl declare a Docker HEALTHCHECK running the binary itself.
+	// The flag is ignored when empty.
+	HealthcheckFlag string
+
+	// Setup wires the business logic into the service: it registers HTTP handlers, gRPC services,
+	// health checks, background workers and shutdown hooks.
+	Setup func(ctx context.Context, svc *Service) error
+}
+
+// Service is the skeleton assembled by Run and handed to Config.Setup.
+type Service struct {
+	Name    string
+	Log     logger.Logger
+	Metrics *metrics.Registry
+	Health  *healthcheck.Checker
+	// Mux is served by the HTTP server, behind the request ID, tracing, logging, metrics and recover middlewares.
+	Mux *http.ServeMux
+	// GRPC is the gRPC server, nil unless Config.GRPCAddr is set.
+	GRPC *grpcx.Server
+
+	workers []func(context.Context) error
+	hooks   []shutdown.TerminationFn
+}
+
+// Go runs fn in the background until the service stops. The context passed to fn is cancelled on shutdown
+// and the service waits for fn to return. A non nil error stops the service.
+func (s *Service) Go(fn func(ctx context.Context) error) {
+	s.workers = append(s.workers, fn)
+}
+
+// OnShutdown registers fn to run once the servers and the background workers stopped.
+// Hooks run in reverse registration order.
+func (s *Service) OnShutdown(fn shutdown.TerminationFn) {
+	s.hooks = append(s.hooks, fn)
+}
+
+// Run loads the configuration, builds the logger, sets up tracing and metrics, calls Setup,
+// starts the HTTP, admin and gRPC servers along with the background workers, and blocks until SIGINT or SIGTERM.
+// On shutdown it fails readiness, waits for the drain delay, stops the servers and the workers,
+// then runs the shutdown hooks.
+func Run(cfg Config) error {
+	cfg.withDefaults()
+	if cfg.HealthcheckFlag != "" && hasFlag(os.Args[1:], cfg.HealthcheckFlag) {
+		os.Exit(healthcheck.RunProbe(cfg.AdminAddr, healthcheck.DefaultProbePath, defaultProbeTimeout))
+	}
+	if cfg.Settings != nil {
+		if err := config.Load(cfg.Settings, cfg.ConfigOptions...); err != nil {
+			return fmt.Errorf("could not load config: %w", err)
+		}
+	}
+	log := logger.GetLoggerString(cfg.Name, cfg.LogLevel).Version(cfg.Version)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	svc := &Service{
+		Name:    cfg.Name,
+		Log:     log,
+		Metrics: metrics.New("", ""),
+		Health:  healthcheck.New(),
+		Mux:     http.NewServeMux(),
+	}
+	if cfg.TracingEndpoint != "" {
+		flush, err := tracing.Init(ctx, cfg.Name,
+			tracing.WithEndpointURL(cfg.TracingEndpoint),
+			tracing.WithServiceVersion(cfg.Version),
+			tracing.WithSampleRatio(cfg.TraceSampleRatio),
+		)
+		if err != nil {
+			return fmt.Errorf("could not init tracing: %w", err)
+		}
+		svc.OnShutdown(flush)
+	}
+	if cfg.GRPCAddr != "" {
+		svc.GRPC = grpcx.NewServer(
+			grpcx.WithLogger(log),
+			grpcx.WithAddr(cfg.GRPCAddr),
+			grpcx.WithShutdownTimeout(cfg.ShutdownTimeout),
+		)
+	}
+	if err := cfg.Setup(ctx, svc); err != nil {
+		return fmt.Errorf("could not set up service: %w", err)
+	}
+
+	buildinfo.Register(svc.Metrics)
+	adm := admin.New(append([]admin.Option{
+		admin.WithAddr(cfg.AdminAddr),
+		admin.WithHealth(svc.Health),
+		admin.WithMetrics(svc.Metrics),
+		admin.WithServerOptions(server.WithShutdownTimeout(cfg.ShutdownTimeout)),
+	}, cfg.AdminOptions...)...)
+
+	// panics are recovered innermost so that they are logged and counted as 500s, and the server span wraps
+	// the access log so that it carries the trace and span IDs
+	var handler http.Handler = svc.Mux
+	handler = middleware.Recover(log)(handler)
+	handler = middleware.Metrics(svc.Metrics)(handler)
+	handler = middleware.Logging(log)(handler)
+	if cfg.TracingEndpoint != "" {
+		handler = middleware.Tracing()(handler)
+	}
+	handler = middleware.RequestID()(handler)
+
+	// runCtx outlives the signal so that readiness can fail for the drain delay before the servers stop
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	defer cancelRun()
+	eg, egCtx := conc.WithContext(runCtx)
+	eg.Go(func() error {
+		return server.New(handler, server.WithAddr(cfg.HTTPAddr), server.WithShutdownTimeout(cfg.ShutdownTimeout)).Run(egCtx)
+	})
+	eg.Go(func() error {
+		return adm.Run(egCtx)
+	})
+	if svc.GRPC != nil {
+		eg.Go(func() error {
+			return svc.GRPC.Run(egCtx)
+		})
+	}
+	if cfg.DebugAddr != "" {
+		eg.Go(func() error {
+			return debug.Serve(egCtx, cfg.DebugAddr, server.WithShutdownTimeout(cfg.ShutdownTimeout))
+		})
+	}
+	for _, w := range svc.workers {
+		eg.Go(func() error {
+			return w(egCtx)
+		})
+	}
+	log.Event("startup").Host(cfg.HTTPAddr).Info("Service started")
+	if cfg.SystemdNotify {
+		notify(log, shutdown.SdReady)
+		watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+		defer stopWatchdog()
+		go shutdown.Watchdog(watchdogCtx)
+	}
+
+	select {
+	case <-ctx.Done():
+		if cfg.SystemdNotify {
+			notify(log, shutdown.SdStopping)
+		}
+		log.Event(shutdownEvent).Info("Starting graceful shutdown process")
+		svc.Health.Drain()
+		time.Sleep(cfg.DrainDelay)
+	case <-egCtx.Done():
+		if cfg.SystemdNotify {
+			notify(log, shutdown.SdStopping)
+		}
+		log.Event(shutdownEvent).Warn("A server or worker stopped, shutting down")
+	}
+	cancelRun()
+	runErr := eg.Wait()
+
+	hookErrs := []error{runErr}
+	for i := len(svc.hooks) - 1; i >= 0; i-- {
+		hookErrs = append(hookErrs, svc.hooks[i](runCtx))
+	}
+	if err := errors.Join(hookErrs...); err != nil {
+		return fmt.Errorf("could not terminate gracefully: %w", err)
+	}
+	log.Event(shutdownEvent).Info("Shutdown process complete")
+	return nil
+}
+
+// hasFlag reports whether args set the boolean flag in input, as -name, --name or -name=true.
+// Flags are looked up by hand so that the services remain free to parse theirs with any package.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		flag, value, ok := strings.Cut(arg, "=")
+		if flag != name {
+			continue
+		}
+		if !ok {
+			return true
+		}
+		set, err := strconv.ParseBool(value)
+		return err == nil && set
+	}
+	return false
+}
+
+func notify(log logger.Logger, state string) {
+	if _, err := shutdown.SdNotify(state); err != nil {
+		log.Event(shutdownEvent).Err(err).Warn("Could not notify systemd")
+	}
+}
+
+func (cfg *Config) withDefaults() {
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = defaultLogLevel
+	}
+	if cfg.Version == "" {
+		cfg.Version = buildinfo.Get().Version
+	}
+	if cfg.HTTPAddr == "" {
+		cfg.HTTPAddr = defaultHTTPAddr
+	}
+	if cfg.AdminAddr == "" {
+		cfg.AdminAddr = defaultAdminAddr
+	}
+	if cfg.TraceSampleRatio == 0 {
+		cfg.TraceSampleRatio = 1
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if cfg.Setup == nil {
+		cfg.Setup = func(context.Context, *Service) error { return nil }
+	}
+}
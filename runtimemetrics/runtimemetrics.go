@@ -0,0 +1,395 @@
+package runtimemetrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+	pkgmetrics "github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	runtimeEvent = "runtime"
+
+	defaultInterval = 15 * time.Second
+	// pauseQuantile is the quantile of the GC pauses and scheduling latencies checked against the thresholds.
+	pauseQuantile = 0.99
+
+	metricGoroutines   = "/sched/goroutines:goroutines"
+	metricHeap         = "/memory/classes/heap/objects:bytes"
+	metricGCCycles     = "/gc/cycles/total:gc-cycles"
+	metricGCPauses     = "/sched/pauses/total/gc:seconds"
+	metricSchedLatency = "/sched/latencies:seconds"
+)
+
+// Check names, used as label of the threshold metric.
+const (
+	CheckGoroutines   = "goroutines"
+	CheckHeap         = "heap"
+	CheckGCPause      = "gc_pause"
+	CheckSchedLatency = "sched_latency"
+	CheckLeak         = "goroutine_leak"
+)
+
+// Stats is a sample of the runtime statistics.
+// Pauses and latencies are the 99th percentile of the ones observed since the previous sample.
+type Stats struct {
+	Goroutines      int
+	HeapBytes       uint64
+	GCCycles        uint64
+	GCPauseP99      time.Duration
+	SchedLatencyP99 time.Duration
+}
+
+// Option customises a Collector.
+type Option func(*Collector)
+
+// WithInterval samples the runtime statistics every d. Defaults to 15s.
+func WithInterval(d time.Duration) Option {
+	return func(c *Collector) {
+		c.interval = d
+	}
+}
+
+// WithLogger logs a warning every time a threshold is exceeded, and an info message when back under it.
+func WithLogger(log logger.Logger) Option {
+	return func(c *Collector) {
+		c.log = log
+	}
+}
+
+// WithMetrics registers the sampled statistics and the exceeded thresholds with the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(c *Collector) {
+		c.goroutines = pkgmetrics.Register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "runtime_sampled_goroutines",
+			Help: "Number of goroutines at the last sample.",
+		}))
+		c.heap = pkgmetrics.Register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "runtime_sampled_heap_bytes",
+			Help: "Bytes of heap objects, reachable or not yet collected, at the last sample.",
+		}))
+		c.gcPause = pkgmetrics.Register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "runtime_sampled_gc_pause_p99_seconds",
+			Help: "99th percentile of the GC pauses between the last two samples.",
+		}))
+		c.schedLatency = pkgmetrics.Register(registerer, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "runtime_sampled_sched_latency_p99_seconds",
+			Help: "99th percentile of the time goroutines spent runnable before running, between the last two samples.",
+		}))
+		c.exceeded = pkgmetrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "runtime_threshold_exceeded_total",
+			Help: "Number of times a runtime threshold was exceeded, by check.",
+		}, []string{"check"}))
+	}
+}
+
+// WithGoroutineThreshold warns when there are more than n goroutines.
+func WithGoroutineThreshold(n int) Option {
+	return func(c *Collector) {
+		c.maxGoroutines = n
+	}
+}
+
+// WithHeapThreshold warns when the heap objects take more than n bytes.
+func WithHeapThreshold(n uint64) Option {
+	return func(c *Collector) {
+		c.maxHeap = n
+	}
+}
+
+// WithGCPauseThreshold warns when the 99th percentile of the GC pauses exceeds d.
+func WithGCPauseThreshold(d time.Duration) Option {
+	return func(c *Collector) {
+		c.maxGCPause = d
+	}
+}
+
+// WithSchedLatencyThreshold warns when the 99th percentile of the scheduling latency exceeds d.
+func WithSchedLatencyThreshold(d time.Duration) Option {
+	return func(c *Collector) {
+		c.maxSchedLatency = d
+	}
+}
+
+// WithLeakDetection warns about a likely goroutine leak when the number of goroutines never decreased
+// over the last samples and grew by more than minGrowth (e.g. 0.5 for 50%) over them.
+func WithLeakDetection(samples int, minGrowth float64) Option {
+	return func(c *Collector) {
+		c.leakSamples = samples
+		c.leakGrowth = minGrowth
+	}
+}
+
+// WithClock schedules the samples with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(cl clock.Clock) Option {
+	return func(c *Collector) {
+		c.clock = cl
+	}
+}
+
+// Collector samples the runtime statistics on an interval, exporting them to Prometheus and warning
+// when they exceed the configured thresholds.
+type Collector struct {
+	interval        time.Duration
+	log             logger.Logger
+	clock           clock.Clock
+	maxGoroutines   int
+	maxHeap         uint64
+	maxGCPause      time.Duration
+	maxSchedLatency time.Duration
+	leakSamples     int
+	leakGrowth      float64
+
+	goroutines   prometheus.Gauge
+	heap         prometheus.Gauge
+	gcPause      prometheus.Gauge
+	schedLatency prometheus.Gauge
+	exceeded     *prometheus.CounterVec
+
+	mu         sync.Mutex
+	samples    []metrics.Sample
+	prevPauses *metrics.Float64Histogram
+	prevSched  *metrics.Float64Histogram
+	history    []int
+	tripped    map[string]bool
+	last       Stats
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New returns a Collector customised by the options in input.
+func New(opts ...Option) *Collector {
+	c := &Collector{
+		interval: defaultInterval,
+		clock:    clock.New(),
+		samples: []metrics.Sample{
+			{Name: metricGoroutines},
+			{Name: metricHeap},
+			{Name: metricGCCycles},
+			{Name: metricGCPauses},
+			{Name: metricSchedLatency},
+		},
+		tripped: make(map[string]bool),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run samples the runtime statistics until ctx is done or the collector is stopped with Stop.
+// It is meant to be run in its own goroutine.
+func (c *Collector) Run(ctx context.Context) {
+	defer close(c.done)
+	ticker := c.clock.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.Sample()
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Sample reads the runtime statistics, exports them and checks them against the thresholds.
+func (c *Collector) Sample() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metrics.Read(c.samples)
+	var s Stats
+	for _, sample := range c.samples {
+		switch sample.Name {
+		case metricGoroutines:
+			s.Goroutines = int(uint64Value(sample.Value))
+		case metricHeap:
+			s.HeapBytes = uint64Value(sample.Value)
+		case metricGCCycles:
+			s.GCCycles = uint64Value(sample.Value)
+		case metricGCPauses:
+			h := histogramValue(sample.Value)
+			s.GCPauseP99 = quantile(h, c.prevPauses, pauseQuantile)
+			c.prevPauses = h
+		case metricSchedLatency:
+			h := histogramValue(sample.Value)
+			s.SchedLatencyP99 = quantile(h, c.prevSched, pauseQuantile)
+			c.prevSched = h
+		}
+	}
+	c.last = s
+	c.export(s)
+	c.check(s)
+	return s
+}
+
+// Last returns the latest sample.
+func (c *Collector) Last() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Stop stops Run, waiting for it to return until ctx is done.
+func (c *Collector) Stop(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not stop runtime metrics collector: %w", ctx.Err())
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn stopping the collector within timeout.
+func (c *Collector) TerminationFn(timeout time.Duration) shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return c.Stop(ctx)
+	}
+}
+
+func (c *Collector) export(s Stats) {
+	if c.goroutines == nil {
+		return
+	}
+	c.goroutines.Set(float64(s.Goroutines))
+	c.heap.Set(float64(s.HeapBytes))
+	c.gcPause.Set(s.GCPauseP99.Seconds())
+	c.schedLatency.Set(s.SchedLatencyP99.Seconds())
+}
+
+// check compares the sample with the thresholds, reporting the ones exceeded.
+func (c *Collector) check(s Stats) {
+	if c.maxGoroutines > 0 {
+		c.report(CheckGoroutines, s.Goroutines > c.maxGoroutines,
+			fmt.Sprintf("%d goroutines, threshold %d", s.Goroutines, c.maxGoroutines))
+	}
+	if c.maxHeap > 0 {
+		c.report(CheckHeap, s.HeapBytes > c.maxHeap,
+			fmt.Sprintf("%d heap bytes, threshold %d", s.HeapBytes, c.maxHeap))
+	}
+	if c.maxGCPause > 0 {
+		c.report(CheckGCPause, s.GCPauseP99 > c.maxGCPause,
+			fmt.Sprintf("GC pause p99 %s, threshold %s", s.GCPauseP99, c.maxGCPause))
+	}
+	if c.maxSchedLatency > 0 {
+		c.report(CheckSchedLatency, s.SchedLatencyP99 > c.maxSchedLatency,
+			fmt.Sprintf("scheduling latency p99 %s, threshold %s", s.SchedLatencyP99, c.maxSchedLatency))
+	}
+	if c.leakSamples > 1 {
+		c.history = append(c.history, s.Goroutines)
+		if len(c.history) > c.leakSamples {
+			c.history = c.history[len(c.history)-c.leakSamples:]
+		}
+		leaking, growth := c.leaking()
+		c.report(CheckLeak, leaking,
+			fmt.Sprintf("goroutines grew by %.0f%% over the last %d samples without decreasing, possible leak", growth*100, len(c.history)))
+	}
+}
+
+// leaking reports whether the goroutines never decreased over a full history and grew more than the minimum.
+func (c *Collector) leaking() (bool, float64) {
+	if len(c.history) < c.leakSamples {
+		return false, 0
+	}
+	for i := 1; i < len(c.history); i++ {
+		if c.history[i] < c.history[i-1] {
+			return false, 0
+		}
+	}
+	first, last := c.history[0], c.history[len(c.history)-1]
+	if first == 0 {
+		return false, 0
+	}
+	growth := float64(last-first) / float64(first)
+	return growth > c.leakGrowth, growth
+}
+
+// report logs a warning when the check starts failing and an info message when it recovers,
+// so that a threshold exceeded for a long time is not logged at every sample.
+func (c *Collector) report(check string, exceeded bool, msg string) {
+	was := c.tripped[check]
+	c.tripped[check] = exceeded
+	if exceeded && c.exceeded != nil {
+		c.exceeded.WithLabelValues(check).Inc()
+	}
+	if c.log == nil || exceeded == was {
+		return
+	}
+	l := c.log.Event(runtimeEvent).Component(check)
+	if exceeded {
+		l.Warn("Runtime threshold exceeded: " + msg)
+		return
+	}
+	l.Info("Runtime back under threshold")
+}
+
+func uint64Value(v metrics.Value) uint64 {
+	if v.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return v.Uint64()
+}
+
+func histogramValue(v metrics.Value) *metrics.Float64Histogram {
+	if v.Kind() != metrics.KindFloat64Histogram {
+		return nil
+	}
+	h := v.Float64Histogram()
+	// the runtime reuses the histogram memory across reads
+	return &metrics.Float64Histogram{
+		Counts:  append([]uint64(nil), h.Counts...),
+		Buckets: h.Buckets,
+	}
+}
+
+// quantile returns the quantile of the observations recorded in cur since prev, taking the upper bound of the bucket.
+func quantile(cur, prev *metrics.Float64Histogram, q float64) time.Duration {
+	if cur == nil {
+		return 0
+	}
+	counts := cur.Counts
+	if prev != nil && len(prev.Counts) == len(counts) {
+		counts = make([]uint64, len(cur.Counts))
+		for i := range counts {
+			counts[i] = cur.Counts[i] - prev.Counts[i]
+		}
+	}
+	var total uint64
+	for _, n := range counts {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, n := range counts {
+		seen += n
+		if seen >= rank {
+			upper := cur.Buckets[i+1]
+			if math.IsInf(upper, 1) {
+				upper = cur.Buckets[i]
+			}
+			return time.Duration(upper * float64(time.Second))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,413 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	// ErrPanic is wrapped by the error of a run which panicked, a *conc.PanicError carrying the stack trace.
+	ErrPanic = conc.ErrPanic
+	// ErrDuplicate is returned when registering a job with the name of an existing one.
+	ErrDuplicate = errors.New("job already registered")
+)
+
+// Job is a unit of work run by the scheduler.
+type Job func(ctx context.Context) error
+
+// Overlap defines what happens when a job is due while its previous run is still in progress.
+type Overlap int
+
+const (
+	// Skip drops the run.
+	Skip Overlap = iota
+	// Queue runs it once the previous run completes. At most one run is kept waiting.
+	Queue
+	// Concurrent runs it alongside the previous run.
+	Concurrent
+)
+
+// Option customises the Scheduler.
+type Option func(*Scheduler)
+
+// WithClock schedules the jobs with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = c
+	}
+}
+
+// WithMetrics records the runs, their latency and the skipped runs of every job with the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(s *Scheduler) {
+		s.runs = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scheduler_job_runs_total",
+			Help: "Number of job runs by outcome.",
+		}, []string{"job", "outcome"}))
+		s.latency = metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scheduler_job_duration_seconds",
+			Help:    "Duration of the job runs.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"job"}))
+		s.skipped = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scheduler_job_skipped_total",
+			Help: "Number of job runs skipped because the previous run was still in progress.",
+		}, []string{"job"}))
+	}
+}
+
+// JobOption customises a registered job.
+type JobOption func(*job)
+
+// WithOverlap sets what happens when the job is due while still running. Defaults to Skip.
+func WithOverlap(o Overlap) JobOption {
+	return func(j *job) {
+		j.overlap = o
+	}
+}
+
+// WithJitter delays every run by a random duration up to d, spreading the load of jobs due at the same time.
+func WithJitter(d time.Duration) JobOption {
+	return func(j *job) {
+		j.jitter = d
+	}
+}
+
+// WithTimeout cancels the context of a run after d.
+func WithTimeout(d time.Duration) JobOption {
+	return func(j *job) {
+		j.timeout = d
+	}
+}
+
+// Status reports the state of a job.
+type Status struct {
+	Name         string    `json:"name"`
+	Spec         string    `json:"spec"`
+	Running      int       `json:"running"`
+	Runs         uint64    `json:"runs"`
+	Failures     uint64    `json:"failures"`
+	LastRun      time.Time `json:"last_run,omitzero"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	NextRun      time.Time `json:"next_run,omitzero"`
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule cron.Schedule
+	fn       Job
+	overlap  Overlap
+	jitter   time.Duration
+	timeout  time.Duration
+	queue    chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+// every runs a job at a fixed interval.
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Scheduler runs jobs on cron schedules or fixed intervals.
+type Scheduler struct {
+	log     logger.Logger
+	clock   clock.Clock
+	runs    *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+	skipped *prometheus.CounterVec
+
+	mu         sync.Mutex
+	jobs       map[string]*job
+	loopCtx    context.Context
+	stopLoops  context.CancelFunc
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+	stopped    bool
+	wg         sync.WaitGroup
+}
+
+// New returns a scheduler logging the failed runs with the logger in input.
+func New(log logger.Logger, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		log:   log,
+		clock: clock.New(),
+		jobs:  make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register schedules the job under the name in input. The spec is either a duration, e.g. 30s,
+// or a cron expression in the standard five fields format, also supporting descriptors such as @hourly and @every 5m.
+// Jobs registered after Start are scheduled immediately.
+func (s *Scheduler) Register(name, spec string, fn Job, opts ...JobOption) error {
+	var schedule cron.Schedule
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return fmt.Errorf("invalid interval %s for job %s", spec, name)
+		}
+		schedule = every(d)
+	} else {
+		schedule, err = cron.ParseStandard(spec)
+		if err != nil {
+			return fmt.Errorf("could not parse schedule of job %s: %w", name, err)
+		}
+	}
+	j := &job{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		fn:       fn,
+		status:   Status{Name: name, Spec: spec},
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicate, name)
+	}
+	s.jobs[name] = j
+	if s.loopCtx != nil && !s.stopped {
+		s.start(j)
+	}
+	return nil
+}
+
+// Start schedules the registered jobs. Runs are cancelled when ctx is done or when Stop times out.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loopCtx != nil || s.stopped {
+		return
+	}
+	s.jobCtx, s.cancelJobs = context.WithCancel(ctx)
+	s.loopCtx, s.stopLoops = context.WithCancel(s.jobCtx)
+	for _, j := range s.jobs {
+		s.start(j)
+	}
+}
+
+// start runs the scheduling loop of the job. It must be called with the lock held.
+func (s *Scheduler) start(j *job) {
+	if j.overlap == Queue {
+		j.queue = make(chan struct{}, 1)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for {
+				select {
+				case <-s.loopCtx.Done():
+					return
+				case <-j.queue:
+					j.begin()
+					s.run(j)
+				}
+			}
+		}()
+	}
+	s.wg.Add(1)
+	go s.loop(j)
+}
+
+func (s *Scheduler) loop(j *job) {
+	defer s.wg.Done()
+	for {
+		now := s.clock.Now()
+		next := j.schedule.Next(now)
+		if j.jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(j.jitter)))) // nolint:gosec
+		}
+		j.mu.Lock()
+		j.status.NextRun = next
+		j.mu.Unlock()
+
+		timer := s.clock.NewTimer(next.Sub(now))
+		select {
+		case <-s.loopCtx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		s.dispatch(j)
+	}
+}
+
+// dispatch starts a run of the job according to its overlap policy.
+func (s *Scheduler) dispatch(j *job) {
+	if j.overlap == Queue {
+		select {
+		case j.queue <- struct{}{}:
+		default:
+			s.skip(j)
+		}
+		return
+	}
+	j.mu.Lock()
+	if j.overlap == Skip && j.status.Running > 0 {
+		j.mu.Unlock()
+		s.skip(j)
+		return
+	}
+	j.status.Running++
+	j.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(j)
+	}()
+}
+
+// begin marks a run of the job as in progress.
+func (j *job) begin() {
+	j.mu.Lock()
+	j.status.Running++
+	j.mu.Unlock()
+}
+
+func (s *Scheduler) skip(j *job) {
+	if s.skipped != nil {
+		s.skipped.WithLabelValues(j.name).Inc()
+	}
+	s.log.Event("job_skipped").Debug(fmt.Sprintf("Job %s is still running, skipping run", j.name))
+}
+
+// run runs the job once, recovering panics and recording the outcome. The caller must mark the run as in progress.
+func (s *Scheduler) run(j *job) {
+	ctx := s.jobCtx
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	start := s.clock.Now()
+	err := conc.Catch(func() error {
+		return j.fn(ctx)
+	})
+	took := s.clock.Since(start)
+
+	j.mu.Lock()
+	j.status.Running--
+	j.status.Runs++
+	j.status.LastRun = start
+	j.status.LastDuration = took.String()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+	}
+	j.mu.Unlock()
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		log := s.log.Event("job_failed").Duration(took)
+		var pe *conc.PanicError
+		if errors.As(err, &pe) {
+			log = log.Stack(string(pe.Stack))
+		}
+		log.Error(fmt.Sprintf("Job %s failed", j.name), err)
+	}
+	if s.runs != nil {
+		s.runs.WithLabelValues(j.name, outcome).Inc()
+		s.latency.WithLabelValues(j.name).Observe(took.Seconds())
+	}
+}
+
+// Stop stops scheduling new runs and waits for the running ones to complete, or for ctx to be done,
+// in which case the running jobs are cancelled.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped = true
+	stopLoops, cancelJobs := s.stopLoops, s.cancelJobs
+	s.mu.Unlock()
+	if stopLoops == nil {
+		return nil
+	}
+
+	stopLoops()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		cancelJobs()
+		return nil
+	case <-ctx.Done():
+		cancelJobs()
+		return fmt.Errorf("could not wait for running jobs: %w", ctx.Err())
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn stopping the scheduler within timeout.
+func (s *Scheduler) TerminationFn(timeout time.Duration) shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return s.Stop(ctx)
+	}
+}
+
+// Status returns the status of every job, sorted by name.
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	out := make([]Status, len(jobs))
+	for i, j := range jobs {
+		j.mu.Lock()
+		out[i] = j.status
+		j.mu.Unlock()
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Name < out[k].Name })
+	return out
+}
+
+// Handler returns an HTTP handler writing the status of the jobs as JSON.
+// It responds with 503 Service Unavailable if the last run of any job failed.
+func (s *Scheduler) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := s.Status()
+		code := http.StatusOK
+		for _, st := range status {
+			if st.LastError != "" {
+				code = http.StatusServiceUnavailable
+				break
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(status)
+	})
+}
@@ -0,0 +1,65 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/indiependente/pkg/secrets"
+)
+
+// API is the subset of the Secrets Manager client used by the provider, satisfied by *secretsmanager.Client.
+type API interface {
+	GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// Provider reads secrets from AWS Secrets Manager.
+type Provider struct {
+	client API
+}
+
+// compile time interface check.
+var _ secrets.Provider = &Provider{}
+
+// New returns a provider reading secrets through the client in input.
+func New(client API) *Provider {
+	return &Provider{client: client}
+}
+
+// Get returns the current value of the secret, or ErrNotFound if it does not exist.
+// A name in the form id#key reads the key of a secret storing a JSON object, e.g. prod/db#password.
+func (p *Provider) Get(ctx context.Context, name string) (string, error) {
+	id, key, hasKey := strings.Cut(name, "#")
+	out, err := p.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		var nf *types.ResourceNotFoundException
+		if errors.As(err, &nf) {
+			return "", fmt.Errorf("%w: %s", secrets.ErrNotFound, id)
+		}
+		return "", fmt.Errorf("could not get secret %s: %w", id, err)
+	}
+	value := aws.ToString(out.SecretString)
+	if out.SecretString == nil {
+		value = string(out.SecretBinary)
+	}
+	if !hasKey {
+		return value, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("could not decode secret %s: %w", id, err)
+	}
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", secrets.ErrNotFound, name)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
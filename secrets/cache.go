@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+)
+
+// CacheOption customises the Cache returned by NewCache.
+type CacheOption func(*Cache)
+
+// WithClock measures the age of the cached secrets with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) CacheOption {
+	return func(cache *Cache) {
+		cache.clock = c
+	}
+}
+
+// WithLogger logs the secrets which could not be refreshed at warning level.
+func WithLogger(log logger.Logger) CacheOption {
+	return func(c *Cache) {
+		c.log = log
+	}
+}
+
+type cached struct {
+	value   string
+	fetched time.Time
+}
+
+// Cache caches the secrets retrieved from a provider for a TTL and notifies rotations.
+type Cache struct {
+	provider Provider
+	ttl      time.Duration
+	clock    clock.Clock
+	log      logger.Logger
+
+	mu       sync.Mutex
+	entries  map[string]cached
+	watchers map[string][]func(value string)
+}
+
+// compile time interface check.
+var _ Provider = &Cache{}
+
+// NewCache returns a provider caching the secrets retrieved from the provider in input for ttl.
+func NewCache(p Provider, ttl time.Duration, opts ...CacheOption) *Cache {
+	c := &Cache{
+		provider: p,
+		ttl:      ttl,
+		clock:    clock.New(),
+		entries:  make(map[string]cached),
+		watchers: make(map[string][]func(string)),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get returns the cached secret, retrieving it from the provider once the TTL has expired.
+// If the provider fails, the expired value is returned, if any, so that an outage of the secret store
+// does not break a running service.
+func (c *Cache) Get(ctx context.Context, name string) (string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && c.clock.Since(e.fetched) < c.ttl {
+		return e.value, nil
+	}
+	v, err := c.fetch(ctx, name)
+	if err != nil {
+		if ok && !errors.Is(err, ErrNotFound) {
+			c.warn(name, err)
+			return e.value, nil
+		}
+		return "", err
+	}
+	return v, nil
+}
+
+// OnRotate calls fn with the new value of the secret every time a refresh finds it changed.
+func (c *Cache) OnRotate(name string, fn func(value string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchers[name] = append(c.watchers[name], fn)
+}
+
+// Refresh retrieves again every cached secret, notifying the rotated ones.
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.entries))
+	for name := range c.entries {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, name := range names {
+		if _, err := c.fetch(ctx, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Watch refreshes the cached secrets every interval until the context is done, so that rotations are noticed
+// even if the secrets are not read. It is meant to be run in its own goroutine.
+func (c *Cache) Watch(ctx context.Context, interval time.Duration) {
+	ticker := c.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := c.Refresh(ctx); err != nil {
+				c.warn("", err)
+			}
+		}
+	}
+}
+
+// fetch retrieves the secret from the provider, caching it and notifying the watchers if it changed.
+func (c *Cache) fetch(ctx context.Context, name string) (string, error) {
+	v, err := c.provider.Get(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	prev, existed := c.entries[name]
+	c.entries[name] = cached{value: v, fetched: c.clock.Now()}
+	var watchers []func(string)
+	if existed && prev.value != v {
+		watchers = append(watchers, c.watchers[name]...)
+	}
+	c.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(v)
+	}
+	return v, nil
+}
+
+func (c *Cache) warn(name string, err error) {
+	if c.log == nil {
+		return
+	}
+	msg := "Could not refresh secrets: " + err.Error()
+	if name != "" {
+		msg = "Could not refresh secret " + name + ": " + err.Error()
+	}
+	c.log.Event("secret_refresh").Warn(msg)
+}
@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by providers when the secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider retrieves secrets by name.
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+// Get calls f(ctx, name).
+func (f ProviderFunc) Get(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// Env reads secrets from environment variables.
+type Env struct {
+	prefix string
+}
+
+// compile time interface check.
+var _ Provider = &Env{}
+
+// NewEnv returns a provider reading the secret named e.g. db/password from the variable PREFIX_DB_PASSWORD.
+// Names are upper-cased and every character other than letters and digits is replaced by an underscore.
+func NewEnv(prefix string) *Env {
+	return &Env{prefix: prefix}
+}
+
+// Get returns the value of the environment variable of the secret, or ErrNotFound if it is not set.
+func (e *Env) Get(_ context.Context, name string) (string, error) {
+	key := envName(name)
+	if e.prefix != "" {
+		key = envName(e.prefix) + "_" + key
+	}
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return v, nil
+}
+
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// File reads secrets from files in a directory, as mounted by Docker (/run/secrets) and Kubernetes.
+type File struct {
+	dir string
+}
+
+// compile time interface check.
+var _ Provider = &File{}
+
+// NewFile returns a provider reading the secret named e.g. db/password from the file dir/db/password.
+func NewFile(dir string) *File {
+	return &File{dir: dir}
+}
+
+// Get returns the content of the file of the secret without trailing newlines, or ErrNotFound if it does not exist.
+func (f *File) Get(_ context.Context, name string) (string, error) {
+	path := filepath.Join(f.dir, filepath.FromSlash(filepath.Clean("/"+name)))
+	b, err := os.ReadFile(path) // nolint:gosec
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read secret file: %w", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Chain returns a provider asking the providers in input in order, moving to the next one when a secret is not found.
+func Chain(providers ...Provider) Provider {
+	return ProviderFunc(func(ctx context.Context, name string) (string, error) {
+		for _, p := range providers {
+			v, err := p.Get(ctx, name)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return v, err
+		}
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	})
+}
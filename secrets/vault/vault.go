@@ -0,0 +1,127 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/indiependente/pkg/secrets"
+)
+
+const (
+	defaultMount = "secret"
+	defaultKey   = "value"
+	tokenHeader  = "X-Vault-Token"
+	nsHeader     = "X-Vault-Namespace"
+)
+
+// Option customises the Vault provider.
+type Option func(*Provider)
+
+// WithMount reads secrets from the KV version 2 engine mounted at the path in input. Defaults to secret.
+func WithMount(mount string) Option {
+	return func(p *Provider) {
+		p.mount = strings.Trim(mount, "/")
+	}
+}
+
+// WithNamespace sends requests to the Vault Enterprise namespace in input.
+func WithNamespace(ns string) Option {
+	return func(p *Provider) {
+		p.namespace = ns
+	}
+}
+
+// WithHTTPClient sends requests with the client in input instead of http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(p *Provider) {
+		p.client = c
+	}
+}
+
+// Provider reads secrets from the KV version 2 engine of HashiCorp Vault.
+type Provider struct {
+	addr      string
+	token     string
+	mount     string
+	namespace string
+	client    *http.Client
+}
+
+// compile time interface check.
+var _ secrets.Provider = &Provider{}
+
+// New returns a provider reading secrets from the Vault server at addr, e.g. https://vault:8200,
+// authenticating with the token in input.
+func New(addr, token string, opts ...Option) *Provider {
+	p := &Provider{
+		addr:   strings.TrimRight(addr, "/"),
+		token:  token,
+		mount:  defaultMount,
+		client: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+type kvResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+}
+
+// Get returns the latest version of the secret, or ErrNotFound if it does not exist.
+// A name in the form path#key reads the key of the secret at path, e.g. db/prod#password;
+// without a key, the key named value is read.
+func (p *Provider) Get(ctx context.Context, name string) (string, error) {
+	path, key, ok := strings.Cut(name, "#")
+	if !ok {
+		key = defaultKey
+	}
+	u := p.addr + "/v1/" + p.mount + "/data/" + escapePath(strings.Trim(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set(tokenHeader, p.token)
+	if p.namespace != "" {
+		req.Header.Set(nsHeader, p.namespace)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not get secret %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%w: %s", secrets.ErrNotFound, path)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("could not get secret %s: unexpected status %d", path, resp.StatusCode)
+	}
+	var kv kvResponse
+	if err := json.NewDecoder(resp.Body).Decode(&kv); err != nil {
+		return "", fmt.Errorf("could not decode secret %s: %w", path, err)
+	}
+	v, ok := kv.Data.Data[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", secrets.ErrNotFound, name)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,244 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/logger"
+)
+
+var (
+	// ErrDuplicateHook is returned when registering a hook under a name already in use.
+	ErrDuplicateHook = errors.New("shutdown hook already registered")
+	// ErrCycle is returned when registering a hook would make the dependencies circular.
+	ErrCycle = errors.New("shutdown hooks dependency cycle")
+)
+
+// HookOption customises a hook registered in a Manager.
+type HookOption func(*hook)
+
+// Before terminates the hook before the hooks in input, e.g. a consumer before the database pool it writes to.
+func Before(names ...string) HookOption {
+	return func(h *hook) {
+		h.before = append(h.before, names...)
+	}
+}
+
+// After terminates the hook once the hooks in input terminated, e.g. the tracer flush after everything else.
+func After(names ...string) HookOption {
+	return func(h *hook) {
+		h.after = append(h.after, names...)
+	}
+}
+
+// WithHookTimeout runs the hook with a context done after d, detached from the cancellation of the termination
+// context, so that a stuck hook does not hold the hooks depending on it forever.
+func WithHookTimeout(d time.Duration) HookOption {
+	return func(h *hook) {
+		h.timeout = d
+	}
+}
+
+// ManagerOption customises a Manager.
+type ManagerOption func(*Manager)
+
+// WithManagerLogger logs the termination of every hook.
+func WithManagerLogger(log logger.Logger) ManagerOption {
+	return func(m *Manager) {
+		m.log = log
+	}
+}
+
+type hook struct {
+	name    string
+	fn      TerminationFn
+	before  []string
+	after   []string
+	timeout time.Duration
+}
+
+// Manager terminates the resources of a service following the dependencies between their hooks, e.g.
+// "kafka-consumer" before "db-pool", both before "tracer-flush", running the independent hooks concurrently.
+// Dependencies may name hooks not registered yet, or never registered, e.g. optional components.
+type Manager struct {
+	log logger.Logger
+
+	mu    sync.Mutex
+	hooks map[string]*hook
+	names []string
+}
+
+// NewManager returns a Manager without hooks.
+func NewManager(opts ...ManagerOption) *Manager {
+	m := &Manager{hooks: make(map[string]*hook)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Register adds the hook under the name in input, returning ErrDuplicateHook if the name is in use
+// and ErrCycle, without registering it, if its dependencies are circular.
+func (m *Manager) Register(name string, fn TerminationFn, opts ...HookOption) error {
+	h := &hook{name: name, fn: fn}
+	for _, opt := range opts {
+		opt(h)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.hooks[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateHook, name)
+	}
+	m.hooks[name] = h
+	m.names = append(m.names, name)
+	if _, err := m.stages(); err != nil {
+		delete(m.hooks, name)
+		m.names = m.names[:len(m.names)-1]
+		return err
+	}
+	return nil
+}
+
+// Order returns the names of the hooks by stage, in the order they terminate: the hooks of a stage only depend on the
+// hooks of the previous stages. Hooks start as soon as their dependencies terminated, so a hook may start before the
+// whole previous stage terminated.
+func (m *Manager) Order() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stages, _ := m.stages()
+	return stages
+}
+
+// deps returns the names of the registered hooks each hook waits for. It must be called with the lock held.
+func (m *Manager) deps() map[string][]string {
+	deps := make(map[string][]string, len(m.hooks))
+	for _, name := range m.names {
+		h := m.hooks[name]
+		for _, a := range h.after {
+			if _, ok := m.hooks[a]; ok && !slices.Contains(deps[name], a) {
+				deps[name] = append(deps[name], a)
+			}
+		}
+		for _, b := range h.before {
+			if _, ok := m.hooks[b]; ok && !slices.Contains(deps[b], name) {
+				deps[b] = append(deps[b], name)
+			}
+		}
+	}
+	return deps
+}
+
+// stages sorts the hooks topologically, returning ErrCycle if they cannot be. It must be called with the lock held.
+func (m *Manager) stages() ([][]string, error) {
+	deps := m.deps()
+	pending := make(map[string]int, len(m.names))
+	dependents := make(map[string][]string)
+	for _, name := range m.names {
+		pending[name] = len(deps[name])
+		for _, d := range deps[name] {
+			dependents[d] = append(dependents[d], name)
+		}
+	}
+	var stages [][]string
+	var ready []string
+	for _, name := range m.names {
+		if pending[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	sorted := 0
+	for len(ready) > 0 {
+		stages = append(stages, ready)
+		sorted += len(ready)
+		var next []string
+		for _, name := range ready {
+			for _, d := range dependents[name] {
+				if pending[d]--; pending[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+		ready = next
+	}
+	if sorted < len(m.names) {
+		var cycle []string
+		for _, name := range m.names {
+			if pending[name] > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", ErrCycle, strings.Join(cycle, ", "))
+	}
+	return stages, nil
+}
+
+// Terminate runs the hooks, each as soon as the hooks it depends on terminated, whether they failed or not,
+// and returns their errors joined. Panics are recovered and returned as errors.
+func (m *Manager) Terminate(ctx context.Context) error {
+	m.mu.Lock()
+	deps := m.deps()
+	hooks := make([]*hook, 0, len(m.names))
+	for _, name := range m.names {
+		hooks = append(hooks, m.hooks[name])
+	}
+	m.mu.Unlock()
+
+	done := make(map[string]chan struct{}, len(hooks))
+	for _, h := range hooks {
+		done[h.name] = make(chan struct{})
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, h := range hooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[h.name])
+			for _, d := range deps[h.name] {
+				<-done[d]
+			}
+			if err := m.run(ctx, h); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("could not terminate %s: %w", h.name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// TerminationFn returns a TerminationFn running the hooks, e.g. to be passed to Wait or to runner's OnShutdown.
+func (m *Manager) TerminationFn() TerminationFn {
+	return m.Terminate
+}
+
+func (m *Manager) run(ctx context.Context, h *hook) error {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), h.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := conc.Catch(func() error {
+		return h.fn(ctx)
+	})
+	if m.log != nil {
+		l := m.log.Event("shutdown").Component(h.name).Duration(time.Since(start))
+		if err != nil {
+			l.Error("Could not terminate", err)
+		} else {
+			l.Info("Terminated")
+		}
+	}
+	return err
+}
@@ -7,19 +7,65 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/indiependente/pkg/conc"
 	"github.com/indiependente/pkg/logger"
-	"golang.org/x/sync/errgroup"
 )
 
 // TerminationFn is a callback invoked on context cancellation.
+// The context it receives is the one passed to Wait, which is already cancelled: implementations needing a
+// deadline derive it from context.WithoutCancel(ctx), keeping its values.
 type TerminationFn func(context.Context) error
 
+// Option customises Wait and WaitWithLogger.
+type Option func(*config)
+
+type config struct {
+	systemd bool
+}
+
+// WithSystemdNotify reports the lifecycle of the service to systemd via the notify protocol: READY=1 once waiting
+// starts, STOPPING=1 when the termination signal is received and, if WatchdogSec is set, WATCHDOG=1 keepalives until
+// the termination completes. It is a no-op when the service is not run by systemd.
+func WithSystemdNotify() Option {
+	return func(c *config) {
+		c.systemd = true
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// notify sends the state to systemd if enabled, logging the failures when a logger is given.
+func (c *config) notify(state string, log logger.Logger) {
+	if !c.systemd {
+		return
+	}
+	if _, err := SdNotify(state); err != nil && log != nil {
+		log.Event("shutdown").Err(err).Warn("Could not notify systemd")
+	}
+}
+
+// watchdog sends the keepalives to the systemd watchdog if enabled, until the returned function is called.
+func (c *config) watchdog() context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	if c.systemd {
+		go Watchdog(ctx)
+	}
+	return cancel
+}
+
 // Wait allows the service to wait for a termination signal, start the cancellation process by calling
 // the context.CancelFunc in order to perform a graceful service shutdown executing the TerminationFn in input.
-func Wait(ctx context.Context, cancel context.CancelFunc, termFn TerminationFn) error {
+func Wait(ctx context.Context, cancel context.CancelFunc, termFn TerminationFn, opts ...Option) error {
 	var (
 		gracefulStop = make(chan os.Signal, 1)
-		eg           errgroup.Group
+		eg           conc.Group
+		cfg          = newConfig(opts)
 	)
 
 	// Get notified for incoming signals
@@ -32,8 +78,13 @@ func Wait(ctx context.Context, cancel context.CancelFunc, termFn TerminationFn)
 		return termFn(ctx)
 	})
 
+	cfg.notify(SdReady, nil)
+	stopWatchdog := cfg.watchdog()
+	defer stopWatchdog()
+
 	// Wait for signal
 	<-gracefulStop
+	cfg.notify(SdStopping, nil)
 
 	// Propagate context cancelling
 	cancel()
@@ -48,10 +99,11 @@ func Wait(ctx context.Context, cancel context.CancelFunc, termFn TerminationFn)
 }
 
 // WaitWithLogger is similar to Wait but it logs on status updates.
-func WaitWithLogger(ctx context.Context, cancel context.CancelFunc, termFn TerminationFn, logger logger.Logger) error {
+func WaitWithLogger(ctx context.Context, cancel context.CancelFunc, termFn TerminationFn, logger logger.Logger, opts ...Option) error {
 	var (
 		gracefulStop = make(chan os.Signal, 1)
-		eg           errgroup.Group
+		eg           conc.Group
+		cfg          = newConfig(opts)
 	)
 
 	// Get notified for incoming signals
@@ -64,8 +116,13 @@ func WaitWithLogger(ctx context.Context, cancel context.CancelFunc, termFn Termi
 		return termFn(ctx)
 	})
 
+	cfg.notify(SdReady, logger)
+	stopWatchdog := cfg.watchdog()
+	defer stopWatchdog()
+
 	// Wait for signal
 	sig := <-gracefulStop
+	cfg.notify(SdStopping, logger)
 	logger.Event("shutdown").Signal(sig).Info("Starting graceful shutdown process")
 	logger.Event("shutdown").Signal(sig).Info("Propagating cancellation")
 	// Propagate context cancelling
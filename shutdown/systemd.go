@@ -0,0 +1,75 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// States sent to systemd with SdNotify.
+const (
+	// SdReady tells systemd the service finished starting up.
+	SdReady = "READY=1"
+	// SdStopping tells systemd the service is shutting down.
+	SdStopping = "STOPPING=1"
+	// SdWatchdog keeps the systemd watchdog from restarting the service.
+	SdWatchdog = "WATCHDOG=1"
+)
+
+// SdNotify sends the state in input to the systemd notification socket named by $NOTIFY_SOCKET,
+// as sd_notify(3) does. It reports false, without error, when the service is not run by systemd
+// with Type=notify or NotifyAccess set.
+func SdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	if socket[0] == '@' {
+		// abstract namespace socket
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("could not connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("could not notify systemd: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns how often the service must send SdWatchdog, half of the watchdog timeout
+// set by systemd with WatchdogSec, and whether the watchdog is enabled for this process.
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond / 2, true
+}
+
+// Watchdog sends SdWatchdog every WatchdogInterval until the context is done, returning right away if the
+// watchdog is not enabled. It is meant to be run in its own goroutine.
+func Watchdog(ctx context.Context) {
+	interval, ok := WatchdogInterval()
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = SdNotify(SdWatchdog)
+		}
+	}
+}
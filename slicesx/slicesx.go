@@ -0,0 +1,154 @@
+package slicesx
+
+// Chunk splits the slice into consecutive chunks of n items, the last one possibly shorter.
+// Chunks share the memory of s, with their capacity clipped so that appending to one does not overwrite the next.
+// It panics if n is not positive.
+func Chunk[S ~[]T, T any](s S, n int) []S {
+	if n <= 0 {
+		panic("slicesx: chunk size must be positive")
+	}
+	if len(s) == 0 {
+		return nil
+	}
+	chunks := make([]S, 0, (len(s)+n-1)/n)
+	for i := 0; i < len(s); i += n {
+		end := min(i+n, len(s))
+		chunks = append(chunks, s[i:end:end])
+	}
+	return chunks
+}
+
+// Unique returns the items of the slice without duplicates, in order of first appearance.
+func Unique[S ~[]T, T comparable](s S) S {
+	return UniqueBy(s, func(v T) T { return v })
+}
+
+// UniqueBy returns the items of the slice whose key is not shared by a previous item, in order.
+func UniqueBy[S ~[]T, T any, K comparable](s S, key func(T) K) S {
+	seen := make(map[K]struct{}, len(s))
+	out := make(S, 0, len(s))
+	for _, v := range s {
+		k := key(v)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
+
+// GroupBy groups the items of the slice by key, keeping their order within every group.
+func GroupBy[S ~[]T, T any, K comparable](s S, key func(T) K) map[K]S {
+	groups := make(map[K]S)
+	for _, v := range s {
+		k := key(v)
+		groups[k] = append(groups[k], v)
+	}
+	return groups
+}
+
+// Filter returns a new slice holding the items for which keep returns true.
+func Filter[S ~[]T, T any](s S, keep func(T) bool) S {
+	out := make(S, 0, len(s))
+	for _, v := range s {
+		if keep(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// FilterInPlace keeps the items for which keep returns true, reusing the memory of s, which must not be used anymore.
+func FilterInPlace[S ~[]T, T any](s S, keep func(T) bool) S {
+	out := s[:0]
+	for _, v := range s {
+		if keep(v) {
+			out = append(out, v)
+		}
+	}
+	clear(s[len(out):])
+	return out
+}
+
+// Map returns a new slice holding the result of fn for every item.
+func Map[S ~[]T, T, R any](s S, fn func(T) R) []R {
+	out := make([]R, len(s))
+	for i, v := range s {
+		out[i] = fn(v)
+	}
+	return out
+}
+
+// Reduce folds the items into an accumulator, starting from init.
+func Reduce[S ~[]T, T, A any](s S, init A, fn func(acc A, v T) A) A {
+	acc := init
+	for _, v := range s {
+		acc = fn(acc, v)
+	}
+	return acc
+}
+
+// Partition splits the slice into the items for which pred returns true and the others, keeping their order.
+func Partition[S ~[]T, T any](s S, pred func(T) bool) (S, S) {
+	var yes, no S
+	for _, v := range s {
+		if pred(v) {
+			yes = append(yes, v)
+		} else {
+			no = append(no, v)
+		}
+	}
+	return yes, no
+}
+
+// Union returns the items of a followed by the items of b not in a, without duplicates.
+func Union[S ~[]T, T comparable](a, b S) S {
+	seen := make(map[T]struct{}, len(a)+len(b))
+	out := make(S, 0, len(a)+len(b))
+	for _, s := range [2]S{a, b} {
+		for _, v := range s {
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Intersect returns the items of a which are also in b, in the order of a, without duplicates.
+func Intersect[S ~[]T, T comparable](a, b S) S {
+	in := toSet(b)
+	out := make(S, 0, min(len(a), len(b)))
+	for _, v := range a {
+		if _, ok := in[v]; ok {
+			delete(in, v)
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Difference returns the items of a which are not in b, in the order of a, without duplicates.
+func Difference[S ~[]T, T comparable](a, b S) S {
+	out := make(S, 0, len(a))
+	seen := toSet(b)
+	for _, v := range a {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return out
+}
+
+func toSet[S ~[]T, T comparable](s S) map[T]struct{} {
+	set := make(map[T]struct{}, len(s))
+	for _, v := range s {
+		set[v] = struct{}{}
+	}
+	return set
+}
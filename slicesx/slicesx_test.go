@@ -0,0 +1,68 @@
+package slicesx
+
+import (
+	"strconv"
+	"testing"
+)
+
+func ints(n, distinct int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i % distinct
+	}
+	return s
+}
+
+func BenchmarkChunk(b *testing.B) {
+	s := ints(10000, 10000)
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			for b.Loop() {
+				_ = Chunk(s, size)
+			}
+		})
+	}
+}
+
+func BenchmarkUnique(b *testing.B) {
+	for _, distinct := range []int{10, 1000, 10000} {
+		s := ints(10000, distinct)
+		b.Run(strconv.Itoa(distinct), func(b *testing.B) {
+			for b.Loop() {
+				_ = Unique(s)
+			}
+		})
+	}
+}
+
+func BenchmarkGroupBy(b *testing.B) {
+	s := ints(10000, 10000)
+	for _, groups := range []int{10, 1000} {
+		b.Run(strconv.Itoa(groups), func(b *testing.B) {
+			for b.Loop() {
+				_ = GroupBy(s, func(v int) int { return v % groups })
+			}
+		})
+	}
+}
+
+func BenchmarkUnion(b *testing.B) {
+	x, y := ints(10000, 10000), ints(10000, 5000)
+	for b.Loop() {
+		_ = Union(x, y)
+	}
+}
+
+func BenchmarkIntersect(b *testing.B) {
+	x, y := ints(10000, 10000), ints(10000, 5000)
+	for b.Loop() {
+		_ = Intersect(x, y)
+	}
+}
+
+func BenchmarkDifference(b *testing.B) {
+	x, y := ints(10000, 10000), ints(10000, 5000)
+	for b.Loop() {
+		_ = Difference(x, y)
+	}
+}
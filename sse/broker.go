@@ -0,0 +1,240 @@
+package sse
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/indiependente/pkg/shutdown"
+)
+
+const defaultBuffer = 64
+
+// DropPolicy decides what happens to the events published while a subscriber's buffer is full.
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest buffered event to make room for the new one.
+	DropOldest DropPolicy = iota
+	// DropNewest discards the new event.
+	DropNewest
+	// Disconnect closes the subscription, the client resumes from its last event when it reconnects.
+	Disconnect
+)
+
+// BrokerOption customises a Broker.
+type BrokerOption func(*Broker)
+
+// WithBuffer buffers up to n events per subscriber. Defaults to 64.
+func WithBuffer(n int) BrokerOption {
+	return func(b *Broker) {
+		b.buffer = n
+	}
+}
+
+// WithDropPolicy applies the policy in input to slow subscribers. Defaults to DropOldest.
+func WithDropPolicy(p DropPolicy) BrokerOption {
+	return func(b *Broker) {
+		b.policy = p
+	}
+}
+
+// WithHistory keeps the last n events to replay them to the clients reconnecting with a Last-Event-ID.
+func WithHistory(n int) BrokerOption {
+	return func(b *Broker) {
+		b.historySize = n
+	}
+}
+
+// WithStreamOptions applies the options in input to the streams opened by ServeHTTP.
+func WithStreamOptions(opts ...Option) BrokerOption {
+	return func(b *Broker) {
+		b.streamOpts = opts
+	}
+}
+
+// Broker fans the events published out to many subscribers.
+// The zero value is not usable, use NewBroker.
+type Broker struct {
+	buffer      int
+	policy      DropPolicy
+	historySize int
+	streamOpts  []Option
+
+	mu      sync.Mutex
+	seq     uint64
+	subs    map[*Subscription]struct{}
+	history []Event
+	closed  bool
+}
+
+// compile time interface check.
+var _ http.Handler = &Broker{}
+
+// NewBroker returns a Broker.
+func NewBroker(opts ...BrokerOption) *Broker {
+	b := &Broker{
+		buffer: defaultBuffer,
+		subs:   make(map[*Subscription]struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Subscription receives the events published on a Broker.
+type Subscription struct {
+	b       *Broker
+	events  chan Event
+	dropped int
+}
+
+// Events returns the channel of events, closed once the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns the number of events dropped because the subscriber was too slow.
+func (s *Subscription) Dropped() int {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	return s.dropped
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.unsubscribe(s)
+}
+
+// Publish sends the event to every subscriber, returning its ID.
+// Events without an ID are given a sequential one, so that clients can resume from them.
+func (b *Broker) Publish(e Event) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(b.seq, 10)
+	}
+	if b.historySize > 0 {
+		b.history = append(b.history, e)
+		if len(b.history) > b.historySize {
+			b.history = b.history[len(b.history)-b.historySize:]
+		}
+	}
+	for s := range b.subs {
+		b.deliver(s, e)
+	}
+	return e.ID
+}
+
+// Subscribe returns a new subscription. When lastEventID is found in the history,
+// the events published after it are delivered first.
+func (b *Broker) Subscribe(lastEventID string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &Subscription{b: b, events: make(chan Event, b.buffer)}
+	if b.closed {
+		close(s.events)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	if lastEventID != "" {
+		for i, e := range b.history {
+			if e.ID == lastEventID {
+				for _, missed := range b.history[i+1:] {
+					b.deliver(s, missed)
+				}
+				break
+			}
+		}
+	}
+	return s
+}
+
+// Len returns the number of subscribers.
+func (b *Broker) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// ServeHTTP streams the events to the client until it goes away or the broker is closed,
+// resuming from the Last-Event-ID it sent, if any.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stream, err := NewStream(w, r, b.streamOpts...)
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+	sub := b.Subscribe(stream.LastEventID())
+	defer sub.Close()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := stream.Send(e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close closes every subscription, ending their streams, and rejects new subscribers.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.unsubscribe(s)
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn closing the broker.
+// The http.Server waits for the streams to end, so the broker must be closed before shutting it down.
+func (b *Broker) TerminationFn() shutdown.TerminationFn {
+	return func(context.Context) error {
+		b.Close()
+		return nil
+	}
+}
+
+// deliver sends the event to the subscriber, applying the drop policy. It must be called with the lock held.
+func (b *Broker) deliver(s *Subscription, e Event) {
+	select {
+	case s.events <- e:
+		return
+	default:
+	}
+	s.dropped++
+	switch b.policy {
+	case DropOldest:
+		select {
+		case <-s.events:
+		default:
+		}
+		select {
+		case s.events <- e:
+		default:
+		}
+	case DropNewest:
+	case Disconnect:
+		b.unsubscribe(s)
+	}
+}
+
+// unsubscribe removes the subscriber and closes its channel. It must be called with the lock held.
+func (b *Broker) unsubscribe(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.events)
+}
@@ -0,0 +1,236 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHeartbeat    = 15 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+	// LastEventIDHeader is the header sent by reconnecting clients with the ID of the last event they received.
+	LastEventIDHeader = "Last-Event-ID"
+)
+
+// ErrClosed is returned when sending on a stream whose client went away.
+var ErrClosed = errors.New("stream closed")
+
+// Event is a server-sent event. Only Data is required.
+type Event struct {
+	// ID is remembered by the client and sent back in the Last-Event-ID header when it reconnects.
+	ID string
+	// Event is the event type, "message" when empty.
+	Event string
+	// Data is the payload. Multi-line data is split into several data fields.
+	Data string
+	// Retry, if positive, tells the client how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// WriteTo writes the event in the text/event-stream format.
+func (e Event) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + sanitize(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + sanitize(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(e.Data, "\r\n", "\n"), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// sanitize drops the line breaks, which would end the field.
+func sanitize(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// Option customises a Stream.
+type Option func(*config)
+
+type config struct {
+	heartbeat    time.Duration
+	writeTimeout time.Duration
+	retry        time.Duration
+}
+
+// WithHeartbeat writes a comment every d to keep idle connections, and the proxies in between, open.
+// Defaults to 15s, a non positive d disables heartbeats.
+func WithHeartbeat(d time.Duration) Option {
+	return func(c *config) {
+		c.heartbeat = d
+	}
+}
+
+// WithWriteTimeout fails writes not completed within d. Defaults to 10s.
+// It replaces the write timeout of the server, which would otherwise end the stream.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.writeTimeout = d
+	}
+}
+
+// WithRetry tells the client how long to wait before reconnecting.
+func WithRetry(d time.Duration) Option {
+	return func(c *config) {
+		c.retry = d
+	}
+}
+
+// Stream is an open event stream to a client. It is safe for concurrent use.
+type Stream struct {
+	cfg         *config
+	w           *bufio.Writer
+	rc          *http.ResponseController
+	lastEventID string
+	ctx         context.Context
+	cancel      context.CancelCauseFunc
+
+	mu sync.Mutex
+}
+
+// NewStream starts an event stream on the response, writing the headers right away.
+// Heartbeats are written until the request context is done or Close is called.
+func NewStream(w http.ResponseWriter, r *http.Request, opts ...Option) (*Stream, error) {
+	cfg := &config{
+		heartbeat:    defaultHeartbeat,
+		writeTimeout: defaultWriteTimeout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	rc := http.NewResponseController(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	s := &Stream{
+		cfg:         cfg,
+		w:           bufio.NewWriter(w),
+		rc:          rc,
+		lastEventID: r.Header.Get(LastEventIDHeader),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	if s.lastEventID == "" {
+		s.lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	prelude := ":ok\n\n"
+	if cfg.retry > 0 {
+		prelude = "retry: " + strconv.FormatInt(cfg.retry.Milliseconds(), 10) + "\n\n"
+	}
+	if err := s.write(func(w io.Writer) error {
+		_, err := io.WriteString(w, prelude)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if cfg.heartbeat > 0 {
+		go s.heartbeats()
+	}
+	return s, nil
+}
+
+// LastEventID returns the ID of the last event received by the client before reconnecting, if any.
+func (s *Stream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Context returns a context done once the client goes away or the stream is closed.
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// Send writes the event and flushes it to the client.
+func (s *Stream) Send(e Event) error {
+	return s.write(func(w io.Writer) error {
+		_, err := e.WriteTo(w)
+		return err
+	})
+}
+
+// Comment writes a comment, ignored by the clients.
+func (s *Stream) Comment(text string) error {
+	return s.write(func(w io.Writer) error {
+		_, err := io.WriteString(w, ": "+sanitize(text)+"\n\n")
+		return err
+	})
+}
+
+// Close stops the heartbeats; the stream ends once the handler returns.
+func (s *Stream) Close() {
+	s.cancel(ErrClosed)
+}
+
+func (s *Stream) write(fn func(w io.Writer) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return ErrClosed
+	}
+	// the server write timeout applies to the whole response, replace it with a deadline per write
+	_ = s.rc.SetWriteDeadline(time.Now().Add(s.cfg.writeTimeout))
+	err := fn(s.w)
+	if err == nil {
+		err = s.w.Flush()
+	}
+	if err == nil {
+		err = s.rc.Flush()
+	}
+	if err != nil {
+		err = fmt.Errorf("could not write event: %w", err)
+		s.cancel(err)
+		return err
+	}
+	return nil
+}
+
+func (s *Stream) heartbeats() {
+	t := time.NewTicker(s.cfg.heartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+			if err := s.write(func(w io.Writer) error {
+				_, err := io.WriteString(w, ":\n\n")
+				return err
+			}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Handler returns an http.Handler opening a stream for every request and passing it to fn,
+// which sends events until it returns.
+func Handler(fn func(r *http.Request, s *Stream), opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := NewStream(w, r, opts...)
+		if err != nil {
+			return
+		}
+		defer s.Close()
+		fn(r, s)
+	})
+}
@@ -0,0 +1,55 @@
+package streamio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned by Verify when the checksum of the content differs from the expected one.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumReader hashes the bytes read from the underlying reader.
+type ChecksumReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+// NewChecksumReader returns a ChecksumReader reading from r and hashing with h, e.g. crc32.NewIEEE().
+func NewChecksumReader(r io.Reader, h hash.Hash) *ChecksumReader {
+	return &ChecksumReader{r: r, h: h}
+}
+
+// NewSHA256Reader returns a ChecksumReader reading from r and hashing with SHA-256.
+func NewSHA256Reader(r io.Reader) *ChecksumReader {
+	return NewChecksumReader(r, sha256.New())
+}
+
+func (c *ChecksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.h.Write(p[:n])
+	return n, err
+}
+
+// Sum returns the checksum of the bytes read so far.
+func (c *ChecksumReader) Sum() []byte {
+	return c.h.Sum(nil)
+}
+
+// Hex returns the hex encoded checksum of the bytes read so far.
+func (c *ChecksumReader) Hex() string {
+	return hex.EncodeToString(c.Sum())
+}
+
+// Verify compares the checksum of the bytes read so far with the hex encoded one in input, returning
+// ErrChecksumMismatch if they differ. It is meant to be called once the content is read entirely.
+func (c *ChecksumReader) Verify(expected string) error {
+	if got := c.Hex(); got != strings.ToLower(expected) {
+		return fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, got, expected)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package streamio
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// CountingReader counts the bytes read from the underlying reader. N can be called concurrently with Read.
+type CountingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+// NewCountingReader returns a CountingReader reading from r.
+func NewCountingReader(r io.Reader) *CountingReader {
+	return &CountingReader{r: r}
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// N returns the number of bytes read so far.
+func (c *CountingReader) N() int64 {
+	return c.n.Load()
+}
+
+// CountingWriter counts the bytes written to the underlying writer. N can be called concurrently with Write.
+type CountingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+// NewCountingWriter returns a CountingWriter writing to w.
+func NewCountingWriter(w io.Writer) *CountingWriter {
+	return &CountingWriter{w: w}
+}
+
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// N returns the number of bytes written so far.
+func (c *CountingWriter) N() int64 {
+	return c.n.Load()
+}
@@ -0,0 +1,71 @@
+package streamio
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// NewRateLimitedReader returns a reader reading from r at up to bytesPerSecond, allowing bursts of one second
+// worth of bytes. Reads wait for the limiter until ctx is done, in which case they fail with its error.
+func NewRateLimitedReader(ctx context.Context, r io.Reader, bytesPerSecond int) io.Reader {
+	return &rateLimitedReader{ctx: ctx, r: r, limiter: newLimiter(bytesPerSecond)}
+}
+
+// NewRateLimitedWriter returns a writer writing to w at up to bytesPerSecond, allowing bursts of one second
+// worth of bytes. Writes wait for the limiter until ctx is done, in which case they fail with its error.
+func NewRateLimitedWriter(ctx context.Context, w io.Writer, bytesPerSecond int) io.Writer {
+	return &rateLimitedWriter{ctx: ctx, w: w, limiter: newLimiter(bytesPerSecond)}
+}
+
+func newLimiter(bytesPerSecond int) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), bytesPerSecond)
+}
+
+// rateLimitedReader limits the read throughput to the rate of the limiter, measured in bytes per second.
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (rr *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rr.limiter.Burst() {
+		p = p[:rr.limiter.Burst()]
+	}
+	n, err := rr.r.Read(p)
+	if n > 0 {
+		if werr := rr.limiter.WaitN(rr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// rateLimitedWriter limits the write throughput to the rate of the limiter, measured in bytes per second.
+type rateLimitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+func (rw *rateLimitedWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > rw.limiter.Burst() {
+			chunk = chunk[:rw.limiter.Burst()]
+		}
+		if err := rw.limiter.WaitN(rw.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := rw.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
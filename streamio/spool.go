@@ -0,0 +1,135 @@
+package streamio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrClosed is returned when writing to a closed SpoolBuffer.
+var ErrClosed = errors.New("spool buffer closed")
+
+// SpoolBuffer buffers content in memory up to a threshold, spilling it to a temporary file past it, e.g. to replay
+// a large body without holding it in memory. Content is appended by Write and read back by independent readers
+// returned by Reader. The buffer must be closed to remove the temporary file.
+type SpoolBuffer struct {
+	threshold int64
+	dir       string
+
+	mu     sync.Mutex
+	mem    bytes.Buffer
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+// compile time interface check.
+var _ io.WriteCloser = &SpoolBuffer{}
+
+// NewSpoolBuffer returns an empty SpoolBuffer spilling to a temporary file in dir, os.TempDir() if empty,
+// once more than threshold bytes are written.
+func NewSpoolBuffer(threshold int64, dir string) *SpoolBuffer {
+	return &SpoolBuffer{threshold: threshold, dir: dir}
+}
+
+// Spool copies r to a new SpoolBuffer, closing it if the copy fails.
+func Spool(r io.Reader, threshold int64, dir string) (*SpoolBuffer, error) {
+	b := NewSpoolBuffer(threshold, dir)
+	if _, err := io.Copy(b, r); err != nil {
+		_ = b.Close()
+		return nil, fmt.Errorf("could not spool content: %w", err)
+	}
+	return b, nil
+}
+
+// Write appends p to the content, moving it to the temporary file once it exceeds the threshold.
+func (b *SpoolBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, ErrClosed
+	}
+	if b.file == nil && b.size+int64(len(p)) > b.threshold {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+	var (
+		n   int
+		err error
+	)
+	if b.file != nil {
+		n, err = b.file.Write(p)
+	} else {
+		n, err = b.mem.Write(p)
+	}
+	b.size += int64(n)
+	return n, err
+}
+
+// spill moves the content held in memory to the temporary file. It must be called with the lock held.
+func (b *SpoolBuffer) spill() error {
+	f, err := os.CreateTemp(b.dir, "spool-*")
+	if err != nil {
+		return fmt.Errorf("could not create spool file: %w", err)
+	}
+	if _, err := f.Write(b.mem.Bytes()); err != nil {
+		f.Close()
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("could not write spool file: %w", err)
+	}
+	b.file = f
+	b.mem = bytes.Buffer{}
+	return nil
+}
+
+// Reader returns a reader of the content written so far, independent of the other readers.
+// It must not be used after the buffer is closed.
+func (b *SpoolBuffer) Reader() *io.SectionReader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file != nil {
+		return io.NewSectionReader(b.file, 0, b.size)
+	}
+	return io.NewSectionReader(bytes.NewReader(b.mem.Bytes()), 0, b.size)
+}
+
+// Size returns the number of bytes written.
+func (b *SpoolBuffer) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Spilled reports whether the content was moved to a temporary file.
+func (b *SpoolBuffer) Spilled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.file != nil
+}
+
+// Close releases the content, removing the temporary file if any.
+func (b *SpoolBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.mem = bytes.Buffer{}
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	err := b.file.Close()
+	if rerr := os.Remove(name); rerr != nil && err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return fmt.Errorf("could not remove spool file: %w", err)
+	}
+	return nil
+}
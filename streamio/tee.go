@@ -0,0 +1,48 @@
+package streamio
+
+import (
+	"io"
+)
+
+// LimitedTee writes to a writer the first bytes read from a reader, e.g. to log the beginning of a large body.
+type LimitedTee struct {
+	r         io.Reader
+	w         io.Writer
+	remaining int64
+	truncated bool
+	err       error
+}
+
+// TeeWithLimit returns a reader reading from r which writes to w the first limit bytes it reads.
+// Unlike io.TeeReader, errors writing to w do not fail the reads: they stop the copy and are returned by Err.
+func TeeWithLimit(r io.Reader, w io.Writer, limit int64) *LimitedTee {
+	return &LimitedTee{r: r, w: w, remaining: limit}
+}
+
+func (t *LimitedTee) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 && t.err == nil {
+		chunk := p[:n]
+		if int64(len(chunk)) > t.remaining {
+			chunk = chunk[:t.remaining]
+			t.truncated = true
+		}
+		if len(chunk) > 0 {
+			if _, werr := t.w.Write(chunk); werr != nil {
+				t.err = werr
+			}
+			t.remaining -= int64(len(chunk))
+		}
+	}
+	return n, err
+}
+
+// Truncated reports whether more bytes were read than the limit.
+func (t *LimitedTee) Truncated() bool {
+	return t.truncated
+}
+
+// Err returns the error which stopped the copy to the writer, if any.
+func (t *LimitedTee) Err() error {
+	return t.err
+}
@@ -0,0 +1,338 @@
+package templatex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/indiependente/pkg/httpx/respond"
+)
+
+const (
+	htmlExt = ".html"
+	textExt = ".txt"
+
+	defaultLayoutsDir  = "layouts"
+	defaultPartialsDir = "partials"
+
+	contentTypeHTML = "text/html; charset=utf-8"
+	contentTypeText = "text/plain; charset=utf-8"
+)
+
+// ErrNotFound is returned when rendering a template which does not exist.
+var ErrNotFound = errors.New("template not found")
+
+// Option customises the loading of templates.
+type Option func(*config)
+
+type config struct {
+	layoutsDir  string
+	partialsDir string
+	layout      string
+	funcs       map[string]interface{}
+	strict      bool
+	reload      bool
+}
+
+// WithFuncs makes the functions in input available to all the templates.
+func WithFuncs(funcs map[string]interface{}) Option {
+	return func(c *config) {
+		for k, v := range funcs {
+			c.funcs[k] = v
+		}
+	}
+}
+
+// WithLayoutsDir loads the layouts from the directory in input, "layouts" by default.
+func WithLayoutsDir(dir string) Option {
+	return func(c *config) {
+		c.layoutsDir = dir
+	}
+}
+
+// WithPartialsDir loads the partials from the directory in input, "partials" by default.
+func WithPartialsDir(dir string) Option {
+	return func(c *config) {
+		c.partialsDir = dir
+	}
+}
+
+// WithLayout renders every page by executing the template called name, typically defined by a layout
+// including the blocks defined by the page, e.g. {{block "content" .}}{{end}}.
+// Pages not defining the template are executed directly.
+func WithLayout(name string) Option {
+	return func(c *config) {
+		c.layout = name
+	}
+}
+
+// WithStrict fails rendering when the data passed to a template misses a key, rather than rendering "<no value>".
+func WithStrict() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// WithReload parses the templates again whenever a file changes, so that edits are picked up without a restart.
+// It is meant for development: every render walks the file system to detect changes.
+func WithReload(reload bool) Option {
+	return func(c *config) {
+		c.reload = reload
+	}
+}
+
+// Templates is a set of HTML and text pages, composed with the shared layouts and partials.
+// Files ending in .html are parsed with html/template, files ending in .txt with text/template;
+// layouts and partials are only available to pages of the same kind.
+// Pages are named after their path in the file system, e.g. "users/show.html".
+type Templates struct {
+	fsys fs.FS
+	cfg  *config
+
+	mu    sync.RWMutex
+	set   *set
+	stamp string
+}
+
+// set holds the parsed pages.
+type set struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+// Load parses all the templates in fsys, failing if any of them does not compile.
+func Load(fsys fs.FS, opts ...Option) (*Templates, error) {
+	cfg := &config{
+		layoutsDir:  defaultLayoutsDir,
+		partialsDir: defaultPartialsDir,
+		funcs:       make(map[string]interface{}),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	t := &Templates{fsys: fsys, cfg: cfg}
+	stamp, err := t.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	s, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.set, t.stamp = s, stamp
+	return t, nil
+}
+
+// Names returns the names of the pages.
+func (t *Templates) Names() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	names := make([]string, 0, len(t.set.html)+len(t.set.text))
+	for name := range t.set.html {
+		names = append(names, name)
+	}
+	for name := range t.set.text {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Execute renders the page called name with the data in input into w.
+func (t *Templates) Execute(w io.Writer, name string, data interface{}) error {
+	s, err := t.current()
+	if err != nil {
+		return err
+	}
+	if tmpl, ok := s.html[name]; ok {
+		if t.cfg.layout != "" && tmpl.Lookup(t.cfg.layout) != nil {
+			return tmpl.ExecuteTemplate(w, t.cfg.layout, data)
+		}
+		return tmpl.Execute(w, data)
+	}
+	if tmpl, ok := s.text[name]; ok {
+		if t.cfg.layout != "" && tmpl.Lookup(t.cfg.layout) != nil {
+			return tmpl.ExecuteTemplate(w, t.cfg.layout, data)
+		}
+		return tmpl.Execute(w, data)
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Render writes the page called name with the data in input as a 200 OK response.
+func (t *Templates) Render(w http.ResponseWriter, name string, data interface{}) error {
+	return t.RenderStatus(w, http.StatusOK, name, data)
+}
+
+// RenderStatus writes the page called name with the data in input as a response with the status code in input.
+// The page is rendered in memory first, so that a failure results in a clean 500 Internal Server Error
+// problem+json response, written by respond.Error, rather than a truncated page. The error is returned for logging.
+func (t *Templates) RenderStatus(w http.ResponseWriter, status int, name string, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, name, data); err != nil {
+		err = fmt.Errorf("could not render template %s: %w", name, err)
+		respond.Error(w, err)
+		return err
+	}
+	contentType := contentTypeHTML
+	if path.Ext(name) == textExt {
+		contentType = contentTypeText
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// current returns the parsed pages, parsing them again first if reloading is enabled and a file changed.
+func (t *Templates) current() (*set, error) {
+	if !t.cfg.reload {
+		return t.set, nil
+	}
+	stamp, err := t.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	t.mu.RLock()
+	s, same := t.set, t.stamp == stamp
+	t.mu.RUnlock()
+	if same {
+		return s, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stamp == stamp {
+		return t.set, nil
+	}
+	s, err = t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.set, t.stamp = s, stamp
+	return s, nil
+}
+
+// fingerprint summarises the names, sizes and modification times of the template files, to detect changes.
+func (t *Templates) fingerprint() (string, error) {
+	var sb strings.Builder
+	err := fs.WalkDir(t.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isTemplate(p) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d;", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not walk templates: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// parse parses every page along with the layouts and partials of its kind, joining the errors of all the files.
+func (t *Templates) parse() (*set, error) {
+	var layouts, pages []string
+	err := fs.WalkDir(t.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isTemplate(p) {
+			return err
+		}
+		if inDir(p, t.cfg.layoutsDir) || inDir(p, t.cfg.partialsDir) {
+			layouts = append(layouts, p)
+		} else {
+			pages = append(pages, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not walk templates: %w", err)
+	}
+
+	htmlBase := htmltemplate.New("").Funcs(t.cfg.funcs)
+	textBase := texttemplate.New("").Funcs(t.cfg.funcs)
+	if t.cfg.strict {
+		htmlBase = htmlBase.Option("missingkey=error")
+		textBase = textBase.Option("missingkey=error")
+	}
+
+	var errs []error
+	for _, p := range layouts {
+		src, err := fs.ReadFile(t.fsys, p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not read template %s: %w", p, err))
+			continue
+		}
+		if path.Ext(p) == htmlExt {
+			_, err = htmlBase.New(p).Parse(string(src))
+		} else {
+			_, err = textBase.New(p).Parse(string(src))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse template %s: %w", p, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	s := &set{
+		html: make(map[string]*htmltemplate.Template),
+		text: make(map[string]*texttemplate.Template),
+	}
+	for _, p := range pages {
+		if err := s.add(t.fsys, p, htmlBase, textBase); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return s, nil
+}
+
+// add parses the page on top of a copy of the layouts and partials.
+func (s *set) add(fsys fs.FS, p string, htmlBase *htmltemplate.Template, textBase *texttemplate.Template) error {
+	src, err := fs.ReadFile(fsys, p)
+	if err != nil {
+		return fmt.Errorf("could not read template %s: %w", p, err)
+	}
+	if path.Ext(p) == htmlExt {
+		tmpl, err := htmlBase.Clone()
+		if err == nil {
+			tmpl, err = tmpl.New(p).Parse(string(src))
+		}
+		if err != nil {
+			return fmt.Errorf("could not parse template %s: %w", p, err)
+		}
+		s.html[p] = tmpl
+		return nil
+	}
+	tmpl, err := textBase.Clone()
+	if err == nil {
+		tmpl, err = tmpl.New(p).Parse(string(src))
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse template %s: %w", p, err)
+	}
+	s.text[p] = tmpl
+	return nil
+}
+
+func isTemplate(p string) bool {
+	ext := path.Ext(p)
+	return ext == htmlExt || ext == textExt
+}
+
+func inDir(p, dir string) bool {
+	return dir != "" && strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
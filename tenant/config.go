@@ -0,0 +1,46 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+)
+
+// ConfigSource returns the configuration values of tenants, e.g. read from a database or a feature flag service.
+type ConfigSource interface {
+	// Lookup returns the value of the key for the tenant in input, reporting false if it is not set.
+	Lookup(ctx context.Context, tenant, key string) (string, bool, error)
+}
+
+// StaticConfig is a ConfigSource holding the values in memory.
+type StaticConfig struct {
+	// Tenants holds the values by tenant ID and key.
+	Tenants map[string]map[string]string
+	// Defaults holds the values of the tenants not overriding them.
+	Defaults map[string]string
+}
+
+// compile time interface check.
+var _ ConfigSource = &StaticConfig{}
+
+// Lookup returns the value of the key for the tenant, falling back to the default one.
+func (c *StaticConfig) Lookup(_ context.Context, tenant, key string) (string, bool, error) {
+	if v, ok := c.Tenants[tenant][key]; ok {
+		return v, true, nil
+	}
+	v, ok := c.Defaults[key]
+	return v, ok, nil
+}
+
+// Lookup returns the value of the key for the tenant carried by the context, failing with ErrNoTenant if the context
+// does not carry one.
+func Lookup(ctx context.Context, src ConfigSource, key string) (string, bool, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return "", false, ErrNoTenant
+	}
+	v, ok, err := src.Lookup(ctx, id, key)
+	if err != nil {
+		return "", false, fmt.Errorf("could not look up %s for tenant %s: %w", key, id, err)
+	}
+	return v, ok, nil
+}
@@ -0,0 +1,161 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/indiependente/pkg/httpx/respond"
+	"github.com/indiependente/pkg/jwt"
+	"github.com/indiependente/pkg/tenantid"
+)
+
+// Header is the default header carrying the tenant ID.
+const Header = tenantid.Header
+
+// ErrNoTenant is returned when the context does not carry a tenant.
+var ErrNoTenant = errors.New("no tenant in context")
+
+// NewContext returns a copy of the context carrying the tenant ID in input, see tenantid.NewContext.
+func NewContext(ctx context.Context, id string) context.Context {
+	return tenantid.NewContext(ctx, id)
+}
+
+// FromContext returns the tenant ID carried by the context, if any, see tenantid.FromContext.
+func FromContext(ctx context.Context) (string, bool) {
+	return tenantid.FromContext(ctx)
+}
+
+// Resolver extracts the tenant ID from the request, returning false if it does not carry one.
+type Resolver func(r *http.Request) (string, bool)
+
+// FromHeader reads the tenant ID from the header in input, Header if empty.
+// The header is set by the client and not verified: use it only behind a trusted internal hop which authenticated
+// the caller and set the header itself, e.g. a gateway, and never on endpoints exposed to clients.
+func FromHeader(name string) Resolver {
+	if name == "" {
+		name = Header
+	}
+	return func(r *http.Request) (string, bool) {
+		id := strings.TrimSpace(r.Header.Get(name))
+		return id, id != ""
+	}
+}
+
+// FromSubdomain reads the tenant ID from the subdomain of the base domain in input, e.g. acme for
+// acme.example.com when the base domain is example.com. Hosts with nested subdomains do not match.
+func FromSubdomain(baseDomain string) Resolver {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return func(r *http.Request) (string, bool) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		sub, ok := strings.CutSuffix(host, suffix)
+		if !ok || sub == "" || strings.Contains(sub, ".") {
+			return "", false
+		}
+		return sub, true
+	}
+}
+
+// FromJWT reads the tenant ID from the claim in input of the bearer token in the Authorization header,
+// validated with the verifier in input. Requests without a valid token do not match.
+func FromJWT(v *jwt.Verifier, claim string) Resolver {
+	return func(r *http.Request) (string, bool) {
+		auth := r.Header.Get("Authorization")
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+			return "", false
+		}
+		claims, err := v.Verify(strings.TrimSpace(auth[7:]))
+		if err != nil {
+			return "", false
+		}
+		return FromClaims(claims, claim)
+	}
+}
+
+// FromClaims returns the string value of the claim in input, e.g. to resolve the tenant from the claims of
+// a caller authenticated upstream.
+func FromClaims(claims map[string]interface{}, claim string) (string, bool) {
+	id, ok := claims[claim].(string)
+	return id, ok && id != ""
+}
+
+// Option customises the middleware.
+type Option func(*config)
+
+type config struct {
+	resolvers []Resolver
+	optional  bool
+	validate  func(ctx context.Context, id string) (bool, error)
+}
+
+// WithOptional lets requests without a tenant through, instead of answering them with 400 Bad Request.
+func WithOptional() Option {
+	return func(c *config) {
+		c.optional = true
+	}
+}
+
+// WithValidator checks the resolved tenants with the function in input, e.g. against the tenants database.
+// Requests for tenants it rejects are answered with 403 Forbidden, and with 500 Internal Server Error if it fails.
+func WithValidator(fn func(ctx context.Context, id string) (bool, error)) Option {
+	return func(c *config) {
+		c.validate = fn
+	}
+}
+
+// Middleware resolves the tenant of every request with the resolvers in input, in order, and stores it in the
+// request context, where FromContext, the logger context extractors and the client tenant propagation find it.
+// The tenant drives authorisation decisions downstream, so the resolvers should read it from the authenticated
+// caller, e.g. FromJWT or middleware.TenantFromPrincipal, rather than from FromHeader.
+// Requests without a tenant are answered with 400 Bad Request. It panics without resolvers.
+func Middleware(resolvers []Resolver, opts ...Option) func(http.Handler) http.Handler {
+	if len(resolvers) == 0 {
+		panic("tenant: Middleware needs at least one resolver")
+	}
+	cfg := &config{resolvers: resolvers}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := cfg.resolve(r)
+			if !ok {
+				if cfg.optional {
+					next.ServeHTTP(w, r)
+					return
+				}
+				respond.Error(w, respond.NewProblem(http.StatusBadRequest, "the request does not specify a tenant"))
+				return
+			}
+			if cfg.validate != nil {
+				valid, err := cfg.validate(r.Context(), id)
+				if err != nil {
+					respond.Error(w, err)
+					return
+				}
+				if !valid {
+					respond.Error(w, respond.NewProblem(http.StatusForbidden, "unknown tenant"))
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+		})
+	}
+}
+
+// resolve returns the tenant found by the first resolver which finds one.
+func (c *config) resolve(r *http.Request) (string, bool) {
+	for _, resolve := range c.resolvers {
+		if id, ok := resolve(r); ok {
+			return id, true
+		}
+	}
+	return "", false
+}
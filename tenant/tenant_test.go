@@ -0,0 +1,136 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/indiependente/pkg/jwt"
+)
+
+func TestMiddleware(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	signer, err := jwt.NewSigner(jwt.HS256, "k", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := jwt.NewVerifier(jwt.NewKeySet(map[string]interface{}{"k": secret}).Keyfunc)
+	token := func(tenant string) string {
+		s, err := signer.Sign(jwt.MapClaims{"tenant": tenant, "exp": jwt.NewNumericDate(time.Now().Add(time.Hour))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + s
+	}
+	validate := func(_ context.Context, id string) (bool, error) {
+		if id == "broken" {
+			return false, errors.New("database down")
+		}
+		return id != "unknown", nil
+	}
+
+	tests := []struct {
+		name       string
+		resolvers  []Resolver
+		opts       []Option
+		host       string
+		headers    map[string]string
+		wantStatus int
+		wantTenant string
+	}{
+		{
+			name:       "from JWT",
+			resolvers:  []Resolver{FromJWT(verifier, "tenant")},
+			headers:    map[string]string{"Authorization": token("acme")},
+			wantStatus: http.StatusOK,
+			wantTenant: "acme",
+		},
+		{
+			name:       "header ignored when resolving from JWT",
+			resolvers:  []Resolver{FromJWT(verifier, "tenant")},
+			headers:    map[string]string{Header: "victim"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "forged JWT",
+			resolvers:  []Resolver{FromJWT(verifier, "tenant")},
+			headers:    map[string]string{"Authorization": token("acme") + "x"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "fallback resolver",
+			resolvers:  []Resolver{FromJWT(verifier, "tenant"), FromSubdomain("example.com")},
+			host:       "globex.example.com",
+			wantStatus: http.StatusOK,
+			wantTenant: "globex",
+		},
+		{
+			name:       "nested subdomain",
+			resolvers:  []Resolver{FromSubdomain("example.com")},
+			host:       "a.b.example.com",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "trusted header",
+			resolvers:  []Resolver{FromHeader("")},
+			headers:    map[string]string{Header: " acme "},
+			wantStatus: http.StatusOK,
+			wantTenant: "acme",
+		},
+		{
+			name:       "optional",
+			resolvers:  []Resolver{FromHeader("")},
+			opts:       []Option{WithOptional()},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "rejected tenant",
+			resolvers:  []Resolver{FromHeader("")},
+			opts:       []Option{WithValidator(validate)},
+			headers:    map[string]string{Header: "unknown"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "failing validator",
+			resolvers:  []Resolver{FromHeader("")},
+			opts:       []Option{WithValidator(validate)},
+			headers:    map[string]string{Header: "broken"},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := Middleware(tt.resolvers, tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = FromContext(r.Context())
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.host != "" {
+				r.Host = tt.host
+			}
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got != tt.wantTenant {
+				t.Fatalf("got tenant %q, want %q", got, tt.wantTenant)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequiresResolvers(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Middleware() did not panic without resolvers")
+		}
+	}()
+	Middleware(nil)
+}
@@ -0,0 +1,21 @@
+package tenantid
+
+import (
+	"context"
+)
+
+// Header is the default header carrying the tenant ID.
+const Header = "X-Tenant-ID"
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context carrying the tenant ID in input.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant ID carried by the context, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
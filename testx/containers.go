@@ -0,0 +1,191 @@
+package testx
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/network"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	defaultPostgresImage = "postgres:17-alpine"
+	defaultRedisImage    = "redis:7-alpine"
+	defaultKafkaImage    = "apache/kafka:3.9.0"
+
+	postgresUser     = "test"
+	postgresPassword = "test"
+	postgresDB       = "test"
+
+	startTimeout = 2 * time.Minute
+)
+
+// ContainerOption customises the containers started by the Start helpers.
+type ContainerOption func(*containerConfig)
+
+type containerConfig struct {
+	image string
+	reuse bool
+	env   map[string]string
+}
+
+// WithImage runs the image in input rather than the default one.
+func WithImage(image string) ContainerOption {
+	return func(c *containerConfig) {
+		c.image = image
+	}
+}
+
+// WithReuse shares one container, named after the service, among tests and test binaries, rather than starting
+// one per test and terminating it on cleanup. Tests sharing a container must not depend on its initial state.
+func WithReuse() ContainerOption {
+	return func(c *containerConfig) {
+		c.reuse = true
+	}
+}
+
+// WithEnv sets an environment variable of the container.
+func WithEnv(key, value string) ContainerOption {
+	return func(c *containerConfig) {
+		c.env[key] = value
+	}
+}
+
+// StartPostgres starts a PostgreSQL container and returns its connection string.
+// The test is skipped when running with -short or when Docker is not available.
+func StartPostgres(t testing.TB, opts ...ContainerOption) string {
+	t.Helper()
+	cfg := newContainerConfig(defaultPostgresImage, opts)
+	cfg.env["POSTGRES_USER"] = postgresUser
+	cfg.env["POSTGRES_PASSWORD"] = postgresPassword
+	cfg.env["POSTGRES_DB"] = postgresDB
+	endpoint := start(t, "postgres", cfg, testcontainers.ContainerRequest{
+		ExposedPorts: []string{"5432/tcp"},
+		// the server restarts once after initialising the database
+		WaitingFor: wait.ForAll(
+			wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+			wait.ForListeningPort("5432/tcp"),
+		),
+	}, "5432/tcp")
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(postgresUser, postgresPassword),
+		Host:     endpoint,
+		Path:     postgresDB,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
+
+// StartRedis starts a Redis container and returns its address.
+// The test is skipped when running with -short or when Docker is not available.
+func StartRedis(t testing.TB, opts ...ContainerOption) string {
+	t.Helper()
+	cfg := newContainerConfig(defaultRedisImage, opts)
+	return start(t, "redis", cfg, testcontainers.ContainerRequest{
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForLog("Ready to accept connections"),
+	}, "6379/tcp")
+}
+
+// StartKafka starts a single node Kafka cluster, running in KRaft mode, and returns the addresses of its brokers.
+// The test is skipped when running with -short or when Docker is not available.
+func StartKafka(t testing.TB, opts ...ContainerOption) []string {
+	t.Helper()
+	cfg := newContainerConfig(defaultKafkaImage, opts)
+	// the broker advertises its address to clients, so the host port must be known before starting it
+	port := FreePort(t)
+	for k, v := range map[string]string{
+		"KAFKA_NODE_ID":                                  "1",
+		"KAFKA_PROCESS_ROLES":                            "broker,controller",
+		"KAFKA_LISTENERS":                                "PLAINTEXT://:9092,CONTROLLER://:9093",
+		"KAFKA_ADVERTISED_LISTENERS":                     "PLAINTEXT://localhost:" + strconv.Itoa(port),
+		"KAFKA_CONTROLLER_LISTENER_NAMES":                "CONTROLLER",
+		"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP":           "CONTROLLER:PLAINTEXT,PLAINTEXT:PLAINTEXT",
+		"KAFKA_CONTROLLER_QUORUM_VOTERS":                 "1@localhost:9093",
+		"KAFKA_OFFSETS_TOPIC_REPLICATION_FACTOR":         "1",
+		"KAFKA_TRANSACTION_STATE_LOG_REPLICATION_FACTOR": "1",
+		"KAFKA_TRANSACTION_STATE_LOG_MIN_ISR":            "1",
+		"KAFKA_GROUP_INITIAL_REBALANCE_DELAY_MS":         "0",
+	} {
+		if _, ok := cfg.env[k]; !ok {
+			cfg.env[k] = v
+		}
+	}
+	endpoint := start(t, "kafka", cfg, testcontainers.ContainerRequest{
+		ExposedPorts: []string{"9092/tcp"},
+		HostConfigModifier: func(hc *container.HostConfig) {
+			hc.PortBindings = network.PortMap{
+				network.MustParsePort("9092/tcp"): {{HostPort: strconv.Itoa(port)}},
+			}
+		},
+		WaitingFor: wait.ForLog("Kafka Server started"),
+	}, "9092/tcp")
+	return []string{endpoint}
+}
+
+func newContainerConfig(image string, opts []ContainerOption) *containerConfig {
+	cfg := &containerConfig{image: image, env: make(map[string]string)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// start runs the container described by the request and returns the host:port address of the port in input.
+func start(t testing.TB, service string, cfg *containerConfig, req testcontainers.ContainerRequest, port string) string {
+	t.Helper()
+	skipWithoutDocker(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	req.Image = cfg.image
+	req.Env = cfg.env
+	if cfg.reuse {
+		req.Name = "testx-" + service
+	}
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+		Reuse:            cfg.reuse,
+	})
+	if !cfg.reuse {
+		testcontainers.CleanupContainer(t, c)
+	}
+	if err != nil {
+		t.Fatalf("could not start %s container: %v", service, err)
+	}
+	endpoint, err := c.PortEndpoint(ctx, port, "")
+	if err != nil {
+		t.Fatalf("could not get %s endpoint: %v", service, err)
+	}
+	return endpoint
+}
+
+// skipWithoutDocker skips the test when running with -short or when Docker is not available.
+func skipWithoutDocker(t testing.TB) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	defer func() {
+		// the provider panics when no Docker host can be found
+		if r := recover(); r != nil {
+			t.Skip(fmt.Sprintf("skipping integration test, Docker is not available: %v", r))
+		}
+	}()
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		t.Skipf("skipping integration test, Docker is not available: %v", err)
+	}
+	defer provider.Close()
+	if err := provider.Health(context.Background()); err != nil {
+		t.Skipf("skipping integration test, Docker is not available: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package testx
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// goldenDir is the directory holding the golden files, relative to the package under test.
+const goldenDir = "testdata"
+
+var update = flag.Bool("update", false, "update the golden files with the actual output")
+
+// Golden compares got with the content of testdata/<name>.golden, failing the test if they differ.
+// Running the tests with -update writes got to the golden file instead.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join(goldenDir, name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("could not create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil { // nolint:gosec
+			t.Fatalf("could not update golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path) // nolint:gosec
+	if err != nil {
+		t.Fatalf("could not read golden file, run the tests with -update to create it: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output does not match golden file %s, run the tests with -update to accept it\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// GoldenString behaves as Golden, comparing a string.
+func GoldenString(t testing.TB, name, got string) {
+	t.Helper()
+	Golden(t, name, []byte(got))
+}
+
+// GoldenJSON behaves as Golden, comparing v encoded as indented JSON.
+func GoldenJSON(t testing.TB, name string, v interface{}) {
+	t.Helper()
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("could not encode JSON: %v", err)
+	}
+	Golden(t, name, append(b, '\n'))
+}
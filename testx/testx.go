@@ -0,0 +1,54 @@
+package testx
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// deadlineGrace is left between the context deadline and the test deadline, so that cleanups get to run
+// and failures are reported before the test binary is killed.
+const deadlineGrace = 5 * time.Second
+
+// FreePort returns a TCP port which is free on the loopback interface at the time of the call.
+func FreePort(t testing.TB) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not find a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// Context returns a context cancelled when the test finishes, with a deadline shortly before the one set
+// by go test -timeout, if any.
+func Context(t testing.TB) context.Context {
+	t.Helper()
+	ctx := t.Context()
+	dt, ok := t.(interface{ Deadline() (time.Time, bool) })
+	if !ok {
+		return ctx
+	}
+	deadline, ok := dt.Deadline()
+	if !ok {
+		return ctx
+	}
+	if grace := time.Until(deadline) / 10; grace < deadlineGrace {
+		deadline = deadline.Add(-grace)
+	} else {
+		deadline = deadline.Add(-deadlineGrace)
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// ContextWithTimeout returns a context cancelled when the test finishes or after the timeout, whichever comes first.
+func ContextWithTimeout(t testing.TB, timeout time.Duration) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(Context(t), timeout)
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,200 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/indiependente/pkg/shutdown"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer used by Start.
+const instrumentationName = "github.com/indiependente/pkg/tracing"
+
+// Protocol is the transport used to export spans via OTLP.
+type Protocol int
+
+const (
+	// GRPC exports spans via OTLP over gRPC.
+	GRPC Protocol = iota
+	// HTTP exports spans via OTLP over HTTP with protobuf payloads.
+	HTTP
+)
+
+// Option customises the tracer provider set up by Init.
+type Option func(*config)
+
+type config struct {
+	protocol    Protocol
+	endpointURL string
+	insecure    bool
+	headers     map[string]string
+	ratio       float64
+	attributes  []attribute.KeyValue
+	exporter    sdktrace.SpanExporter
+}
+
+// WithProtocol sets the OTLP transport. Defaults to GRPC.
+func WithProtocol(p Protocol) Option {
+	return func(c *config) {
+		c.protocol = p
+	}
+}
+
+// WithEndpointURL sets the URL of the OTLP collector, e.g. http://localhost:4317.
+// Defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable, or the exporter default.
+func WithEndpointURL(url string) Option {
+	return func(c *config) {
+		c.endpointURL = url
+	}
+}
+
+// WithInsecure disables TLS towards the collector.
+func WithInsecure() Option {
+	return func(c *config) {
+		c.insecure = true
+	}
+}
+
+// WithHeaders sends the headers in input, e.g. for authentication, with every export request.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *config) {
+		c.headers = headers
+	}
+}
+
+// WithSampleRatio samples the fraction of root traces in input, following the sampling decision of the parent otherwise.
+// Defaults to 1, sampling every trace.
+func WithSampleRatio(ratio float64) Option {
+	return func(c *config) {
+		c.ratio = ratio
+	}
+}
+
+// WithServiceVersion sets the service.version resource attribute.
+func WithServiceVersion(version string) Option {
+	return WithAttributes(semconv.ServiceVersion(version))
+}
+
+// WithEnvironment sets the deployment.environment.name resource attribute.
+func WithEnvironment(env string) Option {
+	return WithAttributes(semconv.DeploymentEnvironmentNameKey.String(env))
+}
+
+// WithAttributes adds the attributes in input to the resource describing the service.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(c *config) {
+		c.attributes = append(c.attributes, attrs...)
+	}
+}
+
+// WithExporter exports spans with the exporter in input instead of the OTLP one.
+func WithExporter(exporter sdktrace.SpanExporter) Option {
+	return func(c *config) {
+		c.exporter = exporter
+	}
+}
+
+// Init sets up the global tracer provider and propagators for the service in input, exporting spans via OTLP.
+// The returned shutdown.TerminationFn flushes the pending spans and stops the exporter.
+func Init(ctx context.Context, serviceName string, opts ...Option) (shutdown.TerminationFn, error) {
+	cfg := &config{ratio: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	exporter := cfg.exporter
+	if exporter == nil {
+		var err error
+		exporter, err = newExporter(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("could not create exporter: %w", err)
+		}
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		append([]attribute.KeyValue{semconv.ServiceName(serviceName)}, cfg.attributes...)...,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("could not create resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.ratio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return func(ctx context.Context) error {
+		// the context passed by shutdown.Wait is already cancelled
+		if err := tp.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			return fmt.Errorf("could not shutdown tracer provider: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg *config) (sdktrace.SpanExporter, error) {
+	if cfg.protocol == HTTP {
+		opts := []otlptracehttp.Option{}
+		if cfg.endpointURL != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.endpointURL))
+		}
+		if cfg.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if cfg.headers != nil {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.headers))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	opts := []otlptracegrpc.Option{}
+	if cfg.endpointURL != "" {
+		opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.endpointURL))
+	}
+	if cfg.insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	if cfg.headers != nil {
+		opts = append(opts, otlptracegrpc.WithHeaders(cfg.headers))
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// Start starts a span with the name in input using the global tracer provider.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// Run runs fn within a span with the name in input, recording the error it returns, if any.
+func Run(ctx context.Context, name string, fn func(context.Context) error, opts ...trace.SpanStartOption) error {
+	ctx, span := Start(ctx, name, opts...)
+	defer span.End()
+	err := fn(ctx)
+	RecordError(span, err)
+	return err
+}
+
+// RecordError records err on the span and marks it as failed. It does nothing if err is nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
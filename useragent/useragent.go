@@ -0,0 +1,198 @@
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Device is the kind of device a client runs on.
+type Device string
+
+const (
+	// Desktop is a desktop or laptop computer.
+	Desktop Device = "desktop"
+	// Mobile is a phone.
+	Mobile Device = "mobile"
+	// Tablet is a tablet.
+	Tablet Device = "tablet"
+	// Bot is a crawler, monitoring agent or HTTP library.
+	Bot Device = "bot"
+	// Unknown is any other device.
+	Unknown Device = "unknown"
+)
+
+// UserAgent is the classification of a User-Agent header.
+type UserAgent struct {
+	// Family is the name of the browser, or of the bot, e.g. Chrome or Googlebot; "Other" if unknown.
+	Family  string
+	Version string
+	// OS is the name of the operating system, e.g. Windows, macOS, iOS, Android or Linux; empty if unknown.
+	OS        string
+	OSVersion string
+	Device    Device
+	IsBot     bool
+}
+
+// rule matches a product token, capturing its version.
+type rule struct {
+	family string
+	re     *regexp.Regexp
+}
+
+// bots are matched first: many of them also claim to be browsers.
+var bots = []rule{
+	{"Googlebot", regexp.MustCompile(`Googlebot(?:-\w+)?/([\d.]+)`)},
+	{"Bingbot", regexp.MustCompile(`bingbot/([\d.]+)`)},
+	{"YandexBot", regexp.MustCompile(`YandexBot/([\d.]+)`)},
+	{"Baiduspider", regexp.MustCompile(`Baiduspider(?:-\w+)?/([\d.]+)`)},
+	{"DuckDuckBot", regexp.MustCompile(`DuckDuckBot/([\d.]+)`)},
+	{"Applebot", regexp.MustCompile(`Applebot/([\d.]+)`)},
+	{"facebookexternalhit", regexp.MustCompile(`facebookexternalhit/([\d.]+)`)},
+	{"Twitterbot", regexp.MustCompile(`Twitterbot/([\d.]+)`)},
+	{"Slackbot", regexp.MustCompile(`Slackbot(?:-LinkExpanding)?(?: ([\d.]+))?`)},
+	{"AhrefsBot", regexp.MustCompile(`AhrefsBot/([\d.]+)`)},
+	{"SemrushBot", regexp.MustCompile(`SemrushBot/([\d.~a-z]+)`)},
+	{"GPTBot", regexp.MustCompile(`GPTBot/([\d.]+)`)},
+	{"UptimeRobot", regexp.MustCompile(`UptimeRobot/([\d.]+)`)},
+	{"kube-probe", regexp.MustCompile(`kube-probe/([\d.]+)`)},
+	{"ELB-HealthChecker", regexp.MustCompile(`ELB-HealthChecker/([\d.]+)`)},
+	{"curl", regexp.MustCompile(`^curl/([\d.]+)`)},
+	{"Wget", regexp.MustCompile(`^Wget/([\d.]+)`)},
+	{"python-requests", regexp.MustCompile(`python-requests/([\d.]+)`)},
+	{"Python-urllib", regexp.MustCompile(`Python-urllib/([\d.]+)`)},
+	{"aiohttp", regexp.MustCompile(`aiohttp/([\d.]+)`)},
+	{"Go-http-client", regexp.MustCompile(`Go-http-client/([\d.]+)`)},
+	{"okhttp", regexp.MustCompile(`okhttp/([\d.]+)`)},
+	{"axios", regexp.MustCompile(`axios/([\d.]+)`)},
+	{"node-fetch", regexp.MustCompile(`node-fetch/([\d.]+)`)},
+	{"Java", regexp.MustCompile(`^Java/([\d._]+)`)},
+	{"Apache-HttpClient", regexp.MustCompile(`Apache-HttpClient/([\d.]+)`)},
+	{"PostmanRuntime", regexp.MustCompile(`PostmanRuntime/([\d.]+)`)},
+	{"HeadlessChrome", regexp.MustCompile(`HeadlessChrome/([\d.]+)`)},
+}
+
+// botRE catches the bots not listed, which mostly identify themselves with these words.
+var botRE = regexp.MustCompile(`(?i)bot\b|crawler|spider|slurp|scraper|fetcher|monitor|headless`)
+
+// browsers are matched in order: browsers built on Chromium also claim to be Chrome and Safari.
+var browsers = []rule{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/([\d.]+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/([\d.]+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/([\d.]+)`)},
+	{"Yandex Browser", regexp.MustCompile(`YaBrowser/([\d.]+)`)},
+	{"Vivaldi", regexp.MustCompile(`Vivaldi/([\d.]+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/([\d.]+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/([\d.]+)`)},
+	{"Safari", regexp.MustCompile(`Version/([\d.]+).*Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)([\d.]+)`)},
+}
+
+var (
+	windowsRE  = regexp.MustCompile(`Windows NT ([\d.]+)`)
+	iosRE      = regexp.MustCompile(`(?:iPhone|CPU) OS ([\d_]+)`)
+	macRE      = regexp.MustCompile(`Mac OS X ([\d_.]+)`)
+	androidRE  = regexp.MustCompile(`Android ([\d.]+)`)
+	chromeOSRE = regexp.MustCompile(`CrOS \S+ ([\d.]+)`)
+)
+
+// windowsVersions maps the Windows NT versions to the product names.
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.1":  "XP",
+}
+
+// Parse classifies the User-Agent header in input. It recognises the common browsers, operating systems and bots;
+// any client it does not recognise has family "Other".
+func Parse(s string) UserAgent {
+	ua := UserAgent{Family: "Other", Device: Unknown}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ua
+	}
+	ua.OS, ua.OSVersion = parseOS(s)
+
+	if family, version, ok := match(bots, s); ok {
+		ua.Family, ua.Version, ua.IsBot, ua.Device = family, version, true, Bot
+		return ua
+	}
+	if botRE.MatchString(s) {
+		ua.IsBot, ua.Device = true, Bot
+		return ua
+	}
+	if family, version, ok := match(browsers, s); ok {
+		ua.Family, ua.Version = family, version
+	}
+	ua.Device = parseDevice(s, ua.OS)
+	return ua
+}
+
+// IsBot reports whether the User-Agent header in input belongs to a bot.
+func IsBot(s string) bool {
+	return Parse(s).IsBot
+}
+
+func match(rules []rule, s string) (string, string, bool) {
+	for _, r := range rules {
+		if m := r.re.FindStringSubmatch(s); m != nil {
+			version := ""
+			if len(m) > 1 {
+				version = m[1]
+			}
+			return r.family, version, true
+		}
+	}
+	return "", "", false
+}
+
+func parseOS(s string) (string, string) {
+	switch {
+	case strings.Contains(s, "Windows"):
+		if m := windowsRE.FindStringSubmatch(s); m != nil {
+			if v, ok := windowsVersions[m[1]]; ok {
+				return "Windows", v
+			}
+			return "Windows", m[1]
+		}
+		return "Windows", ""
+	case strings.Contains(s, "iPhone") || strings.Contains(s, "iPad") || strings.Contains(s, "iPod"):
+		if m := iosRE.FindStringSubmatch(s); m != nil {
+			return "iOS", strings.ReplaceAll(m[1], "_", ".")
+		}
+		return "iOS", ""
+	case strings.Contains(s, "Mac OS X") || strings.Contains(s, "Macintosh"):
+		if m := macRE.FindStringSubmatch(s); m != nil {
+			return "macOS", strings.ReplaceAll(m[1], "_", ".")
+		}
+		return "macOS", ""
+	case strings.Contains(s, "Android"):
+		if m := androidRE.FindStringSubmatch(s); m != nil {
+			return "Android", m[1]
+		}
+		return "Android", ""
+	case strings.Contains(s, "CrOS"):
+		if m := chromeOSRE.FindStringSubmatch(s); m != nil {
+			return "ChromeOS", m[1]
+		}
+		return "ChromeOS", ""
+	case strings.Contains(s, "Linux") || strings.Contains(s, "X11"):
+		return "Linux", ""
+	}
+	return "", ""
+}
+
+func parseDevice(s, os string) Device {
+	switch {
+	case strings.Contains(s, "iPad") || strings.Contains(s, "Tablet") ||
+		(os == "Android" && !strings.Contains(s, "Mobile")):
+		return Tablet
+	case strings.Contains(s, "Mobi") || strings.Contains(s, "iPhone") || strings.Contains(s, "iPod"):
+		return Mobile
+	case os != "":
+		return Desktop
+	}
+	return Unknown
+}
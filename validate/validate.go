@@ -0,0 +1,302 @@
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/indiependente/pkg/errors"
+	"github.com/indiependente/pkg/httpx/respond"
+)
+
+// tag is the struct tag holding the comma separated rules of a field, e.g. `validate:"required,max=64"`.
+const tag = "validate"
+
+// Rule checks the value of a field against the parameter of the rule, e.g. "3" for min=3.
+// The returned error message is reported as the message of the field error.
+type Rule func(v reflect.Value, param string) error
+
+var (
+	mu    sync.RWMutex
+	rules = map[string]Rule{
+		"required": required,
+		"min":      minRule,
+		"max":      maxRule,
+		"len":      lenRule,
+		"email":    email,
+		"uuid":     uuidRule,
+		"url":      urlRule,
+		"oneof":    oneOf,
+	}
+)
+
+// Register adds a rule under the name in input, replacing any rule with the same name.
+// It is meant to be called at init time.
+func Register(name string, rule Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	rules[name] = rule
+}
+
+// FieldError describes a field failing a rule.
+type FieldError struct {
+	// Field is the path of the field, named after its JSON name, e.g. address.city or items[0].sku.
+	Field   string
+	Rule    string
+	Message string
+}
+
+// Errors lists the fields failing validation. It maps onto errors.Invalid and a 400 Bad Request
+// problem with field errors when written with respond.Error.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// StatusCode returns 400 Bad Request.
+func (e Errors) StatusCode() int {
+	return 400
+}
+
+// ErrorCode returns the code of errors.Invalid.
+func (e Errors) ErrorCode() string {
+	return string(errors.Invalid)
+}
+
+// FieldErrors returns the field errors in the format of the respond package.
+func (e Errors) FieldErrors() []respond.FieldError {
+	out := make([]respond.FieldError, len(e))
+	for i, fe := range e {
+		out[i] = respond.FieldError{Field: fe.Field, Message: fe.Message}
+	}
+	return out
+}
+
+// Struct validates the fields of the struct, or pointer to struct, in input against the rules in their validate tag,
+// recursing into nested structs and slices of structs. It returns Errors listing every failing field, or nil.
+// Rules other than required are skipped for empty values.
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs Errors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *Errors) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(sf)
+		fv := rv.Field(i)
+		if spec, ok := sf.Tag.Lookup(tag); ok && spec != "-" {
+			if err := validateField(fv, name, spec, errs); err != nil {
+				return err
+			}
+		}
+		if err := recurse(fv, name, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recurse validates the structs nested in the value.
+func recurse(fv reflect.Value, name string, errs *Errors) error {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		return validateStruct(fv, name+".", errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := recurse(fv.Index(i), fmt.Sprintf("%s[%d]", name, i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateField(fv reflect.Value, name, spec string, errs *Errors) error {
+	empty := isEmpty(fv)
+	for _, r := range strings.Split(spec, ",") {
+		ruleName, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+		if ruleName == "" {
+			continue
+		}
+		mu.RLock()
+		rule, ok := rules[ruleName]
+		mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("unknown validation rule %q on field %s", ruleName, name)
+		}
+		if empty && ruleName != "required" {
+			continue
+		}
+		if err := rule(indirect(fv), param); err != nil {
+			*errs = append(*errs, FieldError{Field: name, Rule: ruleName, Message: err.Error()})
+			if ruleName == "required" {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName returns the JSON name of the field.
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func required(v reflect.Value, _ string) error {
+	if isEmpty(v) {
+		return fmt.Errorf("is required")
+	}
+	return nil
+}
+
+// size returns the length of strings, slices and maps, and the value of numbers.
+func size(v reflect.Value) (float64, bool, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true, nil
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, nil
+	}
+	return 0, false, fmt.Errorf("cannot be measured")
+}
+
+func bound(v reflect.Value, param string, fail func(got, limit float64) bool, lengthMsg, valueMsg string) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("has an invalid rule parameter %q", param)
+	}
+	got, isLen, err := size(v)
+	if err != nil {
+		return err
+	}
+	if !fail(got, limit) {
+		return nil
+	}
+	if isLen {
+		return fmt.Errorf(lengthMsg, param)
+	}
+	return fmt.Errorf(valueMsg, param)
+}
+
+func minRule(v reflect.Value, param string) error {
+	return bound(v, param, func(got, limit float64) bool { return got < limit },
+		"must contain at least %s elements or characters", "must be at least %s")
+}
+
+func maxRule(v reflect.Value, param string) error {
+	return bound(v, param, func(got, limit float64) bool { return got > limit },
+		"must contain at most %s elements or characters", "must be at most %s")
+}
+
+func lenRule(v reflect.Value, param string) error {
+	return bound(v, param, func(got, limit float64) bool { return got != limit },
+		"must contain exactly %s elements or characters", "must be exactly %s")
+}
+
+func email(v reflect.Value, _ string) error {
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("must be a string")
+	}
+	addr, err := mail.ParseAddress(v.String())
+	if err != nil || addr.Address != v.String() {
+		return fmt.Errorf("must be a valid email address")
+	}
+	return nil
+}
+
+func uuidRule(v reflect.Value, _ string) error {
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("must be a string")
+	}
+	if len(v.String()) != 36 || uuid.Validate(v.String()) != nil {
+		return fmt.Errorf("must be a valid UUID")
+	}
+	return nil
+}
+
+func urlRule(v reflect.Value, _ string) error {
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("must be a string")
+	}
+	u, err := url.Parse(v.String())
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("must be a valid absolute URL")
+	}
+	return nil
+}
+
+// oneOf checks the value against the space separated values of the parameter.
+func oneOf(v reflect.Value, param string) error {
+	allowed := strings.Fields(param)
+	s := fmt.Sprint(v.Interface())
+	for _, a := range allowed {
+		if s == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of: %s", strings.Join(allowed, ", "))
+}
@@ -0,0 +1,243 @@
+package warmup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/backoff"
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	warmupEvent = "warmup"
+
+	defaultTimeout        = 2 * time.Minute
+	defaultAttemptTimeout = 5 * time.Second
+	defaultInitial        = 100 * time.Millisecond
+	defaultMax            = 5 * time.Second
+	defaultMultiplier     = 2
+)
+
+// ErrNotReady is wrapped by the error returned when some dependency is not ready by the deadline.
+var ErrNotReady = errors.New("dependencies not ready")
+
+// Check probes a dependency, returning an error while it is not ready.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// Func returns a Check probing the dependency with fn, e.g. a healthcheck.Check.
+func Func(name string, fn func(ctx context.Context) error) Check {
+	return Check{Name: name, Probe: fn}
+}
+
+// SQL returns a Check pinging the database.
+func SQL(name string, db *sql.DB) Check {
+	return Check{Name: name, Probe: db.PingContext}
+}
+
+// Redis returns a Check pinging the Redis server.
+func Redis(name string, client redis.UniversalClient) Check {
+	return Check{Name: name, Probe: func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}}
+}
+
+// HTTP returns a Check sending GET requests to the URL, e.g. the readiness endpoint of a downstream service,
+// with the client in input, e.g. built by the client package, until it answers with a 2xx status code.
+func HTTP(name string, client *http.Client, url string) Check {
+	return Check{Name: name, Probe: func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("could not create request: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+		return nil
+	}}
+}
+
+// Failure describes a dependency which was not ready by the deadline.
+type Failure struct {
+	Name     string
+	Attempts int
+	Err      error
+}
+
+// NotReadyError lists the dependencies which were not ready by the deadline.
+type NotReadyError struct {
+	Failures []Failure
+}
+
+func (e *NotReadyError) Error() string {
+	parts := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		parts[i] = fmt.Sprintf("%s (%d attempts): %v", f.Name, f.Attempts, f.Err)
+	}
+	return ErrNotReady.Error() + ": " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns ErrNotReady along with the last error of every dependency.
+func (e *NotReadyError) Unwrap() []error {
+	errs := []error{ErrNotReady}
+	for _, f := range e.Failures {
+		errs = append(errs, f.Err)
+	}
+	return errs
+}
+
+// Option customises a Gate.
+type Option func(*Gate)
+
+// WithTimeout gives up on the dependencies which are not ready after d, 2m by default.
+func WithTimeout(d time.Duration) Option {
+	return func(g *Gate) {
+		g.timeout = d
+	}
+}
+
+// WithAttemptTimeout fails a probe which does not complete within d, 5s by default.
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(g *Gate) {
+		g.attemptTimeout = d
+	}
+}
+
+// WithBackoff waits initial after the first failed probe of a dependency, growing the delay by multiplier
+// after every failure up to max. Defaults to 100ms, 5s and 2.
+func WithBackoff(initial, max time.Duration, multiplier float64) Option {
+	return func(g *Gate) {
+		g.initial = initial
+		g.max = max
+		g.multiplier = multiplier
+	}
+}
+
+// WithClock waits between probes with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(g *Gate) {
+		g.clock = c
+	}
+}
+
+// Gate waits for the dependencies of a service to be ready before it starts serving traffic.
+type Gate struct {
+	timeout        time.Duration
+	attemptTimeout time.Duration
+	initial        time.Duration
+	max            time.Duration
+	multiplier     float64
+	clock          clock.Clock
+}
+
+// New returns a Gate customised by the options in input.
+func New(opts ...Option) *Gate {
+	g := &Gate{
+		timeout:        defaultTimeout,
+		attemptTimeout: defaultAttemptTimeout,
+		initial:        defaultInitial,
+		max:            defaultMax,
+		multiplier:     defaultMultiplier,
+		clock:          clock.New(),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Wait probes the dependencies with the default Gate.
+func Wait(ctx context.Context, log logger.Logger, checks ...Check) error {
+	return New().Wait(ctx, log, checks...)
+}
+
+// Wait probes the dependencies concurrently, retrying each one with backoff until it is ready, ctx is done
+// or the timeout expires. It returns a *NotReadyError listing the dependencies which were not ready.
+// Progress is logged if log is not nil.
+func (g *Gate) Wait(ctx context.Context, log logger.Logger, checks ...Check) error {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	start := g.clock.Now()
+
+	var (
+		mu       sync.Mutex
+		failures []Failure
+		wg       sync.WaitGroup
+	)
+	for _, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if f, ok := g.probe(ctx, log, chk); !ok {
+				mu.Lock()
+				failures = append(failures, f)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Name < failures[j].Name })
+		err := &NotReadyError{Failures: failures}
+		if log != nil {
+			log.Event(warmupEvent).Duration(g.clock.Since(start)).Error("Dependencies not ready", err)
+		}
+		return err
+	}
+	if log != nil {
+		log.Event(warmupEvent).Duration(g.clock.Since(start)).Info(fmt.Sprintf("All %d dependencies ready", len(checks)))
+	}
+	return nil
+}
+
+// probe retries the check until it succeeds or ctx is done, reporting whether it succeeded.
+func (g *Gate) probe(ctx context.Context, log logger.Logger, chk Check) (Failure, bool) {
+	start := g.clock.Now()
+	strategy := backoff.Exponential(g.initial, g.max, g.multiplier)
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		err := g.attempt(ctx, chk)
+		if err == nil {
+			if log != nil {
+				log.Event(warmupEvent).Component(chk.Name).Duration(g.clock.Since(start)).Info("Dependency ready")
+			}
+			return Failure{}, true
+		}
+		delay = strategy.Delay(attempt, delay)
+		if log != nil {
+			log.Event(warmupEvent).Component(chk.Name).Duration(delay).Err(err).Warn(fmt.Sprintf("Dependency not ready, attempt %d", attempt))
+		}
+		timer := g.clock.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Failure{Name: chk.Name, Attempts: attempt, Err: err}, false
+		case <-timer.C():
+		}
+	}
+}
+
+func (g *Gate) attempt(ctx context.Context, chk Check) error {
+	ctx, cancel := context.WithTimeout(ctx, g.attemptTimeout)
+	defer cancel()
+	return chk.Probe(ctx)
+}
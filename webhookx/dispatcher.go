@@ -0,0 +1,252 @@
+package webhookx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/indiependente/pkg/backoff"
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/http/client"
+	"github.com/indiependente/pkg/id"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/pubsub"
+	"github.com/indiependente/pkg/ratelimit"
+	"github.com/indiependente/pkg/retry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	dispatchEvent = "webhook_dispatcher"
+
+	// endpointHeader and eventTypeHeader carry the endpoint and the type of the webhooks queued as messages.
+	endpointHeader  = "webhook-endpoint"
+	eventTypeHeader = "webhook-event-type"
+
+	defaultMaxAttempts = 5
+	defaultInitial     = time.Second
+	defaultMax         = time.Minute
+)
+
+// Endpoint is a URL receiving webhooks, signed with its secret.
+type Endpoint struct {
+	ID     string
+	URL    string
+	Secret []byte
+}
+
+// Event is the content of a webhook.
+type Event struct {
+	// ID identifies the webhook, so that receivers can discard duplicates. A UUID is generated if empty.
+	ID   string
+	Type string
+	// Payload is the body of the webhook, sent as JSON.
+	Payload []byte
+}
+
+// Option customises a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithClient sends the webhooks with the client in input, e.g. to set timeouts or tracing.
+func WithClient(c *client.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = c
+	}
+}
+
+// WithRetry retries the failed deliveries with the options in input, replacing the default 5 attempts with
+// exponential backoff from 1s to 1m and full jitter. Client errors other than 408 and 429 are never retried.
+func WithRetry(opts ...retry.Option) Option {
+	return func(d *Dispatcher) {
+		d.retry = opts
+	}
+}
+
+// WithRateLimit sends up to rps webhooks per second to every endpoint, with bursts of up to burst webhooks,
+// keeping the limits in the store in input, in memory if nil.
+func WithRateLimit(rps float64, burst int, store ratelimit.Store) Option {
+	return func(d *Dispatcher) {
+		if store == nil {
+			d.limiter = ratelimit.NewMemory(rps, burst)
+			return
+		}
+		d.limiter = ratelimit.New(store, rps, burst)
+	}
+}
+
+// WithDeadLetter calls fn with the webhooks which could not be delivered, along with the last error,
+// e.g. to store them for a manual replay or to disable the endpoint.
+func WithDeadLetter(fn func(ctx context.Context, ep Endpoint, ev Event, err error)) Option {
+	return func(d *Dispatcher) {
+		d.deadLetter = fn
+	}
+}
+
+// WithLogger logs the outcome of every delivery.
+func WithLogger(log logger.Logger) Option {
+	return func(d *Dispatcher) {
+		d.log = log
+	}
+}
+
+// WithMetrics counts the deliveries by event type and result, and observes their duration, on the registerer in input.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(d *Dispatcher) {
+		d.deliveries = metrics.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhook_deliveries_total",
+			Help: "Number of webhook deliveries by event type and result.",
+		}, []string{"event_type", "result"}))
+		d.duration = metrics.Register(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "webhook_delivery_duration_seconds",
+			Help:    "Duration of webhook deliveries, retries included.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"event_type"}))
+	}
+}
+
+// WithClock timestamps the webhooks and waits between attempts with the clock in input, e.g. a clock.Fake in tests.
+func WithClock(c clock.Clock) Option {
+	return func(d *Dispatcher) {
+		d.clock = c
+	}
+}
+
+// Dispatcher delivers signed webhooks, see Sign, retrying the failed deliveries.
+type Dispatcher struct {
+	client     *client.Client
+	retry      []retry.Option
+	limiter    *ratelimit.Limiter
+	deadLetter func(ctx context.Context, ep Endpoint, ev Event, err error)
+	log        logger.Logger
+	clock      clock.Clock
+
+	deliveries *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+// New returns a Dispatcher customised by the options in input.
+func New(opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		client: client.NewClient(),
+		retry: []retry.Option{
+			retry.WithMaxAttempts(defaultMaxAttempts),
+			retry.WithStrategy(backoff.FullJitter(backoff.Exponential(defaultInitial, defaultMax, 2))),
+		},
+		clock: clock.New(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Deliver sends the webhook to the endpoint, retrying until it is accepted with a 2xx status, the attempts are
+// exhausted or ctx is done. Webhooks which could not be delivered are handed to the dead letter function, if any.
+func (d *Dispatcher) Deliver(ctx context.Context, ep Endpoint, ev Event) error {
+	if ev.ID == "" {
+		ev.ID = id.NewUUIDv4()
+	}
+	start := d.clock.Now()
+	opts := append([]retry.Option{retry.WithClock(d.clock), retry.WithRetryIf(isRetryable)}, d.retry...)
+	attempts := 0
+	err := retry.Do(ctx, func(ctx context.Context) error {
+		attempts++
+		return d.send(ctx, ep, ev)
+	}, opts...)
+	elapsed := d.clock.Since(start)
+
+	result := "delivered"
+	if err != nil {
+		result = "failed"
+	}
+	if d.deliveries != nil {
+		d.deliveries.WithLabelValues(ev.Type, result).Inc()
+		d.duration.WithLabelValues(ev.Type).Observe(elapsed.Seconds())
+	}
+	if d.log != nil {
+		l := d.log.Event(dispatchEvent).Component(ep.ID).Duration(elapsed)
+		if err != nil {
+			l.Error(fmt.Sprintf("Could not deliver webhook %s (%s) to %s after %d attempts", ev.ID, ev.Type, ep.URL, attempts), err)
+		} else {
+			l.Info(fmt.Sprintf("Delivered webhook %s (%s) to %s in %d attempts", ev.ID, ev.Type, ep.URL, attempts))
+		}
+	}
+	if err != nil {
+		if d.deadLetter != nil {
+			d.deadLetter(ctx, ep, ev, err)
+		}
+		return fmt.Errorf("could not deliver webhook %s: %w", ev.ID, err)
+	}
+	return nil
+}
+
+// send makes an attempt to deliver the webhook.
+func (d *Dispatcher) send(ctx context.Context, ep Endpoint, ev Event) error {
+	if d.limiter != nil {
+		if err := d.limiter.Wait(ctx, ep.ID); err != nil {
+			return err
+		}
+	}
+	ts := d.clock.Now()
+	resp, err := d.client.Do(ctx, http.MethodPost, ep.URL,
+		client.WithBody(bytes.NewReader(ev.Payload)),
+		client.WithHeader("Content-Type", "application/json"),
+		client.WithHeader(IDHeader, ev.ID),
+		client.WithHeader(TimestampHeader, strconv.FormatInt(ts.Unix(), 10)),
+		client.WithHeader(SignatureHeader, Sign(ep.Secret, ev.ID, ts, ev.Payload)),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &client.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: body}
+}
+
+// isRetryable reports whether a failed delivery may succeed later: client errors are not retried, except for
+// timeouts and rate limiting.
+func isRetryable(err error) bool {
+	var he *client.HTTPError
+	if errors.As(err, &he) && he.StatusCode >= 400 && he.StatusCode < 500 {
+		return he.StatusCode == http.StatusRequestTimeout || he.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// NewMessage returns a message carrying the webhook for the endpoint in input, to be queued and delivered by the
+// handler returned by Handler, e.g. through an outbox or a consumer.
+func NewMessage(endpointID string, ev Event) *pubsub.Message {
+	if ev.ID == "" {
+		ev.ID = id.NewUUIDv4()
+	}
+	return &pubsub.Message{
+		ID:      ev.ID,
+		Key:     []byte(endpointID),
+		Data:    ev.Payload,
+		Headers: map[string]string{endpointHeader: endpointID, eventTypeHeader: ev.Type},
+	}
+}
+
+// Handler returns a pubsub.Handler delivering the webhooks carried by the messages built by NewMessage, looking up
+// their endpoint with the function in input. It is meant to be run by consumer.Run, so that webhooks are delivered
+// in the background; messages keyed by endpoint keep their order with consumer.WithOrderedKeys.
+// Webhooks which could not be delivered fail the handler after being handed to the dead letter function, if any.
+func (d *Dispatcher) Handler(lookup func(ctx context.Context, endpointID string) (Endpoint, error)) pubsub.Handler {
+	return func(ctx context.Context, msg *pubsub.Message) error {
+		ep, err := lookup(ctx, msg.Headers[endpointHeader])
+		if err != nil {
+			return fmt.Errorf("could not look up webhook endpoint %s: %w", msg.Headers[endpointHeader], err)
+		}
+		return d.Deliver(ctx, ep, Event{ID: msg.ID, Type: msg.Headers[eventTypeHeader], Payload: msg.Data})
+	}
+}
@@ -0,0 +1,91 @@
+package webhookx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/indiependente/pkg/backoff"
+	"github.com/indiependente/pkg/http/client"
+	"github.com/indiependente/pkg/retry"
+)
+
+func TestDeliver(t *testing.T) {
+	secret := []byte("secret")
+	tests := []struct {
+		name         string
+		endpointKey  []byte
+		failures     int32
+		failStatus   int
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{name: "delivered", endpointKey: secret, wantAttempts: 1},
+		{name: "retried", endpointKey: secret, failures: 2, failStatus: http.StatusServiceUnavailable, wantAttempts: 3},
+		{name: "rate limited", endpointKey: secret, failures: 1, failStatus: http.StatusTooManyRequests, wantAttempts: 2},
+		{name: "client error", endpointKey: secret, failures: 1, failStatus: http.StatusBadRequest, wantErr: true, wantAttempts: 1},
+		{name: "signed with the wrong secret", endpointKey: []byte("other"), wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				attempts atomic.Int32
+				received []byte
+			)
+			receiver := VerifyMiddleware([][]byte{secret})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Load() <= tt.failures {
+					w.WriteHeader(tt.failStatus)
+					return
+				}
+				received, _ = io.ReadAll(r.Body)
+			}))
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				receiver.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+
+			var deadLettered error
+			d := New(
+				WithRetry(retry.WithMaxAttempts(3), retry.WithStrategy(backoff.Constant(time.Millisecond))),
+				WithDeadLetter(func(_ context.Context, _ Endpoint, _ Event, err error) { deadLettered = err }),
+			)
+			err := d.Deliver(context.Background(), Endpoint{ID: "ep", URL: srv.URL, Secret: tt.endpointKey},
+				Event{Type: "order.created", Payload: []byte(`{"id":1}`)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Deliver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Fatalf("got %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if tt.wantErr {
+				if deadLettered == nil {
+					t.Fatal("failed webhook was not dead lettered")
+				}
+				return
+			}
+			if string(received) != `{"id":1}` {
+				t.Fatalf("received %q", received)
+			}
+		})
+	}
+}
+
+func TestVerifyMiddlewareRejectsLargeBodies(t *testing.T) {
+	h := VerifyMiddleware([][]byte{[]byte("secret")}, WithMaxBodySize(4))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("the next handler was called")
+	}))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	d := New(WithRetry(retry.WithMaxAttempts(1)))
+	err := d.Deliver(context.Background(), Endpoint{URL: srv.URL, Secret: []byte("secret")}, Event{Payload: []byte(`{"id":1}`)})
+	var he *client.HTTPError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Deliver() error = %v, want status %d", err, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,99 @@
+package webhookx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/indiependente/pkg/clock"
+	"github.com/indiependente/pkg/httpx/respond"
+	"github.com/indiependente/pkg/logger"
+)
+
+const (
+	receiveEvent = "webhook_receiver"
+
+	defaultTolerance   = 5 * time.Minute
+	defaultMaxBodySize = 1 << 20
+)
+
+// VerifierOption customises the verifier middleware.
+type VerifierOption func(*verifierConfig)
+
+type verifierConfig struct {
+	tolerance   time.Duration
+	maxBodySize int64
+	log         logger.Logger
+	clock       clock.Clock
+}
+
+// WithTolerance accepts webhooks whose timestamp is within d of now, 5m by default.
+func WithTolerance(d time.Duration) VerifierOption {
+	return func(c *verifierConfig) {
+		c.tolerance = d
+	}
+}
+
+// WithMaxBodySize rejects webhooks larger than n bytes with 413 Request Entity Too Large, 1MiB by default.
+func WithMaxBodySize(n int64) VerifierOption {
+	return func(c *verifierConfig) {
+		c.maxBodySize = n
+	}
+}
+
+// WithVerifierLogger logs the webhooks rejected.
+func WithVerifierLogger(log logger.Logger) VerifierOption {
+	return func(c *verifierConfig) {
+		c.log = log
+	}
+}
+
+// WithVerifierClock tells the time with the clock in input, e.g. a clock.Fake in tests.
+func WithVerifierClock(c clock.Clock) VerifierOption {
+	return func(cfg *verifierConfig) {
+		cfg.clock = c
+	}
+}
+
+// VerifyMiddleware lets through the webhooks signed with one of the secrets in input, see Verify, answering the
+// others with 401 Unauthorized. The body is buffered to be verified and handed to the next handler as is.
+func VerifyMiddleware(secrets [][]byte, opts ...VerifierOption) func(http.Handler) http.Handler {
+	cfg := &verifierConfig{
+		tolerance:   defaultTolerance,
+		maxBodySize: defaultMaxBodySize,
+		clock:       clock.New(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.maxBodySize))
+			if err != nil {
+				var mbe *http.MaxBytesError
+				if errors.As(err, &mbe) {
+					respond.Error(w, err)
+					return
+				}
+				respond.Error(w, respond.NewProblem(http.StatusBadRequest, "could not read the webhook body"))
+				return
+			}
+			err = Verify(secrets, r.Header.Get(IDHeader), r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader),
+				body, cfg.clock.Now(), cfg.tolerance)
+			if err != nil {
+				if cfg.log != nil {
+					logger.WithTrace(r.Context(), cfg.log.Event(receiveEvent).Method(r.Method).URI(r.RequestURI).RemoteAddr(r.RemoteAddr)).
+						Warn(fmt.Sprintf("Rejected webhook %s: %v", r.Header.Get(IDHeader), err))
+				}
+				respond.Error(w, respond.NewProblem(http.StatusUnauthorized, err.Error()))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package webhookx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers carrying the signature of a webhook, following the Standard Webhooks specification.
+const (
+	IDHeader        = "Webhook-Id"
+	TimestampHeader = "Webhook-Timestamp"
+	SignatureHeader = "Webhook-Signature"
+
+	// signatureVersion prefixes the signatures in the Webhook-Signature header.
+	signatureVersion = "v1"
+)
+
+var (
+	// ErrMissingHeaders is returned when a webhook lacks the ID, timestamp or signature header.
+	ErrMissingHeaders = errors.New("missing webhook signature headers")
+	// ErrInvalidTimestamp is returned when the timestamp of a webhook cannot be parsed or is out of tolerance.
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+	// ErrInvalidSignature is returned when no signature of a webhook matches its content.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Sign returns the value of the Webhook-Signature header of the webhook with the ID, timestamp and body in input:
+// the base64 HMAC-SHA256 of "<id>.<unix timestamp>.<body>", prefixed by the version.
+func Sign(secret []byte, id string, ts time.Time, body []byte) string {
+	return signatureVersion + "," + base64.StdEncoding.EncodeToString(mac(secret, id, strconv.FormatInt(ts.Unix(), 10), body))
+}
+
+// Verify checks the signature header of the webhook with the ID, timestamp header and body in input against each
+// secret, so that secrets can be rotated, returning ErrInvalidSignature if none matches. The timestamp must be
+// within tolerance of now, to limit replays; a zero tolerance disables the check.
+func Verify(secrets [][]byte, id, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	if id == "" || timestamp == "" || signature == "" {
+		return ErrMissingHeaders
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidTimestamp, timestamp)
+	}
+	if tolerance > 0 {
+		if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+			return fmt.Errorf("%w: %s away from now", ErrInvalidTimestamp, d.Round(time.Second))
+		}
+	}
+	for _, secret := range secrets {
+		expected := mac(secret, id, timestamp, body)
+		// the header may list several space separated signatures, e.g. while the sender rotates its secret
+		for _, sig := range strings.Fields(signature) {
+			version, value, ok := strings.Cut(sig, ",")
+			if !ok || version != signatureVersion {
+				continue
+			}
+			got, err := base64.StdEncoding.DecodeString(value)
+			if err == nil && hmac.Equal(got, expected) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret []byte, id, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(id + "." + timestamp + "."))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,99 @@
+package webhookx
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secret, rotated := []byte("secret"), []byte("rotated secret")
+	id, body := "msg_1", []byte(`{"amount":1}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign(secret, id, now, body)
+
+	tests := []struct {
+		name      string
+		secrets   [][]byte
+		id        string
+		timestamp string
+		signature string
+		body      []byte
+		now       time.Time
+		wantErr   error
+	}{
+		{name: "valid", secrets: [][]byte{secret}, id: id, timestamp: ts, signature: sig, body: body, now: now},
+		{
+			name: "secret being rotated by the receiver", secrets: [][]byte{rotated, secret},
+			id: id, timestamp: ts, signature: sig, body: body, now: now,
+		},
+		{
+			name: "secret being rotated by the sender", secrets: [][]byte{secret},
+			id: id, timestamp: ts, signature: Sign(rotated, id, now, body) + " " + sig, body: body, now: now,
+		},
+		{
+			name: "within tolerance", secrets: [][]byte{secret},
+			id: id, timestamp: ts, signature: sig, body: body, now: now.Add(4 * time.Minute),
+		},
+		{
+			name: "tampered body", secrets: [][]byte{secret},
+			id: id, timestamp: ts, signature: sig, body: []byte(`{"amount":100}`), now: now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "tampered ID", secrets: [][]byte{secret},
+			id: "msg_2", timestamp: ts, signature: sig, body: body, now: now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "tampered timestamp", secrets: [][]byte{secret},
+			id: id, timestamp: strconv.FormatInt(now.Unix()+1, 10), signature: sig, body: body, now: now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "wrong secret", secrets: [][]byte{rotated},
+			id: id, timestamp: ts, signature: sig, body: body, now: now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "unknown version", secrets: [][]byte{secret},
+			id: id, timestamp: ts, signature: "v2" + sig[2:], body: body, now: now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "malformed signature", secrets: [][]byte{secret},
+			id: id, timestamp: ts, signature: "v1,not base64!", body: body, now: now,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "replayed after the tolerance", secrets: [][]byte{secret},
+			id: id, timestamp: ts, signature: sig, body: body, now: now.Add(6 * time.Minute),
+			wantErr: ErrInvalidTimestamp,
+		},
+		{
+			name: "timestamp in the future", secrets: [][]byte{secret},
+			id: id, timestamp: ts, signature: sig, body: body, now: now.Add(-6 * time.Minute),
+			wantErr: ErrInvalidTimestamp,
+		},
+		{
+			name: "malformed timestamp", secrets: [][]byte{secret},
+			id: id, timestamp: "yesterday", signature: sig, body: body, now: now,
+			wantErr: ErrInvalidTimestamp,
+		},
+		{
+			name: "missing signature", secrets: [][]byte{secret},
+			id: id, timestamp: ts, body: body, now: now,
+			wantErr: ErrMissingHeaders,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secrets, tt.id, tt.timestamp, tt.signature, tt.body, tt.now, 5*time.Minute)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
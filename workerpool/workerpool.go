@@ -0,0 +1,252 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/conc"
+	"github.com/indiependente/pkg/metrics"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ErrStopped is returned when submitting a job to a stopped pool.
+	ErrStopped = errors.New("worker pool is stopped")
+	// ErrPanic is wrapped by the error of jobs which panicked, a *conc.PanicError carrying the stack trace.
+	ErrPanic = conc.ErrPanic
+)
+
+// Func processes a job.
+type Func[T, R any] func(ctx context.Context, job T) (R, error)
+
+// Result is the outcome of a job.
+type Result[T, R any] struct {
+	Job   T
+	Value R
+	Err   error
+	// Latency is the time elapsed between the submission and the completion of the job.
+	Latency time.Duration
+}
+
+// Option customises a pool.
+type Option func(*config)
+
+type config struct {
+	queueSize  int
+	jobTimeout time.Duration
+	registerer prometheus.Registerer
+	name       string
+}
+
+// WithQueueSize sets how many submitted jobs can wait for a free worker before Submit blocks. Defaults to the pool size.
+func WithQueueSize(n int) Option {
+	return func(c *config) {
+		c.queueSize = n
+	}
+}
+
+// WithJobTimeout cancels the context of each job after d.
+func WithJobTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.jobTimeout = d
+	}
+}
+
+// WithMetrics registers the queue length and job latency metrics of the pool, labeled by name, with the registerer in input.
+func WithMetrics(registerer prometheus.Registerer, name string) Option {
+	return func(c *config) {
+		c.registerer = registerer
+		c.name = name
+	}
+}
+
+type task[T any] struct {
+	ctx       context.Context
+	job       T
+	submitted time.Time
+}
+
+// Pool runs jobs on a fixed number of workers.
+// Results are delivered on the Results channel, unless a callback is set with OnResult, and must be consumed
+// for the workers to make progress.
+type Pool[T, R any] struct {
+	fn       Func[T, R]
+	cfg      *config
+	queue    chan task[T]
+	results  chan Result[T, R]
+	onResult func(Result[T, R])
+
+	mu         sync.RWMutex
+	stopped    bool
+	stop       chan struct{}
+	submitters sync.WaitGroup
+	wg         sync.WaitGroup
+	once       sync.Once
+
+	queued  prometheus.Gauge
+	latency prometheus.Observer
+}
+
+// New returns a pool running fn on size workers.
+func New[T, R any](size int, fn Func[T, R], opts ...Option) *Pool[T, R] {
+	if size < 1 {
+		size = 1
+	}
+	cfg := &config{queueSize: size}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	p := &Pool[T, R]{
+		fn:      fn,
+		cfg:     cfg,
+		queue:   make(chan task[T], cfg.queueSize),
+		results: make(chan Result[T, R], size),
+		stop:    make(chan struct{}),
+	}
+	if cfg.registerer != nil {
+		p.queued = metrics.Register(cfg.registerer, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "workerpool_queue_length",
+			Help: "Number of jobs waiting for a free worker.",
+		}, []string{"pool"})).WithLabelValues(cfg.name)
+		p.latency = metrics.Register(cfg.registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "workerpool_job_duration_seconds",
+			Help:    "Time elapsed between the submission and the completion of jobs.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"pool"})).WithLabelValues(cfg.name)
+	}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// OnResult delivers the results to fn instead of the Results channel. It must be called before submitting jobs.
+func (p *Pool[T, R]) OnResult(fn func(Result[T, R])) *Pool[T, R] {
+	p.onResult = fn
+	return p
+}
+
+// Results returns the channel the results are delivered on. It is closed once the pool is stopped and drained.
+func (p *Pool[T, R]) Results() <-chan Result[T, R] {
+	return p.results
+}
+
+// Submit queues the job, blocking until a slot in the queue is free, ctx is done or the pool is stopped.
+// The job runs with a context derived from ctx, so cancelling it cancels the job too.
+func (p *Pool[T, R]) Submit(ctx context.Context, job T) error {
+	p.mu.RLock()
+	if p.stopped {
+		p.mu.RUnlock()
+		return ErrStopped
+	}
+	p.submitters.Add(1)
+	p.mu.RUnlock()
+	defer p.submitters.Done()
+	select {
+	case p.queue <- task[T]{ctx: ctx, job: job, submitted: time.Now()}:
+		if p.queued != nil {
+			p.queued.Inc()
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.stop:
+		return ErrStopped
+	}
+}
+
+// SubmitAll submits the jobs yielded by the sequence in input, e.g. the rows of a csvx.Stream or of an
+// ndjson.Decoder, blocking whenever the queue is full so that jobs are read no faster than they are processed.
+// It stops at the first error, either yielded by the sequence or returned by Submit, and returns it.
+func (p *Pool[T, R]) SubmitAll(ctx context.Context, jobs iter.Seq2[T, error]) error {
+	for job, err := range jobs {
+		if err != nil {
+			return err
+		}
+		if err := p.Submit(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops accepting jobs and waits for the queued ones to complete, or for ctx to be done.
+func (p *Pool[T, R]) Stop(ctx context.Context) error {
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.stopped = true
+		close(p.stop)
+		p.mu.Unlock()
+		go func() {
+			// the queue is closed once the submitters blocked on it have given up
+			p.submitters.Wait()
+			close(p.queue)
+			p.wg.Wait()
+			close(p.results)
+		}()
+	})
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not drain worker pool: %w", ctx.Err())
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn stopping the pool within timeout.
+func (p *Pool[T, R]) TerminationFn(timeout time.Duration) shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return p.Stop(ctx)
+	}
+}
+
+func (p *Pool[T, R]) work() {
+	defer p.wg.Done()
+	for t := range p.queue {
+		if p.queued != nil {
+			p.queued.Dec()
+		}
+		value, err := p.run(t)
+		res := Result[T, R]{Job: t.job, Value: value, Err: err, Latency: time.Since(t.submitted)}
+		if p.latency != nil {
+			p.latency.Observe(res.Latency.Seconds())
+		}
+		if p.onResult != nil {
+			p.onResult(res)
+			continue
+		}
+		p.results <- res
+	}
+}
+
+// run executes the job, turning panics into errors wrapping ErrPanic.
+func (p *Pool[T, R]) run(t task[T]) (value R, err error) {
+	ctx := t.ctx
+	if p.cfg.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.jobTimeout)
+		defer cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return value, err
+	}
+	err = conc.Catch(func() error {
+		var err error
+		value, err = p.fn(ctx, t.job)
+		return err
+	})
+	return value, err
+}
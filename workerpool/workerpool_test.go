@@ -0,0 +1,60 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStopWithBlockedSubmitter(t *testing.T) {
+	p := New(1, func(_ context.Context, job int) (int, error) {
+		return job, nil
+	}, WithQueueSize(1))
+	ctx := context.Background()
+	// results are never drained: the worker blocks on the second result and the third job fills the queue
+	for i := 0; i < 3; i++ {
+		if err := p.Submit(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- p.Submit(ctx, 3)
+	}()
+
+	stopCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- p.Stop(stopCtx)
+	}()
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Stop() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop() did not return by its deadline")
+	}
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, ErrStopped) {
+			t.Fatalf("Submit() error = %v, want %v", err, ErrStopped)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Submit() still blocked after Stop()")
+	}
+	if err := p.Submit(ctx, 4); !errors.Is(err, ErrStopped) {
+		t.Fatalf("Submit() after Stop() error = %v, want %v", err, ErrStopped)
+	}
+
+	// once the results are drained the queued jobs complete and the results channel is closed
+	var got int
+	for range p.Results() {
+		got++
+	}
+	if got != 3 {
+		t.Fatalf("got %d results, want 3", got)
+	}
+}
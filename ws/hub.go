@@ -0,0 +1,121 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/shutdown"
+)
+
+// Hub tracks a set of connections to broadcast messages to them and close them on shutdown.
+// The zero value is not usable, use NewHub.
+type Hub struct {
+	mu     sync.Mutex
+	conns  map[*Conn]struct{}
+	closed bool
+}
+
+// NewHub returns an empty Hub.
+func NewHub() *Hub {
+	return &Hub{conns: make(map[*Conn]struct{})}
+}
+
+// Add tracks the connection until it is closed. Once the hub is closed, the connection is closed right away
+// and ErrClosed is returned.
+func (h *Hub) Add(c *Conn) error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		_ = c.Close(CloseGoingAway, "shutting down")
+		return ErrClosed
+	}
+	h.conns[c] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-c.Done()
+		h.Remove(c)
+	}()
+	return nil
+}
+
+// Remove stops tracking the connection, without closing it.
+func (h *Hub) Remove(c *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, c)
+}
+
+// Len returns the number of connections tracked.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns)
+}
+
+// Broadcast queues the message on every connection without blocking.
+// Connections whose send queue is full are too slow to keep up: they are closed with a policy violation
+// and their number is returned.
+func (h *Hub) Broadcast(typ int, data []byte) int {
+	dropped := 0
+	for _, c := range h.snapshot() {
+		if err := c.TrySend(typ, data); errors.Is(err, ErrQueueFull) {
+			dropped++
+			h.Remove(c)
+			go c.Close(ClosePolicyViolation, "too slow") // nolint:errcheck
+		}
+	}
+	return dropped
+}
+
+// Close closes every connection with a going away close frame, flushing their send queues,
+// and rejects the connections added afterwards. It returns once all of them are closed or ctx is done.
+func (h *Hub) Close(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	h.mu.Unlock()
+
+	conns := h.snapshot()
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for _, c := range conns {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = c.Close(CloseGoingAway, "shutting down")
+			}()
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not close connections: %w", ctx.Err())
+	}
+}
+
+// TerminationFn returns a shutdown.TerminationFn closing the hub within timeout.
+// The http.Server does not track hijacked connections, so the hub must be closed alongside it.
+func (h *Hub) TerminationFn(timeout time.Duration) shutdown.TerminationFn {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return h.Close(ctx)
+	}
+}
+
+func (h *Hub) snapshot() []*Conn {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conns := make([]*Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
@@ -0,0 +1,381 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultPingInterval = 30 * time.Second
+	defaultPongTimeout  = 60 * time.Second
+	defaultWriteTimeout = 10 * time.Second
+	defaultReadLimit    = 1 << 20
+	defaultSendQueue    = 64
+	closeGrace          = time.Second
+)
+
+// Message types, as defined by RFC 6455.
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+// Close codes, as defined by RFC 6455.
+const (
+	CloseNormalClosure     = websocket.CloseNormalClosure
+	CloseGoingAway         = websocket.CloseGoingAway
+	ClosePolicyViolation   = websocket.ClosePolicyViolation
+	CloseMessageTooBig     = websocket.CloseMessageTooBig
+	CloseInternalServerErr = websocket.CloseInternalServerErr
+)
+
+var (
+	// ErrClosed is returned when sending on a closed connection.
+	ErrClosed = errors.New("connection closed")
+	// ErrQueueFull is returned by TrySend when the send queue of the connection is full.
+	ErrQueueFull = errors.New("send queue full")
+)
+
+// Option customises the connections returned by Upgrade and Dial.
+type Option func(*config)
+
+type config struct {
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	writeTimeout time.Duration
+	readLimit    int64
+	sendQueue    int
+	checkOrigin  func(r *http.Request) bool
+	subprotocols []string
+	compression  bool
+	header       http.Header
+}
+
+// WithPingInterval pings the peer every d. Defaults to 30s.
+func WithPingInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.pingInterval = d
+	}
+}
+
+// WithPongTimeout fails reads when nothing, pongs included, is received from the peer for d. Defaults to 60s.
+// It must be longer than the ping interval.
+func WithPongTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.pongTimeout = d
+	}
+}
+
+// WithWriteTimeout fails writes not completed within d, closing the connection. Defaults to 10s.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.writeTimeout = d
+	}
+}
+
+// WithReadLimit closes the connection when the peer sends a message larger than n bytes. Defaults to 1MiB.
+func WithReadLimit(n int64) Option {
+	return func(c *config) {
+		c.readLimit = n
+	}
+}
+
+// WithSendQueue buffers up to n outgoing messages per connection. Defaults to 64.
+func WithSendQueue(n int) Option {
+	return func(c *config) {
+		c.sendQueue = n
+	}
+}
+
+// WithCheckOrigin accepts the upgrade requests for which fn returns true.
+// By default only requests without an Origin header or with one matching the Host are accepted.
+func WithCheckOrigin(fn func(r *http.Request) bool) Option {
+	return func(c *config) {
+		c.checkOrigin = fn
+	}
+}
+
+// WithSubprotocols negotiates the subprotocols in input, in order of preference.
+func WithSubprotocols(protocols ...string) Option {
+	return func(c *config) {
+		c.subprotocols = protocols
+	}
+}
+
+// WithCompression negotiates per-message compression.
+func WithCompression() Option {
+	return func(c *config) {
+		c.compression = true
+	}
+}
+
+// WithHeader sends the header in input with the handshake response, or request for Dial.
+func WithHeader(h http.Header) Option {
+	return func(c *config) {
+		c.header = h
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		pingInterval: defaultPingInterval,
+		pongTimeout:  defaultPongTimeout,
+		writeTimeout: defaultWriteTimeout,
+		readLimit:    defaultReadLimit,
+		sendQueue:    defaultSendQueue,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+type outgoing struct {
+	typ  int
+	data []byte
+}
+
+// Conn is a WebSocket connection with keepalive, deadlines and a bounded send queue.
+// Messages are sent by a dedicated goroutine, so Send can be called concurrently; reads must be done
+// by a single goroutine, which must keep reading for pongs and close frames to be processed.
+type Conn struct {
+	ws  *websocket.Conn
+	cfg *config
+
+	send      chan outgoing
+	closing   chan closeFrame
+	done      chan struct{}
+	closeOnce sync.Once
+	errOnce   sync.Once
+	err       error
+}
+
+type closeFrame struct {
+	code   int
+	reason string
+}
+
+// Upgrade upgrades the HTTP request to a WebSocket connection.
+// On failure an HTTP error has already been written to w.
+func Upgrade(w http.ResponseWriter, r *http.Request, opts ...Option) (*Conn, error) {
+	cfg := newConfig(opts)
+	up := websocket.Upgrader{
+		CheckOrigin:       cfg.checkOrigin,
+		Subprotocols:      cfg.subprotocols,
+		EnableCompression: cfg.compression,
+	}
+	c, err := up.Upgrade(w, r, cfg.header)
+	if err != nil {
+		return nil, fmt.Errorf("could not upgrade connection: %w", err)
+	}
+	return newConn(c, cfg), nil
+}
+
+// Dial connects to the WebSocket server at url, e.g. wss://example.com/ws.
+func Dial(ctx context.Context, url string, opts ...Option) (*Conn, error) {
+	cfg := newConfig(opts)
+	d := websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  cfg.writeTimeout,
+		Subprotocols:      cfg.subprotocols,
+		EnableCompression: cfg.compression,
+	}
+	c, resp, err := d.DialContext(ctx, url, cfg.header)
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not dial %s: %w", url, err)
+	}
+	return newConn(c, cfg), nil
+}
+
+func newConn(ws *websocket.Conn, cfg *config) *Conn {
+	c := &Conn{
+		ws:      ws,
+		cfg:     cfg,
+		send:    make(chan outgoing, cfg.sendQueue),
+		closing: make(chan closeFrame, 1),
+		done:    make(chan struct{}),
+	}
+	ws.SetReadLimit(cfg.readLimit)
+	_ = ws.SetReadDeadline(time.Now().Add(cfg.pongTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(cfg.pongTimeout))
+	})
+	go c.writeLoop()
+	return c
+}
+
+// writeLoop writes the queued messages and the pings, until the connection is closed or a write fails.
+func (c *Conn) writeLoop() {
+	ticker := time.NewTicker(c.cfg.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(c.cfg.writeTimeout))
+			if err := c.ws.WriteMessage(msg.typ, msg.data); err != nil {
+				c.fail(fmt.Errorf("could not write message: %w", err))
+				return
+			}
+		case <-ticker.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.cfg.writeTimeout)); err != nil {
+				c.fail(fmt.Errorf("could not ping: %w", err))
+				return
+			}
+		case f := <-c.closing:
+			// flush what is already queued, then say goodbye
+			for flushed := false; !flushed; {
+				select {
+				case msg := <-c.send:
+					_ = c.ws.SetWriteDeadline(time.Now().Add(c.cfg.writeTimeout))
+					if err := c.ws.WriteMessage(msg.typ, msg.data); err != nil {
+						flushed = true
+					}
+				default:
+					flushed = true
+				}
+			}
+			_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(f.code, f.reason),
+				time.Now().Add(c.cfg.writeTimeout))
+			select {
+			case <-c.done:
+			case <-time.After(closeGrace):
+			}
+			c.fail(ErrClosed)
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// fail records the error and closes the underlying connection.
+func (c *Conn) fail(err error) {
+	c.errOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		_ = c.ws.Close()
+	})
+}
+
+// Err returns the error which closed the connection, or nil while it is open.
+func (c *Conn) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Done returns a channel closed once the connection is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *Conn) Subprotocol() string {
+	return c.ws.Subprotocol()
+}
+
+// Send queues the message for sending, blocking while the send queue is full until ctx is done.
+func (c *Conn) Send(ctx context.Context, typ int, data []byte) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case c.send <- outgoing{typ: typ, data: data}:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySend queues the message for sending without blocking, returning ErrQueueFull if the send queue is full.
+func (c *Conn) TrySend(typ int, data []byte) error {
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case c.send <- outgoing{typ: typ, data: data}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// SendJSON queues the JSON encoding of v as a text message.
+func (c *Conn) SendJSON(ctx context.Context, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not encode message: %w", err)
+	}
+	return c.Send(ctx, TextMessage, b)
+}
+
+// Read returns the next data message, extending the read deadline.
+// It returns a *websocket.CloseError when the peer closes the connection.
+func (c *Conn) Read() (int, []byte, error) {
+	typ, data, err := c.ws.ReadMessage()
+	if err != nil {
+		c.fail(err)
+		return 0, nil, err
+	}
+	_ = c.ws.SetReadDeadline(time.Now().Add(c.cfg.pongTimeout))
+	return typ, data, nil
+}
+
+// ReadJSON decodes the next data message into v.
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, data, err := c.Read()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("could not decode message: %w", err)
+	}
+	return nil
+}
+
+// Close sends the queued messages and a close frame with the code and reason in input,
+// then closes the connection once the peer acknowledges or after a grace period.
+func (c *Conn) Close(code int, reason string) error {
+	c.closeOnce.Do(func() {
+		c.closing <- closeFrame{code: code, reason: reason}
+	})
+	<-c.done
+	return nil
+}
+
+// IsCloseError reports whether the error is a close frame with one of the codes in input,
+// or any close frame if no code is given.
+func IsCloseError(err error, codes ...int) bool {
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}